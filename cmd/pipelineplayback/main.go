@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+
+	"rvpro3/radarvision.com/internal/models/servicemodel"
+	"rvpro3/radarvision.com/internal/smartmicro/triggerpipeline"
+	"rvpro3/radarvision.com/internal/smartmicro/udp/broker"
+	"rvpro3/radarvision.com/utils"
+)
+
+func main() {
+	utils.Print.Ln("Radar Vision")
+	utils.Print.Ln("RVPro Trigger Pipeline Playback - Copyright Radar Vision 2026")
+
+	fileName := utils.Args.GetString("--timeline|-t", "")
+
+	if fileName == "" || utils.Args.Has("--help|-h") {
+		showHelp()
+		os.Exit(0)
+	}
+
+	frames, err := triggerpipeline.TriggerFrameHelper.LoadFile(fileName)
+	if err != nil {
+		utils.Print.ErrorLn("Unable to load timeline", err)
+		os.Exit(1)
+	}
+
+	if len(frames) == 0 {
+		utils.Print.WarnLn("Timeline is empty", fileName)
+		os.Exit(0)
+	}
+
+	playback := triggerpipeline.NewTriggerPlayback(buildPipeline(&frames[0]))
+	diffs := playback.Run(frames)
+
+	for _, diff := range diffs {
+		utils.Print.Ln(diff.String())
+	}
+
+	utils.Print.InfoLn("Frames:", len(frames), "Differences:", len(diffs))
+
+	if len(diffs) > 0 {
+		os.Exit(2)
+	}
+}
+
+func showHelp() {
+	utils.Print.Ln("Usage:", os.Args[0], "--timeline=<pipeline.jsonl> [options]")
+	utils.Print.Ln("Options:")
+	utils.Print.Option("--timeline|-t")
+	utils.Print.Descrp("The timeline recorded with radar.pipeline.recorder.enabled")
+	utils.Print.Sample("Sample: --timeline=pipeline-20260101T120000.jsonl")
+
+	utils.Print.Option("--cfg|-c")
+	utils.Print.Descrp("The radar channel configuration (json) to play the timeline against")
+	utils.Print.Sample("Sample: --cfg=config.json")
+	utils.Print.Sample("Default: [empty] - the default configuration")
+
+	utils.Print.Ln("Exits with 2 when the playback differs from the recording")
+}
+
+// buildPipeline builds the pipeline for the radars found in the timeline
+func buildPipeline(frame *triggerpipeline.TriggerFrame) *triggerpipeline.TriggerPipeline {
+	settings := &utils.GlobalSettings
+	settings.Basic.Set("startup.cfg.file", utils.Args.GetString("--cfg|-c", ""))
+	settings.ReadArgs()
+
+	config, err := servicemodel.SettingsBuilder.Build(settings)
+	if err != nil {
+		utils.Print.ErrorLn("Unable to load channel configuration", err)
+		os.Exit(1)
+	}
+
+	pipeline := new(triggerpipeline.TriggerPipeline)
+	built := make(map[string]bool)
+
+	for _, item := range frame.Items {
+		if built[item.RadarIP] {
+			continue
+		}
+		built[item.RadarIP] = true
+
+		radarCfg := config.GetRadarByIP(utils.IP4Builder.FromString(item.RadarIP))
+		if radarCfg == nil {
+			utils.Print.WarnLn("Radar not configured", item.RadarIP)
+			continue
+		}
+
+		broker.PipelineBuilder.Build(pipeline, radarCfg)
+	}
+
+	return pipeline
+}
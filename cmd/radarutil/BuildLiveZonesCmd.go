@@ -47,7 +47,7 @@ func (s *BuildLiveZonesCmd) Init(params *radarUtilParams) {
 		insService.OnResponse = s.onInstructionResponse
 	}
 
-	s.dataService.OnData = s.onDataServiceDataCallback
+	s.dataService.RegisterReceiver(s.onDataServiceDataCallback)
 	s.quitStrategy.OnDone = func(strategy *QuitStrategy) {
 		Terminal.Println("Quitting LiveConfig.")
 		for i := range s.insServices {
@@ -44,7 +44,7 @@ func (s *ListRadarsCmd) Init(params *radarUtilParams) {
 		s.waitGroup.Done()
 	}
 
-	s.dataService.OnData = func(dataService *service.UDPDataService, addr net.UDPAddr, bytes []byte) {
+	s.dataService.RegisterReceiver(func(dataService *service.UDPDataService, addr net.UDPAddr, bytes []byte) {
 		ip4 := utils.IP4Builder.FromIP(addr.IP, addr.Port)
 		_, ok := s.radarIPs[ip4]
 
@@ -60,7 +60,7 @@ func (s *ListRadarsCmd) Init(params *radarUtilParams) {
 			Terminal.Println("Received data from", addr.String(), "with protocol", th.ProtocolType.String())
 			s.quitStrategy.Iterate()
 		}
-	}
+	})
 
 	s.dataService.OnError = func(dataService *service.UDPDataService, err error) {
 		Terminal.PrintErrMsg("Program abort due to error:")
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"rvpro3/radarvision.com/internal/smartmicro/instruction/backup"
+	"rvpro3/radarvision.com/internal/smartmicro/port"
+	"rvpro3/radarvision.com/internal/smartmicro/service"
+	"rvpro3/radarvision.com/utils"
+)
+
+// RadarBackupCmd saves (or restores) a parameter snapshot per radar, the
+// radars are handled as soon as data is received from them
+type RadarBackupCmd struct {
+	clientId         uint32
+	targetIP         utils.IP4
+	isRestore        bool
+	snapshotPattern  string
+	aliveService     service.UDPKeepAliveService
+	dataService      service.UDPDataService
+	insServices      [4]service.Instruction
+	insStarteds      [4]bool
+	waitGroup        sync.WaitGroup
+	radarWaitGroup   sync.WaitGroup
+	quitStrategy     QuitStrategy
+	lock             sync.Mutex
+	failedRadarCount int
+}
+
+func (s *RadarBackupCmd) Init(params *radarUtilParams, isRestore bool) {
+	s.quitStrategy = params.GetQuitStrategy()
+	s.clientId = params.GetClientId()
+	s.targetIP = params.GetTargetIP()
+	s.snapshotPattern = params.GetSnapshotPattern()
+	s.isRestore = isRestore
+
+	s.waitGroup.Add(2)
+
+	s.dataService.InitFromSettings(&utils.GlobalSettings)
+	s.aliveService.InitFromSettings(&utils.GlobalSettings)
+
+	for i := range s.insServices {
+		insService := &s.insServices[i]
+		insService.Init()
+		insService.ResendsCooldownMs = 2000
+		insService.IdleCooldownMs = 10
+		insService.Start(&s.dataService, utils.RadarIPOf(i))
+	}
+
+	s.dataService.RegisterReceiver(s.onDataServiceDataCallback)
+	s.quitStrategy.OnDone = func(strategy *QuitStrategy) {
+		// Radars already being handled are completed first
+		s.radarWaitGroup.Wait()
+
+		for i := range s.insServices {
+			insService := &s.insServices[i]
+			insService.Stop()
+		}
+		s.aliveService.Stop()
+		s.dataService.Stop()
+	}
+
+	s.aliveService.OnTerminate = func(aliveService *service.UDPKeepAliveService) {
+		s.waitGroup.Done()
+	}
+
+	s.dataService.OnTerminate = func(dataService *service.UDPDataService) {
+		s.waitGroup.Done()
+	}
+}
+
+func (s *RadarBackupCmd) onDataServiceDataCallback(ds *service.UDPDataService, addr net.UDPAddr, bytes []byte) {
+	radarIP4 := utils.IP4Builder.FromIP(addr.IP, addr.Port)
+	radarIndex := utils.RadarIndexOf(radarIP4.ToU32())
+
+	if radarIndex == -1 {
+		return
+	}
+
+	insService := &s.insServices[radarIndex]
+
+	s.lock.Lock()
+	if !s.insStarteds[radarIndex] {
+		s.insStarteds[radarIndex] = true
+		s.radarWaitGroup.Add(1)
+		go s.execute(insService)
+	}
+	s.lock.Unlock()
+
+	th := port.TransportHeader{}
+	ph := port.PortHeader{}
+	reader := utils.NewFixedBuffer(bytes[:], 0, len(bytes))
+
+	th.Read(&reader)
+	ph.Read(&reader)
+
+	if ph.Identifier == port.PiInstruction {
+		reader.ResetTo(0, len(bytes))
+		ins := &port.Instruction{}
+
+		if err := ins.Read(&reader); err != nil {
+			log.Err(err).Msg("Error reading instruction")
+			return
+		}
+
+		insService.EnqueueReceive(ins)
+	}
+}
+
+func (s *RadarBackupCmd) execute(insService *service.Instruction) {
+	defer s.radarWaitGroup.Done()
+
+	radarIP := insService.RadarIP.ToIPString()
+	fileName := backup.FileNameOf(s.snapshotPattern, radarIP)
+
+	radarBackup := backup.RadarBackup{
+		Client:   backup.NewInstructionClient(insService, 10*time.Second, 5),
+		ClientId: s.clientId,
+		RadarIP:  insService.RadarIP,
+	}
+
+	var err error
+	if s.isRestore {
+		err = s.restore(&radarBackup, fileName)
+	} else {
+		err = s.backup(&radarBackup, fileName)
+	}
+
+	if err != nil {
+		Terminal.PrintErrMsg("Radar", radarIP, "failed")
+		Terminal.PrintErr(err)
+
+		s.lock.Lock()
+		s.failedRadarCount++
+		s.lock.Unlock()
+	}
+	s.quitStrategy.Iterate()
+}
+
+func (s *RadarBackupCmd) backup(radarBackup *backup.RadarBackup, fileName string) error {
+	radarIP := radarBackup.RadarIP.ToIPString()
+	Terminal.Println("Backing up radar", radarIP)
+
+	snapshot, err := radarBackup.Backup(time.Now())
+	if err != nil {
+		return err
+	}
+
+	if err = snapshot.Save(fileName); err != nil {
+		return err
+	}
+
+	Terminal.Println(len(snapshot.Parameters), "parameters of radar", radarIP, "saved to", fileName)
+	return nil
+}
+
+func (s *RadarBackupCmd) restore(radarBackup *backup.RadarBackup, fileName string) error {
+	radarIP := radarBackup.RadarIP.ToIPString()
+
+	snapshot, err := backup.LoadSnapshot(fileName)
+	if err != nil {
+		return err
+	}
+
+	Terminal.Println("Restoring radar", radarIP, "from", fileName, "taken", snapshot.CreateOn.Format(time.DateTime))
+
+	count, mismatches, err := radarBackup.Restore(snapshot)
+	for _, mismatch := range mismatches {
+		Terminal.PrintfLnKv(
+			mismatch.Parameter.String(),
+			"expected %s, radar has %s",
+			mismatch.Parameter.Value,
+			mismatch.Actual,
+		)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	Terminal.Println(count, "parameters of radar", radarIP, "restored and verified")
+	return nil
+}
+
+func (s *RadarBackupCmd) Execute() {
+	s.quitStrategy.PrintDetail(&Terminal)
+
+	Terminal.Println("Starting Alive Service")
+	Terminal.Indent(2)
+	Terminal.PrintfLnKv("Target RVProIP", "%s", s.targetIP.String())
+	Terminal.PrintfLnKv("Client ID", "0x%x", s.clientId)
+	Terminal.PrintfLnKv("Snapshot", "%s", s.snapshotPattern)
+	s.aliveService.Start(&utils.GlobalState, &utils.GlobalSettings)
+
+	Terminal.Indent(-2)
+	Terminal.Println("Starting data Service")
+	s.dataService.Start(&utils.GlobalState, &utils.GlobalSettings)
+
+	Terminal.Indent(2)
+	Terminal.PrintfLnKv("Listening on", "%s", s.dataService.ListenAddr.String())
+	Terminal.Indent(-2)
+
+	s.quitStrategy.Start()
+
+	s.waitGroup.Wait()
+
+	if s.failedRadarCount > 0 {
+		Terminal.Println(s.failedRadarCount, "radar(s) failed!!!")
+	}
+}
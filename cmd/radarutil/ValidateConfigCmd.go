@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"rvpro3/radarvision.com/internal/models/servicemodel"
+)
+
+// ValidateConfigCmd validates an rvpro channel configuration file, printing
+// the errors and warnings by path.  The exit code is 1 on errors
+type ValidateConfigCmd struct {
+	configFilename string
+	isJSON         bool
+	result         *servicemodel.ValidationResult
+}
+
+func (s *ValidateConfigCmd) Init(params *radarUtilParams) {
+	s.configFilename = params.GetConfigFilename()
+	s.isJSON = params.IsJSON()
+}
+
+func (s *ValidateConfigCmd) Execute() int {
+	data, err := os.ReadFile(s.configFilename)
+	if err != nil {
+		Terminal.PrintErr(err)
+		return 1
+	}
+
+	_, s.result = servicemodel.ConfigValidator.ValidateJSON(data)
+
+	if s.isJSON {
+		s.printJSON()
+	} else {
+		s.print()
+	}
+
+	if s.result.HasErrors() {
+		return 1
+	}
+	return 0
+}
+
+func (s *ValidateConfigCmd) print() {
+	Terminal.Println("Validating", s.configFilename)
+	Terminal.Indent(2)
+
+	for _, issue := range s.result.Issues {
+		Terminal.PrintfLn("%-7s %s", issue.Severity, issue)
+	}
+
+	Terminal.Indent(-2)
+	Terminal.PrintfLn("%d error(s), %d warning(s)", len(s.result.Errors()), len(s.result.Warnings()))
+}
+
+func (s *ValidateConfigCmd) printJSON() {
+	data, err := json.MarshalIndent(s.result, "", "  ")
+	if err != nil {
+		Terminal.PrintErr(err)
+		return
+	}
+	fmt.Println(string(data))
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"os"
 	"strings"
 
 	"rvpro3/radarvision.com/utils"
@@ -14,6 +15,9 @@ const (
 	cmdHelp radarUtilCommand = iota
 	cmdListRadars
 	cmdLiveZones
+	cmdBackup
+	cmdRestore
+	cmdValidate
 )
 
 type terminal struct {
@@ -72,14 +76,20 @@ type radarUtilParams struct {
 	quitStrategySeconds    int
 	quitStrategyIterations int
 	liveConfigFilename     string
+	snapshotPattern        string
+	configFilename         string
+	isJSON                 bool
 }
 
 func (s *radarUtilParams) Setup() {
 	clientIdPtr := flag.String("clientid", "0x01000001", "Client ID")
 	targetIPPtr := flag.String("targetip", "192.168.11.1:55555", "Target RVProIP")
-	commandPtr := flag.String("cmd", "help", "Command to execute.  Options include (help, list-radars, live-zones). E.g. -cmd=live-zones")
+	commandPtr := flag.String("cmd", "help", "Command to execute.  Options include (help, list-radars, live-zones, backup, restore, validate). E.g. -cmd=live-zones")
 	quitStrategyPtr := flag.String("qs", "seconds", "Quit strategy (infinite, iterations, seconds)")
 	liveConfigFilenamePtr := flag.String("liveconfig", "live-zones.json", "Path to live config file.")
+	snapshotPatternPtr := flag.String("snapshot", "radar-%s.json", "Path to the radar snapshot files, %s is the radar ip.")
+	flag.StringVar(&s.configFilename, "config", "config.json", "Path to the rvpro channel config file to validate.")
+	flag.BoolVar(&s.isJSON, "json", false, "Print the validation issues as JSON.")
 	flag.IntVar(&s.quitStrategySeconds, "qs-seconds", 10, "seconds=10")
 	flag.IntVar(&s.quitStrategyIterations, "qs-iterations", 10, "iterations=10")
 
@@ -88,6 +98,7 @@ func (s *radarUtilParams) Setup() {
 	s.targetIP = *targetIPPtr
 	s.command = *commandPtr
 	s.liveConfigFilename = *liveConfigFilenamePtr
+	s.snapshotPattern = *snapshotPatternPtr
 	s.quitStrategy = *quitStrategyPtr
 }
 
@@ -110,6 +121,12 @@ func (s *radarUtilParams) GetCommand() radarUtilCommand {
 		return cmdListRadars
 	case "live-zones":
 		return cmdLiveZones
+	case "backup":
+		return cmdBackup
+	case "restore":
+		return cmdRestore
+	case "validate":
+		return cmdValidate
 	default:
 		return cmdHelp
 	}
@@ -135,6 +152,18 @@ func (s *radarUtilParams) GetLiveConfigFilename() string {
 	return s.liveConfigFilename
 }
 
+func (s *radarUtilParams) GetSnapshotPattern() string {
+	return s.snapshotPattern
+}
+
+func (s *radarUtilParams) GetConfigFilename() string {
+	return s.configFilename
+}
+
+func (s *radarUtilParams) IsJSON() bool {
+	return s.isJSON
+}
+
 func main() {
 	fmt.Println("Radar Vision radarutil v 1.0.0 - 20251117")
 	params := radarUtilParams{}
@@ -149,6 +178,18 @@ func main() {
 		cmd := BuildLiveZonesCmd{}
 		cmd.Init(&params)
 		cmd.Execute()
+	case cmdBackup:
+		cmd := RadarBackupCmd{}
+		cmd.Init(&params, false)
+		cmd.Execute()
+	case cmdRestore:
+		cmd := RadarBackupCmd{}
+		cmd.Init(&params, true)
+		cmd.Execute()
+	case cmdValidate:
+		cmd := ValidateConfigCmd{}
+		cmd.Init(&params)
+		os.Exit(cmd.Execute())
 	default:
 		doShowHelp()
 	}
@@ -7,8 +7,13 @@ import (
 	"rvpro3/radarvision.com/utils"
 )
 
+var config utils.Settings
+
 func main() {
+	config.Init()
+
 	hc := client.RouterClient{}
+	hc.InitFromSettings(&config)
 	hc.Start(utils.IP4Builder.FromString("192.168.0.103:45001"))
 	time.Sleep(6000 * time.Minute)
 	hc.StopAndJoin()
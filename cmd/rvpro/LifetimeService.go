@@ -1,7 +1,10 @@
 package main
 
 import (
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
 	"rvpro3/radarvision.com/utils"
@@ -32,10 +35,19 @@ func (l *LifetimeService) StopApplication() {
 	l.Wg.Done()
 }
 
+// run waits for SIGINT or SIGTERM, the application then stops its services
+// (see stopServices) before exiting
 func (l *LifetimeService) run() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
 	for !l.Terminated {
-		time.Sleep(1 * time.Second)
+		select {
+		case <-signals:
+			l.Terminated = true
+		case <-time.After(1 * time.Second):
+		}
 	}
-	l.Terminated = true
 	l.Wg.Done()
 }
@@ -11,13 +11,20 @@ import (
 	"rvpro3/radarvision.com/internal/api/services/testing"
 	"rvpro3/radarvision.com/internal/api/services/web"
 	"rvpro3/radarvision.com/internal/constants"
+	"rvpro3/radarvision.com/internal/devices/detector"
 	"rvpro3/radarvision.com/internal/devices/joystick"
 	"rvpro3/radarvision.com/internal/devices/lcd/general"
 	"rvpro3/radarvision.com/internal/devices/lcd/pages"
 	"rvpro3/radarvision.com/internal/models/servicemodel"
 	"rvpro3/radarvision.com/internal/router/server"
 	"rvpro3/radarvision.com/internal/sdlc/uartsdlc"
+	"rvpro3/radarvision.com/internal/services/metrichistory"
 	"rvpro3/radarvision.com/internal/services/ping"
+	"rvpro3/radarvision.com/internal/services/pvrhistory"
+	"rvpro3/radarvision.com/internal/services/snmp"
+	"rvpro3/radarvision.com/internal/services/statisticshistory"
+	"rvpro3/radarvision.com/internal/services/wrongway"
+	"rvpro3/radarvision.com/internal/smartmicro/instruction/backup"
 	"rvpro3/radarvision.com/internal/smartmicro/service"
 	"rvpro3/radarvision.com/internal/smartmicro/udp/activity/trigger"
 	"rvpro3/radarvision.com/internal/smartmicro/udp/broker"
@@ -146,6 +153,25 @@ func awaitComplete() {
 	lts.Wg.Wait()
 }
 
+// stopServices stops the brokers first, their workflows flush the open
+// statistics bins into the histories, then closes the histories once their
+// queued inserts are written
+func stopServices() {
+	utils.Print.InfoLn("Stopping services")
+
+	if brokers, ok := utils.GlobalState.Get(broker.UDPBrokersServiceName).(*broker.UDPBrokersService); ok {
+		brokers.Stop()
+	}
+
+	if history, ok := utils.GlobalState.Get(statisticshistory.StatisticsHistoryServiceName).(*statisticshistory.StatisticsHistoryService); ok {
+		_ = history.Close()
+	}
+
+	if history, ok := utils.GlobalState.Get(pvrhistory.PVRHistoryServiceName).(*pvrhistory.PVRHistoryService); ok {
+		_ = history.Close()
+	}
+}
+
 func startServices() {
 	utils.Print.InfoLn("Starting services")
 	for _, svc := range services {
@@ -177,13 +203,14 @@ func registerServiceSettings(target *utils.Settings) {
 //	}
 //}
 
-func registerUDPRadarServices(settings *utils.Settings) {
+func registerUDPRadarServices(settings *utils.Settings, webService *web.WebService) {
 	if settings.Basic.GetBool("feature.umrr.udp.enabled", true) {
 		config, err := servicemodel.SettingsBuilder.Build(settings)
 		if err != nil {
 			utils.Print.ErrorLn("Unable to load channel configuration", err)
 			os.Exit(1)
 		}
+		validateConfig(config, settings)
 		utils.GlobalState.Set(servicemodel.StateName, config)
 
 		registerService(new(service.UDPKeepAliveService))
@@ -193,7 +220,38 @@ func registerUDPRadarServices(settings *utils.Settings) {
 		// that any number of radars can be defined.  This also
 		// means that the radar port can be different which will be very helpful
 		// in integration testing.  The question is however, what is a default config
-		registerService(new(broker.UDPBrokersService))
+		registerService(&broker.UDPBrokersService{StreamSink: webService})
+
+		if settings.Basic.GetBool("feature.config.reload.enabled", false) {
+			registerService(new(broker.ConfigReloadService))
+		}
+
+		if settings.Basic.GetBool("feature.udp.capture.enabled", false) {
+			registerService(new(broker.UDPCaptureService))
+		}
+
+		if settings.Basic.GetBool("feature.radar.backup.enabled", false) {
+			registerService(new(backup.RadarBackupService))
+		}
+	}
+}
+
+// validateConfig prints the issues of the channel configuration, and exits
+// on errors when config.validate.strict (otherwise the configuration is used
+// as before the validation)
+func validateConfig(config *servicemodel.Config, settings *utils.Settings) {
+	result := servicemodel.ConfigValidator.Validate(config)
+
+	for _, issue := range result.Warnings() {
+		utils.Print.WarnLn("Channel configuration", issue)
+	}
+
+	for _, issue := range result.Errors() {
+		utils.Print.ErrorLn("Channel configuration", issue)
+	}
+
+	if result.HasErrors() && settings.Basic.GetBool("config.validate.strict", false) {
+		os.Exit(1)
 	}
 }
 
@@ -201,6 +259,16 @@ func registerSDLCServices(settings *utils.Settings) {
 	if settings.Basic.GetBool("feature.sdlc.uart.enabled", false) {
 		registerService(new(uartsdlc.SDLCService))
 		registerService(new(uartsdlc.SDLCExecutorService))
+
+		if settings.Basic.GetBool("feature.sdlc.time.enabled", false) {
+			registerService(new(uartsdlc.SDLCTimeSourceService))
+		}
+	}
+}
+
+func registerDetectorServices(settings *utils.Settings) {
+	if settings.Basic.GetBool("feature.detector.output.enabled", false) {
+		registerService(new(detector.DetectorOutputService))
 	}
 }
 
@@ -226,8 +294,11 @@ func registerServices(settings *utils.Settings) {
 	registerService(new(LifetimeService))
 	registerService(new(LoggingService))
 
-	registerUDPRadarServices(settings)
+	// The web service streams the radar data of the brokers
+	webService := new(web.WebService)
+	registerUDPRadarServices(settings, webService)
 	registerSDLCServices(settings)
+	registerDetectorServices(settings)
 	registerVideoServices(settings)
 
 	pageService := new(general.LcdPageService)
@@ -235,15 +306,34 @@ func registerServices(settings *utils.Settings) {
 	pageService.ScreenSaverPage = &pages.LcdScreenSaverPage{}
 	registerService(pageService)
 	registerService(new(joystick.JoystickService))
-	registerService(new(web.WebService))
+	registerService(webService)
 	registerService(new(testing.SendTimeSocketService))
 	registerService(new(ping.PingStatsService))
 
+	if settings.Basic.GetBool("feature.metrics.history.enabled", false) {
+		registerService(new(metrichistory.MetricHistoryService))
+	}
+
+	if settings.Basic.GetBool("feature.statistics.history.enabled", false) {
+		registerService(new(statisticshistory.StatisticsHistoryService))
+	}
+
+	if settings.Basic.GetBool("feature.pvr.history.enabled", false) {
+		registerService(new(pvrhistory.PVRHistoryService))
+	}
+
+	if settings.Basic.GetBool("feature.wrongway.dispatch.enabled", false) {
+		registerService(new(wrongway.WrongWayDispatchService))
+	}
+
+	if settings.Basic.GetBool("feature.snmp.enabled", false) {
+		registerService(new(snmp.SNMPAgentService))
+	}
+
 	registerService(new(server.RouterServerService))
 
 	//NB:  When creating UDPBrokersService, remember to add the WorkflowBuilder
 	//TODO: Add TcpHub/Router back into the fold
-	//TODO: SNMP
 	//TODO: LCD
 }
 
@@ -285,6 +375,52 @@ func doDumpFinalSettings(args *utils.Settings) {
 	utils.GlobalSettings.DumpTo(os.Stdout)
 }
 
+// doDumpMib writes the MIB module served by the SNMP agent to the --mib-file,
+// beneath the snmp.enterprise.number of the settings
+func doDumpMib(args *utils.Settings) {
+	fileName := utils.Args.GetString("--mib-file", snmp.RVProMibModuleName+".txt")
+
+	args.MergeFromSettings(loadSettingsFile(args))
+	agent := new(snmp.SNMPAgentService)
+	agent.InitFromSettings(args)
+
+	if agent.EnterpriseNumber <= 0 {
+		utils.Print.ErrorLn("Unable to write MIB file, snmp.enterprise.number not set")
+		os.Exit(1)
+	}
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		utils.Print.ErrorLn("Unable to create MIB file", err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	if err = snmp.NewRVProMib(agent).Write(file); err != nil {
+		utils.Print.ErrorLn("Unable to write MIB file", err)
+		os.Exit(1)
+	}
+	utils.Print.InfoLn("MIB written to ->", fileName)
+}
+
+// doDumpOpenAPI writes the OpenAPI document of the web api to the --openapi-file
+func doDumpOpenAPI() {
+	fileName := utils.Args.GetString("--openapi-file", "openapi.json")
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		utils.Print.ErrorLn("Unable to create OpenAPI file", err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	if err = new(web.WebService).WriteOpenAPI(file); err != nil {
+		utils.Print.ErrorLn("Unable to write OpenAPI file", err)
+		os.Exit(1)
+	}
+	utils.Print.InfoLn("OpenAPI written to ->", fileName)
+}
+
 func doRunMode(args *utils.Settings) {
 	fileSettings := loadSettingsFile(args)
 	args.MergeFromSettings(fileSettings)
@@ -295,6 +431,7 @@ func doRunMode(args *utils.Settings) {
 
 	startServices()
 	awaitComplete()
+	stopServices()
 
 	utils.Print.InfoLn("rvm program completed")
 }
@@ -314,6 +451,12 @@ func main() {
 	case "dump-config":
 		doDumpTestConfig(args)
 
+	case "dump-mib":
+		doDumpMib(args)
+
+	case "dump-openapi":
+		doDumpOpenAPI()
+
 	case "show-help":
 		showHelp()
 
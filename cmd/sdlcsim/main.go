@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"rvpro3/radarvision.com/internal/sdlc/sdlcsim"
+	"rvpro3/radarvision.com/internal/sdlc/uartsdlc"
+	"rvpro3/radarvision.com/utils"
+)
+
+func main() {
+	utils.Print.Ln("Radar Vision")
+	utils.Print.Ln("RVPro SDLC Simulator - Copyright Radar Vision 2026")
+
+	if utils.Args.Has("--help|-h") {
+		showHelp()
+		os.Exit(0)
+	}
+
+	pty, err := sdlcsim.OpenPty()
+	if err != nil {
+		utils.Print.ErrorLn("Unable to open pseudo terminal", err)
+		os.Exit(1)
+	}
+	defer func() {
+		_ = pty.Close()
+	}()
+
+	portName := pty.SlaveName
+	if link := utils.Args.GetString("--link|-l", ""); link != "" {
+		_ = os.Remove(link)
+		if err = os.Symlink(pty.SlaveName, link); err != nil {
+			utils.Print.ErrorLn("Unable to link", link, err)
+			os.Exit(1)
+		}
+		defer func() {
+			_ = os.Remove(link)
+		}()
+		portName = link
+	}
+
+	sim := buildSimulator()
+
+	utils.Print.InfoLn("Serving SDLC on", portName)
+	utils.Print.InfoLn("Start rvpro with", "-o=sdlc.uart.portname="+portName)
+
+	go func() {
+		if err := sim.Serve(pty); err != nil {
+			utils.Print.ErrorLn("Simulator stopped", err)
+			os.Exit(1)
+		}
+	}()
+
+	showDetects(sim)
+}
+
+func showHelp() {
+	utils.Print.Ln("Usage:", os.Args[0], "[options]")
+	utils.Print.Ln("Options:")
+	utils.Print.Option("--link|-l")
+	utils.Print.Descrp("Symlink to the pseudo terminal, used as the sdlc.uart.portname")
+	utils.Print.Sample("Sample: --link=/tmp/ttySDLC")
+	utils.Print.Sample("Default: [empty] - use the /dev/pts/N name")
+
+	utils.Print.Option("--biu|-b")
+	utils.Print.Descrp("The BIU flags (bit 0..3) reported by the static status")
+	utils.Print.Sample("Sample: --biu=3")
+	utils.Print.Sample("Default: 15 - all 4 BIUs")
+
+	utils.Print.Option("--broadcast")
+	utils.Print.Descrp("Broadcast the CMU frame and date/time every second")
+}
+
+func buildSimulator() *sdlcsim.SDLCSimulator {
+	sim := sdlcsim.NewSDLCSimulator("SDLC.Simulator")
+	sim.IsBroadcast = utils.Args.Has("--broadcast")
+
+	if biu := utils.Args.GetString("--biu|-b", ""); biu != "" {
+		flags, err := strconv.ParseUint(biu, 0, 8)
+		if err != nil {
+			utils.Print.ErrorLn("Invalid BIU flags", err)
+			os.Exit(1)
+		}
+		sim.StaticStatus.BIU = uartsdlc.BIUFlags(flags)
+	}
+
+	return sim
+}
+
+// showDetects prints the detector bits when they change
+func showDetects(sim *sdlcsim.SDLCSimulator) {
+	var previous uint64
+	var previousOn time.Time
+
+	for {
+		detects, detectsOn := sim.GetDetects()
+
+		if detectsOn != previousOn && (detects != previous || previousOn.IsZero()) {
+			utils.Print.Ln(detectsOn.Format(utils.DisplayDateTimeMS), "Detects:", strconv.FormatUint(detects, 2))
+			previous = detects
+		}
+		previousOn = detectsOn
+
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -142,6 +142,21 @@ func onPopMessage(service *uartsdlc.SDLCService, bytes []byte) {
 		utils.Debug.Panic(err)
 		obj.PrintDetail()
 
+	case uartsdlc.SIUDiagnosticResponseCode:
+		obj, err := response.GetSIUDiagnostics()
+		utils.Debug.Panic(err)
+		obj.PrintDetail()
+
+	case uartsdlc.CMUFrameStreamCode:
+		obj, err := response.GetCMUFrame()
+		utils.Debug.Panic(err)
+		obj.PrintDetail()
+
+	case uartsdlc.DateTimeStreamCode:
+		obj, err := response.GetDateTimeStream()
+		utils.Debug.Panic(err)
+		obj.PrintDetail()
+
 	case uartsdlc.AcknowledgeResponseCode:
 		obj, err := response.GetAcknowledge()
 		utils.Debug.Panic(err)
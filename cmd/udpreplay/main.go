@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"time"
+
+	"rvpro3/radarvision.com/internal/models/servicemodel"
+	"rvpro3/radarvision.com/internal/smartmicro/udp/broker"
+	"rvpro3/radarvision.com/utils"
+)
+
+const replaySectionName = "UDP.Replay"
+
+func main() {
+	utils.Print.Ln("Radar Vision")
+	utils.Print.Ln("RVPro UDP Replay Tool - Copyright Radar Vision 2026")
+
+	fileName := utils.Args.GetString("--file|-f", "")
+
+	if fileName == "" || utils.Args.Has("--help|-h") {
+		showHelp()
+		os.Exit(0)
+	}
+
+	replay := buildReplay()
+
+	utils.Print.InfoLn("Replaying ->", fileName)
+	startOn := time.Now()
+
+	if err := replay.ReplayFile(fileName); err != nil {
+		utils.Print.ErrorLn("Replay failed", err)
+		os.Exit(1)
+	}
+
+	utils.Print.InfoLn("Replay completed in", time.Since(startOn))
+	dumpMetrics()
+}
+
+func showHelp() {
+	utils.Print.Ln("Usage:", os.Args[0], "--file=<capture.pcap> [options]")
+	utils.Print.Ln("Options:")
+	utils.Print.Option("--file|-f")
+	utils.Print.Descrp("The pcap or pcapng capture to replay")
+	utils.Print.Sample("Sample: --file=udp-20260101T120000.000.pcap")
+
+	utils.Print.Option("--cfg|-c")
+	utils.Print.Descrp("The radar channel configuration (json), or test/debug")
+	utils.Print.Sample("Sample: --cfg=config.json")
+	utils.Print.Sample("Default: [empty] - the default configuration")
+
+	utils.Print.Option("--speed|-s")
+	utils.Print.Descrp("Replay speed relative to the capture, 0 replays as fast as possible")
+	utils.Print.Sample("Sample: --speed=4")
+	utils.Print.Sample("Default: 1 - the original timing")
+
+	utils.Print.Option("--radar|-r")
+	utils.Print.Descrp("Only replay the radars (; separated)")
+	utils.Print.Sample("Sample: --radar=192.168.11.12;192.168.11.13")
+	utils.Print.Sample("Default: [empty] - all the configured radars")
+
+	utils.Print.Option("-o=setting")
+	utils.Print.Descrp("Override any rvpro setting")
+	utils.Print.Sample("Sample: -o=radar.udp.segment.timeout=1000")
+}
+
+func buildReplay() *broker.UDPReplay {
+	settings := &utils.GlobalSettings
+	settings.Basic.Set("startup.cfg.file", utils.Args.GetString("--cfg|-c", ""))
+	settings.ReadArgs()
+
+	config, err := servicemodel.SettingsBuilder.Build(settings)
+	if err != nil {
+		utils.Print.ErrorLn("Unable to load channel configuration", err)
+		os.Exit(1)
+	}
+	utils.GlobalState.Set(servicemodel.StateName, config)
+
+	speed, err := strconv.ParseFloat(utils.Args.GetString("--speed|-s", "1"), 64)
+	if err != nil {
+		utils.Print.ErrorLn("Invalid speed", err)
+		os.Exit(1)
+	}
+
+	brokers := new(broker.UDPBrokersService)
+	brokers.InitFromSettings(settings)
+	brokers.StartReplay(&utils.GlobalState, settings)
+
+	replay := &broker.UDPReplay{
+		Brokers: brokers,
+		Speed:   speed,
+	}
+	replay.InitMetrics(replaySectionName)
+
+	if radars := utils.Args.GetString("--radar|-r", ""); radars != "" {
+		for _, radar := range settings.Split(radars) {
+			replay.Radars = append(replay.Radars, utils.IP4Builder.FromString(radar))
+		}
+	}
+
+	return replay
+}
+
+func dumpMetrics() {
+	sections := make(map[string]*utils.Metrics)
+	utils.GlobalMetrics.MergeRegEx(sections, "^(UDP\\.|Workflow)")
+
+	jsonData, err := json.MarshalIndent(sections, "", "  ")
+	utils.Debug.Panic(err)
+	utils.Print.RawLn(string(jsonData))
+}
@@ -8,7 +8,7 @@ import (
 )
 
 type UDPBroker struct {
-	Metrics map[string]utils.Metrics
+	Metrics map[string]*utils.Metrics
 }
 
 func (r *UDPBroker) GetMetric(rootName string, metricName string) *utils.Metric {
@@ -6,6 +6,7 @@ import (
 	"github.com/rs/zerolog/log"
 	"rvpro3/radarvision.com/internal/api/services/web"
 	"rvpro3/radarvision.com/internal/general"
+	"rvpro3/radarvision.com/internal/models/streammodel"
 	"rvpro3/radarvision.com/utils"
 )
 
@@ -46,7 +47,7 @@ func (s *SendTimeSocketService) GetServiceName() string {
 
 func (s *SendTimeSocketService) run() {
 	for !s.Terminate {
-		if s.web.IsAnySubscribed(web.SocketTime) {
+		if s.web.IsAnySubscribed(streammodel.SubscriptionTime) {
 			now := time.Now()
 			msg := web.SocketMessage{}
 
@@ -57,7 +58,7 @@ func (s *SendTimeSocketService) run() {
 			zone, offset := now.Zone()
 			msg.Set("Zone", zone)
 			msg.SetInt("GetOffset", offset)
-			s.web.Broadcast(msg.ToPayload(web.SocketTime))
+			s.web.Broadcast(msg.ToPayload(streammodel.SubscriptionTime))
 		}
 
 		time.Sleep(1 * time.Second)
@@ -0,0 +1,71 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"rvpro3/radarvision.com/internal/constants"
+	"rvpro3/radarvision.com/internal/models/servicemodel"
+	"rvpro3/radarvision.com/utils"
+)
+
+func (w *WebService) getApiConfig(context *gin.Context) {
+	serviceCfg, ok := utils.GlobalState.Get(servicemodel.StateName).(*servicemodel.Config)
+	if !ok {
+		context.JSON(http.StatusNotFound, gin.H{"error": "config not found"})
+		return
+	}
+	context.JSON(http.StatusOK, serviceCfg)
+}
+
+// putApiConfig applies the configuration without a restart, only the radars
+// changed are rebuilt.  A configuration with validation errors is rejected
+// before being applied (or saved), leaving the running configuration as is
+func (w *WebService) putApiConfig(context *gin.Context) {
+	reloader, ok := utils.GlobalState.Get(constants.ConfigReloadServiceName).(servicemodel.IConfigReloader)
+	if !ok {
+		context.JSON(http.StatusNotFound, gin.H{"error": "config reload not enabled"})
+		return
+	}
+
+	serviceCfg, result, ok := w.validateApiConfig(context)
+	if !ok {
+		return
+	}
+
+	diffs, err := reloader.Reload(serviceCfg)
+	if err != nil {
+		context.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "Issues": result.Issues, "Radars": diffs})
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"Issues": result.Issues, "Radars": diffs})
+}
+
+// postApiConfigValidate validates the configuration without applying it
+func (w *WebService) postApiConfigValidate(context *gin.Context) {
+	if _, result, ok := w.validateApiConfig(context); ok {
+		context.JSON(http.StatusOK, gin.H{"Issues": result.Issues})
+	}
+}
+
+// validateApiConfig decodes and validates the configuration of the body,
+// answering 400 (invalid JSON) or 422 (validation errors) when not ok
+func (w *WebService) validateApiConfig(context *gin.Context) (*servicemodel.Config, *servicemodel.ValidationResult, bool) {
+	data, err := context.GetRawData()
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, nil, false
+	}
+
+	serviceCfg, result := servicemodel.ConfigValidator.ValidateJSON(data)
+	if serviceCfg == nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": "invalid config", "Issues": result.Issues})
+		return nil, nil, false
+	}
+
+	if result.HasErrors() {
+		context.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid config", "Issues": result.Issues})
+		return nil, nil, false
+	}
+	return serviceCfg, result, true
+}
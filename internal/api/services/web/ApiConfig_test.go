@@ -0,0 +1,63 @@
+package web
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/internal/constants"
+	"rvpro3/radarvision.com/internal/models/servicemodel"
+	"rvpro3/radarvision.com/utils"
+)
+
+type testConfigReloader struct {
+	config *servicemodel.Config
+}
+
+func (r *testConfigReloader) Reload(config *servicemodel.Config) ([]servicemodel.RadarDiff, error) {
+	r.config = config
+	return []servicemodel.RadarDiff{{RadarIP: "192.168.11.12:0", Change: servicemodel.RadarChanged}}, nil
+}
+
+func withBody(body string, prepare func(request *http.Request)) func(request *http.Request) {
+	return func(request *http.Request) {
+		request.Body = io.NopCloser(bytes.NewBufferString(body))
+		prepare(request)
+	}
+}
+
+func TestApiV1_Config(t *testing.T) {
+	_, router := newTestWebService(false)
+	reloader := &testConfigReloader{}
+	utils.GlobalState.Set(constants.ConfigReloadServiceName, reloader)
+
+	valid := `{"SiteName":"Site","DistanceUnit":"ft","SpeedUnit":"mph","Radars":[{"RadarIP":"192.168.11.12","RadarName":"Radar 1","Channels":[{"Channel":1,"FailSafe":"set","MaxHld":"5"}]}]}`
+	invalid := `{"SiteName":"Site","DistanceUnit":"ft","SpeedUnit":"mph","Radars":[{"RadarIP":"192.168.11.12","RadarName":"Radar 1","Channels":[{"Channel":1,"MaxHold":"abc"}]}]}`
+
+	response := serveTest(router, http.MethodPost, "/api/v1/config/validate", withBody(invalid, asUser("view", "v")))
+	assert.Equal(t, http.StatusForbidden, response.Code)
+
+	response = serveTest(router, http.MethodPost, "/api/v1/config/validate", withBody(invalid, asUser("root", "r")))
+	assert.Equal(t, http.StatusUnprocessableEntity, response.Code)
+	assert.JSONEq(t, `{"error":"invalid config","Issues":[
+		{"Path":"Radars[0].Channels[0].MaxHold","Severity":"error","Message":"not a number \"abc\""}
+	]}`, response.Body.String())
+
+	// The invalid configuration is never applied
+	response = serveTest(router, http.MethodPut, "/api/v1/config", withBody(invalid, asUser("root", "r")))
+	assert.Equal(t, http.StatusUnprocessableEntity, response.Code)
+	assert.Nil(t, reloader.config)
+
+	response = serveTest(router, http.MethodPut, "/api/v1/config", withBody("{", asUser("root", "r")))
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+
+	response = serveTest(router, http.MethodPut, "/api/v1/config", withBody(valid, asUser("root", "r")))
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `{
+		"Issues":[{"Path":"Radars[0].Channels[0].MaxHld","Severity":"warning","Message":"unknown field"}],
+		"Radars":[{"RadarIP":"192.168.11.12:0","Change":"changed"}]
+	}`, response.Body.String())
+	assert.Equal(t, "Radar 1", reloader.config.Radars[0].RadarName)
+}
@@ -0,0 +1,96 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"rvpro3/radarvision.com/internal/models/servicemodel"
+	"rvpro3/radarvision.com/internal/smartmicro/instruction/backup"
+	"rvpro3/radarvision.com/utils"
+)
+
+// postRadarBackup takes the parameter snapshot of the radar, saved on rvpro
+// and returned
+func (w *WebService) postRadarBackup(context *gin.Context) {
+	backupService, radarIP, ok := w.radarBackupOf(context)
+	if !ok {
+		return
+	}
+
+	snapshot, fileName, err := backupService.Backup(radarIP)
+	if err != nil {
+		context.JSON(w.radarBackupStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"FileName": fileName, "Snapshot": snapshot})
+}
+
+// postRadarRestore writes the snapshot of the body onto the radar and
+// verifies it, Parameters the number restored, the parameters that differ are returned with 422
+func (w *WebService) postRadarRestore(context *gin.Context) {
+	backupService, radarIP, ok := w.radarBackupOf(context)
+	if !ok {
+		return
+	}
+
+	data, err := context.GetRawData()
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	snapshot, err := backup.ParseSnapshot(data)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	count, mismatches, err := backupService.Restore(radarIP, snapshot)
+	if err != nil {
+		context.JSON(w.radarBackupStatus(err), gin.H{"error": err.Error(), "Mismatches": mismatches})
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"Parameters": count, "Mismatches": mismatches})
+}
+
+// radarBackupOf returns the backup service and the configured radar of the
+// radar parameter, with the default radar port when the configuration has
+// none.  It answers 404 when not ok
+func (w *WebService) radarBackupOf(context *gin.Context) (*backup.RadarBackupService, utils.IP4, bool) {
+	backupService, ok := utils.GlobalState.Get(backup.RadarBackupServiceName).(*backup.RadarBackupService)
+	if !ok {
+		context.JSON(http.StatusNotFound, gin.H{"error": "radar backup not enabled"})
+		return nil, utils.IP4{}, false
+	}
+
+	radarIP := utils.IP4Builder.FromString(context.Query("radar"))
+	serviceCfg, _ := utils.GlobalState.Get(servicemodel.StateName).(*servicemodel.Config)
+
+	if serviceCfg != nil && radarIP.ToU32() != 0 {
+		for _, radarCfg := range serviceCfg.Radars {
+			if radarCfg == nil || !radarCfg.GetRadarIP().IsEqualIP(radarIP) {
+				continue
+			}
+
+			if radarCfg.GetRadarIP().Port == 0 {
+				return backupService, radarCfg.GetRadarIP().WithPort(utils.Radar11IP.Port), true
+			}
+			return backupService, radarCfg.GetRadarIP(), true
+		}
+	}
+
+	context.JSON(http.StatusNotFound, gin.H{"error": "radar not configured"})
+	return nil, utils.IP4{}, false
+}
+
+func (w *WebService) radarBackupStatus(err error) int {
+	switch {
+	case errors.Is(err, backup.ErrBackupBusy):
+		return http.StatusConflict
+	case errors.Is(err, backup.ErrVerifyFailed):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusBadGateway
+	}
+}
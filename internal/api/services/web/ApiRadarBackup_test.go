@@ -0,0 +1,62 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/internal/models/servicemodel"
+	"rvpro3/radarvision.com/internal/smartmicro/instruction/backup"
+	"rvpro3/radarvision.com/utils"
+)
+
+func TestApiV1_RadarBackup(t *testing.T) {
+	_, router := newTestWebService(false)
+	asOperator := func(request *http.Request) {
+		request.Header.Set("Authorization", "Bearer tok")
+	}
+	asRootWith := func(body string) func(request *http.Request) {
+		return func(request *http.Request) {
+			request.Body = io.NopCloser(strings.NewReader(body))
+			request.SetBasicAuth("root", "r")
+		}
+	}
+
+	response := serveTest(router, http.MethodPost, "/api/v1/radars/backup?radar=127.0.0.1", asUser("root", "r"))
+	assert.Equal(t, http.StatusNotFound, response.Code)
+	assert.JSONEq(t, `{"error":"radar backup not enabled"}`, response.Body.String())
+
+	previous := utils.GlobalState.Get(servicemodel.StateName)
+	t.Cleanup(func() {
+		utils.GlobalState.Set(servicemodel.StateName, previous)
+		utils.GlobalState.Set(backup.RadarBackupServiceName, nil)
+	})
+
+	cfg := servicemodel.TestBuilder.Build()
+	cfg.Normalize()
+	utils.GlobalState.Set(servicemodel.StateName, cfg)
+	utils.GlobalState.Set(backup.RadarBackupServiceName, new(backup.RadarBackupService))
+
+	// Admin only, and not outside /api/v1
+	response = serveTest(router, http.MethodPost, "/api/v1/radars/backup?radar=127.0.0.1", asOperator)
+	assert.Equal(t, http.StatusForbidden, response.Code)
+
+	response = serveTest(router, http.MethodPost, "/radars/backup?radar=127.0.0.1", nil)
+	assert.Equal(t, http.StatusNotFound, response.Code)
+
+	response = serveTest(router, http.MethodPost, "/api/v1/radars/backup?radar=10.0.0.1", asUser("root", "r"))
+	assert.Equal(t, http.StatusNotFound, response.Code)
+	assert.JSONEq(t, `{"error":"radar not configured"}`, response.Body.String())
+
+	// Without the UDP data service the radar cannot be reached
+	response = serveTest(router, http.MethodPost, "/api/v1/radars/backup?radar=127.0.0.1", asUser("root", "r"))
+	assert.Equal(t, http.StatusBadGateway, response.Code)
+
+	response = serveTest(router, http.MethodPost, "/api/v1/radars/restore?radar=127.0.0.1", asRootWith(`{"Version":9}`))
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+
+	response = serveTest(router, http.MethodPost, "/api/v1/radars/restore?radar=127.0.0.1", asOperator)
+	assert.Equal(t, http.StatusForbidden, response.Code)
+}
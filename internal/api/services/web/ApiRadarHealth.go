@@ -0,0 +1,32 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"rvpro3/radarvision.com/internal/models/servicemodel"
+	"rvpro3/radarvision.com/internal/smartmicro/udp/state"
+	"rvpro3/radarvision.com/utils"
+)
+
+// getRadarHealth returns the health of a configured radar, copied under the
+// lock of the radar state (the application state leaves it out)
+func (w *WebService) getRadarHealth(context *gin.Context) {
+	radarIP := utils.IP4Builder.FromString(context.Query("radar"))
+	serviceCfg, _ := utils.GlobalState.Get(servicemodel.StateName).(*servicemodel.Config)
+
+	if serviceCfg != nil && radarIP.ToU32() != 0 {
+		for _, radarCfg := range serviceCfg.Radars {
+			if radarCfg == nil || !radarCfg.GetRadarIP().IsEqualIP(radarIP) {
+				continue
+			}
+
+			if radarState := state.RadarStateHelper.Get(radarCfg.GetRadarIP()); radarState != nil {
+				context.JSON(http.StatusOK, radarState.GetHealth())
+				return
+			}
+		}
+	}
+
+	context.JSON(http.StatusNotFound, gin.H{"error": "radar not configured"})
+}
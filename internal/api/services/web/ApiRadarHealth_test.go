@@ -0,0 +1,42 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/internal/models/servicemodel"
+	"rvpro3/radarvision.com/internal/smartmicro/udp/state"
+	"rvpro3/radarvision.com/utils"
+)
+
+func TestApiV1_RadarHealth(t *testing.T) {
+	_, router := newTestWebService(false)
+
+	previous := utils.GlobalState.Get(servicemodel.StateName)
+	t.Cleanup(func() { utils.GlobalState.Set(servicemodel.StateName, previous) })
+
+	cfg := servicemodel.TestBuilder.Build()
+	cfg.Normalize()
+	utils.GlobalState.Set(servicemodel.StateName, cfg)
+
+	response := serveTest(router, http.MethodGet, "/api/v1/radars/health?radar=10.0.0.1", asUser("view", "v"))
+	assert.Equal(t, http.StatusNotFound, response.Code)
+
+	radarState := state.RadarStateHelper.GetOrSet(cfg.Radars[0].GetRadarIP())
+	radarState.SetHealth(state.RadarHealth{Temperature: 90, IsDegraded: true, Reasons: []string{"temperature"}})
+
+	response = serveTest(router, http.MethodGet, "/api/v1/radars/health?radar=127.0.0.1", asUser("view", "v"))
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	health := state.RadarHealth{}
+	assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &health))
+	assert.True(t, health.IsDegraded)
+	assert.Equal(t, []string{"temperature"}, health.Reasons)
+
+	// The application state leaves the health out, it is read under the lock
+	data, err := json.Marshal(radarState)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "Temperature")
+}
@@ -0,0 +1,263 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"rvpro3/radarvision.com/internal/constants"
+	"rvpro3/radarvision.com/utils"
+)
+
+const ApiV1Prefix = "/api/v1"
+
+// ApiParam is a (query) parameter of an api route
+type ApiParam struct {
+	Name        string
+	In          string
+	Type        string
+	IsArray     bool
+	IsRequired  bool
+	Description string
+}
+
+// ApiRoute is a route of the versioned api.  The routes are registered and
+// the OpenAPI document is generated from the same definitions
+type ApiRoute struct {
+	Method      string
+	Path        string
+	Role        Role
+	Summary     string
+	Params      []ApiParam
+	Body        string
+	Response    string
+	IsWebSocket bool
+	handler     gin.HandlerFunc
+}
+
+func queryParam(name string, description string, isRequired bool) ApiParam {
+	return ApiParam{Name: name, In: "query", Type: "string", IsRequired: isRequired, Description: description}
+}
+
+func (w *WebService) apiV1Routes() []*ApiRoute {
+	return []*ApiRoute{
+		{
+			Method:   http.MethodGet,
+			Path:     "/openapi.json",
+			Role:     RoleNone,
+			Summary:  "The OpenAPI document of the api",
+			Response: "The OpenAPI 3 document",
+			handler:  w.getOpenAPI,
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/version",
+			Role:     RoleViewer,
+			Summary:  "The version and build of rvpro",
+			Response: "The application info",
+			handler:  w.getApiVersion,
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/me",
+			Role:     RoleViewer,
+			Summary:  "The user authenticated and its role",
+			Response: "The user",
+			handler:  w.getApiMe,
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/metrics/sections",
+			Role:     RoleViewer,
+			Summary:  "The names of the metric sections",
+			Response: "The section names",
+			handler:  w.getMetricsSections,
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/metrics/section",
+			Role:    RoleViewer,
+			Summary: "The metrics of the sections, by name or regular expression",
+			Params: []ApiParam{
+				{Name: "sn", In: "query", Type: "string", IsArray: true, Description: "The section names"},
+				{Name: "regex", In: "query", Type: "string", IsArray: true, Description: "The section name regular expressions"},
+			},
+			Response: "The metrics by section name",
+			handler:  w.getMetricsSection,
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/metrics/history",
+			Role:    RoleViewer,
+			Summary: "The time series of a metric (feature.metrics.history.enabled)",
+			Params: []ApiParam{
+				queryParam("sn", "The section name", true),
+				queryParam("mn", "The metric name", true),
+				queryParam("from", "The start, unix milliseconds or RFC3339 (default an hour before to)", false),
+				queryParam("to", "The end, unix milliseconds or RFC3339 (default now)", false),
+			},
+			Response: "The metric samples",
+			handler:  w.getMetricsHistory,
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/statistics",
+			Role:    RoleViewer,
+			Summary: "The statistics bins of the radars (feature.statistics.history.enabled)",
+			Params: []ApiParam{
+				queryParam("bin", "The bin size in minutes, 1, 5, 15 or 60 (default 15)", false),
+				queryParam("radar", "The radar IP address (default all)", false),
+				queryParam("zone", "The zone (default all)", false),
+				queryParam("class", "The object class, e.g. CAR (default all)", false),
+				queryParam("from", "The start, unix milliseconds or RFC3339 (default a day before to)", false),
+				queryParam("to", "The end, unix milliseconds or RFC3339 (default now)", false),
+			},
+			Response: "The statistics bins by start, radar, zone and class",
+			handler:  w.getStatistics,
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/pvr",
+			Role:    RoleViewer,
+			Summary: "The vehicle events of the radars (feature.pvr.history.enabled)",
+			Params: []ApiParam{
+				queryParam("radar", "The radar IP address (default all)", false),
+				queryParam("zone", "The zone (default all)", false),
+				queryParam("class", "The object class, e.g. CAR (default all)", false),
+				queryParam("minspeed", "The minimum speed, in the speed unit (default 0)", false),
+				queryParam("maxspeed", "The speed (excluded) above the band, in the speed unit (default none)", false),
+				queryParam("from", "The start, unix milliseconds or RFC3339 (default an hour before to)", false),
+				queryParam("to", "The end, unix milliseconds or RFC3339 (default now)", false),
+				queryParam("limit", "The maximum number of events (default pvr.history.max.rows)", false),
+			},
+			Response: "The vehicle events by time and radar",
+			handler:  w.getPVR,
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/state/keys",
+			Role:     RoleAdmin,
+			Summary:  "The keys of the application state",
+			Response: "The state keys",
+			handler:  w.getStateKeys,
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/state/key",
+			Role:     RoleAdmin,
+			Summary:  "The application state of a key",
+			Params:   []ApiParam{queryParam("id", "The state key", true)},
+			Response: "The state",
+			handler:  w.getStateKey,
+		},
+		{
+			Method:  http.MethodPut,
+			Path:    "/state/phase",
+			Role:    RoleOperator,
+			Summary: "Sets the red, yellow and green phases",
+			Params: []ApiParam{
+				queryParam("red", "The red phases (hex bit flags)", true),
+				queryParam("yellow", "The yellow phases (hex bit flags)", true),
+				queryParam("green", "The green phases (hex bit flags)", true),
+			},
+			Response: "The phase state",
+			handler:  w.setPhaseState,
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/config",
+			Role:     RoleViewer,
+			Summary:  "The radar and channel configuration running",
+			Response: "The configuration",
+			handler:  w.getApiConfig,
+		},
+		{
+			Method:   http.MethodPut,
+			Path:     "/config",
+			Role:     RoleAdmin,
+			Summary:  "Validates and applies the configuration without a restart (feature.config.reload.enabled), only the radars changed are rebuilt",
+			Body:     "The configuration",
+			Response: "The validation warnings, and the radars added, removed, changed or unchanged",
+			handler:  w.putApiConfig,
+		},
+		{
+			Method:   http.MethodPost,
+			Path:     "/config/validate",
+			Role:     RoleOperator,
+			Summary:  "Validates the configuration, the errors and warnings by path",
+			Body:     "The configuration",
+			Response: "The validation warnings",
+			handler:  w.postApiConfigValidate,
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/radars/health",
+			Role:     RoleViewer,
+			Summary:  "The health of a radar decoded from its diagnostics (radar.health.enabled)",
+			Params:   []ApiParam{queryParam("radar", "The radar IP address", true)},
+			Response: "The health, degraded with the reasons",
+			handler:  w.getRadarHealth,
+		},
+		{
+			Method:   http.MethodPost,
+			Path:     "/radars/backup",
+			Role:     RoleAdmin,
+			Summary:  "Backs up the parameters of a radar over the instruction protocol (feature.radar.backup.enabled), saved to radar.backup.snapshot.pattern",
+			Params:   []ApiParam{queryParam("radar", "The radar IP address", true)},
+			Response: "The file name and the snapshot",
+			handler:  w.postRadarBackup,
+		},
+		{
+			Method:   http.MethodPost,
+			Path:     "/radars/restore",
+			Role:     RoleAdmin,
+			Summary:  "Restores and verifies the parameters of a radar from a snapshot (feature.radar.backup.enabled)",
+			Params:   []ApiParam{queryParam("radar", "The radar IP address", true)},
+			Body:     "The snapshot",
+			Response: "The number of parameters restored",
+			handler:  w.postRadarRestore,
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/socket",
+			Role:    RoleViewer,
+			Summary: "Upgrades to the websocket, the token can be given as access_token",
+			Params: []ApiParam{
+				queryParam("subscribe", "The subscriptions (bit flags): 1 time, 2 object list, 4 PVR, 8 statistics, 16 trigger, 32 channel status", false),
+				queryParam("radars", "The radar ips (; separated) of the radar subscriptions, all radars when empty", false),
+				queryParam("access_token", "The bearer token", false),
+			},
+			Response:    "Switching to the websocket",
+			IsWebSocket: true,
+			handler:     w.getSocket,
+		},
+	}
+}
+
+// registerApiV1 registers the authenticated api, with CORS for the allowed
+// origins
+func (w *WebService) registerApiV1(router *gin.Engine) {
+	v1 := router.Group(ApiV1Prefix, w.Origins.Cors())
+
+	// Preflight requests are answered by the CORS middleware
+	v1.OPTIONS("/*path", func(context *gin.Context) {})
+
+	for _, route := range w.apiV1Routes() {
+		v1.Handle(route.Method, route.Path, w.Auth.Authorize(route.Role), route.handler)
+	}
+}
+
+func (w *WebService) getOpenAPI(context *gin.Context) {
+	context.JSON(http.StatusOK, w.OpenAPI())
+}
+
+func (w *WebService) getApiVersion(context *gin.Context) {
+	appInfo, ok := utils.GlobalState.Get(constants.AppInfoStateName).(*utils.AppInfo)
+	if !ok {
+		appInfo = &utils.AppInfo{}
+	}
+	context.JSON(http.StatusOK, appInfo)
+}
+
+func (w *WebService) getApiMe(context *gin.Context) {
+	context.JSON(http.StatusOK, context.MustGet(WebUserKey))
+}
@@ -0,0 +1,141 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/utils"
+)
+
+func newTestWebService(isLegacyAuth bool) (*WebService, *gin.Engine) {
+	gin.SetMode(gin.TestMode)
+
+	settings := &utils.Settings{}
+	settings.Init()
+	settings.Basic.Set(authUsers, "view:viewer:"+HashSecret("v")+";root:admin:"+HashSecret("r"))
+	settings.Basic.Set(authTokens, "ui:operator:"+HashSecret("tok"))
+	settings.Basic.Set(corsOrigins, "https://ui.example.com")
+	if isLegacyAuth {
+		settings.Basic.Set(authLegacyEnabled, "true")
+	}
+
+	service := &WebService{}
+	service.InitFromSettings(settings)
+	return service, service.NewRouter()
+}
+
+func serveTest(router *gin.Engine, method string, url string, prepare func(request *http.Request)) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, url, nil)
+	if prepare != nil {
+		prepare(request)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func asUser(name string, password string) func(request *http.Request) {
+	return func(request *http.Request) {
+		request.SetBasicAuth(name, password)
+	}
+}
+
+func TestApiV1_Authorization(t *testing.T) {
+	service, router := newTestWebService(false)
+	unauthorizedCount := service.Auth.Metrics.UnauthorizedCount.Value
+
+	response := serveTest(router, http.MethodGet, "/api/v1/version", nil)
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	assert.Equal(t, `Basic realm="rvpro"`, response.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, int64(1), service.Auth.Metrics.UnauthorizedCount.Value-unauthorizedCount)
+
+	response = serveTest(router, http.MethodGet, "/api/v1/me", asUser("view", "v"))
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `{"Name":"view","Role":"viewer"}`, response.Body.String())
+
+	response = serveTest(router, http.MethodPut, "/api/v1/state/phase?red=1&yellow=0&green=0", asUser("view", "v"))
+	assert.Equal(t, http.StatusForbidden, response.Code)
+
+	response = serveTest(router, http.MethodGet, "/api/v1/state/keys", func(request *http.Request) {
+		request.Header.Set("Authorization", "Bearer tok")
+	})
+	assert.Equal(t, http.StatusForbidden, response.Code)
+
+	response = serveTest(router, http.MethodGet, "/api/v1/state/keys", asUser("root", "r"))
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	response = serveTest(router, http.MethodGet, "/api/v1/openapi.json", nil)
+	assert.Equal(t, http.StatusOK, response.Code)
+}
+
+func TestApiV1_Legacy(t *testing.T) {
+	_, router := newTestWebService(false)
+
+	response := serveTest(router, http.MethodGet, "/metrics/sections", nil)
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	_, router = newTestWebService(true)
+
+	response = serveTest(router, http.MethodPut, "/state/set/phase?red=1&yellow=0&green=0", nil)
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+
+	response = serveTest(router, http.MethodPut, "/state/set/phase?red=1&yellow=0&green=0", asUser("view", "v"))
+	assert.Equal(t, http.StatusForbidden, response.Code)
+
+	response = serveTest(router, http.MethodGet, "/metrics/sections", asUser("view", "v"))
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	// Open unless enabled
+	settings := &utils.Settings{}
+	settings.Init()
+	auth := WebAuth{}
+	auth.InitFromSettings(settings)
+	assert.False(t, auth.LegacyEnabled)
+}
+
+func TestWebService_LogFormatter(t *testing.T) {
+	line := logFormatter(gin.LogFormatterParams{Method: http.MethodGet, Path: "/socket?access_token=tok", StatusCode: http.StatusOK})
+
+	assert.Contains(t, line, `"/socket"`)
+	assert.NotContains(t, line, "tok")
+}
+
+func TestApiV1_Cors(t *testing.T) {
+	_, router := newTestWebService(false)
+
+	response := serveTest(router, http.MethodOptions, "/api/v1/state/phase", func(request *http.Request) {
+		request.Header.Set("Origin", "https://ui.example.com")
+	})
+	assert.Equal(t, http.StatusNoContent, response.Code)
+	assert.Equal(t, "https://ui.example.com", response.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, response.Header().Get("Access-Control-Allow-Headers"), "Authorization")
+
+	response = serveTest(router, http.MethodGet, "/api/v1/me", func(request *http.Request) {
+		request.Header.Set("Origin", "https://evil.example.com")
+		request.SetBasicAuth("view", "v")
+	})
+	assert.Equal(t, http.StatusForbidden, response.Code)
+	assert.Empty(t, response.Header().Get("Access-Control-Allow-Origin"))
+}
+
+// The OpenAPI document shipped must be the api served, regenerate it with
+// rvpro --mode=dump-openapi --openapi-file=internal/api/services/web/openapi.json
+func TestWebService_OpenAPIMatchesFile(t *testing.T) {
+	var buffer bytes.Buffer
+	assert.NoError(t, new(WebService).WriteOpenAPI(&buffer))
+
+	expected, err := os.ReadFile("openapi.json")
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), buffer.String())
+
+	var document map[string]any
+	assert.NoError(t, json.Unmarshal(expected, &document))
+	assert.Contains(t, document["paths"], "/state/phase")
+}
@@ -0,0 +1,155 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"unicode"
+)
+
+const OpenAPIVersion = "1.0.0"
+
+// OpenAPI returns the OpenAPI 3 document of the api, generated from the
+// routes registered
+func (w *WebService) OpenAPI() map[string]any {
+	paths := make(map[string]any)
+
+	for _, route := range w.apiV1Routes() {
+		operations, ok := paths[route.Path].(map[string]any)
+		if !ok {
+			operations = make(map[string]any)
+			paths[route.Path] = operations
+		}
+		operations[strings.ToLower(route.Method)] = w.openAPIOperation(route)
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "rvpro API",
+			"version":     OpenAPIVersion,
+			"description": "The radar, metric and state api of rvpro. The roles are viewer, operator and admin, a role includes the lower roles",
+		},
+		"servers": []any{
+			map[string]any{"url": ApiV1Prefix},
+		},
+		"components": map[string]any{
+			"securitySchemes": map[string]any{
+				"basicAuth":  map[string]any{"type": "http", "scheme": "basic"},
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+			"schemas": map[string]any{
+				"Error": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"error": map[string]any{"type": "string"},
+					},
+				},
+			},
+		},
+		"paths": paths,
+	}
+}
+
+// WriteOpenAPI writes the OpenAPI document (JSON)
+func (w *WebService) WriteOpenAPI(writer io.Writer) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(w.OpenAPI())
+}
+
+func (w *WebService) openAPIOperation(route *ApiRoute) map[string]any {
+	status := "200"
+	if route.IsWebSocket {
+		status = "101"
+	}
+
+	responses := map[string]any{
+		status: map[string]any{"description": route.Response},
+	}
+
+	res := map[string]any{
+		"operationId": w.openAPIOperationId(route),
+		"summary":     route.Summary,
+		"responses":   responses,
+	}
+
+	if len(route.Params) > 0 {
+		params := make([]any, 0, len(route.Params))
+
+		for _, param := range route.Params {
+			schema := map[string]any{"type": param.Type}
+			if param.IsArray {
+				schema = map[string]any{"type": "array", "items": schema}
+			}
+
+			params = append(params, map[string]any{
+				"name":        param.Name,
+				"in":          param.In,
+				"required":    param.IsRequired,
+				"description": param.Description,
+				"schema":      schema,
+			})
+		}
+		res["parameters"] = params
+		responses["400"] = w.openAPIError("Invalid parameters")
+	}
+
+	if len(route.Body) > 0 {
+		res["requestBody"] = map[string]any{
+			"description": route.Body,
+			"required":    true,
+			"content": map[string]any{
+				"application/json": map[string]any{
+					"schema": map[string]any{"type": "object"},
+				},
+			},
+		}
+		responses["400"] = w.openAPIError("Invalid body")
+		responses["422"] = w.openAPIError("Rejected, the running state is unchanged")
+	}
+
+	if route.Role != RoleNone {
+		res["security"] = []any{
+			map[string]any{"basicAuth": []any{}},
+			map[string]any{"bearerAuth": []any{}},
+		}
+		res["x-role"] = route.Role.String()
+		responses["401"] = w.openAPIError("Authentication required")
+		responses["403"] = w.openAPIError("The " + route.Role.String() + " role is required")
+	}
+	return res
+}
+
+func (w *WebService) openAPIError(description string) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content": map[string]any{
+			"application/json": map[string]any{
+				"schema": map[string]any{"$ref": "#/components/schemas/Error"},
+			},
+		},
+	}
+}
+
+// openAPIOperationId returns the method and path in camel case, PUT
+// /state/phase is putStatePhase
+func (w *WebService) openAPIOperationId(route *ApiRoute) string {
+	var bld strings.Builder
+	bld.WriteString(strings.ToLower(route.Method))
+
+	isUpper := true
+	for _, char := range route.Path {
+		if !unicode.IsLetter(char) && !unicode.IsDigit(char) {
+			isUpper = true
+			continue
+		}
+
+		if isUpper {
+			char = unicode.ToUpper(char)
+			isUpper = false
+		}
+		bld.WriteRune(char)
+	}
+	return bld.String()
+}
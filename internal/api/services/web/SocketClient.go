@@ -1,17 +1,67 @@
 package web
 
 import (
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/rs/zerolog/log"
+	"rvpro3/radarvision.com/utils"
 )
 
 type SocketClient struct {
 	Subscriptions uint64
+	Radars        []utils.IP4
 	service       *SocketService
 	conn          *websocket.Conn
 	send          chan *SocketPayload
+	lock          sync.RWMutex
+}
+
+// SetFilter sets the subscriptions, and the radars (ip or ip:port) of the
+// radar payloads, no radars being all radars
+func (s *SocketClient) SetFilter(subscriptions uint64, radars []string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.Subscriptions = subscriptions
+	s.Radars = s.Radars[:0]
+
+	for _, radar := range radars {
+		if radar = strings.TrimSpace(radar); len(radar) > 0 {
+			s.Radars = append(s.Radars, utils.IP4Builder.FromString(radar))
+		}
+	}
+}
+
+// IsSubscribedTo returns whether the client subscribed to any of the mask,
+// for the radar when not zero
+func (s *SocketClient) IsSubscribedTo(mask uint64, radar utils.IP4) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.Subscriptions&mask == 0 {
+		return false
+	}
+
+	if len(s.Radars) == 0 || radar.ToU32() == 0 {
+		return true
+	}
+
+	return slices.ContainsFunc(s.Radars, radar.IsEqualIP)
+}
+
+func (s *SocketClient) getFilter() (uint64, []string) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	radars := make([]string, 0, len(s.Radars))
+	for _, radar := range s.Radars {
+		radars = append(radars, radar.ToIPString())
+	}
+	return s.Subscriptions, radars
 }
 
 func (s *SocketClient) readSocket() {
@@ -52,7 +102,7 @@ func (s *SocketClient) writeSocket() {
 		select {
 		case message, ok := <-s.send:
 			if ok {
-				if message.Subscription == 0 || message.Subscription&s.Subscriptions != 0 {
+				if message.Subscription == 0 || s.IsSubscribedTo(message.Subscription, message.Radar) {
 					_ = s.conn.SetWriteDeadline(s.service.WriteDeadline.Add(time.Now()))
 					if !ok {
 						_ = s.conn.WriteMessage(websocket.CloseMessage, []byte{})
@@ -109,10 +159,23 @@ func (s *SocketClient) handleMessage(message []byte) {
 
 	switch requestMsg.GetType("") {
 	case "my-subscriptions-request":
+		subscriptions, radars := s.getFilter()
 		responseMsg := SocketMessage{}
 		responseMsg.Init()
 		responseMsg.SetType("my-subscriptions-response")
-		responseMsg.SetInt("Value", int(s.Subscriptions))
+		responseMsg.SetInt("Value", int(subscriptions))
+		responseMsg.SetAny("Radars", radars)
+		s.send <- responseMsg.ToPayload(0)
+
+	case "subscribe-request":
+		s.SetFilter(uint64(requestMsg.GetInt("Value", 0)), requestMsg.GetStrings("Radars"))
+
+		subscriptions, radars := s.getFilter()
+		responseMsg := SocketMessage{}
+		responseMsg.Init()
+		responseMsg.SetType("subscribe-response")
+		responseMsg.SetInt("Value", int(subscriptions))
+		responseMsg.SetAny("Radars", radars)
 		s.send <- responseMsg.ToPayload(0)
 	}
 }
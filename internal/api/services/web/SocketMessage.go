@@ -1,11 +1,16 @@
 package web
 
-import "encoding/json"
+import (
+	"encoding/json"
 
-const SocketTime uint64 = 1
+	"rvpro3/radarvision.com/utils"
+)
 
+// SocketPayload is sent to the clients subscribed (streammodel flags), a
+// radar payload only to the clients filtering on the radar (or not filtering)
 type SocketPayload struct {
 	Subscription uint64
+	Radar        utils.IP4
 	Payload      []byte
 }
 
@@ -33,6 +38,30 @@ func (m *SocketMessage) SetInt(key string, value int) {
 	m.Data[key] = value
 }
 
+func (m *SocketMessage) SetAny(key string, value any) {
+	m.Data[key] = value
+}
+
+// GetInt returns the number (JSON numbers are float64)
+func (m *SocketMessage) GetInt(key string, defValue int) int {
+	if value, ok := m.Data[key].(float64); ok {
+		return int(value)
+	}
+	return defValue
+}
+
+// GetStrings returns the array of strings, ignoring other values
+func (m *SocketMessage) GetStrings(key string) (res []string) {
+	values, _ := m.Data[key].([]any)
+
+	for _, value := range values {
+		if str, ok := value.(string); ok {
+			res = append(res, str)
+		}
+	}
+	return res
+}
+
 func (m *SocketMessage) ToPayload(subscription uint64) *SocketPayload {
 	data, _ := json.Marshal(m.Data)
 
@@ -1,6 +1,8 @@
 package web
 
 import (
+	"sync"
+
 	"rvpro3/radarvision.com/utils"
 )
 
@@ -10,20 +12,30 @@ type SocketService struct {
 	PingEvery     utils.Milliseconds
 	WriteDeadline utils.Milliseconds
 	MaxReadSize   int64
+	Metrics       SocketServiceMetrics `json:"-"`
 	clients       map[*SocketClient]bool
+	clientsLock   sync.RWMutex
 	register      chan *SocketClient
 	unregister    chan *SocketClient
 	broadcast     chan *SocketPayload
 	done          chan bool
 }
 
+type SocketServiceMetrics struct {
+	BroadcastCount *utils.Metric
+	DroppedCount   *utils.Metric
+	utils.MetricsInitMixin
+}
+
 func NewSocketService() *SocketService {
-	return &SocketService{
+	res := &SocketService{
 		clients:    make(map[*SocketClient]bool),
 		register:   make(chan *SocketClient),
 		unregister: make(chan *SocketClient),
-		broadcast:  make(chan *SocketPayload),
+		broadcast:  make(chan *SocketPayload, 64),
 	}
+	res.Metrics.InitMetrics("Socket.Service", &res.Metrics)
+	return res
 }
 
 func (s *SocketService) Run() {
@@ -31,16 +43,25 @@ func (s *SocketService) Run() {
 	for {
 		select {
 		case client := <-s.register:
+			s.clientsLock.Lock()
 			s.clients[client] = true
+			s.clientsLock.Unlock()
 
 		case client := <-s.unregister:
+			s.clientsLock.Lock()
 			if _, ok := s.clients[client]; ok {
 				delete(s.clients, client)
 				close(client.send)
 			}
+			s.clientsLock.Unlock()
 
 		case message := <-s.broadcast:
+			s.clientsLock.Lock()
 			for client := range s.clients {
+				if message.Subscription != 0 && !client.IsSubscribedTo(message.Subscription, message.Radar) {
+					continue
+				}
+
 				select {
 				case client.send <- message:
 					// Unable to send to the client, either too slow or disconnected
@@ -49,35 +70,59 @@ func (s *SocketService) Run() {
 					close(client.send)
 				}
 			}
+			s.clientsLock.Unlock()
+
 		case <-s.done:
 			s.Terminated = true
+			s.clientsLock.Lock()
 			for client := range s.clients {
 				delete(s.clients, client)
 				close(client.send)
 			}
+			s.clientsLock.Unlock()
 		}
 	}
 }
 
 func (s *SocketService) NoSubscriptions(mask uint64) (res int) {
+	s.clientsLock.RLock()
+	defer s.clientsLock.RUnlock()
+
 	for client := range s.clients {
-		if client.Subscriptions&mask != 0 {
+		if client.IsSubscribedTo(mask, utils.IP4{}) {
 			res++
 		}
 	}
 	return res
 }
 
+// Broadcast queues the message, dropping it rather than blocking the radar
+// workflows when the clients cannot keep up
 func (s *SocketService) Broadcast(msg *SocketPayload) {
 	if s.Terminated {
 		return
 	}
-	s.broadcast <- msg
+
+	select {
+	case s.broadcast <- msg:
+		s.Metrics.BroadcastCount.Inc(1)
+	default:
+		s.Metrics.DroppedCount.Inc(1)
+	}
 }
 
 func (s *SocketService) IsAnySubscribed(mask uint64) bool {
+	return s.IsAnySubscribedTo(mask, utils.IP4{})
+}
+
+// IsAnySubscribedTo returns whether any client subscribed to the mask for
+// the radar, a zero radar being any radar
+func (s *SocketService) IsAnySubscribedTo(mask uint64, radar utils.IP4) bool {
+	s.clientsLock.RLock()
+	defer s.clientsLock.RUnlock()
+
 	for client := range s.clients {
-		if client.Subscriptions&mask != 0 {
+		if client.IsSubscribedTo(mask, radar) {
 			return true
 		}
 	}
@@ -0,0 +1,99 @@
+package web
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/internal/models/streammodel"
+	"rvpro3/radarvision.com/utils"
+)
+
+func newTestSocketClient(service *SocketService, subscriptions uint64, radars ...string) *SocketClient {
+	client := &SocketClient{
+		service: service,
+		send:    make(chan *SocketPayload, 10),
+	}
+	client.SetFilter(subscriptions, radars)
+	return client
+}
+
+func TestSocketClient_IsSubscribedTo(t *testing.T) {
+	north := utils.IP4Builder.FromString("192.168.11.12:55555")
+	south := utils.IP4Builder.FromString("192.168.11.13:55555")
+
+	client := newTestSocketClient(nil, streammodel.SubscriptionObjectList|streammodel.SubscriptionTrigger, "192.168.11.12", "")
+	assert.True(t, client.IsSubscribedTo(streammodel.SubscriptionObjectList, north))
+	assert.False(t, client.IsSubscribedTo(streammodel.SubscriptionObjectList, south))
+	assert.False(t, client.IsSubscribedTo(streammodel.SubscriptionPVR, north))
+	assert.True(t, client.IsSubscribedTo(streammodel.SubscriptionTrigger, utils.IP4{}))
+
+	client.SetFilter(streammodel.SubscriptionPVR, nil)
+	assert.True(t, client.IsSubscribedTo(streammodel.SubscriptionPVR, south))
+	assert.False(t, client.IsSubscribedTo(streammodel.SubscriptionObjectList, north))
+}
+
+func TestSocketService_Broadcast(t *testing.T) {
+	north := utils.IP4Builder.FromString("192.168.11.12:55555")
+	south := utils.IP4Builder.FromString("192.168.11.13:55555")
+
+	service := NewSocketService()
+	go service.Run()
+
+	northClient := newTestSocketClient(service, streammodel.SubscriptionObjectList, "192.168.11.12")
+	allClient := newTestSocketClient(service, streammodel.SubscriptionObjectList|streammodel.SubscriptionTime)
+	service.register <- northClient
+	service.register <- allClient
+
+	// The clients are added by the service after being received
+	assert.Eventually(t, func() bool {
+		return service.NoSubscriptions(streammodel.SubscriptionObjectList) == 2
+	}, time.Second, time.Millisecond)
+
+	assert.True(t, service.IsAnySubscribedTo(streammodel.SubscriptionObjectList, south))
+	assert.False(t, service.IsAnySubscribedTo(streammodel.SubscriptionPVR, north))
+
+	service.Broadcast(&SocketPayload{Subscription: streammodel.SubscriptionObjectList, Radar: south, Payload: []byte("south")})
+	service.Broadcast(&SocketPayload{Subscription: streammodel.SubscriptionObjectList, Radar: north, Payload: []byte("north")})
+
+	assert.Equal(t, "south", string(receivePayload(t, allClient)))
+	assert.Equal(t, "north", string(receivePayload(t, allClient)))
+	assert.Equal(t, "north", string(receivePayload(t, northClient)))
+	assert.Empty(t, northClient.send)
+}
+
+func receivePayload(t *testing.T, client *SocketClient) []byte {
+	select {
+	case payload := <-client.send:
+		return payload.Payload
+	case <-time.After(time.Second):
+		assert.Fail(t, "no payload received")
+		return nil
+	}
+}
+
+func TestWebService_Stream(t *testing.T) {
+	radar := utils.IP4Builder.FromString("192.168.11.12:55555")
+
+	// Without the sockets nothing is subscribed, nor sent
+	service := &WebService{}
+	assert.False(t, service.IsAnySubscribedTo(streammodel.SubscriptionPVR, radar))
+	assert.NoError(t, service.Stream(streammodel.SubscriptionPVR, radar, &streammodel.PVRMessage{}))
+
+	service.Sockets = NewSocketService()
+	go service.Sockets.Run()
+
+	client := newTestSocketClient(service.Sockets, streammodel.SubscriptionPVR)
+	service.Sockets.register <- client
+
+	assert.Eventually(t, func() bool {
+		return service.IsAnySubscribedTo(streammodel.SubscriptionPVR, radar)
+	}, time.Second, 10*time.Millisecond)
+
+	message := &streammodel.TriggerMessage{
+		RadarMessage: streammodel.NewRadarMessage(streammodel.TriggerType, radar, time.Now()),
+		Relays:       "3",
+	}
+	assert.NoError(t, service.Stream(streammodel.SubscriptionPVR, radar, message))
+	assert.Contains(t, string(receivePayload(t, client)), `"Relays":"3"`)
+}
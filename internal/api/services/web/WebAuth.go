@@ -0,0 +1,235 @@
+package web
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/bcrypt"
+	"rvpro3/radarvision.com/utils"
+)
+
+const authUsers = "http.auth.users"
+const authTokens = "http.auth.tokens"
+const authRealm = "http.auth.realm"
+const authLegacyEnabled = "http.auth.legacy.enabled"
+
+// The kinds of credential, a user (basic auth) or a token (bearer auth)
+const (
+	authKindUser  = "user"
+	authKindToken = "token"
+)
+
+// WebUserKey is the gin context key of the authenticated *WebUser
+const WebUserKey = "Web.User"
+
+// Role is the access level of a user, a role includes the lower roles
+type Role int
+
+const (
+	RoleNone Role = iota
+	RoleViewer
+	RoleOperator
+	RoleAdmin
+)
+
+var roleNames = []string{"none", "viewer", "operator", "admin"}
+
+func (r Role) String() string {
+	if r < RoleNone || r > RoleAdmin {
+		return "unknown"
+	}
+	return roleNames[r]
+}
+
+func (r Role) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// ParseRole returns the role by name, RoleNone when unknown
+func ParseRole(name string) Role {
+	for index, roleName := range roleNames {
+		if strings.EqualFold(roleName, strings.TrimSpace(name)) {
+			return Role(index)
+		}
+	}
+	return RoleNone
+}
+
+// WebUser is a user (basic auth) or a token (bearer auth), only the bcrypt
+// hash of the password or token is configured
+type WebUser struct {
+	Name       string
+	Role       Role
+	SecretHash string `json:"-"`
+}
+
+// WebAuth authenticates the requests, and authorizes them by role.  Users
+// and tokens are configured as name:role:bcrypt;...  As bcrypt is slow by
+// design, the secrets verified are remembered by the SHA-256 of their kind,
+// name and secret, in memory only
+type WebAuth struct {
+	Users         []*WebUser
+	Tokens        []*WebUser
+	Realm         string
+	LegacyEnabled bool
+	Metrics       WebAuthMetrics `json:"-"`
+	verified      map[[sha256.Size]byte]*WebUser
+	verifiedLock  sync.Mutex
+}
+
+type WebAuthMetrics struct {
+	UnauthorizedCount *utils.Metric
+	ForbiddenCount    *utils.Metric
+	utils.MetricsInitMixin
+}
+
+func (a *WebAuth) InitFromSettings(settings *utils.Settings) {
+	a.Users = ParseWebUsers(settings.Basic.GetArray(authUsers, ""))
+	a.Tokens = ParseWebUsers(settings.Basic.GetArray(authTokens, ""))
+	a.Realm = settings.Basic.Get(authRealm, "rvpro")
+	a.LegacyEnabled = settings.Basic.GetBool(authLegacyEnabled, false)
+	a.Metrics.InitMetrics("Web.Auth", &a.Metrics)
+}
+
+// HashSecret returns the bcrypt hash of the password or token, as configured
+// in http.auth.users and http.auth.tokens
+func HashSecret(secret string) string {
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		log.Err(err).Msg("WebAuth.HashSecret")
+		return ""
+	}
+	return string(hash)
+}
+
+// ParseWebUsers parses the name:role:bcrypt entries, ignoring the blank and
+// invalid ones
+func ParseWebUsers(entries []string) []*WebUser {
+	res := make([]*WebUser, 0, len(entries))
+
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || len(parts[0]) == 0 || ParseRole(parts[1]) == RoleNone || !isBcryptHash(parts[2]) {
+			log.Warn().Str("entry", parts[0]).Msg("WebAuth.ParseWebUsers - invalid user, expected name:role:bcrypt")
+			continue
+		}
+
+		res = append(res, &WebUser{
+			Name:       parts[0],
+			Role:       ParseRole(parts[1]),
+			SecretHash: parts[2],
+		})
+	}
+	return res
+}
+
+func isBcryptHash(hash string) bool {
+	_, err := bcrypt.Cost([]byte(hash))
+	return err == nil
+}
+
+// Authenticate returns the user of the basic or bearer authorization, the
+// basic authorization requires a name.  Only the websocket upgrade takes the
+// bearer token as access_token, as browsers cannot set the headers of a
+// websocket
+func (a *WebAuth) Authenticate(request *http.Request) *WebUser {
+	if name, password, ok := request.BasicAuth(); ok {
+		return a.find(authKindUser, a.Users, name, password)
+	}
+
+	var token string
+	if websocket.IsWebSocketUpgrade(request) {
+		token = request.URL.Query().Get("access_token")
+	}
+
+	if header := request.Header.Get("Authorization"); len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		token = strings.TrimSpace(header[7:])
+	}
+
+	if len(token) > 0 {
+		return a.find(authKindToken, a.Tokens, "", token)
+	}
+	return nil
+}
+
+// find returns the user of the secret, a user by its (required) name and any
+// token
+func (a *WebAuth) find(kind string, users []*WebUser, name string, secret string) *WebUser {
+	if kind == authKindUser && len(name) == 0 {
+		return nil
+	}
+	key := sha256.Sum256([]byte(kind + ":" + name + ":" + secret))
+
+	a.verifiedLock.Lock()
+	user, ok := a.verified[key]
+	a.verifiedLock.Unlock()
+
+	if ok {
+		return user
+	}
+
+	for _, user = range users {
+		if kind == authKindUser && user.Name != name {
+			continue
+		}
+
+		if bcrypt.CompareHashAndPassword([]byte(user.SecretHash), []byte(secret)) != nil {
+			continue
+		}
+
+		a.verifiedLock.Lock()
+		if a.verified == nil {
+			a.verified = make(map[[sha256.Size]byte]*WebUser)
+		}
+		a.verified[key] = user
+		a.verifiedLock.Unlock()
+		return user
+	}
+	return nil
+}
+
+// Authorize returns the middleware requiring the role, RoleNone is public
+func (a *WebAuth) Authorize(role Role) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if role == RoleNone {
+			context.Next()
+			return
+		}
+
+		user := a.Authenticate(context.Request)
+		if user == nil {
+			a.Metrics.UnauthorizedCount.Inc(1)
+			context.Header("WWW-Authenticate", `Basic realm="`+a.Realm+`"`)
+			context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return
+		}
+
+		if user.Role < role {
+			a.Metrics.ForbiddenCount.Inc(1)
+			context.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": role.String() + " role required"})
+			return
+		}
+
+		context.Set(WebUserKey, user)
+		context.Next()
+	}
+}
+
+// AuthorizeLegacy protects the routes outside /api/v1 only when enabled, as
+// existing integrations call them without credentials
+func (a *WebAuth) AuthorizeLegacy(role Role) gin.HandlerFunc {
+	if !a.LegacyEnabled {
+		return a.Authorize(RoleNone)
+	}
+	return a.Authorize(role)
+}
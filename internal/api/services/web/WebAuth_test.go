@@ -0,0 +1,112 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/utils"
+)
+
+func TestHashSecret(t *testing.T) {
+	hash := HashSecret("secret")
+
+	assert.True(t, isBcryptHash(hash))
+	assert.NotEqual(t, hash, HashSecret("secret"), "salted")
+	assert.False(t, isBcryptHash("2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"))
+}
+
+func TestParseRole(t *testing.T) {
+	assert.Equal(t, RoleViewer, ParseRole("viewer"))
+	assert.Equal(t, RoleOperator, ParseRole(" Operator"))
+	assert.Equal(t, RoleAdmin, ParseRole("ADMIN"))
+	assert.Equal(t, RoleNone, ParseRole("root"))
+	assert.Equal(t, "unknown", Role(9).String())
+}
+
+func TestParseWebUsers(t *testing.T) {
+	users := ParseWebUsers([]string{
+		"ops:operator:" + HashSecret("pw"),
+		"",
+		"nobody:root:" + HashSecret("pw"),
+		"nohash:viewer:",
+		"sha:viewer:2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b",
+		"short",
+	})
+
+	if assert.Len(t, users, 1) {
+		assert.Equal(t, "ops", users[0].Name)
+		assert.Equal(t, RoleOperator, users[0].Role)
+	}
+}
+
+func TestWebAuth_Authenticate(t *testing.T) {
+	auth := &WebAuth{
+		Users:  ParseWebUsers([]string{"ops:operator:" + HashSecret("pw")}),
+		Tokens: ParseWebUsers([]string{"ui:viewer:" + HashSecret("tok")}),
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/me", nil)
+	request.SetBasicAuth("ops", "pw")
+	assert.Equal(t, "ops", auth.Authenticate(request).Name)
+
+	request.SetBasicAuth("ops", "wrong")
+	assert.Nil(t, auth.Authenticate(request))
+
+	// A token is not a password
+	request.SetBasicAuth("ui", "tok")
+	assert.Nil(t, auth.Authenticate(request))
+
+	request = httptest.NewRequest(http.MethodGet, "/api/v1/me", nil)
+	request.Header.Set("Authorization", "Bearer tok")
+	assert.Equal(t, "ui", auth.Authenticate(request).Name)
+
+	// The access_token is only taken by the websocket upgrade
+	request = httptest.NewRequest(http.MethodGet, "/api/v1/me?access_token=tok", nil)
+	assert.Nil(t, auth.Authenticate(request))
+
+	request = httptest.NewRequest(http.MethodGet, "/api/v1/socket?access_token=tok", nil)
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Upgrade", "websocket")
+	assert.Equal(t, "ui", auth.Authenticate(request).Name)
+	assert.Equal(t, "ui", auth.Authenticate(request).Name, "verified before")
+
+	// A basic authorization requires a name, the secrets verified are not
+	// shared between the users and the tokens
+	request = httptest.NewRequest(http.MethodGet, "/api/v1/me", nil)
+	request.SetBasicAuth("", "tok")
+	assert.Nil(t, auth.Authenticate(request))
+	request.SetBasicAuth("", "pw")
+	assert.Nil(t, auth.Authenticate(request))
+
+	request = httptest.NewRequest(http.MethodGet, "/api/v1/me", nil)
+	request.Header.Set("Authorization", "Bearer pw")
+	assert.Nil(t, auth.Authenticate(request))
+
+	request = httptest.NewRequest(http.MethodGet, "/api/v1/me", nil)
+	assert.Nil(t, auth.Authenticate(request))
+}
+
+func TestWebOrigins_IsAllowed(t *testing.T) {
+	origins := &WebOrigins{Origins: []string{"https://ui.example.com"}}
+
+	request := httptest.NewRequest(http.MethodGet, "http://rvpro:8080/api/v1/socket", nil)
+	assert.True(t, origins.IsAllowed(request))
+
+	request.Header.Set("Origin", "http://rvpro:8080")
+	assert.True(t, origins.IsAllowed(request))
+
+	request.Header.Set("Origin", "https://UI.example.com")
+	assert.True(t, origins.IsAllowed(request))
+
+	request.Header.Set("Origin", "https://evil.example.com")
+	assert.False(t, origins.IsAllowed(request))
+
+	settings := &utils.Settings{}
+	settings.Init()
+	settings.Basic.Set(corsOrigins, "*;https://ui.example.com/")
+	origins.InitFromSettings(settings)
+	assert.Equal(t, []string{"https://ui.example.com"}, origins.Origins, "any origin is rejected")
+	assert.False(t, origins.IsAllowed(request))
+}
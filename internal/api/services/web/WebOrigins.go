@@ -0,0 +1,84 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"rvpro3/radarvision.com/utils"
+)
+
+const corsOrigins = "http.cors.origins"
+
+// WebOrigins are the browser origins (scheme://host:port) allowed to call
+// the API and open websockets besides the web service itself.  As the
+// requests carry the credentials, * (any origin) is rejected
+type WebOrigins struct {
+	Origins []string
+}
+
+func (o *WebOrigins) InitFromSettings(settings *utils.Settings) {
+	o.Origins = o.Origins[:0]
+
+	for _, origin := range settings.Basic.GetArray(corsOrigins, "") {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+
+		if origin == "*" {
+			log.Warn().Msg("WebOrigins.InitFromSettings - http.cors.origins * ignored, list the origins allowed")
+			continue
+		}
+
+		if len(origin) > 0 {
+			o.Origins = append(o.Origins, origin)
+		}
+	}
+}
+
+// IsAllowed returns whether the origin of the request is allowed, requests
+// without an origin are not from a browser
+func (o *WebOrigins) IsAllowed(request *http.Request) bool {
+	origin := request.Header.Get("Origin")
+	if len(origin) == 0 {
+		return true
+	}
+
+	if originURL, err := url.Parse(origin); err == nil && strings.EqualFold(originURL.Host, request.Host) {
+		return true
+	}
+
+	return slices.ContainsFunc(o.Origins, func(allowed string) bool {
+		return strings.EqualFold(allowed, origin)
+	})
+}
+
+// Cors returns the middleware adding the CORS headers for the allowed
+// origins, and answering the preflight requests
+func (o *WebOrigins) Cors() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		origin := context.GetHeader("Origin")
+
+		if len(origin) > 0 {
+			if !o.IsAllowed(context.Request) {
+				context.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "origin not allowed"})
+				return
+			}
+
+			context.Header("Access-Control-Allow-Origin", origin)
+			context.Header("Access-Control-Allow-Credentials", "true")
+			context.Header("Vary", "Origin")
+		}
+
+		if context.Request.Method == http.MethodOptions {
+			context.Header("Access-Control-Allow-Methods", "GET, PUT, POST, DELETE, OPTIONS")
+			context.Header("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			context.Header("Access-Control-Max-Age", "600")
+			context.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		context.Next()
+	}
+}
@@ -1,13 +1,20 @@
 package web
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"github.com/rs/zerolog/log"
 	"rvpro3/radarvision.com/internal/general"
+	"rvpro3/radarvision.com/internal/services/metrichistory"
+	"rvpro3/radarvision.com/internal/services/pvrhistory"
+	"rvpro3/radarvision.com/internal/services/statisticshistory"
 	"rvpro3/radarvision.com/internal/smartmicro/interfaces"
 	"rvpro3/radarvision.com/utils"
 )
@@ -21,14 +28,9 @@ const socketPongEvery = "http.sockets.pong.every"
 const socketWriteDeadline = "http.sockets.write.deadline"
 const socketMaxReadSize = "http.sockets.max.read.size"
 const socketMaxWriteSize = "http.sockets.max.write.size"
-
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  2000,
-	WriteBufferSize: 2000,
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
-}
+const metricsPrefix = "http.metrics.prefix"
+const tlsCertFile = "http.tls.cert.file"
+const tlsKeyFile = "http.tls.key.file"
 
 type WebService struct {
 	Sockets             *SocketService
@@ -40,6 +42,12 @@ type WebService struct {
 	SocketWriteDeadline utils.Milliseconds
 	SocketMaxReadSize   int
 	SocketMaxWriteSize  int
+	MetricsPrefix       string
+	TLSCertFile         string
+	TLSKeyFile          string
+	Auth                WebAuth
+	Origins             WebOrigins
+	upgrader            *websocket.Upgrader
 }
 
 func (w *WebService) InitFromSettings(settings *utils.Settings) {
@@ -52,6 +60,12 @@ func (w *WebService) InitFromSettings(settings *utils.Settings) {
 	w.SocketWriteDeadline = settings.Basic.GetMilliseconds(socketWriteDeadline, 3000)
 	w.SocketMaxReadSize = settings.Basic.GetInt(socketMaxReadSize, 2*utils.Kilobyte)
 	w.SocketMaxWriteSize = settings.Basic.GetInt(socketMaxWriteSize, 2*utils.Kilobyte)
+	w.MetricsPrefix = settings.Basic.Get(metricsPrefix, "rvpro")
+	w.TLSCertFile = settings.Basic.Get(tlsCertFile, "")
+	w.TLSKeyFile = settings.Basic.Get(tlsKeyFile, "")
+
+	w.Auth.InitFromSettings(settings)
+	w.Origins.InitFromSettings(settings)
 }
 
 func (w *WebService) Start(state *utils.State, settings *utils.Settings) {
@@ -69,28 +83,21 @@ func (w *WebService) Start(state *utils.State, settings *utils.Settings) {
 		w.Sockets.PongEvery = w.SocketPongEvery
 		w.Sockets.WriteDeadline = w.SocketWriteDeadline
 		w.Sockets.MaxReadSize = int64(w.SocketMaxWriteSize)
-		upgrader.ReadBufferSize = w.SocketMaxWriteSize
-		upgrader.WriteBufferSize = w.SocketMaxWriteSize
 		go w.Sockets.Run()
 	}
 
-	router := gin.Default()
-	router.GET("/general/version", w.getGeneralVersion)
-	router.GET("/metrics/section", w.getMetricsSection)
-	router.GET("/metrics/sections", w.getMetricsSections)
-	router.GET("/state/keys", w.getStateKeys)
-	router.GET("/state/key", w.getStateKey)
-	router.PUT("/state/set/phase", w.setPhaseState)
+	router := w.NewRouter()
 
-	//router.PUT("/executor/radars/stop", putStopRadars)
-	//router.PUT("/executor/radars/start", putStartRadars)
-	//router.GET("/executor/radars/status", getRadarsStatus)
+	go func() {
+		var err error
 
-	// WebSocket
-	router.GET("/socket", w.getSocket)
+		if len(w.TLSCertFile) > 0 && len(w.TLSKeyFile) > 0 {
+			err = router.RunTLS(w.Host, w.TLSCertFile, w.TLSKeyFile)
+		} else {
+			err = router.Run(w.Host)
+		}
 
-	go func() {
-		if err := router.Run(w.Host); err != nil {
+		if err != nil {
 			// Handle the error if the server fails to start
 			utils.Debug.Panic(err)
 		}
@@ -101,6 +108,57 @@ func (w *WebService) Start(state *utils.State, settings *utils.Settings) {
 	state.Set(WebServiceName, w)
 }
 
+// NewRouter returns the routes, the routes outside /api/v1 are only
+// authenticated when http.auth.legacy.enabled.  The requests are logged without
+// their query string, as it can hold the access_token
+func (w *WebService) NewRouter() *gin.Engine {
+	w.upgrader = &websocket.Upgrader{
+		ReadBufferSize:  w.SocketMaxWriteSize,
+		WriteBufferSize: w.SocketMaxWriteSize,
+		CheckOrigin:     w.Origins.IsAllowed,
+	}
+
+	viewer := w.Auth.AuthorizeLegacy(RoleViewer)
+	operator := w.Auth.AuthorizeLegacy(RoleOperator)
+	admin := w.Auth.AuthorizeLegacy(RoleAdmin)
+
+	router := gin.New()
+	router.Use(gin.LoggerWithFormatter(logFormatter), gin.Recovery())
+	router.GET("/general/version", viewer, w.getGeneralVersion)
+	router.GET("/metrics", viewer, w.getMetrics)
+	router.GET("/metrics/section", viewer, w.getMetricsSection)
+	router.GET("/metrics/sections", viewer, w.getMetricsSections)
+	router.GET("/metrics/history", viewer, w.getMetricsHistory)
+	router.GET("/state/keys", admin, w.getStateKeys)
+	router.GET("/state/key", admin, w.getStateKey)
+	router.PUT("/state/set/phase", operator, w.setPhaseState)
+
+	//router.PUT("/executor/radars/stop", putStopRadars)
+	//router.PUT("/executor/radars/start", putStartRadars)
+	//router.GET("/executor/radars/status", getRadarsStatus)
+
+	// WebSocket
+	router.GET("/socket", viewer, w.getSocket)
+
+	w.registerApiV1(router)
+	return router
+}
+
+// logFormatter is the gin log line, without the query string
+func logFormatter(param gin.LogFormatterParams) string {
+	path, _, _ := strings.Cut(param.Path, "?")
+
+	return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		param.StatusCode,
+		param.Latency,
+		param.ClientIP,
+		param.Method,
+		path,
+		param.ErrorMessage,
+	)
+}
+
 func (w *WebService) GetServiceName() string {
 	return WebServiceName
 }
@@ -109,6 +167,24 @@ func (w *WebService) getGeneralVersion(context *gin.Context) {
 	context.String(200, "3.0.0 - Build 125")
 }
 
+// getMetrics exposes the metrics to Prometheus, in the OpenMetrics format
+// when accepted by the scraper
+func (w *WebService) getMetrics(context *gin.Context) {
+	isOpenMetrics := strings.Contains(context.GetHeader("Accept"), "application/openmetrics-text")
+
+	if isOpenMetrics {
+		context.Header("Content-Type", utils.OpenMetricsContentType)
+	} else {
+		context.Header("Content-Type", utils.PrometheusContentType)
+	}
+	context.Status(http.StatusOK)
+
+	sections := utils.GlobalMetrics.Sections()
+	if err := utils.OpenMetrics.Write(context.Writer, sections, w.MetricsPrefix, isOpenMetrics); err != nil {
+		log.Err(err).Msg("WebService.getMetrics")
+	}
+}
+
 func (w *WebService) getMetricsSections(context *gin.Context) {
 	context.JSON(http.StatusOK, utils.GlobalMetrics.Names())
 }
@@ -133,6 +209,194 @@ func (w *WebService) getMetricsSection(context *gin.Context) {
 	context.JSON(http.StatusOK, result)
 }
 
+// getMetricsHistory returns the time series of the metric (mn) of the
+// section (sn) between from and to (unix milliseconds or RFC3339), by
+// default the last hour
+func (w *WebService) getMetricsHistory(context *gin.Context) {
+	history, ok := utils.GlobalState.Get(metrichistory.MetricHistoryServiceName).(*metrichistory.MetricHistoryService)
+	if !ok {
+		context.JSON(http.StatusNotFound, gin.H{"error": "metric history not enabled"})
+		return
+	}
+
+	sectionName := context.Query("sn")
+	metricName := context.Query("mn")
+
+	if len(sectionName) == 0 || len(metricName) == 0 {
+		context.JSON(http.StatusBadRequest, gin.H{"error": "sn and mn are required"})
+		return
+	}
+
+	var err error
+	to := time.Now()
+	from := to.Add(-time.Hour)
+
+	if value := context.Query("to"); len(value) > 0 {
+		if to, err = metrichistory.MetricHistoryDao.FromTimeStr(value); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		from = to.Add(-time.Hour)
+	}
+
+	if value := context.Query("from"); len(value) > 0 {
+		if from, err = metrichistory.MetricHistoryDao.FromTimeStr(value); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if from.After(to) {
+		context.JSON(http.StatusBadRequest, gin.H{"error": "from is after to"})
+		return
+	}
+
+	series, err := history.Series(sectionName, metricName, from, to)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	context.JSON(http.StatusOK, series)
+}
+
+// getStatistics returns the statistics bins of bin minutes between from and
+// to (unix milliseconds or RFC3339), by default the last day, optionally of
+// a radar, zone and class
+func (w *WebService) getStatistics(context *gin.Context) {
+	history, ok := utils.GlobalState.Get(statisticshistory.StatisticsHistoryServiceName).(*statisticshistory.StatisticsHistoryService)
+	if !ok {
+		context.JSON(http.StatusNotFound, gin.H{"error": "statistics history not enabled"})
+		return
+	}
+
+	var err error
+	query := statisticshistory.StatisticsQuery{
+		RadarIP:    context.Query("radar"),
+		BinMinutes: 15,
+		Zone:       -1,
+		Class:      context.Query("class"),
+	}
+
+	if value := context.Query("bin"); len(value) > 0 {
+		if query.BinMinutes, err = strconv.Atoi(value); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if value := context.Query("zone"); len(value) > 0 {
+		if query.Zone, err = strconv.Atoi(value); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	to := time.Now()
+	from := to.Add(-24 * time.Hour)
+
+	if value := context.Query("to"); len(value) > 0 {
+		if to, err = metrichistory.MetricHistoryDao.FromTimeStr(value); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		from = to.Add(-24 * time.Hour)
+	}
+
+	if value := context.Query("from"); len(value) > 0 {
+		if from, err = metrichistory.MetricHistoryDao.FromTimeStr(value); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	query.From = from.UnixMilli()
+	query.To = to.UnixMilli()
+
+	bins, err := history.Select(query)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	context.JSON(http.StatusOK, bins)
+}
+
+// getPVR returns the vehicle events between from and to (unix milliseconds
+// or RFC3339), by default the last hour, optionally of a radar, zone, class
+// and speed band
+func (w *WebService) getPVR(context *gin.Context) {
+	history, ok := utils.GlobalState.Get(pvrhistory.PVRHistoryServiceName).(*pvrhistory.PVRHistoryService)
+	if !ok {
+		context.JSON(http.StatusNotFound, gin.H{"error": "pvr history not enabled"})
+		return
+	}
+
+	var err error
+	query := pvrhistory.PVRQuery{
+		RadarIP: context.Query("radar"),
+		Zone:    -1,
+		Class:   context.Query("class"),
+	}
+
+	if value := context.Query("zone"); len(value) > 0 {
+		if query.Zone, err = strconv.Atoi(value); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if value := context.Query("minspeed"); len(value) > 0 {
+		if query.MinSpeed, err = strconv.ParseFloat(value, 64); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if value := context.Query("maxspeed"); len(value) > 0 {
+		if query.MaxSpeed, err = strconv.ParseFloat(value, 64); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if value := context.Query("limit"); len(value) > 0 {
+		if query.MaxRows, err = strconv.Atoi(value); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	to := time.Now()
+	from := to.Add(-time.Hour)
+
+	if value := context.Query("to"); len(value) > 0 {
+		if to, err = metrichistory.MetricHistoryDao.FromTimeStr(value); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		from = to.Add(-time.Hour)
+	}
+
+	if value := context.Query("from"); len(value) > 0 {
+		if from, err = metrichistory.MetricHistoryDao.FromTimeStr(value); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	query.From = from.UnixMilli()
+	query.To = to.UnixMilli()
+
+	events, err := history.Select(query)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	context.JSON(http.StatusOK, events)
+}
+
 func (w *WebService) getStateKey(context *gin.Context) {
 	id := context.Query("id")
 	result := utils.GlobalState.Get(id)
@@ -145,7 +409,12 @@ func (w *WebService) getStateKeys(context *gin.Context) {
 }
 
 func (w *WebService) getSocket(context *gin.Context) {
-	conn, err := upgrader.Upgrade(context.Writer, context.Request, nil)
+	if w.Sockets == nil {
+		context.JSON(http.StatusNotFound, gin.H{"error": "sockets not enabled"})
+		return
+	}
+
+	conn, err := w.upgrader.Upgrade(context.Writer, context.Request, nil)
 	if err != nil {
 		log.Err(err).Msg("WebSocket.ServeWebSocket")
 		return
@@ -153,13 +422,16 @@ func (w *WebService) getSocket(context *gin.Context) {
 
 	subscribeStr := context.Request.URL.Query().Get("subscribe")
 	subscriptions, _ := strconv.Atoi(subscribeStr)
+	radars := strings.FieldsFunc(context.Request.URL.Query().Get("radars"), func(r rune) bool {
+		return r == ';' || r == ','
+	})
 
 	client := &SocketClient{
-		service:       w.Sockets,
-		conn:          conn,
-		send:          make(chan *SocketPayload, 5),
-		Subscriptions: uint64(subscriptions),
+		service: w.Sockets,
+		conn:    conn,
+		send:    make(chan *SocketPayload, 32),
 	}
+	client.SetFilter(uint64(subscriptions), radars)
 	w.Sockets.register <- client
 
 	go client.readSocket()
@@ -188,9 +460,38 @@ func (w *WebService) IsAnySubscribed(mask uint64) bool {
 	return w.Sockets.IsAnySubscribed(mask)
 }
 
+// IsAnySubscribedTo returns whether any client subscribed to the mask for
+// the radar, the radar payloads are only decoded when true
+func (w *WebService) IsAnySubscribedTo(mask uint64, radar utils.IP4) bool {
+	if w.Sockets == nil {
+		return false
+	}
+	return w.Sockets.IsAnySubscribedTo(mask, radar)
+}
+
+// Stream broadcasts the message (JSON) to the clients subscribed to the
+// radar, the web service being the interfaces.IStreamSink of the brokers
+func (w *WebService) Stream(subscription uint64, radar utils.IP4, message any) error {
+	if w.Sockets == nil {
+		return nil
+	}
+
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	w.Sockets.Broadcast(&SocketPayload{
+		Subscription: subscription,
+		Radar:        radar,
+		Payload:      payload,
+	})
+	return nil
+}
+
 func (w *WebService) setPhaseState(context *gin.Context) {
-	phases := utils.GlobalState.Get(interfaces.PhaseStateName).(interfaces.IPhaseState)
-	if phases == nil {
+	phases, ok := utils.GlobalState.Get(interfaces.PhaseStateName).(interfaces.IPhaseState)
+	if !ok {
 		http.Error(context.Writer, "Phase state not found", http.StatusNotFound)
 		return
 	}
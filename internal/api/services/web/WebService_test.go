@@ -0,0 +1,38 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/internal/services/metrichistory"
+	"rvpro3/radarvision.com/utils"
+)
+
+func TestWebService_MetricsHistory(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := &WebService{}
+	router := gin.New()
+	router.GET("/metrics/history", w.getMetricsHistory)
+
+	serve := func(url string) *httptest.ResponseRecorder {
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, url, nil))
+		return response
+	}
+
+	t.Cleanup(func() {
+		utils.GlobalState.Set(metrichistory.MetricHistoryServiceName, nil)
+	})
+	utils.GlobalState.Set(metrichistory.MetricHistoryServiceName, new(metrichistory.MetricHistoryService))
+
+	response := serve("/metrics/history?sn=s&mn=m&from=1700000001000&to=1700000000000")
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	assert.JSONEq(t, `{"error":"from is after to"}`, response.Body.String())
+
+	// The history is not open
+	response = serve("/metrics/history?sn=s&mn=m&from=1700000000000&to=1700000000000")
+	assert.Equal(t, http.StatusInternalServerError, response.Code)
+}
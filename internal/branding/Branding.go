@@ -1,8 +1,6 @@
 package branding
 
 import (
-	"time"
-
 	"rvpro3/radarvision.com/utils"
 )
 
@@ -14,7 +12,7 @@ func (csvBranding) WriteTitle(writer *utils.CSVWriter, fileType string, fileVers
 	writer.WriteColsNL(fileType, fileVersion)
 	writer.WriteColsNL("Radar Vision", "https://radarvision.ai")
 	writer.WriteLn("======================================================")
-	writer.WriteColsNL("Recording start date:", time.Now().Format(utils.DisplayDateTimeZone))
+	writer.WriteColsNL("Recording start date:", utils.Time.Corrected().Format(utils.DisplayDateTimeZone))
 }
 
 func (csvBranding) WriteFeaturesNL(writer *utils.CSVWriter, data ...string) {
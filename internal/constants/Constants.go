@@ -3,3 +3,4 @@ package constants
 const UDPDataServiceName = "UDP.Data.Service"
 const RouterServerService = "Router.Server.Service"
 const AppInfoStateName = "App.Info"
+const ConfigReloadServiceName = "Config.Reload.Service"
@@ -0,0 +1,174 @@
+package detector
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"rvpro3/radarvision.com/internal/general"
+	"rvpro3/radarvision.com/internal/sdlc/uartsdlc"
+	"rvpro3/radarvision.com/internal/smartmicro/triggerpipeline"
+	"rvpro3/radarvision.com/utils"
+)
+
+const DetectorOutputServiceName = "Detector.Output.Service"
+const detectorOutputBackends = "detector.output.backends"
+const detectorOutputMode = "detector.output.mode"
+const detectorOutputCycle = "detector.output.cycle"
+const detectorOutputSource = "detector.output.source"
+const detectorOutputBIU = "detector.output.biu"
+const detectorOutputBIUFromStatus = "detector.output.biu.fromstatus"
+const detectorOutputMaxFailures = "detector.output.max.failures"
+const detectorOutputRetryEvery = "detector.output.retry.every"
+const detectorOutputWebSDLCBasePath = "detector.output.websdlc.basepath"
+const detectorOutputGPIOPorts = "detector.output.gpio.ports"
+
+var errUnknownBackend = errors.New("unknown detector output backend")
+
+// ITriggerSource executes the trigger pipelines (see broker.UDPBrokersService)
+type ITriggerSource interface {
+	ExecutePipelines(now time.Time, display triggerpipeline.ITriggerDisplay) utils.Uint128
+}
+
+// DetectorOutputService executes the trigger pipelines every cycle, and
+// writes the result onto the detector outputs.  The BIUs are either
+// configured or taken from the SDLC static status
+type DetectorOutputService struct {
+	Outputs         DetectorOutputs
+	Backends        []string
+	CycleDuration   utils.Milliseconds
+	SourceName      string
+	IsBIUFromStatus bool
+	WebSDLCBasePath string
+	GPIOPorts       string
+	Source          ITriggerSource                  `json:"-"`
+	Display         triggerpipeline.ITriggerDisplay `json:"-"`
+	Metronome       utils.Metronome
+	Terminate       bool
+	Terminated      bool
+	staticStatus    *uartsdlc.StaticStatus
+	Metrics         DetectorOutputServiceMetrics
+}
+
+type DetectorOutputServiceMetrics struct {
+	Cycles          *utils.Metric
+	NoSourceCount   *utils.Metric
+	BackendErrCount *utils.Metric
+	utils.MetricsInitMixin
+}
+
+func (s *DetectorOutputService) InitFromSettings(settings *utils.Settings) {
+	s.Backends = settings.Basic.GetArray(detectorOutputBackends, "sdlc")
+	s.Outputs.Mode = settings.Basic.Get(detectorOutputMode, OutputModeAll)
+	s.CycleDuration = settings.Basic.GetMilliseconds(detectorOutputCycle, 100)
+	s.SourceName = settings.Basic.Get(detectorOutputSource, "UDP.Brokers.Service")
+	s.Outputs.BIU = uartsdlc.BIUFlags(settings.Basic.GetInt(detectorOutputBIU, 0x0f))
+	s.IsBIUFromStatus = settings.Basic.GetBool(detectorOutputBIUFromStatus, true)
+	s.Outputs.MaxFailures = settings.Basic.GetInt(detectorOutputMaxFailures, 3)
+	s.Outputs.RetryEvery = settings.Basic.GetMilliseconds(detectorOutputRetryEvery, 10000)
+	s.WebSDLCBasePath = settings.Basic.Get(detectorOutputWebSDLCBasePath, "")
+	s.GPIOPorts = settings.Basic.Get(detectorOutputGPIOPorts, "")
+}
+
+func (s *DetectorOutputService) Start(state *utils.State, settings *utils.Settings) {
+	if !general.ServiceHelper.ShouldStart(state, settings, s) {
+		return
+	}
+
+	s.init()
+
+	if s.Source == nil {
+		s.Source, _ = state.Get(s.SourceName).(ITriggerSource)
+	}
+
+	if s.IsBIUFromStatus {
+		s.staticStatus, _ = state.Get(uartsdlc.SDLCStaticStatusStateName).(*uartsdlc.StaticStatus)
+	}
+
+	s.Outputs.Start()
+	go s.run()
+}
+
+func (s *DetectorOutputService) GetServiceName() string {
+	return DetectorOutputServiceName
+}
+
+func (s *DetectorOutputService) init() {
+	s.Metrics.InitMetrics(s.GetServiceName(), &s.Metrics)
+	s.Outputs.Init(s.GetServiceName() + ".Outputs")
+	s.Metronome.CycleDuration = time.Duration(s.CycleDuration)
+
+	if len(s.Outputs.Outputs) > 0 {
+		return
+	}
+
+	for _, backend := range s.Backends {
+		output, err := s.createOutput(strings.TrimSpace(backend))
+		if err != nil {
+			s.Metrics.BackendErrCount.Inc(1)
+			log.Err(err).Str("backend", backend).Msg("DetectorOutputService.init")
+			continue
+		}
+
+		if output != nil {
+			s.Outputs.Add(output)
+		}
+	}
+}
+
+func (s *DetectorOutputService) createOutput(backend string) (IDetectorOutput, error) {
+	switch strings.ToLower(backend) {
+	case "":
+		return nil, nil
+
+	case "sdlc":
+		return new(SDLCSerialOutput), nil
+
+	case "websdlc":
+		return &WebSDLCOutput{BasePath: s.WebSDLCBasePath}, nil
+
+	case "gpio":
+		ports, err := ParseGPIOPorts(s.GPIOPorts)
+		if err != nil {
+			return nil, err
+		}
+		return &GPIOOutput{Ports: ports}, nil
+	}
+
+	return nil, errUnknownBackend
+}
+
+func (s *DetectorOutputService) run() {
+	s.Metronome.Start()
+
+	for !s.Terminate {
+		s.Execute(time.Now())
+		s.Metronome.AwaitClick()
+	}
+
+	s.Outputs.Stop()
+	s.Terminated = true
+}
+
+// GetChannelCalls returns the channel calls (channel N = bit N-1) last
+// written onto the outputs
+func (s *DetectorOutputService) GetChannelCalls() utils.Uint128 {
+	return s.Outputs.GetCalls()
+}
+
+// Execute executes the pipelines and writes the result onto the outputs
+func (s *DetectorOutputService) Execute(now time.Time) {
+	s.Metrics.Cycles.IncAt(1, now)
+
+	if s.Source == nil {
+		s.Metrics.NoSourceCount.IncAt(1, now)
+		return
+	}
+
+	if s.staticStatus != nil && s.staticStatus.BIU != 0 {
+		s.Outputs.BIU = s.staticStatus.BIU
+	}
+
+	s.Outputs.Write(now, s.Source.ExecutePipelines(now, s.Display))
+}
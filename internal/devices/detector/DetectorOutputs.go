@@ -0,0 +1,330 @@
+package detector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"rvpro3/radarvision.com/internal/sdlc/uartsdlc"
+	"rvpro3/radarvision.com/utils"
+)
+
+// OutputModeAll writes the detects to every healthy output (redundant)
+const OutputModeAll = "all"
+
+// OutputModeFailover writes the detects to the first healthy output, in the
+// order the outputs were added
+const OutputModeFailover = "failover"
+
+// DetectorOutputs fans the pipeline result out onto the detector outputs.
+// Every output is written from its own goroutine, holding only the latest
+// detects, meaning a slow output (e.g. web SDLC) never delays the others.
+//
+// An output becomes unhealthy after MaxFailures consecutive failures, and is
+// probed every RetryEvery.  In failover mode a probed output that takes
+// precedence becomes active again once written successfully.
+//
+// The outputs carry the channels 1..64, a call of the channels 65..128 is
+// counted (HiChannelCount) and logged when the channels change, not written
+type DetectorOutputs struct {
+	Mode        string
+	BIU         uartsdlc.BIUFlags
+	MaxFailures int
+	RetryEvery  utils.Milliseconds
+	Outputs     []*DetectorOutput
+	ActiveIndex int
+	Calls       utils.Uint128
+	Metrics     DetectorOutputsMetrics
+	hiChannels  uint64
+	lock        sync.Mutex
+}
+
+type DetectorOutputsMetrics struct {
+	Writes         *utils.Metric
+	NoOutputCount  *utils.Metric
+	FailoverCount  *utils.Metric
+	HiChannelCount *utils.Metric
+	utils.MetricsInitMixin
+}
+
+// DetectorOutput is the output together with its health
+type DetectorOutput struct {
+	Output    IDetectorOutput `json:"-"`
+	Name      string
+	IsOpen    bool
+	IsHealthy bool
+	FailCount int
+	RetryOn   time.Time
+	LastError string
+	Metrics   DetectorOutputMetrics
+	pending   chan detectorWrite
+	done      chan bool
+}
+
+type DetectorOutputMetrics struct {
+	WriteCount     *utils.Metric
+	WriteErrCount  *utils.Metric
+	OpenErrCount   *utils.Metric
+	SkipCount      *utils.Metric
+	UnhealthyCount *utils.Metric
+	IsHealthy      *utils.Metric
+	utils.MetricsInitMixin
+}
+
+type detectorWrite struct {
+	now     time.Time
+	detects uint64
+	mask    uint64
+}
+
+func (d *DetectorOutputs) Init(metricsName string) {
+	d.Metrics.InitMetrics(metricsName, &d.Metrics)
+
+	if len(d.Mode) == 0 {
+		d.Mode = OutputModeAll
+	}
+
+	if d.MaxFailures < 1 {
+		d.MaxFailures = 1
+	}
+}
+
+// Add appends the output, the order determines the failover precedence
+func (d *DetectorOutputs) Add(output IDetectorOutput) *DetectorOutput {
+	res := &DetectorOutput{
+		Output:    output,
+		Name:      output.GetName(),
+		IsHealthy: true,
+	}
+	res.Metrics.InitMetrics("Detector.Output."+res.Name, &res.Metrics)
+
+	d.Outputs = append(d.Outputs, res)
+	return res
+}
+
+// Start opens the outputs and starts the output writers
+func (d *DetectorOutputs) Start() {
+	now := time.Now()
+
+	for _, output := range d.Outputs {
+		output.pending = make(chan detectorWrite, 1)
+		output.done = make(chan bool)
+		d.open(now, output)
+
+		go d.run(output, output.pending)
+	}
+}
+
+// Stop terminates the output writers and closes the outputs
+func (d *DetectorOutputs) Stop() {
+	stopped := make([]chan bool, 0, len(d.Outputs))
+
+	d.lock.Lock()
+	for _, output := range d.Outputs {
+		if output.pending != nil {
+			close(output.pending)
+			output.pending = nil
+			stopped = append(stopped, output.done)
+		}
+	}
+	d.lock.Unlock()
+
+	for _, done := range stopped {
+		<-done
+	}
+}
+
+// Write writes the pipeline result (channel N = bit N-1) onto the outputs
+func (d *DetectorOutputs) Write(now time.Time, result utils.Uint128) {
+	write := detectorWrite{
+		now:     now,
+		detects: result.Lo,
+		mask:    uint64(d.BIU.ToBIUMask()),
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.Calls = result
+	d.Metrics.Writes.IncAt(1, now)
+	d.checkHiChannels(now, result.Hi)
+	written := false
+
+	for index, output := range d.Outputs {
+		if !output.isAvailable(now) {
+			continue
+		}
+
+		// An unhealthy output is probed, without relying on it
+		if !output.IsHealthy {
+			output.RetryOn = now.Add(time.Duration(d.RetryEvery))
+			output.enqueue(now, write)
+			continue
+		}
+
+		output.enqueue(now, write)
+		written = true
+
+		if d.Mode == OutputModeFailover {
+			if d.ActiveIndex != index {
+				d.ActiveIndex = index
+				d.Metrics.FailoverCount.IncAt(1, now)
+				log.Warn().Str("output", output.Name).Msg("Detector output failover")
+			}
+			break
+		}
+	}
+
+	if !written {
+		d.Metrics.NoOutputCount.IncAt(1, now)
+	}
+}
+
+// checkHiChannels counts the writes calling channels beyond 64, which the
+// outputs do not carry
+func (d *DetectorOutputs) checkHiChannels(now time.Time, hi uint64) {
+	if hi != 0 {
+		d.Metrics.HiChannelCount.IncAt(1, now)
+	}
+
+	if hi != d.hiChannels && hi != 0 {
+		log.Warn().Uint64("channels65to128", hi).Msg("Detector outputs only carry the channels 1..64")
+	}
+	d.hiChannels = hi
+}
+
+// GetCalls returns the pipeline result last written
+func (d *DetectorOutputs) GetCalls() utils.Uint128 {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.Calls
+}
+
+// GetActive returns the output currently written in failover mode
+func (d *DetectorOutputs) GetActive() *DetectorOutput {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.ActiveIndex < len(d.Outputs) {
+		return d.Outputs[d.ActiveIndex]
+	}
+	return nil
+}
+
+// IsHealthy returns whether the output named is healthy
+func (d *DetectorOutputs) IsHealthy(name string) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, output := range d.Outputs {
+		if output.Name == name {
+			return output.IsHealthy
+		}
+	}
+	return false
+}
+
+func (d *DetectorOutputs) run(output *DetectorOutput, pending chan detectorWrite) {
+	for write := range pending {
+		d.write(output, write)
+	}
+
+	d.lock.Lock()
+	if output.IsOpen {
+		output.IsOpen = false
+		_ = output.Output.Close()
+	}
+	d.lock.Unlock()
+
+	close(output.done)
+}
+
+func (d *DetectorOutputs) open(now time.Time, output *DetectorOutput) bool {
+	if output.IsOpen {
+		return true
+	}
+
+	if err := output.Output.Open(); err != nil {
+		output.Metrics.OpenErrCount.IncAt(1, now)
+		d.onResult(now, output, err)
+		return false
+	}
+
+	output.IsOpen = true
+	return true
+}
+
+func (d *DetectorOutputs) write(output *DetectorOutput, write detectorWrite) {
+	d.lock.Lock()
+	isOpen := d.open(write.now, output)
+	d.lock.Unlock()
+
+	if !isOpen {
+		return
+	}
+
+	err := output.Output.Write(write.now, write.detects, write.mask)
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if err != nil {
+		output.Metrics.WriteErrCount.IncAt(1, write.now)
+	} else {
+		output.Metrics.WriteCount.IncAt(1, write.now)
+	}
+	d.onResult(write.now, output, err)
+}
+
+// onResult updates the health of the output, the lock must be held
+func (d *DetectorOutputs) onResult(now time.Time, output *DetectorOutput, err error) {
+	if err == nil {
+		output.FailCount = 0
+		output.LastError = ""
+
+		if !output.IsHealthy {
+			output.IsHealthy = true
+			log.Info().Str("output", output.Name).Msg("Detector output healthy")
+		}
+		output.Metrics.IsHealthy.SetAt(1, now)
+		return
+	}
+
+	output.FailCount++
+	output.LastError = err.Error()
+
+	if output.FailCount < d.MaxFailures {
+		return
+	}
+
+	// Unhealthy (again), postpone the next retry
+	output.RetryOn = now.Add(time.Duration(d.RetryEvery))
+
+	if output.IsHealthy {
+		output.IsHealthy = false
+		output.Metrics.UnhealthyCount.IncAt(1, now)
+		log.Err(err).Str("output", output.Name).Msg("Detector output unhealthy")
+	}
+	output.Metrics.IsHealthy.SetAt(0, now)
+}
+
+// isAvailable returns whether the output is healthy or due for a retry
+func (o *DetectorOutput) isAvailable(now time.Time) bool {
+	return o.IsHealthy || !now.Before(o.RetryOn)
+}
+
+// enqueue replaces the pending detects (if not yet written) with the latest
+func (o *DetectorOutput) enqueue(now time.Time, write detectorWrite) {
+	select {
+	case <-o.pending:
+		o.Metrics.SkipCount.IncAt(1, now)
+	default:
+	}
+
+	select {
+	case o.pending <- write:
+	default:
+		o.Metrics.SkipCount.IncAt(1, now)
+	}
+}
@@ -0,0 +1,203 @@
+package detector
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/internal/sdlc/sdlcsim"
+	"rvpro3/radarvision.com/internal/sdlc/uartsdlc"
+	"rvpro3/radarvision.com/utils"
+)
+
+var errTestOutput = errors.New("test output failure")
+
+type testOutput struct {
+	name    string
+	lock    sync.Mutex
+	isFail  bool
+	detects uint64
+	mask    uint64
+	writes  int
+}
+
+func (o *testOutput) GetName() string { return o.name }
+func (o *testOutput) Open() error     { return nil }
+func (o *testOutput) Close() error    { return nil }
+
+func (o *testOutput) Write(_ time.Time, detects uint64, mask uint64) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.isFail {
+		return errTestOutput
+	}
+	o.detects = detects
+	o.mask = mask
+	o.writes++
+	return nil
+}
+
+func (o *testOutput) setFail(isFail bool) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.isFail = isFail
+}
+
+func (o *testOutput) get() (uint64, uint64, int) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.detects, o.mask, o.writes
+}
+
+func awaitCondition(condition func() bool) bool {
+	for range 50 {
+		if condition() {
+			return true
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return false
+}
+
+// awaitWrite writes the detects and awaits the outputs processing it
+func awaitWrite(outputs *DetectorOutputs, now time.Time, detects uint64) {
+	outputs.Write(now, utils.Uint128{Lo: detects})
+
+	awaitCondition(func() bool {
+		outputs.lock.Lock()
+		defer outputs.lock.Unlock()
+
+		for _, output := range outputs.Outputs {
+			if len(output.pending) > 0 {
+				return false
+			}
+		}
+		return true
+	})
+	time.Sleep(20 * time.Millisecond)
+}
+
+func TestDetectorOutputs_All(t *testing.T) {
+	primary := &testOutput{name: "test.all.primary"}
+	secondary := &testOutput{name: "test.all.secondary"}
+
+	outputs := DetectorOutputs{Mode: OutputModeAll, BIU: 0x01}
+	outputs.Init("Test.Detector.Outputs.All")
+	outputs.Add(primary)
+	outputs.Add(secondary)
+	outputs.Start()
+	defer outputs.Stop()
+
+	awaitWrite(&outputs, time.Now(), 0x0005)
+
+	for _, output := range []*testOutput{primary, secondary} {
+		detects, mask, writes := output.get()
+		assert.Equal(t, uint64(0x0005), detects)
+		assert.Equal(t, uint64(0xFFFF000000000000), mask)
+		assert.Equal(t, 1, writes)
+	}
+	assert.Equal(t, int64(0), outputs.Metrics.HiChannelCount.Value)
+
+	// The channels beyond 64 are counted, not written
+	outputs.Write(time.Now(), utils.Uint128{Hi: 0x01, Lo: 0x0005})
+	assert.Equal(t, int64(1), outputs.Metrics.HiChannelCount.Value)
+}
+
+func TestDetectorOutputs_Failover(t *testing.T) {
+	primary := &testOutput{name: "test.failover.primary"}
+	secondary := &testOutput{name: "test.failover.secondary"}
+
+	outputs := DetectorOutputs{
+		Mode:        OutputModeFailover,
+		BIU:         0x0f,
+		MaxFailures: 2,
+		RetryEvery:  utils.Milliseconds(time.Second),
+	}
+	outputs.Init("Test.Detector.Outputs.Failover")
+	outputs.Add(primary)
+	outputs.Add(secondary)
+	outputs.Start()
+	defer outputs.Stop()
+
+	now := time.Now()
+	awaitWrite(&outputs, now, 0x01)
+	_, _, writes := secondary.get()
+	assert.Equal(t, 0, writes)
+
+	// The primary fails twice, after which the secondary takes over
+	primary.setFail(true)
+	awaitWrite(&outputs, now, 0x02)
+	awaitWrite(&outputs, now, 0x03)
+	assert.False(t, outputs.IsHealthy(primary.name))
+
+	awaitWrite(&outputs, now, 0x04)
+	detects, _, _ := secondary.get()
+	assert.Equal(t, uint64(0x04), detects)
+	assert.Equal(t, secondary.name, outputs.GetActive().Name)
+
+	// The primary is probed after the retry, and recovers
+	primary.setFail(false)
+	now = now.Add(2 * time.Second)
+	awaitWrite(&outputs, now, 0x05)
+	assert.True(t, outputs.IsHealthy(primary.name))
+	detects, _, _ = secondary.get()
+	assert.Equal(t, uint64(0x05), detects)
+
+	awaitWrite(&outputs, now, 0x06)
+	assert.Equal(t, primary.name, outputs.GetActive().Name)
+	detects, _, _ = primary.get()
+	assert.Equal(t, uint64(0x06), detects)
+}
+
+func TestDetectorOutputs_NoOutput(t *testing.T) {
+	primary := &testOutput{name: "test.none.primary", isFail: true}
+
+	outputs := DetectorOutputs{Mode: OutputModeFailover, RetryEvery: utils.Milliseconds(time.Minute)}
+	outputs.Init("Test.Detector.Outputs.None")
+	outputs.Add(primary)
+	outputs.Start()
+	defer outputs.Stop()
+
+	now := time.Now()
+	awaitWrite(&outputs, now, 0x01)
+	assert.False(t, outputs.IsHealthy(primary.name))
+
+	noOutputCount := outputs.Metrics.NoOutputCount.Value
+	awaitWrite(&outputs, now, 0x01)
+	assert.Equal(t, noOutputCount+1, outputs.Metrics.NoOutputCount.Value)
+}
+
+// TestSDLCSerialOutput writes the detects to the SDLC simulator
+func TestSDLCSerialOutput(t *testing.T) {
+	sim := sdlcsim.NewSDLCSimulator("Test.Detector.SDLC.Simulator")
+	utils.GlobalState.Delete(uartsdlc.SDLCServiceName)
+
+	settings := &utils.Settings{}
+	settings.Init()
+	settings.Basic.Set("feature.sdlc.uart.csv.enabled", "false")
+
+	sdlcService := new(uartsdlc.SDLCService)
+	sdlcService.InitFromSettings(settings)
+	sdlcService.Serial.OpenPort = sim.Open
+	sdlcService.Start(&utils.GlobalState, settings)
+
+	defer func() {
+		sdlcService.Stop()
+		sim.Stop()
+	}()
+
+	outputs := DetectorOutputs{Mode: OutputModeAll, BIU: 0x08}
+	outputs.Init("Test.Detector.Outputs.SDLC")
+	outputs.Add(new(SDLCSerialOutput))
+	outputs.Start()
+	defer outputs.Stop()
+
+	assert.True(t, awaitCondition(func() bool {
+		outputs.Write(time.Now(), utils.Uint128{Lo: 0xFFFF_0000_0000_0102})
+		detects, _ := sim.GetDetects()
+		return detects == 0x0102
+	}))
+}
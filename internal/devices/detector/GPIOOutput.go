@@ -0,0 +1,122 @@
+package detector
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"rvpro3/radarvision.com/utils/bit"
+	"rvpro3/radarvision.com/utils/device/gpio"
+)
+
+// GPIOLine is an output line, as returned by gpio.Chip.WriteToLine
+type GPIOLine interface {
+	SetValue(value int) error
+	Close() error
+}
+
+// GPIOOutput drives a GPIO port per detector, Ports maps the detector number
+// (1 based) onto the GPIO port.  A line is only written when its value changes
+type GPIOOutput struct {
+	Ports    map[int]int
+	OpenLine func(port int) (GPIOLine, error) `json:"-"`
+	chips    gpio.Chips
+	lines    map[int]GPIOLine
+	values   map[int]int
+}
+
+// ParseGPIOPorts parses the detector to GPIO port mapping, e.g. "1=117;2=118"
+func ParseGPIOPorts(value string) (map[int]int, error) {
+	res := make(map[int]int)
+
+	for _, pair := range strings.Split(value, ";") {
+		if len(strings.TrimSpace(pair)) == 0 {
+			continue
+		}
+
+		detectorStr, portStr, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, errors.Errorf("invalid gpio port mapping %q", pair)
+		}
+
+		detector, err := strconv.Atoi(strings.TrimSpace(detectorStr))
+		if err != nil || detector < 1 || detector > 64 {
+			return nil, errors.Errorf("invalid gpio detector %q", detectorStr)
+		}
+
+		port, err := strconv.Atoi(strings.TrimSpace(portStr))
+		if err != nil || port < 0 {
+			return nil, errors.Errorf("invalid gpio port %q", portStr)
+		}
+		res[detector] = port
+	}
+	return res, nil
+}
+
+func (o *GPIOOutput) GetName() string {
+	return "gpio"
+}
+
+func (o *GPIOOutput) Open() error {
+	if o.OpenLine == nil {
+		o.chips.Init()
+		o.OpenLine = o.openChipLine
+	}
+
+	o.lines = make(map[int]GPIOLine, len(o.Ports))
+	o.values = make(map[int]int, len(o.Ports))
+
+	for detector, port := range o.Ports {
+		line, err := o.OpenLine(port)
+		if err != nil {
+			_ = o.Close()
+			return errors.Wrapf(err, "gpio port %d", port)
+		}
+		o.lines[detector] = line
+	}
+	return nil
+}
+
+func (o *GPIOOutput) openChipLine(port int) (GPIOLine, error) {
+	chip, err := o.chips.OpenByPort(port)
+	if err != nil {
+		return nil, err
+	}
+	return chip.WriteToLine(gpio.Util.GetOffset(port), 0, 0)
+}
+
+func (o *GPIOOutput) Write(_ time.Time, detects uint64, mask uint64) error {
+	detects &= mask
+
+	for detector, line := range o.lines {
+		value := 0
+		if bit.IsSet(detects, detector-1) {
+			value = 1
+		}
+
+		if previous, ok := o.values[detector]; ok && previous == value {
+			continue
+		}
+
+		if err := line.SetValue(value); err != nil {
+			delete(o.values, detector)
+			return err
+		}
+		o.values[detector] = value
+	}
+	return nil
+}
+
+func (o *GPIOOutput) Close() error {
+	// The chips own (and close) the lines they opened
+	if o.chips.Chips != nil {
+		o.chips.Close()
+	} else {
+		for _, line := range o.lines {
+			_ = line.Close()
+		}
+	}
+	clear(o.lines)
+	return nil
+}
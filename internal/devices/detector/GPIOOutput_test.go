@@ -0,0 +1,55 @@
+package detector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testLine struct {
+	values []int
+}
+
+func (l *testLine) SetValue(value int) error {
+	l.values = append(l.values, value)
+	return nil
+}
+
+func (l *testLine) Close() error {
+	return nil
+}
+
+func TestParseGPIOPorts(t *testing.T) {
+	ports, err := ParseGPIOPorts("1=117; 2=118;")
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{1: 117, 2: 118}, ports)
+
+	_, err = ParseGPIOPorts("1:117")
+	assert.Error(t, err)
+
+	_, err = ParseGPIOPorts("65=117")
+	assert.Error(t, err)
+}
+
+func TestGPIOOutput_Write(t *testing.T) {
+	lines := make(map[int]*testLine)
+
+	output := GPIOOutput{
+		Ports: map[int]int{1: 117, 3: 118},
+		OpenLine: func(port int) (GPIOLine, error) {
+			lines[port] = new(testLine)
+			return lines[port], nil
+		},
+	}
+	assert.NoError(t, output.Open())
+
+	now := time.Now()
+	assert.NoError(t, output.Write(now, 0x05, 0xFFFF))
+	assert.NoError(t, output.Write(now, 0x01, 0xFFFF))
+	assert.NoError(t, output.Write(now, 0x01, 0xFFFE))
+
+	assert.Equal(t, []int{1, 0}, lines[117].values)
+	assert.Equal(t, []int{1, 0}, lines[118].values)
+	assert.NoError(t, output.Close())
+}
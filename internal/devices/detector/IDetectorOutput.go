@@ -0,0 +1,13 @@
+package detector
+
+import "time"
+
+// IDetectorOutput is a backend driving the cabinet detector inputs.  The
+// detects carry detector 1 in bit 0, the mask holds the detectors owned by
+// the enabled BIUs (see uartsdlc.BIUFlags.ToBIUMask)
+type IDetectorOutput interface {
+	GetName() string
+	Open() error
+	Write(now time.Time, detects uint64, mask uint64) error
+	Close() error
+}
@@ -0,0 +1,52 @@
+package detector
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"rvpro3/radarvision.com/internal/sdlc/uartsdlc"
+	"rvpro3/radarvision.com/utils"
+)
+
+var errSDLCServiceMissing = errors.New("sdlc service not started")
+var errSDLCNotConnected = errors.New("sdlc serial not connected")
+
+// SDLCSerialOutput sends the detects as TS2Detect frames over the SDLC
+// UART service
+type SDLCSerialOutput struct {
+	Service *uartsdlc.SDLCService
+	encoder uartsdlc.SDLCRequestEncoder
+}
+
+func (o *SDLCSerialOutput) GetName() string {
+	return "sdlc"
+}
+
+func (o *SDLCSerialOutput) Open() error {
+	if o.Service == nil {
+		o.Service, _ = utils.GlobalState.Get(uartsdlc.SDLCServiceName).(*uartsdlc.SDLCService)
+	}
+
+	if o.Service == nil {
+		return errSDLCServiceMissing
+	}
+	return nil
+}
+
+func (o *SDLCSerialOutput) Write(_ time.Time, detects uint64, mask uint64) error {
+	if !o.Service.Serial.IsConnected() {
+		return errSDLCNotConnected
+	}
+
+	data, err := o.encoder.TS2Detect(detects & mask)
+	if err != nil {
+		return err
+	}
+
+	o.Service.Write(data)
+	return nil
+}
+
+func (o *SDLCSerialOutput) Close() error {
+	return nil
+}
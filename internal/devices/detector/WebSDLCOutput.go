@@ -0,0 +1,39 @@
+package detector
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"rvpro3/radarvision.com/internal/sdlc/websdlc"
+)
+
+var errWebSDLCNoBasePath = errors.New("web sdlc base path not configured")
+
+// WebSDLCOutput sends the detects to the web SDLC interface (dets.cgi), the
+// detectors outside the mask are left untouched
+type WebSDLCOutput struct {
+	BasePath string
+}
+
+func (o *WebSDLCOutput) GetName() string {
+	return "websdlc"
+}
+
+func (o *WebSDLCOutput) Open() error {
+	if len(o.BasePath) == 0 {
+		return errWebSDLCNoBasePath
+	}
+	return nil
+}
+
+func (o *WebSDLCOutput) Write(_ time.Time, detects uint64, mask uint64) error {
+	api := websdlc.SDLCWebApi
+	api.BasePath = o.BasePath
+
+	_, err := api.SendTS2Detect(detects, mask)
+	return err
+}
+
+func (o *WebSDLCOutput) Close() error {
+	return nil
+}
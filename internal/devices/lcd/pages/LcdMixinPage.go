@@ -76,5 +76,5 @@ func (l *LcdMixinPage) getRouterClients() int {
 		}
 	}
 
-	return len(l.router.Server.Connections)
+	return l.router.Server.GetConnectionCount()
 }
@@ -1,11 +1,90 @@
 package servicemodel
 
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FailSafe values of the Channel, i.e. what happens to the channel when the
+// radar stops sending
+const (
+	FailSafeSet   = "set"
+	FailSafeClear = "clear"
+	FailSafeHold  = "hold"
+)
+
+// ChannelSource values of the Channel, i.e. what raises the channel call.
+// Empty is the radar's own relays
+const (
+	ChannelSourceTrigger    = "trigger"
+	ChannelSourceZone       = "zone"
+	ChannelSourceForceSet   = "forceSet"
+	ChannelSourceForceClear = "forceClear"
+)
+
+// Channel is a detector channel (1 based) assigned to a phase (1 based, 0
+// when not assigned).  The Delay, Extend and MaxHold are in seconds, with 0
+// disabling the detector feature
 type Channel struct {
 	Channel       int    `json:"Channel"`
 	Phase         int    `json:"Phase"`
+	Delay         string `json:"Delay,omitempty"`
 	MaxHold       string `json:"MaxHold"`
 	Extend        string `json:"Extend"`
 	FailSafe      string `json:"FailSafe"`
 	ChannelSource string `json:"ChannelSource"`
 	Zones         []Zone `json:"Zones"`
 }
+
+// IsValid returns true when the channel number fits the 128 channel pipeline
+func (c *Channel) IsValid() bool {
+	return c.Channel >= 1 && c.Channel <= 128
+}
+
+// GetIndex returns the (0 based) bit index of the channel
+func (c *Channel) GetIndex() int {
+	return c.Channel - 1
+}
+
+// GetPhaseIndex returns the (0 based) bit index of the phase, or -1
+func (c *Channel) GetPhaseIndex() int {
+	return c.Phase - 1
+}
+
+// IsZoneDetect returns true when the channel call is raised by its zones
+// (ChannelSource zone) instead of the radar's own relays
+func (c *Channel) IsZoneDetect() bool {
+	return c.IsValid() && strings.EqualFold(c.ChannelSource, ChannelSourceZone) && len(c.Zones) > 0
+}
+
+func (c *Channel) GetDelay() time.Duration {
+	return parseSeconds(c.Delay)
+}
+
+func (c *Channel) GetExtend() time.Duration {
+	return parseSeconds(c.Extend)
+}
+
+func (c *Channel) GetMaxHold() time.Duration {
+	return parseSeconds(c.MaxHold)
+}
+
+// GetFailSafe returns the lower case FailSafe, defaulting to FailSafeSet
+// which is the conventional detector failure mode (a constant call)
+func (c *Channel) GetFailSafe() string {
+	if c.FailSafe == "" {
+		return FailSafeSet
+	}
+	return strings.ToLower(c.FailSafe)
+}
+
+// parseSeconds returns 0 for an empty, invalid or negative value
+func parseSeconds(value string) time.Duration {
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
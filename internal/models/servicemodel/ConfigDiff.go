@@ -0,0 +1,109 @@
+package servicemodel
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// RadarChange is how a radar differs between two configurations
+type RadarChange int
+
+const (
+	RadarUnchanged RadarChange = iota
+	RadarAdded
+	RadarRemoved
+	RadarChanged
+)
+
+func (c RadarChange) String() string {
+	switch c {
+	case RadarAdded:
+		return "added"
+	case RadarRemoved:
+		return "removed"
+	case RadarChanged:
+		return "changed"
+	default:
+		return "unchanged"
+	}
+}
+
+func (c RadarChange) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// RadarDiff is the change of a radar, Previous is nil when added and Current
+// is nil when removed
+type RadarDiff struct {
+	RadarIP  string
+	Change   RadarChange
+	Previous *Radar `json:"-"`
+	Current  *Radar `json:"-"`
+}
+
+// IConfigReloader applies a new configuration to the running services,
+// returning the radars changed
+type IConfigReloader interface {
+	Reload(config *Config) ([]RadarDiff, error)
+}
+
+// Diff returns the changes of the radars compared to the previous
+// configuration, in the order of the radars (the removed radars last).  A
+// change of the units changes every radar, as the zones are in those units
+func (r *Config) Diff(previous *Config) []RadarDiff {
+	res := make([]RadarDiff, 0, len(r.Radars))
+	isUnitChanged := previous.DistanceUnit != r.DistanceUnit || previous.SpeedUnit != r.SpeedUnit
+
+	for _, radar := range r.Radars {
+		diff := RadarDiff{
+			RadarIP: radar.GetRadarIP().String(),
+			Change:  RadarAdded,
+			Current: radar,
+		}
+
+		if previousRadar := previous.GetRadarByIP(radar.GetRadarIP()); previousRadar != nil {
+			diff.Previous = previousRadar
+			diff.Change = RadarUnchanged
+
+			if isUnitChanged || !previousRadar.IsEqual(radar) {
+				diff.Change = RadarChanged
+			}
+		}
+		res = append(res, diff)
+	}
+
+	for _, previousRadar := range previous.Radars {
+		if r.GetRadarByIP(previousRadar.GetRadarIP()) == nil {
+			res = append(res, RadarDiff{
+				RadarIP:  previousRadar.GetRadarIP().String(),
+				Change:   RadarRemoved,
+				Previous: previousRadar,
+			})
+		}
+	}
+	return res
+}
+
+// IsChanged returns true when any radar is added, removed or changed
+func (r *Config) IsChanged(diffs []RadarDiff) bool {
+	for _, diff := range diffs {
+		if diff.Change != RadarUnchanged {
+			return true
+		}
+	}
+	return false
+}
+
+// IsEqual compares the radar configurations as saved (JSON)
+func (r *Radar) IsEqual(other *Radar) bool {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return false
+	}
+
+	otherData, err := json.Marshal(other)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(data, otherData)
+}
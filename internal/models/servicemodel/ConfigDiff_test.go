@@ -0,0 +1,40 @@
+package servicemodel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_Diff(t *testing.T) {
+	previous := TestBuilder.Build()
+	current := TestBuilder.Build()
+
+	current.Radars[0].Channels = []Channel{{Channel: 1, Phase: 2, Extend: "1.5"}}
+	current.Radars = append(current.Radars[:2], &Radar{RadarIP: "127.0.0.1:50005", RadarName: "Sim Sensor 5"})
+	current.Normalize()
+
+	diffs := current.Diff(previous)
+	changes := make(map[string]RadarChange, len(diffs))
+	for _, diff := range diffs {
+		changes[diff.RadarIP] = diff.Change
+	}
+
+	assert.True(t, current.IsChanged(diffs))
+	assert.Equal(t, map[string]RadarChange{
+		"127.0.0.1:50001": RadarChanged,
+		"127.0.0.1:50002": RadarUnchanged,
+		"127.0.0.1:50005": RadarAdded,
+		"127.0.0.1:50003": RadarRemoved,
+		"127.0.0.1:50004": RadarRemoved,
+	}, changes)
+	assert.Equal(t, RadarRemoved, diffs[len(diffs)-1].Change)
+	assert.False(t, previous.IsChanged(previous.Diff(TestBuilder.Build())))
+
+	// The zones are in the units, so every radar changes with the units
+	current = TestBuilder.Build()
+	current.SpeedUnit = "kph"
+	for _, diff := range current.Diff(previous) {
+		assert.Equal(t, RadarChanged, diff.Change, diff.RadarIP)
+	}
+}
@@ -0,0 +1,417 @@
+package servicemodel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Severity of a ValidationIssue, an error prevents the configuration from
+// being applied, a warning does not
+type Severity int
+
+const (
+	SeverityWarning Severity = iota
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// ValidationIssue is a problem of the configuration, the Path addresses the
+// value as in the JSON, e.g. Radars[0].Channels[1].MaxHold
+type ValidationIssue struct {
+	Path     string
+	Severity Severity
+	Message  string
+}
+
+func (i ValidationIssue) String() string {
+	return fmt.Sprintf("%s: %s", i.Path, i.Message)
+}
+
+// ValidationResult are the issues found, in the order of the configuration
+type ValidationResult struct {
+	Issues []ValidationIssue
+}
+
+func (r *ValidationResult) addError(path string, format string, args ...any) {
+	r.Issues = append(r.Issues, ValidationIssue{Path: path, Severity: SeverityError, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *ValidationResult) addWarning(path string, format string, args ...any) {
+	r.Issues = append(r.Issues, ValidationIssue{Path: path, Severity: SeverityWarning, Message: fmt.Sprintf(format, args...)})
+}
+
+// HasErrors returns true when the configuration should not be applied
+func (r *ValidationResult) HasErrors() bool {
+	return len(r.Errors()) > 0
+}
+
+func (r *ValidationResult) Errors() []ValidationIssue {
+	return r.filter(SeverityError)
+}
+
+func (r *ValidationResult) Warnings() []ValidationIssue {
+	return r.filter(SeverityWarning)
+}
+
+func (r *ValidationResult) filter(severity Severity) (res []ValidationIssue) {
+	for _, issue := range r.Issues {
+		if issue.Severity == severity {
+			res = append(res, issue)
+		}
+	}
+	return res
+}
+
+// Err returns the errors (not the warnings) as a single error, nil when none
+func (r *ValidationResult) Err() error {
+	issues := r.Errors()
+	if len(issues) == 0 {
+		return nil
+	}
+
+	messages := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		messages = append(messages, issue.String())
+	}
+	return errors.New(strings.Join(messages, "; "))
+}
+
+type configValidator struct {
+}
+
+// ConfigValidator checks the configuration beyond the JSON decoding, which
+// silently accepts e.g. unknown fields, duplicate radars or non-numeric
+// durations
+var ConfigValidator configValidator
+
+// Validate returns an error when the configuration cannot be applied, the
+// issues being available from ConfigValidator
+func (r *Config) Validate() error {
+	return ConfigValidator.Validate(r).Err()
+}
+
+// ValidateJSON decodes and validates the configuration (JSON), including the
+// fields unknown to the configuration (warnings).  The configuration is nil
+// when it cannot be decoded
+func (v configValidator) ValidateJSON(data []byte) (*Config, *ValidationResult) {
+	res := &ValidationResult{}
+	cfg := &Config{}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			res.addError(v.jsonErrorPath(typeErr.Field), "expected %s, found %s", typeErr.Type, typeErr.Value)
+		} else {
+			res.addError("", "invalid JSON: %s", err)
+		}
+		return nil, res
+	}
+
+	var values any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err == nil {
+		v.validateKeys(res, "", values, reflect.TypeOf(cfg).Elem())
+	}
+
+	cfg.Normalize()
+	res.Issues = append(res.Issues, v.Validate(cfg).Issues...)
+	return cfg, res
+}
+
+// jsonErrorPath returns the path of the decoding, Radars.0.RadarIP, as
+// Radars[0].RadarIP
+func (v configValidator) jsonErrorPath(field string) string {
+	var bld strings.Builder
+
+	for index, part := range strings.Split(field, ".") {
+		if _, err := strconv.Atoi(part); err == nil {
+			bld.WriteString("[" + part + "]")
+			continue
+		}
+
+		if index > 0 {
+			bld.WriteByte('.')
+		}
+		bld.WriteString(part)
+	}
+	return bld.String()
+}
+
+// Validate validates the (decoded and normalised) configuration
+func (v configValidator) Validate(cfg *Config) *ValidationResult {
+	res := &ValidationResult{}
+
+	if strings.TrimSpace(cfg.SiteName) == "" {
+		res.addWarning("SiteName", "empty")
+	}
+
+	v.validateUnit(res, "DistanceUnit", cfg.DistanceUnit, DistanceUnitMeter, DistanceUnitMeter, DistanceUnitFeet)
+	v.validateUnit(res, "SpeedUnit", cfg.SpeedUnit, SpeedUnitMps, SpeedUnitMps, SpeedUnitKph, "kmh", "km/h", SpeedUnitMph)
+
+	if len(cfg.Radars) == 0 {
+		res.addWarning("Radars", "no radars")
+	}
+
+	// The channels of the radars are combined, a channel of two radars is a
+	// call when either radar calls
+	channelRadars := make(map[int]int)
+
+	for index, radar := range cfg.Radars {
+		path := fmt.Sprintf("Radars[%d]", index)
+
+		if radar == nil {
+			res.addError(path, "missing")
+			continue
+		}
+		v.validateRadar(res, path, cfg, index, radar)
+
+		for channelIndex := range radar.Channels {
+			channel := radar.Channels[channelIndex].Channel
+			otherIndex, ok := channelRadars[channel]
+
+			if ok && otherIndex != index {
+				res.addWarning(
+					fmt.Sprintf("%s.Channels[%d].Channel", path, channelIndex),
+					"channel %d also used by Radars[%d]",
+					channel,
+					otherIndex,
+				)
+			} else if !ok {
+				channelRadars[channel] = index
+			}
+		}
+	}
+	return res
+}
+
+func (v configValidator) validateUnit(res *ValidationResult, path string, unit string, defaultUnit string, units ...string) {
+	if unit == "" {
+		res.addWarning(path, "empty, %s is used", defaultUnit)
+		return
+	}
+
+	if !slices.Contains(units, strings.ToLower(unit)) {
+		res.addError(path, "unknown unit %q, expected one of %s", unit, strings.Join(units, ", "))
+	}
+}
+
+func (v configValidator) validateRadar(res *ValidationResult, path string, cfg *Config, index int, radar *Radar) {
+	if radar.GetRadarIP().ToU32() == 0 {
+		res.addError(path+".RadarIP", "invalid RadarIP %q", radar.RadarIP)
+	} else {
+		for otherIndex, other := range cfg.Radars[:index] {
+			if other != nil && other.GetRadarIP().Equals(radar.GetRadarIP()) {
+				res.addError(path+".RadarIP", "duplicate RadarIP %q (Radars[%d])", radar.RadarIP, otherIndex)
+				break
+			}
+		}
+	}
+
+	if strings.TrimSpace(radar.RadarName) == "" {
+		res.addWarning(path+".RadarName", "empty")
+	}
+
+	v.validateNumber(res, path+".StopBarDistance", radar.StopBarDistance)
+	v.validateNumber(res, path+".FailSafeTime", radar.FailSafeTime)
+
+	for channelIndex := range radar.Channels {
+		channelPath := fmt.Sprintf("%s.Channels[%d]", path, channelIndex)
+		channel := &radar.Channels[channelIndex]
+		v.validateChannel(res, channelPath, channel)
+
+		for otherIndex := range radar.Channels[:channelIndex] {
+			if radar.Channels[otherIndex].Channel == channel.Channel {
+				res.addError(channelPath+".Channel", "overlaps Channels[%d], channel %d", otherIndex, channel.Channel)
+				break
+			}
+		}
+	}
+}
+
+func (v configValidator) validateChannel(res *ValidationResult, path string, channel *Channel) {
+	if !channel.IsValid() {
+		res.addError(path+".Channel", "invalid Channel %d, expected 1 to 128", channel.Channel)
+	}
+
+	if channel.Phase < 0 || channel.Phase > 64 {
+		res.addError(path+".Phase", "invalid Phase %d, expected 0 (none) to 64", channel.Phase)
+	}
+
+	v.validateNumber(res, path+".Delay", channel.Delay)
+	v.validateNumber(res, path+".MaxHold", channel.MaxHold)
+	v.validateNumber(res, path+".Extend", channel.Extend)
+
+	switch strings.ToLower(channel.FailSafe) {
+	case "", FailSafeSet, FailSafeClear, FailSafeHold:
+	default:
+		res.addError(path+".FailSafe", "unknown FailSafe %q, expected %s, %s or %s", channel.FailSafe, FailSafeSet, FailSafeClear, FailSafeHold)
+	}
+
+	switch strings.ToLower(channel.ChannelSource) {
+	case "", ChannelSourceTrigger, ChannelSourceZone, strings.ToLower(ChannelSourceForceSet), strings.ToLower(ChannelSourceForceClear):
+	default:
+		res.addWarning(
+			path+".ChannelSource",
+			"unknown ChannelSource %q, expected %s, %s, %s or %s",
+			channel.ChannelSource,
+			ChannelSourceTrigger,
+			ChannelSourceZone,
+			ChannelSourceForceSet,
+			ChannelSourceForceClear,
+		)
+	}
+
+	for zoneIndex := range channel.Zones {
+		v.validateZone(res, fmt.Sprintf("%s.Zones[%d]", path, zoneIndex), channel, zoneIndex)
+	}
+}
+
+func (v configValidator) validateZone(res *ValidationResult, path string, channel *Channel, zoneIndex int) {
+	zone := &channel.Zones[zoneIndex]
+
+	if zone.FromSpeed > zone.ToSpeed {
+		res.addError(path+".fromSpeed", "inverted speed range %g to %g", zone.FromSpeed, zone.ToSpeed)
+	}
+
+	if zone.FromDistance > zone.ToDistance {
+		res.addError(path+".fromDistance", "inverted distance range %g to %g", zone.FromDistance, zone.ToDistance)
+	}
+
+	if zone.FromDistance < 0 {
+		res.addError(path+".fromDistance", "negative distance %g", zone.FromDistance)
+	}
+
+	for laneIndex, lane := range zone.Lanes {
+		if lane < 0 {
+			res.addError(fmt.Sprintf("%s.lanes[%d]", path, laneIndex), "negative lane %d", lane)
+		}
+	}
+
+	for otherIndex := range channel.Zones[:zoneIndex] {
+		if channel.Zones[otherIndex].Zone == zone.Zone {
+			res.addWarning(path+".zone", "duplicate zone %d (Zones[%d])", zone.Zone, otherIndex)
+			break
+		}
+	}
+}
+
+// validateNumber checks the (seconds, distance) strings, empty being 0
+func (v configValidator) validateNumber(res *ValidationResult, path string, value string) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		res.addError(path, "not a number %q", value)
+		return
+	}
+
+	if number < 0 {
+		res.addError(path, "negative %q", value)
+	}
+}
+
+// validateKeys warns about the JSON fields unknown to the configuration,
+// typically a misspelling leaving the setting at its default
+func (v configValidator) validateKeys(res *ValidationResult, path string, value any, typ reflect.Type) {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	switch typ.Kind() {
+	case reflect.Struct:
+		values, ok := value.(map[string]any)
+		if !ok {
+			return
+		}
+
+		fields := v.jsonFields(typ)
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+
+			fieldType, ok := v.jsonField(fields, key)
+			if !ok {
+				res.addWarning(keyPath, "unknown field")
+				continue
+			}
+			v.validateKeys(res, keyPath, values[key], fieldType)
+		}
+
+	case reflect.Slice:
+		values, ok := value.([]any)
+		if !ok {
+			return
+		}
+
+		for index, item := range values {
+			v.validateKeys(res, fmt.Sprintf("%s[%d]", path, index), item, typ.Elem())
+		}
+	}
+}
+
+// jsonField returns the field type of the key, matched like the decoding
+// does, the exact name first otherwise case insensitive
+func (v configValidator) jsonField(fields map[string]reflect.Type, key string) (reflect.Type, bool) {
+	if res, ok := fields[key]; ok {
+		return res, true
+	}
+
+	for name, res := range fields {
+		if strings.EqualFold(name, key) {
+			return res, true
+		}
+	}
+	return nil, false
+}
+
+// jsonFields returns the field types by JSON name
+func (v configValidator) jsonFields(typ reflect.Type) map[string]reflect.Type {
+	res := make(map[string]reflect.Type, typ.NumField())
+
+	for index := 0; index < typ.NumField(); index++ {
+		field := typ.Field(index)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		res[name] = field.Type
+	}
+	return res
+}
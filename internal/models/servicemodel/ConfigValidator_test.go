@@ -0,0 +1,97 @@
+package servicemodel
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func issueMap(result *ValidationResult) map[string]Severity {
+	res := make(map[string]Severity, len(result.Issues))
+	for _, issue := range result.Issues {
+		res[issue.Path] = issue.Severity
+	}
+	return res
+}
+
+func TestConfig_Validate(t *testing.T) {
+	cfg := TestBuilder.Build()
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Radars[1].RadarIP = cfg.Radars[0].RadarIP
+	cfg.Normalize()
+	assert.ErrorContains(t, cfg.Validate(), `Radars[1].RadarIP: duplicate RadarIP "127.0.0.1:50001" (Radars[0])`)
+
+	cfg = TestBuilder.Build()
+	cfg.Radars[2].Channels = []Channel{{Channel: 1}, {Channel: 129}}
+	assert.ErrorContains(t, cfg.Validate(), "Radars[2].Channels[1].Channel: invalid Channel 129")
+
+	cfg = TestBuilder.Build()
+	cfg.Radars[3].RadarIP = "sensor"
+	cfg.Normalize()
+	assert.ErrorContains(t, cfg.Validate(), `Radars[3].RadarIP: invalid RadarIP "sensor"`)
+}
+
+func TestConfigValidator_Validate(t *testing.T) {
+	cfg := TestBuilder.Build()
+	cfg.SiteName = ""
+	cfg.SpeedUnit = "knots"
+	cfg.Radars[0].FailSafeTime = "thirty"
+	cfg.Radars[0].Channels = []Channel{
+		{Channel: 1, Phase: 2, MaxHold: "abc", Extend: "-1", FailSafe: "Hold", ChannelSource: "forceSet"},
+		{Channel: 1, Phase: 65, FailSafe: "off", ChannelSource: "radar", Zones: []Zone{
+			{Zone: 1, FromSpeed: 20, ToSpeed: 10},
+			{Zone: 1, FromDistance: 30, ToDistance: 10, Lanes: []int{-1}},
+		}},
+	}
+	cfg.Radars[1].Channels = []Channel{{Channel: 1}}
+
+	result := ConfigValidator.Validate(cfg)
+	assert.True(t, result.HasErrors())
+	assert.Equal(t, map[string]Severity{
+		"SiteName":                                    SeverityWarning,
+		"SpeedUnit":                                   SeverityError,
+		"Radars[0].FailSafeTime":                      SeverityError,
+		"Radars[0].Channels[0].MaxHold":               SeverityError,
+		"Radars[0].Channels[0].Extend":                SeverityError,
+		"Radars[0].Channels[1].Phase":                 SeverityError,
+		"Radars[0].Channels[1].FailSafe":              SeverityError,
+		"Radars[0].Channels[1].ChannelSource":         SeverityWarning,
+		"Radars[0].Channels[1].Zones[0].fromSpeed":    SeverityError,
+		"Radars[0].Channels[1].Zones[1].fromDistance": SeverityError,
+		"Radars[0].Channels[1].Zones[1].lanes[0]":     SeverityError,
+		"Radars[0].Channels[1].Zones[1].zone":         SeverityWarning,
+		"Radars[0].Channels[1].Channel":               SeverityError,
+		"Radars[1].Channels[0].Channel":               SeverityWarning,
+	}, issueMap(result))
+	assert.Equal(t, len(result.Issues), len(result.Errors())+len(result.Warnings()))
+}
+
+func TestConfigValidator_ValidateJSON(t *testing.T) {
+	data, err := os.ReadFile("config.json")
+	assert.NoError(t, err)
+
+	cfg, result := ConfigValidator.ValidateJSON(data)
+	assert.NotNil(t, cfg)
+	assert.False(t, result.HasErrors(), result.Err())
+
+	// The misspelled field is left at its default, the key case is ignored
+	cfg, result = ConfigValidator.ValidateJSON([]byte(`{
+		"SiteName": "Site", "distanceunit": "m", "SpeedUnit": "mps",
+		"Radars": [{"RadarIP": "192.168.11.12", "RadarName": "Radar 1",
+			"Channels": [{"Channel": 1, "MaxHld": "10", "Zones": [{"zone": 1, "speed": 3}]}]}]
+	}`))
+	assert.NotNil(t, cfg)
+	assert.Equal(t, map[string]Severity{
+		"Radars[0].Channels[0].MaxHld":         SeverityWarning,
+		"Radars[0].Channels[0].Zones[0].speed": SeverityWarning,
+	}, issueMap(result))
+
+	cfg, result = ConfigValidator.ValidateJSON([]byte(`{"Radars": [{"RadarIP": 12}]}`))
+	assert.Nil(t, cfg)
+	assert.Equal(t, map[string]Severity{"Radars[0].RadarIP": SeverityError}, issueMap(result))
+
+	_, result = ConfigValidator.ValidateJSON([]byte(`{"Radars": [`))
+	assert.True(t, result.HasErrors())
+}
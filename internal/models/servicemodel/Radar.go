@@ -1,6 +1,8 @@
 package servicemodel
 
 import (
+	"time"
+
 	"rvpro3/radarvision.com/utils"
 )
 
@@ -23,3 +25,9 @@ func (r *Radar) Normalize() {
 func (r *Radar) GetRadarIP() utils.IP4 {
 	return r.radarIP
 }
+
+// GetFailSafeTime returns the time without radar activity before the
+// channels fail safe, 0 fails safe as soon as the radar is silent
+func (r *Radar) GetFailSafeTime() time.Duration {
+	return parseSeconds(r.FailSafeTime)
+}
@@ -0,0 +1,42 @@
+package servicemodel
+
+import "strings"
+
+// Distance units, the radar reports in meters
+const (
+	DistanceUnitMeter = "m"
+	DistanceUnitFeet  = "ft"
+)
+
+// Speed units, the radar reports in meters per second
+const (
+	SpeedUnitMps = "mps"
+	SpeedUnitKph = "kph"
+	SpeedUnitMph = "mph"
+)
+
+const metersToFeet = 3.280839895
+const mpsToKph = 3.6
+const mpsToMph = 2.236936292
+
+// GetDistanceFactor returns the factor converting meters to the DistanceUnit
+func (r *Config) GetDistanceFactor() float64 {
+	switch strings.ToLower(r.DistanceUnit) {
+	case DistanceUnitFeet:
+		return metersToFeet
+	default:
+		return 1
+	}
+}
+
+// GetSpeedFactor returns the factor converting meters per second to the SpeedUnit
+func (r *Config) GetSpeedFactor() float64 {
+	switch strings.ToLower(r.SpeedUnit) {
+	case SpeedUnitKph, "kmh", "km/h":
+		return mpsToKph
+	case SpeedUnitMph:
+		return mpsToMph
+	default:
+		return 1
+	}
+}
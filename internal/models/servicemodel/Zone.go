@@ -1,5 +1,13 @@
 package servicemodel
 
+import (
+	"slices"
+)
+
+// Zone is expressed in the Config DistanceUnit and SpeedUnit.  A range where
+// both the from and to is 0 does not filter, likewise empty Lanes or Classes.
+// The speed is signed as reported by the radar, i.e. the range selects the
+// direction of travel, a negative range matches the opposite direction
 type Zone struct {
 	Zone         int     `json:"zone"`
 	FromSpeed    float64 `json:"fromSpeed"`
@@ -9,3 +17,32 @@ type Zone struct {
 	Lanes        []int   `json:"lanes"`
 	Classes      []int   `json:"classes"`
 }
+
+// IsMatch returns true if the object (speed and distance in the configured
+// units) falls within the zone
+func (z *Zone) IsMatch(speed float64, distance float64, lane int, class int) bool {
+	if !isInRange(speed, z.FromSpeed, z.ToSpeed) {
+		return false
+	}
+
+	if !isInRange(distance, z.FromDistance, z.ToDistance) {
+		return false
+	}
+
+	if len(z.Lanes) > 0 && !slices.Contains(z.Lanes, lane) {
+		return false
+	}
+
+	if len(z.Classes) > 0 && !slices.Contains(z.Classes, class) {
+		return false
+	}
+
+	return true
+}
+
+func isInRange(value float64, from float64, to float64) bool {
+	if from == 0 && to == 0 {
+		return true
+	}
+	return value >= from && value <= to
+}
@@ -0,0 +1,39 @@
+package servicemodel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZone_IsMatch(t *testing.T) {
+	zone := Zone{
+		FromSpeed:    5,
+		ToSpeed:      30,
+		FromDistance: 10,
+		ToDistance:   50,
+		Lanes:        []int{1, 2},
+	}
+
+	assert.True(t, zone.IsMatch(10, 20, 1, 5))
+	assert.False(t, zone.IsMatch(40, 20, 1, 5))
+	assert.False(t, zone.IsMatch(10, 60, 1, 5))
+	assert.False(t, zone.IsMatch(10, 20, 3, 5))
+
+	zone.Classes = []int{2}
+	assert.False(t, zone.IsMatch(10, 20, 1, 5))
+	assert.True(t, zone.IsMatch(10, 20, 1, 2))
+
+	// An empty zone matches anything
+	assert.True(t, (&Zone{}).IsMatch(100, 1000, 7, 9))
+}
+
+func TestConfig_UnitFactors(t *testing.T) {
+	cfg := Config{DistanceUnit: "ft", SpeedUnit: "MPH"}
+	assert.InDelta(t, 3.28, cfg.GetDistanceFactor(), 0.01)
+	assert.InDelta(t, 2.24, cfg.GetSpeedFactor(), 0.01)
+
+	cfg = Config{DistanceUnit: "m", SpeedUnit: "kph"}
+	assert.Equal(t, 1.0, cfg.GetDistanceFactor())
+	assert.Equal(t, 3.6, cfg.GetSpeedFactor())
+}
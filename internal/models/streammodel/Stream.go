@@ -0,0 +1,158 @@
+package streammodel
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"rvpro3/radarvision.com/utils"
+)
+
+// The subscriptions (bit flags) of a socket client
+const (
+	SubscriptionTime          uint64 = 1
+	SubscriptionObjectList    uint64 = 2
+	SubscriptionPVR           uint64 = 4
+	SubscriptionStatistics    uint64 = 8
+	SubscriptionTrigger       uint64 = 16
+	SubscriptionChannelStatus uint64 = 32
+)
+
+// The types of the radar stream messages
+const (
+	ObjectListType    = "object-list-stream"
+	PVRType           = "pvr-stream"
+	StatisticsType    = "statistics-stream"
+	TriggerType       = "trigger-stream"
+	ChannelStatusType = "channel-status-stream"
+)
+
+// RadarMessage is the header of the radar stream messages, On is in
+// unix milliseconds
+type RadarMessage struct {
+	Type  string
+	Radar string
+	On    int64
+}
+
+func NewRadarMessage(msgType string, radar utils.IP4, on time.Time) RadarMessage {
+	return RadarMessage{
+		Type:  msgType,
+		Radar: radar.ToIPString(),
+		On:    utils.Time.Correct(on).UnixMilli(),
+	}
+}
+
+// ObjectListMessage are the objects tracked by the radar
+type ObjectListMessage struct {
+	RadarMessage
+	Objects []Object
+}
+
+// Object is sent as [id, class, x, y, speed, heading, length]
+type Object struct {
+	Id      int
+	Class   string
+	X       float32
+	Y       float32
+	Speed   float32
+	Heading float32
+	Length  float32
+}
+
+func (o Object) MarshalJSON() ([]byte, error) {
+	res := make([]byte, 0, 64)
+	res = append(res, '[')
+	res = strconv.AppendInt(res, int64(o.Id), 10)
+	res = append(res, ',')
+	res = strconv.AppendQuote(res, o.Class)
+	res = appendFloats(res, o.X, o.Y, o.Speed, o.Heading, o.Length)
+	return append(res, ']'), nil
+}
+
+// PVRMessage are the per vehicle records of the radar
+type PVRMessage struct {
+	RadarMessage
+	Objects []PVRObject
+}
+
+// PVRObject is sent as [id, class, zone, speed, heading, length]
+type PVRObject struct {
+	Id      int
+	Class   string
+	Zone    int
+	Speed   float32
+	Heading float32
+	Length  float32
+}
+
+func (o PVRObject) MarshalJSON() ([]byte, error) {
+	res := make([]byte, 0, 64)
+	res = append(res, '[')
+	res = strconv.AppendInt(res, int64(o.Id), 10)
+	res = append(res, ',')
+	res = strconv.AppendQuote(res, o.Class)
+	res = append(res, ',')
+	res = strconv.AppendInt(res, int64(o.Zone), 10)
+	res = appendFloats(res, o.Speed, o.Heading, o.Length)
+	return append(res, ']'), nil
+}
+
+// StatisticsMessage are the statistics of an interval of the radar,
+// the interval as reported by the radar
+type StatisticsMessage struct {
+	RadarMessage
+	Interval   int
+	Statistics []Statistic
+}
+
+// Statistic is sent as [zone, class, mode, output]
+type Statistic struct {
+	Zone   int
+	Class  string
+	Mode   string
+	Output int
+}
+
+func (s Statistic) MarshalJSON() ([]byte, error) {
+	res := make([]byte, 0, 48)
+	res = append(res, '[')
+	res = strconv.AppendInt(res, int64(s.Zone), 10)
+	res = append(res, ',')
+	res = strconv.AppendQuote(res, s.Class)
+	res = append(res, ',')
+	res = strconv.AppendQuote(res, s.Mode)
+	res = append(res, ',')
+	res = strconv.AppendInt(res, int64(s.Output), 10)
+	return append(res, ']'), nil
+}
+
+// TriggerMessage are the relays triggered by the radar (hex)
+type TriggerMessage struct {
+	RadarMessage
+	Relays string
+}
+
+// ChannelStatusMessage are the channel calls of the radar after the
+// trigger pipeline, and whether the radar is in failsafe
+type ChannelStatusMessage struct {
+	RadarMessage
+	Calls            utils.Uint128
+	IsAutoFailSafe   bool
+	IsManualFailSafe bool
+}
+
+// appendFloats appends the values rounded to 2 decimals, JSON having
+// no NaN or infinity they are sent as 0
+func appendFloats(res []byte, values ...float32) []byte {
+	for _, value := range values {
+		rounded := math.Round(float64(value)*100) / 100
+		if math.IsNaN(rounded) || math.IsInf(rounded, 0) {
+			rounded = 0
+		}
+
+		res = append(res, ',')
+		res = strconv.AppendFloat(res, rounded, 'f', -1, 64)
+	}
+	return res
+}
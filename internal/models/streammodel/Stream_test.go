@@ -0,0 +1,47 @@
+package streammodel
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/utils"
+)
+
+func TestStream_Messages(t *testing.T) {
+	radar := utils.IP4Builder.FromString("192.168.11.12:55555")
+	on := time.UnixMilli(1700000000123)
+
+	objects := ObjectListMessage{
+		RadarMessage: NewRadarMessage(ObjectListType, radar, on),
+		Objects: []Object{
+			{Id: 7, Class: "Car", X: 12.345, Y: -1.5, Speed: 13.899, Heading: float32(math.NaN()), Length: 4},
+		},
+	}
+
+	data, err := json.Marshal(&objects)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"Type": "object-list-stream",
+		"Radar": "192.168.11.12",
+		"On": `+itoa(utils.Time.Correct(on).UnixMilli())+`,
+		"Objects": [[7, "Car", 12.35, -1.5, 13.9, 0, 4]]
+	}`, string(data))
+
+	statistics := StatisticsMessage{
+		RadarMessage: NewRadarMessage(StatisticsType, radar, on),
+		Interval:     60,
+		Statistics:   []Statistic{{Zone: 1, Class: "Truck", Mode: "Volume", Output: 12}},
+	}
+
+	data, err = json.Marshal(&statistics)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"Interval":60,"Statistics":[[1,"Truck","Volume",12]]`)
+}
+
+func itoa(value int64) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
@@ -12,21 +12,35 @@ import (
 
 const routerClient = "Router.RouterClient"
 
+const routerClientMulticastEnabled = "router.client.multicast.enabled"
+const routerClientMulticastIPAddress = "router.client.multicast.ip.address"
+const routerClientMulticastInterface = "router.client.multicast.interface"
+const routerClientMulticastLoopback = "router.client.multicast.loopback"
+const routerClientMulticastLoopWindow = "router.client.multicast.loop.window"
+
 // RouterClient connects to HubServer.  It takes HubServer TCP traffic, interprets it
 // and forwards it to the local machine mimicking the UDP data like if it was a radar
 // by hosting a UDP server at the address.
-// TODO: Propagate Radar Multicast as Multicast via the RouterClient!
+// The Subscription (all radars when empty) is sent as the handshake on each
+// connect.
+// The radar multicast is emitted as multicast on the local interface (from the
+// radar address when hosted), and the local multicast (the keep-alives of the
+// radar tools) is sent to the server, never the radar announcements read back
 type RouterClient struct {
-	RemoteAddr   utils.IP4
-	packetQueue  utils.QueueBuffer
-	connection   utils.TCPClientConnection
-	writePool    sync.Pool
-	writeChannel chan []byte
-	doneChannel  chan bool
-	Terminate    bool
-	refCount     atomic.Int32
-	Radars       map[string]*RouterClientRadar
-	Metrics      ClientMetrics
+	RemoteAddr         utils.IP4
+	Subscription       tcphub.Subscription
+	Subscribed         tcphub.Subscription
+	IsMulticastEnabled bool
+	Multicast          tcphub.MulticastRelay
+	packetQueue        utils.QueueBuffer
+	connection         utils.TCPClientConnection
+	writePool          sync.Pool
+	writeChannel       chan []byte
+	doneChannel        chan bool
+	Terminate          bool
+	refCount           atomic.Int32
+	Radars             map[string]*RouterClientRadar
+	Metrics            ClientMetrics
 }
 
 type ClientMetrics struct {
@@ -55,9 +69,23 @@ type ClientMetrics struct {
 	ReadStarvedIterations     *utils.Metric
 	ReadPopErrors             *utils.Metric
 	ReadEmptyIterations       *utils.Metric
+	SubscribeIterations       *utils.Metric
+	SubscribeErrors           *utils.Metric
+	MulticastEmitOKs          *utils.Metric
+	MulticastEmitErrors       *utils.Metric
+	MulticastSkipped          *utils.Metric
+	MulticastForwards         *utils.Metric
 	utils.MetricsInitMixin
 }
 
+func (h *RouterClient) InitFromSettings(settings *utils.Settings) {
+	h.IsMulticastEnabled = settings.Basic.GetBool(routerClientMulticastEnabled, true)
+	h.Multicast.GroupAddr = settings.Basic.GetIP4(routerClientMulticastIPAddress, utils.IP4Builder.FromString("239.144.0.0:60000"))
+	h.Multicast.InterfaceName = settings.Basic.Get(routerClientMulticastInterface, "")
+	h.Multicast.IsLoopback = settings.Basic.GetBool(routerClientMulticastLoopback, true)
+	h.Multicast.LoopWindow = settings.Basic.GetMilliseconds(routerClientMulticastLoopWindow, 2000)
+}
+
 func (h *RouterClient) Start(remoteAddr utils.IP4) {
 	h.writePool = sync.Pool{
 		New: func() interface{} {
@@ -77,6 +105,12 @@ func (h *RouterClient) Start(remoteAddr utils.IP4) {
 	h.connection.OnConnect = h.onTCPOpen
 	h.connection.OnDisconnect = h.onTCPClose
 
+	if h.IsMulticastEnabled {
+		h.Multicast.Init(routerClient + ".Multicast")
+		h.Multicast.OnRead = h.onMulticastFromDesktop
+		h.Multicast.Listen()
+	}
+
 	go h.executeRead()
 	go h.executeWrite()
 }
@@ -87,6 +121,10 @@ func (h *RouterClient) Stop() {
 	}
 	h.Terminate = true
 
+	if h.IsMulticastEnabled {
+		h.Multicast.Stop()
+	}
+
 }
 
 func (h *RouterClient) StopAndJoin() {
@@ -136,7 +174,16 @@ func (h *RouterClient) executeRead() {
 					ip4 := hubPacket.GetSourceIP4()
 					ip4Str := ip4.String()
 
-					if ip4Str != "192.168.11.2:55555" {
+					switch {
+					case hubPacket.GetPacketType() == tcphub.PtSubscribe:
+						// The server answers the handshake with the
+						// subscription applied
+						h.onSubscribed(hubPacket.GetData(), now)
+
+					case hubPacket.GetPacketType() == tcphub.PtRadarMulticast:
+						h.onRadarMulticast(ip4, hubPacket.GetData(), now)
+
+					case ip4Str != "192.168.11.2:55555":
 						// Add radar if it does not exist
 						if _, ok := h.Radars[ip4Str]; !ok {
 							fmt.Println("Registering", ip4Str)
@@ -150,12 +197,12 @@ func (h *RouterClient) executeRead() {
 						radar := h.Radars[ip4Str]
 						//fmt.Println("Writing Packet from", hubPacket.GetSourceIP4(), "to", hubPacket.GetTargetIP4(), "bytes", len(hubPacket.GetData()))
 						radar.Write(hubPacket.GetPacket())
+					}
 
-						if err := h.packetQueue.PopSize(hubPacket.GetPacketSize()); err != nil {
-							h.Metrics.ReadPopErrors.IncAt(1, now)
-							h.packetQueue.Reset()
-							break
-						}
+					if err := h.packetQueue.PopSize(hubPacket.GetPacketSize()); err != nil {
+						h.Metrics.ReadPopErrors.IncAt(1, now)
+						h.packetQueue.Reset()
+						break
 					}
 
 					hubPacket.Buffer = h.packetQueue.GetDataSlice()
@@ -245,12 +292,73 @@ func (h *RouterClient) onRawDataFromDesktop(v *RouterClientRadar, addr utils.IP4
 	h.Write(pw.GetPacket())
 }
 
+// onRadarMulticast emits the radar announcement on the local interface, from
+// the radar address (ip and port) when hosted by the machine
+func (h *RouterClient) onRadarMulticast(radar utils.IP4, data []byte, now time.Time) {
+	if !h.IsMulticastEnabled {
+		h.Metrics.MulticastSkipped.IncAt(1, now)
+		return
+	}
+
+	if err := h.Multicast.Emit(radar, data, now); err != nil {
+		h.Metrics.MulticastEmitErrors.IncAt(1, now)
+	} else {
+		h.Metrics.MulticastEmitOKs.IncAt(1, now)
+	}
+}
+
+// onMulticastFromDesktop sends the local multicast (the keep-alives of the
+// radar tools) to the server, to be emitted on the radar network.  The
+// announcements (from the multicast port) are those of the radars, never sent
+// back
+func (h *RouterClient) onMulticastFromDesktop(relay *tcphub.MulticastRelay, addr utils.IP4, bytes []byte) {
+	if addr.Port == relay.GroupAddr.Port {
+		h.Metrics.MulticastSkipped.Inc(1)
+		return
+	}
+
+	var backingBuffer [2 * utils.Kilobyte]byte
+	pw := tcphub.PacketWrapper{}
+	pw.Init(backingBuffer[:], 0, relay.GroupAddr, addr)
+	pw.SetPacketType(tcphub.PtRadarMulticast)
+	pw.SetData(bytes)
+
+	h.Metrics.MulticastForwards.Inc(1)
+	h.Write(pw.GetPacket())
+}
+
 func (h *RouterClient) onTCPClose(_ *utils.TCPClientConnection) {
 	h.Metrics.TcpClose.IncAt(1, time.Now())
 }
 
 func (h *RouterClient) onTCPOpen(_ *utils.TCPClientConnection) {
 	h.Metrics.TcpOpen.IncAt(1, time.Now())
+	h.Subscribe(h.Subscription)
+}
+
+// Subscribe sends the subscription handshake, the server then only
+// forwarding the radars and ports subscribed to
+func (h *RouterClient) Subscribe(subscription tcphub.Subscription) {
+	var buffer [2 * utils.Kilobyte]byte
+	h.Subscription = subscription
+
+	packet, err := tcphub.NewSubscribePacket(buffer[:], &subscription)
+	if err != nil {
+		h.Metrics.SubscribeErrors.IncAt(1, time.Now())
+		return
+	}
+	h.Metrics.SubscribeIterations.IncAt(1, time.Now())
+	h.Write(packet)
+}
+
+func (h *RouterClient) onSubscribed(data []byte, now time.Time) {
+	var subscribed tcphub.Subscription
+
+	if err := subscribed.Deserialize(data); err != nil {
+		h.Metrics.SubscribeErrors.IncAt(1, now)
+		return
+	}
+	h.Subscribed = subscribed
 }
 
 func (h *RouterClient) onTCPError(connection *utils.TCPClientConnection, context utils.IPErrorContext, err error) {
@@ -1,9 +1,9 @@
 package server
 
 import (
-	"fmt"
 	"net"
 	"os"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -16,10 +16,17 @@ import (
 
 const routerServer = "Router.Server"
 
+// RouterServer forwards the radar data and multicast to its clients, any
+// number of them.  The clients are keyed by their remote address (ip and
+// port), several clients connecting from the same machine.  The multicast
+// of the clients (e.g. the keep-alives of remote tools) is emitted on the
+// radar network
 type RouterServer struct {
 	MultiAddr      utils.IP4
 	BindAddr       utils.IP4
 	Terminate      bool
+	WriteQueueSize int
+	Multicast      tcphub.MulticastRelay
 	Metrics        ServerMetrics
 	MulticastError error
 	writeChannel   chan []byte
@@ -28,6 +35,7 @@ type RouterServer struct {
 	Writer         interfaces.IUDPWriter              `json:"-"`
 	OnError        func(*RouterServer, error)         `json:"-"`
 	Connections    map[string]*RouterServerConnection `json:"-"`
+	connectionLock sync.RWMutex
 }
 
 type ServerMetrics struct {
@@ -39,6 +47,12 @@ type ServerMetrics struct {
 	PropagateOKBytes     *utils.Metric
 	PropagateNoops       *utils.Metric
 	PropagateNoopBytes   *utils.Metric
+	MulticastReadLoops   *utils.Metric
+	MulticastEmitOKs     *utils.Metric
+	MulticastEmitErrors  *utils.Metric
+	ConnectionsOpened    *utils.Metric
+	ConnectionsClosed    *utils.Metric
+	Subscriptions        *utils.Metric
 	utils.MetricsInitMixin
 }
 
@@ -53,11 +67,34 @@ func (h *RouterServer) Start(
 	h.Terminate = false
 	h.Writer = writer
 	h.refCount.Store(2)
+	h.Multicast.GroupAddr = multiAddr
+	h.Multicast.Init(routerServer + ".Multicast")
 
 	go h.executeAccept()
 	go h.executeMulticast()
 }
 
+// Stop closes the listener and the client connections
+func (h *RouterServer) Stop() {
+	h.Terminate = true
+
+	h.connectionLock.RLock()
+	connections := make([]*RouterServerConnection, 0, len(h.Connections))
+	for _, conn := range h.Connections {
+		connections = append(connections, conn)
+	}
+	h.connectionLock.RUnlock()
+
+	for _, conn := range connections {
+		conn.Stop()
+	}
+	h.Multicast.Stop()
+
+	for h.refCount.Load() > 1 {
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
 func (h *RouterServer) executeMulticast() {
 	multiAddr, err := net.ResolveUDPAddr("udp", h.MultiAddr.String())
 	if err != nil {
@@ -93,7 +130,11 @@ func (h *RouterServer) executeMulticast() {
 			} else {
 				remoteIP := utils.IP4Builder.FromAddr(readAddr)
 
-				if remoteIP.Port == 60000 {
+				// Only the radar announcements are sent to the clients, the
+				// multicast of the clients emitted being read back as well
+				if h.Multicast.IsLooped(remoteIP, buffer[:readBytes], time.Now()) {
+					h.Metrics.MulticastReadLoops.Inc(1)
+				} else if remoteIP.Port == 60000 {
 					var packetBuffer [256]byte
 					var packet tcphub.PacketWrapper
 					packet.Init(packetBuffer[:], 0, h.MultiAddr, remoteIP)
@@ -156,19 +197,29 @@ func (h *RouterServer) executeAccept() {
 }
 
 func (h *RouterServer) StartClient(conn *net.TCPConn) {
-	h.clearConnections()
-
-	ip4 := utils.IP4Builder.FromAddr(conn.RemoteAddr())
-	obj := &RouterServerConnection{}
+	obj := &RouterServerConnection{
+		Key:            conn.RemoteAddr().String(),
+		WriteQueueSize: h.WriteQueueSize,
+	}
 	obj.OnPropagate = h.onPropagateConnectionData
 	obj.OnClose = h.onCloseConnection
-	h.Connections[ip4.String()] = obj
+	obj.OnSubscribe = h.onSubscribeConnection
+
+	h.connectionLock.Lock()
+	h.Connections[obj.Key] = obj
+	h.connectionLock.Unlock()
+
+	h.Metrics.ConnectionsOpened.Inc(1)
 	obj.Start(conn)
 }
 
-// Write sends the packet data to each client.  Each client determines
-// its backlog, and copy the packet if it thinks possible to send it
+// Write sends the packet data to each client.  Each client filters on
+// its subscription, determines its backlog, and copy the packet if it
+// thinks possible to send it
 func (h *RouterServer) Write(packetData []byte) {
+	h.connectionLock.RLock()
+	defer h.connectionLock.RUnlock()
+
 	for _, conn := range h.Connections {
 		conn.Write(packetData)
 	}
@@ -182,16 +233,6 @@ func (h *RouterServer) onError(err error) {
 	}
 }
 
-func (h *RouterServer) clearConnections() {
-
-	for key, conn := range h.Connections {
-		if conn.IsTerminated() {
-			delete(h.Connections, key)
-		}
-	}
-	fmt.Println("Clear Connections", len(h.Connections))
-}
-
 // onPropagateConnectionData takes the data as sent from the connection and send it
 // to the Server associated packet writer (generally udp sent to the radar)
 func (h *RouterServer) onPropagateConnectionData(connection *RouterServerConnection, packetData []byte) {
@@ -201,6 +242,11 @@ func (h *RouterServer) onPropagateConnectionData(connection *RouterServerConnect
 		Buffer: packetData,
 	}
 
+	if packet.GetPacketType() == tcphub.PtRadarMulticast {
+		h.propagateMulticast(&packet, now)
+		return
+	}
+
 	if h.Writer != nil {
 		if err := h.Writer.WriteData(packet.GetTargetIP4(), packet.GetData()); err != nil {
 			h.Metrics.PropagateErrors.IncAt(1, now)
@@ -215,10 +261,51 @@ func (h *RouterServer) onPropagateConnectionData(connection *RouterServerConnect
 	}
 }
 
+// propagateMulticast emits the multicast of a client (generally the
+// keep-alive of a radar tool) to the radar network
+func (h *RouterServer) propagateMulticast(packet *tcphub.PacketWrapper, now time.Time) {
+	if err := h.Multicast.Emit(utils.IP4{}, packet.GetData(), now); err != nil {
+		h.Metrics.MulticastEmitErrors.IncAt(1, now)
+		h.onError(err)
+	} else {
+		h.Metrics.MulticastEmitOKs.IncAt(1, now)
+	}
+}
+
 func (h *RouterServer) HasConnections() bool {
+	h.connectionLock.RLock()
+	defer h.connectionLock.RUnlock()
 	return len(h.Connections) > 0
 }
 
-func (h *RouterServer) onCloseConnection(*RouterServerConnection, error) {
-	h.clearConnections()
+func (h *RouterServer) GetConnectionCount() int {
+	h.connectionLock.RLock()
+	defer h.connectionLock.RUnlock()
+	return len(h.Connections)
+}
+
+// GetSubscriptions returns the subscription of each client by remote address
+func (h *RouterServer) GetSubscriptions() map[string]tcphub.Subscription {
+	h.connectionLock.RLock()
+	defer h.connectionLock.RUnlock()
+
+	res := make(map[string]tcphub.Subscription, len(h.Connections))
+	for key, conn := range h.Connections {
+		res[key] = conn.GetSubscription()
+	}
+	return res
+}
+
+func (h *RouterServer) onCloseConnection(conn *RouterServerConnection, _ error) {
+	h.connectionLock.Lock()
+	defer h.connectionLock.Unlock()
+
+	if h.Connections[conn.Key] == conn {
+		delete(h.Connections, conn.Key)
+		h.Metrics.ConnectionsClosed.Inc(1)
+	}
+}
+
+func (h *RouterServer) onSubscribeConnection(*RouterServerConnection, tcphub.Subscription) {
+	h.Metrics.Subscriptions.Inc(1)
 }
@@ -2,7 +2,6 @@ package server
 
 import (
 	"bufio"
-	"fmt"
 	"net"
 	"os"
 	"sync"
@@ -17,7 +16,17 @@ import (
 
 const rsc = "Router.Server.Connection"
 
+// defaultWriteQueueSize is the packets queued per connection, the packets
+// beyond are dropped for that connection only
+const defaultWriteQueueSize = 10
+
+// RouterServerConnection is a client of the RouterServer.  The client
+// receives the radars and ports of its subscription (all until the client
+// subscribes), each connection queueing its packets so a slow client drops
+// its own packets without holding up the other clients
 type RouterServerConnection struct {
+	Key            string
+	WriteQueueSize int
 	connection     *net.TCPConn
 	ReadTerminate  bool
 	WriteTerminate bool
@@ -30,7 +39,11 @@ type RouterServerConnection struct {
 	OnPropagate    func(*RouterServerConnection, []byte)
 	OnError        func(*RouterServerConnection, error)
 	OnClose        func(*RouterServerConnection, error)
+	OnSubscribe    func(*RouterServerConnection, tcphub.Subscription)
 	DoneTerminate  bool
+	subscription   tcphub.Subscription
+	subLock        sync.RWMutex
+	writeLock      sync.RWMutex
 }
 
 type HubServerConnectionMetrics struct {
@@ -48,6 +61,10 @@ type HubServerConnectionMetrics struct {
 	WriteTerminatedIterations *utils.Metric
 	WriteDequeueIterations    *utils.Metric
 	WriteDequeueBytes         *utils.Metric
+	WriteFilteredIterations   *utils.Metric
+	WriteFilteredBytes        *utils.Metric
+	SubscribeIterations       *utils.Metric
+	SubscribeErrors           *utils.Metric
 	utils.MetricsInitMixin
 }
 
@@ -56,7 +73,10 @@ func (h *RouterServerConnection) Start(connection *net.TCPConn) {
 	h.connection = connection
 	h.ReadTerminate = false
 	h.refCount.Store(2)
-	h.writeChannel = make(chan []byte, 10)
+	if h.WriteQueueSize <= 0 {
+		h.WriteQueueSize = defaultWriteQueueSize
+	}
+	h.writeChannel = make(chan []byte, h.WriteQueueSize)
 	h.doneChannel = make(chan bool)
 	h.packetQueue.Init(8 * utils.Kilobyte)
 	h.writePool = sync.Pool{
@@ -65,7 +85,6 @@ func (h *RouterServerConnection) Start(connection *net.TCPConn) {
 		},
 	}
 
-	fmt.Println("onstart")
 	go h.executeRead()
 	go h.executeWrite()
 	go h.executeError()
@@ -89,7 +108,6 @@ func (h *RouterServerConnection) Join() {
 	}
 
 	if h.OnClose != nil {
-		fmt.Println("close OnClose")
 		h.OnClose(h, nil)
 	}
 }
@@ -106,6 +124,10 @@ func (h *RouterServerConnection) executeRead() {
 		bytesRead, err := reader.Read(readBuffer[:])
 
 		if err != nil {
+			if h.ReadTerminate {
+				break
+			}
+
 			if !errors.Is(err, os.ErrDeadlineExceeded) {
 				h.onError(err)
 				if !h.DoneTerminate {
@@ -142,10 +164,12 @@ func (h *RouterServerConnection) executeRead() {
 				}
 
 				// Reading from a Connection means that the data should be
-				// sent to a radar
-
-				packetBytes := packet.GetPacket()
-				h.onPropagate(packetBytes)
+				// sent to a radar, but for the subscription handshake
+				if packet.GetPacketType() == tcphub.PtSubscribe {
+					h.subscribe(packet.GetData(), now)
+				} else {
+					h.onPropagate(packet.GetPacket())
+				}
 
 				if err := h.packetQueue.PopSize(packet.GetPacketSize()); err != nil {
 					h.Metrics.ReadPopErrors.IncAt(1, now)
@@ -213,10 +237,13 @@ func (h *RouterServerConnection) writeData(packetData []byte) error {
 		h.Metrics.TcpWriteBytes.IncAt(int64(packet.GetPacketSize()), now)
 	}
 
-	h.writePool.Put(packetData)
+	h.writePool.Put(packetData[:cap(packetData)])
 	return err
 }
 
+// Write queues a copy of the packet when the connection subscribed to its
+// radar (the source) and port.  The packet is dropped when the queue is
+// full, the write never blocking the server
 func (h *RouterServerConnection) Write(packetData []byte) {
 	if h.WriteTerminate {
 		return
@@ -233,21 +260,80 @@ func (h *RouterServerConnection) Write(packetData []byte) {
 		return
 	}
 
-	if h.ReadTerminate || h.writeChannel == nil {
-		h.Metrics.WriteTerminatedBytes.IncAt(int64(packet.GetPacketSize()), now)
+	if !h.IsSubscribedTo(&packet) {
+		h.Metrics.WriteFilteredIterations.IncAt(1, now)
+		h.Metrics.WriteFilteredBytes.IncAt(int64(packet.GetPacketSize()), now)
+		return
+	}
+
+	h.enqueue(packet.GetPacket(), now)
+}
+
+func (h *RouterServerConnection) enqueue(packetData []byte, now time.Time) {
+	h.writeLock.RLock()
+	defer h.writeLock.RUnlock()
+
+	if h.WriteTerminate || h.ReadTerminate || h.writeChannel == nil {
+		h.Metrics.WriteTerminatedBytes.IncAt(int64(len(packetData)), now)
 		h.Metrics.WriteTerminatedIterations.IncAt(1, now)
 		return
 	}
 
-	if len(h.writeChannel)+1 >= cap(h.writeChannel) {
+	packetCopy := h.writePool.Get().([]byte)
+	if cap(packetCopy) < len(packetData) {
+		packetCopy = make([]byte, len(packetData))
+	}
+	packetCopy = packetCopy[:len(packetData)]
+	copy(packetCopy, packetData)
+
+	select {
+	case h.writeChannel <- packetCopy:
+	default:
+		h.writePool.Put(packetCopy[:cap(packetCopy)])
 		h.Metrics.WriteDequeueIterations.IncAt(1, now)
-		h.Metrics.WriteDequeueBytes.IncAt(int64(packet.GetPacketSize()), now)
+		h.Metrics.WriteDequeueBytes.IncAt(int64(len(packetData)), now)
+	}
+}
+
+// IsSubscribedTo returns whether the packet is of a radar and port the
+// connection subscribed to
+func (h *RouterServerConnection) IsSubscribedTo(packet *tcphub.PacketWrapper) bool {
+	h.subLock.RLock()
+	defer h.subLock.RUnlock()
+	return h.subscription.IsSubscribedTo(packet.GetPacketType(), packet.GetSourceIP4(), packet.GetData())
+}
+
+// GetSubscription returns the subscription of the connection handshake
+func (h *RouterServerConnection) GetSubscription() tcphub.Subscription {
+	h.subLock.RLock()
+	defer h.subLock.RUnlock()
+	return h.subscription
+}
+
+// subscribe replaces the subscription, and answers with the subscription
+// applied.  A subscription failing to parse leaves the previous as is
+func (h *RouterServerConnection) subscribe(data []byte, now time.Time) {
+	var subscription tcphub.Subscription
+
+	if err := subscription.Deserialize(data); err != nil {
+		h.Metrics.SubscribeErrors.IncAt(1, now)
+		h.onError(err)
 		return
 	}
 
-	packetCopy := h.writePool.Get().([]byte)
-	copy(packetCopy, packet.GetPacket())
-	h.writeChannel <- packetData
+	h.subLock.Lock()
+	h.subscription = subscription
+	h.subLock.Unlock()
+	h.Metrics.SubscribeIterations.IncAt(1, now)
+
+	if h.OnSubscribe != nil {
+		h.OnSubscribe(h, subscription)
+	}
+
+	var buffer [2 * utils.Kilobyte]byte
+	if answer, err := tcphub.NewSubscribePacket(buffer[:], &subscription); err == nil {
+		h.enqueue(answer, now)
+	}
 }
 
 func (h *RouterServerConnection) onPropagate(bytes []byte) {
@@ -271,11 +357,17 @@ func (h *RouterServerConnection) IsTerminated() bool {
 func (h *RouterServerConnection) executeError() {
 	for range h.doneChannel {
 
+		h.writeLock.Lock()
 		h.WriteTerminate = true
 		h.ReadTerminate = true
+		h.writeLock.Unlock()
 
 		h.refCount.Add(-1)
 
+		if connection := h.connection; connection != nil {
+			_ = connection.Close()
+		}
+
 		close(h.writeChannel)
 		close(h.doneChannel)
 
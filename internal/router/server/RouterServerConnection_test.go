@@ -0,0 +1,138 @@
+package server
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/internal/smartmicro/port"
+	"rvpro3/radarvision.com/internal/tcphub"
+	"rvpro3/radarvision.com/utils"
+)
+
+func TestRouterServer_FanOut(t *testing.T) {
+	bindAddr := utils.IP4Builder.FromString("127.0.0.1:45181")
+	radar12 := utils.IP4Builder.FromString("192.168.11.12:55555")
+	radar13 := utils.IP4Builder.FromString("192.168.11.13:55555")
+
+	hs := RouterServer{WriteQueueSize: 4}
+	hs.Start(bindAddr, utils.IP4Builder.FromString("239.144.0.0:60000"), &DummyUDPPacketWriter{})
+	defer hs.Stop()
+
+	byRadar := dialRouterServer(t, bindAddr, &tcphub.Subscription{Radars: []utils.IP4{radar12}})
+	byPort := dialRouterServer(t, bindAddr, &tcphub.Subscription{PortIdentifiers: []uint32{port.PiStatistics}})
+	all := dialRouterServer(t, bindAddr, nil)
+	slow := dialRouterServer(t, bindAddr, nil)
+	defer func() {
+		for _, conn := range []net.Conn{byRadar, byPort, all, slow} {
+			_ = conn.Close()
+		}
+	}()
+
+	// Wait for the handshakes, each answered with the subscription applied
+	for _, conn := range []net.Conn{byRadar, byPort} {
+		packets := readRouterPackets(t, conn, 1)
+		if assert.Len(t, packets, 1) {
+			assert.Equal(t, tcphub.PtSubscribe, packets[0].GetPacketType())
+		}
+	}
+	assert.Eventually(t, func() bool { return len(hs.GetSubscriptions()) == 4 }, 3*time.Second, 10*time.Millisecond)
+
+	hs.Write(newForwardPacket(radar12, port.PiObjectList))
+	hs.Write(newForwardPacket(radar13, port.PiStatistics))
+	hs.Write(newForwardPacket(radar12, port.PiStatistics))
+
+	packets := readRouterPackets(t, byRadar, 2)
+	if assert.Len(t, packets, 2) {
+		assert.Equal(t, radar12, packets[0].GetSourceIP4())
+		assert.Equal(t, radar12, packets[1].GetSourceIP4())
+	}
+
+	packets = readRouterPackets(t, byPort, 2)
+	if assert.Len(t, packets, 2) {
+		assert.Equal(t, radar13, packets[0].GetSourceIP4())
+		assert.Equal(t, radar12, packets[1].GetSourceIP4())
+	}
+
+	assert.Len(t, readRouterPackets(t, all, 3), 3)
+
+	// The slow client never reads, the server keeps on writing to the others
+	start := time.Now()
+	for i := 0; i < 5000; i++ {
+		hs.Write(newForwardPacket(radar13, port.PiObjectList))
+	}
+	hs.Write(newForwardPacket(radar12, port.PiPVR))
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	assert.Eventually(t, func() bool {
+		for _, packet := range readRouterPackets(t, byRadar, 1) {
+			if packet.GetSourceIP4() == radar12 {
+				return true
+			}
+		}
+		return false
+	}, 3*time.Second, 10*time.Millisecond)
+}
+
+func dialRouterServer(t *testing.T, bindAddr utils.IP4, subscription *tcphub.Subscription) net.Conn {
+	var conn net.Conn
+	var err error
+
+	assert.Eventually(t, func() bool {
+		conn, err = net.Dial("tcp", bindAddr.String())
+		return err == nil
+	}, 3*time.Second, 50*time.Millisecond)
+
+	if subscription != nil {
+		var buffer [2 * utils.Kilobyte]byte
+		packet, err := tcphub.NewSubscribePacket(buffer[:], subscription)
+		assert.NoError(t, err)
+		_, err = conn.Write(packet)
+		assert.NoError(t, err)
+	}
+	return conn
+}
+
+// readRouterPackets reads the count of packets (or fewer on timeout)
+func readRouterPackets(t *testing.T, conn net.Conn, count int) []tcphub.PacketWrapper {
+	var readBuffer [4 * utils.Kilobyte]byte
+	var res []tcphub.PacketWrapper
+	var pending []byte
+
+	for len(res) < count {
+		_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+		bytesRead, err := conn.Read(readBuffer[:])
+		if err != nil {
+			t.Log("readRouterPackets", err)
+			return res
+		}
+		pending = append(pending, readBuffer[:bytesRead]...)
+
+		packet := tcphub.PacketWrapper{Buffer: pending}
+		for len(res) < count && packet.IsComplete() {
+			size := packet.GetPacketSize()
+			res = append(res, tcphub.PacketWrapper{Buffer: append([]byte(nil), pending[:size]...)})
+			pending = pending[size:]
+			packet.Buffer = pending
+		}
+	}
+	return res
+}
+
+// newForwardPacket returns the radar data packet, the data being a smartmicro
+// transport and port header
+func newForwardPacket(radar utils.IP4, portIdentifier uint32) []byte {
+	var buffer [256]byte
+	data := make([]byte, 12+24)
+	data[0] = port.StartPattern
+	data[2] = 12
+	binary.BigEndian.PutUint32(data[12:], portIdentifier)
+
+	packet := tcphub.PacketWrapper{}
+	packet.Init(buffer[:], 0, utils.IP4Builder.FromString("192.168.11.1:55555"), radar)
+	packet.SetPacketType(tcphub.PtUdpForward)
+	packet.SetData(data)
+	return packet.GetPacket()
+}
@@ -2,9 +2,7 @@ package server
 
 import (
 	"net"
-	"time"
 
-	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"rvpro3/radarvision.com/internal/constants"
 	"rvpro3/radarvision.com/internal/smartmicro/service"
@@ -12,21 +10,22 @@ import (
 	"rvpro3/radarvision.com/utils"
 )
 
-// RouterServerService is a wrapper around the RouterServer
-// TODO: Put the listAndForward inside the RouterServer
+// RouterServerService is a wrapper around the RouterServer, the server
+// forwarding the radar multicast itself
 type RouterServerService struct {
-	Server     RouterServer
-	BindAddr   utils.IP4
-	IsEnabled  bool
-	MultiAddr  utils.IP4
-	Terminate  bool
-	Terminated bool
-	LastError  error
+	Server    RouterServer
+	BindAddr  utils.IP4
+	IsEnabled bool
+	MultiAddr utils.IP4
 }
 
 func (r *RouterServerService) InitFromSettings(settings *utils.Settings) {
 	r.BindAddr = settings.Basic.GetIP4("router.server.bind.ip.address", utils.IP4Builder.FromString("0.0.0.0:45001"))
 	r.MultiAddr = settings.Basic.GetIP4("router.server.multicast.ip.address", utils.IP4Builder.FromString("239.144.0.0:60000"))
+	r.Server.WriteQueueSize = settings.Basic.GetInt("router.server.client.queue.size", defaultWriteQueueSize)
+	r.Server.Multicast.InterfaceName = settings.Basic.Get("router.server.multicast.interface", "")
+	r.Server.Multicast.IsLoopback = settings.Basic.GetBool("router.server.multicast.loopback", false)
+	r.Server.Multicast.LoopWindow = settings.Basic.GetMilliseconds("router.server.multicast.loop.window", 2000)
 }
 
 func (r *RouterServerService) Start(state *utils.State, settings *utils.Settings) {
@@ -38,8 +37,6 @@ func (r *RouterServerService) Start(state *utils.State, settings *utils.Settings
 		writer.RegisterReceiver(r.onReadUDP)
 		r.IsEnabled = true
 		r.Server.Start(r.BindAddr, r.MultiAddr, writer)
-
-		go r.listenAndForwardMulticast()
 	} else {
 		r.IsEnabled = false
 		log.Warn().Msg("Router Server not started as no UDP Writer found")
@@ -50,58 +47,6 @@ func (r *RouterServerService) GetServiceName() string {
 	return constants.RouterServerService
 }
 
-func (r *RouterServerService) listenAndForwardMulticast() {
-	multiAddr, err := net.ResolveUDPAddr("udp", r.MultiAddr.String())
-	if err != nil {
-		log.Error().Err(err).Msg("Error resolving multicast address")
-		return
-	}
-
-	var conn *net.UDPConn
-	var buffer [60]byte
-
-	for !r.Terminate {
-		if conn == nil {
-			conn, err = net.ListenMulticastUDP("udp", nil, multiAddr)
-			if err != nil {
-				if !errors.Is(err, r.LastError) {
-					log.Error().Err(err).Msg("Error listening multicast UDP")
-				}
-				time.Sleep(3 * time.Second)
-			} else {
-				_ = conn.SetReadBuffer(cap(buffer))
-			}
-
-		}
-
-		if conn != nil {
-			readBytes, readAddr, readErr := conn.ReadFromUDP(buffer[:])
-
-			if readErr != nil {
-				log.Error().Err(readErr).Msg("Error reading multicast packet")
-				_ = conn.Close()
-				conn = nil
-			} else {
-				remoteIP := utils.IP4Builder.FromAddr(readAddr)
-
-				if remoteIP.Port == 60000 && r.Server.HasConnections() {
-					var packetBuffer [256]byte
-					var packet tcphub.PacketWrapper
-					packet.Init(packetBuffer[:], 0, r.MultiAddr, remoteIP)
-					packet.SetPacketType(tcphub.PtRadarMulticast)
-					packet.SetData(buffer[:readBytes])
-					r.Server.Write(packet.GetPacket())
-				}
-			}
-		}
-	}
-	r.Terminated = true
-
-	if conn != nil {
-		_ = conn.Close()
-	}
-}
-
 func (r *RouterServerService) onReadUDP(
 	dataService *service.UDPDataService,
 	addr net.UDPAddr,
@@ -115,6 +60,7 @@ func (r *RouterServerService) onReadUDP(
 		packet.Init(
 			packetData[:], 0, dataService.ListenAddr, sourceIP,
 		)
+		packet.SetPacketType(tcphub.PtUdpForward)
 		packet.SetData(bytes)
 		r.Server.Write(packet.GetPacket())
 	}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"fmt"
+	"net"
 	"strings"
 	"sync"
 	"testing"
@@ -13,10 +14,16 @@ import (
 )
 
 func TestServer_Start(t *testing.T) {
+	listener, err := net.Listen("tcp", "192.168.11.1:45000")
+	if err != nil {
+		t.Skip("Router network not available", err)
+	}
+	_ = listener.Close()
+
 	wg := sync.WaitGroup{}
 	writer := DummyUDPPacketWriter{}
 	hs := RouterServer{}
-	hs.Start(utils.IP4Builder.FromString("192.168.11.1:45000"), &writer)
+	hs.Start(utils.IP4Builder.FromString("192.168.11.1:45000"), utils.IP4Builder.FromString("239.144.0.0:60000"), &writer)
 
 	hc := startClient(utils.IP4Builder.FromString("192.168.11.1:45000"))
 	wg.Add(1)
@@ -30,8 +37,8 @@ func TestServer_Start(t *testing.T) {
 	hc.StopAndJoin()
 }
 
-func startClient(ip4 utils.IP4) client.RouterClient {
-	hc := client.RouterClient{}
+func startClient(ip4 utils.IP4) *client.RouterClient {
+	hc := &client.RouterClient{}
 	hc.Start(ip4)
 	return hc
 }
@@ -56,11 +63,7 @@ func writeViaServer(radarIP utils.IP4, server *RouterServer, wg *sync.WaitGroup)
 type DummyUDPPacketWriter struct {
 }
 
-func (d *DummyUDPPacketWriter) WritePacket(packetData []byte) error {
-	packet := tcphub.PacketWrapper{
-		Buffer: packetData,
-	}
-
-	fmt.Printf("target ip: %s,  data size: %d data: %s\n", packet.GetTargetIP4(), packet.GetDataSize(), string(packet.GetData()))
+func (d *DummyUDPPacketWriter) WriteData(ip4 utils.IP4, data []byte) error {
+	fmt.Printf("target ip: %s,  data size: %d data: %s\n", ip4, len(data), string(data))
 	return nil
 }
@@ -0,0 +1,107 @@
+//go:build linux
+
+package sdlcsim
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// PtyConnection is the master side of a pseudo terminal, the slave
+// (SlaveName) is opened as the serial port by the SDLCService
+type PtyConnection struct {
+	Master    *os.File
+	Slave     *os.File
+	SlaveName string
+}
+
+// OpenPty opens a pseudo terminal in raw mode
+func OpenPty() (*PtyConnection, error) {
+	// Non-blocking, so that the master supports the read deadline
+	masterFd, err := syscall.Open("/dev/ptmx", syscall.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	master := os.NewFile(uintptr(masterFd), "/dev/ptmx")
+
+	var unlock int32
+	if err = ioctl(master.Fd(), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		_ = master.Close()
+		return nil, err
+	}
+
+	var ptyNo uint32
+	if err = ioctl(master.Fd(), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&ptyNo))); err != nil {
+		_ = master.Close()
+		return nil, err
+	}
+
+	slaveName := "/dev/pts/" + strconv.Itoa(int(ptyNo))
+
+	// The slave is kept open so that the master does not fail (EIO) while
+	// the SDLCService (re)connects
+	slave, err := os.OpenFile(slaveName, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		_ = master.Close()
+		return nil, err
+	}
+
+	if err = makeRaw(slave.Fd()); err != nil {
+		_ = slave.Close()
+		_ = master.Close()
+		return nil, err
+	}
+
+	return &PtyConnection{Master: master, Slave: slave, SlaveName: slaveName}, nil
+}
+
+// Read returns 0 bytes after 100ms without data, allowing the simulator to
+// broadcast
+func (p *PtyConnection) Read(buffer []byte) (int, error) {
+	_ = p.Master.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+
+	size, err := p.Master.Read(buffer)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return 0, nil
+	}
+	return size, err
+}
+
+func (p *PtyConnection) Write(data []byte) (int, error) {
+	return p.Master.Write(data)
+}
+
+func (p *PtyConnection) Close() error {
+	_ = p.Slave.Close()
+	return p.Master.Close()
+}
+
+func makeRaw(fd uintptr) error {
+	var termios syscall.Termios
+
+	if err := ioctl(fd, syscall.TCGETS, uintptr(unsafe.Pointer(&termios))); err != nil {
+		return err
+	}
+
+	termios.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	termios.Oflag &^= syscall.OPOST
+	termios.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	termios.Cflag &^= syscall.CSIZE | syscall.PARENB
+	termios.Cflag |= syscall.CS8
+	termios.Cc[syscall.VMIN] = 1
+	termios.Cc[syscall.VTIME] = 0
+
+	return ioctl(fd, syscall.TCSETS, uintptr(unsafe.Pointer(&termios)))
+}
+
+func ioctl(fd uintptr, request uintptr, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+//go:build linux
+
+package sdlcsim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/internal/sdlc/uartsdlc"
+)
+
+func TestOpenPty(t *testing.T) {
+	pty, err := OpenPty()
+	if err != nil {
+		t.Skip("Pseudo terminals not available", err)
+	}
+	defer func() {
+		_ = pty.Close()
+	}()
+
+	sim := NewSDLCSimulator("Test.SDLC.Simulator.Pty")
+	go func() {
+		_ = sim.Serve(pty)
+	}()
+	defer sim.Stop()
+
+	encoder := uartsdlc.SDLCRequestEncoder{}
+	request, _ := encoder.StaticStatus()
+	_, err = pty.Slave.Write(request)
+	assert.NoError(t, err)
+
+	var buffer [64]byte
+	_ = pty.Slave.SetReadDeadline(time.Now().Add(2 * time.Second))
+	size, err := pty.Slave.Read(buffer[:])
+	assert.NoError(t, err)
+
+	decoder := uartsdlc.SDLCResponseDecoder{}
+	assert.NoError(t, decoder.Init(buffer[:size]))
+	assert.Equal(t, uartsdlc.StaticStatusResponseCode, decoder.GetIdentifier())
+}
@@ -0,0 +1,32 @@
+//go:build !linux
+
+package sdlcsim
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+type PtyConnection struct {
+	Master    *os.File
+	Slave     *os.File
+	SlaveName string
+}
+
+// OpenPty is only supported on Linux, use the SimulatorPort instead
+func OpenPty() (*PtyConnection, error) {
+	return nil, errors.New("pseudo terminals are only supported on linux")
+}
+
+func (p *PtyConnection) Read([]byte) (int, error) {
+	return 0, os.ErrClosed
+}
+
+func (p *PtyConnection) Write([]byte) (int, error) {
+	return 0, os.ErrClosed
+}
+
+func (p *PtyConnection) Close() error {
+	return nil
+}
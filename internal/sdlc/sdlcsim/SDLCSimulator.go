@@ -0,0 +1,271 @@
+package sdlcsim
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"rvpro3/radarvision.com/internal/sdlc/uartsdlc"
+	"rvpro3/radarvision.com/utils"
+)
+
+// SDLCSimulator is a virtual BIU/controller speaking the uartsdlc.Codec
+// framing.  It answers the status and diagnostics requests with the
+// configured responses, acknowledges the TS2Detect and ConfigBIU commands
+// and records the detector bits it received.
+//
+// The simulator is served over any io.ReadWriter, see SimulatorPort (in
+// process) and OpenPty (pseudo terminal)
+type SDLCSimulator struct {
+	StaticStatus    uartsdlc.StaticStatus
+	DynamicStatus   uartsdlc.DynamicStatus
+	CMUFrame        uartsdlc.CMUFrame
+	BIUDiagnostics  uartsdlc.BIUDiagnostics
+	SDLCDiagnostics uartsdlc.SDLCDiagnostics
+	SIUDiagnostics  uartsdlc.SIUDiagnostics
+	BIUConfig       uartsdlc.BIUFlags
+	Detects         uint64
+	DetectsOn       time.Time
+	DetectRecords   []DetectRecord
+	MaxRecords      int
+	IsBroadcast     bool
+	BroadcastEvery  time.Duration
+	BroadcastOn     time.Time
+	Now             func() time.Time `json:"-"`
+	Metrics         SDLCSimulatorMetrics
+	lock            sync.Mutex
+	terminate       bool
+}
+
+// DetectRecord is a TS2Detect command as received by the simulator
+type DetectRecord struct {
+	On      time.Time
+	Detects uint64
+}
+
+type SDLCSimulatorMetrics struct {
+	Requests        *utils.Metric
+	DecodeErrCount  *utils.Metric
+	UnknownRequests *utils.Metric
+	DetectCount     *utils.Metric
+	ConfigBIUCount  *utils.Metric
+	ResponseCount   *utils.Metric
+	BroadcastCount  *utils.Metric
+	utils.MetricsInitMixin
+}
+
+// NewSDLCSimulator creates a TS2 simulator with all 4 BIUs enabled
+func NewSDLCSimulator(metricsName string) *SDLCSimulator {
+	sim := &SDLCSimulator{
+		StaticStatus: uartsdlc.StaticStatus{
+			BIU:             0x0f,
+			MajorVersion:    1,
+			MinorVersion:    0,
+			Serial:          0x5349_4d55_4c41_5445,
+			ProtocolVersion: 1,
+			Mode:            0x02,
+		},
+		CMUFrame:       uartsdlc.CMUFrame{Mode: 0x02, BitCount: 16},
+		MaxRecords:     1024,
+		BroadcastEvery: time.Second,
+		Now:            time.Now,
+	}
+	sim.Metrics.InitMetrics(metricsName, &sim.Metrics)
+	return sim
+}
+
+// Handle decodes the request and returns the encoded response, or nil
+// when the request is not answered
+func (s *SDLCSimulator) Handle(request []byte) []byte {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := s.Now()
+	s.Metrics.Requests.IncAt(1, now)
+
+	decoder := uartsdlc.SDLCRequestDecoder{}
+	if err := decoder.Init(request); err != nil {
+		s.Metrics.DecodeErrCount.IncAt(1, now)
+		return nil
+	}
+
+	encoder := uartsdlc.SDLCResponseEncoder{}
+	var response []byte
+	var err error
+
+	switch decoder.GetIdentifier() {
+	case uartsdlc.StaticStatusRequestCode:
+		response, err = encoder.StaticStatus(&s.StaticStatus)
+
+	case uartsdlc.DynamicStatusRequestCode:
+		s.DynamicStatus.RequestedCount++
+		response, err = encoder.DynamicStatus(&s.DynamicStatus)
+
+	case uartsdlc.BIUDiagnosticRequestCode:
+		response, err = encoder.BIUDiagnostics(&s.BIUDiagnostics)
+		s.resetOn(&decoder, func() { s.BIUDiagnostics = uartsdlc.BIUDiagnostics{} })
+
+	case uartsdlc.SDLCDiagnosticRequestCode:
+		response, err = encoder.SDLCDiagnostics(&s.SDLCDiagnostics)
+		s.resetOn(&decoder, func() { s.SDLCDiagnostics = uartsdlc.SDLCDiagnostics{} })
+
+	case uartsdlc.SIUDiagnosticRequestCode:
+		response, err = encoder.SIUDiagnostics(&s.SIUDiagnostics)
+		s.resetOn(&decoder, func() { s.SIUDiagnostics = uartsdlc.SIUDiagnostics{} })
+
+	case uartsdlc.SendDetectDataCode:
+		if err = s.onDetect(now, &decoder); err == nil {
+			response, err = encoder.Acknowledge(0)
+		}
+
+	case uartsdlc.ConfigBIURequestCode:
+		if s.BIUConfig, err = decoder.GetConfigBIU(); err == nil {
+			s.Metrics.ConfigBIUCount.IncAt(1, now)
+			response, err = encoder.Acknowledge(0)
+		}
+
+	default:
+		s.Metrics.UnknownRequests.IncAt(1, now)
+		return nil
+	}
+
+	if err != nil {
+		s.Metrics.DecodeErrCount.IncAt(1, now)
+		log.Err(err).Msg("SDLCSimulator.Handle")
+		return nil
+	}
+
+	s.Metrics.ResponseCount.IncAt(1, now)
+	return response
+}
+
+func (s *SDLCSimulator) resetOn(decoder *uartsdlc.SDLCRequestDecoder, reset func()) {
+	if value, err := decoder.GetReset(); err == nil && value != 0 {
+		reset()
+	}
+}
+
+func (s *SDLCSimulator) onDetect(now time.Time, decoder *uartsdlc.SDLCRequestDecoder) error {
+	detects, err := decoder.GetTS2Detect()
+	if err != nil {
+		return err
+	}
+
+	s.Metrics.DetectCount.IncAt(1, now)
+	s.Detects = detects
+	s.DetectsOn = now
+
+	if s.MaxRecords > 0 && len(s.DetectRecords) >= s.MaxRecords {
+		s.DetectRecords = s.DetectRecords[1:]
+	}
+	s.DetectRecords = append(s.DetectRecords, DetectRecord{On: now, Detects: detects})
+	return nil
+}
+
+// GetDetects returns the last detector bits received
+func (s *SDLCSimulator) GetDetects() (uint64, time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.Detects, s.DetectsOn
+}
+
+// GetDetectRecords returns a copy of the detector bits received
+func (s *SDLCSimulator) GetDetectRecords() []DetectRecord {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]DetectRecord(nil), s.DetectRecords...)
+}
+
+// Update changes the simulated state (e.g. the CMU frame) under the lock
+func (s *SDLCSimulator) Update(update func(*SDLCSimulator)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	update(s)
+}
+
+// broadcast returns the CMU frame and the date/time broadcasts when due
+func (s *SDLCSimulator) broadcast() [][]byte {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := s.Now()
+
+	if !s.IsBroadcast || !utils.Time.IsExpired(now, s.BroadcastOn, s.BroadcastEvery) {
+		return nil
+	}
+	s.BroadcastOn = now
+
+	encoder := uartsdlc.SDLCResponseEncoder{}
+	frames := make([][]byte, 0, 2)
+
+	if data, err := encoder.CMUFrame(&s.CMUFrame); err == nil {
+		frames = append(frames, append([]byte(nil), data...))
+	}
+
+	if data, err := encoder.DateTime(now); err == nil {
+		frames = append(frames, append([]byte(nil), data...))
+	}
+
+	s.Metrics.BroadcastCount.IncAt(int64(len(frames)), now)
+	return frames
+}
+
+// Serve reads the requests from the connection and writes the responses
+// (and broadcasts) back, up until the connection is closed or Stop
+func (s *SDLCSimulator) Serve(connection io.ReadWriter) error {
+	var readBuffer [256]byte
+	var backingBuffer [1024]byte
+
+	serialBuffer := utils.SerialBuffer{
+		Buffer:     backingBuffer[:],
+		StartDelim: 0x02,
+		EndDelim:   0x03,
+	}
+
+	for !s.isTerminated() {
+		for _, frame := range s.broadcast() {
+			if _, err := connection.Write(frame); err != nil {
+				return err
+			}
+		}
+
+		readSize, err := connection.Read(readBuffer[:])
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		if readSize == 0 {
+			continue
+		}
+
+		if err = serialBuffer.Push(readBuffer[:readSize]); err != nil {
+			serialBuffer.Reset()
+			continue
+		}
+
+		for request := serialBuffer.Pop(); request != nil; request = serialBuffer.Pop() {
+			if response := s.Handle(request); response != nil {
+				if _, err = connection.Write(response); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *SDLCSimulator) Stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.terminate = true
+}
+
+func (s *SDLCSimulator) isTerminated() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.terminate
+}
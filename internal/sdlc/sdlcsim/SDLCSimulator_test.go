@@ -0,0 +1,97 @@
+package sdlcsim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/internal/sdlc/uartsdlc"
+	"rvpro3/radarvision.com/utils"
+)
+
+func TestSDLCSimulator_Handle(t *testing.T) {
+	sim := NewSDLCSimulator("Test.SDLC.Simulator")
+	encoder := uartsdlc.SDLCRequestEncoder{}
+	decoder := uartsdlc.SDLCResponseDecoder{}
+
+	request, _ := encoder.StaticStatus()
+	assert.NoError(t, decoder.Init(sim.Handle(request)))
+	status, err := decoder.GetStaticStatus()
+	assert.NoError(t, err)
+	assert.Equal(t, sim.StaticStatus.Serial, status.Serial)
+	assert.Equal(t, uartsdlc.BIUFlags(0x0f), status.BIU)
+
+	sim.SDLCDiagnostics.CRCError = 3
+	request, _ = encoder.Diagnostics(1)
+	assert.NoError(t, decoder.Init(sim.Handle(request)))
+	diagnostics, err := decoder.GetSDLCDiagnostics()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, diagnostics.CRCError)
+	assert.Equal(t, 0, sim.SDLCDiagnostics.CRCError)
+
+	request, _ = encoder.TS2Detect(0x8001)
+	assert.NoError(t, decoder.Init(sim.Handle(request)))
+	assert.Equal(t, uartsdlc.AcknowledgeResponseCode, decoder.GetIdentifier())
+
+	request, _ = encoder.ConfigBIU(0x03)
+	assert.NoError(t, decoder.Init(sim.Handle(request)))
+	assert.Equal(t, uartsdlc.BIUFlags(0x03), sim.BIUConfig)
+
+	detects, _ := sim.GetDetects()
+	assert.Equal(t, uint64(0x8001), detects)
+	assert.Equal(t, 1, len(sim.GetDetectRecords()))
+	assert.Nil(t, sim.Handle([]byte{0x02, 0x03}))
+}
+
+func awaitCondition(condition func() bool) bool {
+	for range 50 {
+		if condition() {
+			return true
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return false
+}
+
+// TestSDLCSimulator_Executor runs the SDLCService and SDLCExecutorService
+// against the simulator (in process)
+func TestSDLCSimulator_Executor(t *testing.T) {
+	sim := NewSDLCSimulator("Test.SDLC.Simulator.Executor")
+	sim.IsBroadcast = true
+	utils.GlobalState.Delete(uartsdlc.SDLCServiceName)
+	utils.GlobalState.Delete(uartsdlc.SDLCExecutorServiceStateName)
+
+	settings := &utils.Settings{}
+	settings.Init()
+	settings.Basic.Set("feature.sdlc.uart.csv.enabled", "false")
+
+	sdlcService := new(uartsdlc.SDLCService)
+	sdlcService.InitFromSettings(settings)
+	sdlcService.Serial.OpenPort = sim.Open
+	sdlcService.Start(&utils.GlobalState, settings)
+
+	executor := new(uartsdlc.SDLCExecutorService)
+	executor.InitFromSettings(settings)
+	executor.Start(&utils.GlobalState, settings)
+
+	defer func() {
+		executor.Terminate = true
+		sdlcService.Stop()
+		sim.Stop()
+	}()
+
+	staticStatus := utils.GlobalState.Get(uartsdlc.SDLCStaticStatusStateName).(*uartsdlc.StaticStatus)
+	assert.True(t, awaitCondition(func() bool { return staticStatus.Serial == sim.StaticStatus.Serial }))
+
+	dateTime := utils.GlobalState.Get(uartsdlc.SDLCDateTimeStateName).(*uartsdlc.DateTimeState)
+	assert.True(t, awaitCondition(func() bool { return !dateTime.Get().CabinetTime.IsZero() }))
+
+	encoder := uartsdlc.SDLCRequestEncoder{}
+	request, _ := encoder.TS2Detect(0x0102)
+	sdlcService.Write(request)
+
+	assert.True(t, awaitCondition(func() bool {
+		detects, _ := sim.GetDetects()
+		return detects == 0x0102
+	}))
+}
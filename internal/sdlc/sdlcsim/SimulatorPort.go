@@ -0,0 +1,154 @@
+package sdlcsim
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+// byteQueue is a unidirectional in-process byte stream with a read timeout,
+// a timed out read returns 0 bytes (as the serial ports do)
+type byteQueue struct {
+	chunks   chan []byte
+	pending  []byte
+	timeout  atomic.Int64
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+func newByteQueue() *byteQueue {
+	queue := &byteQueue{
+		chunks: make(chan []byte, 64),
+		done:   make(chan struct{}),
+	}
+	queue.timeout.Store(int64(serial.NoTimeout))
+	return queue
+}
+
+func (q *byteQueue) Read(buffer []byte) (int, error) {
+	if len(q.pending) == 0 {
+		var timeout <-chan time.Time
+
+		if timeoutAfter := time.Duration(q.timeout.Load()); timeoutAfter >= 0 {
+			timer := time.NewTimer(timeoutAfter)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+
+		select {
+		case q.pending = <-q.chunks:
+		case <-timeout:
+			return 0, nil
+		case <-q.done:
+			return 0, io.EOF
+		}
+	}
+
+	size := copy(buffer, q.pending)
+	q.pending = q.pending[size:]
+	return size, nil
+}
+
+func (q *byteQueue) Write(data []byte) (int, error) {
+	select {
+	case <-q.done:
+		return 0, io.ErrClosedPipe
+	case q.chunks <- append([]byte(nil), data...):
+		return len(data), nil
+	}
+}
+
+func (q *byteQueue) Close() {
+	q.doneOnce.Do(func() { close(q.done) })
+}
+
+// SimulatorPort is an in-process serial.Port connected to the simulator,
+// use Open as the uartsdlc.SerialConnection OpenPort
+type SimulatorPort struct {
+	toSimulator *byteQueue
+	toHost      *byteQueue
+}
+
+// simulatorSide is the simulator end of the SimulatorPort
+type simulatorSide struct {
+	port *SimulatorPort
+}
+
+func (s simulatorSide) Read(buffer []byte) (int, error) {
+	return s.port.toSimulator.Read(buffer)
+}
+
+func (s simulatorSide) Write(data []byte) (int, error) {
+	return s.port.toHost.Write(data)
+}
+
+// Open creates a SimulatorPort and serves the simulator on it, the port is
+// closed when the serial connection disconnects
+func (s *SDLCSimulator) Open(_ string, _ *serial.Mode) (serial.Port, error) {
+	port := &SimulatorPort{
+		toSimulator: newByteQueue(),
+		toHost:      newByteQueue(),
+	}
+	port.toSimulator.timeout.Store(int64(100 * time.Millisecond))
+
+	go func() {
+		_ = s.Serve(simulatorSide{port: port})
+		port.toHost.Close()
+	}()
+
+	return port, nil
+}
+
+func (p *SimulatorPort) SetMode(*serial.Mode) error {
+	return nil
+}
+
+func (p *SimulatorPort) Read(buffer []byte) (int, error) {
+	return p.toHost.Read(buffer)
+}
+
+func (p *SimulatorPort) Write(data []byte) (int, error) {
+	return p.toSimulator.Write(data)
+}
+
+func (p *SimulatorPort) Drain() error {
+	return nil
+}
+
+func (p *SimulatorPort) ResetInputBuffer() error {
+	return nil
+}
+
+func (p *SimulatorPort) ResetOutputBuffer() error {
+	return nil
+}
+
+func (p *SimulatorPort) SetDTR(bool) error {
+	return nil
+}
+
+func (p *SimulatorPort) SetRTS(bool) error {
+	return nil
+}
+
+func (p *SimulatorPort) GetModemStatusBits() (*serial.ModemStatusBits, error) {
+	return &serial.ModemStatusBits{CTS: true, DSR: true, DCD: true}, nil
+}
+
+func (p *SimulatorPort) SetReadTimeout(timeout time.Duration) error {
+	p.toHost.timeout.Store(int64(timeout))
+	return nil
+}
+
+func (p *SimulatorPort) Close() error {
+	p.toSimulator.Close()
+	p.toHost.Close()
+	return nil
+}
+
+func (p *SimulatorPort) Break(time.Duration) error {
+	return nil
+}
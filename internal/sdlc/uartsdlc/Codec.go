@@ -74,7 +74,8 @@ func (codec) appendCRC(data []byte) ([]byte, error) {
 // original rawData; the slice is equal or smaller than the length of the
 // rawData.
 func (codec) Decode(buffer []byte) (res []byte, err error) {
-	if len(buffer) <= 5 {
+	// start marker, identifier, CRC16 and end marker
+	if len(buffer) < 5 {
 		return nil, ErrSDLCInvalidBuffer
 	}
 
@@ -103,7 +104,7 @@ func (codec) Decode(buffer []byte) (res []byte, err error) {
 }
 
 func (codec) DecodeInto(source []byte, target []byte) (res []byte, err error) {
-	if len(source) <= 5 {
+	if len(source) < 5 {
 		return nil, ErrSDLCInvalidBuffer
 	}
 
@@ -18,6 +18,23 @@ func TestDecode(t *testing.T) {
 	cycle(t, "02117D230000000000000068DA03")
 }
 
+// A request without data, e.g. the static status request, is framed in 5
+// bytes: the start marker, the identifier, the CRC16 and the end marker
+func TestDecode_IdentifierOnly(t *testing.T) {
+	cycle(t, "0210F3C103")
+
+	source, err := hex.DecodeString("0210f3c103")
+	utils.Debug.Panic(err)
+
+	var target [16]byte
+	res, err := Codec.DecodeInto(source, target[:])
+	assert.NoError(t, err)
+	assert.Equal(t, source, res)
+
+	_, err = Codec.Decode(source[:4])
+	assert.ErrorIs(t, err, ErrSDLCInvalidBuffer)
+}
+
 func decode(source string) {
 	buffer, err := hex.DecodeString(source)
 	utils.Debug.Panic(err)
@@ -3,43 +3,111 @@ package uartsdlc
 import (
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"rvpro3/radarvision.com/internal/general"
+	"rvpro3/radarvision.com/internal/smartmicro/interfaces"
 	"rvpro3/radarvision.com/utils"
 )
 
 const SDLCStaticStatusStateName = "SDLC.StaticStatus"
+const SDLCDynamicStatusStateName = "SDLC.DynamicStatus"
+const SDLCCMUFrameStateName = "SDLC.CMUFrame"
+const SDLCDateTimeStateName = "SDLC.DateTime"
+const SDLCBIUDiagnosticsStateName = "SDLC.BIUDiagnostics"
+const SDLCDiagnosticsStateName = "SDLC.SDLCDiagnostics"
+const SDLCSIUDiagnosticsStateName = "SDLC.SIUDiagnostics"
 const SDLCExecutorServiceStateName = "SDLC.Executor.Service"
 const sdlcUARTStaticStatusRequestEvery = "sdlcexec.uart.staticrequest.every"
+const sdlcUARTDynamicStatusRequestEvery = "sdlcexec.uart.dynamicrequest.every"
+const sdlcUARTDiagnosticsRequestEvery = "sdlcexec.uart.diagnosticrequest.every"
+const sdlcUARTBIUDiagnosticsRequestEvery = "sdlcexec.uart.biudiagnosticrequest.every"
+const sdlcUARTSIUDiagnosticsRequestEvery = "sdlcexec.uart.siudiagnosticrequest.every"
+const sdlcUARTCMUPhaseStateEnabled = "sdlcexec.uart.cmu.phasestate.enabled"
+
+// SDLCPhaseSource is the PhaseState source when set from the CMU frames
+const SDLCPhaseSource = "SDLC.CMU"
 
 type sdlcExecutorSettings struct{}
 
+// SDLCExecutorService polls the SDLC (static status, dynamic status and the
+// diagnostics) each on its own schedule, where a schedule of 0 disables the
+// request.  By default only the static status is polled.  The decoded
+// responses are published under utils.GlobalState.  The CMU frames, when
+// enabled, also update the interfaces.PhaseState
 type SDLCExecutorService struct {
-	Metronome                utils.Metronome
-	Terminate                bool
-	Terminated               bool
-	sdlcService              *SDLCService
-	Now                      time.Time
-	StaticRequestOn          time.Time
-	StaticRequestInterval    time.Duration
-	StaticStatus             *StaticStatus
-	Metrics                  SDLCExecutorServiceMetrics
-	StaticStatusRequestEvery utils.Milliseconds
+	Metronome                  utils.Metronome
+	Terminate                  bool
+	Terminated                 bool
+	sdlcService                *SDLCService
+	Now                        time.Time
+	StaticRequestOn            time.Time
+	StaticRequestInterval      time.Duration
+	DynamicRequestOn           time.Time
+	DiagnosticsRequestOn       time.Time
+	BIUDiagnosticsRequestOn    time.Time
+	SIUDiagnosticsRequestOn    time.Time
+	StaticStatus               *StaticStatus
+	DynamicStatus              *DynamicStatus
+	CMUFrame                   *CMUFrame
+	DateTime                   *DateTimeState
+	BIUDiagnostics             *BIUDiagnostics
+	SDLCDiagnostics            *SDLCDiagnostics
+	SIUDiagnostics             *SIUDiagnostics
+	Metrics                    SDLCExecutorServiceMetrics
+	StaticStatusRequestEvery   utils.Milliseconds
+	DynamicStatusRequestEvery  utils.Milliseconds
+	DiagnosticsRequestEvery    utils.Milliseconds
+	BIUDiagnosticsRequestEvery utils.Milliseconds
+	SIUDiagnosticsRequestEvery utils.Milliseconds
+	IsCMUPhaseState            bool
+	OnResponse                 func(*SDLCExecutorService, SDLCIdentifier, any) `json:"-"`
 }
 
 type SDLCExecutorServiceMetrics struct {
-	DecodeErrCount        *utils.Metric
-	DecodeErrBytes        *utils.Metric
-	StaticStatusRequests  *utils.Metric
-	StaticStatusResponses *utils.Metric
+	DecodeErrCount          *utils.Metric
+	DecodeErrBytes          *utils.Metric
+	ResponseErrCount        *utils.Metric
+	UnknownResponses        *utils.Metric
+	StaticStatusRequests    *utils.Metric
+	StaticStatusResponses   *utils.Metric
+	DynamicStatusRequests   *utils.Metric
+	DynamicStatusResponses  *utils.Metric
+	DiagnosticsRequests     *utils.Metric
+	DiagnosticsResponses    *utils.Metric
+	BIUDiagnosticsRequests  *utils.Metric
+	BIUDiagnosticsResponses *utils.Metric
+	SIUDiagnosticsRequests  *utils.Metric
+	SIUDiagnosticsResponses *utils.Metric
+	CMUFrames               *utils.Metric
+	ClockBroadcasts         *utils.Metric
+	Acknowledges            *utils.Metric
+	RequestErrCount         *utils.Metric
 	utils.MetricsInitMixin
 }
 
 func (s *SDLCExecutorService) InitFromSettings(settings *utils.Settings) {
 	s.StaticStatusRequestEvery = settings.Basic.GetMilliseconds(
 		sdlcUARTStaticStatusRequestEvery,
-		5000,
+		10000,
+	)
+	s.DynamicStatusRequestEvery = settings.Basic.GetMilliseconds(
+		sdlcUARTDynamicStatusRequestEvery,
+		0,
+	)
+	s.DiagnosticsRequestEvery = settings.Basic.GetMilliseconds(
+		sdlcUARTDiagnosticsRequestEvery,
+		0,
+	)
+	s.BIUDiagnosticsRequestEvery = settings.Basic.GetMilliseconds(
+		sdlcUARTBIUDiagnosticsRequestEvery,
+		0,
 	)
+	s.SIUDiagnosticsRequestEvery = settings.Basic.GetMilliseconds(
+		sdlcUARTSIUDiagnosticsRequestEvery,
+		0,
+	)
+	s.IsCMUPhaseState = settings.Basic.GetBool(sdlcUARTCMUPhaseStateEnabled, false)
 }
 
 func (s *SDLCExecutorService) init() {
@@ -49,14 +117,24 @@ func (s *SDLCExecutorService) init() {
 	s.Metronome.CycleDuration = 100 * time.Millisecond
 	s.Metronome.IsReal = false
 	s.sdlcService = utils.GlobalState.Get(SDLCServiceName).(*SDLCService)
-	s.StaticRequestInterval = time.Duration(10) * time.Second
+	s.StaticRequestInterval = time.Duration(s.StaticStatusRequestEvery)
 
 	if s.sdlcService == nil {
 		panic("SDLC service is not running")
 	}
 
 	s.sdlcService.OnReadMessage = s.OnReadMessage
-	s.StaticStatus = utils.GlobalState.Set(SDLCStaticStatusStateName, new(StaticStatus)).(*StaticStatus)
+	s.initState(&utils.GlobalState)
+}
+
+func (s *SDLCExecutorService) initState(state *utils.State) {
+	s.StaticStatus = state.Set(SDLCStaticStatusStateName, new(StaticStatus)).(*StaticStatus)
+	s.DynamicStatus = state.Set(SDLCDynamicStatusStateName, new(DynamicStatus)).(*DynamicStatus)
+	s.CMUFrame = state.Set(SDLCCMUFrameStateName, new(CMUFrame)).(*CMUFrame)
+	s.DateTime = state.Set(SDLCDateTimeStateName, new(DateTimeState)).(*DateTimeState)
+	s.BIUDiagnostics = state.Set(SDLCBIUDiagnosticsStateName, new(BIUDiagnostics)).(*BIUDiagnostics)
+	s.SDLCDiagnostics = state.Set(SDLCDiagnosticsStateName, new(SDLCDiagnostics)).(*SDLCDiagnostics)
+	s.SIUDiagnostics = state.Set(SDLCSIUDiagnosticsStateName, new(SIUDiagnostics)).(*SIUDiagnostics)
 }
 
 func (s *SDLCExecutorService) Start(state *utils.State, settings *utils.Settings) {
@@ -78,6 +156,7 @@ func (s *SDLCExecutorService) run() {
 	for !s.Terminate {
 		s.Now = time.Now()
 		s.doStaticStatusRequest()
+		s.doPolledRequests()
 
 		s.Metronome.AwaitClick()
 	}
@@ -105,20 +184,150 @@ func (s *SDLCExecutorService) doStaticStatusRequest() {
 	s.StaticRequestOn = s.Now
 }
 
+func (s *SDLCExecutorService) doPolledRequests() {
+	s.doRequest(
+		s.DynamicStatusRequestEvery,
+		&s.DynamicRequestOn,
+		s.Metrics.DynamicStatusRequests,
+		func(encoder *SDLCRequestEncoder) ([]byte, error) { return encoder.DynamicStatus() },
+	)
+	s.doRequest(
+		s.DiagnosticsRequestEvery,
+		&s.DiagnosticsRequestOn,
+		s.Metrics.DiagnosticsRequests,
+		func(encoder *SDLCRequestEncoder) ([]byte, error) { return encoder.Diagnostics(0) },
+	)
+	s.doRequest(
+		s.BIUDiagnosticsRequestEvery,
+		&s.BIUDiagnosticsRequestOn,
+		s.Metrics.BIUDiagnosticsRequests,
+		func(encoder *SDLCRequestEncoder) ([]byte, error) { return encoder.BIUDiagnostics(0) },
+	)
+	s.doRequest(
+		s.SIUDiagnosticsRequestEvery,
+		&s.SIUDiagnosticsRequestOn,
+		s.Metrics.SIUDiagnosticsRequests,
+		func(encoder *SDLCRequestEncoder) ([]byte, error) { return encoder.SIUDiagnostics(0) },
+	)
+}
+
+// doRequest writes the request when the schedule expired, an every of 0
+// disables the request
+func (s *SDLCExecutorService) doRequest(
+	every utils.Milliseconds,
+	requestOn *time.Time,
+	metric *utils.Metric,
+	encode func(*SDLCRequestEncoder) ([]byte, error),
+) {
+	if every <= 0 || !every.Expired(s.Now, *requestOn) {
+		return
+	}
+
+	*requestOn = s.Now
+	encoder := SDLCRequestEncoder{}
+	data, err := encode(&encoder)
+
+	if err != nil {
+		s.Metrics.RequestErrCount.IncAt(1, s.Now)
+		log.Err(err).Msg("SDLCExecutorService.doRequest")
+		return
+	}
+
+	metric.IncAt(1, s.Now)
+	s.sdlcService.Write(data)
+}
+
 func (s *SDLCExecutorService) OnReadMessage(_ *SDLCService, data []byte) {
 	decoder := SDLCResponseDecoder{}
+	now := time.Now()
 
 	if err := decoder.Init(data); err != nil {
-		now := time.Now()
 		s.Metrics.DecodeErrCount.IncAt(1, now)
 		s.Metrics.DecodeErrBytes.IncAt(int64(len(data)), now)
 		return
 	}
 
-	switch decoder.GetIdentifier() {
-	case StaticStatusResponseCode:
+	if decoder.GetIdentifier() == StaticStatusResponseCode {
 		s.onStaticResponse(&decoder)
+		return
 	}
+
+	response, err := decoder.Decode()
+	if err != nil {
+		if errors.Is(err, ErrSDLCUnknownIdentifier) {
+			s.Metrics.UnknownResponses.IncAt(1, now)
+		} else {
+			s.Metrics.ResponseErrCount.IncAt(1, now)
+			log.Err(err).Msg("SDLCExecutorService.OnReadMessage")
+		}
+		return
+	}
+
+	s.onResponse(now, decoder.GetIdentifier(), response)
+}
+
+// onResponse publishes the decoded response, the published state is
+// overwritten in place so that the state pointers remain valid
+func (s *SDLCExecutorService) onResponse(now time.Time, identifier SDLCIdentifier, response any) {
+	switch value := response.(type) {
+	case DynamicStatus:
+		value.UpdateOn = now
+		*s.DynamicStatus = value
+		s.Metrics.DynamicStatusResponses.IncAt(1, now)
+
+	case CMUFrame:
+		value.UpdateOn = now
+		*s.CMUFrame = value
+		s.Metrics.CMUFrames.IncAt(1, now)
+		s.updatePhaseState(&value)
+
+	case DateTimeStream:
+		value.UpdateOn = now
+		s.DateTime.Set(value)
+		s.Metrics.ClockBroadcasts.IncAt(1, now)
+
+	case BIUDiagnostics:
+		value.UpdateOn = now
+		*s.BIUDiagnostics = value
+		s.Metrics.BIUDiagnosticsResponses.IncAt(1, now)
+
+	case SDLCDiagnostics:
+		value.UpdateOn = now
+		*s.SDLCDiagnostics = value
+		s.Metrics.DiagnosticsResponses.IncAt(1, now)
+
+	case SIUDiagnostics:
+		value.UpdateOn = now
+		*s.SIUDiagnostics = value
+		s.Metrics.SIUDiagnosticsResponses.IncAt(1, now)
+
+	case byte:
+		s.Metrics.Acknowledges.IncAt(1, now)
+	}
+
+	if s.OnResponse != nil {
+		s.OnResponse(s, identifier, response)
+	}
+}
+
+// updatePhaseState maps the CMU channels onto the phases, where green,
+// yellow and red of channel N is phase N
+func (s *SDLCExecutorService) updatePhaseState(frame *CMUFrame) {
+	if !s.IsCMUPhaseState {
+		return
+	}
+
+	phases, ok := utils.GlobalState.Get(interfaces.PhaseStateName).(interfaces.IPhaseState)
+	if !ok {
+		return
+	}
+
+	phases.SetRYG(
+		SDLCPhaseSource,
+		utils.Uint64(frame.Red),
+		utils.Uint64(frame.Yellow),
+		utils.Uint64(frame.Green),
+	)
 }
 
 func (s *SDLCExecutorService) onStaticResponse(decoder *SDLCResponseDecoder) {
@@ -2,9 +2,50 @@ package uartsdlc
 
 import (
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/internal/smartmicro/interfaces"
+	"rvpro3/radarvision.com/utils"
 )
 
 func TestSdlcExecutorSettings_Setup(t *testing.T) {
 	//exec := SDLCExecutorSettings.SetupNew(&utils.GlobalSettings)
 	//fmt.Println(exec)
 }
+
+func TestSDLCExecutorService_OnReadMessage(t *testing.T) {
+	settings := &utils.Settings{}
+	settings.Init()
+
+	phases := new(interfaces.PhaseState)
+	utils.GlobalState.Set(interfaces.PhaseStateName, phases)
+
+	executor := new(SDLCExecutorService)
+	executor.InitFromSettings(settings)
+	assert.Equal(t, utils.Milliseconds(10*time.Second), executor.StaticStatusRequestEvery)
+	assert.Equal(t, utils.Milliseconds(0), executor.DynamicStatusRequestEvery, "polled when configured")
+	assert.False(t, executor.IsCMUPhaseState, "the phases are only taken from the CMU when configured")
+	executor.IsCMUPhaseState = true
+
+	executor.Metrics.InitMetrics("Test.SDLC.Executor", &executor.Metrics)
+	executor.initState(&utils.GlobalState)
+
+	executor.OnReadMessage(nil, encodeResponse(DynamicStatusResponseCode, 1, 2, 3, 4, 5, 6))
+	executor.OnReadMessage(nil, encodeResponse(CMUFrameStreamCode, 0x00, 0x00, 0x05, 0x00, 0x02, 0xff, 0xf8))
+	executor.OnReadMessage(nil, encodeResponse(SDLCDiagnosticResponseCode, 1, 2, 3, 4, 5, 130))
+
+	dynamicStatus := utils.GlobalState.Get(SDLCDynamicStatusStateName).(*DynamicStatus)
+	assert.Equal(t, 5, dynamicStatus.SdlcFailCount)
+	assert.Equal(t, 6, dynamicStatus.UartFailCount)
+	assert.False(t, dynamicStatus.UpdateOn.IsZero())
+
+	diagnostics := utils.GlobalState.Get(SDLCDiagnosticsStateName).(*SDLCDiagnostics)
+	assert.Equal(t, 384, diagnostics.LongFrameError)
+
+	assert.Equal(t, SDLCPhaseSource, phases.Source)
+	assert.Equal(t, utils.Uint64(0x05), phases.PhaseGreen)
+	assert.Equal(t, utils.Uint64(0x02), phases.PhaseYellow)
+	assert.Equal(t, utils.Uint64(0xfff8), phases.PhaseRed)
+	assert.Equal(t, int64(1), executor.Metrics.CMUFrames.Value)
+}
@@ -0,0 +1,70 @@
+package uartsdlc
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+	"rvpro3/radarvision.com/utils"
+)
+
+// SDLCRequestDecoder is the counterpart of SDLCRequestEncoder, decoding the
+// requests as received by the BIU/controller (e.g. when simulating)
+type SDLCRequestDecoder struct {
+	rawData [256]byte
+	slice   []byte
+	offset  int
+}
+
+func (s *SDLCRequestDecoder) Init(buffer []byte) (err error) {
+	if s.slice, err = Codec.DecodeInto(buffer, s.rawData[:]); err != nil {
+		return err
+	}
+
+	// SDLCRequestEncoder.ConfigBIU prefixes the identifier with the data length
+	s.offset = 1
+	if len(s.slice) > 6 && s.slice[1] == 1 && SDLCIdentifier(s.slice[2]) == ConfigBIURequestCode {
+		s.offset = 2
+	}
+	return nil
+}
+
+func (s *SDLCRequestDecoder) GetIdentifier() SDLCIdentifier {
+	return SDLCIdentifier(s.slice[s.offset])
+}
+
+func (s *SDLCRequestDecoder) dataReader(minLen int) (utils.FixedBuffer, error) {
+	dataStart := s.offset + 1
+	dataLen := len(s.slice) - dataStart - 3
+
+	if dataLen < minLen {
+		return utils.FixedBuffer{}, errors.Wrapf(ErrSDLCDataLength, "%s: %d < %d", s.GetIdentifier(), dataLen, minLen)
+	}
+
+	return utils.FixedBuffer{Buffer: s.slice, WritePos: dataStart + dataLen, ReadPos: dataStart}, nil
+}
+
+// GetTS2Detect returns the detector bits (see SDLCRequestEncoder.TS2Detect)
+func (s *SDLCRequestDecoder) GetTS2Detect() (uint64, error) {
+	fb, err := s.dataReader(8)
+	if err != nil {
+		return 0, err
+	}
+	return fb.ReadU64(binary.LittleEndian), fb.Err
+}
+
+func (s *SDLCRequestDecoder) GetConfigBIU() (BIUFlags, error) {
+	fb, err := s.dataReader(1)
+	if err != nil {
+		return 0, err
+	}
+	return BIUFlags(fb.ReadU8()), fb.Err
+}
+
+// GetReset returns the reset byte of the diagnostics requests
+func (s *SDLCRequestDecoder) GetReset() (byte, error) {
+	fb, err := s.dataReader(1)
+	if err != nil {
+		return 0, err
+	}
+	return fb.ReadU8(), fb.Err
+}
@@ -6,11 +6,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/pkg/errors"
 	"rvpro3/radarvision.com/utils"
 )
 
+var ErrSDLCDataLength = errors.New("SDLC response data too short")
+var ErrSDLCUnknownIdentifier = errors.New("SDLC unknown response identifier")
+
 type StaticStatusMode byte
 
 func (s StaticStatusMode) IsATC() bool {
@@ -62,6 +67,7 @@ type SDLCResponseDecoder struct {
 }
 
 type BIUDiagnostics struct {
+	UpdateOn                 time.Time
 	MMULoadSwitchCounter     byte
 	DateTimeBroadcastCounter byte
 	CallDataRequestCounter   [4]byte
@@ -92,6 +98,7 @@ func (d BIUDiagnostics) PrintDetail() {
 }
 
 type SIUDiagnostics struct {
+	UpdateOn                 time.Time
 	StatusCounter            byte
 	MillisecondCounter       byte
 	InputConfigCounter       byte
@@ -103,7 +110,61 @@ type SIUDiagnostics struct {
 	ModuleDescriptionCounter byte
 }
 
+func (d SIUDiagnostics) PrintDetail() {
+	utils.Print.Detail("SIU Diagnostics Response", "\n")
+	utils.Print.SetIndent(2)
+	utils.Print.Detail("Status", "%d\n", d.StatusCounter)
+	utils.Print.Detail("Millisecond", "%d\n", d.MillisecondCounter)
+	utils.Print.Detail("Input Config", "%d\n", d.InputConfigCounter)
+	utils.Print.Detail("Poll Raw", "%d\n", d.PollRawCounter)
+	utils.Print.Detail("Poll Filtered", "%d\n", d.PollFilteredCounter)
+	utils.Print.Detail("Transition Buffer", "%d\n", d.TransitionBufferCounter)
+	utils.Print.Detail("Module ID", "%d\n", d.ModuleIDCounter)
+	utils.Print.Detail("Time Date", "%d\n", d.TimeDateCounter)
+	utils.Print.Detail("Module Description", "%d\n", d.ModuleDescriptionCounter)
+	utils.Print.SetIndent(-2)
+}
+
+// DateTimeStream is the cabinet date/time broadcast, UpdateOn is the local
+// time at which the broadcast was received
+type DateTimeStream struct {
+	UpdateOn    time.Time
+	CabinetTime time.Time
+}
+
+// DateTimeState holds the last DateTimeStream in the state, written by the
+// SDLCExecutorService and read by the SDLCTimeSourceService
+type DateTimeState struct {
+	lock  sync.Mutex
+	value DateTimeStream
+}
+
+func (d *DateTimeState) Set(value DateTimeStream) {
+	d.lock.Lock()
+	d.value = value
+	d.lock.Unlock()
+}
+
+// Get returns a copy of the last DateTimeStream
+func (d *DateTimeState) Get() DateTimeStream {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.value
+}
+
+func (d *DateTimeState) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Get())
+}
+
+func (d DateTimeStream) PrintDetail() {
+	utils.Print.Detail("Date Time Stream", "\n")
+	utils.Print.SetIndent(2)
+	utils.Print.Detail("Cabinet Time", "%s\n", d.CabinetTime.Format(utils.DisplayDateTimeMS))
+	utils.Print.SetIndent(-2)
+}
+
 type DynamicStatus struct {
+	UpdateOn           time.Time
 	SinceLastSDLCComms int
 	RequestedCount     byte
 	UptimeInDays       byte
@@ -122,10 +183,12 @@ func (s DynamicStatus) PrintDetail() {
 	utils.Print.Detail("Since Last COMMs", "%d\n", s.SinceLastSDLCComms)
 	utils.Print.Detail("Uptime (Days)", "%d\n", s.UptimeInDays)
 	utils.Print.Detail("Uptime (mins)", "%d\n", s.UptimeIn6Mins)
+	utils.Print.Detail("Failsafe Mapped", "%t\n", s.IsFailSafeMapped)
 	utils.Print.SetIndent(-2)
 }
 
 type SDLCDiagnostics struct {
+	UpdateOn        time.Time
 	ShortFrameError int
 	ControlError    int
 	CRCError        int
@@ -137,6 +200,7 @@ type SDLCDiagnostics struct {
 func (d SDLCDiagnostics) PrintDetail() {
 	utils.Print.Detail("SDLC Diagnostics Response", "\n")
 	utils.Print.SetIndent(2)
+	utils.Print.Detail("Short-Frame Error", "%d\n", d.ShortFrameError)
 	utils.Print.Detail("Control Error", "%d\n", d.ControlError)
 	utils.Print.Detail("CRC Error", "%d\n", d.CRCError)
 	utils.Print.Detail("IdleError", "%d\n", d.IdleError)
@@ -178,7 +242,10 @@ func (s *StaticStatus) PrintDetail() {
 	utils.Print.SetIndent(-2)
 }
 
+// CMUFrame is the channel state of the conflict monitor, where channel N
+// (1-based) is bit N-1.  TS2 reports 16 channels, ATC 32
 type CMUFrame struct {
+	UpdateOn time.Time
 	Mode     StaticStatusMode
 	Green    uint32
 	Yellow   uint32
@@ -191,7 +258,35 @@ func (c *CMUFrame) String() string {
 		return fmt.Sprintf("Mode: %s, Green: %04x, Yellow: %04x, Red: %04x", c.Mode, c.Green, c.Yellow, c.Red)
 	}
 	return fmt.Sprintf("Mode: %s, Green: %08x, Yellow: %08x, Red: %08x", c.Mode, c.Green, c.Yellow, c.Red)
+}
+
+func (c *CMUFrame) PrintDetail() {
+	utils.Print.Detail("CMU Frame", "\n")
+	utils.Print.SetIndent(2)
+	utils.Print.Detail("Mode", "%s\n", c.Mode.String())
+	utils.Print.Detail("Green", "%0*b\n", c.BitCount, c.Green)
+	utils.Print.Detail("Yellow", "%0*b\n", c.BitCount, c.Yellow)
+	utils.Print.Detail("Red", "%0*b\n", c.BitCount, c.Red)
+	utils.Print.SetIndent(-2)
+}
 
+func (c *CMUFrame) IsGreen(channel int) bool {
+	return c.isSet(c.Green, channel)
+}
+
+func (c *CMUFrame) IsYellow(channel int) bool {
+	return c.isSet(c.Yellow, channel)
+}
+
+func (c *CMUFrame) IsRed(channel int) bool {
+	return c.isSet(c.Red, channel)
+}
+
+func (c *CMUFrame) isSet(value uint32, channel int) bool {
+	if channel < 1 || channel > c.BitCount {
+		return false
+	}
+	return value&(1<<(channel-1)) != 0
 }
 
 func (s *SDLCResponseDecoder) Init(buffer []byte) (err error) {
@@ -203,8 +298,49 @@ func (s *SDLCResponseDecoder) GetIdentifier() SDLCIdentifier {
 	return SDLCIdentifier(s.slice[1])
 }
 
+// Decode returns the typed response (StaticStatus, DynamicStatus,
+// CMUFrame, DateTimeStream, BIUDiagnostics, SDLCDiagnostics, SIUDiagnostics
+// or the acknowledged byte) according to the identifier
+func (s *SDLCResponseDecoder) Decode() (any, error) {
+	switch s.GetIdentifier() {
+	case StaticStatusResponseCode:
+		return s.GetStaticStatus()
+	case DynamicStatusResponseCode:
+		return s.GetDynamicStatus()
+	case CMUFrameStreamCode:
+		return s.GetCMUFrame()
+	case DateTimeStreamCode:
+		return s.GetDateTimeStream()
+	case BIUDiagnosticResponseCode:
+		return s.GetBIUDiagnostics()
+	case SDLCDiagnosticResponseCode:
+		return s.GetSDLCDiagnostics()
+	case SIUDiagnosticResponseCode:
+		return s.GetSIUDiagnostics()
+	case AcknowledgeResponseCode:
+		return s.GetAcknowledge()
+	default:
+		return nil, errors.Wrapf(ErrSDLCUnknownIdentifier, "identifier 0x%02x", uint8(s.GetIdentifier()))
+	}
+}
+
+// dataReader returns a reader over the data part (excluding the CRC and
+// end marker) provided the data is at least minLen bytes
+func (s *SDLCResponseDecoder) dataReader(minLen int) (utils.FixedBuffer, error) {
+	dataLen := Codec.GetDataLen(s.slice)
+
+	if dataLen < minLen {
+		return utils.FixedBuffer{}, errors.Wrapf(ErrSDLCDataLength, "%s: %d < %d", s.GetIdentifier(), dataLen, minLen)
+	}
+
+	return utils.FixedBuffer{Buffer: s.slice, WritePos: 2 + dataLen, ReadPos: 2}, nil
+}
+
 func (s *SDLCResponseDecoder) GetBIUDiagnostics() (BIUDiagnostics, error) {
-	fb := utils.FixedBuffer{Buffer: s.slice, WritePos: len(s.slice), ReadPos: 2}
+	fb, err := s.dataReader(12)
+	if err != nil {
+		return BIUDiagnostics{}, err
+	}
 
 	res := BIUDiagnostics{}
 	res.MMULoadSwitchCounter = fb.ReadU8()
@@ -224,11 +360,18 @@ func (s *SDLCResponseDecoder) GetBIUDiagnostics() (BIUDiagnostics, error) {
 }
 
 func (s *SDLCResponseDecoder) GetCMUFrame() (CMUFrame, error) {
-	fb := utils.FixedBuffer{Buffer: s.slice, WritePos: len(s.slice), ReadPos: 2}
+	fb, err := s.dataReader(7)
+	if err != nil {
+		return CMUFrame{}, err
+	}
 
 	res := CMUFrame{}
 	res.Mode = StaticStatusMode(fb.ReadU8())
 
+	if !res.Mode.IsTS2() && Codec.GetDataLen(s.slice) < 13 {
+		return res, errors.Wrap(ErrSDLCDataLength, "ATC CMU frame")
+	}
+
 	if res.Mode.IsTS2() {
 		res.Green = uint32(fb.ReadU16(binary.BigEndian))
 		res.Yellow = uint32(fb.ReadU16(binary.BigEndian))
@@ -250,7 +393,10 @@ func (s *SDLCResponseDecoder) GetAcknowledge() (byte, error) {
 }
 
 func (s *SDLCResponseDecoder) GetDateTime() (time.Time, error) {
-	fb := utils.FixedBuffer{Buffer: s.slice, WritePos: len(s.slice), ReadPos: 2}
+	fb, err := s.dataReader(6)
+	if err != nil {
+		return time.Time{}, err
+	}
 
 	return time.Date(
 		2000+int(fb.ReadU8()),
@@ -264,8 +410,16 @@ func (s *SDLCResponseDecoder) GetDateTime() (time.Time, error) {
 	), fb.Err
 }
 
+func (s *SDLCResponseDecoder) GetDateTimeStream() (DateTimeStream, error) {
+	cabinetTime, err := s.GetDateTime()
+	return DateTimeStream{CabinetTime: cabinetTime}, err
+}
+
 func (s *SDLCResponseDecoder) GetDynamicStatus() (DynamicStatus, error) {
-	fb := utils.FixedBuffer{Buffer: s.slice, WritePos: len(s.slice), ReadPos: 2}
+	fb, err := s.dataReader(4)
+	if err != nil {
+		return DynamicStatus{}, err
+	}
 
 	res := DynamicStatus{}
 	dataLen := Codec.GetDataLen(s.slice)
@@ -293,7 +447,10 @@ func (s *SDLCResponseDecoder) GetDynamicStatus() (DynamicStatus, error) {
 }
 
 func (s *SDLCResponseDecoder) GetSDLCDiagnostics() (SDLCDiagnostics, error) {
-	fb := utils.FixedBuffer{Buffer: s.slice, WritePos: len(s.slice), ReadPos: 2}
+	fb, err := s.dataReader(6)
+	if err != nil {
+		return SDLCDiagnostics{}, err
+	}
 	return SDLCDiagnostics{
 		ShortFrameError: calcCount(fb.ReadU8()),
 		ControlError:    calcCount(fb.ReadU8()),
@@ -312,7 +469,10 @@ func calcCount(count byte) int {
 }
 
 func (s *SDLCResponseDecoder) GetSIUDiagnostics() (SIUDiagnostics, error) {
-	fb := utils.FixedBuffer{Buffer: s.slice, WritePos: len(s.slice), ReadPos: 2}
+	fb, err := s.dataReader(9)
+	if err != nil {
+		return SIUDiagnostics{}, err
+	}
 	return SIUDiagnostics{
 		StatusCounter:            fb.ReadU8(),
 		MillisecondCounter:       fb.ReadU8(),
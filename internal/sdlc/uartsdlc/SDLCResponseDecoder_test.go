@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"rvpro3/radarvision.com/utils"
@@ -55,3 +56,45 @@ func TestSDLCRequestEncoder_TS2Detect(t *testing.T) {
 	encode(math.MaxUint64, "0211ffffffffffffffff064e03")
 	encode(0, "02110000000000000000a0af03")
 }
+
+func encodeResponse(identifier SDLCIdentifier, data ...byte) []byte {
+	buffer := make([]byte, 0, 64)
+	buffer = append(buffer, startMarker, byte(identifier))
+	buffer = append(buffer, data...)
+
+	res, err := Codec.Encode(buffer)
+	utils.Debug.Panic(err)
+	return res
+}
+
+func TestSDLCResponseDecoder_Decode(t *testing.T) {
+	decoder := SDLCResponseDecoder{}
+
+	utils.Debug.Panic(decoder.Init(encodeResponse(SIUDiagnosticResponseCode, 1, 2, 3, 4, 5, 6, 7, 8, 9)))
+	response, err := decoder.Decode()
+	assert.NoError(t, err)
+	assert.Equal(t, byte(9), response.(SIUDiagnostics).ModuleDescriptionCounter)
+
+	utils.Debug.Panic(decoder.Init(encodeResponse(DateTimeStreamCode, 24, 10, 2, 17, 59, 57)))
+	response, err = decoder.Decode()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 10, 2, 17, 59, 57, 0, time.Local), response.(DateTimeStream).CabinetTime)
+
+	utils.Debug.Panic(decoder.Init(encodeResponse(CMUFrameStreamCode, 0x00, 0x00, 0x05, 0x00, 0x02, 0xff, 0xf8)))
+	response, err = decoder.Decode()
+	assert.NoError(t, err)
+	cmuFrame := response.(CMUFrame)
+	assert.True(t, cmuFrame.IsGreen(1))
+	assert.True(t, cmuFrame.IsGreen(3))
+	assert.True(t, cmuFrame.IsYellow(2))
+	assert.False(t, cmuFrame.IsRed(3))
+	assert.False(t, cmuFrame.IsRed(17))
+
+	utils.Debug.Panic(decoder.Init(encodeResponse(SDLCDiagnosticResponseCode, 1, 2)))
+	_, err = decoder.Decode()
+	assert.ErrorIs(t, err, ErrSDLCDataLength)
+
+	utils.Debug.Panic(decoder.Init(encodeResponse(0x4A, 1)))
+	_, err = decoder.Decode()
+	assert.ErrorIs(t, err, ErrSDLCUnknownIdentifier)
+}
@@ -0,0 +1,124 @@
+package uartsdlc
+
+import (
+	"encoding/binary"
+	"time"
+
+	"rvpro3/radarvision.com/utils"
+)
+
+// SDLCResponseEncoder is the counterpart of SDLCResponseDecoder, encoding
+// the responses as sent by the BIU/controller (e.g. when simulating)
+type SDLCResponseEncoder struct {
+	buffer [64]byte
+}
+
+func (s *SDLCResponseEncoder) start(identifier SDLCIdentifier) utils.FixedBuffer {
+	fb := utils.FixedBuffer{Buffer: s.buffer[:]}
+	fb.WriteU8(startMarker)
+	fb.WriteU8(uint8(identifier))
+	return fb
+}
+
+func (s *SDLCResponseEncoder) StaticStatus(status *StaticStatus) ([]byte, error) {
+	fb := s.start(StaticStatusResponseCode)
+	fb.WriteU8(uint8(status.BIU))
+	fb.WriteU8(status.MajorVersion)
+	fb.WriteU8(status.MinorVersion)
+	fb.WriteU64(status.Serial, binary.LittleEndian)
+	fb.WriteU8(status.ProtocolVersion)
+	fb.WriteU8(uint8(status.Mode))
+	return Codec.Encode(fb.AsWriteSlice())
+}
+
+// DynamicStatus encodes the UART fail count, unless the failsafe is mapped
+func (s *SDLCResponseEncoder) DynamicStatus(status *DynamicStatus) ([]byte, error) {
+	fb := s.start(DynamicStatusResponseCode)
+	fb.WriteU8(uint8(status.SinceLastSDLCComms / 10))
+	fb.WriteU8(status.RequestedCount)
+	fb.WriteU8(status.UptimeInDays)
+	fb.WriteU8(uint8(status.UptimeIn6Mins / 6))
+	fb.WriteU8(uint8(status.SdlcFailCount))
+
+	if !status.IsFailSafeMapped {
+		fb.WriteU8(uint8(status.UartFailCount))
+	}
+	return Codec.Encode(fb.AsWriteSlice())
+}
+
+func (s *SDLCResponseEncoder) CMUFrame(frame *CMUFrame) ([]byte, error) {
+	fb := s.start(CMUFrameStreamCode)
+	fb.WriteU8(uint8(frame.Mode))
+
+	if frame.Mode.IsTS2() {
+		fb.WriteU16(uint16(frame.Green), binary.BigEndian)
+		fb.WriteU16(uint16(frame.Yellow), binary.BigEndian)
+		fb.WriteU16(uint16(frame.Red), binary.BigEndian)
+	} else {
+		fb.WriteU32(frame.Green, binary.BigEndian)
+		fb.WriteU32(frame.Yellow, binary.BigEndian)
+		fb.WriteU32(frame.Red, binary.BigEndian)
+	}
+	return Codec.Encode(fb.AsWriteSlice())
+}
+
+func (s *SDLCResponseEncoder) DateTime(cabinetTime time.Time) ([]byte, error) {
+	fb := s.start(DateTimeStreamCode)
+	fb.WriteU8(uint8(cabinetTime.Year() - 2000))
+	fb.WriteU8(uint8(cabinetTime.Month()))
+	fb.WriteU8(uint8(cabinetTime.Day()))
+	fb.WriteU8(uint8(cabinetTime.Hour()))
+	fb.WriteU8(uint8(cabinetTime.Minute()))
+	fb.WriteU8(uint8(cabinetTime.Second()))
+	return Codec.Encode(fb.AsWriteSlice())
+}
+
+func (s *SDLCResponseEncoder) BIUDiagnostics(diagnostics *BIUDiagnostics) ([]byte, error) {
+	fb := s.start(BIUDiagnosticResponseCode)
+	fb.WriteU8(diagnostics.MMULoadSwitchCounter)
+	fb.WriteU8(diagnostics.DateTimeBroadcastCounter)
+	fb.WriteBytes(diagnostics.CallDataRequestCounter[:])
+	fb.WriteBytes(diagnostics.ResetDiagnosticCounter[:])
+	fb.WriteU8(diagnostics.ServiceRequestCounter)
+	fb.WriteU8(diagnostics.ReservedCounter)
+	return Codec.Encode(fb.AsWriteSlice())
+}
+
+func (s *SDLCResponseEncoder) SDLCDiagnostics(diagnostics *SDLCDiagnostics) ([]byte, error) {
+	fb := s.start(SDLCDiagnosticResponseCode)
+	fb.WriteU8(toCount(diagnostics.ShortFrameError))
+	fb.WriteU8(toCount(diagnostics.ControlError))
+	fb.WriteU8(toCount(diagnostics.CRCError))
+	fb.WriteU8(toCount(diagnostics.IdleError))
+	fb.WriteU8(toCount(diagnostics.FramingError))
+	fb.WriteU8(toCount(diagnostics.LongFrameError))
+	return Codec.Encode(fb.AsWriteSlice())
+}
+
+func (s *SDLCResponseEncoder) SIUDiagnostics(diagnostics *SIUDiagnostics) ([]byte, error) {
+	fb := s.start(SIUDiagnosticResponseCode)
+	fb.WriteU8(diagnostics.StatusCounter)
+	fb.WriteU8(diagnostics.MillisecondCounter)
+	fb.WriteU8(diagnostics.InputConfigCounter)
+	fb.WriteU8(diagnostics.PollRawCounter)
+	fb.WriteU8(diagnostics.PollFilteredCounter)
+	fb.WriteU8(diagnostics.TransitionBufferCounter)
+	fb.WriteU8(diagnostics.ModuleIDCounter)
+	fb.WriteU8(diagnostics.TimeDateCounter)
+	fb.WriteU8(diagnostics.ModuleDescriptionCounter)
+	return Codec.Encode(fb.AsWriteSlice())
+}
+
+func (s *SDLCResponseEncoder) Acknowledge(value byte) ([]byte, error) {
+	fb := s.start(AcknowledgeResponseCode)
+	fb.WriteU8(value)
+	return Codec.Encode(fb.AsWriteSlice())
+}
+
+// toCount is the inverse of calcCount, counts above 128 lose precision
+func toCount(count int) byte {
+	if count < 129 {
+		return byte(max(count, 0))
+	}
+	return byte(min(count/128+127, 255))
+}
@@ -113,6 +113,7 @@ func (s *SDLCService) GetServiceNames() []string {
 }
 
 func (s *SDLCService) init() {
+	s.Metrics.InitMetrics(s.GetServiceName(), &s.Metrics)
 	s.RetrySleepDuration = time.Duration(1) * time.Second
 	s.WritePool = NewSDLCWritePool()
 	s.Serial.RetryGuard.RetryEvery = 3
@@ -255,7 +256,7 @@ func (s *SDLCService) logMessage(data []byte, action string, omitMetric *utils.M
 			return
 		}
 
-		writer.WriteCol(now.Format(utils.DisplayDateTimeMS))
+		writer.WriteCol(utils.Time.Correct(now).Format(utils.DisplayDateTimeMS))
 		writer.WriteCol(action)
 		writer.WriteColNL(hex.EncodeToString(data))
 	}
@@ -283,7 +284,7 @@ func (s *SDLCService) logError(errObj error) {
 			return
 		}
 
-		writer.WriteCol(now.Format(utils.DisplayDateTimeMS))
+		writer.WriteCol(utils.Time.Correct(now).Format(utils.DisplayDateTimeMS))
 		writer.WriteCol(errorAction)
 		writer.WriteColNL(errObj.Error())
 	}
@@ -0,0 +1,202 @@
+package uartsdlc
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"rvpro3/radarvision.com/internal/general"
+	"rvpro3/radarvision.com/utils"
+)
+
+const SDLCTimeSourceServiceName = "SDLC.TimeSource.Service"
+const sdlcTimeWindowSize = "sdlc.time.window.size"
+const sdlcTimeCheckEvery = "sdlc.time.check.every"
+const sdlcTimeCorrectEnabled = "sdlc.time.correct.enabled"
+const sdlcTimeAuthoritative = "sdlc.time.authoritative"
+const sdlcTimeSetThreshold = "sdlc.time.set.threshold"
+const sdlcTimeZone = "sdlc.time.zone"
+
+// SDLCTimeSourceService tracks the offset and drift of the cabinet controller
+// clock (SDLC DateTime broadcast) against the system clock.  The broadcast
+// has a resolution of a second, and is received some time after the second
+// started, meaning that every sample (cabinet - received) underestimates the
+// offset.  The estimate is therefore the maximum sample within a window.
+//
+// The broadcast carries the wall clock of the cabinet without a zone, it is
+// read in the Location of the cabinet (the system zone by default).
+//
+// When correcting (off by default), the estimate is applied to utils.Time,
+// the corrected clock used by the CSV writers and the wrong way case records.
+// The metrics keep the system clock, their offset is the OffsetMillis metric.
+// When the controller is authoritative, the system clock is set once the
+// offset exceeds the threshold
+type SDLCTimeSourceService struct {
+	Metronome       utils.Metronome
+	Terminate       bool
+	Terminated      bool
+	WindowSize      int
+	CheckEvery      utils.Milliseconds
+	SetThreshold    utils.Milliseconds
+	IsCorrecting    bool
+	IsAuthoritative bool
+	Location        *time.Location `json:"-"`
+	Offset          time.Duration
+	DriftPPM        float64
+	EstimateOn      time.Time
+	IsEstimated     bool
+	windowMax       time.Duration
+	windowCount     int
+	lastUpdateOn    time.Time
+	dateTime        *DateTimeState
+	SetClock        func(time.Time) error `json:"-"`
+	Metrics         SDLCTimeSourceServiceMetrics
+}
+
+type SDLCTimeSourceServiceMetrics struct {
+	Samples        *utils.Metric
+	InvalidSamples *utils.Metric
+	Estimates      *utils.Metric
+	OffsetMillis   *utils.Metric `metric:"gauge"`
+	DriftPPM       *utils.Metric `metric:"gauge"`
+	ClockSetCount  *utils.Metric
+	ClockSetErr    *utils.Metric
+	utils.MetricsInitMixin
+}
+
+func (s *SDLCTimeSourceService) InitFromSettings(settings *utils.Settings) {
+	s.WindowSize = settings.Basic.GetInt(sdlcTimeWindowSize, 60)
+	s.CheckEvery = settings.Basic.GetMilliseconds(sdlcTimeCheckEvery, 250)
+	s.SetThreshold = settings.Basic.GetMilliseconds(sdlcTimeSetThreshold, 2000)
+	s.IsCorrecting = settings.Basic.GetBool(sdlcTimeCorrectEnabled, false)
+	s.IsAuthoritative = settings.Basic.GetBool(sdlcTimeAuthoritative, false)
+
+	zone := settings.Basic.Get(sdlcTimeZone, "Local")
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		log.Err(err).Str("zone", zone).Msg("SDLCTimeSourceService.InitFromSettings")
+		location = time.Local
+	}
+	s.Location = location
+}
+
+func (s *SDLCTimeSourceService) Start(state *utils.State, settings *utils.Settings) {
+	if !general.ServiceHelper.ShouldStart(state, settings, s) {
+		return
+	}
+
+	s.init()
+	s.dateTime, _ = state.GetOrSet(SDLCDateTimeStateName, new(DateTimeState)).(*DateTimeState)
+	go s.run()
+}
+
+func (s *SDLCTimeSourceService) GetServiceName() string {
+	return SDLCTimeSourceServiceName
+}
+
+func (s *SDLCTimeSourceService) init() {
+	s.Metrics.InitMetrics(s.GetServiceName(), &s.Metrics)
+	s.Metronome.CycleDuration = time.Duration(s.CheckEvery)
+
+	if s.WindowSize < 1 {
+		s.WindowSize = 1
+	}
+
+	if s.SetClock == nil {
+		s.SetClock = SystemClock.Set
+	}
+
+	if s.Location == nil {
+		s.Location = time.Local
+	}
+}
+
+func (s *SDLCTimeSourceService) run() {
+	s.Metronome.Start()
+
+	for !s.Terminate {
+		if s.dateTime != nil {
+			// A copy, the executor writes the DateTime concurrently
+			sample := s.dateTime.Get()
+
+			if sample.UpdateOn != s.lastUpdateOn {
+				s.lastUpdateOn = sample.UpdateOn
+				s.AddSample(sample.CabinetTime, sample.UpdateOn)
+			}
+		}
+
+		s.Metronome.AwaitClick()
+	}
+	s.Terminated = true
+}
+
+// AddSample adds the cabinet time as received on the system time
+func (s *SDLCTimeSourceService) AddSample(cabinetTime time.Time, receivedOn time.Time) {
+	if cabinetTime.IsZero() || receivedOn.IsZero() {
+		s.Metrics.InvalidSamples.IncAt(1, receivedOn)
+		return
+	}
+
+	s.Metrics.Samples.IncAt(1, receivedOn)
+	sample := s.inLocation(cabinetTime).Sub(receivedOn)
+
+	if s.windowCount == 0 || sample > s.windowMax {
+		s.windowMax = sample
+	}
+	s.windowCount++
+
+	if s.windowCount >= s.WindowSize {
+		s.estimate(receivedOn, s.windowMax)
+		s.windowCount = 0
+	}
+}
+
+// inLocation reads the wall clock of the cabinet time in the Location of the
+// cabinet
+func (s *SDLCTimeSourceService) inLocation(cabinetTime time.Time) time.Time {
+	year, month, day := cabinetTime.Date()
+	hour, minute, second := cabinetTime.Clock()
+	return time.Date(year, month, day, hour, minute, second, cabinetTime.Nanosecond(), s.Location)
+}
+
+func (s *SDLCTimeSourceService) estimate(now time.Time, offset time.Duration) {
+	if s.IsEstimated {
+		elapsed := now.Sub(s.EstimateOn)
+		if elapsed > 0 {
+			s.DriftPPM = float64(offset-s.Offset) / float64(elapsed) * 1e6
+		}
+	}
+
+	s.Offset = offset
+	s.EstimateOn = now
+	s.IsEstimated = true
+
+	s.Metrics.Estimates.IncAt(1, now)
+	s.Metrics.OffsetMillis.SetAt(offset.Milliseconds(), now)
+	s.Metrics.DriftPPM.SetAt(int64(s.DriftPPM), now)
+
+	if s.IsAuthoritative && offset.Abs() > time.Duration(s.SetThreshold) {
+		s.setSystemClock(now, offset)
+		return
+	}
+
+	if s.IsCorrecting {
+		utils.Time.SetClockOffset(offset)
+	}
+}
+
+// setSystemClock sets the system clock to the cabinet time.  The system
+// clock then matches the cabinet, meaning the offset is reset
+func (s *SDLCTimeSourceService) setSystemClock(now time.Time, offset time.Duration) {
+	if err := s.SetClock(time.Now().Add(offset)); err != nil {
+		s.Metrics.ClockSetErr.IncAt(1, now)
+		log.Err(err).Msg("SDLCTimeSourceService.setSystemClock")
+		return
+	}
+
+	s.Metrics.ClockSetCount.IncAt(1, now)
+	log.Info().Dur("offset", offset).Msg("System clock set from the cabinet time")
+
+	s.Offset = 0
+	s.IsEstimated = false
+	utils.Time.SetClockOffset(0)
+}
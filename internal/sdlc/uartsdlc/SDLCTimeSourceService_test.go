@@ -0,0 +1,93 @@
+package uartsdlc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/utils"
+)
+
+func newTestTimeSource(windowSize int) *SDLCTimeSourceService {
+	settings := &utils.Settings{}
+	settings.Init()
+
+	service := new(SDLCTimeSourceService)
+	service.InitFromSettings(settings)
+	service.IsCorrecting = true
+	service.WindowSize = windowSize
+	service.init()
+	return service
+}
+
+func TestSDLCTimeSourceService_AddSample(t *testing.T) {
+	defer utils.Time.SetClockOffset(0)
+
+	service := newTestTimeSource(4)
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.Local)
+
+	// The cabinet is 3 seconds ahead, received 100..400ms after the second
+	for index := 0; index < 4; index++ {
+		cabinet := start.Add(time.Duration(index+3) * time.Second)
+		received := start.Add(time.Duration(index)*time.Second + time.Duration(100*(4-index))*time.Millisecond)
+		service.AddSample(cabinet, received)
+	}
+
+	assert.True(t, service.IsEstimated)
+	assert.Equal(t, 2900*time.Millisecond, service.Offset)
+	assert.Equal(t, 2900*time.Millisecond, utils.Time.GetClockOffset())
+
+	// The cabinet gains 10ms over the next (about) 4 seconds
+	for index := 4; index < 8; index++ {
+		cabinet := start.Add(time.Duration(index+3) * time.Second)
+		received := start.Add(time.Duration(index)*time.Second + 90*time.Millisecond)
+		service.AddSample(cabinet, received)
+	}
+
+	assert.Equal(t, 2910*time.Millisecond, service.Offset)
+	assert.InDelta(t, 2500, service.DriftPPM, 10)
+	assert.Equal(t, int64(2910), service.Metrics.OffsetMillis.Value)
+}
+
+func TestSDLCTimeSourceService_Location(t *testing.T) {
+	defer utils.Time.SetClockOffset(0)
+
+	settings := &utils.Settings{}
+	settings.Init()
+	settings.Basic.Set(sdlcTimeZone, "America/New_York")
+
+	service := new(SDLCTimeSourceService)
+	service.InitFromSettings(settings)
+	service.WindowSize = 1
+	service.init()
+	assert.False(t, service.IsCorrecting, "only corrected when configured")
+
+	// The cabinet broadcasts 07:00 in New York, received at 12:00 UTC
+	received := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	service.AddSample(time.Date(2026, 1, 1, 7, 0, 1, 0, time.Local), received)
+
+	assert.Equal(t, time.Second, service.Offset)
+	assert.Equal(t, time.Duration(0), utils.Time.GetClockOffset())
+}
+
+func TestSDLCTimeSourceService_Authoritative(t *testing.T) {
+	defer utils.Time.SetClockOffset(0)
+
+	var setTo time.Time
+	service := newTestTimeSource(1)
+	service.IsAuthoritative = true
+	service.SetClock = func(now time.Time) error {
+		setTo = now
+		return nil
+	}
+
+	now := time.Now()
+	service.AddSample(now.Add(time.Second), now)
+	assert.True(t, setTo.IsZero())
+	assert.Equal(t, time.Second, utils.Time.GetClockOffset())
+
+	service.AddSample(now.Add(time.Hour), now)
+	assert.False(t, setTo.IsZero())
+	assert.Equal(t, time.Duration(0), utils.Time.GetClockOffset())
+	assert.Equal(t, int64(1), service.Metrics.ClockSetCount.Value)
+}
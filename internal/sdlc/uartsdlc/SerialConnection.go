@@ -23,6 +23,10 @@ type SerialConnection struct {
 	OnError      func(*SerialConnection, error)  `json:"-"`
 	OnWrote      func(*SerialConnection, []byte) `json:"-"`
 	OnRead       func(*SerialConnection, []byte) `json:"-"`
+
+	// OpenPort opens the port (default serial.Open), allowing the connection
+	// to be made to a simulated port
+	OpenPort func(string, *serial.Mode) (serial.Port, error) `json:"-"`
 }
 
 func (s *SerialConnection) Init(
@@ -52,7 +56,11 @@ func (s *SerialConnection) Connect() bool {
 		return false
 	}
 
-	if s.connection, err = serial.Open(s.PortName, &s.Mode); err != nil {
+	if s.OpenPort == nil {
+		s.OpenPort = serial.Open
+	}
+
+	if s.connection, err = s.OpenPort(s.PortName, &s.Mode); err != nil {
 		goto errorLabel
 	}
 
@@ -69,6 +77,11 @@ errorLabel:
 	return false
 }
 
+// IsConnected returns whether the port is open
+func (s *SerialConnection) IsConnected() bool {
+	return s.connection != nil
+}
+
 func (s *SerialConnection) Read(buffer []byte) int {
 	var bytesRead int
 	var err error
@@ -0,0 +1,6 @@
+package uartsdlc
+
+type systemClock struct{}
+
+// SystemClock sets the system (wall) clock, this requires CAP_SYS_TIME
+var SystemClock systemClock
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package uartsdlc
+
+import (
+	"syscall"
+	"time"
+)
+
+func (systemClock) Set(now time.Time) error {
+	tv := syscall.NsecToTimeval(now.UnixNano())
+	return syscall.Settimeofday(&tv)
+}
@@ -0,0 +1,13 @@
+//go:build windows
+
+package uartsdlc
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func (systemClock) Set(time.Time) error {
+	return errors.New("setting the system clock is not supported")
+}
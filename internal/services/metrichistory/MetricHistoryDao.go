@@ -0,0 +1,212 @@
+package metrichistory
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"rvpro3/radarvision.com/utils"
+)
+
+type metricHistoryDao struct{}
+
+var MetricHistoryDao metricHistoryDao
+
+// MetricSampleRec is a metric as it was at the snapshot, the times are unix
+// milliseconds.  Detail holds the histogram (json) of a histogram metric
+type MetricSampleRec struct {
+	Id         int64  `json:"-"`
+	SnapshotOn int64  `json:"SnapshotOn"`
+	Section    string `json:"Section"`
+	Metric     string `json:"Metric"`
+	Type       string `json:"Type"`
+	Value      int64  `json:"Value"`
+	FirstOn    int64  `json:"FirstOn"`
+	LastOn     int64  `json:"LastOn"`
+	Detail     string `json:"Detail,omitempty"`
+}
+
+func (metricHistoryDao) CreateTables(db *sql.DB) (err error) {
+	s := `CREATE TABLE IF NOT EXISTS metric_sample (
+	id INTEGER NOT NULL PRIMARY KEY,
+	snapshot_on INTEGER NOT NULL,
+	section TEXT NOT NULL,
+	metric TEXT NOT NULL,
+	type TEXT NOT NULL,
+	value INTEGER NOT NULL,
+	first_on INTEGER NOT NULL,
+	last_on INTEGER NOT NULL,
+	detail TEXT NOT NULL
+)`
+	if _, err = db.Exec(s); err != nil {
+		return err
+	}
+
+	s = `CREATE INDEX IF NOT EXISTS metric_sample_ndx ON metric_sample (section, metric, snapshot_on)`
+	if _, err = db.Exec(s); err != nil {
+		return err
+	}
+
+	s = `CREATE INDEX IF NOT EXISTS metric_sample_on_ndx ON metric_sample (snapshot_on)`
+	if _, err = db.Exec(s); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (metricHistoryDao) DropTables(db *sql.DB) (err error) {
+	if _, err = db.Exec(`DROP TABLE IF EXISTS metric_sample`); err != nil {
+		return err
+	}
+
+	if _, err = db.Exec(`DROP INDEX IF EXISTS metric_sample_ndx`); err != nil {
+		return err
+	}
+
+	if _, err = db.Exec(`DROP INDEX IF EXISTS metric_sample_on_ndx`); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SampleOf captures the metric of the section at the snapshot
+func (metricHistoryDao) SampleOf(snapshotOn int64, section string, metric *utils.Metric) *MetricSampleRec {
+	res := &MetricSampleRec{
+		SnapshotOn: snapshotOn,
+		Section:    section,
+		Metric:     metric.Name,
+		Value:      metric.Value,
+		FirstOn:    metric.FirstOn,
+		LastOn:     metric.LastOn,
+	}
+
+	if metricType, err := metric.Type.MarshalJSON(); err == nil {
+		res.Type, _ = strconv.Unquote(string(metricType))
+	}
+
+	if metric.Histogram != nil {
+		if detail, err := json.Marshal(metric.Histogram); err == nil {
+			res.Detail = string(detail)
+		}
+	}
+	return res
+}
+
+// InsertSamples inserts the samples of a snapshot in a single transaction
+func (metricHistoryDao) InsertSamples(db *sql.DB, recs []*MetricSampleRec) (err error) {
+	var tx *sql.Tx
+	var stmt *sql.Stmt
+
+	if tx, err = db.Begin(); err != nil {
+		return err
+	}
+
+	qry := `
+INSERT INTO metric_sample
+    (snapshot_on, section, metric, type, value, first_on, last_on, detail)
+VALUES
+    (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	if stmt, err = tx.Prepare(qry); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, rec := range recs {
+		var res sql.Result
+
+		res, err = stmt.Exec(rec.SnapshotOn, rec.Section, rec.Metric, rec.Type, rec.Value, rec.FirstOn, rec.LastOn, rec.Detail)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+
+		if rec.Id, err = res.LastInsertId(); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteBefore removes the samples of the snapshots before the time (unix
+// milliseconds), and returns the number of samples removed
+func (metricHistoryDao) DeleteBefore(db *sql.DB, snapshotOn int64) (int64, error) {
+	res, err := db.Exec(`DELETE FROM metric_sample WHERE snapshot_on<?`, snapshotOn)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// SelectSeries returns the samples of the metric between from and to
+// (inclusive, unix milliseconds).  As only changed metrics are stored, the
+// series starts with the latest sample before from (if any)
+func (metricHistoryDao) SelectSeries(
+	db *sql.DB,
+	section string,
+	metric string,
+	from int64,
+	to int64,
+) (recs []*MetricSampleRec, err error) {
+	qry := `
+SELECT * FROM (
+	SELECT
+		id, snapshot_on, section, metric, type, value, first_on, last_on, detail
+	FROM metric_sample
+	WHERE
+		section=? AND metric=? AND snapshot_on<?
+	ORDER BY snapshot_on DESC
+	LIMIT 1
+)
+UNION ALL
+SELECT * FROM (
+	SELECT
+		id, snapshot_on, section, metric, type, value, first_on, last_on, detail
+	FROM metric_sample
+	WHERE
+		section=? AND metric=? AND snapshot_on>=? AND snapshot_on<=?
+)
+ORDER BY snapshot_on`
+
+	var rows *sql.Rows
+	rows, err = db.Query(qry, section, metric, from, section, metric, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recs = make([]*MetricSampleRec, 0, 64)
+	for rows.Next() {
+		rec := new(MetricSampleRec)
+		err = rows.Scan(
+			&rec.Id,
+			&rec.SnapshotOn,
+			&rec.Section,
+			&rec.Metric,
+			&rec.Type,
+			&rec.Value,
+			&rec.FirstOn,
+			&rec.LastOn,
+			&rec.Detail,
+		)
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, rec)
+	}
+	return recs, rows.Err()
+}
+
+// FromTimeStr parses unix milliseconds or an RFC3339 time
+func (metricHistoryDao) FromTimeStr(value string) (time.Time, error) {
+	if milliseconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.UnixMilli(milliseconds), nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
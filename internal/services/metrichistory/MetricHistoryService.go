@@ -0,0 +1,159 @@
+package metrichistory
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"rvpro3/radarvision.com/internal/general"
+	"rvpro3/radarvision.com/internal/services/sqlitestore"
+	"rvpro3/radarvision.com/utils"
+)
+
+const MetricHistoryServiceName = "Metric.History.Service"
+const metricHistoryFile = "metrics.history.file"
+const metricHistoryEvery = "metrics.history.every"
+const metricHistoryRetentionHours = "metrics.history.retention.hours"
+const metricHistoryPurgeEvery = "metrics.history.purge.every"
+
+// MetricHistoryService snapshots the GlobalMetrics every Every into a SQLite
+// database, keeping RetentionHours of history across restarts.  A metric is
+// only stored when it changed since its previous snapshot
+type MetricHistoryService struct {
+	FileName       string
+	Every          utils.Milliseconds
+	PurgeEvery     utils.Milliseconds
+	RetentionHours int
+	Terminate      bool
+	Terminated     bool
+	Metrics        MetricHistoryServiceMetrics `json:"-"`
+	store          sqlitestore.Store
+	lastOn         map[string]int64
+}
+
+type MetricHistoryServiceMetrics struct {
+	SnapshotCount    *utils.Metric
+	SampleCount      *utils.Metric
+	PurgedCount      *utils.Metric
+	ErrCount         *utils.Metric
+	SnapshotDuration *utils.Metric
+	utils.MetricsInitMixin
+}
+
+func (s *MetricHistoryService) InitFromSettings(settings *utils.Settings) {
+	s.FileName = settings.Basic.Get(metricHistoryFile, "metrics.db")
+	s.Every = settings.Basic.GetMilliseconds(metricHistoryEvery, 60000)
+	s.PurgeEvery = settings.Basic.GetMilliseconds(metricHistoryPurgeEvery, 3600000)
+	s.RetentionHours = settings.Basic.GetInt(metricHistoryRetentionHours, 7*24)
+}
+
+func (s *MetricHistoryService) Start(state *utils.State, settings *utils.Settings) {
+	if !general.ServiceHelper.ShouldStart(state, settings, s) {
+		return
+	}
+
+	if err := s.Open(); err != nil {
+		log.Err(err).Str("file", s.FileName).Msg("MetricHistoryService.Start")
+		return
+	}
+
+	go s.run()
+}
+
+func (s *MetricHistoryService) GetServiceName() string {
+	return MetricHistoryServiceName
+}
+
+// Open opens (and creates) the database
+func (s *MetricHistoryService) Open() error {
+	s.Metrics.InitMetrics(s.GetServiceName(), &s.Metrics)
+	s.lastOn = make(map[string]int64, 256)
+
+	s.store.FileName = s.FileName
+	s.store.PurgeEvery = s.PurgeEvery
+	s.store.Retention = time.Duration(s.RetentionHours) * time.Hour
+	s.store.CreateTables = MetricHistoryDao.CreateTables
+	s.store.DeleteBefore = MetricHistoryDao.DeleteBefore
+
+	return s.store.Open()
+}
+
+// Close closes the database, the history can no longer be queried
+func (s *MetricHistoryService) Close() error {
+	return s.store.Close()
+}
+
+func (s *MetricHistoryService) run() {
+	for !s.Terminate {
+		s.Every.Sleep()
+
+		if err := s.Snapshot(time.Now()); err != nil {
+			log.Err(err).Msg("MetricHistoryService.run")
+		}
+	}
+
+	// The final snapshot keeps the metrics up to the shutdown
+	if err := s.Snapshot(time.Now()); err != nil {
+		log.Err(err).Msg("MetricHistoryService.run")
+	}
+
+	_ = s.Close()
+	s.Terminated = true
+}
+
+// Snapshot stores the metrics changed since the previous snapshot, and
+// purges the history beyond the retention every PurgeEvery
+func (s *MetricHistoryService) Snapshot(now time.Time) error {
+	err := s.store.Exec(func(db *sql.DB) error {
+		snapshotOn := now.UnixMilli()
+		recs := make([]*MetricSampleRec, 0, 256)
+
+		for _, section := range utils.GlobalMetrics.Sections() {
+			for _, metric := range section.List() {
+				if !metric.IsSet {
+					continue
+				}
+
+				lastOn, ok := s.lastOn[section.Name+"/"+metric.Name]
+				if ok && lastOn == metric.LastOn {
+					continue
+				}
+
+				recs = append(recs, MetricHistoryDao.SampleOf(snapshotOn, section.Name, metric))
+			}
+		}
+
+		if err := MetricHistoryDao.InsertSamples(db, recs); err != nil {
+			return err
+		}
+
+		// Only marked as stored once committed
+		for _, rec := range recs {
+			s.lastOn[rec.Section+"/"+rec.Metric] = rec.LastOn
+		}
+
+		s.Metrics.SnapshotCount.IncAt(1, now)
+		s.Metrics.SampleCount.IncAt(int64(len(recs)), now)
+		s.Metrics.SnapshotDuration.SetAt(time.Since(now).Milliseconds(), now)
+
+		purged, err := s.store.Purge(db, now)
+		if purged > 0 {
+			s.Metrics.PurgedCount.IncAt(purged, now)
+		}
+		return err
+	})
+
+	if err != nil && err != sqlitestore.ErrNotOpen {
+		s.Metrics.ErrCount.IncAt(1, now)
+	}
+	return err
+}
+
+// Series returns the samples of the metric between from and to
+func (s *MetricHistoryService) Series(section string, metric string, from time.Time, to time.Time) (recs []*MetricSampleRec, err error) {
+	err = s.store.Exec(func(db *sql.DB) error {
+		recs, err = MetricHistoryDao.SelectSeries(db, section, metric, from.UnixMilli(), to.UnixMilli())
+		return err
+	})
+	return recs, err
+}
@@ -0,0 +1,110 @@
+package metrichistory
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/utils"
+)
+
+func newTestHistory(t *testing.T) *MetricHistoryService {
+	res := &MetricHistoryService{
+		FileName:       filepath.Join(t.TempDir(), "metrics.db"),
+		PurgeEvery:     1000,
+		RetentionHours: 1,
+	}
+	assert.NoError(t, res.Open())
+	t.Cleanup(func() { _ = res.Close() })
+	return res
+}
+
+func TestMetricHistoryService_Snapshot(t *testing.T) {
+	history := newTestHistory(t)
+	section := "Metric.History.Test." + t.Name()
+
+	on := time.Now().Add(-10 * time.Minute)
+	errCount := utils.GlobalMetrics.Metric(section, "ReadErrCount", utils.MtI64)
+	unchanged := utils.GlobalMetrics.Metric(section, "ConnectCount", utils.MtI64)
+	utils.GlobalMetrics.Metric(section, "NeverSet", utils.MtI64)
+
+	unchanged.SetAt(1, on)
+
+	for minute := 0; minute < 5; minute++ {
+		snapshotOn := on.Add(time.Duration(minute) * time.Minute)
+		errCount.SetAt(int64(minute), snapshotOn)
+		assert.NoError(t, history.Snapshot(snapshotOn))
+	}
+
+	series, err := history.Series(section, "ReadErrCount", on.Add(2*time.Minute), on.Add(3*time.Minute))
+	assert.NoError(t, err)
+
+	// The sample before from, followed by the samples between from and to
+	if assert.Len(t, series, 3) {
+		assert.Equal(t, int64(1), series[0].Value)
+		assert.Equal(t, int64(2), series[1].Value)
+		assert.Equal(t, int64(3), series[2].Value)
+		assert.Equal(t, "GA", series[2].Type, "set metrics are gauges")
+		assert.Equal(t, on.Add(3*time.Minute).UnixMilli(), series[2].SnapshotOn)
+	}
+
+	// Unchanged metrics are stored once
+	series, err = history.Series(section, "ConnectCount", on, on.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, series, 1)
+
+	series, err = history.Series(section, "NeverSet", on, on.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, series)
+}
+
+func TestMetricHistoryService_Purge(t *testing.T) {
+	history := newTestHistory(t)
+	section := "Metric.History.Test." + t.Name()
+
+	on := time.Now().Add(-3 * time.Hour)
+	metric := utils.GlobalMetrics.Metric(section, "ReadErrCount", utils.MtI64)
+
+	metric.SetAt(1, on)
+	assert.NoError(t, history.Snapshot(on))
+
+	metric.SetAt(2, on.Add(2*time.Hour))
+	assert.NoError(t, history.Snapshot(on.Add(2*time.Hour)))
+
+	series, err := history.Series(section, "ReadErrCount", on, on.Add(3*time.Hour))
+	assert.NoError(t, err)
+	if assert.Len(t, series, 1) {
+		assert.Equal(t, int64(2), series[0].Value)
+	}
+}
+
+func TestMetricHistoryService_Histogram(t *testing.T) {
+	history := newTestHistory(t)
+	section := "Metric.History.Test." + t.Name()
+
+	on := time.Now()
+	metric := utils.GlobalMetrics.Metric(section, "FrameDurationHistogram", utils.MtHistogram).WithBounds(10)
+	metric.ObserveAt(5, on)
+	assert.NoError(t, history.Snapshot(on))
+
+	series, err := history.Series(section, "FrameDurationHistogram", on.Add(-time.Minute), on.Add(time.Minute))
+	assert.NoError(t, err)
+	if assert.Len(t, series, 1) {
+		assert.Equal(t, "HG", series[0].Type)
+		assert.JSONEq(t, `{"Bounds":[10],"Counts":[1,0],"Count":1,"Sum":5}`, series[0].Detail)
+	}
+}
+
+func TestMetricHistoryDao_FromTimeStr(t *testing.T) {
+	tm, err := MetricHistoryDao.FromTimeStr("1700000000500")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1700000000500), tm.UnixMilli())
+
+	tm, err = MetricHistoryDao.FromTimeStr("2026-01-02T03:04:05Z")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), tm.UTC())
+
+	_, err = MetricHistoryDao.FromTimeStr("yesterday")
+	assert.Error(t, err)
+}
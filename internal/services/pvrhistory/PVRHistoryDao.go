@@ -0,0 +1,192 @@
+package pvrhistory
+
+import (
+	"database/sql"
+	"strings"
+)
+
+type pvrHistoryDao struct{}
+
+var PVRHistoryDao pvrHistoryDao
+
+// PVREventRec is a vehicle reported by the per vehicle record of the radar,
+// On (unix milliseconds).  The speed is in the speed unit and the length in
+// the distance unit of the configuration, the heading in radians
+type PVREventRec struct {
+	Id       int64   `json:"-"`
+	RadarIP  string  `json:"RadarIP"`
+	On       int64   `json:"On"`
+	ObjectId int     `json:"ObjectId"`
+	Counter  int     `json:"Counter"`
+	Zone     int     `json:"Zone"`
+	Class    string  `json:"Class"`
+	Speed    float64 `json:"Speed"`
+	Heading  float64 `json:"Heading"`
+	Length   float64 `json:"Length"`
+}
+
+// PVRQuery selects the events between From and To (unix milliseconds,
+// inclusive) with a speed in the band [MinSpeed, MaxSpeed), the empty
+// RadarIP or Class, a negative Zone and a zero MaxSpeed select all
+type PVRQuery struct {
+	RadarIP  string
+	Zone     int
+	Class    string
+	MinSpeed float64
+	MaxSpeed float64
+	From     int64
+	To       int64
+	MaxRows  int
+}
+
+func (pvrHistoryDao) CreateTables(db *sql.DB) (err error) {
+	s := `CREATE TABLE IF NOT EXISTS pvr_event (
+	id INTEGER NOT NULL PRIMARY KEY,
+	radar_ip TEXT NOT NULL,
+	on_time INTEGER NOT NULL,
+	object_id INTEGER NOT NULL,
+	counter INTEGER NOT NULL,
+	zone INTEGER NOT NULL,
+	class TEXT NOT NULL,
+	speed REAL NOT NULL,
+	heading REAL NOT NULL,
+	length REAL NOT NULL
+)`
+	if _, err = db.Exec(s); err != nil {
+		return err
+	}
+
+	s = `CREATE INDEX IF NOT EXISTS pvr_event_ndx ON pvr_event (on_time, radar_ip)`
+	if _, err = db.Exec(s); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (pvrHistoryDao) DropTables(db *sql.DB) (err error) {
+	if _, err = db.Exec(`DROP TABLE IF EXISTS pvr_event`); err != nil {
+		return err
+	}
+
+	if _, err = db.Exec(`DROP INDEX IF EXISTS pvr_event_ndx`); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// InsertEvents inserts the events in a single transaction
+func (pvrHistoryDao) InsertEvents(db *sql.DB, recs []*PVREventRec) (err error) {
+	var tx *sql.Tx
+	var stmt *sql.Stmt
+
+	if tx, err = db.Begin(); err != nil {
+		return err
+	}
+
+	qry := `
+INSERT INTO pvr_event
+    (radar_ip, on_time, object_id, counter, zone, class, speed, heading, length)
+VALUES
+    (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	if stmt, err = tx.Prepare(qry); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, rec := range recs {
+		var res sql.Result
+
+		res, err = stmt.Exec(
+			rec.RadarIP, rec.On, rec.ObjectId, rec.Counter, rec.Zone, rec.Class,
+			rec.Speed, rec.Heading, rec.Length,
+		)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+
+		if rec.Id, err = res.LastInsertId(); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteBefore removes the events before the time (unix milliseconds), and
+// returns the number of events removed
+func (pvrHistoryDao) DeleteBefore(db *sql.DB, on int64) (int64, error) {
+	res, err := db.Exec(`DELETE FROM pvr_event WHERE on_time<?`, on)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// SelectEvents returns the events of the query by time and radar
+func (pvrHistoryDao) SelectEvents(db *sql.DB, query *PVRQuery) (recs []*PVREventRec, err error) {
+	where := []string{"on_time>=?", "on_time<=?", "speed>=?"}
+	args := []any{query.From, query.To, query.MinSpeed}
+
+	if query.MaxSpeed > 0 {
+		where = append(where, "speed<?")
+		args = append(args, query.MaxSpeed)
+	}
+
+	if query.RadarIP != "" {
+		where = append(where, "radar_ip=?")
+		args = append(args, query.RadarIP)
+	}
+
+	if query.Zone >= 0 {
+		where = append(where, "zone=?")
+		args = append(args, query.Zone)
+	}
+
+	if query.Class != "" {
+		where = append(where, "class=?")
+		args = append(args, query.Class)
+	}
+	args = append(args, query.MaxRows)
+
+	qry := `
+SELECT
+    id, radar_ip, on_time, object_id, counter, zone, class, speed, heading, length
+FROM pvr_event
+WHERE ` + strings.Join(where, " AND ") + `
+ORDER BY on_time, radar_ip, id
+LIMIT ?`
+
+	var rows *sql.Rows
+	if rows, err = db.Query(qry, args...); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recs = make([]*PVREventRec, 0, 64)
+	for rows.Next() {
+		rec := new(PVREventRec)
+		err = rows.Scan(
+			&rec.Id,
+			&rec.RadarIP,
+			&rec.On,
+			&rec.ObjectId,
+			&rec.Counter,
+			&rec.Zone,
+			&rec.Class,
+			&rec.Speed,
+			&rec.Heading,
+			&rec.Length,
+		)
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, rec)
+	}
+	return recs, rows.Err()
+}
@@ -0,0 +1,142 @@
+package pvrhistory
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"rvpro3/radarvision.com/internal/general"
+	"rvpro3/radarvision.com/internal/services/sqlitestore"
+	"rvpro3/radarvision.com/utils"
+)
+
+const PVRHistoryServiceName = "PVR.History.Service"
+const pvrHistoryFile = "pvr.history.file"
+const pvrHistoryRetentionDays = "pvr.history.retention.days"
+const pvrHistoryPurgeEvery = "pvr.history.purge.every"
+const pvrHistoryMaxRows = "pvr.history.max.rows"
+const pvrHistoryQueueSize = "pvr.history.queue.size"
+
+// PVRHistoryService stores the vehicle events of the radars into a SQLite
+// database, keeping RetentionDays of events across restarts.  The events are
+// queued by the PVR workflows, and queried by the web api
+type PVRHistoryService struct {
+	FileName      string
+	PurgeEvery    utils.Milliseconds
+	RetentionDays int
+	MaxRows       int
+	QueueSize     int
+	Metrics       PVRHistoryServiceMetrics `json:"-"`
+	store         sqlitestore.Store
+	queue         sqlitestore.InsertQueue[*PVREventRec]
+}
+
+type PVRHistoryServiceMetrics struct {
+	InsertCount *utils.Metric
+	EventCount  *utils.Metric
+	PurgedCount *utils.Metric
+	DropCount   *utils.Metric
+	ErrCount    *utils.Metric
+	utils.MetricsInitMixin
+}
+
+func (s *PVRHistoryService) InitFromSettings(settings *utils.Settings) {
+	s.FileName = settings.Basic.Get(pvrHistoryFile, "/media/SDLOGS/pvr/pvr.db")
+	s.PurgeEvery = settings.Basic.GetMilliseconds(pvrHistoryPurgeEvery, 3600000)
+	s.RetentionDays = settings.Basic.GetInt(pvrHistoryRetentionDays, 90)
+	s.MaxRows = settings.Basic.GetInt(pvrHistoryMaxRows, 10000)
+	s.QueueSize = settings.Basic.GetInt(pvrHistoryQueueSize, 256)
+}
+
+func (s *PVRHistoryService) Start(state *utils.State, settings *utils.Settings) {
+	if !general.ServiceHelper.ShouldStart(state, settings, s) {
+		return
+	}
+
+	if err := s.Open(); err != nil {
+		log.Err(err).Str("file", s.FileName).Msg("PVRHistoryService.Start")
+	}
+}
+
+func (s *PVRHistoryService) GetServiceName() string {
+	return PVRHistoryServiceName
+}
+
+// Open opens (and creates) the database, and starts the queue of the inserts
+func (s *PVRHistoryService) Open() error {
+	s.Metrics.InitMetrics(s.GetServiceName(), &s.Metrics)
+
+	s.store.FileName = s.FileName
+	s.store.PurgeEvery = s.PurgeEvery
+	s.store.Retention = time.Duration(s.RetentionDays) * 24 * time.Hour
+	s.store.CreateTables = PVRHistoryDao.CreateTables
+	s.store.DeleteBefore = PVRHistoryDao.DeleteBefore
+
+	if err := s.store.Open(); err != nil {
+		return err
+	}
+
+	s.queue.Size = s.QueueSize
+	s.queue.Insert = s.insertQueued
+	s.queue.Start()
+	return nil
+}
+
+// Close inserts the events queued, and closes the database
+func (s *PVRHistoryService) Close() error {
+	s.queue.Close()
+	return s.store.Close()
+}
+
+// Queue queues the events to be inserted on the writer goroutine, the events
+// queued meanwhile are inserted in one transaction.  The events are dropped
+// when the queue is full
+func (s *PVRHistoryService) Queue(now time.Time, recs []*PVREventRec) {
+	if !s.queue.Queue(recs) {
+		s.Metrics.DropCount.IncAt(int64(len(recs)), now)
+	}
+}
+
+func (s *PVRHistoryService) insertQueued(recs []*PVREventRec) {
+	if err := s.Insert(time.Now(), recs); err != nil {
+		log.Err(err).Msg("PVRHistoryService.insertQueued")
+	}
+}
+
+// Insert stores the events, and purges the events beyond the retention
+// every PurgeEvery
+func (s *PVRHistoryService) Insert(now time.Time, recs []*PVREventRec) error {
+	err := s.store.Exec(func(db *sql.DB) error {
+		if err := PVRHistoryDao.InsertEvents(db, recs); err != nil {
+			return err
+		}
+
+		s.Metrics.InsertCount.IncAt(1, now)
+		s.Metrics.EventCount.IncAt(int64(len(recs)), now)
+
+		// The events are stamped with the cabinet (corrected) time
+		purged, err := s.store.Purge(db, utils.Time.Correct(now))
+		if purged > 0 {
+			s.Metrics.PurgedCount.IncAt(purged, now)
+		}
+		return err
+	})
+
+	if err != nil && err != sqlitestore.ErrNotOpen {
+		s.Metrics.ErrCount.IncAt(1, now)
+	}
+	return err
+}
+
+// Select returns the events of the query, at most MaxRows
+func (s *PVRHistoryService) Select(query PVRQuery) (recs []*PVREventRec, err error) {
+	if query.MaxRows <= 0 || query.MaxRows > s.MaxRows {
+		query.MaxRows = s.MaxRows
+	}
+
+	err = s.store.Exec(func(db *sql.DB) error {
+		recs, err = PVRHistoryDao.SelectEvents(db, &query)
+		return err
+	})
+	return recs, err
+}
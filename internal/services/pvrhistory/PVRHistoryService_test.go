@@ -0,0 +1,121 @@
+package pvrhistory
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/internal/services/sqlitestore"
+	"rvpro3/radarvision.com/utils"
+)
+
+func newTestHistory(t *testing.T) *PVRHistoryService {
+	res := &PVRHistoryService{
+		FileName:      filepath.Join(t.TempDir(), "pvr.db"),
+		PurgeEvery:    1000,
+		RetentionDays: 1,
+		MaxRows:       100,
+		QueueSize:     4,
+	}
+	assert.NoError(t, res.Open())
+	t.Cleanup(func() { _ = res.Close() })
+	return res
+}
+
+func newTestEvent(radarIP string, on time.Time, zone int, class string, speed float64) *PVREventRec {
+	return &PVREventRec{
+		RadarIP:  radarIP,
+		On:       on.UnixMilli(),
+		ObjectId: 7,
+		Counter:  1,
+		Zone:     zone,
+		Class:    class,
+		Speed:    speed,
+		Length:   4.5,
+	}
+}
+
+func TestPVRHistoryService_Select(t *testing.T) {
+	history := newTestHistory(t)
+	on := utils.Time.Correct(time.Now()).Truncate(time.Hour)
+
+	assert.NoError(t, history.Insert(on, []*PVREventRec{
+		newTestEvent("192.168.11.12", on, 0, "CAR", 40),
+		newTestEvent("192.168.11.12", on.Add(time.Second), 1, "CAR", 55),
+		newTestEvent("192.168.11.12", on.Add(2*time.Second), 1, "CAR", 70),
+		newTestEvent("192.168.11.12", on.Add(3*time.Second), 1, "LONG TRUCK", 60),
+		newTestEvent("192.168.11.13", on.Add(4*time.Second), 1, "CAR", 60),
+	}))
+
+	query := PVRQuery{Zone: -1, From: on.UnixMilli(), To: on.Add(time.Minute).UnixMilli()}
+	events, err := history.Select(query)
+	assert.NoError(t, err)
+	assert.Len(t, events, 5)
+
+	query.RadarIP = "192.168.11.12"
+	query.Zone = 1
+	query.Class = "CAR"
+	events, err = history.Select(query)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+
+	query.MinSpeed = 50
+	query.MaxSpeed = 70
+	events, err = history.Select(query)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1, "the speed band excludes its maximum") {
+		assert.Equal(t, 55.0, events[0].Speed)
+		assert.Equal(t, on.Add(time.Second).UnixMilli(), events[0].On)
+		assert.Equal(t, 4.5, events[0].Length)
+	}
+
+	query = PVRQuery{Zone: -1, To: on.Add(time.Minute).UnixMilli(), MaxRows: 2}
+	events, err = history.Select(query)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+}
+
+func TestPVRHistoryService_Purge(t *testing.T) {
+	history := newTestHistory(t)
+	now := time.Now()
+
+	assert.NoError(t, history.Insert(now, []*PVREventRec{
+		newTestEvent("192.168.11.12", utils.Time.Correct(now).Add(-48*time.Hour), 1, "CAR", 50),
+		newTestEvent("192.168.11.12", utils.Time.Correct(now).Add(-time.Hour), 1, "CAR", 50),
+	}))
+
+	events, err := history.Select(PVRQuery{Zone: -1, To: now.UnixMilli() + 1})
+	assert.NoError(t, err)
+	assert.Len(t, events, 1, "the events beyond the retention are purged")
+	assert.Equal(t, int64(1), history.Metrics.PurgedCount.Value)
+}
+
+func TestPVRHistoryService_NotOpen(t *testing.T) {
+	history := PVRHistoryService{}
+
+	_, err := history.Select(PVRQuery{})
+	assert.ErrorIs(t, err, sqlitestore.ErrNotOpen)
+	assert.ErrorIs(t, history.Insert(time.Now(), nil), sqlitestore.ErrNotOpen)
+}
+
+func TestPVRHistoryService_Queue(t *testing.T) {
+	history := newTestHistory(t)
+	on := utils.Time.Correct(time.Now()).Truncate(time.Hour)
+
+	history.Queue(on, []*PVREventRec{newTestEvent("192.168.11.12", on, 1, "CAR", 50)})
+	history.Queue(on, []*PVREventRec{
+		newTestEvent("192.168.11.12", on.Add(time.Second), 1, "CAR", 60),
+		newTestEvent("192.168.11.12", on.Add(time.Second), 2, "CAR", 70),
+	})
+
+	// Closing the queue inserts the events queued
+	history.queue.Close()
+
+	events, err := history.Select(PVRQuery{Zone: -1, From: on.UnixMilli(), To: on.Add(time.Hour).UnixMilli()})
+	assert.NoError(t, err)
+	assert.Len(t, events, 3)
+
+	history.Queue(on, []*PVREventRec{newTestEvent("192.168.11.12", on, 3, "CAR", 50)})
+	assert.Equal(t, int64(1), history.Metrics.DropCount.Value, "dropped once closed")
+}
@@ -0,0 +1,239 @@
+package snmp
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/slayercat/GoSNMPServer"
+)
+
+// MibSyntax is the SMIv2 syntax of an object together with its BER type
+type MibSyntax struct {
+	Name   string
+	Type   gosnmp.Asn1BER
+	Module string
+}
+
+var (
+	SyntaxInteger   = MibSyntax{Name: "Integer32", Type: gosnmp.Integer, Module: "SNMPv2-SMI"}
+	SyntaxGauge     = MibSyntax{Name: "Gauge32", Type: gosnmp.Gauge32, Module: "SNMPv2-SMI"}
+	SyntaxCounter64 = MibSyntax{Name: "Counter64", Type: gosnmp.Counter64, Module: "SNMPv2-SMI"}
+	SyntaxTimeTicks = MibSyntax{Name: "TimeTicks", Type: gosnmp.TimeTicks, Module: "SNMPv2-SMI"}
+	SyntaxString    = MibSyntax{Name: "DisplayString", Type: gosnmp.OctetString, Module: "SNMPv2-TC"}
+	SyntaxTruth     = MibSyntax{Name: "TruthValue", Type: gosnmp.Integer, Module: "SNMPv2-TC"}
+	SyntaxGauge64   = MibSyntax{Name: "CounterBasedGauge64", Type: gosnmp.Counter64, Module: "HCNUM-TC"}
+)
+
+// MibScalar is a read-only scalar, served as instance 0
+type MibScalar struct {
+	Name        string
+	Id          int
+	Syntax      MibSyntax
+	Description string
+	Get         func() any
+}
+
+// MibColumn is a read-only column of a table, the index column is not
+// accessible (as SMIv2 recommends) and only holds the row number
+type MibColumn struct {
+	Name        string
+	Id          int
+	Syntax      MibSyntax
+	Description string
+	IsIndex     bool
+	Get         func(row int) any
+}
+
+// MibTable is a table indexed by the row number (1..Rows)
+type MibTable struct {
+	Name        string
+	Id          int
+	Entry       string
+	Description string
+	Rows        func() int
+	Columns     []*MibColumn
+}
+
+// MibGroup is a subtree of the objects holding scalars and tables
+type MibGroup struct {
+	Name        string
+	Id          int
+	Description string
+	Scalars     []*MibScalar
+	Tables      []*MibTable
+}
+
+// MibNotification is a trap, with the (column) objects sent along
+type MibNotification struct {
+	Name        string
+	Id          int
+	Description string
+	Objects     []string
+}
+
+// Mib is the enterprise MIB.  The agent serves it and the MIB file is
+// written from it, meaning both always agree.  The tree is:
+//
+//	OID.1       objects (the groups)
+//	OID.2.0     notifications
+//	OID.3       conformance
+type Mib struct {
+	ModuleName    string
+	Name          string
+	OID           string
+	Enterprise    string
+	LastUpdated   string
+	Organization  string
+	ContactInfo   string
+	Description   string
+	Groups        []*MibGroup
+	Notifications []*MibNotification
+}
+
+func (m *Mib) ObjectsOID() string {
+	return m.OID + ".1"
+}
+
+func (m *Mib) GroupOID(group *MibGroup) string {
+	return m.ObjectsOID() + "." + strconv.Itoa(group.Id)
+}
+
+func (m *Mib) ScalarOID(group *MibGroup, scalar *MibScalar) string {
+	return m.GroupOID(group) + "." + strconv.Itoa(scalar.Id) + ".0"
+}
+
+func (m *Mib) TableOID(group *MibGroup, table *MibTable) string {
+	return m.GroupOID(group) + "." + strconv.Itoa(table.Id)
+}
+
+// ColumnOID returns the OID of the column instance of the row (1..Rows)
+func (m *Mib) ColumnOID(group *MibGroup, table *MibTable, column *MibColumn, row int) string {
+	return fmt.Sprintf("%s.1.%d.%d", m.TableOID(group, table), column.Id, row)
+}
+
+func (m *Mib) NotificationOID(notification *MibNotification) string {
+	return m.OID + ".2.0." + strconv.Itoa(notification.Id)
+}
+
+// FindColumn returns the OID of the column instance (by name) of the row
+func (m *Mib) FindColumn(name string, row int) (string, MibSyntax, bool) {
+	for _, group := range m.Groups {
+		for _, table := range group.Tables {
+			for _, column := range table.Columns {
+				if column.Name == name {
+					return m.ColumnOID(group, table, column, row), column.Syntax, true
+				}
+			}
+		}
+	}
+	return "", MibSyntax{}, false
+}
+
+// FindNotification returns the notification by name
+func (m *Mib) FindNotification(name string) *MibNotification {
+	for _, notification := range m.Notifications {
+		if notification.Name == name {
+			return notification
+		}
+	}
+	return nil
+}
+
+// ControlItems returns the items served by the agent, the table rows are
+// counted when called
+func (m *Mib) ControlItems() []*GoSNMPServer.PDUValueControlItem {
+	res := make([]*GoSNMPServer.PDUValueControlItem, 0, 64)
+
+	for _, group := range m.Groups {
+		for _, scalar := range group.Scalars {
+			res = append(res, &GoSNMPServer.PDUValueControlItem{
+				OID:      m.ScalarOID(group, scalar),
+				Type:     scalar.Syntax.Type,
+				OnGet:    m.scalarGet(scalar),
+				Document: scalar.Name,
+			})
+		}
+
+		for _, table := range group.Tables {
+			rows := table.Rows()
+
+			for _, column := range table.Columns {
+				if column.IsIndex {
+					continue
+				}
+
+				for row := 1; row <= rows; row++ {
+					res = append(res, &GoSNMPServer.PDUValueControlItem{
+						OID:      m.ColumnOID(group, table, column, row),
+						Type:     column.Syntax.Type,
+						OnGet:    m.columnGet(column, row),
+						Document: column.Name,
+					})
+				}
+			}
+		}
+	}
+	return res
+}
+
+func (m *Mib) scalarGet(scalar *MibScalar) GoSNMPServer.FuncPDUControlGet {
+	return func() (any, error) {
+		return MibValueOf(scalar.Syntax, scalar.Get()), nil
+	}
+}
+
+func (m *Mib) columnGet(column *MibColumn, row int) GoSNMPServer.FuncPDUControlGet {
+	return func() (any, error) {
+		return MibValueOf(column.Syntax, column.Get(row)), nil
+	}
+}
+
+// MibValueOf converts the value into the go type the BER encoding of the
+// syntax expects, a bool is a TruthValue (true(1), false(2)) and negative
+// values of the unsigned syntaxes read 0
+func MibValueOf(syntax MibSyntax, value any) any {
+	var number int64
+
+	switch v := value.(type) {
+	case bool:
+		if v {
+			number = 1
+		} else {
+			number = 2
+		}
+	case int:
+		number = int64(v)
+	case int64:
+		number = v
+	case uint32:
+		number = int64(v)
+	case uint64:
+		if syntax.Type == gosnmp.Counter64 {
+			return v
+		}
+		number = int64(v)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(value)
+	}
+
+	switch syntax.Type {
+	case gosnmp.Integer:
+		return int(number)
+
+	case gosnmp.Gauge32:
+		return uint(max(0, min(number, 0xffffffff)))
+
+	case gosnmp.TimeTicks:
+		return uint32(max(0, min(number, 0xffffffff)))
+
+	case gosnmp.Counter64:
+		return uint64(max(0, number))
+
+	case gosnmp.OctetString:
+		return strconv.FormatInt(number, 10)
+	}
+	return int(number)
+}
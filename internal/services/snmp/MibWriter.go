@@ -0,0 +1,246 @@
+package snmp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Write writes the MIB module (SMIv2)
+func (m *Mib) Write(writer io.Writer) error {
+	w := bufio.NewWriter(writer)
+
+	m.writeHeader(w)
+
+	for _, group := range m.Groups {
+		m.writeLn(w, "%s OBJECT IDENTIFIER ::= { %sObjects %d }", group.Name, m.Name, group.Id)
+		m.writeLn(w, "")
+
+		for _, scalar := range group.Scalars {
+			m.writeObjectType(w, scalar.Name, scalar.Syntax.Name, "read-only", scalar.Description, "", group.Name, scalar.Id)
+		}
+
+		for _, table := range group.Tables {
+			m.writeTable(w, group, table)
+		}
+	}
+
+	for _, notification := range m.Notifications {
+		m.writeLn(w, "%s NOTIFICATION-TYPE", notification.Name)
+		m.writeLn(w, "    OBJECTS     { %s }", strings.Join(notification.Objects, ", "))
+		m.writeLn(w, "    STATUS      current")
+		m.writeDescription(w, notification.Description)
+		m.writeLn(w, "    ::= { %sNotificationPrefix %d }", m.Name, notification.Id)
+		m.writeLn(w, "")
+	}
+
+	m.writeConformance(w)
+	m.writeLn(w, "END")
+
+	return w.Flush()
+}
+
+func (m *Mib) writeHeader(w *bufio.Writer) {
+	m.writeLn(w, "%s DEFINITIONS ::= BEGIN", m.ModuleName)
+	m.writeLn(w, "")
+	m.writeLn(w, "IMPORTS")
+	m.writeLn(w, "    MODULE-IDENTITY, OBJECT-TYPE, NOTIFICATION-TYPE, enterprises,")
+	m.writeLn(w, "    %s", strings.Join(m.imports("SNMPv2-SMI"), ", "))
+	m.writeLn(w, "        FROM SNMPv2-SMI")
+
+	for _, module := range []string{"SNMPv2-TC", "HCNUM-TC"} {
+		if names := m.imports(module); len(names) > 0 {
+			m.writeLn(w, "    %s", strings.Join(names, ", "))
+			m.writeLn(w, "        FROM %s", module)
+		}
+	}
+
+	m.writeLn(w, "    MODULE-COMPLIANCE, OBJECT-GROUP, NOTIFICATION-GROUP")
+	m.writeLn(w, "        FROM SNMPv2-CONF;")
+	m.writeLn(w, "")
+
+	m.writeLn(w, "%s MODULE-IDENTITY", m.Name)
+	m.writeLn(w, "    LAST-UPDATED \"%s\"", m.LastUpdated)
+	m.writeLn(w, "    ORGANIZATION \"%s\"", m.Organization)
+	m.writeLn(w, "    CONTACT-INFO \"%s\"", m.ContactInfo)
+	m.writeDescription(w, m.Description)
+	m.writeLn(w, "    REVISION     \"%s\"", m.LastUpdated)
+	m.writeDescription(w, "Initial version")
+	m.writeLn(w, "    ::= { enterprises %s }", strings.ReplaceAll(strings.TrimPrefix(m.OID, m.Enterprise+"."), ".", " "))
+	m.writeLn(w, "")
+
+	m.writeLn(w, "%sObjects OBJECT IDENTIFIER ::= { %s 1 }", m.Name, m.Name)
+	m.writeLn(w, "%sNotifications OBJECT IDENTIFIER ::= { %s 2 }", m.Name, m.Name)
+	m.writeLn(w, "%sNotificationPrefix OBJECT IDENTIFIER ::= { %sNotifications 0 }", m.Name, m.Name)
+	m.writeLn(w, "%sConformance OBJECT IDENTIFIER ::= { %s 3 }", m.Name, m.Name)
+	m.writeLn(w, "")
+}
+
+func (m *Mib) writeTable(w *bufio.Writer, group *MibGroup, table *MibTable) {
+	entryType := m.typeName(table.Entry)
+	var index string
+
+	for _, column := range table.Columns {
+		if column.IsIndex {
+			index = column.Name
+		}
+	}
+
+	m.writeObjectType(w, table.Name, "SEQUENCE OF "+entryType, "not-accessible", table.Description, "", group.Name, table.Id)
+	m.writeObjectType(w, table.Entry, entryType, "not-accessible", "A row of the "+table.Name, index, table.Name, 1)
+
+	m.writeLn(w, "%s ::= SEQUENCE {", entryType)
+	for index, column := range table.Columns {
+		separator := ","
+		if index == len(table.Columns)-1 {
+			separator = ""
+		}
+		m.writeLn(w, "    %-24s %s%s", column.Name, column.Syntax.Name, separator)
+	}
+	m.writeLn(w, "}")
+	m.writeLn(w, "")
+
+	for _, column := range table.Columns {
+		if column.IsIndex {
+			syntax := column.Syntax.Name + " (1..2147483647)"
+			m.writeObjectType(w, column.Name, syntax, "not-accessible", column.Description, "", table.Entry, column.Id)
+			continue
+		}
+		m.writeObjectType(w, column.Name, column.Syntax.Name, "read-only", column.Description, "", table.Entry, column.Id)
+	}
+}
+
+func (m *Mib) writeObjectType(
+	w *bufio.Writer,
+	name string,
+	syntax string,
+	access string,
+	description string,
+	index string,
+	parent string,
+	id int,
+) {
+	m.writeLn(w, "%s OBJECT-TYPE", name)
+	m.writeLn(w, "    SYNTAX      %s", syntax)
+	m.writeLn(w, "    MAX-ACCESS  %s", access)
+	m.writeLn(w, "    STATUS      current")
+	m.writeDescription(w, description)
+
+	if len(index) > 0 {
+		m.writeLn(w, "    INDEX       { %s }", index)
+	}
+
+	m.writeLn(w, "    ::= { %s %d }", parent, id)
+	m.writeLn(w, "")
+}
+
+func (m *Mib) writeConformance(w *bufio.Writer) {
+	var objects []string
+	var notifications []string
+
+	for _, group := range m.Groups {
+		for _, scalar := range group.Scalars {
+			objects = append(objects, scalar.Name)
+		}
+
+		for _, table := range group.Tables {
+			for _, column := range table.Columns {
+				if !column.IsIndex {
+					objects = append(objects, column.Name)
+				}
+			}
+		}
+	}
+
+	for _, notification := range m.Notifications {
+		notifications = append(notifications, notification.Name)
+	}
+
+	m.writeLn(w, "%sGroups OBJECT IDENTIFIER ::= { %sConformance 1 }", m.Name, m.Name)
+	m.writeLn(w, "%sCompliances OBJECT IDENTIFIER ::= { %sConformance 2 }", m.Name, m.Name)
+	m.writeLn(w, "")
+
+	m.writeLn(w, "%sObjectGroup OBJECT-GROUP", m.Name)
+	m.writeList(w, "OBJECTS", objects)
+	m.writeLn(w, "    STATUS      current")
+	m.writeDescription(w, "The objects of the "+m.ModuleName)
+	m.writeLn(w, "    ::= { %sGroups 1 }", m.Name)
+	m.writeLn(w, "")
+
+	m.writeLn(w, "%sNotificationGroup NOTIFICATION-GROUP", m.Name)
+	m.writeList(w, "NOTIFICATIONS", notifications)
+	m.writeLn(w, "    STATUS      current")
+	m.writeDescription(w, "The notifications of the "+m.ModuleName)
+	m.writeLn(w, "    ::= { %sGroups 2 }", m.Name)
+	m.writeLn(w, "")
+
+	m.writeLn(w, "%sCompliance MODULE-COMPLIANCE", m.Name)
+	m.writeLn(w, "    STATUS      current")
+	m.writeDescription(w, "The requirements of the "+m.ModuleName)
+	m.writeLn(w, "    MODULE")
+	m.writeLn(w, "        MANDATORY-GROUPS { %sObjectGroup, %sNotificationGroup }", m.Name, m.Name)
+	m.writeLn(w, "    ::= { %sCompliances 1 }", m.Name)
+	m.writeLn(w, "")
+}
+
+func (m *Mib) writeList(w *bufio.Writer, clause string, names []string) {
+	m.writeLn(w, "    %-11s {", clause)
+	for index, name := range names {
+		separator := ","
+		if index == len(names)-1 {
+			separator = ""
+		}
+		m.writeLn(w, "        %s%s", name, separator)
+	}
+	m.writeLn(w, "    }")
+}
+
+func (m *Mib) writeDescription(w *bufio.Writer, description string) {
+	m.writeLn(w, "    DESCRIPTION")
+	m.writeLn(w, "        \"%s\"", strings.ReplaceAll(description, "\"", "'"))
+}
+
+func (m *Mib) writeLn(w *bufio.Writer, format string, args ...any) {
+	if len(args) == 0 {
+		_, _ = w.WriteString(format)
+	} else {
+		_, _ = w.WriteString(fmt.Sprintf(format, args...))
+	}
+	_ = w.WriteByte('\n')
+}
+
+// imports returns the syntaxes used from the module
+func (m *Mib) imports(module string) []string {
+	var res []string
+
+	add := func(syntax MibSyntax) {
+		if syntax.Module == module && !slices.Contains(res, syntax.Name) {
+			res = append(res, syntax.Name)
+		}
+	}
+
+	for _, group := range m.Groups {
+		for _, scalar := range group.Scalars {
+			add(scalar.Syntax)
+		}
+
+		for _, table := range group.Tables {
+			for _, column := range table.Columns {
+				add(column.Syntax)
+			}
+		}
+	}
+
+	slices.Sort(res)
+	return res
+}
+
+// typeName returns the SEQUENCE type of the entry, radarEntry is RadarEntry
+func (m *Mib) typeName(entry string) string {
+	runes := []rune(entry)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
@@ -0,0 +1,50 @@
+package snmp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+)
+
+// The MIB is written beneath the enterprise number of the agent, generate it
+// with rvpro --mode=dump-mib --override=snmp.enterprise.number=<number>
+func TestMib_Write(t *testing.T) {
+	var buffer bytes.Buffer
+	assert.NoError(t, NewRVProMib(&SNMPAgentService{EnterpriseNumber: testEnterpriseNumber}).Write(&buffer))
+
+	assert.True(t, strings.HasPrefix(buffer.String(), RVProMibModuleName+" DEFINITIONS ::= BEGIN"))
+	assert.Contains(t, buffer.String(), "::= { enterprises 99999 1 }")
+}
+
+func TestMib_OIDs(t *testing.T) {
+	mib := NewRVProMib(&SNMPAgentService{EnterpriseNumber: testEnterpriseNumber})
+
+	oid, syntax, ok := mib.FindColumn("radarName", 2)
+	assert.True(t, ok)
+	assert.Equal(t, RVProMibOID(testEnterpriseNumber)+".1.2.1.1.3.2", oid)
+	assert.Equal(t, SyntaxString, syntax)
+
+	_, _, ok = mib.FindColumn("rvproVersion", 1)
+	assert.False(t, ok)
+
+	notification := mib.FindNotification(NotificationRadarFailSafeOn)
+	if assert.NotNil(t, notification) {
+		assert.Equal(t, RVProMibOID(testEnterpriseNumber)+".2.0.3", mib.NotificationOID(notification))
+	}
+	assert.Nil(t, mib.FindNotification("radarOnFire"))
+}
+
+func TestMibValueOf(t *testing.T) {
+	assert.Equal(t, 1, MibValueOf(SyntaxTruth, true))
+	assert.Equal(t, 2, MibValueOf(SyntaxTruth, false))
+	assert.Equal(t, uint(0), MibValueOf(SyntaxGauge, int64(-5)))
+	assert.Equal(t, uint(0xffffffff), MibValueOf(SyntaxGauge, int64(1)<<40))
+	assert.Equal(t, uint64(7), MibValueOf(SyntaxCounter64, 7))
+	assert.Equal(t, uint64(0), MibValueOf(SyntaxGauge64, int64(-1)))
+	assert.Equal(t, uint32(12), MibValueOf(SyntaxTimeTicks, uint32(12)))
+	assert.Equal(t, "42", MibValueOf(SyntaxString, 42))
+	assert.Equal(t, gosnmp.Integer, SyntaxTruth.Type)
+}
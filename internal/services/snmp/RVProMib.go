@@ -0,0 +1,375 @@
+package snmp
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"time"
+)
+
+// EnterpriseOID is the enterprises (1.3.6.1.4.1) node
+const EnterpriseOID = "1.3.6.1.4.1"
+
+const RVProMibModuleName = "RVPRO-MIB"
+
+// The notifications sent as traps
+const (
+	NotificationRadarOffline     = "radarOffline"
+	NotificationRadarOnline      = "radarOnline"
+	NotificationRadarFailSafeOn  = "radarFailSafeOn"
+	NotificationRadarFailSafeOff = "radarFailSafeOff"
+)
+
+// RVProMibOID returns the OID of the MIB module beneath the (IANA private)
+// enterprise number
+func RVProMibOID(enterpriseNumber int) string {
+	return EnterpriseOID + "." + strconv.Itoa(enterpriseNumber) + ".1"
+}
+
+// NewRVProMib defines the MIB served by the agent, the values are read from
+// the agent when requested
+func NewRVProMib(agent *SNMPAgentService) *Mib {
+	return &Mib{
+		ModuleName:   RVProMibModuleName,
+		Name:         "rvpro",
+		OID:          RVProMibOID(agent.EnterpriseNumber),
+		Enterprise:   EnterpriseOID,
+		LastUpdated:  "202610180000Z",
+		Organization: "Radar Vision",
+		ContactInfo:  "Radar Vision support",
+		Description:  "The radar, detector, SDLC and network health of the rvpro traffic radar middleware",
+		Groups: []*MibGroup{
+			{
+				Name: "rvproSystem",
+				Id:   1,
+				Scalars: []*MibScalar{
+					{
+						Name:        "rvproVersion",
+						Id:          1,
+						Syntax:      SyntaxString,
+						Description: "The version of rvpro",
+						Get:         agent.getVersion,
+					},
+					{
+						Name:        "rvproUptime",
+						Id:          2,
+						Syntax:      SyntaxTimeTicks,
+						Description: "The time since the agent started (hundredths of a second)",
+						Get: func() any {
+							return uint32(time.Since(agent.startOn).Milliseconds() / 10)
+						},
+					},
+				},
+			},
+			{
+				Name: "rvproRadars",
+				Id:   2,
+				Tables: []*MibTable{
+					{
+						Name:        "radarTable",
+						Id:          1,
+						Entry:       "radarEntry",
+						Description: "The radars configured",
+						Rows:        func() int { return len(agent.getRadars()) },
+						Columns: []*MibColumn{
+							{
+								Name:        "radarIndex",
+								Id:          1,
+								Syntax:      SyntaxInteger,
+								Description: "The radar number",
+								IsIndex:     true,
+							},
+							{
+								Name:        "radarIP",
+								Id:          2,
+								Syntax:      SyntaxString,
+								Description: "The ip address (and port) of the radar",
+								Get:         func(row int) any { return agent.getRadar(row - 1).IP.String() },
+							},
+							{
+								Name:        "radarName",
+								Id:          3,
+								Syntax:      SyntaxString,
+								Description: "The name of the radar",
+								Get:         func(row int) any { return agent.getRadar(row - 1).Name },
+							},
+							{
+								Name:        "radarOnline",
+								Id:          4,
+								Syntax:      SyntaxTruth,
+								Description: "Whether the radar sent data recently",
+								Get:         func(row int) any { return agent.isRadarOnline(row-1, time.Now()) },
+							},
+							{
+								Name:        "radarAutoFailSafe",
+								Id:          5,
+								Syntax:      SyntaxTruth,
+								Description: "Whether the channels of the radar are in failsafe, due to no radar activity",
+								Get:         func(row int) any { return agent.getRadar(row - 1).GetAutoFailSafe() },
+							},
+							{
+								Name:        "radarManualFailSafe",
+								Id:          6,
+								Syntax:      SyntaxTruth,
+								Description: "Whether the channels of the radar are in failsafe, as requested",
+								Get:         func(row int) any { return agent.getRadar(row - 1).IsManualFailSafe },
+							},
+							{
+								Name:        "radarDataAge",
+								Id:          7,
+								Syntax:      SyntaxGauge,
+								Description: "The seconds since the radar last sent data, 4294967295 when never",
+								Get:         func(row int) any { return agent.getRadarDataAge(row-1, time.Now()) },
+							},
+							{
+								Name:        "radarReceivedCount",
+								Id:          8,
+								Syntax:      SyntaxCounter64,
+								Description: "The UDP messages received from the radar",
+								Get:         func(row int) any { return agent.getRadarReceivedCount(row - 1) },
+							},
+						},
+					},
+				},
+			},
+			{
+				Name: "rvproDetector",
+				Id:   3,
+				Scalars: []*MibScalar{
+					{
+						Name:        "detectorCalls1to32",
+						Id:          1,
+						Syntax:      SyntaxGauge,
+						Description: "The calls of channel 1 to 32, channel N is bit N-1",
+						Get:         func() any { return int64(uint32(agent.getChannelCalls().Lo)) },
+					},
+					{
+						Name:        "detectorCalls33to64",
+						Id:          2,
+						Syntax:      SyntaxGauge,
+						Description: "The calls of channel 33 to 64, channel N is bit N-33",
+						Get:         func() any { return int64(agent.getChannelCalls().Lo >> 32) },
+					},
+					{
+						Name:        "detectorCallCount",
+						Id:          3,
+						Syntax:      SyntaxGauge,
+						Description: "The number of channels called",
+						Get: func() any {
+							calls := agent.getChannelCalls()
+							return bits.OnesCount64(calls.Lo) + bits.OnesCount64(calls.Hi)
+						},
+					},
+				},
+			},
+			{
+				Name: "rvproSdlc",
+				Id:   4,
+				Scalars: []*MibScalar{
+					{
+						Name:        "sdlcStatusValid",
+						Id:          1,
+						Syntax:      SyntaxTruth,
+						Description: "Whether the static status was received from the cabinet",
+						Get:         func() any { return agent.StaticStatus != nil },
+					},
+					{
+						Name:        "sdlcBIU",
+						Id:          2,
+						Syntax:      SyntaxInteger,
+						Description: "The detector BIUs present (bit flags)",
+						Get:         func() any { return int(agent.getStaticStatus().BIU) },
+					},
+					{
+						Name:        "sdlcVersion",
+						Id:          3,
+						Syntax:      SyntaxString,
+						Description: "The SDLC version (major.minor)",
+						Get: func() any {
+							status := agent.getStaticStatus()
+							return fmt.Sprintf("%d.%d", status.MajorVersion, status.MinorVersion)
+						},
+					},
+					{
+						Name:        "sdlcProtocolVersion",
+						Id:          4,
+						Syntax:      SyntaxInteger,
+						Description: "The SDLC protocol version",
+						Get:         func() any { return int(agent.getStaticStatus().ProtocolVersion) },
+					},
+					{
+						Name:        "sdlcSerial",
+						Id:          5,
+						Syntax:      SyntaxString,
+						Description: "The serial number of the SDLC interface (hex)",
+						Get:         func() any { return fmt.Sprintf("%016x", agent.getStaticStatus().Serial) },
+					},
+					{
+						Name:        "sdlcMode",
+						Id:          6,
+						Syntax:      SyntaxInteger,
+						Description: "The cabinet mode flags",
+						Get:         func() any { return int(agent.getStaticStatus().Mode) },
+					},
+					{
+						Name:        "sdlcSafe",
+						Id:          7,
+						Syntax:      SyntaxTruth,
+						Description: "Whether the cabinet is in safe mode",
+						Get:         func() any { return agent.StaticStatus != nil && agent.StaticStatus.Mode.IsSafe() },
+					},
+				},
+			},
+			{
+				Name: "rvproPing",
+				Id:   5,
+				Tables: []*MibTable{
+					{
+						Name:        "pingTable",
+						Id:          1,
+						Entry:       "pingEntry",
+						Description: "The devices (radars and cameras) pinged",
+						Rows:        agent.getPingRows,
+						Columns: []*MibColumn{
+							{
+								Name:        "pingIndex",
+								Id:          1,
+								Syntax:      SyntaxInteger,
+								Description: "The device number",
+								IsIndex:     true,
+							},
+							{
+								Name:        "pingIP",
+								Id:          2,
+								Syntax:      SyntaxString,
+								Description: "The ip address of the device",
+								Get:         func(row int) any { return agent.Pings.List[row-1].DriverStat.Addr },
+							},
+							{
+								Name:        "pingDeviceType",
+								Id:          3,
+								Syntax:      SyntaxString,
+								Description: "The device type (Radar or Camera)",
+								Get:         func(row int) any { return agent.Pings.List[row-1].DeviceType },
+							},
+							{
+								Name:        "pingReachable",
+								Id:          4,
+								Syntax:      SyntaxTruth,
+								Description: "Whether the device answered the last ping",
+								Get: func(row int) any {
+									stat := agent.Pings.List[row-1]
+									return stat.LastError == nil && stat.DriverStat.PacketsRecv > 0
+								},
+							},
+							{
+								Name:        "pingRtt",
+								Id:          5,
+								Syntax:      SyntaxGauge,
+								Description: "The average round trip time of the last ping (milliseconds)",
+								Get:         func(row int) any { return agent.Pings.List[row-1].DriverStat.AvgRtt.Milliseconds() },
+							},
+							{
+								Name:        "pingLoss",
+								Id:          6,
+								Syntax:      SyntaxGauge,
+								Description: "The packet loss of the last ping (percent)",
+								Get:         func(row int) any { return int64(agent.Pings.List[row-1].DriverStat.PacketLoss) },
+							},
+							{
+								Name:        "pingOkCount",
+								Id:          7,
+								Syntax:      SyntaxCounter64,
+								Description: "The successful pings",
+								Get:         func(row int) any { return agent.Pings.List[row-1].Metrics.PingOkCount.Value },
+							},
+							{
+								Name:        "pingFailCount",
+								Id:          8,
+								Syntax:      SyntaxCounter64,
+								Description: "The failed pings",
+								Get:         func(row int) any { return agent.Pings.List[row-1].Metrics.PingFailCount.Value },
+							},
+						},
+					},
+				},
+			},
+			{
+				Name: "rvproMetrics",
+				Id:   6,
+				Tables: []*MibTable{
+					{
+						Name:        "metricTable",
+						Id:          1,
+						Entry:       "metricEntry",
+						Description: "The metrics selected (snmp.metrics)",
+						Rows:        func() int { return len(agent.MetricNames) },
+						Columns: []*MibColumn{
+							{
+								Name:        "metricIndex",
+								Id:          1,
+								Syntax:      SyntaxInteger,
+								Description: "The metric number",
+								IsIndex:     true,
+							},
+							{
+								Name:        "metricSection",
+								Id:          2,
+								Syntax:      SyntaxString,
+								Description: "The section of the metric",
+								Get:         func(row int) any { return agent.MetricNames[row-1].Section },
+							},
+							{
+								Name:        "metricName",
+								Id:          3,
+								Syntax:      SyntaxString,
+								Description: "The name of the metric",
+								Get:         func(row int) any { return agent.MetricNames[row-1].Metric },
+							},
+							{
+								Name:        "metricType",
+								Id:          4,
+								Syntax:      SyntaxString,
+								Description: "The type of the metric (I64, MT, MD, YN, RT, WN, HG, GA), empty when not yet registered",
+								Get:         func(row int) any { return agent.getMetricType(row - 1) },
+							},
+							{
+								Name:        "metricValue",
+								Id:          5,
+								Syntax:      SyntaxGauge64,
+								Description: "The value of the metric, negative values read 0 as does a metric not yet registered",
+								Get:         func(row int) any { return agent.getMetricValue(row - 1) },
+							},
+						},
+					},
+				},
+			},
+		},
+		Notifications: []*MibNotification{
+			{
+				Name:        NotificationRadarOffline,
+				Id:          1,
+				Description: "The radar stopped sending data",
+				Objects:     []string{"radarIP", "radarName"},
+			},
+			{
+				Name:        NotificationRadarOnline,
+				Id:          2,
+				Description: "The radar sends data again",
+				Objects:     []string{"radarIP", "radarName"},
+			},
+			{
+				Name:        NotificationRadarFailSafeOn,
+				Id:          3,
+				Description: "The channels of the radar went into failsafe",
+				Objects:     []string{"radarIP", "radarName", "radarAutoFailSafe"},
+			},
+			{
+				Name:        NotificationRadarFailSafeOff,
+				Id:          4,
+				Description: "The channels of the radar left failsafe",
+				Objects:     []string{"radarIP", "radarName", "radarAutoFailSafe"},
+			},
+		},
+	}
+}
@@ -0,0 +1,539 @@
+package snmp
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/slayercat/GoSNMPServer"
+	"rvpro3/radarvision.com/internal/constants"
+	"rvpro3/radarvision.com/internal/general"
+	"rvpro3/radarvision.com/internal/models/servicemodel"
+	"rvpro3/radarvision.com/internal/sdlc/uartsdlc"
+	"rvpro3/radarvision.com/internal/services/ping"
+	"rvpro3/radarvision.com/internal/smartmicro/udp/state"
+	"rvpro3/radarvision.com/utils"
+)
+
+const SNMPAgentServiceName = "SNMP.Agent.Service"
+const snmpListen = "snmp.listen"
+const snmpCommunity = "snmp.community"
+const snmpTrapTargets = "snmp.trap.targets"
+const snmpTrapCommunity = "snmp.trap.community"
+const snmpCheckEvery = "snmp.check.every"
+const snmpRadarOfflineAfter = "snmp.radar.offline.after"
+const snmpMetrics = "snmp.metrics"
+const snmpCallsSource = "snmp.calls.source"
+const snmpEnterpriseNumber = "snmp.enterprise.number"
+
+var errUnknownNotification = errors.New("unknown notification")
+var errNoEnterpriseNumber = errors.New("snmp.enterprise.number (the IANA private enterprise number) not set")
+
+// IChannelCalls provides the detector channel calls (see
+// detector.DetectorOutputService)
+type IChannelCalls interface {
+	GetChannelCalls() utils.Uint128
+}
+
+// MetricName is a metric (snmp.metrics) exposed in the metricTable, given as
+// Section/Metric
+type MetricName struct {
+	Section string
+	Metric  string
+}
+
+// SNMPAgentService serves the RVPRO-MIB (v1/v2c) beneath the EnterpriseNumber,
+// and sends the radar offline/online and failsafe traps to the trap targets.
+// The pings and metrics are resolved on start, the radars again once the
+// configuration is reloaded (the agent then listens anew for the table rows)
+type SNMPAgentService struct {
+	EnterpriseNumber int
+	ListenAddr       string
+	Community        string
+	TrapTargets      []string
+	TrapCommunity    string
+	CheckEvery       utils.Milliseconds
+	RadarOfflineTime utils.Milliseconds
+	MetricNames      []MetricName
+	CallsSourceName  string
+	Radars           []*state.RadarState    `json:"-"`
+	Pings            *ping.PingStats        `json:"-"`
+	CallsSource      IChannelCalls          `json:"-"`
+	StaticStatus     *uartsdlc.StaticStatus `json:"-"`
+	Mib              *Mib                   `json:"-"`
+	Terminate        bool
+	Terminated       bool
+	Metrics          SNMPAgentServiceMetrics `json:"-"`
+	server           *GoSNMPServer.SNMPServer
+	trappers         []*gosnmp.GoSNMP
+	radarsOnline     []bool
+	radarsFailSafe   []bool
+	startOn          time.Time
+	state            *utils.State
+	config           *servicemodel.Config
+	lock             sync.Mutex
+	radarsLock       sync.RWMutex
+}
+
+type SNMPAgentServiceMetrics struct {
+	TrapCount    *utils.Metric
+	TrapErrCount *utils.Metric
+	ServeErrs    *utils.Metric
+	utils.MetricsInitMixin
+}
+
+func (s *SNMPAgentService) InitFromSettings(settings *utils.Settings) {
+	s.EnterpriseNumber = settings.Basic.GetInt(snmpEnterpriseNumber, 0)
+	s.ListenAddr = settings.Basic.Get(snmpListen, "0.0.0.0:161")
+	s.Community = settings.Basic.Get(snmpCommunity, "public")
+	s.TrapTargets = settings.Basic.GetArray(snmpTrapTargets, "")
+	s.TrapCommunity = settings.Basic.Get(snmpTrapCommunity, "public")
+	s.CheckEvery = settings.Basic.GetMilliseconds(snmpCheckEvery, 1000)
+	s.RadarOfflineTime = settings.Basic.GetMilliseconds(snmpRadarOfflineAfter, 5000)
+	s.MetricNames = ParseMetricNames(settings.Basic.GetArray(snmpMetrics, ""))
+	s.CallsSourceName = settings.Basic.Get(snmpCallsSource, "Detector.Output.Service")
+}
+
+func (s *SNMPAgentService) Start(state *utils.State, settings *utils.Settings) {
+	if !general.ServiceHelper.ShouldStart(state, settings, s) {
+		return
+	}
+
+	s.resolve(state)
+
+	if err := s.Listen(); err != nil {
+		log.Err(err).Str("listen", s.ListenAddr).Msg("SNMPAgentService.Start")
+		return
+	}
+
+	go s.run()
+}
+
+func (s *SNMPAgentService) GetServiceName() string {
+	return SNMPAgentServiceName
+}
+
+// ParseMetricNames parses the Section/Metric names, ignoring the blanks
+func ParseMetricNames(names []string) []MetricName {
+	res := make([]MetricName, 0, len(names))
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		index := strings.LastIndex(name, "/")
+
+		if index <= 0 || index == len(name)-1 {
+			continue
+		}
+		res = append(res, MetricName{Section: name[:index], Metric: name[index+1:]})
+	}
+	return res
+}
+
+// resolve finds the radars, ping stats, channel calls and SDLC status
+func (s *SNMPAgentService) resolve(st *utils.State) {
+	s.state = st
+
+	if config, ok := st.Get(servicemodel.StateName).(*servicemodel.Config); ok && s.Radars == nil {
+		s.config = config
+		s.Radars = s.radarsOf(config)
+	}
+
+	if s.Pings == nil {
+		s.Pings, _ = st.Get(ping.PingStatsStateName).(*ping.PingStats)
+	}
+
+	if s.CallsSource == nil {
+		s.CallsSource, _ = st.Get(s.CallsSourceName).(IChannelCalls)
+	}
+
+	if s.StaticStatus == nil {
+		s.StaticStatus, _ = st.Get(uartsdlc.SDLCStaticStatusStateName).(*uartsdlc.StaticStatus)
+	}
+}
+
+func (s *SNMPAgentService) radarsOf(config *servicemodel.Config) []*state.RadarState {
+	res := make([]*state.RadarState, 0, len(config.Radars))
+
+	for _, radar := range config.Radars {
+		res = append(res, state.RadarStateHelper.GetOrSet(radar.GetRadarIP()))
+	}
+	return res
+}
+
+// Listen builds the MIB, serves the requests and connects the trap targets.
+// The MIB is not served without the enterprise number
+func (s *SNMPAgentService) Listen() (err error) {
+	if s.EnterpriseNumber <= 0 {
+		return errNoEnterpriseNumber
+	}
+
+	s.Metrics.InitMetrics(s.GetServiceName(), &s.Metrics)
+	s.startOn = time.Now()
+	s.Mib = NewRVProMib(s)
+
+	s.radarsOnline = make([]bool, len(s.Radars))
+	s.radarsFailSafe = make([]bool, len(s.Radars))
+
+	if err = s.listen(); err != nil {
+		return err
+	}
+
+	for _, target := range s.TrapTargets {
+		target = strings.TrimSpace(target)
+		if len(target) == 0 {
+			continue
+		}
+
+		ip4 := utils.IP4Builder.FromString(target)
+		port := ip4.Port
+		if port == 0 {
+			port = 162
+		}
+
+		trapper := &gosnmp.GoSNMP{
+			Target:    ip4.ToIPString(),
+			Port:      uint16(port),
+			Community: s.TrapCommunity,
+			Version:   gosnmp.Version2c,
+			Timeout:   2 * time.Second,
+			Retries:   0,
+		}
+
+		if err = trapper.Connect(); err != nil {
+			log.Err(err).Str("target", target).Msg("SNMPAgentService.Listen")
+			continue
+		}
+		s.trappers = append(s.trappers, trapper)
+	}
+	return nil
+}
+
+// listen serves the MIB with the rows of the radars, replacing the server
+// listened on before
+func (s *SNMPAgentService) listen() error {
+	master := GoSNMPServer.MasterAgent{
+		Logger:         &GoSNMPServer.DiscardLogger{},
+		SecurityConfig: GoSNMPServer.SecurityConfig{NoSecurity: true},
+		SubAgents: []*GoSNMPServer.SubAgent{
+			{
+				CommunityIDs: []string{s.Community},
+				OIDs:         s.Mib.ControlItems(),
+			},
+		},
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.server != nil {
+		s.server.Shutdown()
+		s.server = nil
+	}
+
+	server := GoSNMPServer.NewSNMPServer(master)
+	if err := server.ListenUDP("udp", s.ListenAddr); err != nil {
+		return err
+	}
+
+	s.server = server
+	go s.serve(server)
+	return nil
+}
+
+// GetAddress returns the address listened on
+func (s *SNMPAgentService) GetAddress() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.server == nil {
+		return ""
+	}
+	return s.server.Address().String()
+}
+
+// Stop stops serving, and disconnects the trap targets
+func (s *SNMPAgentService) Stop() {
+	s.Terminate = true
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.server != nil {
+		s.server.Shutdown()
+		s.server = nil
+	}
+
+	for _, trapper := range s.trappers {
+		_ = trapper.Conn.Close()
+	}
+	s.trappers = nil
+}
+
+func (s *SNMPAgentService) serve(server *GoSNMPServer.SNMPServer) {
+	if err := server.ServeForever(); err != nil {
+		s.Metrics.ServeErrs.Inc(1)
+		log.Err(err).Msg("SNMPAgentService.serve")
+	}
+}
+
+func (s *SNMPAgentService) run() {
+	for !s.Terminate {
+		s.Check(time.Now())
+		s.CheckEvery.Sleep()
+	}
+	s.Terminated = true
+}
+
+// Check sends the traps of the radars that went offline/online, or into or
+// out of failsafe since the previous check
+func (s *SNMPAgentService) Check(now time.Time) {
+	s.reload()
+
+	for index, radarState := range s.getRadars() {
+		isOnline := s.isRadarOnline(index, now)
+		if isOnline != s.radarsOnline[index] {
+			s.radarsOnline[index] = isOnline
+
+			notification := NotificationRadarOffline
+			if isOnline {
+				notification = NotificationRadarOnline
+			}
+			s.trap(now, notification, index+1)
+		}
+
+		isFailSafe := radarState.GetAutoFailSafe()
+		if isFailSafe != s.radarsFailSafe[index] {
+			s.radarsFailSafe[index] = isFailSafe
+
+			notification := NotificationRadarFailSafeOff
+			if isFailSafe {
+				notification = NotificationRadarFailSafeOn
+			}
+			s.trap(now, notification, index+1)
+		}
+	}
+}
+
+// reload resolves the radars of the configuration reloaded, keeping the
+// online and failsafe of the radars still configured, and listens anew for
+// the rows
+func (s *SNMPAgentService) reload() {
+	if s.state == nil {
+		return
+	}
+
+	config, ok := s.state.Get(servicemodel.StateName).(*servicemodel.Config)
+	if !ok || config == s.config {
+		return
+	}
+
+	radars := s.radarsOf(config)
+	radarsOnline := make([]bool, len(radars))
+	radarsFailSafe := make([]bool, len(radars))
+
+	for index, radarState := range radars {
+		for previousIndex, previous := range s.getRadars() {
+			if previous == radarState {
+				radarsOnline[index] = s.radarsOnline[previousIndex]
+				radarsFailSafe[index] = s.radarsFailSafe[previousIndex]
+			}
+		}
+	}
+
+	s.radarsLock.Lock()
+	s.Radars = radars
+	s.radarsLock.Unlock()
+
+	s.config = config
+	s.radarsOnline = radarsOnline
+	s.radarsFailSafe = radarsFailSafe
+
+	if err := s.listen(); err != nil {
+		s.Metrics.ServeErrs.Inc(1)
+		log.Err(err).Str("listen", s.ListenAddr).Msg("SNMPAgentService.reload")
+	}
+}
+
+func (s *SNMPAgentService) getRadars() []*state.RadarState {
+	s.radarsLock.RLock()
+	defer s.radarsLock.RUnlock()
+
+	return s.Radars
+}
+
+// getRadar returns the radar of the row index, an empty radar once the radar
+// is no longer configured
+func (s *SNMPAgentService) getRadar(index int) *state.RadarState {
+	radars := s.getRadars()
+	if index < 0 || index >= len(radars) {
+		return &state.RadarState{}
+	}
+	return radars[index]
+}
+
+// trap sends the notification, with its objects of the row, to the targets
+func (s *SNMPAgentService) trap(now time.Time, name string, row int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(s.trappers) == 0 {
+		return
+	}
+
+	variables, err := s.trapVariables(name, row)
+	if err != nil {
+		s.Metrics.TrapErrCount.IncAt(1, now)
+		log.Err(err).Str("notification", name).Msg("SNMPAgentService.trap")
+		return
+	}
+
+	for _, trapper := range s.trappers {
+		if _, err = trapper.SendTrap(gosnmp.SnmpTrap{Variables: variables}); err != nil {
+			s.Metrics.TrapErrCount.IncAt(1, now)
+			log.Err(err).Str("target", trapper.Target).Str("notification", name).Msg("SNMPAgentService.trap")
+			continue
+		}
+		s.Metrics.TrapCount.IncAt(1, now)
+	}
+}
+
+func (s *SNMPAgentService) trapVariables(name string, row int) ([]gosnmp.SnmpPDU, error) {
+	notification := s.Mib.FindNotification(name)
+	if notification == nil {
+		return nil, errors.Wrap(errUnknownNotification, name)
+	}
+
+	res := []gosnmp.SnmpPDU{
+		{
+			Name:  "1.3.6.1.2.1.1.3.0",
+			Type:  gosnmp.TimeTicks,
+			Value: uint32(time.Since(s.startOn).Milliseconds() / 10),
+		},
+		{
+			Name:  "1.3.6.1.6.3.1.1.4.1.0",
+			Type:  gosnmp.ObjectIdentifier,
+			Value: s.Mib.NotificationOID(notification),
+		},
+	}
+
+	for _, object := range notification.Objects {
+		oid, syntax, ok := s.Mib.FindColumn(object, row)
+		if !ok {
+			return nil, errors.Wrap(errUnknownNotification, object)
+		}
+
+		value := s.columnValue(object, row)
+		res = append(res, gosnmp.SnmpPDU{Name: oid, Type: syntax.Type, Value: MibValueOf(syntax, value)})
+	}
+	return res, nil
+}
+
+func (s *SNMPAgentService) columnValue(name string, row int) any {
+	for _, group := range s.Mib.Groups {
+		for _, table := range group.Tables {
+			for _, column := range table.Columns {
+				if column.Name == name && column.Get != nil {
+					return column.Get(row)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *SNMPAgentService) getVersion() any {
+	if appInfo, ok := utils.GlobalState.Get(constants.AppInfoStateName).(*utils.AppInfo); ok {
+		return appInfo.Version
+	}
+	return ""
+}
+
+func (s *SNMPAgentService) getLastDataOn(index int) time.Time {
+	return s.getRadar(index).GetActivityOn()
+}
+
+func (s *SNMPAgentService) isRadarOnline(index int, now time.Time) bool {
+	lastDataOn := s.getLastDataOn(index)
+	return !lastDataOn.IsZero() && !s.RadarOfflineTime.Expired(now, lastDataOn)
+}
+
+// getRadarDataAge returns the seconds since the radar last sent, MaxUint32
+// when it never did
+func (s *SNMPAgentService) getRadarDataAge(index int, now time.Time) uint32 {
+	lastDataOn := s.getLastDataOn(index)
+	if lastDataOn.IsZero() {
+		return math.MaxUint32
+	}
+
+	age := int64(now.Sub(lastDataOn).Seconds())
+	return uint32(max(0, min(age, math.MaxUint32-1)))
+}
+
+func (s *SNMPAgentService) getRadarReceivedCount(index int) int64 {
+	section := utils.GlobalMetrics.FindOrNil("UDP.Broker-" + s.getRadar(index).IP.String())
+	if section == nil {
+		return 0
+	}
+
+	if metric := section.Get("ReceivedCount"); metric != nil {
+		return metric.Value
+	}
+	return 0
+}
+
+func (s *SNMPAgentService) getChannelCalls() utils.Uint128 {
+	if s.CallsSource == nil {
+		return utils.Uint128{}
+	}
+	return s.CallsSource.GetChannelCalls()
+}
+
+func (s *SNMPAgentService) getStaticStatus() *uartsdlc.StaticStatus {
+	if s.StaticStatus == nil {
+		return &uartsdlc.StaticStatus{}
+	}
+	return s.StaticStatus
+}
+
+func (s *SNMPAgentService) getPingRows() int {
+	if s.Pings == nil {
+		return 0
+	}
+	return len(s.Pings.List)
+}
+
+// getMetric returns the metric selected, nil when it does not exist (yet),
+// e.g. of a radar that never sent data
+func (s *SNMPAgentService) getMetric(index int) *utils.Metric {
+	name := s.MetricNames[index]
+
+	section := utils.GlobalMetrics.FindOrNil(name.Section)
+	if section == nil {
+		return nil
+	}
+	return section.Get(name.Metric)
+}
+
+func (s *SNMPAgentService) getMetricType(index int) any {
+	metric := s.getMetric(index)
+	if metric == nil {
+		return ""
+	}
+
+	metricType, err := metric.Type.MarshalJSON()
+	if err != nil {
+		return ""
+	}
+	return strings.Trim(string(metricType), "\"")
+}
+
+func (s *SNMPAgentService) getMetricValue(index int) int64 {
+	if metric := s.getMetric(index); metric != nil {
+		return metric.Value
+	}
+	return 0
+}
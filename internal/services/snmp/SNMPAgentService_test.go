@@ -0,0 +1,229 @@
+package snmp
+
+import (
+	"math"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/internal/models/servicemodel"
+	"rvpro3/radarvision.com/internal/sdlc/uartsdlc"
+	"rvpro3/radarvision.com/internal/smartmicro/triggerpipeline"
+	"rvpro3/radarvision.com/internal/smartmicro/udp/state"
+	"rvpro3/radarvision.com/utils"
+)
+
+// testEnterpriseNumber is a private enterprise number for the tests only
+const testEnterpriseNumber = 99999
+
+type testChannelCalls utils.Uint128
+
+func (c testChannelCalls) GetChannelCalls() utils.Uint128 {
+	return utils.Uint128(c)
+}
+
+func newTestRadar(ip string, name string) *state.RadarState {
+	return &state.RadarState{
+		IP:       utils.IP4Builder.FromString(ip),
+		Name:     name,
+		FailSafe: &triggerpipeline.RadarFailsafePipelineItem{NoRadarActivitySecs: 5},
+	}
+}
+
+func newTestAgent(t *testing.T, trapTargets ...string) *SNMPAgentService {
+	res := &SNMPAgentService{
+		EnterpriseNumber: testEnterpriseNumber,
+		ListenAddr:       "127.0.0.1:0",
+		Community:        "public",
+		TrapTargets:      trapTargets,
+		TrapCommunity:    "public",
+		RadarOfflineTime: utils.Milliseconds(5 * time.Second),
+		MetricNames:      ParseMetricNames([]string{"SNMP.Test/ReadErrCount", "", "NoMetric/", "/NoSection"}),
+		Radars: []*state.RadarState{
+			newTestRadar("192.168.11.12:55555", "North"),
+			newTestRadar("192.168.11.13:55555", "South"),
+		},
+		CallsSource:  testChannelCalls(utils.Uint128{Lo: 0x1_0000_0005}),
+		StaticStatus: &uartsdlc.StaticStatus{MajorVersion: 2, MinorVersion: 3},
+	}
+
+	assert.NoError(t, res.Listen())
+	t.Cleanup(res.Stop)
+	return res
+}
+
+func newTestClient(t *testing.T, address string) *gosnmp.GoSNMP {
+	host, portStr, err := net.SplitHostPort(address)
+	assert.NoError(t, err)
+
+	port, err := strconv.Atoi(portStr)
+	assert.NoError(t, err)
+
+	client := &gosnmp.GoSNMP{
+		Target:    host,
+		Port:      uint16(port),
+		Community: "public",
+		Version:   gosnmp.Version2c,
+		Timeout:   2 * time.Second,
+	}
+	assert.NoError(t, client.Connect())
+	t.Cleanup(func() { _ = client.Conn.Close() })
+	return client
+}
+
+func TestParseMetricNames(t *testing.T) {
+	names := ParseMetricNames([]string{" UDP.Broker-1.2.3.4:5/ReceivedCount ", "", "Bad"})
+	assert.Equal(t, []MetricName{{Section: "UDP.Broker-1.2.3.4:5", Metric: "ReceivedCount"}}, names)
+}
+
+func TestSNMPAgentService_Get(t *testing.T) {
+	agent := newTestAgent(t)
+	client := newTestClient(t, agent.GetAddress())
+
+	utils.GlobalMetrics.Metric("SNMP.Test", "ReadErrCount", utils.MtI64).Set(9)
+	agent.Radars[0].FailSafe.SetUpdateOn(time.Now())
+	agent.Radars[1].FailSafe.(*triggerpipeline.RadarFailsafePipelineItem).IsActive = true
+
+	radarName, _, _ := agent.Mib.FindColumn("radarName", 2)
+	radarOnline, _, _ := agent.Mib.FindColumn("radarOnline", 1)
+	radarOffline, _, _ := agent.Mib.FindColumn("radarOnline", 2)
+	radarFailSafe, _, _ := agent.Mib.FindColumn("radarAutoFailSafe", 2)
+	metricValue, _, _ := agent.Mib.FindColumn("metricValue", 1)
+	calls := RVProMibOID(testEnterpriseNumber) + ".1.3.1.0"
+	callCount := RVProMibOID(testEnterpriseNumber) + ".1.3.3.0"
+	sdlcVersion := RVProMibOID(testEnterpriseNumber) + ".1.4.3.0"
+
+	result, err := client.Get([]string{
+		radarName, radarOnline, radarOffline, radarFailSafe, metricValue, calls, callCount, sdlcVersion,
+	})
+	if !assert.NoError(t, err) || !assert.Len(t, result.Variables, 8) {
+		return
+	}
+
+	assert.Equal(t, []byte("South"), result.Variables[0].Value)
+	assert.Equal(t, 1, result.Variables[1].Value)
+	assert.Equal(t, 2, result.Variables[2].Value)
+	assert.Equal(t, 1, result.Variables[3].Value)
+	assert.Equal(t, uint64(9), result.Variables[4].Value)
+	assert.Equal(t, uint(5), result.Variables[5].Value)
+	assert.Equal(t, uint(3), result.Variables[6].Value)
+	assert.Equal(t, []byte("2.3"), result.Variables[7].Value)
+
+	// Only the metrics parsed have a row
+	missing, _, _ := agent.Mib.FindColumn("metricValue", 2)
+	result, err = client.Get([]string{missing})
+	if assert.NoError(t, err) && assert.Len(t, result.Variables, 1) {
+		assert.Equal(t, gosnmp.NoSuchInstance, result.Variables[0].Type)
+	}
+}
+
+func TestSNMPAgentService_Walk(t *testing.T) {
+	agent := newTestAgent(t)
+	client := newTestClient(t, agent.GetAddress())
+
+	var names []string
+	err := client.BulkWalk(RVProMibOID(testEnterpriseNumber)+".1.2", func(pdu gosnmp.SnmpPDU) error {
+		names = append(names, pdu.Name)
+		return nil
+	})
+
+	// 7 accessible columns of 2 radars
+	assert.NoError(t, err)
+	assert.Len(t, names, 14)
+}
+
+func TestSNMPAgentService_Reload(t *testing.T) {
+	agent := newTestAgent(t)
+	config := servicemodel.TestBuilder.Build()
+	config.Radars = config.Radars[:3]
+
+	agent.state = &utils.State{}
+	agent.state.Init()
+	agent.state.Set(servicemodel.StateName, config)
+	agent.Check(time.Now())
+
+	client := newTestClient(t, agent.GetAddress())
+	var names []string
+	err := client.BulkWalk(RVProMibOID(testEnterpriseNumber)+".1.2", func(pdu gosnmp.SnmpPDU) error {
+		names = append(names, pdu.Name)
+		return nil
+	})
+
+	// The rows of the radars reloaded
+	assert.NoError(t, err)
+	assert.Len(t, names, 21)
+	assert.Equal(t, uint32(math.MaxUint32), agent.getRadarDataAge(2, time.Now()), "never sent")
+}
+
+func TestSNMPAgentService_Listen(t *testing.T) {
+	agent := &SNMPAgentService{ListenAddr: "127.0.0.1:0"}
+	assert.ErrorIs(t, agent.Listen(), errNoEnterpriseNumber)
+	assert.Empty(t, agent.GetAddress())
+}
+
+func TestSNMPAgentService_MetricNotRegistered(t *testing.T) {
+	agent := &SNMPAgentService{MetricNames: ParseMetricNames([]string{"SNMP.Test.Missing/NeverCount"})}
+
+	assert.Equal(t, int64(0), agent.getMetricValue(0))
+	assert.Equal(t, "", agent.getMetricType(0))
+	assert.Nil(t, utils.GlobalMetrics.FindOrNil("SNMP.Test.Missing"), "not registered by the lookup")
+}
+
+func TestSNMPAgentService_Traps(t *testing.T) {
+	traps := make(chan *gosnmp.SnmpPacket, 10)
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	go func() {
+		for {
+			// The packet values refer to the buffer
+			buffer := make([]byte, 4096)
+			n, _, err := conn.ReadFromUDP(buffer)
+			if err != nil {
+				return
+			}
+
+			if packet, err := gosnmp.Default.UnmarshalTrap(buffer[:n], false); err == nil {
+				traps <- packet
+			}
+		}
+	}()
+
+	agent := newTestAgent(t, conn.LocalAddr().String())
+	trapCount := agent.Metrics.TrapCount.Value
+	now := time.Now()
+
+	// Nothing changed, as the radars start offline and out of failsafe
+	agent.Check(now)
+
+	agent.Radars[0].FailSafe.SetUpdateOn(now)
+	agent.Check(now)
+	assertTrap(t, agent, traps, NotificationRadarOnline, "North")
+
+	agent.Radars[0].FailSafe.(*triggerpipeline.RadarFailsafePipelineItem).IsActive = true
+	agent.Check(now.Add(10 * time.Second))
+	assertTrap(t, agent, traps, NotificationRadarOffline, "North")
+	assertTrap(t, agent, traps, NotificationRadarFailSafeOn, "North")
+
+	assert.Equal(t, int64(3), agent.Metrics.TrapCount.Value-trapCount)
+	assert.Empty(t, traps)
+}
+
+func assertTrap(t *testing.T, agent *SNMPAgentService, traps chan *gosnmp.SnmpPacket, name string, radarName string) {
+	select {
+	case packet := <-traps:
+		notification := agent.Mib.FindNotification(name)
+		if assert.GreaterOrEqual(t, len(packet.Variables), 4) {
+			assert.Equal(t, "."+agent.Mib.NotificationOID(notification), packet.Variables[1].Value)
+			assert.Equal(t, []byte(radarName), packet.Variables[3].Value)
+		}
+
+	case <-time.After(2 * time.Second):
+		assert.Fail(t, "no trap received", name)
+	}
+}
@@ -0,0 +1,99 @@
+package sqlitestore
+
+import (
+	"sync"
+)
+
+// InsertQueue inserts the records queued on its writer goroutine, so the
+// inserts do not hold up the goroutine of the radar.  The records queued
+// while an insert runs are inserted together by the next, at most BatchSize
+// a time.  Queue drops the records when Size batches are waiting
+type InsertQueue[T any] struct {
+	Size      int
+	BatchSize int
+	Insert    func(recs []T)
+	channel   chan []T
+	done      chan struct{}
+	isClosed  bool
+	lock      sync.Mutex
+}
+
+// Start starts the writer goroutine, a started queue is left as is
+func (q *InsertQueue[T]) Start() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.channel != nil && !q.isClosed {
+		return
+	}
+
+	if q.Size <= 0 {
+		q.Size = 1
+	}
+
+	if q.BatchSize <= 0 {
+		q.BatchSize = 1000
+	}
+
+	q.channel = make(chan []T, q.Size)
+	q.done = make(chan struct{})
+	q.isClosed = false
+
+	go q.run(q.channel, q.done)
+}
+
+// Queue queues the records to insert, it returns false when they are dropped
+// as the queue is full or not started
+func (q *InsertQueue[T]) Queue(recs []T) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.channel == nil || q.isClosed {
+		return false
+	}
+
+	select {
+	case q.channel <- recs:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close inserts the records queued and stops the writer goroutine
+func (q *InsertQueue[T]) Close() {
+	q.lock.Lock()
+	if q.channel == nil || q.isClosed {
+		q.lock.Unlock()
+		return
+	}
+	q.isClosed = true
+	close(q.channel)
+	done := q.done
+	q.lock.Unlock()
+
+	<-done
+}
+
+func (q *InsertQueue[T]) run(channel chan []T, done chan struct{}) {
+	defer close(done)
+
+	for recs := range channel {
+		batch := append(make([]T, 0, len(recs)), recs...)
+
+	_batchLabel:
+		for len(batch) < q.BatchSize {
+			select {
+			case more, ok := <-channel:
+				if !ok {
+					break _batchLabel
+				}
+				batch = append(batch, more...)
+			default:
+				break _batchLabel
+			}
+		}
+
+		q.Insert(batch)
+	}
+}
@@ -0,0 +1,100 @@
+package sqlitestore
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	_ "modernc.org/sqlite"
+	"rvpro3/radarvision.com/utils"
+)
+
+var ErrNotOpen = errors.New("database not open")
+
+// Store is a SQLite database shared by the goroutines of a service, the
+// operations of the store are serialised on its single connection.  When
+// DeleteBefore is set, Purge removes the records beyond the Retention every
+// PurgeEvery
+type Store struct {
+	FileName     string
+	PurgeEvery   utils.Milliseconds
+	Retention    time.Duration
+	CreateTables func(db *sql.DB) error
+	DeleteBefore func(db *sql.DB, on int64) (int64, error)
+	db           *sql.DB
+	purgeOn      time.Time
+	lock         sync.Mutex
+}
+
+// Open opens (and creates) the database, an open store is left as is
+func (s *Store) Open() (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.db != nil {
+		return nil
+	}
+
+	if err = os.MkdirAll(filepath.Dir(s.FileName), 0755); err != nil {
+		return err
+	}
+
+	var db *sql.DB
+	if db, err = sql.Open("sqlite", s.FileName); err != nil {
+		return err
+	}
+
+	// The writes of sqlite are serialised, more connections only add
+	// SQLITE_BUSY errors
+	db.SetMaxOpenConns(1)
+
+	if s.CreateTables != nil {
+		if err = s.CreateTables(db); err != nil {
+			_ = db.Close()
+			return err
+		}
+	}
+
+	s.db = db
+	return nil
+}
+
+func (s *Store) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.db == nil {
+		return nil
+	}
+
+	err := s.db.Close()
+	s.db = nil
+	return err
+}
+
+// Exec runs the callback with the database, serialised with the other
+// operations of the store
+func (s *Store) Exec(callback func(db *sql.DB) error) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.db == nil {
+		return ErrNotOpen
+	}
+	return callback(s.db)
+}
+
+// Purge removes the records older than the Retention before now (in the
+// clock of the records), unless purged within PurgeEvery.  It returns the number of records
+// removed, and is called within Exec
+func (s *Store) Purge(db *sql.DB, now time.Time) (int64, error) {
+	if s.DeleteBefore == nil || now.Before(s.purgeOn) {
+		return 0, nil
+	}
+	s.purgeOn = s.PurgeEvery.Add(now)
+
+	return s.DeleteBefore(db, now.Add(-s.Retention).UnixMilli())
+}
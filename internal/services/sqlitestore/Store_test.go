@@ -0,0 +1,91 @@
+package sqlitestore
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStore(t *testing.T) *Store {
+	res := &Store{
+		FileName:   filepath.Join(t.TempDir(), "test", "test.db"),
+		PurgeEvery: 1000,
+		Retention:  time.Hour,
+		CreateTables: func(db *sql.DB) error {
+			_, err := db.Exec(`CREATE TABLE IF NOT EXISTS sample (on_time INTEGER NOT NULL)`)
+			return err
+		},
+		DeleteBefore: func(db *sql.DB, on int64) (int64, error) {
+			res, err := db.Exec(`DELETE FROM sample WHERE on_time<?`, on)
+			if err != nil {
+				return 0, err
+			}
+			return res.RowsAffected()
+		},
+	}
+	assert.NoError(t, res.Open())
+	t.Cleanup(func() { _ = res.Close() })
+	return res
+}
+
+func TestStore_Purge(t *testing.T) {
+	store := newTestStore(t)
+	now := time.Now()
+
+	assert.NoError(t, store.Exec(func(db *sql.DB) error {
+		_, err := db.Exec(`INSERT INTO sample VALUES (?), (?)`, now.Add(-2*time.Hour).UnixMilli(), now.UnixMilli())
+		return err
+	}))
+
+	var purged int64
+	assert.NoError(t, store.Exec(func(db *sql.DB) (err error) {
+		purged, err = store.Purge(db, now)
+		return err
+	}))
+	assert.Equal(t, int64(1), purged)
+
+	assert.NoError(t, store.Exec(func(db *sql.DB) (err error) {
+		purged, err = store.Purge(db, now.Add(500*time.Millisecond))
+		return err
+	}))
+	assert.Equal(t, int64(0), purged, "purged once every PurgeEvery")
+
+	assert.NoError(t, store.Close())
+	assert.ErrorIs(t, store.Exec(func(*sql.DB) error { return nil }), ErrNotOpen)
+}
+
+func TestInsertQueue_Queue(t *testing.T) {
+	var batches [][]int
+	started := make(chan bool)
+	release := make(chan bool)
+
+	queue := InsertQueue[int]{
+		Size:      2,
+		BatchSize: 10,
+		Insert: func(recs []int) {
+			if len(batches) == 0 {
+				started <- true
+				<-release
+			}
+			batches = append(batches, recs)
+		},
+	}
+	assert.False(t, queue.Queue([]int{0}), "not started")
+
+	queue.Start()
+	assert.True(t, queue.Queue([]int{1}))
+	<-started
+
+	// Queued while the first insert runs, inserted together
+	assert.True(t, queue.Queue([]int{2, 3}))
+	assert.True(t, queue.Queue([]int{4}))
+	assert.False(t, queue.Queue([]int{5}), "the queue is full")
+
+	close(release)
+	queue.Close()
+	assert.Equal(t, [][]int{{1}, {2, 3, 4}}, batches)
+	assert.False(t, queue.Queue([]int{6}), "closed")
+}
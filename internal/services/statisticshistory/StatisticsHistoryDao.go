@@ -0,0 +1,192 @@
+package statisticshistory
+
+import (
+	"database/sql"
+	"strings"
+)
+
+type statisticsHistoryDao struct{}
+
+var StatisticsHistoryDao statisticsHistoryDao
+
+// StatisticsBinRec is the statistics of a zone and class over a bin of
+// BinMinutes, from StartOn (unix milliseconds).  The speed is in the speed
+// unit of the configuration, the headway and the gap in seconds
+type StatisticsBinRec struct {
+	Id         int64   `json:"-"`
+	RadarIP    string  `json:"RadarIP"`
+	BinMinutes int     `json:"BinMinutes"`
+	StartOn    int64   `json:"StartOn"`
+	Zone       int     `json:"Zone"`
+	Class      string  `json:"Class"`
+	Volume     int     `json:"Volume"`
+	Occupancy  float64 `json:"Occupancy"`
+	AvgSpeed   float64 `json:"AvgSpeed"`
+	Headway    float64 `json:"Headway"`
+	Gap        float64 `json:"Gap"`
+	Intervals  int     `json:"Intervals"`
+}
+
+// StatisticsQuery selects the bins of BinMinutes between From and To (unix
+// milliseconds, inclusive), the empty RadarIP or Class and a negative Zone
+// select all
+type StatisticsQuery struct {
+	RadarIP    string
+	BinMinutes int
+	Zone       int
+	Class      string
+	From       int64
+	To         int64
+	MaxRows    int
+}
+
+func (statisticsHistoryDao) CreateTables(db *sql.DB) (err error) {
+	s := `CREATE TABLE IF NOT EXISTS statistics_bin (
+	id INTEGER NOT NULL PRIMARY KEY,
+	radar_ip TEXT NOT NULL,
+	bin_minutes INTEGER NOT NULL,
+	start_on INTEGER NOT NULL,
+	zone INTEGER NOT NULL,
+	class TEXT NOT NULL,
+	volume INTEGER NOT NULL,
+	occupancy REAL NOT NULL,
+	avg_speed REAL NOT NULL,
+	headway REAL NOT NULL,
+	gap REAL NOT NULL,
+	intervals INTEGER NOT NULL
+)`
+	if _, err = db.Exec(s); err != nil {
+		return err
+	}
+
+	s = `CREATE INDEX IF NOT EXISTS statistics_bin_ndx ON statistics_bin (bin_minutes, start_on, radar_ip)`
+	if _, err = db.Exec(s); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (statisticsHistoryDao) DropTables(db *sql.DB) (err error) {
+	if _, err = db.Exec(`DROP TABLE IF EXISTS statistics_bin`); err != nil {
+		return err
+	}
+
+	if _, err = db.Exec(`DROP INDEX IF EXISTS statistics_bin_ndx`); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// InsertBins inserts the bins in a single transaction
+func (statisticsHistoryDao) InsertBins(db *sql.DB, recs []*StatisticsBinRec) (err error) {
+	var tx *sql.Tx
+	var stmt *sql.Stmt
+
+	if tx, err = db.Begin(); err != nil {
+		return err
+	}
+
+	qry := `
+INSERT INTO statistics_bin
+    (radar_ip, bin_minutes, start_on, zone, class, volume, occupancy, avg_speed, headway, gap, intervals)
+VALUES
+    (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	if stmt, err = tx.Prepare(qry); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, rec := range recs {
+		var res sql.Result
+
+		res, err = stmt.Exec(
+			rec.RadarIP, rec.BinMinutes, rec.StartOn, rec.Zone, rec.Class,
+			rec.Volume, rec.Occupancy, rec.AvgSpeed, rec.Headway, rec.Gap, rec.Intervals,
+		)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+
+		if rec.Id, err = res.LastInsertId(); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteBefore removes the bins starting before the time (unix milliseconds),
+// and returns the number of bins removed
+func (statisticsHistoryDao) DeleteBefore(db *sql.DB, startOn int64) (int64, error) {
+	res, err := db.Exec(`DELETE FROM statistics_bin WHERE start_on<?`, startOn)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// SelectBins returns the bins of the query by start, radar, zone and class
+func (statisticsHistoryDao) SelectBins(db *sql.DB, query *StatisticsQuery) (recs []*StatisticsBinRec, err error) {
+	where := []string{"bin_minutes=?", "start_on>=?", "start_on<=?"}
+	args := []any{query.BinMinutes, query.From, query.To}
+
+	if query.RadarIP != "" {
+		where = append(where, "radar_ip=?")
+		args = append(args, query.RadarIP)
+	}
+
+	if query.Zone >= 0 {
+		where = append(where, "zone=?")
+		args = append(args, query.Zone)
+	}
+
+	if query.Class != "" {
+		where = append(where, "class=?")
+		args = append(args, query.Class)
+	}
+	args = append(args, query.MaxRows)
+
+	qry := `
+SELECT
+    id, radar_ip, bin_minutes, start_on, zone, class, volume, occupancy, avg_speed, headway, gap, intervals
+FROM statistics_bin
+WHERE ` + strings.Join(where, " AND ") + `
+ORDER BY start_on, radar_ip, zone, class
+LIMIT ?`
+
+	var rows *sql.Rows
+	if rows, err = db.Query(qry, args...); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recs = make([]*StatisticsBinRec, 0, 64)
+	for rows.Next() {
+		rec := new(StatisticsBinRec)
+		err = rows.Scan(
+			&rec.Id,
+			&rec.RadarIP,
+			&rec.BinMinutes,
+			&rec.StartOn,
+			&rec.Zone,
+			&rec.Class,
+			&rec.Volume,
+			&rec.Occupancy,
+			&rec.AvgSpeed,
+			&rec.Headway,
+			&rec.Gap,
+			&rec.Intervals,
+		)
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, rec)
+	}
+	return recs, rows.Err()
+}
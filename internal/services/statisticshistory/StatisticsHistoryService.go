@@ -0,0 +1,141 @@
+package statisticshistory
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"rvpro3/radarvision.com/internal/general"
+	"rvpro3/radarvision.com/internal/services/sqlitestore"
+	"rvpro3/radarvision.com/utils"
+)
+
+const StatisticsHistoryServiceName = "Statistics.History.Service"
+const statisticsHistoryFile = "statistics.history.file"
+const statisticsHistoryRetentionDays = "statistics.history.retention.days"
+const statisticsHistoryPurgeEvery = "statistics.history.purge.every"
+const statisticsHistoryMaxRows = "statistics.history.max.rows"
+const statisticsHistoryQueueSize = "statistics.history.queue.size"
+
+// StatisticsHistoryService stores the statistics bins of the radars into a
+// SQLite database, keeping RetentionDays of bins across restarts.  The bins
+// are queued by the statistics workflows, and queried by the web api
+type StatisticsHistoryService struct {
+	FileName      string
+	PurgeEvery    utils.Milliseconds
+	RetentionDays int
+	MaxRows       int
+	QueueSize     int
+	Metrics       StatisticsHistoryServiceMetrics `json:"-"`
+	store         sqlitestore.Store
+	queue         sqlitestore.InsertQueue[*StatisticsBinRec]
+}
+
+type StatisticsHistoryServiceMetrics struct {
+	InsertCount *utils.Metric
+	BinCount    *utils.Metric
+	PurgedCount *utils.Metric
+	DropCount   *utils.Metric
+	ErrCount    *utils.Metric
+	utils.MetricsInitMixin
+}
+
+func (s *StatisticsHistoryService) InitFromSettings(settings *utils.Settings) {
+	s.FileName = settings.Basic.Get(statisticsHistoryFile, "/media/SDLOGS/statistics/statistics.db")
+	s.PurgeEvery = settings.Basic.GetMilliseconds(statisticsHistoryPurgeEvery, 3600000)
+	s.RetentionDays = settings.Basic.GetInt(statisticsHistoryRetentionDays, 400)
+	s.MaxRows = settings.Basic.GetInt(statisticsHistoryMaxRows, 10000)
+	s.QueueSize = settings.Basic.GetInt(statisticsHistoryQueueSize, 64)
+}
+
+func (s *StatisticsHistoryService) Start(state *utils.State, settings *utils.Settings) {
+	if !general.ServiceHelper.ShouldStart(state, settings, s) {
+		return
+	}
+
+	if err := s.Open(); err != nil {
+		log.Err(err).Str("file", s.FileName).Msg("StatisticsHistoryService.Start")
+	}
+}
+
+func (s *StatisticsHistoryService) GetServiceName() string {
+	return StatisticsHistoryServiceName
+}
+
+// Open opens (and creates) the database, and starts the queue of the inserts
+func (s *StatisticsHistoryService) Open() error {
+	s.Metrics.InitMetrics(s.GetServiceName(), &s.Metrics)
+
+	s.store.FileName = s.FileName
+	s.store.PurgeEvery = s.PurgeEvery
+	s.store.Retention = time.Duration(s.RetentionDays) * 24 * time.Hour
+	s.store.CreateTables = StatisticsHistoryDao.CreateTables
+	s.store.DeleteBefore = StatisticsHistoryDao.DeleteBefore
+
+	if err := s.store.Open(); err != nil {
+		return err
+	}
+
+	s.queue.Size = s.QueueSize
+	s.queue.Insert = s.insertQueued
+	s.queue.Start()
+	return nil
+}
+
+// Close inserts the bins queued, and closes the database
+func (s *StatisticsHistoryService) Close() error {
+	s.queue.Close()
+	return s.store.Close()
+}
+
+// Queue queues the bins closed to be inserted on the writer goroutine, the
+// bins are dropped when the queue is full
+func (s *StatisticsHistoryService) Queue(now time.Time, recs []*StatisticsBinRec) {
+	if !s.queue.Queue(recs) {
+		s.Metrics.DropCount.IncAt(int64(len(recs)), now)
+	}
+}
+
+func (s *StatisticsHistoryService) insertQueued(recs []*StatisticsBinRec) {
+	if err := s.Insert(time.Now(), recs); err != nil {
+		log.Err(err).Msg("StatisticsHistoryService.insertQueued")
+	}
+}
+
+// Insert stores the bins closed, and purges the bins beyond the retention
+// every PurgeEvery
+func (s *StatisticsHistoryService) Insert(now time.Time, recs []*StatisticsBinRec) error {
+	err := s.store.Exec(func(db *sql.DB) error {
+		if err := StatisticsHistoryDao.InsertBins(db, recs); err != nil {
+			return err
+		}
+
+		s.Metrics.InsertCount.IncAt(1, now)
+		s.Metrics.BinCount.IncAt(int64(len(recs)), now)
+
+		// The bins are stamped with the cabinet (corrected) time
+		purged, err := s.store.Purge(db, utils.Time.Correct(now))
+		if purged > 0 {
+			s.Metrics.PurgedCount.IncAt(purged, now)
+		}
+		return err
+	})
+
+	if err != nil && err != sqlitestore.ErrNotOpen {
+		s.Metrics.ErrCount.IncAt(1, now)
+	}
+	return err
+}
+
+// Select returns the bins of the query, at most MaxRows
+func (s *StatisticsHistoryService) Select(query StatisticsQuery) (recs []*StatisticsBinRec, err error) {
+	if query.MaxRows <= 0 || query.MaxRows > s.MaxRows {
+		query.MaxRows = s.MaxRows
+	}
+
+	err = s.store.Exec(func(db *sql.DB) error {
+		recs, err = StatisticsHistoryDao.SelectBins(db, &query)
+		return err
+	})
+	return recs, err
+}
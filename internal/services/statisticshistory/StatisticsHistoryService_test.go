@@ -0,0 +1,116 @@
+package statisticshistory
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/internal/services/sqlitestore"
+	"rvpro3/radarvision.com/utils"
+)
+
+func newTestHistory(t *testing.T) *StatisticsHistoryService {
+	res := &StatisticsHistoryService{
+		FileName:      filepath.Join(t.TempDir(), "statistics.db"),
+		PurgeEvery:    1000,
+		RetentionDays: 1,
+		MaxRows:       100,
+		QueueSize:     4,
+	}
+	assert.NoError(t, res.Open())
+	t.Cleanup(func() { _ = res.Close() })
+	return res
+}
+
+func newTestBin(radarIP string, minutes int, startOn time.Time, zone int, class string) *StatisticsBinRec {
+	return &StatisticsBinRec{
+		RadarIP:    radarIP,
+		BinMinutes: minutes,
+		StartOn:    startOn.UnixMilli(),
+		Zone:       zone,
+		Class:      class,
+		Volume:     10,
+		AvgSpeed:   25.5,
+		Intervals:  minutes,
+	}
+}
+
+func TestStatisticsHistoryService_Select(t *testing.T) {
+	history := newTestHistory(t)
+	on := utils.Time.Correct(time.Now()).Truncate(time.Hour)
+
+	assert.NoError(t, history.Insert(on, []*StatisticsBinRec{
+		newTestBin("192.168.11.12", 15, on, 0, "CAR"),
+		newTestBin("192.168.11.12", 15, on, 1, "CAR"),
+		newTestBin("192.168.11.12", 15, on, 1, "LONG TRUCK"),
+		newTestBin("192.168.11.13", 15, on, 1, "LONG TRUCK"),
+		newTestBin("192.168.11.12", 5, on, 1, "LONG TRUCK"),
+	}))
+	assert.NoError(t, history.Insert(on, []*StatisticsBinRec{
+		newTestBin("192.168.11.12", 15, on.Add(15*time.Minute), 1, "LONG TRUCK"),
+	}))
+
+	query := StatisticsQuery{BinMinutes: 15, Zone: -1, From: on.UnixMilli(), To: on.Add(time.Hour).UnixMilli()}
+	bins, err := history.Select(query)
+	assert.NoError(t, err)
+	assert.Len(t, bins, 5)
+
+	query.RadarIP = "192.168.11.12"
+	query.Zone = 1
+	query.Class = "LONG TRUCK"
+	bins, err = history.Select(query)
+	assert.NoError(t, err)
+	if assert.Len(t, bins, 2) {
+		assert.Equal(t, on.UnixMilli(), bins[0].StartOn)
+		assert.Equal(t, 10, bins[0].Volume)
+		assert.Equal(t, 25.5, bins[0].AvgSpeed)
+		assert.Equal(t, on.Add(15*time.Minute).UnixMilli(), bins[1].StartOn)
+	}
+
+	query.MaxRows = 1
+	bins, err = history.Select(query)
+	assert.NoError(t, err)
+	assert.Len(t, bins, 1)
+}
+
+func TestStatisticsHistoryService_Purge(t *testing.T) {
+	history := newTestHistory(t)
+	now := time.Now()
+
+	assert.NoError(t, history.Insert(now, []*StatisticsBinRec{
+		newTestBin("192.168.11.12", 60, utils.Time.Correct(now).Add(-48*time.Hour), 1, "LONG TRUCK"),
+		newTestBin("192.168.11.12", 60, utils.Time.Correct(now).Add(-time.Hour), 1, "LONG TRUCK"),
+	}))
+
+	bins, err := history.Select(StatisticsQuery{BinMinutes: 60, Zone: -1, To: now.UnixMilli() + 1})
+	assert.NoError(t, err)
+	assert.Len(t, bins, 1, "the bins beyond the retention are purged")
+	assert.Equal(t, int64(1), history.Metrics.PurgedCount.Value)
+}
+
+func TestStatisticsHistoryService_NotOpen(t *testing.T) {
+	history := StatisticsHistoryService{}
+
+	_, err := history.Select(StatisticsQuery{})
+	assert.ErrorIs(t, err, sqlitestore.ErrNotOpen)
+	assert.ErrorIs(t, history.Insert(time.Now(), nil), sqlitestore.ErrNotOpen)
+}
+
+func TestStatisticsHistoryService_Queue(t *testing.T) {
+	history := newTestHistory(t)
+	on := utils.Time.Correct(time.Now()).Truncate(time.Hour)
+
+	history.Queue(on, []*StatisticsBinRec{newTestBin("192.168.11.12", 15, on, 1, "CAR")})
+	history.Queue(on, []*StatisticsBinRec{newTestBin("192.168.11.12", 15, on.Add(15*time.Minute), 1, "CAR")})
+
+	// Closing the queue inserts the bins queued
+	history.queue.Close()
+
+	bins, err := history.Select(StatisticsQuery{BinMinutes: 15, Zone: -1, From: on.UnixMilli(), To: on.Add(time.Hour).UnixMilli()})
+	assert.NoError(t, err)
+	assert.Len(t, bins, 2)
+
+	history.Queue(on, []*StatisticsBinRec{newTestBin("192.168.11.12", 15, on, 2, "CAR")})
+	assert.Equal(t, int64(1), history.Metrics.DropCount.Value, "dropped once closed")
+}
@@ -0,0 +1,67 @@
+package wrongway
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"rvpro3/radarvision.com/internal/smartmicro/udp/activity/trigger"
+)
+
+// IDispatchEndpoint sends the evidence package of a case, the name is
+// recorded with each attempt in wrongway_dispatch
+type IDispatchEndpoint interface {
+	GetName() string
+	Dispatch(pkg *CasePackage) error
+}
+
+// CasePackage is the evidence of a case, the case metadata and its photos
+type CasePackage struct {
+	Site   string
+	Alert  *trigger.WrongWayAlertRec
+	Photos []*trigger.WrongWayPhotoRec
+}
+
+// CaseDocument is the metadata of the case sent to the endpoints
+type CaseDocument struct {
+	Site    string
+	CaseID  string
+	StartOn string
+	Trigger string
+	Photos  []PhotoDocument
+}
+
+type PhotoDocument struct {
+	Name      string
+	Type      string
+	CaptureOn string
+}
+
+func (p *CasePackage) GetDocument() *CaseDocument {
+	res := &CaseDocument{
+		Site:    p.Site,
+		CaseID:  p.Alert.CaseID,
+		StartOn: p.Alert.StartOn,
+		Trigger: p.Alert.Trigger,
+		Photos:  make([]PhotoDocument, 0, len(p.Photos)),
+	}
+
+	for _, photo := range p.Photos {
+		res.Photos = append(res.Photos, PhotoDocument{
+			Name:      filepath.Base(photo.PhotoPath),
+			Type:      photo.PhotoType,
+			CaptureOn: photo.CaptureOn,
+		})
+	}
+	return res
+}
+
+func (p *CasePackage) GetDocumentJSON() ([]byte, error) {
+	return json.MarshalIndent(p.GetDocument(), "", "  ")
+}
+
+// ReadPhoto returns the photo name and content
+func (p *CasePackage) ReadPhoto(photo *trigger.WrongWayPhotoRec) (string, []byte, error) {
+	data, err := os.ReadFile(photo.PhotoPath)
+	return filepath.Base(photo.PhotoPath), data, err
+}
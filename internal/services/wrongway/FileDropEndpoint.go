@@ -0,0 +1,61 @@
+package wrongway
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// FileDropEndpoint copies the case in a directory per case (Path/CaseID),
+// the CaseDocument as case.json with the photos.  The directory is written
+// under a temporary name first, a case dropped is always complete.  Path
+// is generally the mount of a network share
+type FileDropEndpoint struct {
+	Path string
+}
+
+func (e *FileDropEndpoint) GetName() string {
+	return "file:" + e.Path
+}
+
+func (e *FileDropEndpoint) Dispatch(pkg *CasePackage) error {
+	target := filepath.Join(e.Path, pkg.Alert.CaseID)
+	temp := filepath.Join(e.Path, "."+pkg.Alert.CaseID)
+
+	// The leftovers of a previous attempt
+	_ = os.RemoveAll(temp)
+
+	if err := os.MkdirAll(temp, 0755); err != nil {
+		return err
+	}
+
+	if err := e.write(temp, pkg); err != nil {
+		_ = os.RemoveAll(temp)
+		return err
+	}
+
+	_ = os.RemoveAll(target)
+	return os.Rename(temp, target)
+}
+
+func (e *FileDropEndpoint) write(dir string, pkg *CasePackage) error {
+	document, err := pkg.GetDocumentJSON()
+	if err != nil {
+		return err
+	}
+
+	if err = os.WriteFile(filepath.Join(dir, "case.json"), document, 0644); err != nil {
+		return err
+	}
+
+	for _, photo := range pkg.Photos {
+		name, data, err := pkg.ReadPhoto(photo)
+		if err != nil {
+			return err
+		}
+
+		if err = os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package wrongway
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+)
+
+// base64LineLength is the line length of the attachments (RFC 2045)
+const base64LineLength = 76
+
+// SMTPEndpoint mails the case, the case metadata as text with the photos
+// attached.  The authentication is only used when User is set
+type SMTPEndpoint struct {
+	Addr     string
+	From     string
+	To       []string
+	User     string
+	Password string
+}
+
+func (e *SMTPEndpoint) GetName() string {
+	return "smtp:" + e.Addr
+}
+
+func (e *SMTPEndpoint) Dispatch(pkg *CasePackage) error {
+	msg, err := e.getMessage(pkg)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if e.User != "" {
+		host, _, _ := net.SplitHostPort(e.Addr)
+		auth = smtp.PlainAuth("", e.User, e.Password, host)
+	}
+	return smtp.SendMail(e.Addr, auth, e.From, e.To, msg)
+}
+
+func (e *SMTPEndpoint) getMessage(pkg *CasePackage) ([]byte, error) {
+	msg := new(bytes.Buffer)
+	writer := multipart.NewWriter(msg)
+
+	_, _ = fmt.Fprintf(msg, "From: %s\r\n", e.From)
+	_, _ = fmt.Fprintf(msg, "To: %s\r\n", strings.Join(e.To, ", "))
+	_, _ = fmt.Fprintf(msg, "Subject: Wrong way %s %s\r\n", pkg.Site, pkg.Alert.StartOn)
+	_, _ = fmt.Fprintf(msg, "MIME-Version: 1.0\r\n")
+	_, _ = fmt.Fprintf(msg, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", writer.Boundary())
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return nil, err
+	}
+
+	document := pkg.GetDocument()
+	_, _ = fmt.Fprintf(part, "Site: %s\r\nCase: %s\r\nStart: %s\r\nTrigger: %s\r\nPhotos: %d\r\n",
+		document.Site, document.CaseID, document.StartOn, document.Trigger, len(document.Photos))
+
+	for _, photo := range pkg.Photos {
+		name, data, err := pkg.ReadPhoto(photo)
+		if err != nil {
+			return nil, err
+		}
+
+		header = textproto.MIMEHeader{}
+		header.Set("Content-Type", "image/jpeg")
+		header.Set("Content-Transfer-Encoding", "base64")
+		header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+
+		if part, err = writer.CreatePart(header); err != nil {
+			return nil, err
+		}
+
+		encoded := base64.StdEncoding.EncodeToString(data)
+		for len(encoded) > 0 {
+			line := encoded[:min(base64LineLength, len(encoded))]
+			encoded = encoded[len(line):]
+			_, _ = part.Write([]byte(line + "\r\n"))
+		}
+	}
+
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+	return msg.Bytes(), nil
+}
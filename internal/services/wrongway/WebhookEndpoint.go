@@ -0,0 +1,101 @@
+package wrongway
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// WebhookEndpoint posts the case as multipart/form-data: the "case" part is
+// the CaseDocument (json), followed by a "photo" part per photo
+type WebhookEndpoint struct {
+	URL     string
+	Token   string
+	Timeout time.Duration
+	client  http.Client
+}
+
+func (e *WebhookEndpoint) GetName() string {
+	return "webhook:" + e.URL
+}
+
+func (e *WebhookEndpoint) Dispatch(pkg *CasePackage) error {
+	body, contentType, err := e.getBody(pkg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.URL, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	if e.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+e.Token)
+	}
+
+	e.client.Timeout = e.Timeout
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("webhook status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (e *WebhookEndpoint) getBody(pkg *CasePackage) (*bytes.Buffer, string, error) {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+
+	document, err := pkg.GetDocumentJSON()
+	if err != nil {
+		return nil, "", err
+	}
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="case"`)
+	header.Set("Content-Type", "application/json")
+
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err = part.Write(document); err != nil {
+		return nil, "", err
+	}
+
+	for _, photo := range pkg.Photos {
+		name, data, err := pkg.ReadPhoto(photo)
+		if err != nil {
+			return nil, "", err
+		}
+
+		header = textproto.MIMEHeader{}
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="photo"; filename="%s"`, name))
+		header.Set("Content-Type", "image/jpeg")
+
+		if part, err = writer.CreatePart(header); err != nil {
+			return nil, "", err
+		}
+		if _, err = part.Write(data); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err = writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return body, writer.FormDataContentType(), nil
+}
@@ -0,0 +1,313 @@
+package wrongway
+
+import (
+	"database/sql"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"rvpro3/radarvision.com/internal/general"
+	"rvpro3/radarvision.com/internal/smartmicro/udp/activity/trigger"
+	"rvpro3/radarvision.com/utils"
+)
+
+const WrongWayDispatchServiceName = "WrongWay.Dispatch.Service"
+const wrongWayDispatchEvery = "wrongway.dispatch.every"
+const wrongWayDispatchSite = "wrongway.dispatch.site"
+const wrongWayDispatchRetryMin = "wrongway.dispatch.retry.min"
+const wrongWayDispatchRetryMax = "wrongway.dispatch.retry.max"
+const wrongWayDispatchAttempts = "wrongway.dispatch.attempts"
+const wrongWayDispatchWebhookURL = "wrongway.dispatch.webhook.url"
+const wrongWayDispatchWebhookToken = "wrongway.dispatch.webhook.token"
+const wrongWayDispatchWebhookTimeout = "wrongway.dispatch.webhook.timeout"
+const wrongWayDispatchSMTPAddr = "wrongway.dispatch.smtp.addr"
+const wrongWayDispatchSMTPFrom = "wrongway.dispatch.smtp.from"
+const wrongWayDispatchSMTPTo = "wrongway.dispatch.smtp.to"
+const wrongWayDispatchSMTPUser = "wrongway.dispatch.smtp.user"
+const wrongWayDispatchSMTPPassword = "wrongway.dispatch.smtp.password"
+const wrongWayDispatchFilePath = "wrongway.dispatch.file.path"
+
+// dispatchPageSize is the number of open cases read at once
+const dispatchPageSize = 50
+
+// WrongWayDispatchService sends the open cases of the WrongWayStore to each
+// endpoint, every attempt is recorded in wrongway_dispatch.  An endpoint
+// failing is retried after RetryMin, doubling up to RetryMax, for at most
+// MaxAttempts.  The case is dispatched once sent to every endpoint, failed
+// once the attempts of the endpoints not sent to are exhausted
+type WrongWayDispatchService struct {
+	Every       utils.Milliseconds
+	RetryMin    utils.Milliseconds
+	RetryMax    utils.Milliseconds
+	MaxAttempts int
+	Site        string
+	Endpoints   []IDispatchEndpoint    `json:"-"`
+	Store       *trigger.WrongWayStore `json:"-"`
+	Terminate   bool
+	Terminated  bool
+	Metrics     WrongWayDispatchMetrics `json:"-"`
+	wakeChannel chan bool
+}
+
+type WrongWayDispatchMetrics struct {
+	Iterations      *utils.Metric
+	AttemptCount    *utils.Metric
+	AttemptErrors   *utils.Metric
+	CasesDispatched *utils.Metric
+	CasesFailed     *utils.Metric
+	ErrCount        *utils.Metric
+	utils.MetricsInitMixin
+}
+
+// endpointState is the dispatch of a case to an endpoint, read from the
+// attempts recorded
+type endpointState struct {
+	Attempts int
+	IsSent   bool
+	LastOn   time.Time
+}
+
+func (s *WrongWayDispatchService) InitFromSettings(settings *utils.Settings) {
+	hostName, _ := os.Hostname()
+
+	s.Every = settings.Basic.GetMilliseconds(wrongWayDispatchEvery, 5000)
+	s.RetryMin = settings.Basic.GetMilliseconds(wrongWayDispatchRetryMin, 10000)
+	s.RetryMax = settings.Basic.GetMilliseconds(wrongWayDispatchRetryMax, 3600000)
+	s.MaxAttempts = settings.Basic.GetInt(wrongWayDispatchAttempts, 20)
+	s.Site = settings.Basic.Get(wrongWayDispatchSite, hostName)
+	s.Endpoints = nil
+
+	if url := settings.Basic.Get(wrongWayDispatchWebhookURL, ""); url != "" {
+		s.Endpoints = append(s.Endpoints, &WebhookEndpoint{
+			URL:     url,
+			Token:   settings.Basic.Get(wrongWayDispatchWebhookToken, ""),
+			Timeout: time.Duration(settings.Basic.GetMilliseconds(wrongWayDispatchWebhookTimeout, 30000)),
+		})
+	}
+
+	if addr := settings.Basic.Get(wrongWayDispatchSMTPAddr, ""); addr != "" {
+		s.Endpoints = append(s.Endpoints, &SMTPEndpoint{
+			Addr:     addr,
+			From:     settings.Basic.Get(wrongWayDispatchSMTPFrom, "rvpro@radarvision.com"),
+			To:       strings.Split(settings.Basic.Get(wrongWayDispatchSMTPTo, ""), ";"),
+			User:     settings.Basic.Get(wrongWayDispatchSMTPUser, ""),
+			Password: settings.Basic.Get(wrongWayDispatchSMTPPassword, ""),
+		})
+	}
+
+	if path := settings.Basic.Get(wrongWayDispatchFilePath, ""); path != "" {
+		s.Endpoints = append(s.Endpoints, &FileDropEndpoint{Path: path})
+	}
+}
+
+func (s *WrongWayDispatchService) Start(state *utils.State, settings *utils.Settings) {
+	if !general.ServiceHelper.ShouldStart(state, settings, s) {
+		return
+	}
+
+	if len(s.Endpoints) == 0 {
+		log.Warn().Msg("WrongWayDispatchService.Start: no endpoints")
+		return
+	}
+
+	if s.Store == nil {
+		var err error
+		if s.Store, err = trigger.WrongWayStoreHelper.GetOrOpen(settings); err != nil {
+			log.Err(err).Str("file", s.Store.FileName).Msg("WrongWayDispatchService.Start")
+			return
+		}
+	}
+
+	s.Init()
+	go s.run()
+}
+
+func (s *WrongWayDispatchService) GetServiceName() string {
+	return WrongWayDispatchServiceName
+}
+
+// Init wakes the service on the cases queued
+func (s *WrongWayDispatchService) Init() {
+	s.Metrics.InitMetrics(s.GetServiceName(), &s.Metrics)
+	s.wakeChannel = make(chan bool, 1)
+	s.Store.OnCaseQueued = s.onCaseQueued
+}
+
+func (s *WrongWayDispatchService) onCaseQueued(_ *trigger.WrongWayAlertRec) {
+	select {
+	case s.wakeChannel <- true:
+	default:
+	}
+}
+
+func (s *WrongWayDispatchService) run() {
+	for !s.Terminate {
+		select {
+		case <-s.wakeChannel:
+		case <-time.After(time.Duration(s.Every)):
+		}
+
+		if err := s.Dispatch(time.Now()); err != nil {
+			log.Err(err).Msg("WrongWayDispatchService.run")
+		}
+	}
+	s.Terminated = true
+}
+
+// Dispatch sends the open cases to the endpoints due
+func (s *WrongWayDispatchService) Dispatch(now time.Time) error {
+	s.Metrics.Iterations.IncAt(1, now)
+
+	idAfter := int64(0)
+	for {
+		var alerts []*trigger.WrongWayAlertRec
+
+		err := s.Store.Exec(func(db *sql.DB) (err error) {
+			alerts, err = trigger.WrongWayDao.SelectAlertList(db, idAfter, trigger.WrongWayStatusOpen, dispatchPageSize)
+			return err
+		})
+		if err != nil {
+			s.Metrics.ErrCount.IncAt(1, now)
+			return err
+		}
+
+		for _, alert := range alerts {
+			if err = s.dispatchCase(now, alert); err != nil {
+				s.Metrics.ErrCount.IncAt(1, now)
+				return err
+			}
+			idAfter = alert.Id
+		}
+
+		if len(alerts) < dispatchPageSize {
+			return nil
+		}
+	}
+}
+
+func (s *WrongWayDispatchService) dispatchCase(now time.Time, alert *trigger.WrongWayAlertRec) error {
+	states, err := s.getEndpointStates(alert.CaseID)
+	if err != nil {
+		return err
+	}
+
+	var pkg *CasePackage
+	isSent, isPending := true, false
+
+	for _, endpoint := range s.Endpoints {
+		state := states[endpoint.GetName()]
+
+		if !state.IsSent && state.Attempts < s.MaxAttempts && s.isDue(now, state) {
+			if pkg == nil {
+				if pkg, err = s.getPackage(alert); err != nil {
+					return err
+				}
+			}
+
+			if err = s.attempt(now, endpoint, pkg); err != nil {
+				return err
+			}
+
+			if states, err = s.getEndpointStates(alert.CaseID); err != nil {
+				return err
+			}
+			state = states[endpoint.GetName()]
+		}
+
+		isSent = isSent && state.IsSent
+		isPending = isPending || (!state.IsSent && state.Attempts < s.MaxAttempts)
+	}
+
+	switch {
+	case isSent:
+		s.Metrics.CasesDispatched.IncAt(1, now)
+		return s.updateStatus(alert.CaseID, trigger.WrongWayStatusDispatched)
+
+	case !isPending:
+		s.Metrics.CasesFailed.IncAt(1, now)
+		return s.updateStatus(alert.CaseID, trigger.WrongWayStatusFailed)
+	}
+	return nil
+}
+
+// isDue returns true when the endpoint is due an attempt, the delay after an
+// attempt failed doubling from RetryMin up to RetryMax
+func (s *WrongWayDispatchService) isDue(now time.Time, state endpointState) bool {
+	if state.Attempts == 0 {
+		return true
+	}
+	return !utils.Time.Correct(now).Before(state.LastOn.Add(s.GetRetryDelay(state.Attempts)))
+}
+
+// GetRetryDelay returns the delay after the attempt (1 for the first)
+func (s *WrongWayDispatchService) GetRetryDelay(attempt int) time.Duration {
+	delay := time.Duration(s.RetryMin)
+
+	for i := 1; i < attempt && delay < time.Duration(s.RetryMax); i++ {
+		delay *= 2
+	}
+	return min(delay, time.Duration(s.RetryMax))
+}
+
+// attempt sends the package to the endpoint, recording the attempt
+func (s *WrongWayDispatchService) attempt(now time.Time, endpoint IDispatchEndpoint, pkg *CasePackage) error {
+	s.Metrics.AttemptCount.IncAt(1, now)
+
+	rec := trigger.WrongWayDispatchRec{
+		CaseID:     pkg.Alert.CaseID,
+		DispatchOn: trigger.WrongWayDao.ToDateStr(utils.Time.Correct(now)),
+		EndPoint:   endpoint.GetName(),
+	}
+
+	if err := endpoint.Dispatch(pkg); err != nil {
+		s.Metrics.AttemptErrors.IncAt(1, now)
+		log.Err(err).Str("case", rec.CaseID).Str("endpoint", rec.EndPoint).Msg("WrongWayDispatchService.attempt")
+		rec.Error = err.Error()
+	}
+
+	return s.Store.Exec(func(db *sql.DB) error {
+		return trigger.WrongWayDao.InsertDispatch(db, &rec)
+	})
+}
+
+func (s *WrongWayDispatchService) getEndpointStates(caseID string) (map[string]endpointState, error) {
+	var recs []*trigger.WrongWayDispatchRec
+
+	err := s.Store.Exec(func(db *sql.DB) (err error) {
+		recs, err = trigger.WrongWayDao.SelectDispatchList(db, caseID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]endpointState, len(s.Endpoints))
+	for _, rec := range recs {
+		state := res[rec.EndPoint]
+		state.Attempts++
+		state.IsSent = state.IsSent || rec.Error == ""
+
+		if on, err := trigger.WrongWayDao.FromDateStr(rec.DispatchOn); err == nil && on.After(state.LastOn) {
+			state.LastOn = on
+		}
+		res[rec.EndPoint] = state
+	}
+	return res, nil
+}
+
+func (s *WrongWayDispatchService) getPackage(alert *trigger.WrongWayAlertRec) (*CasePackage, error) {
+	res := &CasePackage{Site: s.Site, Alert: alert}
+
+	err := s.Store.Exec(func(db *sql.DB) (err error) {
+		res.Photos, err = trigger.WrongWayDao.SelectPhotoList(db, alert.CaseID)
+		return err
+	})
+	return res, err
+}
+
+func (s *WrongWayDispatchService) updateStatus(caseID string, status string) error {
+	return s.Store.Exec(func(db *sql.DB) error {
+		return trigger.WrongWayDao.UpdateWrongWay(db, caseID, status)
+	})
+}
@@ -0,0 +1,305 @@
+package wrongway
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/internal/smartmicro/udp/activity/trigger"
+	"rvpro3/radarvision.com/utils"
+)
+
+func newTestDispatch(t *testing.T, endpoints ...IDispatchEndpoint) *WrongWayDispatchService {
+	store := &trigger.WrongWayStore{FileName: filepath.Join(t.TempDir(), "wrongway.db")}
+	assert.NoError(t, store.Open())
+	t.Cleanup(func() { _ = store.Close() })
+
+	res := &WrongWayDispatchService{
+		RetryMin:    utils.Milliseconds(time.Second),
+		RetryMax:    utils.Milliseconds(4 * time.Second),
+		MaxAttempts: 5,
+		Site:        "Test",
+		Endpoints:   endpoints,
+		Store:       store,
+	}
+	res.Init()
+	return res
+}
+
+// queueTestCase stores a closed case of two photos
+func queueTestCase(t *testing.T, s *WrongWayDispatchService, caseID string) {
+	caseDir := filepath.Join(t.TempDir(), caseID)
+	assert.NoError(t, os.MkdirAll(caseDir, 0755))
+
+	assert.NoError(t, s.Store.InsertAlert(&trigger.WrongWayAlertRec{
+		CaseID:  caseID,
+		CaseDir: caseDir,
+		StartOn: trigger.WrongWayDao.ToDateStr(time.Now()),
+		Status:  trigger.WrongWayStatusRecording,
+		Trigger: "0x4",
+	}))
+
+	for index, photoType := range []string{trigger.WrongWayPhotoHead, trigger.WrongWayPhotoCase} {
+		photoPath := filepath.Join(caseDir, fmt.Sprintf("%03d-%s.jpg", index+1, photoType))
+		assert.NoError(t, os.WriteFile(photoPath, []byte(photoType), 0644))
+		assert.NoError(t, s.Store.InsertPhoto(&trigger.WrongWayPhotoRec{
+			CaseID:    caseID,
+			CaptureOn: trigger.WrongWayDao.ToDateStr(time.Now()),
+			PhotoType: photoType,
+			PhotoPath: photoPath,
+		}))
+	}
+
+	_, err := s.Store.QueueCase(caseID)
+	assert.NoError(t, err)
+}
+
+func selectCase(t *testing.T, s *WrongWayDispatchService, caseID string) (alert *trigger.WrongWayAlertRec, dispatches []*trigger.WrongWayDispatchRec) {
+	assert.NoError(t, s.Store.Exec(func(db *sql.DB) (err error) {
+		if alert, err = trigger.WrongWayDao.SelectWrongWay(db, caseID); err != nil {
+			return err
+		}
+		dispatches, err = trigger.WrongWayDao.SelectDispatchList(db, caseID)
+		return err
+	}))
+	return alert, dispatches
+}
+
+// webhookStandIn answers the status of statuses in turn (the last repeated),
+// keeping the requests read
+type webhookStandIn struct {
+	Statuses  []int
+	Documents []CaseDocument
+	Photos    [][]string
+	lock      sync.Mutex
+}
+
+func (w *webhookStandIn) ServeHTTP(writer http.ResponseWriter, req *http.Request) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	var document CaseDocument
+	var photos []string
+
+	reader, err := req.MultipartReader()
+	for err == nil {
+		var part *multipart.Part
+		if part, err = reader.NextPart(); err != nil {
+			break
+		}
+
+		data, _ := io.ReadAll(part)
+		switch part.FormName() {
+		case "case":
+			_ = json.Unmarshal(data, &document)
+		case "photo":
+			photos = append(photos, part.FileName()+"="+string(data))
+		}
+	}
+
+	w.Documents = append(w.Documents, document)
+	w.Photos = append(w.Photos, photos)
+
+	status := w.Statuses[min(len(w.Documents), len(w.Statuses))-1]
+	writer.WriteHeader(status)
+}
+
+// startSMTPStandIn accepts the mails sent, without extensions (no STARTTLS)
+func startSMTPStandIn(t *testing.T) (string, chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("SMTP stand-in not available", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	mails := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, mails)
+		}
+	}()
+	return listener.Addr().String(), mails
+}
+
+func serveSMTP(conn net.Conn, mails chan string) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	_, _ = fmt.Fprint(conn, "220 localhost\r\n")
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		switch command := strings.ToUpper(strings.Fields(line + " ")[0]); command {
+		case "DATA":
+			_, _ = fmt.Fprint(conn, "354 go ahead\r\n")
+			var data strings.Builder
+			for {
+				if line, err = reader.ReadString('\n'); err != nil || line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			mails <- data.String()
+			_, _ = fmt.Fprint(conn, "250 OK\r\n")
+		case "QUIT":
+			_, _ = fmt.Fprint(conn, "221 bye\r\n")
+			return
+		default:
+			_, _ = fmt.Fprint(conn, "250 OK\r\n")
+		}
+	}
+}
+
+func TestWrongWayDispatchService_Dispatch(t *testing.T) {
+	webhook := &webhookStandIn{Statuses: []int{http.StatusOK}}
+	server := httptest.NewServer(webhook)
+	defer server.Close()
+
+	smtpAddr, mails := startSMTPStandIn(t)
+	dropPath := t.TempDir()
+
+	s := newTestDispatch(t,
+		&WebhookEndpoint{URL: server.URL, Timeout: 5 * time.Second},
+		&SMTPEndpoint{Addr: smtpAddr, From: "rvpro@radarvision.com", To: []string{"ops@radarvision.com"}},
+		&FileDropEndpoint{Path: dropPath},
+	)
+	queueTestCase(t, s, "case-1")
+	assert.Len(t, s.wakeChannel, 1, "the service is woken on the case queued")
+
+	assert.NoError(t, s.Dispatch(time.Now()))
+
+	alert, dispatches := selectCase(t, s, "case-1")
+	assert.Equal(t, trigger.WrongWayStatusDispatched, alert.Status)
+	if assert.Len(t, dispatches, 3) {
+		for _, dispatch := range dispatches {
+			assert.Empty(t, dispatch.Error)
+		}
+		assert.Equal(t, "webhook:"+server.URL, dispatches[0].EndPoint)
+	}
+
+	// Webhook, the case json followed by the photos
+	if assert.Len(t, webhook.Documents, 1) {
+		assert.Equal(t, "case-1", webhook.Documents[0].CaseID)
+		assert.Equal(t, "Test", webhook.Documents[0].Site)
+		assert.Len(t, webhook.Documents[0].Photos, 2)
+		assert.Equal(t, []string{"001-head.jpg=head", "002-case.jpg=case"}, webhook.Photos[0])
+	}
+
+	// SMTP, the photos attached
+	select {
+	case data := <-mails:
+		msg, err := mail.ReadMessage(strings.NewReader(data))
+		if assert.NoError(t, err) {
+			assert.Equal(t, "ops@radarvision.com", msg.Header.Get("To"))
+
+			_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+			assert.NoError(t, err)
+
+			var attachments []string
+			reader := multipart.NewReader(msg.Body, params["boundary"])
+			for part, err := reader.NextPart(); err == nil; part, err = reader.NextPart() {
+				if part.FileName() != "" {
+					attachments = append(attachments, part.FileName())
+				}
+			}
+			assert.Equal(t, []string{"001-head.jpg", "002-case.jpg"}, attachments)
+		}
+	default:
+		t.Error("no mail sent")
+	}
+
+	// File drop, a directory per case
+	data, err := os.ReadFile(filepath.Join(dropPath, "case-1", "002-case.jpg"))
+	assert.NoError(t, err)
+	assert.Equal(t, "case", string(data))
+	assert.FileExists(t, filepath.Join(dropPath, "case-1", "case.json"))
+
+	// A dispatched case is not sent again
+	assert.NoError(t, s.Dispatch(time.Now()))
+	assert.Len(t, webhook.Documents, 1)
+}
+
+func TestWrongWayDispatchService_Retry(t *testing.T) {
+	webhook := &webhookStandIn{Statuses: []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK}}
+	server := httptest.NewServer(webhook)
+	defer server.Close()
+
+	s := newTestDispatch(t, &WebhookEndpoint{URL: server.URL, Timeout: 5 * time.Second})
+	queueTestCase(t, s, "case-retry")
+
+	now := time.Now()
+	for _, offset := range []time.Duration{0, 500 * time.Millisecond, time.Second, 2500 * time.Millisecond} {
+		assert.NoError(t, s.Dispatch(now.Add(offset)))
+	}
+
+	// Attempted at 0 and 1s, the next due at 1s + 2s
+	alert, dispatches := selectCase(t, s, "case-retry")
+	assert.Equal(t, trigger.WrongWayStatusOpen, alert.Status)
+	if assert.Len(t, dispatches, 2) {
+		assert.Equal(t, "webhook status 500", dispatches[0].Error)
+		assert.Equal(t, "webhook status 502", dispatches[1].Error)
+	}
+
+	assert.NoError(t, s.Dispatch(now.Add(3*time.Second)))
+	alert, dispatches = selectCase(t, s, "case-retry")
+	assert.Equal(t, trigger.WrongWayStatusDispatched, alert.Status)
+	if assert.Len(t, dispatches, 3) {
+		assert.Empty(t, dispatches[2].Error)
+	}
+}
+
+func TestWrongWayDispatchService_Exhausted(t *testing.T) {
+	webhook := &webhookStandIn{Statuses: []int{http.StatusServiceUnavailable}}
+	server := httptest.NewServer(webhook)
+	defer server.Close()
+
+	dropPath := t.TempDir()
+	s := newTestDispatch(t, &WebhookEndpoint{URL: server.URL, Timeout: 5 * time.Second}, &FileDropEndpoint{Path: dropPath})
+	s.MaxAttempts = 2
+	queueTestCase(t, s, "case-failed")
+
+	now := time.Now()
+	assert.NoError(t, s.Dispatch(now))
+	alert, _ := selectCase(t, s, "case-failed")
+	assert.Equal(t, trigger.WrongWayStatusOpen, alert.Status)
+
+	assert.NoError(t, s.Dispatch(now.Add(time.Second)))
+	alert, dispatches := selectCase(t, s, "case-failed")
+	assert.Equal(t, trigger.WrongWayStatusFailed, alert.Status)
+	assert.Len(t, dispatches, 3, "the file drop is sent once, the webhook attempted twice")
+}
+
+func TestWrongWayDispatchService_GetRetryDelay(t *testing.T) {
+	s := WrongWayDispatchService{
+		RetryMin: utils.Milliseconds(time.Second),
+		RetryMax: utils.Milliseconds(5 * time.Second),
+	}
+
+	assert.Equal(t, time.Second, s.GetRetryDelay(1))
+	assert.Equal(t, 2*time.Second, s.GetRetryDelay(2))
+	assert.Equal(t, 4*time.Second, s.GetRetryDelay(3))
+	assert.Equal(t, 5*time.Second, s.GetRetryDelay(4))
+	assert.Equal(t, 5*time.Second, s.GetRetryDelay(100))
+}
@@ -0,0 +1,81 @@
+package backup
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"rvpro3/radarvision.com/internal/smartmicro/port"
+	"rvpro3/radarvision.com/internal/smartmicro/service"
+)
+
+var errInstructionTimeout = errors.New("instruction response timeout")
+var errInstructionDropped = errors.New("instruction dropped after retries")
+
+// IInstructionClient sends an instruction to the radar and returns the
+// response
+type IInstructionClient interface {
+	Send(request *port.Instruction) (*port.Instruction, error)
+}
+
+// InstructionClient sends the instructions one at a time over the
+// (asynchronous) service.Instruction, awaiting each response
+type InstructionClient struct {
+	Service   *service.Instruction
+	Timeout   time.Duration
+	MaxTries  int
+	responses chan *service.SendQueueItem
+}
+
+func NewInstructionClient(insService *service.Instruction, timeout time.Duration, maxTries int) *InstructionClient {
+	res := &InstructionClient{
+		Service:   insService,
+		Timeout:   timeout,
+		MaxTries:  maxTries,
+		responses: make(chan *service.SendQueueItem, 8),
+	}
+
+	insService.OnResponse = res.onResponse
+	insService.OnDropInstruction = res.onDropInstruction
+	return res
+}
+
+func (c *InstructionClient) Send(request *port.Instruction) (*port.Instruction, error) {
+	c.Service.EnqueueSend(request, c.MaxTries)
+
+	timeout := time.NewTimer(c.Timeout)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case item := <-c.responses:
+			// Responses to requests that timed out earlier are ignored
+			if item.Request != request {
+				continue
+			}
+
+			if item.Response == nil {
+				return nil, errInstructionDropped
+			}
+			return item.Response, nil
+
+		case <-timeout.C:
+			return nil, errInstructionTimeout
+		}
+	}
+}
+
+func (c *InstructionClient) onResponse(_ *service.Instruction, item *service.SendQueueItem) {
+	c.push(item)
+}
+
+func (c *InstructionClient) onDropInstruction(_ *service.Instruction, item *service.SendQueueItem) bool {
+	c.push(item)
+	return true
+}
+
+func (c *InstructionClient) push(item *service.SendQueueItem) {
+	select {
+	case c.responses <- item:
+	default:
+	}
+}
@@ -0,0 +1,221 @@
+package backup
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"rvpro3/radarvision.com/internal/smartmicro/instruction/face08700"
+	"rvpro3/radarvision.com/internal/smartmicro/port"
+	"rvpro3/radarvision.com/utils"
+)
+
+var ErrVerifyFailed = errors.New("radar parameters differ from the snapshot")
+var errNoResponseDetail = errors.New("response without the parameter")
+var errUnknownParameter = errors.New("unknown snapshot parameter")
+
+// RadarBackup walks the known sections of a radar (app parameters, zones,
+// zone segments) into a Snapshot, and restores a snapshot onto a radar
+type RadarBackup struct {
+	Client   IInstructionClient
+	ClientId uint32
+	RadarIP  utils.IP4
+	OnStep   func(parameter SnapshotParameter) `json:"-"`
+}
+
+// Mismatch is a parameter whose radar value differs from the snapshot
+type Mismatch struct {
+	Parameter SnapshotParameter
+	Actual    string
+}
+
+// Backup reads the app parameters, then the zone parameters for nof_zones
+// zones, then the segment parameters for the sum of the used_segments
+func (b *RadarBackup) Backup(now time.Time) (*Snapshot, error) {
+	res := &Snapshot{
+		Version:  SnapshotVersion,
+		RadarIP:  b.RadarIP.ToIPString(),
+		CreateOn: now,
+	}
+
+	nofZones := 0
+	nofSegments := 0
+
+	for _, def := range face08700.AppParameters {
+		parameter, err := b.read(def, 0)
+		if err != nil {
+			return nil, err
+		}
+		res.Parameters = append(res.Parameters, parameter)
+
+		if def == face08700.NofZonesParameter {
+			nofZones, _ = strconv.Atoi(parameter.Value)
+		}
+	}
+
+	for zone := 0; zone < nofZones; zone++ {
+		for _, def := range face08700.ZoneParameters {
+			parameter, err := b.read(def, zone)
+			if err != nil {
+				return nil, err
+			}
+			res.Parameters = append(res.Parameters, parameter)
+
+			if def == face08700.UsedSegmentsParameter {
+				segments, _ := strconv.Atoi(parameter.Value)
+				nofSegments += segments
+			}
+		}
+	}
+
+	for segment := 0; segment < nofSegments; segment++ {
+		for _, def := range face08700.ZoneSegmentParameters {
+			parameter, err := b.read(def, segment)
+			if err != nil {
+				return nil, err
+			}
+			res.Parameters = append(res.Parameters, parameter)
+		}
+	}
+
+	return res, nil
+}
+
+// Restore sets the snapshot parameters restored (in snapshot order), and
+// verifies them on the radar afterward.  It returns the number of parameters
+// restored, the zone parameters are skipped
+func (b *RadarBackup) Restore(snapshot *Snapshot) (int, []Mismatch, error) {
+	restored := &Snapshot{
+		Version:  snapshot.Version,
+		RadarIP:  snapshot.RadarIP,
+		CreateOn: snapshot.CreateOn,
+	}
+
+	for _, parameter := range snapshot.Parameters {
+		def, ok := face08700.FindParameter(parameter.SectionId, parameter.ParameterId)
+		if !ok {
+			return 0, nil, errors.Wrap(errUnknownParameter, parameter.String())
+		}
+		if !def.IsRestored {
+			continue
+		}
+
+		if err := b.write(parameter); err != nil {
+			return 0, nil, err
+		}
+		restored.Parameters = append(restored.Parameters, parameter)
+	}
+
+	mismatches, err := b.Verify(restored)
+	return len(restored.Parameters), mismatches, err
+}
+
+// Verify reads every snapshot parameter, and returns those that differ
+func (b *RadarBackup) Verify(snapshot *Snapshot) ([]Mismatch, error) {
+	var res []Mismatch
+
+	for _, parameter := range snapshot.Parameters {
+		def, ok := face08700.FindParameter(parameter.SectionId, parameter.ParameterId)
+		if !ok {
+			return nil, errors.Wrap(errUnknownParameter, parameter.String())
+		}
+
+		actual, err := b.read(def, parameter.Element)
+		if err != nil {
+			return nil, err
+		}
+
+		if actual.Value != parameter.Value {
+			res = append(res, Mismatch{Parameter: parameter, Actual: actual.Value})
+		}
+	}
+
+	if len(res) > 0 {
+		return res, ErrVerifyFailed
+	}
+	return nil, nil
+}
+
+func (b *RadarBackup) newInstruction() *port.Instruction {
+	res := port.NewInstruction()
+	res.Th.Flags = res.Th.Flags.Set(port.FlSourceClientId)
+	res.Th.SourceClientId = b.ClientId
+	return res
+}
+
+func (b *RadarBackup) read(def face08700.ParameterDef, element int) (SnapshotParameter, error) {
+	res := SnapshotParameter{
+		SectionId:   def.SectionId,
+		Section:     def.SectionName,
+		ParameterId: def.ParameterId,
+		Name:        def.Name,
+		DataType:    def.DataType.ToString(),
+		Element:     element,
+	}
+
+	request := b.newInstruction()
+	request.AddDetail(def.Get(element))
+
+	response, detail, err := b.send(request, res)
+	if err != nil {
+		return res, err
+	}
+
+	res.Value = detail.ToString(response.Ph.GetOrder())
+
+	if b.OnStep != nil {
+		b.OnStep(res)
+	}
+	return res, nil
+}
+
+func (b *RadarBackup) write(parameter SnapshotParameter) error {
+	def, ok := face08700.FindParameter(parameter.SectionId, parameter.ParameterId)
+	if !ok {
+		return errors.Wrap(errUnknownParameter, parameter.String())
+	}
+
+	request := b.newInstruction()
+	detail := request.AddDetail(def.Set(parameter.Element))
+
+	if err := detail.FromString(request.Ph.GetOrder(), parameter.Value); err != nil {
+		return errors.Wrap(err, parameter.String())
+	}
+
+	if _, _, err := b.send(request, parameter); err != nil {
+		return err
+	}
+
+	if b.OnStep != nil {
+		b.OnStep(parameter)
+	}
+	return nil
+}
+
+// send sends the request, and returns the response detail when successful
+func (b *RadarBackup) send(
+	request *port.Instruction,
+	parameter SnapshotParameter,
+) (*port.Instruction, *port.InstructionDetail, error) {
+	response, err := b.Client.Send(request)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, parameter.String())
+	}
+
+	for index := range response.Detail {
+		detail := &response.Detail[index]
+
+		if detail.SectionId != parameter.SectionId ||
+			detail.ParameterId != parameter.ParameterId ||
+			int(detail.Element1) != parameter.Element {
+			continue
+		}
+
+		if detail.ResponseType != port.ResTypeSuccess {
+			return nil, nil, errors.Errorf("%s: %s", parameter, detail.ResponseType.ToString())
+		}
+		return response, detail, nil
+	}
+
+	return nil, nil, errors.Wrap(errNoResponseDetail, parameter.String())
+}
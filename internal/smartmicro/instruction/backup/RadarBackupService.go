@@ -0,0 +1,188 @@
+package backup
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"rvpro3/radarvision.com/internal/constants"
+	"rvpro3/radarvision.com/internal/general"
+	"rvpro3/radarvision.com/internal/smartmicro/port"
+	"rvpro3/radarvision.com/internal/smartmicro/service"
+	"rvpro3/radarvision.com/utils"
+)
+
+const RadarBackupServiceName = "Radar.Backup.Service"
+const radarBackupSnapshotPattern = "radar.backup.snapshot.pattern"
+const radarBackupTimeout = "radar.backup.timeout"
+const radarBackupMaxTries = "radar.backup.max.tries"
+const radarBackupClientId = "udp.keepalive.clientid"
+
+var ErrBackupBusy = errors.New("a backup or restore of the radar is in progress")
+var errNoDataService = errors.New("radar backup without the UDP data service")
+
+// RadarBackupService backs up and restores the radar parameters for the api,
+// over the UDP data service of the brokers.  The instruction responses of a
+// radar are only taken while its backup or restore runs, and a radar runs one
+// backup or restore at a time.  The snapshots taken are saved to the
+// SnapshotPattern
+type RadarBackupService struct {
+	SnapshotPattern string
+	Timeout         utils.Milliseconds
+	MaxTries        int
+	ClientId        uint32
+	Metrics         RadarBackupServiceMetrics `json:"-"`
+	dataService     *service.UDPDataService
+	running         map[uint32]*service.Instruction
+	lock            sync.Mutex
+}
+
+type RadarBackupServiceMetrics struct {
+	BackupCount  *utils.Metric
+	RestoreCount *utils.Metric
+	FailCount    *utils.Metric
+	BusyCount    *utils.Metric
+	utils.MetricsInitMixin
+}
+
+func (s *RadarBackupService) InitFromSettings(settings *utils.Settings) {
+	s.SnapshotPattern = settings.Basic.Get(radarBackupSnapshotPattern, "/media/SDLOGS/backup/radar-%s.json")
+	s.Timeout = settings.Basic.GetMilliseconds(radarBackupTimeout, 10000)
+	s.MaxTries = settings.Basic.GetInt(radarBackupMaxTries, 5)
+	s.ClientId = uint32(settings.Basic.GetInt(radarBackupClientId, 0x1000001))
+}
+
+func (s *RadarBackupService) Start(state *utils.State, settings *utils.Settings) {
+	if !general.ServiceHelper.ShouldStart(state, settings, s) {
+		return
+	}
+
+	s.Metrics.InitMetrics(s.GetServiceName(), &s.Metrics)
+	s.running = make(map[uint32]*service.Instruction)
+
+	dataService, ok := state.Get(constants.UDPDataServiceName).(*service.UDPDataService)
+	if !ok {
+		log.Warn().Msg("Radar backup not configured due to no UDP data service...")
+		return
+	}
+
+	s.dataService = dataService
+	dataService.RegisterReceiver(s.OnData)
+}
+
+func (s *RadarBackupService) GetServiceName() string {
+	return RadarBackupServiceName
+}
+
+// Backup takes the snapshot of the radar, and saves it.  It returns the
+// snapshot and the file name
+func (s *RadarBackupService) Backup(radarIP utils.IP4) (*Snapshot, string, error) {
+	radarBackup, err := s.begin(radarIP)
+	if err != nil {
+		return nil, "", err
+	}
+	defer s.end(radarIP)
+
+	s.Metrics.BackupCount.Inc(1)
+	snapshot, err := radarBackup.Backup(utils.Time.Approx())
+	if err != nil {
+		s.Metrics.FailCount.Inc(1)
+		return nil, "", err
+	}
+
+	fileName := FileNameOf(s.SnapshotPattern, radarIP.ToIPString())
+	if err = snapshot.Save(fileName); err != nil {
+		s.Metrics.FailCount.Inc(1)
+		return snapshot, "", err
+	}
+	return snapshot, fileName, nil
+}
+
+// Restore writes the snapshot onto the radar, and returns the number of
+// parameters restored.  The mismatches are the parameters that differ after
+// the restore (ErrVerifyFailed)
+func (s *RadarBackupService) Restore(radarIP utils.IP4, snapshot *Snapshot) (int, []Mismatch, error) {
+	radarBackup, err := s.begin(radarIP)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer s.end(radarIP)
+
+	s.Metrics.RestoreCount.Inc(1)
+	count, mismatches, err := radarBackup.Restore(snapshot)
+	if err != nil {
+		s.Metrics.FailCount.Inc(1)
+	}
+	return count, mismatches, err
+}
+
+// begin starts the instruction service of the radar, unless a backup or
+// restore of the radar runs already
+func (s *RadarBackupService) begin(radarIP utils.IP4) (*RadarBackup, error) {
+	if s.dataService == nil {
+		return nil, errNoDataService
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.running[radarIP.ToU32()] != nil {
+		s.Metrics.BusyCount.Inc(1)
+		return nil, ErrBackupBusy
+	}
+
+	insService := new(service.Instruction)
+	insService.Init()
+	insService.ResendsCooldownMs = 2000
+	insService.IdleCooldownMs = 10
+	insService.Start(s.dataService, radarIP)
+	s.running[radarIP.ToU32()] = insService
+
+	return &RadarBackup{
+		Client:   NewInstructionClient(insService, time.Duration(s.Timeout), s.MaxTries),
+		ClientId: s.ClientId,
+		RadarIP:  radarIP,
+	}, nil
+}
+
+func (s *RadarBackupService) end(radarIP utils.IP4) {
+	s.lock.Lock()
+	insService := s.running[radarIP.ToU32()]
+	delete(s.running, radarIP.ToU32())
+	s.lock.Unlock()
+
+	if insService != nil {
+		insService.Stop()
+	}
+}
+
+// OnData is registered with the UDPDataService and is called on the UDP
+// reader goroutine, the instructions of the radars backed up or restored are
+// queued to their instruction service
+func (s *RadarBackupService) OnData(_ *service.UDPDataService, addr net.UDPAddr, bytes []byte) {
+	s.lock.Lock()
+	insService := s.running[utils.IP4Builder.FromUDPAddr(addr).ToU32()]
+	s.lock.Unlock()
+
+	if insService == nil {
+		return
+	}
+
+	th := port.TransportHeaderReader{Buffer: bytes}
+	ph := port.PortHeaderReader{Buffer: bytes, StartOffset: int(th.GetHeaderLength())}
+
+	if ph.Check() != nil || ph.GetIdentifier() != port.PiInstruction {
+		return
+	}
+
+	ins := &port.Instruction{}
+	reader := utils.NewFixedBuffer(bytes, 0, len(bytes))
+
+	if err := ins.Read(&reader); err != nil {
+		log.Err(err).Msg("RadarBackupService.OnData")
+		return
+	}
+	insService.EnqueueReceive(ins)
+}
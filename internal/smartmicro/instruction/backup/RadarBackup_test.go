@@ -0,0 +1,153 @@
+package backup
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/internal/smartmicro/instruction/face08700"
+	"rvpro3/radarvision.com/internal/smartmicro/port"
+	"rvpro3/radarvision.com/internal/smartmicro/service"
+	"rvpro3/radarvision.com/utils"
+)
+
+type parameterKey struct {
+	sectionId   uint16
+	parameterId uint16
+	element     uint16
+}
+
+// testRadar answers the get and set parameter instructions from memory
+type testRadar struct {
+	values map[parameterKey][8]byte
+	sets   int
+}
+
+func newTestRadar() *testRadar {
+	return &testRadar{values: make(map[parameterKey][8]byte)}
+}
+
+func (r *testRadar) set(def face08700.ParameterDef, element int, value string) {
+	detail := def.Set(element)
+	_ = detail.FromString(port.NewInstruction().Ph.GetOrder(), value)
+	r.values[parameterKey{def.SectionId, def.ParameterId, uint16(element)}] = detail.Value
+}
+
+func (r *testRadar) Send(request *port.Instruction) (*port.Instruction, error) {
+	response := port.NewInstruction()
+	response.Header.SequenceNo = request.Header.SequenceNo
+
+	for _, detail := range request.Detail {
+		key := parameterKey{detail.SectionId, detail.ParameterId, detail.Element1}
+
+		if detail.RequestType == port.ReqTypeSetParameter {
+			r.values[key] = detail.Value
+			r.sets++
+		} else {
+			detail.Value = r.values[key]
+		}
+
+		detail.ResponseType = port.ResTypeSuccess
+		response.AddDetail(detail)
+	}
+	return response, nil
+}
+
+func TestParameterDef_Signature(t *testing.T) {
+	relay := face08700.ZoneParameters[1].Get(3)
+	expected := face08700.Detail.Zones.GetRelayAssignment(3)
+	assert.Equal(t, expected, relay)
+
+	posY := face08700.ZoneSegmentParameters[1].Get(7)
+	assert.Equal(t, face08700.Detail.ZoneSegments.GetYSegment(7), posY)
+
+	assert.Equal(t, face08700.Detail.Parameters.GetNofZones(), face08700.NofZonesParameter.Get(0))
+}
+
+func TestRadarBackup_BackupRestore(t *testing.T) {
+	source := newTestRadar()
+	source.set(face08700.NofZonesParameter, 0, "2")
+	source.set(face08700.SimulationModeParameter, 0, "2")
+	source.set(face08700.UsedSegmentsParameter, 0, "2")
+	source.set(face08700.UsedSegmentsParameter, 1, "1")
+	source.set(face08700.ZoneParameters[1], 1, "4")
+	source.set(face08700.ZoneParameters[2], 0, "3.5")
+	source.set(face08700.ZoneSegmentParameters[0], 2, "-12.25")
+	source.set(face08700.ZoneSegmentParameters[1], 2, "80")
+
+	radarIP := utils.IP4Builder.FromString("192.168.11.12:55555")
+	backup := RadarBackup{Client: source, ClientId: 0x01000001, RadarIP: radarIP}
+
+	snapshot, err := backup.Backup(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, SnapshotVersion, snapshot.Version)
+
+	// 2 app, 2 zones * 3 and 3 segments * 2 parameters
+	assert.Equal(t, 2+6+6, len(snapshot.Parameters))
+	assert.Equal(t, "-12.25", snapshot.Parameters[12].Value)
+
+	fileName := filepath.Join(t.TempDir(), FileNameOf("radar-%s.json", snapshot.RadarIP))
+	assert.NoError(t, snapshot.Save(fileName))
+
+	loaded, err := LoadSnapshot(fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, snapshot.Parameters, loaded.Parameters)
+
+	// Restore onto a replaced radar, only simulation_mode is restored
+	target := newTestRadar()
+	backup.Client = target
+	count, mismatches, err := backup.Restore(loaded)
+	assert.NoError(t, err)
+	assert.Empty(t, mismatches)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, 1, target.sets)
+	assert.Equal(t, "2", snapshot.Parameters[1].Value)
+
+	restored, err := backup.Backup(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, snapshot.Parameters[1], restored.Parameters[1])
+
+	// The zones are not restored, so the radar does not verify as backed up
+	mismatches, err = backup.Verify(snapshot)
+	assert.ErrorIs(t, err, ErrVerifyFailed)
+	assert.Equal(t, "0", mismatches[0].Actual)
+
+	// A radar changed afterward no longer verifies
+	source.set(face08700.ZoneParameters[1], 1, "5")
+	backup.Client = source
+	mismatches, err = backup.Verify(snapshot)
+	assert.ErrorIs(t, err, ErrVerifyFailed)
+	assert.Equal(t, 1, len(mismatches))
+	assert.Equal(t, "5", mismatches[0].Actual)
+}
+
+func TestLoadSnapshot_Version(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "snapshot.json")
+	snapshot := Snapshot{Version: SnapshotVersion + 1}
+	assert.NoError(t, snapshot.Save(fileName))
+
+	_, err := LoadSnapshot(fileName)
+	assert.ErrorIs(t, err, ErrSnapshotVersion)
+}
+
+func TestRadarBackupService_Busy(t *testing.T) {
+	backupService := &RadarBackupService{
+		dataService: new(service.UDPDataService),
+		running:     make(map[uint32]*service.Instruction),
+	}
+	backupService.Metrics.InitMetrics("test.backup", &backupService.Metrics)
+	radarIP := utils.IP4Builder.FromString("192.168.11.12:55555")
+
+	_, err := backupService.begin(radarIP)
+	assert.NoError(t, err)
+
+	_, _, err = backupService.Restore(radarIP, &Snapshot{Version: SnapshotVersion})
+	assert.ErrorIs(t, err, ErrBackupBusy)
+	assert.Equal(t, int64(1), backupService.Metrics.BusyCount.Value)
+
+	backupService.end(radarIP)
+	_, err = backupService.begin(radarIP)
+	assert.NoError(t, err)
+	backupService.end(radarIP)
+}
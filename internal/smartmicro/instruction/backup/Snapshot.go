@@ -0,0 +1,79 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SnapshotVersion is incremented whenever the snapshot layout changes
+const SnapshotVersion = 1
+
+var ErrSnapshotVersion = errors.New("unsupported snapshot version")
+
+// Snapshot is the configuration of a radar, as the parameters in the order
+// walked (and restored)
+type Snapshot struct {
+	Version    int
+	RadarIP    string
+	CreateOn   time.Time
+	Parameters []SnapshotParameter
+}
+
+// SnapshotParameter is a parameter value, Value being the
+// port.InstructionDetail ToString presentation
+type SnapshotParameter struct {
+	SectionId   uint16
+	Section     string
+	ParameterId uint16
+	Name        string
+	DataType    string
+	Element     int
+	Value       string
+}
+
+func (p SnapshotParameter) String() string {
+	return fmt.Sprintf("%s.%s[%d]", p.Section, p.Name, p.Element)
+}
+
+// FileNameOf returns the snapshot file name of the radar, the pattern holds a
+// %s for the radar IP, e.g. radar-%s.json
+func FileNameOf(pattern string, radarIP string) string {
+	if !strings.Contains(pattern, "%s") {
+		return pattern
+	}
+	return fmt.Sprintf(pattern, strings.ReplaceAll(radarIP, ":", "_"))
+}
+
+func (s *Snapshot) Save(fileName string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, data, 0644)
+}
+
+func LoadSnapshot(fileName string) (*Snapshot, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSnapshot(data)
+}
+
+// ParseSnapshot decodes the snapshot (JSON), rejecting unsupported versions
+func ParseSnapshot(data []byte) (*Snapshot, error) {
+	res := new(Snapshot)
+	if err := json.Unmarshal(data, res); err != nil {
+		return nil, err
+	}
+
+	if res.Version < 1 || res.Version > SnapshotVersion {
+		return nil, errors.Wrapf(ErrSnapshotVersion, "version %d", res.Version)
+	}
+	return res, nil
+}
@@ -0,0 +1,131 @@
+package face08700
+
+import "rvpro3/radarvision.com/internal/smartmicro/port"
+
+// ParameterDef describes a parameter by section, id and signature inputs,
+// the parameter is an array when DimElements is set.  Only the parameters
+// IsRestored are set by a restore, the others are backed up and read only
+type ParameterDef struct {
+	SectionId   uint16
+	SectionName string
+	ParameterId uint16
+	Name        string
+	DataType    port.InstructionDataType
+	DimName     string
+	DimElements int
+	IsRestored  bool
+}
+
+var NofZonesParameter = ParameterDef{
+	SectionId:   AppTMParametersSection,
+	SectionName: AppTMParametersSectionName,
+	ParameterId: S3017NofZones,
+	Name:        S3017NofZonesName,
+	DataType:    port.IdtU16,
+}
+
+var SimulationModeParameter = ParameterDef{
+	SectionId:   AppTMParametersSection,
+	SectionName: AppTMParametersSectionName,
+	ParameterId: S3017SimulationMode,
+	Name:        S3017SimulationModeName,
+	DataType:    port.IdtU16,
+	IsRestored:  true,
+}
+
+// AppParameters are the scalar app_tm_parameters, nof_zones first
+var AppParameters = []ParameterDef{
+	NofZonesParameter,
+	SimulationModeParameter,
+}
+
+// UsedSegmentsParameter is the number of segments of a zone, the segments of
+// the zones are consecutive in app_tm_zone_segments
+var UsedSegmentsParameter = zoneParameter(S3018UsedSegments, S3018UsedSegmentsName, port.IdtU8)
+
+// ZoneParameters are the app_tm_zones parameters, per zone.  The zones are
+// read as the live zones are, they are not restored until their set
+// parameters are confirmed against the radar instruction interface
+var ZoneParameters = []ParameterDef{
+	UsedSegmentsParameter,
+	zoneParameter(S3018RelayAssignment, S3018RelayAssignmentName, port.IdtU8),
+	zoneParameter(S3018ZoneWidth, S3018ZoneWidthName, port.IdtF32),
+}
+
+// ZoneSegmentParameters are the app_tm_zone_segments parameters, per segment
+var ZoneSegmentParameters = []ParameterDef{
+	zoneSegmentParameter(S3019PosX, S3019PosXName, port.IdtF32),
+	zoneSegmentParameter(S3019PosY, S3019PosYName, port.IdtF32),
+}
+
+func zoneParameter(paramId uint16, name string, dt port.InstructionDataType) ParameterDef {
+	return ParameterDef{
+		SectionId:   AppTMZonesSection,
+		SectionName: AppTMZonesSectionName,
+		ParameterId: paramId,
+		Name:        name,
+		DataType:    dt,
+		DimName:     MaxNofZonesName,
+		DimElements: MaxNofZones,
+	}
+}
+
+func zoneSegmentParameter(paramId uint16, name string, dt port.InstructionDataType) ParameterDef {
+	return ParameterDef{
+		SectionId:   AppTMZoneSegmentsSection,
+		SectionName: AppTMZoneSegmentsSectionName,
+		ParameterId: paramId,
+		Name:        name,
+		DataType:    dt,
+		DimName:     MaxNofZoneSegmentsName,
+		DimElements: MaxNofZoneSegments,
+	}
+}
+
+// FindParameter returns the parameter known by section and parameter id
+func FindParameter(sectionId uint16, parameterId uint16) (ParameterDef, bool) {
+	for _, defs := range [][]ParameterDef{AppParameters, ZoneParameters, ZoneSegmentParameters} {
+		for _, def := range defs {
+			if def.SectionId == sectionId && def.ParameterId == parameterId {
+				return def, true
+			}
+		}
+	}
+	return ParameterDef{}, false
+}
+
+func (p ParameterDef) IsArray() bool {
+	return p.DimElements > 0
+}
+
+// Get returns the get parameter instruction for the element (0 if scalar)
+func (p ParameterDef) Get(element int) port.InstructionDetail {
+	res := port.InstructionDetail{
+		SectionId:    p.SectionId,
+		ParameterId:  p.ParameterId,
+		RequestType:  port.ReqTypeGetParameter,
+		ResponseType: port.ResTypeNoInstruction,
+		DataType:     p.DataType,
+	}
+
+	if p.IsArray() {
+		res.DimCount = 1
+		res.Element1 = uint16(element)
+		res.Sign1Dim(p.SectionName, p.Name, p.DimName, p.DimElements)
+	} else {
+		res.Sign(p.SectionName, p.Name)
+	}
+	return res
+}
+
+// Set returns the set parameter instruction for the element, without value
+func (p ParameterDef) Set(element int) port.InstructionDetail {
+	res := p.Get(element)
+	res.RequestType = port.ReqTypeSetParameter
+	return res
+}
+
+// Is returns whether the detail is (a response to) the parameter
+func (p ParameterDef) Is(detail *port.InstructionDetail) bool {
+	return detail.SectionId == p.SectionId && detail.ParameterId == p.ParameterId
+}
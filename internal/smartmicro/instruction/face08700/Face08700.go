@@ -23,7 +23,7 @@ const ParameterSimulationMode = 3
 
 func (appTmZoneSegments) instruction(paramId int, paramName string, dt port.InstructionDataType, element1 int) port.InstructionDetail {
 	res := port.InstructionDetail{
-		SectionId:    3019,
+		SectionId:    AppTMZoneSegmentsSection,
 		ParameterId:  uint16(paramId),
 		DimCount:     1,
 		RequestType:  port.ReqTypeGetParameter,
@@ -36,11 +36,11 @@ func (appTmZoneSegments) instruction(paramId int, paramName string, dt port.Inst
 	res.Signature = port.Calc1Dim(
 		int(res.SectionId),
 		int(res.ParameterId),
-		"app_tm_zone_segments",
+		AppTMZoneSegmentsSectionName,
 		paramName,
 		1,
-		"MAX_NOF_ZONE_SEGMENTS",
-		128,
+		MaxNofZoneSegmentsName,
+		MaxNofZoneSegments,
 		dt.ToString(),
 	)
 	return res
@@ -48,7 +48,7 @@ func (appTmZoneSegments) instruction(paramId int, paramName string, dt port.Inst
 
 func (appTmZones) instruction(paramId int, paramName string, dt port.InstructionDataType, element1 int) port.InstructionDetail {
 	res := port.InstructionDetail{
-		SectionId:    3018,
+		SectionId:    AppTMZonesSection,
 		ParameterId:  uint16(paramId),
 		DimCount:     1,
 		RequestType:  port.ReqTypeGetParameter,
@@ -61,54 +61,54 @@ func (appTmZones) instruction(paramId int, paramName string, dt port.Instruction
 	res.Signature = port.Calc1Dim(
 		int(res.SectionId),
 		int(res.ParameterId),
-		"app_tm_zones",
+		AppTMZonesSectionName,
 		paramName,
 		1,
-		"MAX_NOF_ZONES",
-		32,
+		MaxNofZonesName,
+		MaxNofZones,
 		dt.ToString(),
 	)
 	return res
 }
 
 func (s appTmZones) GetRelayAssignment(zone int) port.InstructionDetail {
-	return s.instruction(2, "relay_assignment", port.IdtU8, zone)
+	return s.instruction(S3018RelayAssignment, S3018RelayAssignmentName, port.IdtU8, zone)
 }
 
 func (s appTmZones) GetNofSegmentsByZone(zone int) port.InstructionDetail {
-	return s.instruction(0, "used_segments", port.IdtU8, zone)
+	return s.instruction(S3018UsedSegments, S3018UsedSegmentsName, port.IdtU8, zone)
 }
 
 func (s appTmZones) GetWidthByZone(zone int) port.InstructionDetail {
-	return s.instruction(4, "zone_width", port.IdtF32, zone)
+	return s.instruction(S3018ZoneWidth, S3018ZoneWidthName, port.IdtF32, zone)
 }
 
 func (s appTmZones) IsNofSegmentsByZone(detail *port.InstructionDetail) bool {
-	return detail.SectionId == 3018 && detail.ParameterId == uint16(0)
+	return detail.SectionId == AppTMZonesSection && detail.ParameterId == S3018UsedSegments
 }
 
 func (s appTmZones) IsWidthByZone(detail *port.InstructionDetail) bool {
-	return detail.SectionId == 3018 && detail.ParameterId == uint16(4)
+	return detail.SectionId == AppTMZonesSection && detail.ParameterId == S3018ZoneWidth
 }
 
 func (s appTmZones) IsRelayAssignment(detail *port.InstructionDetail) bool {
-	return detail.SectionId == 3018 && detail.ParameterId == uint16(2)
+	return detail.SectionId == AppTMZonesSection && detail.ParameterId == S3018RelayAssignment
 }
 
 func (s appTmZoneSegments) GetXSegment(segment int) port.InstructionDetail {
-	return s.instruction(0, "pos_x", port.IdtF32, segment)
+	return s.instruction(S3019PosX, S3019PosXName, port.IdtF32, segment)
 }
 
 func (s appTmZoneSegments) GetYSegment(segment int) port.InstructionDetail {
-	return s.instruction(1, "pos_y", port.IdtF32, segment)
+	return s.instruction(S3019PosY, S3019PosYName, port.IdtF32, segment)
 }
 
 func (s appTmZoneSegments) IsGetXSegment(detail *port.InstructionDetail) bool {
-	return detail.SectionId == 3019 && detail.ParameterId == uint16(0)
+	return detail.SectionId == AppTMZoneSegmentsSection && detail.ParameterId == S3019PosX
 }
 
 func (s appTmZoneSegments) IsGetYSegment(detail *port.InstructionDetail) bool {
-	return detail.SectionId == 3019 && detail.ParameterId == uint16(1)
+	return detail.SectionId == AppTMZoneSegmentsSection && detail.ParameterId == S3019PosY
 }
 
 func (s appParameters) instruction(paramId int, paramName string, dt port.InstructionDataType) port.InstructionDetail {
@@ -122,16 +122,16 @@ func (s appParameters) instruction(paramId int, paramName string, dt port.Instru
 		Element1:     0,
 		Element2:     0,
 	}
-	res.Sign("app_tm_parameters", paramName)
+	res.Sign(AppTMParametersSectionName, paramName)
 	return res
 }
 
 func (s appParameters) GetNofZones() port.InstructionDetail {
-	return s.instruction(0, "nof_zones", port.IdtU16)
+	return s.instruction(S3017NofZones, S3017NofZonesName, port.IdtU16)
 }
 
 func (appParameters) IsGetNofZones(detail *port.InstructionDetail) bool {
-	return detail.SectionId == 3017 && detail.ParameterId == uint16(0)
+	return detail.SectionId == AppTMParametersSection && detail.ParameterId == S3017NofZones
 }
 
 func (s appParameters) GetSimulationMode() port.InstructionDetail {
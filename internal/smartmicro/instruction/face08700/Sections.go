@@ -1,9 +1,32 @@
 package face08700
 
 const AppTMParametersSection = 3017
+const AppTMParametersSectionName = "app_tm_parameters"
+const S3017NofZones = 0
+const S3017NofZonesName = "nof_zones"
 const S3017SimulationMode = 3
 const S3017SimulationModeName = "simulation_mode"
 
+const AppTMZonesSection = 3018
+const AppTMZonesSectionName = "app_tm_zones"
+const MaxNofZonesName = "MAX_NOF_ZONES"
+const MaxNofZones = 32
+const S3018UsedSegments = 0
+const S3018UsedSegmentsName = "used_segments"
+const S3018RelayAssignment = 2
+const S3018RelayAssignmentName = "relay_assignment"
+const S3018ZoneWidth = 4
+const S3018ZoneWidthName = "zone_width"
+
+const AppTMZoneSegmentsSection = 3019
+const AppTMZoneSegmentsSectionName = "app_tm_zone_segments"
+const MaxNofZoneSegmentsName = "MAX_NOF_ZONE_SEGMENTS"
+const MaxNofZoneSegments = 128
+const S3019PosX = 0
+const S3019PosXName = "pos_x"
+const S3019PosY = 1
+const S3019PosYName = "pos_y"
+
 const TRObjectListSection = 218
 const S218SimulationMode = 8
 
@@ -0,0 +1,11 @@
+package interfaces
+
+import "rvpro3/radarvision.com/utils"
+
+// IStreamSink streams the radar messages to the clients subscribed to the
+// radar, the subscriptions being the streammodel flags.  The messages should
+// only be built while IsAnySubscribedTo
+type IStreamSink interface {
+	IsAnySubscribedTo(subscription uint64, radar utils.IP4) bool
+	Stream(subscription uint64, radar utils.IP4, message any) error
+}
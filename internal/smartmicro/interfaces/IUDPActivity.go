@@ -16,6 +16,9 @@ type IUDPActivity interface {
 	Init(workflow IUDPWorkflow, index int, fullName string)
 	Process(time.Time, []byte)
 	UpdateMetrics(duration int64, on time.Time)
+	// Close releases the files and the go routines of the activity, the
+	// activities of a broker stopped or reconfigured are closed
+	Close()
 }
 
 type UDPActivityMixin struct {
@@ -43,6 +46,10 @@ func (u *UDPActivityMixin) UpdateMetrics(duration int64, now time.Time) {
 	u.ProcessedCount.IncAt(1, now)
 }
 
+// Close does nothing, the activities holding resources override it
+func (u *UDPActivityMixin) Close() {
+}
+
 func (u *UDPActivityMixin) GetMetricName() string {
 	return u.MetricName
 }
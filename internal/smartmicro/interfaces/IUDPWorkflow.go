@@ -16,4 +16,5 @@ type IUDPWorkflow interface {
 
 	AddActivity(activity IUDPActivity)
 	NextActivityId() int
+	Close()
 }
@@ -0,0 +1,103 @@
+package port
+
+import (
+	"encoding/binary"
+
+	"rvpro3/radarvision.com/utils"
+)
+
+// Diagnostics is the diagnostics port message (see DiagnosticsReader), to
+// simulate the health of a radar
+type Diagnostics struct {
+	Th         TransportHeader
+	Ph         PortHeader
+	Header     DiagnosticsHeader
+	ErrorCodes []uint16
+	Crc        uint16
+	CrcCheck   uint16
+}
+
+type DiagnosticsHeader struct {
+	UnixTime      uint32
+	Milliseconds  uint16
+	Status        DiagnosticsStatus
+	NofErrors     uint8
+	Temperature   int16
+	SupplyVoltage uint16
+	Misalignment  int16
+	Blockage      uint8
+	Padding       uint8
+}
+
+func (h *DiagnosticsHeader) Write(writer *utils.FixedBuffer, order binary.ByteOrder) {
+	writer.WriteU32(h.UnixTime, order)
+	writer.WriteU16(h.Milliseconds, order)
+	writer.WriteU8(uint8(h.Status))
+	writer.WriteU8(h.NofErrors)
+	writer.WriteU16(uint16(h.Temperature), order)
+	writer.WriteU16(h.SupplyVoltage, order)
+	writer.WriteU16(uint16(h.Misalignment), order)
+	writer.WriteU8(h.Blockage)
+	writer.WriteU8(h.Padding)
+}
+
+func (h *DiagnosticsHeader) GetByteSize() int {
+	return 16
+}
+
+func NewDiagnostics() *Diagnostics {
+	res := &Diagnostics{}
+	res.Th.Init()
+	res.Ph.Init(PiDiagnostics)
+	res.Ph.PortMajorVersion = 1
+	res.Ph.PortMinorVersion = 0
+	return res
+}
+
+func (d *Diagnostics) Write(writer *utils.FixedBuffer) error {
+	d.Header.NofErrors = uint8(len(d.ErrorCodes))
+
+	writer.StartWriteMarker()
+	d.Th.PayloadLength = d.GetPayloadSize()
+	d.Th.Write(writer)
+	d.Th.CRC16 = writer.WriteCRC16(binary.BigEndian)
+	d.Th.CheckCRC16 = d.Th.CRC16
+
+	writer.StartWriteMarker()
+	order := d.Ph.GetOrder()
+
+	d.Ph.PortSize = uint32(d.Th.PayloadLength)
+	d.Ph.Write(writer)
+	d.Header.Write(writer, order)
+
+	for _, code := range d.ErrorCodes {
+		writer.WriteU16(code, order)
+	}
+
+	if !d.Th.Flags.IsSkipPayloadCrc() {
+		d.CrcCheck = writer.CalcWriteCRC()
+		d.Crc = d.CrcCheck
+		writer.WriteU16(d.Crc, binary.BigEndian)
+	}
+
+	return writer.Err
+}
+
+func (d *Diagnostics) GetPayloadSize() uint16 {
+	return uint16(d.Ph.GetByteSize() + d.Header.GetByteSize() + len(d.ErrorCodes)*2)
+}
+
+func (d *Diagnostics) GetTotalSize() int {
+	return int(d.Th.GetSize()) + int(d.GetPayloadSize()) + 2
+}
+
+func (d *Diagnostics) SaveAsBytes() []byte {
+	res := make([]byte, d.GetTotalSize())
+
+	writer := utils.NewFixedBuffer(res, 0, 0)
+	if err := d.Write(&writer); err != nil {
+		panic(err)
+	}
+
+	return writer.AsWriteSlice()
+}
@@ -0,0 +1,150 @@
+package port
+
+import "rvpro3/radarvision.com/utils"
+
+// DiagnosticsReader reads the health of the radar from the diagnostics port
+// (version 1).  The temperature is in 0.1 °C, the supply voltage in mV and
+// the misalignment in 0.01 degrees, the getters return them in °C, V and
+// degrees
+type DiagnosticsReader struct {
+	readerMixin
+}
+
+func (d *DiagnosticsReader) Init(buffer []byte) {
+	d.initBuffer(buffer)
+}
+
+func (d *DiagnosticsReader) IsSupported() bool {
+	switch d.VersionMajor {
+	case 1:
+		return true
+	default:
+		return false
+	}
+}
+
+func (d *DiagnosticsReader) GetUnixTime() uint32 {
+	switch d.VersionMajor {
+	case 1:
+		return utils.OffsetReader.ReadU32(d.Buffer, d.Order, d.StartOffset)
+	default:
+		return 0
+	}
+}
+
+func (d *DiagnosticsReader) GetMilliseconds() uint16 {
+	switch d.VersionMajor {
+	case 1:
+		return utils.OffsetReader.ReadU16(d.Buffer, d.Order, d.StartOffset+4)
+	default:
+		return 0
+	}
+}
+
+func (d *DiagnosticsReader) GetStatus() DiagnosticsStatus {
+	switch d.VersionMajor {
+	case 1:
+		return DiagnosticsStatus(utils.OffsetReader.ReadU8(d.Buffer, d.StartOffset+6))
+	default:
+		return 0
+	}
+}
+
+func (d *DiagnosticsReader) GetNofErrors() uint8 {
+	switch d.VersionMajor {
+	case 1:
+		return utils.OffsetReader.ReadU8(d.Buffer, d.StartOffset+7)
+	default:
+		return 0
+	}
+}
+
+func (d *DiagnosticsReader) GetTemperature() float32 {
+	switch d.VersionMajor {
+	case 1:
+		return float32(utils.OffsetReader.ReadI16(d.Buffer, d.Order, d.StartOffset+8)) / 10
+	default:
+		return 0
+	}
+}
+
+func (d *DiagnosticsReader) GetSupplyVoltage() float32 {
+	switch d.VersionMajor {
+	case 1:
+		return float32(utils.OffsetReader.ReadU16(d.Buffer, d.Order, d.StartOffset+10)) / 1000
+	default:
+		return 0
+	}
+}
+
+func (d *DiagnosticsReader) GetMisalignment() float32 {
+	switch d.VersionMajor {
+	case 1:
+		return float32(utils.OffsetReader.ReadI16(d.Buffer, d.Order, d.StartOffset+12)) / 100
+	default:
+		return 0
+	}
+}
+
+// GetBlockage returns the blockage of the radome in percent
+func (d *DiagnosticsReader) GetBlockage() uint8 {
+	switch d.VersionMajor {
+	case 1:
+		return utils.OffsetReader.ReadU8(d.Buffer, d.StartOffset+14)
+	default:
+		return 0
+	}
+}
+
+func (d *DiagnosticsReader) GetErrorCode(idx int) uint16 {
+	switch d.VersionMajor {
+	case 1:
+		return utils.OffsetReader.ReadU16(d.Buffer, d.Order, d.detailOff(idx))
+	default:
+		return 0
+	}
+}
+
+// GetErrorCodes returns the error codes reported, nil when none
+func (d *DiagnosticsReader) GetErrorCodes() []uint16 {
+	nofErrors := int(d.GetNofErrors())
+	if nofErrors == 0 {
+		return nil
+	}
+
+	res := make([]uint16, nofErrors)
+	for idx := range res {
+		res[idx] = d.GetErrorCode(idx)
+	}
+	return res
+}
+
+func (d *DiagnosticsReader) detailOff(idx int) int {
+	return d.StartOffset + d.GetHeaderLength() + idx*2
+}
+
+func (d *DiagnosticsReader) GetHeaderLength() int {
+	return 16
+}
+
+func (d *DiagnosticsReader) PrintDetail() {
+	utils.Print.Detail("Diagnostics", "\n")
+	utils.Print.SetIndent(2)
+	utils.Print.Detail("Unix Time", "%d\n", d.GetUnixTime())
+	utils.Print.Detail("Milliseconds", "%d\n", d.GetMilliseconds())
+	utils.Print.Detail("Status", "%d, %s\n", d.GetStatus(), d.GetStatus())
+	utils.Print.Detail("Temperature", "%.1f\n", d.GetTemperature())
+	utils.Print.Detail("Supply Voltage", "%.3f\n", d.GetSupplyVoltage())
+	utils.Print.Detail("Misalignment", "%.2f\n", d.GetMisalignment())
+	utils.Print.Detail("Blockage", "%d\n", d.GetBlockage())
+	utils.Print.Detail("Nof Errors", "%d\n", d.GetNofErrors())
+
+	for n := 0; n < int(d.GetNofErrors()); n++ {
+		utils.Print.Detail("Error Code", "%d\n", d.GetErrorCode(n))
+	}
+	utils.Print.SetIndent(-2)
+}
+
+func (d *DiagnosticsReader) TotalSize() int {
+	return d.detailOff(int(d.GetNofErrors()))
+}
@@ -0,0 +1,39 @@
+package port
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiagnosticsReader_Read(t *testing.T) {
+	diagnostics := NewDiagnostics()
+	diagnostics.Header.UnixTime = 1792310400
+	diagnostics.Header.Milliseconds = 250
+	diagnostics.Header.Status = DsBlockage | DsVoltage
+	diagnostics.Header.Temperature = -125
+	diagnostics.Header.SupplyVoltage = 11950
+	diagnostics.Header.Misalignment = 150
+	diagnostics.Header.Blockage = 60
+	diagnostics.ErrorCodes = []uint16{0x101, 0x2002}
+	bytes := diagnostics.SaveAsBytes()
+
+	reader := DiagnosticsReader{}
+	reader.Init(bytes)
+
+	assert.True(t, reader.IsSupported())
+	assert.Equal(t, uint32(1792310400), reader.GetUnixTime())
+	assert.Equal(t, uint16(250), reader.GetMilliseconds())
+	assert.Equal(t, DsBlockage|DsVoltage, reader.GetStatus())
+	assert.Equal(t, "blockage,voltage", reader.GetStatus().String())
+	assert.InDelta(t, -12.5, reader.GetTemperature(), 0.001)
+	assert.InDelta(t, 11.95, reader.GetSupplyVoltage(), 0.001)
+	assert.InDelta(t, 1.5, reader.GetMisalignment(), 0.001)
+	assert.Equal(t, uint8(60), reader.GetBlockage())
+	assert.Equal(t, []uint16{0x101, 0x2002}, reader.GetErrorCodes())
+	assert.Equal(t, len(bytes)-2, reader.TotalSize(), "the payload crc follows")
+
+	reader.VersionMajor = 2
+	assert.False(t, reader.IsSupported())
+	assert.Nil(t, reader.GetErrorCodes())
+}
@@ -0,0 +1,45 @@
+package port
+
+import "strings"
+
+// DiagnosticsStatus is the health flags reported on the diagnostics port
+type DiagnosticsStatus uint8
+
+const (
+	DsBlockage DiagnosticsStatus = 1 << iota
+	DsMisalignment
+	DsTemperature
+	DsVoltage
+	DsHardware
+	DsInterference
+)
+
+func (s DiagnosticsStatus) String() string {
+	sb := strings.Builder{}
+	sb.Grow(70)
+
+	if s&DsBlockage == DsBlockage {
+		sb.WriteString("blockage,")
+	}
+
+	if s&DsMisalignment == DsMisalignment {
+		sb.WriteString("misalignment,")
+	}
+
+	if s&DsTemperature == DsTemperature {
+		sb.WriteString("temperature,")
+	}
+
+	if s&DsVoltage == DsVoltage {
+		sb.WriteString("voltage,")
+	}
+
+	if s&DsHardware == DsHardware {
+		sb.WriteString("hardware,")
+	}
+
+	if s&DsInterference == DsInterference {
+		sb.WriteString("interference,")
+	}
+	return strings.TrimSuffix(sb.String(), ",")
+}
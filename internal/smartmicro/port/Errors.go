@@ -6,3 +6,5 @@ var ErrTransportHeaderTooSmall = errors.New("buffer too small for Transport Head
 var ErrTransportHeaderStartPattern = errors.New("invalid Transport Header start pattern")
 var ErrPayloadTooSmall = errors.New("buffer too small for Payload")
 var ErrUnsupportedProtocol = errors.New("unsupported protocol")
+var ErrUnmappedDataType = errors.New("unmapped instruction data type")
+var ErrUnsupportedVersion = errors.New("unsupported port version")
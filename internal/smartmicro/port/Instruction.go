@@ -333,6 +333,64 @@ func (id *InstructionDetail) SetU32(order binary.ByteOrder, value uint32) {
 	order.PutUint32(id.Value[:], value)
 }
 
+func (id *InstructionDetail) SetU8(value uint8) {
+	id.Value[0] = value
+}
+
+func (id *InstructionDetail) SetF32(order binary.ByteOrder, value float32) {
+	order.PutUint32(id.Value[:], math.Float32bits(value))
+}
+
+func (id *InstructionDetail) SetF64(order binary.ByteOrder, value float64) {
+	order.PutUint64(id.Value[:], math.Float64bits(value))
+}
+
+// FromString sets the value from its ToString presentation
+func (id *InstructionDetail) FromString(order binary.ByteOrder, value string) error {
+	switch id.DataType {
+	case IdtF32:
+		res, err := strconv.ParseFloat(value, 32)
+		id.SetF32(order, float32(res))
+		return err
+
+	case IdtF64:
+		res, err := strconv.ParseFloat(value, 64)
+		id.SetF64(order, res)
+		return err
+
+	case IdtI32:
+		res, err := strconv.ParseInt(value, 10, 32)
+		id.SetU32(order, uint32(res))
+		return err
+
+	case IdtU32:
+		res, err := strconv.ParseUint(value, 10, 32)
+		id.SetU32(order, uint32(res))
+		return err
+
+	case IdtI8:
+		res, err := strconv.ParseInt(value, 10, 8)
+		id.SetU8(uint8(res))
+		return err
+
+	case IdtU8:
+		res, err := strconv.ParseUint(value, 10, 8)
+		id.SetU8(uint8(res))
+		return err
+
+	case IdtI16:
+		res, err := strconv.ParseInt(value, 10, 16)
+		id.SetU16(order, uint16(res))
+		return err
+
+	case IdtU16:
+		res, err := strconv.ParseUint(value, 10, 16)
+		id.SetU16(order, uint16(res))
+		return err
+	}
+	return ErrUnmappedDataType
+}
+
 type Instruction struct {
 	Th       TransportHeader
 	Ph       PortHeader
@@ -45,13 +45,13 @@ func (t TransportHeaderReader) GetFlags() FlagsType {
 
 func (t TransportHeaderReader) GetMessageCounter() uint16 {
 	flags := t.GetFlags()
-	offset := flagsOffset + flags.OffsetOf(FlMessageCount)
+	offset := flagsDataOffset + flags.OffsetOf(FlMessageCount)
 	return utils.OffsetReader.ReadU16(t.Buffer, binary.BigEndian, offset)
 }
 
 func (t TransportHeaderReader) GetTimeStamp() int64 {
 	flags := t.GetFlags()
-	offset := flagsOffset + flags.OffsetOf(FlTimestamp)
+	offset := flagsDataOffset + flags.OffsetOf(FlTimestamp)
 	return utils.OffsetReader.ReadI64(t.Buffer, binary.BigEndian, offset)
 }
 
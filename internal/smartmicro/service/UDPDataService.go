@@ -44,7 +44,14 @@ type UDPDataService struct {
 	doneChannel   chan bool
 	writeChannel  chan *UDPSendData
 	writePool     sync.Pool
-	dataReceivers []func(*UDPDataService, net.UDPAddr, []byte)
+	dataReceivers []udpDataReceiver
+	receiverLock  sync.RWMutex
+	receiverId    int
+}
+
+type udpDataReceiver struct {
+	id       int
+	receiver func(*UDPDataService, net.UDPAddr, []byte)
 }
 
 type UdpDataMetrics struct {
@@ -67,8 +74,27 @@ type UdpDataMetrics struct {
 	utils.MetricsInitMixin
 }
 
-func (u *UDPDataService) RegisterReceiver(receiver func(*UDPDataService, net.UDPAddr, []byte)) {
-	u.dataReceivers = append(u.dataReceivers, receiver)
+// RegisterReceiver adds the receiver of the datagrams read, and returns its
+// id for UnregisterReceiver
+func (u *UDPDataService) RegisterReceiver(receiver func(*UDPDataService, net.UDPAddr, []byte)) int {
+	u.receiverLock.Lock()
+	defer u.receiverLock.Unlock()
+
+	u.receiverId++
+	u.dataReceivers = append(u.dataReceivers, udpDataReceiver{id: u.receiverId, receiver: receiver})
+	return u.receiverId
+}
+
+func (u *UDPDataService) UnregisterReceiver(id int) {
+	u.receiverLock.Lock()
+	defer u.receiverLock.Unlock()
+
+	for index, dataReceiver := range u.dataReceivers {
+		if dataReceiver.id == id {
+			u.dataReceivers = append(u.dataReceivers[:index:index], u.dataReceivers[index+1:]...)
+			return
+		}
+	}
 }
 
 func (u *UDPDataService) InitFromSettings(settings *utils.Settings) {
@@ -178,10 +204,12 @@ func (u *UDPDataService) executeReader() {
 				u.Metrics.DataReadCount.Inc(1)
 				u.Metrics.DataReadBytes.Inc(int64(u.BufferLen))
 
-				for _, receiver := range u.dataReceivers {
+				u.receiverLock.RLock()
+				for _, dataReceiver := range u.dataReceivers {
 					u.Metrics.OnDataCallbackCount.IncAt(1, u.Now)
-					receiver(u, u.Connection.FromAddr, u.Buffer[:u.BufferLen])
+					dataReceiver.receiver(u, u.Connection.FromAddr, u.Buffer[:u.BufferLen])
 				}
+				u.receiverLock.RUnlock()
 			} else {
 				u.Metrics.NoDataCount.IncAt(1, u.Now)
 			}
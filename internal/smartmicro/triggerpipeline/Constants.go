@@ -8,3 +8,15 @@ const Manual = "Manual"
 
 // Failsafe is the radar failsafe
 const Failsafe = "Failsafe"
+
+// Delay is the per channel detector delay
+const Delay = "Delay"
+
+// Extend is the per channel detector extend (carryover)
+const Extend = "Extend"
+
+// MaxPresence is the per channel detector max presence cutoff
+const MaxPresence = "MaxPresence"
+
+// ZoneDetect is the channel calls detected from the object list zones
+const ZoneDetect = "ZoneDetect"
@@ -0,0 +1,48 @@
+package triggerpipeline
+
+import (
+	"time"
+
+	"rvpro3/radarvision.com/internal/smartmicro/interfaces"
+	"rvpro3/radarvision.com/utils"
+)
+
+// DelayPipelineItem suppresses a call until the channel has been on for the
+// channel Duration.  When Phases is set, the delay is phase conditioned:
+// a channel assigned to a phase is not delayed while its phase is green
+type DelayPipelineItem struct {
+	TriggerPipelineItemMixin
+	Channels []DetectorChannel
+	Phases   *interfaces.PhaseState `json:"-"`
+}
+
+func (d *DelayPipelineItem) Execute(now time.Time, source utils.Uint128, _ ITriggerDisplay) utils.Uint128 {
+	res := source
+
+	for index := range d.Channels {
+		channel := &d.Channels[index]
+		isOn := source.IsBit(channel.Index)
+		channel.track(now, isOn)
+
+		if !isOn || d.isGreen(channel) {
+			continue
+		}
+
+		if channel.OnFor(now) < channel.Duration {
+			res = res.SetBit(channel.Index, false)
+		}
+	}
+
+	return res
+}
+
+func (d *DelayPipelineItem) isGreen(channel *DetectorChannel) bool {
+	if d.Phases == nil || channel.PhaseIndex < 0 {
+		return false
+	}
+	return d.Phases.PhaseGreen.IsBit(channel.PhaseIndex)
+}
+
+func (d *DelayPipelineItem) getChannels() []DetectorChannel {
+	return d.Channels
+}
@@ -0,0 +1,67 @@
+package triggerpipeline
+
+import (
+	"time"
+)
+
+// DetectorChannel is the per channel setting and state of the detector logic
+// items (delay, extend and max presence).  The Index and PhaseIndex are 0
+// based bit indexes, a PhaseIndex of -1 means that the channel is not
+// assigned to a phase
+type DetectorChannel struct {
+	Index      int
+	PhaseIndex int
+	Duration   time.Duration
+	IsOn       bool      `json:"-"`
+	OnSince    time.Time `json:"-"`
+	LastOn     time.Time `json:"-"`
+	IsCutOff   bool      `json:"-"`
+}
+
+// track follows the channel input, returning true when the input turned on
+func (d *DetectorChannel) track(now time.Time, isOn bool) bool {
+	isRising := isOn && !d.IsOn
+
+	if isRising {
+		d.OnSince = now
+		d.IsCutOff = false
+	}
+
+	if isOn {
+		d.LastOn = now
+	}
+
+	d.IsOn = isOn
+	return isRising
+}
+
+// OnFor returns how long the input has been on, 0 when off
+func (d *DetectorChannel) OnFor(now time.Time) time.Duration {
+	if !d.IsOn {
+		return 0
+	}
+	return now.Sub(d.OnSince)
+}
+
+// detectorPipelineItem is a pipeline item with detector channels
+type detectorPipelineItem interface {
+	getChannels() []DetectorChannel
+}
+
+// carryOverChannels copies the state of the previous channels with the same
+// index, the settings (phase, duration) are those of the channels
+func carryOverChannels(channels []DetectorChannel, previous []DetectorChannel) {
+	for index := range channels {
+		channel := &channels[index]
+
+		for _, previousChannel := range previous {
+			if previousChannel.Index == channel.Index {
+				channel.IsOn = previousChannel.IsOn
+				channel.OnSince = previousChannel.OnSince
+				channel.LastOn = previousChannel.LastOn
+				channel.IsCutOff = previousChannel.IsCutOff
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package triggerpipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/internal/smartmicro/interfaces"
+	"rvpro3/radarvision.com/utils"
+)
+
+var call = utils.Uint128{Lo: 1}
+var noCall = utils.Uint128{}
+
+func detectorChannel(duration time.Duration) []DetectorChannel {
+	return []DetectorChannel{{Index: 0, PhaseIndex: 1, Duration: duration}}
+}
+
+func TestDelayPipelineItem_Execute(t *testing.T) {
+	item := &DelayPipelineItem{Channels: detectorChannel(2 * time.Second)}
+	now := time.Now()
+
+	assert.Equal(t, noCall, item.Execute(now, call, nil))
+	assert.Equal(t, noCall, item.Execute(now.Add(time.Second), call, nil))
+	assert.Equal(t, call, item.Execute(now.Add(2*time.Second), call, nil))
+
+	// The delay restarts once the channel turned off
+	assert.Equal(t, noCall, item.Execute(now.Add(3*time.Second), noCall, nil))
+	assert.Equal(t, noCall, item.Execute(now.Add(4*time.Second), call, nil))
+}
+
+func TestDelayPipelineItem_PhaseConditioned(t *testing.T) {
+	phases := &interfaces.PhaseState{}
+	item := &DelayPipelineItem{Channels: detectorChannel(2 * time.Second), Phases: phases}
+	now := time.Now()
+
+	assert.Equal(t, noCall, item.Execute(now, call, nil))
+
+	// Phase 2 (index 1) turns green, the delay no longer applies
+	phases.SetRYG("test", 0, 0, utils.Uint64(0).SetBit(1))
+	assert.Equal(t, call, item.Execute(now.Add(time.Second), call, nil))
+}
+
+func TestExtendPipelineItem_Execute(t *testing.T) {
+	item := &ExtendPipelineItem{Channels: detectorChannel(time.Second)}
+	display := &ChannelDisplay{}
+	now := time.Now()
+
+	assert.Equal(t, noCall, item.Execute(now, noCall, display))
+	assert.Equal(t, call, item.Execute(now.Add(time.Second), call, display))
+	assert.Equal(t, call, item.Execute(now.Add(1500*time.Millisecond), noCall, display))
+	assert.Equal(t, ChannelStatusRedExtend, display.Get(0))
+	assert.Equal(t, noCall, item.Execute(now.Add(2*time.Second), noCall, display))
+}
+
+func TestMaxPresencePipelineItem_Execute(t *testing.T) {
+	item := &MaxPresencePipelineItem{Channels: detectorChannel(10 * time.Second)}
+	now := time.Now()
+
+	assert.Equal(t, call, item.Execute(now, call, nil))
+	assert.Equal(t, call, item.Execute(now.Add(9*time.Second), call, nil))
+	assert.Equal(t, noCall, item.Execute(now.Add(10*time.Second), call, nil))
+	assert.Equal(t, noCall, item.Execute(now.Add(20*time.Second), call, nil))
+
+	// A new call after the channel turned off is passed again
+	assert.Equal(t, noCall, item.Execute(now.Add(21*time.Second), noCall, nil))
+	assert.Equal(t, call, item.Execute(now.Add(22*time.Second), call, nil))
+}
+
+func TestTriggerPipeline_CarryOver(t *testing.T) {
+	radarIP := utils.IP4Builder.FromString("192.168.11.12:55555")
+	now := time.Now()
+
+	previous := &TriggerPipeline{}
+	previousExtend := &ExtendPipelineItem{Channels: detectorChannel(2 * time.Second)}
+	previousExtend.Name, previousExtend.RadarIP = Extend, radarIP
+	previous.AddItem(previousExtend)
+	assert.Equal(t, call, previous.Execute(now, call, nil))
+
+	// The rebuilt extend continues the extension of the previous
+	pipeline := &TriggerPipeline{}
+	extend := &ExtendPipelineItem{Channels: detectorChannel(3 * time.Second)}
+	extend.Name, extend.RadarIP = Extend, radarIP
+	pipeline.AddItem(extend)
+	pipeline.CarryOver(previous)
+
+	assert.Equal(t, now, extend.Channels[0].LastOn)
+	assert.Equal(t, 3*time.Second, extend.Channels[0].Duration)
+	assert.Equal(t, call, pipeline.Execute(now.Add(2500*time.Millisecond), noCall, nil))
+
+	target := &TriggerPipeline{}
+	target.Replace(pipeline)
+	assert.Same(t, target, extend.GetParent())
+}
@@ -0,0 +1,42 @@
+package triggerpipeline
+
+import (
+	"time"
+
+	"rvpro3/radarvision.com/utils"
+)
+
+// ExtendPipelineItem carries a call over for the channel Duration after the
+// channel turned off
+type ExtendPipelineItem struct {
+	TriggerPipelineItemMixin
+	Channels []DetectorChannel
+}
+
+func (e *ExtendPipelineItem) Execute(now time.Time, source utils.Uint128, display ITriggerDisplay) utils.Uint128 {
+	res := source
+
+	for index := range e.Channels {
+		channel := &e.Channels[index]
+		isOn := source.IsBit(channel.Index)
+		channel.track(now, isOn)
+
+		if isOn || channel.LastOn.IsZero() {
+			continue
+		}
+
+		if now.Sub(channel.LastOn) < channel.Duration {
+			res = res.SetBit(channel.Index, true)
+
+			if display != nil {
+				display.Set(channel.Index, ChannelStatusRedExtend)
+			}
+		}
+	}
+
+	return res
+}
+
+func (e *ExtendPipelineItem) getChannels() []DetectorChannel {
+	return e.Channels
+}
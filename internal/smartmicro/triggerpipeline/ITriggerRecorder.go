@@ -0,0 +1,17 @@
+package triggerpipeline
+
+import (
+	"time"
+
+	"rvpro3/radarvision.com/utils"
+)
+
+// ITriggerRecorder receives the progress of a single TriggerPipeline.Execute.
+// RecordItem is called after each item executed with the item's trigger
+// (input) and update time as it was before execution and the pipeline value
+// after the item (output)
+type ITriggerRecorder interface {
+	StartFrame(now time.Time, source utils.Uint128)
+	RecordItem(item ITriggerPipelineItem, input utils.Uint128, updateOn time.Time, output utils.Uint128)
+	EndFrame(result utils.Uint128)
+}
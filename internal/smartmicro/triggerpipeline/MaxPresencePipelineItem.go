@@ -0,0 +1,47 @@
+package triggerpipeline
+
+import (
+	"time"
+
+	"rvpro3/radarvision.com/utils"
+)
+
+// MaxPresencePipelineItem cuts a call off once the channel has been on
+// continuously for the channel Duration.  The call stays cut off until the
+// channel turns off, which guards against a stuck call holding the phase
+type MaxPresencePipelineItem struct {
+	TriggerPipelineItemMixin
+	Channels []DetectorChannel
+}
+
+func (m *MaxPresencePipelineItem) Execute(now time.Time, source utils.Uint128, display ITriggerDisplay) utils.Uint128 {
+	res := source
+
+	for index := range m.Channels {
+		channel := &m.Channels[index]
+		isOn := source.IsBit(channel.Index)
+		channel.track(now, isOn)
+
+		if !isOn {
+			continue
+		}
+
+		if channel.OnFor(now) >= channel.Duration {
+			channel.IsCutOff = true
+		}
+
+		if channel.IsCutOff {
+			res = res.SetBit(channel.Index, false)
+
+			if display != nil {
+				display.Set(channel.Index, ChannelStatusWatchDog)
+			}
+		}
+	}
+
+	return res
+}
+
+func (m *MaxPresencePipelineItem) getChannels() []DetectorChannel {
+	return m.Channels
+}
@@ -9,35 +9,46 @@ import (
 
 // RadarFailsafePipelineItem must be registered with the highest order, which will make it
 // execute last. It should be setup and executed per radar (RadarChannel).  Its purpose
-// Is to simply set/clear flags based on whether the radar is sending message or not.
+// Is to simply set/clear flags based on whether the radar is sending message or not,
+// or is sending while reporting a degraded health (IsDegraded).
 type RadarFailsafePipelineItem struct {
 	TriggerPipelineItemMixin
 	SetChannels         utils.Uint128 `json:"SetChannels"`
 	ClearChannels       utils.Uint128 `json:"ClearChannels"`
 	NoRadarActivitySecs int           `json:"NoRadarActivitySecs"`
+	IsActive            bool          `json:"IsActive"`
+	IsDegraded          bool          `json:"IsDegraded"`
 }
 
 //func (r *RadarFailsafePipelineItem) AfterInit() {
 //}
 
 func (r *RadarFailsafePipelineItem) Execute(now time.Time, source utils.Uint128, display ITriggerDisplay) utils.Uint128 {
+	// A NoRadarActivitySecs of 0 fails safe as soon as the radar is silent,
+	// a degraded health fails safe whatever the activity
 	// WARNING: Review the next line
-	if !utils.Time.IsExpired(r.UpdateOn, now, time.Duration(r.NoRadarActivitySecs)*time.Second) {
+	isSilent := utils.Time.IsExpired(r.UpdateOn, now, time.Duration(r.NoRadarActivitySecs)*time.Second)
+	if !isSilent && !r.IsDegraded {
+		r.IsActive = false
 		return source
 	}
 
-	lo := bit.U64Bits(r.ClearChannels.Lo)
-	lo.ForNotSet(func(index int, isNotSet bool) {
-		if isNotSet {
-			display.Set(index, ChannelStatusFailSafeOff)
-		}
-	})
-
-	lo.ForEachBit(func(index int, isSet bool) {
-		if isSet {
-			display.Set(index, ChannelStatusFailSafeOn)
-		}
-	})
+	r.IsActive = true
+
+	if display != nil {
+		lo := bit.U64Bits(r.ClearChannels.Lo)
+		lo.ForNotSet(func(index int, isNotSet bool) {
+			if isNotSet {
+				display.Set(index, ChannelStatusFailSafeOff)
+			}
+		})
+
+		lo.ForEachBit(func(index int, isSet bool) {
+			if isSet {
+				display.Set(index, ChannelStatusFailSafeOn)
+			}
+		})
+	}
 
 	res := source.And(r.ClearChannels)
 	res = res.Or(r.SetChannels)
@@ -0,0 +1,50 @@
+package triggerpipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/utils"
+)
+
+func TestRadarFailsafePipelineItem_Execute(t *testing.T) {
+	item := &RadarFailsafePipelineItem{SetChannels: utils.Uint128{Lo: 0b10}, NoRadarActivitySecs: 5}
+	now := time.Now()
+	item.SetUpdateOn(now)
+
+	assert.Equal(t, call, item.Execute(now.Add(5*time.Second), call, nil))
+	assert.False(t, item.IsActive)
+
+	// Silent beyond NoRadarActivitySecs
+	assert.Equal(t, utils.Uint128{Lo: 0b10}, item.Execute(now.Add(6*time.Second), call, nil))
+	assert.True(t, item.IsActive)
+}
+
+func TestRadarFailsafePipelineItem_Degraded(t *testing.T) {
+	item := &RadarFailsafePipelineItem{SetChannels: utils.Uint128{Lo: 0b10}, NoRadarActivitySecs: 5}
+	now := time.Now()
+	item.SetUpdateOn(now)
+
+	// A degraded health fails safe while the radar is sending
+	item.IsDegraded = true
+	assert.Equal(t, utils.Uint128{Lo: 0b10}, item.Execute(now.Add(time.Second), call, nil))
+	assert.True(t, item.IsActive)
+
+	item.IsDegraded = false
+	assert.Equal(t, call, item.Execute(now.Add(time.Second), call, nil))
+	assert.False(t, item.IsActive)
+}
+
+func TestRadarFailsafePipelineItem_ZeroSecs(t *testing.T) {
+	item := &RadarFailsafePipelineItem{SetChannels: utils.Uint128{Lo: 0b10}}
+	now := time.Now()
+	item.SetUpdateOn(now)
+
+	assert.Equal(t, call, item.Execute(now, call, nil))
+	assert.False(t, item.IsActive)
+
+	// Without NoRadarActivitySecs a silent radar fails safe straight away
+	assert.Equal(t, utils.Uint128{Lo: 0b10}, item.Execute(now.Add(time.Millisecond), call, nil))
+	assert.True(t, item.IsActive)
+}
@@ -0,0 +1,137 @@
+package triggerpipeline
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"rvpro3/radarvision.com/utils"
+)
+
+// TriggerFrame is a single pipeline execution as recorded by the TriggerRecorder
+type TriggerFrame struct {
+	On     time.Time
+	Source utils.Uint128
+	Items  []TriggerFrameItem
+	Result utils.Uint128
+}
+
+type TriggerFrameItem struct {
+	Name     string
+	RadarIP  string
+	Input    utils.Uint128
+	UpdateOn time.Time
+	Output   utils.Uint128
+}
+
+// IsSameAs compares the triggers of the frames, ignoring the times
+func (f *TriggerFrame) IsSameAs(other *TriggerFrame) bool {
+	if !f.Source.Equals(other.Source) || !f.Result.Equals(other.Result) {
+		return false
+	}
+
+	if len(f.Items) != len(other.Items) {
+		return false
+	}
+
+	for index := range f.Items {
+		item := &f.Items[index]
+		otherItem := &other.Items[index]
+
+		if item.Name != otherItem.Name ||
+			item.RadarIP != otherItem.RadarIP ||
+			!item.Input.Equals(otherItem.Input) ||
+			!item.Output.Equals(otherItem.Output) {
+			return false
+		}
+	}
+	return true
+}
+
+// CopyFrom deep copies the source frame, reusing the items slice
+func (f *TriggerFrame) CopyFrom(source *TriggerFrame) {
+	f.On = source.On
+	f.Source = source.Source
+	f.Result = source.Result
+	f.Items = append(f.Items[:0], source.Items...)
+}
+
+func (f *TriggerFrame) FindItem(name string, radarIP string) *TriggerFrameItem {
+	for index := range f.Items {
+		if f.Items[index].Name == name && f.Items[index].RadarIP == radarIP {
+			return &f.Items[index]
+		}
+	}
+	return nil
+}
+
+// triggerFrameCapture is the ITriggerRecorder that fills the Frame
+type triggerFrameCapture struct {
+	Frame TriggerFrame
+}
+
+func (c *triggerFrameCapture) StartFrame(now time.Time, source utils.Uint128) {
+	c.Frame.On = now
+	c.Frame.Source = source
+	c.Frame.Items = c.Frame.Items[:0]
+}
+
+func (c *triggerFrameCapture) RecordItem(
+	item ITriggerPipelineItem,
+	input utils.Uint128,
+	updateOn time.Time,
+	output utils.Uint128,
+) {
+	c.Frame.Items = append(c.Frame.Items, TriggerFrameItem{
+		Name:     item.GetName(),
+		RadarIP:  item.GetRadarIP().String(),
+		Input:    input,
+		UpdateOn: updateOn,
+		Output:   output,
+	})
+}
+
+func (c *triggerFrameCapture) EndFrame(result utils.Uint128) {
+	c.Frame.Result = result
+}
+
+type triggerFrameHelper struct {
+}
+
+var TriggerFrameHelper triggerFrameHelper
+
+// Load reads a JSONL timeline as written by the TriggerRecorder
+func (triggerFrameHelper) Load(source io.Reader) (res []TriggerFrame, err error) {
+	scanner := bufio.NewScanner(source)
+	scanner.Buffer(make([]byte, 64*utils.Kilobyte), utils.Megabyte)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var frame TriggerFrame
+		if err = json.Unmarshal(line, &frame); err != nil {
+			return nil, err
+		}
+		res = append(res, frame)
+	}
+
+	return res, scanner.Err()
+}
+
+func (triggerFrameHelper) LoadFile(fileName string) ([]TriggerFrame, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = file.Close()
+	}()
+
+	return TriggerFrameHelper.Load(file)
+}
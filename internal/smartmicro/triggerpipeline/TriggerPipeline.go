@@ -10,7 +10,8 @@ import (
 const TriggerPipelineStateName = "Pipeline"
 
 type TriggerPipeline struct {
-	Item []ITriggerPipelineItem
+	Item     []ITriggerPipelineItem
+	Recorder ITriggerRecorder `json:"-"`
 }
 
 func (t *TriggerPipeline) AddItem(item ITriggerPipelineItem) ITriggerPipelineItem {
@@ -58,6 +59,10 @@ func (t *TriggerPipeline) ListByRadar(radarIP utils.IP4, fill []ITriggerPipeline
 }
 
 func (t *TriggerPipeline) Execute(now time.Time, source utils.Uint128, display ITriggerDisplay) utils.Uint128 {
+	if t.Recorder != nil {
+		return t.executeRecorded(now, source, display)
+	}
+
 	res := source
 
 	for _, item := range t.Item {
@@ -67,6 +72,23 @@ func (t *TriggerPipeline) Execute(now time.Time, source utils.Uint128, display I
 	return res
 }
 
+// executeRecorded is Execute, but with the input and output of each item
+// handed to the Recorder
+func (t *TriggerPipeline) executeRecorded(now time.Time, source utils.Uint128, display ITriggerDisplay) utils.Uint128 {
+	res := source
+	t.Recorder.StartFrame(now, source)
+
+	for _, item := range t.Item {
+		input := item.GetTrigger()
+		updateOn := item.GetUpdateOn()
+		res = item.Execute(now, res, display)
+		t.Recorder.RecordItem(item, input, updateOn, res)
+	}
+
+	t.Recorder.EndFrame(res)
+	return res
+}
+
 func (t *TriggerPipeline) ExecuteList(item []ITriggerPipelineItem) utils.Uint128 {
 	now := time.Now()
 
@@ -78,3 +100,36 @@ func (t *TriggerPipeline) ExecuteList(item []ITriggerPipelineItem) utils.Uint128
 
 	return res
 }
+
+// CarryOver copies the state of the previous items to the items of the same
+// name and radar, so a rebuilt pipeline continues where the previous left
+// off (the calls held, the failsafe timer, the detector channel timers)
+func (t *TriggerPipeline) CarryOver(previous *TriggerPipeline) {
+	for _, item := range t.Item {
+		previousItem := previous.Find(item.GetName(), item.GetRadarIP())
+		if previousItem == nil {
+			continue
+		}
+
+		triggers := previousItem.GetTrigger()
+		item.SetTrigger(previousItem.GetSetOn(), triggers.Hi, triggers.Lo)
+		item.SetUpdateOn(previousItem.GetUpdateOn())
+
+		detectorItem, ok := item.(detectorPipelineItem)
+		previousDetectorItem, isPreviousOk := previousItem.(detectorPipelineItem)
+
+		if ok && isPreviousOk {
+			carryOverChannels(detectorItem.getChannels(), previousDetectorItem.getChannels())
+		}
+	}
+}
+
+// Replace takes over the items (and recorder) of the pipeline
+func (t *TriggerPipeline) Replace(pipeline *TriggerPipeline) {
+	t.Item = pipeline.Item
+	t.Recorder = pipeline.Recorder
+
+	for _, item := range t.Item {
+		item.SetParent(t)
+	}
+}
@@ -0,0 +1,129 @@
+package triggerpipeline
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"rvpro3/radarvision.com/utils"
+)
+
+// PlaybackResult is the TriggerDiff name used for the final pipeline result
+const PlaybackResult = "Result"
+
+// TriggerPlayback re-runs a recorded timeline against a (new) pipeline and
+// reports where the outputs differ from the recording.
+// The recorded inputs are applied before each frame executes:
+//   - TriggerInputs are items set from outside the pipeline (radar relays,
+//     zone detection and manual overrides) and receive the recorded trigger
+//   - ActivityInputs are items driven by radar activity and receive the
+//     recorded update time
+//
+// All other items run on their own state, which is what is being tested
+type TriggerPlayback struct {
+	Pipeline       *TriggerPipeline
+	TriggerInputs  []string
+	ActivityInputs []string
+	Display        ChannelDisplay
+	capture        triggerFrameCapture
+}
+
+type TriggerDiff struct {
+	Frame    int
+	On       time.Time
+	Name     string
+	RadarIP  string
+	Expected utils.Uint128
+	Actual   utils.Uint128
+}
+
+func (d TriggerDiff) String() string {
+	return fmt.Sprintf(
+		"frame %d (%s) %s %s expected %s, actual %s",
+		d.Frame,
+		d.On.Format(utils.DisplayDateTimeMS),
+		d.Name,
+		d.RadarIP,
+		d.Expected,
+		d.Actual,
+	)
+}
+
+func NewTriggerPlayback(pipeline *TriggerPipeline) *TriggerPlayback {
+	return &TriggerPlayback{
+		Pipeline:       pipeline,
+		TriggerInputs:  []string{Staging, ZoneDetect, Manual},
+		ActivityInputs: []string{Failsafe},
+	}
+}
+
+// Run plays the frames in order and returns the differences.  Item outputs
+// are only compared for items present in both the recording and the pipeline
+func (p *TriggerPlayback) Run(frames []TriggerFrame) (res []TriggerDiff) {
+	recorder := p.Pipeline.Recorder
+	p.Pipeline.Recorder = &p.capture
+
+	defer func() {
+		p.Pipeline.Recorder = recorder
+	}()
+
+	for index := range frames {
+		expected := &frames[index]
+
+		p.applyInputs(expected)
+		p.Display.Clear(len(p.Display.Status), ChannelStatusNoCall)
+		p.Pipeline.Execute(expected.On, expected.Source, &p.Display)
+
+		res = p.diff(res, index, expected)
+	}
+
+	return res
+}
+
+func (p *TriggerPlayback) applyInputs(frame *TriggerFrame) {
+	for index := range frame.Items {
+		recorded := &frame.Items[index]
+		item := p.Pipeline.Find(recorded.Name, utils.IP4Builder.FromString(recorded.RadarIP))
+
+		if item == nil {
+			continue
+		}
+
+		if slices.Contains(p.TriggerInputs, recorded.Name) {
+			item.SetTrigger(frame.On, recorded.Input.Hi, recorded.Input.Lo)
+		}
+
+		if slices.Contains(p.ActivityInputs, recorded.Name) {
+			item.SetUpdateOn(recorded.UpdateOn)
+		}
+	}
+}
+
+func (p *TriggerPlayback) diff(res []TriggerDiff, index int, expected *TriggerFrame) []TriggerDiff {
+	for itemIndex := range expected.Items {
+		recorded := &expected.Items[itemIndex]
+		actual := p.capture.Frame.FindItem(recorded.Name, recorded.RadarIP)
+
+		if actual != nil && !actual.Output.Equals(recorded.Output) {
+			res = append(res, TriggerDiff{
+				Frame:    index,
+				On:       expected.On,
+				Name:     recorded.Name,
+				RadarIP:  recorded.RadarIP,
+				Expected: recorded.Output,
+				Actual:   actual.Output,
+			})
+		}
+	}
+
+	if !expected.Result.Equals(p.capture.Frame.Result) {
+		res = append(res, TriggerDiff{
+			Frame:    index,
+			On:       expected.On,
+			Name:     PlaybackResult,
+			Expected: expected.Result,
+			Actual:   p.capture.Frame.Result,
+		})
+	}
+	return res
+}
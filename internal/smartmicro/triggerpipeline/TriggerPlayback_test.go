@@ -0,0 +1,134 @@
+package triggerpipeline
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/utils"
+)
+
+var testRadarIP = utils.IP4Builder.FromString("192.168.11.12:55555")
+
+func newTestPipeline(failsafeSecs int) (*TriggerPipeline, ITriggerPipelineItem) {
+	pipeline := new(TriggerPipeline)
+
+	staging := new(TriggerPipelineOrItem)
+	staging.RadarIP = testRadarIP
+	staging.Name = Staging
+	staging.Order = 10
+	pipeline.AddItem(staging)
+
+	failsafe := new(RadarFailsafePipelineItem)
+	failsafe.RadarIP = testRadarIP
+	failsafe.Name = Failsafe
+	failsafe.Order = 90
+	failsafe.SetChannels = utils.Uint128{Lo: 0x0f}
+	failsafe.NoRadarActivitySecs = failsafeSecs
+	pipeline.AddItem(failsafe)
+
+	return pipeline, staging
+}
+
+func recordTimeline(t *testing.T, isChangesOnly bool, queueSize int) []TriggerFrame {
+	var buffer bytes.Buffer
+
+	pipeline, staging := newTestPipeline(5)
+	failsafe := pipeline.Find(Failsafe, testRadarIP)
+	recorder := NewTriggerRecorder(&buffer)
+	recorder.IsChangesOnly = isChangesOnly
+	if queueSize > 0 {
+		recorder.Start(queueSize)
+	}
+	pipeline.Recorder = recorder
+
+	start := time.Unix(1700000000, 0)
+	display := &ChannelDisplay{}
+
+	for second := 0; second < 10; second++ {
+		now := start.Add(time.Duration(second) * time.Second)
+
+		// The radar stops sending after 3 seconds
+		if second <= 3 {
+			staging.SetTrigger(now, 0, uint64(second%2))
+			failsafe.SetUpdateOn(now)
+		}
+		pipeline.Execute(now, utils.Uint128{}, display)
+	}
+
+	assert.NoError(t, recorder.Close())
+	assert.Equal(t, 10, recorder.FrameCount)
+	assert.Equal(t, 0, recorder.DropCount)
+
+	frames, err := TriggerFrameHelper.Load(&buffer)
+	assert.NoError(t, err)
+	assert.Equal(t, recorder.WriteCount, len(frames))
+	return frames
+}
+
+func TestTriggerRecorder_ChangesOnly(t *testing.T) {
+	frames := recordTimeline(t, true, 0)
+
+	// 0, 1, 0, 1, then 1 (no change), failsafe at 9 seconds
+	assert.Equal(t, 5, len(frames))
+	assert.Equal(t, uint64(0x0f), frames[4].Result.Lo)
+	assert.Equal(t, uint64(1), frames[1].FindItem(Staging, testRadarIP.String()).Input.Lo)
+}
+
+func TestTriggerRecorder_Full(t *testing.T) {
+	frames := recordTimeline(t, false, 0)
+	assert.Equal(t, 10, len(frames))
+}
+
+func TestTriggerRecorder_Queue(t *testing.T) {
+	frames := recordTimeline(t, true, 16)
+	assert.Equal(t, 5, len(frames))
+	assert.Equal(t, uint64(0x0f), frames[4].Result.Lo)
+}
+
+func TestTriggerRecorder_Rotate(t *testing.T) {
+	pathTemplate := filepath.Join(t.TempDir(), "pipeline-%s.jsonl")
+	recorder, err := CreateRotatingTriggerRecorder(pathTemplate, 1, 2)
+	assert.NoError(t, err)
+
+	recorder.Start(4)
+
+	pipeline, _ := newTestPipeline(5)
+	pipeline.Recorder = recorder
+
+	start := time.Unix(1700000000, 0)
+	for second := 0; second < 4; second++ {
+		pipeline.Execute(start.Add(time.Duration(second)*time.Second), utils.Uint128{}, &ChannelDisplay{})
+	}
+	assert.NoError(t, recorder.Close())
+	assert.NoError(t, recorder.Err)
+
+	files, err := filepath.Glob(filepath.Join(filepath.Dir(pathTemplate), "*.jsonl"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(files), "the oldest files are removed")
+	assert.Equal(t, recorder.FileNames, files)
+}
+
+func TestTriggerPlayback_Same(t *testing.T) {
+	frames := recordTimeline(t, false, 0)
+	pipeline, _ := newTestPipeline(5)
+
+	diffs := NewTriggerPlayback(pipeline).Run(frames)
+	assert.Empty(t, diffs)
+}
+
+func TestTriggerPlayback_Changed(t *testing.T) {
+	frames := recordTimeline(t, false, 0)
+
+	// A longer failsafe time means that the failsafe does not kick in
+	pipeline, _ := newTestPipeline(10)
+
+	diffs := NewTriggerPlayback(pipeline).Run(frames)
+	assert.Equal(t, 2, len(diffs))
+	assert.Equal(t, Failsafe, diffs[0].Name)
+	assert.Equal(t, PlaybackResult, diffs[1].Name)
+	assert.Equal(t, uint64(0x0f), diffs[1].Expected.Lo)
+	assert.Equal(t, uint64(0x01), diffs[1].Actual.Lo)
+}
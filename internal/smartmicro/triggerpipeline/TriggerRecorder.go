@@ -0,0 +1,234 @@
+package triggerpipeline
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"rvpro3/radarvision.com/utils"
+)
+
+// TriggerRecorder writes a JSONL timeline of the pipeline executions, one
+// TriggerFrame per line.  When IsChangesOnly is set, a frame is only written
+// if the source, an item input or output, or the result differs from the
+// previously written frame.  The update times are not considered a change,
+// as the failsafe item's update time changes with each radar message, so a
+// changes only timeline is smaller but does not play back the failsafe
+// timing exactly; by default every frame is written.
+// A recorder created with CreateRotatingTriggerRecorder starts a new file
+// once MaxFileBytes are written, keeping the last MaxFiles files.  Once
+// started, the frames are written by the writer goroutine; WriteCount, Err
+// and FileNames are then only read after Close
+type TriggerRecorder struct {
+	IsChangesOnly bool
+	FlushInterval time.Duration
+	PathTemplate  string
+	MaxFileBytes  int64
+	MaxFiles      int
+	FileNames     []string
+	FrameCount    int
+	WriteCount    int
+	DropCount     int
+	Err           error
+	writer        *bufio.Writer
+	encoder       *json.Encoder
+	closer        io.Closer
+	counter       *countingWriter
+	previous      TriggerFrame
+	hasPrevious   bool
+	flushOn       time.Time
+	queue         chan *TriggerFrame
+	done          chan struct{}
+	triggerFrameCapture
+}
+
+// countingWriter counts the bytes written to the file, for the rotation
+type countingWriter struct {
+	target  io.Writer
+	written int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.target.Write(p)
+	c.written += int64(n)
+	return n, err
+}
+
+func NewTriggerRecorder(target io.Writer) *TriggerRecorder {
+	res := &TriggerRecorder{
+		FlushInterval: time.Second,
+	}
+	res.setTarget(target)
+	return res
+}
+
+func (r *TriggerRecorder) setTarget(target io.Writer) {
+	r.counter = &countingWriter{target: target}
+	r.writer = bufio.NewWriter(r.counter)
+	r.encoder = json.NewEncoder(r.writer)
+}
+
+// CreateTriggerRecorder creates (or truncates) the timeline file, including
+// any missing directories
+func CreateTriggerRecorder(fileName string) (*TriggerRecorder, error) {
+	if err := os.MkdirAll(filepath.Dir(fileName), os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	res := NewTriggerRecorder(file)
+	res.closer = file
+	res.FileNames = append(res.FileNames, fileName)
+	return res, nil
+}
+
+// CreateRotatingTriggerRecorder creates the timeline file of the path template
+// (%s the time), rotated every maxFileBytes and keeping maxFiles files
+func CreateRotatingTriggerRecorder(pathTemplate string, maxFileBytes int64, maxFiles int) (*TriggerRecorder, error) {
+	res, err := CreateTriggerRecorder(fmt.Sprintf(pathTemplate, time.Now().Format(utils.FileDateTimeMS)))
+	if err != nil {
+		return nil, err
+	}
+
+	res.PathTemplate = pathTemplate
+	res.MaxFileBytes = maxFileBytes
+	res.MaxFiles = maxFiles
+	return res, nil
+}
+
+// Start starts the writer goroutine, the frames are then queued (at most
+// size) and written off the goroutine of the pipeline.  A frame is dropped
+// when the queue is full
+func (r *TriggerRecorder) Start(size int) {
+	if r.queue != nil {
+		return
+	}
+
+	if size <= 0 {
+		size = 1
+	}
+
+	r.queue = make(chan *TriggerFrame, size)
+	r.done = make(chan struct{})
+	go r.run(r.queue, r.done)
+}
+
+func (r *TriggerRecorder) EndFrame(result utils.Uint128) {
+	r.Frame.Result = result
+	r.FrameCount++
+
+	if r.IsChangesOnly && r.hasPrevious && r.Frame.IsSameAs(&r.previous) {
+		return
+	}
+
+	r.previous.CopyFrom(&r.Frame)
+	r.hasPrevious = true
+
+	if r.queue == nil {
+		r.write(&r.Frame)
+		return
+	}
+
+	frame := new(TriggerFrame)
+	frame.CopyFrom(&r.Frame)
+
+	select {
+	case r.queue <- frame:
+	default:
+		r.DropCount++
+	}
+}
+
+func (r *TriggerRecorder) run(queue chan *TriggerFrame, done chan struct{}) {
+	defer close(done)
+
+	for frame := range queue {
+		r.write(frame)
+	}
+}
+
+func (r *TriggerRecorder) write(frame *TriggerFrame) {
+	if err := r.encoder.Encode(frame); err != nil {
+		r.Err = err
+		return
+	}
+	r.WriteCount++
+
+	if r.PathTemplate != "" && r.MaxFileBytes > 0 && r.counter.written+int64(r.writer.Buffered()) >= r.MaxFileBytes {
+		if err := r.rotate(frame.On); err != nil {
+			r.Err = err
+		}
+		return
+	}
+
+	// The pipeline runs for the lifetime of the process, flush periodically
+	// so that the timeline is usable while recording
+	if utils.Time.IsExpired(frame.On, r.flushOn, r.FlushInterval) {
+		r.flushOn = frame.On
+		if err := r.writer.Flush(); err != nil {
+			r.Err = err
+		}
+	}
+}
+
+// rotate closes the file and continues in a new file, removing the oldest
+// files beyond MaxFiles
+func (r *TriggerRecorder) rotate(on time.Time) error {
+	if err := r.closeFile(); err != nil {
+		return err
+	}
+
+	fileName := fmt.Sprintf(r.PathTemplate, on.Format(utils.FileDateTimeMS))
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+
+	r.setTarget(file)
+	r.closer = file
+	r.FileNames = append(r.FileNames, fileName)
+
+	for r.MaxFiles > 0 && len(r.FileNames) > r.MaxFiles {
+		if err = os.Remove(r.FileNames[0]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		r.FileNames = r.FileNames[1:]
+	}
+	return nil
+}
+
+// Flush flushes the timeline of a recorder not started
+func (r *TriggerRecorder) Flush() error {
+	return r.writer.Flush()
+}
+
+// Close writes the frames queued, stops the writer goroutine, flushes the
+// timeline and closes the file (if created with CreateTriggerRecorder)
+func (r *TriggerRecorder) Close() error {
+	if r.queue != nil {
+		close(r.queue)
+		<-r.done
+		r.queue = nil
+	}
+	return r.closeFile()
+}
+
+func (r *TriggerRecorder) closeFile() error {
+	err := r.writer.Flush()
+
+	if r.closer != nil {
+		if closeErr := r.closer.Close(); err == nil {
+			err = closeErr
+		}
+		r.closer = nil
+	}
+	return err
+}
@@ -19,13 +19,7 @@ func (t *RadarCSVWriterMixin) InitWriter(
 }
 
 func (t *RadarCSVWriterMixin) Close() {
-	w, err := t.CSVFacade.GetWriter()
-	if err != nil {
-		return
-	}
-
-	_ = w.Flush()
-	w.Close()
+	t.CSVFacade.Close()
 }
 
 func (t *RadarCSVWriterMixin) Flush() error {
@@ -0,0 +1,60 @@
+package generic
+
+import (
+	"errors"
+	"time"
+
+	"rvpro3/radarvision.com/internal/smartmicro/interfaces"
+	"rvpro3/radarvision.com/internal/smartmicro/port"
+	"rvpro3/radarvision.com/utils"
+)
+
+// StreamDecoder returns the stream message of the radar data, or
+// port.ErrUnsupportedVersion for the port versions not streamed
+type StreamDecoder func(radarIP utils.IP4, now time.Time, bytes []byte) (any, error)
+
+// SocketStreamActivity streams the radar data of the workflow to the clients
+// of the Sink subscribed to the radar (Subscription), the data is only
+// decoded while a client is subscribed
+type SocketStreamActivity struct {
+	interfaces.UDPActivityMixin
+	Sink         interfaces.IStreamSink `json:"-"`
+	Subscription uint64
+	Decode       StreamDecoder               `json:"-"`
+	Metrics      SocketStreamActivityMetrics `json:"-"`
+}
+
+type SocketStreamActivityMetrics struct {
+	StreamedCount      *utils.Metric
+	UnsupportedVersion *utils.Metric
+	ErrCount           *utils.Metric
+	utils.MetricsInitMixin
+}
+
+func (s *SocketStreamActivity) Init(workflow interfaces.IUDPWorkflow, index int, fullName string) {
+	s.InitBase(workflow, index, fullName)
+	s.Metrics.InitMetrics(fullName, &s.Metrics)
+}
+
+func (s *SocketStreamActivity) Process(now time.Time, bytes []byte) {
+	radarIP := s.Workflow.GetRadarIP()
+	if !s.Sink.IsAnySubscribedTo(s.Subscription, radarIP) {
+		return
+	}
+
+	message, err := s.Decode(radarIP, now, bytes)
+	if errors.Is(err, port.ErrUnsupportedVersion) {
+		s.Metrics.UnsupportedVersion.IncAt(1, now)
+		return
+	}
+
+	if err == nil {
+		err = s.Sink.Stream(s.Subscription, radarIP, message)
+	}
+
+	if err != nil {
+		s.Metrics.ErrCount.IncAt(1, now)
+		return
+	}
+	s.Metrics.StreamedCount.IncAt(1, now)
+}
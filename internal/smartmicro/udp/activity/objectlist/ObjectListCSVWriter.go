@@ -28,7 +28,7 @@ func (o *ObjectListCSVWriter) Init() {
 }
 
 func (o *ObjectListCSVWriter) onFilenameCallback(provider *utils.CSVRollOverFileWriterProvider) string {
-	now := time.Now()
+	now := utils.Time.Corrected()
 
 	if o.FileName == "" || !utils.Time.IsSameDay(o.FileDate, now) {
 		o.FileName = fmt.Sprintf(o.CSVFacade.PathTemplate, now.Format(utils.FileDateTimeSecond), o.FileNo)
@@ -62,7 +62,7 @@ func (o *ObjectListCSVWriter) Write(now time.Time, objectId int, class port.Obje
 	}
 
 	w.WriteColsNL(
-		now.Format(utils.DisplayDateTimeMS),
+		utils.Time.Correct(now).Format(utils.DisplayDateTimeMS),
 		strconv.Itoa(objectId),
 		class.String(),
 		strconv.Itoa(zone),
@@ -1,6 +1,7 @@
 package objectlist
 
 import (
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -13,7 +14,11 @@ func TestObjectListCSVWriter_Write(t *testing.T) {
 	wr.SpeedUnit = "mph"
 	wr.DistanceUnit = "ft"
 	wr.MaxRecords = 5
-	wr.Init("/tmp/objlist-%s.%d.csv", "serial", "name", utils.IP4{})
+	wr.CSVFacade.PathTemplate = filepath.Join(t.TempDir(), "objlist-%s.%d.csv")
+	wr.SensorIP = "127.0.0.1"
+	wr.SensorName = "name"
+	wr.SensorSerial = "serial"
+	wr.Init()
 
 	for n := range 10 {
 		utils.Debug.Panic(wr.Write(
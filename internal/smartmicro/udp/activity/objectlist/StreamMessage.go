@@ -0,0 +1,48 @@
+package objectlist
+
+import (
+	"time"
+
+	"rvpro3/radarvision.com/internal/models/streammodel"
+	"rvpro3/radarvision.com/internal/smartmicro/port"
+	"rvpro3/radarvision.com/utils"
+)
+
+// StreamMessageOf returns the objects of the object list (version 3.0) as
+// streamed to the clients (generic.StreamDecoder)
+func StreamMessageOf(radarIP utils.IP4, now time.Time, bytes []byte) (any, error) {
+	th := port.TransportHeaderReader{
+		Buffer: bytes,
+	}
+
+	ph := port.PortHeaderReader{
+		Buffer:      bytes,
+		StartOffset: int(th.GetHeaderLength()),
+	}
+
+	if ph.GetPortMajorVersion() != 3 || ph.GetPortMinorVersion() != 0 {
+		return nil, port.ErrUnsupportedVersion
+	}
+
+	objList := port.ObjectListReader{}
+	objList.Init(bytes)
+
+	noObjects := int(objList.GetNofObjects())
+	message := &streammodel.ObjectListMessage{
+		RadarMessage: streammodel.NewRadarMessage(streammodel.ObjectListType, radarIP, now),
+		Objects:      make([]streammodel.Object, 0, noObjects),
+	}
+
+	for objIdx := 0; objIdx < noObjects; objIdx++ {
+		message.Objects = append(message.Objects, streammodel.Object{
+			Id:      int(objList.GetObjectId(objIdx)),
+			Class:   objList.GetObjectClass(objIdx).String(),
+			X:       objList.GetPosXFront(objIdx),
+			Y:       objList.GetPosYFront(objIdx),
+			Speed:   objList.GetSpeed(objIdx),
+			Heading: objList.GetHeading(objIdx),
+			Length:  objList.GetLength(objIdx),
+		})
+	}
+	return message, nil
+}
@@ -0,0 +1,107 @@
+package objectlist
+
+import (
+	"time"
+
+	"rvpro3/radarvision.com/internal/models/servicemodel"
+	"rvpro3/radarvision.com/internal/smartmicro/interfaces"
+	"rvpro3/radarvision.com/internal/smartmicro/port"
+	"rvpro3/radarvision.com/internal/smartmicro/triggerpipeline"
+	"rvpro3/radarvision.com/internal/smartmicro/udp/state"
+	"rvpro3/radarvision.com/utils"
+)
+
+// ZoneDetectActivity matches each object of the object list against the
+// zones of the radar channels and raises the channel calls through the
+// ZoneDetect pipeline item.  The object speed and distance (meters and meters
+// per second) are converted to the configured units using the factors.  Only
+// the channels with ChannelSource zone are detected, the speed keeps its sign
+// so that a zone only matches its direction of travel
+type ZoneDetectActivity struct {
+	interfaces.UDPActivityMixin
+	Radar          *servicemodel.Radar
+	DistanceFactor float64
+	SpeedFactor    float64
+	Calls          utils.Uint128
+	RadarState     *state.RadarState         `json:"-"`
+	Metrics        ZoneDetectActivityMetrics `json:"-"`
+}
+
+type ZoneDetectActivityMetrics struct {
+	ObjectCount        *utils.Metric
+	MatchCount         *utils.Metric
+	UnsupportedVersion *utils.Metric
+	ObjectListSizeErr  *utils.Metric
+	utils.MetricsInitMixin
+}
+
+func (z *ZoneDetectActivity) Init(workflow interfaces.IUDPWorkflow, index int, fullName string) {
+	z.InitBase(workflow, index, fullName)
+	z.Metrics.InitMetrics(fullName, &z.Metrics)
+
+	z.RadarState = state.RadarStateHelper.GetOrSet(workflow.GetRadarIP())
+}
+
+func (z *ZoneDetectActivity) Process(now time.Time, bytes []byte) {
+	th := port.TransportHeaderReader{
+		Buffer: bytes,
+	}
+
+	ph := port.PortHeaderReader{
+		Buffer:      bytes,
+		StartOffset: int(th.GetHeaderLength()),
+	}
+
+	if ph.GetPortMajorVersion() != 3 || ph.GetPortMinorVersion() != 0 {
+		z.Metrics.UnsupportedVersion.IncAt(1, now)
+		return
+	}
+
+	objList := port.ObjectListReader{}
+	objList.Init(bytes)
+
+	if objList.TotalSize() > len(bytes) {
+		z.Metrics.ObjectListSizeErr.IncAt(1, now)
+		return
+	}
+
+	z.Calls = z.Detect(&objList)
+	z.Metrics.ObjectCount.IncAt(int64(objList.GetNofObjects()), now)
+
+	// The pipeline item is only absent when the radar has no zones
+	z.RadarState.SetTrigger(triggerpipeline.ZoneDetect, now, z.Calls.Hi, z.Calls.Lo)
+}
+
+// Detect returns the channels with at least one object in one of its zones
+func (z *ZoneDetectActivity) Detect(objList *port.ObjectListReader) (res utils.Uint128) {
+	noObjects := int(objList.GetNofObjects())
+
+	for objIdx := range noObjects {
+		speed := float64(objList.GetSpeed(objIdx)) * z.SpeedFactor
+		distance := float64(objList.GetPosXFront(objIdx)) * z.DistanceFactor
+		lane := int(objList.GetLane(objIdx))
+		class := int(objList.GetObjectClass(objIdx))
+
+		for index := range z.Radar.Channels {
+			channel := &z.Radar.Channels[index]
+
+			if !channel.IsZoneDetect() || res.IsBit(channel.GetIndex()) || !z.isMatch(channel, speed, distance, lane, class) {
+				continue
+			}
+
+			res = res.SetBit(channel.GetIndex(), true)
+			z.Metrics.MatchCount.Inc(1)
+		}
+	}
+
+	return res
+}
+
+func (z *ZoneDetectActivity) isMatch(channel *servicemodel.Channel, speed float64, distance float64, lane int, class int) bool {
+	for index := range channel.Zones {
+		if channel.Zones[index].IsMatch(speed, distance, lane, class) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package objectlist
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/internal/models/servicemodel"
+	"rvpro3/radarvision.com/internal/smartmicro/port"
+	"rvpro3/radarvision.com/internal/smartmicro/triggerpipeline"
+	"rvpro3/radarvision.com/internal/smartmicro/udp/state"
+	"rvpro3/radarvision.com/utils"
+)
+
+type testObject struct {
+	speed    float32
+	distance float32
+	lane     uint16
+	class    port.ObjectClassType
+}
+
+// objectListBytes returns a version 3.0 object list (object center reference
+// point) with the objects
+func objectListBytes(objects ...testObject) []byte {
+	const detailLen = 56
+
+	th := port.TransportHeader{}
+	th.Init()
+	ph := port.PortHeader{}
+	ph.Init(port.PiObjectList)
+	ph.PortMajorVersion = 3
+	ph.PortMinorVersion = 0
+
+	res := make([]byte, 256+len(objects)*detailLen)
+	writer := utils.NewFixedBuffer(res, 0, 0)
+	th.Write(&writer)
+	writer.WriteCRC16(binary.BigEndian)
+	ph.Write(&writer)
+	order := ph.GetOrder()
+
+	writer.WriteF32(0.05, order)
+	writer.WriteU16(uint16(len(objects)), order)
+	writer.WriteU8(uint8(port.RpObjectCenter))
+	writer.WriteU8(detailLen)
+	writer.WriteU64(0, order)
+
+	for _, object := range objects {
+		detail := make([]byte, detailLen)
+		order.PutUint32(detail[0:], math.Float32bits(object.distance))
+		order.PutUint32(detail[20:], math.Float32bits(object.speed))
+		detail[46] = uint8(object.class)
+		order.PutUint16(detail[48:], object.lane)
+		writer.WriteBytes(detail)
+	}
+
+	return writer.AsWriteSlice()
+}
+
+func newTestZoneDetectActivity() *ZoneDetectActivity {
+	res := &ZoneDetectActivity{
+		Radar: &servicemodel.Radar{
+			Channels: []servicemodel.Channel{
+				{Channel: 1, ChannelSource: servicemodel.ChannelSourceZone, Zones: []servicemodel.Zone{
+					{Zone: 1, FromSpeed: 5, ToSpeed: 30, FromDistance: 10, ToDistance: 50},
+				}},
+				{Channel: 2, ChannelSource: servicemodel.ChannelSourceZone, Zones: []servicemodel.Zone{
+					{Zone: 2, FromSpeed: -30, ToSpeed: -5, Lanes: []int{2}},
+				}},
+				{Channel: 3, ChannelSource: servicemodel.ChannelSourceTrigger, Zones: []servicemodel.Zone{
+					{Zone: 3},
+				}},
+			},
+		},
+		DistanceFactor: 1,
+		SpeedFactor:    1,
+		RadarState:     new(state.RadarState),
+	}
+	zoneItem := new(triggerpipeline.TriggerPipelineOrItem)
+	zoneItem.Name = triggerpipeline.ZoneDetect
+	res.RadarState.Pipeline.AddItem(zoneItem)
+	res.Metrics.InitMetrics("test.zone", &res.Metrics)
+	return res
+}
+
+func TestZoneDetectActivity_Process(t *testing.T) {
+	activity := newTestZoneDetectActivity()
+	now := time.Now()
+
+	activity.Process(now, objectListBytes(testObject{speed: 10, distance: 20, lane: 1, class: port.OctCar}))
+	assert.Equal(t, uint64(0b1), activity.Calls.Lo, "only the approaching zone")
+	assert.Equal(t, uint64(0b1), activity.RadarState.Pipeline.Item[0].GetTrigger().Lo)
+
+	activity.Process(now, objectListBytes(testObject{speed: -10, distance: 20, lane: 1, class: port.OctCar}))
+	assert.Equal(t, uint64(0), activity.Calls.Lo, "the opposite direction is in the wrong lane")
+
+	activity.Process(now, objectListBytes(
+		testObject{speed: -10, distance: 20, lane: 2, class: port.OctCar},
+		testObject{speed: 10, distance: 20, lane: 2, class: port.OctCar},
+	))
+	assert.Equal(t, uint64(0b11), activity.Calls.Lo)
+	assert.Equal(t, int64(4), activity.Metrics.ObjectCount.Value)
+}
+
+func TestZoneDetectActivity_ProcessSize(t *testing.T) {
+	activity := newTestZoneDetectActivity()
+	bytes := objectListBytes(testObject{speed: 10, distance: 20})
+
+	activity.Process(time.Now(), bytes[:len(bytes)-10])
+	assert.Equal(t, int64(1), activity.Metrics.ObjectListSizeErr.Value)
+	assert.Equal(t, uint64(0), activity.Calls.Lo)
+}
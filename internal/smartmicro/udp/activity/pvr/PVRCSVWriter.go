@@ -65,7 +65,7 @@ func (p *PVRCSVWriter) Write(
 	}
 
 	w.WriteColsNL(
-		now.Format(utils.DisplayDateTimeMS),
+		utils.Time.Correct(now).Format(utils.DisplayDateTimeMS),
 		strconv.Itoa(objectId),
 		class.String(),
 		strconv.Itoa(zone),
@@ -79,7 +79,7 @@ func (p *PVRCSVWriter) Write(
 }
 
 func (p *PVRCSVWriter) onFilenameCallback(_ *utils.CSVRollOverFileWriterProvider) string {
-	now := time.Now()
+	now := utils.Time.Corrected()
 
 	if p.FileName == "" || !utils.Time.IsSameDay(p.FileDate, now) {
 		p.FileName = fmt.Sprintf(p.CSVFacade.PathTemplate, now.Format(utils.FileDateTimeSecond), p.FileNo)
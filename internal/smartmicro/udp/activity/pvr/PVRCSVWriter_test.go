@@ -13,7 +13,11 @@ func TestPVRCSVWriter_Write(t *testing.T) {
 	wr.SpeedUnit = "mph"
 	wr.DistanceUnit = "ft"
 	wr.MaxRecords = 5
-	wr.Init("/tmp/pvr-12-%s-%d.csv", "serial", "name", utils.IP4{})
+	wr.CSVFacade.PathTemplate = "/tmp/pvr-12-%s-%d.csv"
+	wr.SensorIP = "127.0.0.1"
+	wr.SensorName = "name"
+	wr.SensorSerial = "serial"
+	wr.Init()
 
 	for n := range 20 {
 		utils.Debug.Panic(wr.Write(time.Now(), n, port.OctLongTruck, n, 1.234, 27.554, 3.3, n))
@@ -0,0 +1,38 @@
+package pvr
+
+import (
+	"time"
+
+	"rvpro3/radarvision.com/internal/models/streammodel"
+	"rvpro3/radarvision.com/internal/smartmicro/port"
+	"rvpro3/radarvision.com/utils"
+)
+
+// StreamMessageOf returns the per vehicle records as streamed to the clients
+// (generic.StreamDecoder)
+func StreamMessageOf(radarIP utils.IP4, now time.Time, bytes []byte) (any, error) {
+	pvrObj := port.PVRReader{}
+	pvrObj.Init(bytes)
+
+	if !pvrObj.IsSupported() {
+		return nil, port.ErrUnsupportedVersion
+	}
+
+	noObjects := int(pvrObj.GetNofObjects())
+	message := &streammodel.PVRMessage{
+		RadarMessage: streammodel.NewRadarMessage(streammodel.PVRType, radarIP, now),
+		Objects:      make([]streammodel.PVRObject, 0, noObjects),
+	}
+
+	for idx := 0; idx < noObjects; idx++ {
+		message.Objects = append(message.Objects, streammodel.PVRObject{
+			Id:      int(pvrObj.GetObjectId(idx)),
+			Class:   pvrObj.GetObjectClass(idx).String(),
+			Zone:    int(pvrObj.GetZone(idx)),
+			Speed:   pvrObj.GetSpeed(idx),
+			Heading: pvrObj.GetHeading(idx),
+			Length:  pvrObj.GetLength(idx),
+		})
+	}
+	return message, nil
+}
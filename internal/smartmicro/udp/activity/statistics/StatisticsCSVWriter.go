@@ -47,6 +47,21 @@ func (t *StatisticsCSVWriter) Write(
 	avgSpeed float32,
 	head float32,
 	gap float32,
+) error {
+	return t.WriteCorrected(utils.Time.Correct(now), zone, class, volume, occupancy, avgSpeed, head, gap)
+}
+
+// WriteCorrected writes the statistics of a time on the reference clock
+// already, e.g. the start of a bin
+func (t *StatisticsCSVWriter) WriteCorrected(
+	correctedOn time.Time,
+	zone int,
+	class port.ObjectClassType,
+	volume int,
+	occupancy float32,
+	avgSpeed float32,
+	head float32,
+	gap float32,
 ) error {
 	writer, err := t.CSVFacade.GetWriter()
 
@@ -55,7 +70,7 @@ func (t *StatisticsCSVWriter) Write(
 	}
 
 	writer.WriteColsNL(
-		now.Format(utils.DisplayDateTimeMS),
+		correctedOn.Format(utils.DisplayDateTimeMS),
 		strconv.Itoa(zone),
 		class.String(),
 		strconv.Itoa(volume),
@@ -15,8 +15,11 @@ func TestStatisticsCSVWriter_Write(t *testing.T) {
 	wr.SpeedUnit = "mph"
 
 	defer wr.Close()
-
-	wr.Init("/tmp/stats-12-%s.csv", "serial", "name", utils.IP4{})
+	wr.CSVFacade.PathTemplate = "/tmp/stats-12-%s.csv"
+	wr.SensorIP = "127.0.0.1"
+	wr.SensorName = "name"
+	wr.SensorSerial = "serial"
+	wr.Init()
 
 	for n := range 10 {
 		utils.Debug.Panic(wr.Write(time.Now(), 1, port.OctLongTruck, n, 1, 2, 3, 4))
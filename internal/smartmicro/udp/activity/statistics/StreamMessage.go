@@ -0,0 +1,37 @@
+package statistics
+
+import (
+	"time"
+
+	"rvpro3/radarvision.com/internal/models/streammodel"
+	"rvpro3/radarvision.com/internal/smartmicro/port"
+	"rvpro3/radarvision.com/utils"
+)
+
+// StreamMessageOf returns the statistics as streamed to the clients
+// (generic.StreamDecoder)
+func StreamMessageOf(radarIP utils.IP4, now time.Time, bytes []byte) (any, error) {
+	stats := port.StatisticsReader{}
+	stats.Init(bytes)
+
+	if !stats.IsSupported() {
+		return nil, port.ErrUnsupportedVersion
+	}
+
+	noStatistics := int(stats.GetNofStatistics())
+	message := &streammodel.StatisticsMessage{
+		RadarMessage: streammodel.NewRadarMessage(streammodel.StatisticsType, radarIP, now),
+		Interval:     int(stats.GetIntervalTime()),
+		Statistics:   make([]streammodel.Statistic, 0, noStatistics),
+	}
+
+	for idx := 0; idx < noStatistics; idx++ {
+		message.Statistics = append(message.Statistics, streammodel.Statistic{
+			Zone:   int(stats.GetZone(idx)),
+			Class:  stats.GetObjectClass(idx).String(),
+			Mode:   stats.GetMode(idx).String(),
+			Output: int(stats.GetOutput(idx)),
+		})
+	}
+	return message, nil
+}
@@ -19,6 +19,8 @@ type CaptureMJPegCacheItem struct {
 	Buffer *bytes.Buffer
 }
 
+// CaptureMJPegCache keeps the last Capacity frames, the frames before a
+// trigger.  The items are pooled, an item popped is no longer valid
 type CaptureMJPegCache struct {
 	Cache    []*CaptureMJPegCacheItem
 	Capacity int
@@ -26,16 +28,19 @@ type CaptureMJPegCache struct {
 
 func (c *CaptureMJPegCache) Init(capacity int) {
 	c.Capacity = capacity
-	c.Cache = make([]*CaptureMJPegCacheItem, c.Capacity+1)
+	c.Cache = make([]*CaptureMJPegCacheItem, 0, c.Capacity+1)
 }
 
-func (c *CaptureMJPegCache) Push(buffer *bytes.Buffer) {
+// Push copies the frame, dropping the oldest when beyond Capacity
+func (c *CaptureMJPegCache) Push(now time.Time, buffer *bytes.Buffer) {
 	item := captureCache.Get().(*CaptureMJPegCacheItem)
+	item.Time = now
+	item.Buffer.Reset()
 	item.Buffer.Write(buffer.Bytes())
 
 	c.Cache = append(c.Cache, item)
 
-	if len(c.Cache) == cap(c.Cache) {
+	if len(c.Cache) > c.Capacity {
 		c.PopFront()
 	}
 }
@@ -43,8 +48,9 @@ func (c *CaptureMJPegCache) Push(buffer *bytes.Buffer) {
 func (c *CaptureMJPegCache) PopFront() {
 	if len(c.Cache) > 0 {
 		first := c.Cache[0]
-		captureCache.Put(first)
+		c.Cache[0] = nil
 		c.Cache = c.Cache[1:]
+		captureCache.Put(first)
 	}
 }
 
@@ -104,3 +104,8 @@ func (l *LogCSVActivity) Process(time time.Time, bytes []byte) {
 		l.Metrics.SkipDisabledCount.IncAt(1, time)
 	}
 }
+
+// Close closes the CSV file
+func (l *LogCSVActivity) Close() {
+	l.CSVWriter.Close()
+}
@@ -2,11 +2,13 @@ package trigger
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"io"
 	"mime"
 	"mime/multipart"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -40,6 +42,9 @@ type MJPegStreamService struct {
 	OnErrorCallback      func(service any, now time.Time, err error)            `json:"-"`
 	transport            http.Transport
 	ServiceName          string `json:"ServiceName"`
+	state                *utils.State
+	cancel               context.CancelFunc
+	lock                 sync.Mutex
 }
 
 type MJPegStreamServiceMetrics struct {
@@ -48,6 +53,9 @@ type MJPegStreamServiceMetrics struct {
 	FrameMinDuration       *utils.Metric
 	FrameMaxDuration       *utils.Metric
 	FrameTotalDuration     *utils.Metric
+	FrameRate              *utils.Metric
+	FrameDurationWindow    *utils.Metric
+	FrameDurationHistogram *utils.Metric
 	ErrorsTotal            *utils.Metric
 	ErrorsLogged           *utils.Metric
 	ErrorsOfHttpConnect    *utils.Metric
@@ -83,6 +91,7 @@ func (c *MJPegStreamService) Start(state *utils.State, settings *utils.Settings)
 		return
 	}
 
+	c.state = state
 	c.Metrics.InitMetrics(c.GetServiceName(), &c.Metrics)
 
 	if !c.Enabled || c.StreamURL == "" {
@@ -99,21 +108,47 @@ func (c *MJPegStreamService) Start(state *utils.State, settings *utils.Settings)
 		},
 	}
 
-	go c.run()
+	var ctx context.Context
+	ctx, c.cancel = context.WithCancel(context.Background())
+
+	go c.run(ctx)
+}
+
+// Stop terminates the stream, the frame read in progress is cancelled.  The
+// service is removed from the state, so a service of the same name can start
+func (c *MJPegStreamService) Stop() {
+	c.lock.Lock()
+	c.Terminate = true
+	cancel := c.cancel
+	c.lock.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+
+	if c.state != nil && c.state.Get(c.GetServiceName()) == c {
+		c.state.Delete(c.GetServiceName())
+	}
+}
+
+func (c *MJPegStreamService) isTerminate() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.Terminate
 }
 
 func (c *MJPegStreamService) GetServiceName() string {
 	return c.ServiceName
 }
 
-func (c *MJPegStreamService) run() {
+func (c *MJPegStreamService) run(ctx context.Context) {
 	var err error
 	var lastErr error
 	var errCount int
 	var now time.Time
 
-	for !c.Terminate {
-		err = c.stream()
+	for !c.isTerminate() {
+		err = c.stream(ctx)
 
 		if err != nil {
 			now = time.Now()
@@ -130,14 +165,19 @@ func (c *MJPegStreamService) run() {
 				c.OnErrorCallback(c.Metrics, now, err)
 			}
 
-			time.Sleep(c.ErrorDuration)
+			select {
+			case <-ctx.Done():
+			case <-time.After(c.ErrorDuration):
+			}
 		}
 	}
 
+	c.lock.Lock()
 	c.Terminated = true
+	c.lock.Unlock()
 }
 
-func (c *MJPegStreamService) stream() (err error) {
+func (c *MJPegStreamService) stream(ctx context.Context) (err error) {
 	var resp *http.Response
 	client := &http.Client{
 		Transport: &c.transport,
@@ -146,7 +186,13 @@ func (c *MJPegStreamService) stream() (err error) {
 
 	startConnect := time.Now()
 
-	resp, err = client.Get(c.StreamURL)
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(ctx, http.MethodGet, c.StreamURL, nil); err != nil {
+		c.Metrics.ErrorsOfHttpConnect.Inc(1)
+		return err
+	}
+
+	resp, err = client.Do(req)
 	if err != nil {
 		c.Metrics.ErrorsOfHttpConnect.Inc(1)
 		return err
@@ -175,7 +221,9 @@ func (c *MJPegStreamService) stream() (err error) {
 	c.Metrics.ConnectMinDuration.SetIfLessAt(connectTime, frameStart)
 	c.Metrics.ConnectMaxDuration.SetIfMoreAt(connectTime, frameStart)
 
-	for !c.Terminated {
+	for !c.isTerminate() {
+		buffer.Reset()
+
 		var part *multipart.Part
 		if part, err = reader.NextPart(); err != nil {
 			c.Metrics.ErrorsOfHttpMultipart.IncAt(1, frameStart)
@@ -199,6 +247,9 @@ func (c *MJPegStreamService) stream() (err error) {
 		c.Metrics.FrameMinDuration.SetIfLessAt(durationMs, frameEnd)
 		c.Metrics.FrameMaxDuration.SetIfMoreAt(durationMs, frameEnd)
 		c.Metrics.FrameTotalDuration.IncAt(durationMs, frameEnd)
+		c.Metrics.FrameRate.CountAt(1, frameEnd)
+		c.Metrics.FrameDurationWindow.ObserveAt(durationMs, frameEnd)
+		c.Metrics.FrameDurationHistogram.ObserveAt(durationMs, frameEnd)
 		c.Metrics.FrameReadBytes.IncAt(byteCount, frameEnd)
 		c.Metrics.FrameReadCount.IncAt(1, frameEnd)
 
@@ -11,20 +11,13 @@ import (
 
 type StageTriggerActivity struct {
 	interfaces.UDPActivityMixin
-	PipelineItem triggerpipeline.ITriggerPipelineItem
+	RadarState *state.RadarState `json:"-"`
 }
 
 func (s *StageTriggerActivity) Init(workflow interfaces.IUDPWorkflow, index int, fullName string) {
 	s.InitBase(workflow, index, fullName)
 
-	radarState := state.RadarStateHelper.GetOrSet(workflow.GetRadarIP())
-
-	if radarState != nil {
-		s.PipelineItem = radarState.Pipeline.Find(
-			triggerpipeline.Staging,
-			workflow.GetRadarIP(),
-		)
-	}
+	s.RadarState = state.RadarStateHelper.GetOrSet(workflow.GetRadarIP())
 }
 
 func (s *StageTriggerActivity) Process(time time.Time, bytes []byte) {
@@ -41,7 +34,8 @@ func (s *StageTriggerActivity) Process(time time.Time, bytes []byte) {
 		trigger := port.EventTriggerReader{}
 		trigger.Init(bytes)
 
-		// Staging pipeline is mandatory, so PipelineItem can never be nil
-		s.PipelineItem.SetTrigger(time, 0, trigger.GetRelays())
+		// Staging pipeline is mandatory, through the radar state as the
+		// pipeline executes (and is replaced) concurrently
+		s.RadarState.SetTrigger(triggerpipeline.Staging, time, 0, trigger.GetRelays())
 	}
 }
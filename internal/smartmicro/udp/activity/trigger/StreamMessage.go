@@ -0,0 +1,35 @@
+package trigger
+
+import (
+	"strconv"
+	"time"
+
+	"rvpro3/radarvision.com/internal/models/streammodel"
+	"rvpro3/radarvision.com/internal/smartmicro/port"
+	"rvpro3/radarvision.com/utils"
+)
+
+// StreamMessageOf returns the relays triggered (version 4.0) as streamed to
+// the clients (generic.StreamDecoder)
+func StreamMessageOf(radarIP utils.IP4, now time.Time, bytes []byte) (any, error) {
+	th := port.TransportHeaderReader{
+		Buffer: bytes,
+	}
+
+	ph := port.PortHeaderReader{
+		Buffer:      bytes,
+		StartOffset: int(th.GetHeaderLength()),
+	}
+
+	if ph.GetPortMajorVersion() != 4 || ph.GetPortMinorVersion() != 0 {
+		return nil, port.ErrUnsupportedVersion
+	}
+
+	trigger := port.EventTriggerReader{}
+	trigger.Init(bytes)
+
+	return &streammodel.TriggerMessage{
+		RadarMessage: streammodel.NewRadarMessage(streammodel.TriggerType, radarIP, now),
+		Relays:       strconv.FormatUint(trigger.GetRelays(), 16),
+	}, nil
+}
@@ -42,7 +42,7 @@ func (t *TriggerCSVWriter) Write(
 	}
 
 	writer.WriteColsNL(
-		now.Format(utils.DisplayDateTimeMS),
+		utils.Time.Correct(now).Format(utils.DisplayDateTimeMS),
 		strconv.Itoa(objectCount),
 		strconv.Itoa(relayCount),
 		fmt.Sprintf("0x%016x", relays),
@@ -17,6 +17,6 @@ func TestTriggerCSVWriter_Write(t *testing.T) {
 	wr.Init()
 
 	for n := range 10 {
-		utils.Debug.Panic(wr.Write(time.Now(), 1, 2, uint32(n), 1))
+		utils.Debug.Panic(wr.Write(time.Now(), 1, 2, uint64(n)))
 	}
 }
@@ -2,16 +2,35 @@ package trigger
 
 import (
 	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
+	"github.com/google/uuid"
+	"rvpro3/radarvision.com/internal/general"
 	"rvpro3/radarvision.com/internal/smartmicro/interfaces"
 	"rvpro3/radarvision.com/internal/smartmicro/port"
 	"rvpro3/radarvision.com/utils"
 	"rvpro3/radarvision.com/utils/bit"
 )
 
+const WrongWayMJPegStreamName = "WrongWay.MJPegStream"
+const wrongWayChannel = "wrongway.channel"
+const wrongWayStreamURL = "wrongway.stream.url"
+const wrongWayHeadPhotos = "wrongway.head.photos"
+const wrongWayRecordingDuration = "wrongway.recording.duration"
+const wrongWayCasePath = "wrongway.case.path"
+const wrongWayCasePathDefault = "/media/SDLOGS/wrongway/%d/cases"
+const wrongWayWriteQueue = "wrongway.write.queue"
+
 type wrongWayStatus int
 
+// A case starts on the trigger of the channel (wwsPending), writes the frames
+// cached before the trigger (wwsWriteCache), then writes the frames until the
+// trigger releases or MaxRecordingDuration (wwsWriteProgress).  A case closed
+// on MaxRecordingDuration waits for the trigger to release (wwsWriteTail)
 const (
 	wwsPending wrongWayStatus = iota
 	wwsWriteCache
@@ -19,20 +38,35 @@ const (
 	wwsWriteTail
 )
 
+// WrongWayActivity records the evidence of a wrong way case: the camera frames
+// before and while the channel is triggered are written in the case directory
+// (CasePath/CaseID), and the case is stored in the WrongWayStore.  The case
+// closed is queued (open) for dispatch.  The photos and the store are written
+// by the writer go routine, off the broker and the camera stream, in the order
+// queued.  A photo is dropped when the queue (WriteQueueSize) is full, the case
+// records wait for room
 type WrongWayActivity struct {
 	InCase               bool
 	CaseStartTime        time.Time
 	CaseStatus           wrongWayStatus
 	CasePath             string
 	CaseID               string
+	CaseDir              string
+	CasePhotoCount       int
 	ChannelSO            int
 	ChannelMask          uint64
 	ChannelMaskPrevious  uint64
 	StreamURL            string
 	MaxHeadPhotos        int
 	MaxRecordingDuration time.Duration
+	WriteQueueSize       int
 	jpegService          *MJPegStreamService
 	jpegCache            CaptureMJPegCache
+	store                *WrongWayStore
+	caseError            utils.ErrorLoggerMixin
+	lock                 sync.Mutex
+	writeChannel         chan func()
+	writerDone           chan bool
 	Metrics              WrongWayActivityMetrics `json:"-"`
 	interfaces.UDPActivityMixin
 }
@@ -40,6 +74,14 @@ type WrongWayActivity struct {
 type WrongWayActivityMetrics struct {
 	PortVersionError           *utils.Metric
 	StatePendingWithoutTrigger *utils.Metric
+	CaseCount                  *utils.Metric
+	CaseClosedOnRelease        *utils.Metric
+	CaseClosedOnDuration       *utils.Metric
+	PhotoCount                 *utils.Metric
+	PhotoBytes                 *utils.Metric
+	CaseErrors                 *utils.Metric
+	CameraErrors               *utils.Metric
+	PhotoDrops                 *utils.Metric
 	utils.MetricsInitMixin
 }
 
@@ -47,62 +89,289 @@ func (w *WrongWayActivity) Init(workflow interfaces.IUDPWorkflow, index int, met
 	w.InitBase(workflow, index, metricsName)
 	w.Metrics.InitMetrics(metricsName, &w.Metrics)
 
+	radarIP := w.Workflow.GetRadarIP()
+	ip := radarIP.String()
+	gs := &utils.GlobalSettings
+
+	w.ChannelSO = gs.Indexed.GetInt(wrongWayChannel, ip, w.ChannelSO)
+	w.StreamURL = gs.Indexed.Get(wrongWayStreamURL, ip, w.StreamURL)
+	w.MaxHeadPhotos = gs.Indexed.GetInt(wrongWayHeadPhotos, ip, 10)
+	w.MaxRecordingDuration = gs.Indexed.GetDurationMs(wrongWayRecordingDuration, ip, 10000)
+	w.CasePath = gs.Indexed.Get(wrongWayCasePath, ip, fmt.Sprintf(wrongWayCasePathDefault, radarIP.GetHost()))
+	w.WriteQueueSize = gs.Indexed.GetInt(wrongWayWriteQueue, ip, 64)
+
+	var err error
+	if w.store, err = WrongWayStoreHelper.GetOrOpen(gs); err != nil {
+		w.onCaseError(time.Now(), err)
+	}
+
+	w.jpegCache.Init(w.MaxHeadPhotos)
+	w.startWriter()
 	w.jpegService = &MJPegStreamService{
 		StreamURL:       w.StreamURL,
 		Enabled:         true,
+		ErrorDuration:   time.Second,
+		ErrorCountLog:   100,
 		OnFrameCallback: w.onFrameCallback,
 		OnErrorCallback: w.onErrorCallback,
+		ServiceName:     general.ServiceHelper.NameWithIP(WrongWayMJPegStreamName, radarIP),
 	}
-	w.jpegCache.Init(w.MaxHeadPhotos)
-	w.jpegService.Start(&utils.GlobalState, &utils.GlobalSettings)
+	w.jpegService.Start(&utils.GlobalState, gs)
 	w.ChannelMask = bit.Set(uint64(0), w.ChannelSO)
 }
 
 func (w *WrongWayActivity) Process(now time.Time, buffer []byte) {
-	th := port.TransportHeaderReader{Buffer: buffer}
-	ph := port.PortHeaderReader{Buffer: buffer, StartOffset: int(th.GetHeaderLength())}
+	trg := port.EventTriggerReader{}
+	trg.Init(buffer)
+
+	if !trg.IsSupported() {
+		w.Metrics.PortVersionError.IncAt(1, now)
+		return
+	}
 
-	if ph.GetPortMajorVersion() == 2 && ph.GetPortMinorVersion() == 0 {
-		trg := port.EventTriggerReader{}
-		trg.Init(buffer[:])
+	w.processRelays(now, trg.GetRelays())
+}
 
-		relays := trg.GetRelays()
-		currentMask := relays & w.ChannelMask
+// Close stops the camera stream, the case recording is closed (queued for
+// dispatch) and the writes queued are done.  The store is shared by the
+// radars and stays open
+func (w *WrongWayActivity) Close() {
+	if w.jpegService != nil {
+		w.jpegService.Stop()
+	}
 
-		switch w.CaseStatus {
-		case wwsPending:
-			w.handlePending(now, trg, currentMask)
+	w.lock.Lock()
+	if w.InCase {
+		w.closeCase(time.Now())
+	}
+	w.CaseStatus = wwsPending
+	writeChannel := w.writeChannel
+	w.writeChannel = nil
+	w.lock.Unlock()
 
-		case wwsWriteCache:
-			//w.handleWriteCache(trg, currentMask)
+	if writeChannel != nil {
+		close(writeChannel)
+		<-w.writerDone
+	}
+}
 
-		case wwsWriteProgress:
-			//w.handleProgress(trg, currentMask)
+func (w *WrongWayActivity) startWriter() {
+	if w.WriteQueueSize <= 0 {
+		w.WriteQueueSize = 64
+	}
 
-		case wwsWriteTail:
-			//w.handleWriteTail(trg, currentMask)
-		}
+	w.writeChannel = make(chan func(), w.WriteQueueSize)
+	w.writerDone = make(chan bool)
+	go w.executeWrites(w.writeChannel)
+}
 
-	} else {
-		w.Metrics.PortVersionError.IncAt(1, now)
+func (w *WrongWayActivity) executeWrites(writeChannel chan func()) {
+	for write := range writeChannel {
+		write()
+	}
+	close(w.writerDone)
+}
+
+// queueWrite queues the write for the writer, waiting for room unless
+// dropped.  It returns false when the write is dropped (the queue full, or
+// the activity closed).  The lock is held
+func (w *WrongWayActivity) queueWrite(write func(), isDropped bool) bool {
+	if w.writeChannel == nil {
+		return false
+	}
+
+	if !isDropped {
+		w.writeChannel <- write
+		return true
+	}
+
+	select {
+	case w.writeChannel <- write:
+		return true
+	default:
+		return false
+	}
+}
+
+func (w *WrongWayActivity) processRelays(now time.Time, relays uint64) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	currentMask := relays & w.ChannelMask
+
+	switch w.CaseStatus {
+	case wwsPending:
+		w.handlePending(now, relays, currentMask)
+
+	case wwsWriteCache:
+		w.handleWriteCache()
+
+	case wwsWriteProgress:
+		w.handleProgress(now, currentMask)
+
+	case wwsWriteTail:
+		w.handleWriteTail(currentMask)
+	}
+
+	w.ChannelMaskPrevious = currentMask
+}
+
+func (w *WrongWayActivity) handlePending(now time.Time, relays uint64, mask uint64) {
+	if mask == 0 {
+		w.Metrics.StatePendingWithoutTrigger.IncAt(1, now)
+		return
+	}
+	w.startTransaction(now, relays)
+
+	// The frames before the trigger are written straight away, the radar
+	// not sending the trigger again until changed
+	w.handleWriteCache()
+}
+
+// handleWriteCache writes the frames cached before the trigger as the head
+// photos of the case
+func (w *WrongWayActivity) handleWriteCache() {
+	for w.jpegCache.Depth() > 0 {
+		item := w.jpegCache.GetFront()
+		w.writePhoto(item.Time, WrongWayPhotoHead, item.Buffer)
+		w.jpegCache.PopFront()
 	}
+	w.CaseStatus = wwsWriteProgress
 }
 
-func (w *WrongWayActivity) handlePending(now time.Time, trg port.EventTriggerReader, mask uint64) {
+func (w *WrongWayActivity) handleProgress(now time.Time, mask uint64) {
 	if mask == 0 {
-		w.Metrics.StatePendingWithoutTrigger.IncAt(1, time.Now())
+		w.Metrics.CaseClosedOnRelease.IncAt(1, now)
+		w.closeCase(now)
+		w.CaseStatus = wwsPending
 		return
 	}
-	w.startTransaction(now, trg)
+
+	if w.isRecordingExpired(now) {
+		w.Metrics.CaseClosedOnDuration.IncAt(1, now)
+		w.closeCase(now)
+		w.CaseStatus = wwsWriteTail
+	}
+}
+
+// handleWriteTail waits for the trigger to release, a trigger longer than
+// MaxRecordingDuration is not a new case
+func (w *WrongWayActivity) handleWriteTail(mask uint64) {
+	if mask == 0 {
+		w.CaseStatus = wwsPending
+	}
+}
+
+func (w *WrongWayActivity) isRecordingExpired(now time.Time) bool {
+	return utils.Time.Correct(now).Sub(w.CaseStartTime) >= w.MaxRecordingDuration
 }
 
-func (w *WrongWayActivity) startTransaction(now time.Time, trg port.EventTriggerReader) {
+func (w *WrongWayActivity) startTransaction(now time.Time, relays uint64) {
+	// Case records use the cabinet (corrected) time
+	w.CaseStartTime = utils.Time.Correct(now)
 	w.CaseStatus = wwsWriteCache
+	w.CaseID = uuid.NewString()
+	w.CaseDir = filepath.Join(w.CasePath, w.CaseID)
+	w.CasePhotoCount = 0
+	w.InCase = true
+	w.Metrics.CaseCount.IncAt(1, now)
+
+	rec := &WrongWayAlertRec{
+		CaseID:  w.CaseID,
+		CaseDir: w.CaseDir,
+		StartOn: WrongWayDao.ToDateStr(w.CaseStartTime),
+		Status:  WrongWayStatusRecording,
+		Trigger: fmt.Sprintf("%#x", relays),
+	}
+
+	w.queueWrite(func() {
+		if err := os.MkdirAll(rec.CaseDir, 0755); err != nil {
+			w.onCaseError(now, err)
+		}
+		w.onCaseError(now, w.getStore().InsertAlert(rec))
+	}, false)
+}
+
+// closeCase ends the recording, queuing the case for dispatch
+func (w *WrongWayActivity) closeCase(now time.Time) {
+	w.InCase = false
+	caseID := w.CaseID
+
+	w.queueWrite(func() {
+		_, err := w.getStore().QueueCase(caseID)
+		w.onCaseError(now, err)
+	}, false)
 }
 
-func (w *WrongWayActivity) onFrameCallback(service any, now time.Time, buffer *bytes.Buffer) {
+// writePhoto queues the frame, written in the case directory and stored.  The
+// frame is copied, the buffer being reused
+func (w *WrongWayActivity) writePhoto(captureOn time.Time, photoType string, buffer *bytes.Buffer) {
+	w.CasePhotoCount++
+	photoPath := filepath.Join(w.CaseDir, fmt.Sprintf("%03d-%s.jpg", w.CasePhotoCount, photoType))
+	rec := &WrongWayPhotoRec{
+		CaseID:    w.CaseID,
+		CaptureOn: WrongWayDao.ToDateStr(utils.Time.Correct(captureOn)),
+		PhotoType: photoType,
+		PhotoPath: photoPath,
+	}
+	data := bytes.Clone(buffer.Bytes())
+
+	isQueued := w.queueWrite(func() {
+		if err := os.WriteFile(photoPath, data, 0644); err != nil {
+			w.onCaseError(captureOn, err)
+			return
+		}
+
+		w.Metrics.PhotoCount.IncAt(1, captureOn)
+		w.Metrics.PhotoBytes.IncAt(int64(len(data)), captureOn)
+		w.onCaseError(captureOn, w.getStore().InsertPhoto(rec))
+	}, true)
+
+	if !isQueued {
+		w.Metrics.PhotoDrops.IncAt(1, captureOn)
+	}
+}
+
+// onFrameCallback caches the frames until triggered, and writes the frames of
+// the case.  The frames arrive on the stream go routine
+func (w *WrongWayActivity) onFrameCallback(_ any, now time.Time, buffer *bytes.Buffer) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.CaseStatus != wwsWriteProgress {
+		w.jpegCache.Push(now, buffer)
+		return
+	}
+
+	// The radar only sends the trigger when changed, the duration is
+	// checked on the frames as well
+	if w.isRecordingExpired(now) {
+		w.Metrics.CaseClosedOnDuration.IncAt(1, now)
+		w.closeCase(now)
+		w.CaseStatus = wwsWriteTail
+		w.jpegCache.Push(now, buffer)
+		return
+	}
+
+	w.writePhoto(now, WrongWayPhotoCase, buffer)
+}
 
+func (w *WrongWayActivity) onErrorCallback(_ any, now time.Time, _ error) {
+	w.Metrics.CameraErrors.IncAt(1, now)
 }
 
-func (w *WrongWayActivity) onErrorCallback(service any, now time.Time, err error) {
+func (w *WrongWayActivity) onCaseError(now time.Time, err error) {
+	if err != nil {
+		w.Metrics.CaseErrors.IncAt(1, now)
+	}
+	w.caseError.LogErrorAt(now, "WrongWayActivity.case", err)
+}
+
+// getStore returns a closed store when the store failed to open, the case
+// photos are written regardless
+func (w *WrongWayActivity) getStore() *WrongWayStore {
+	if w.store == nil {
+		w.store = &WrongWayStore{}
+	}
+	return w.store
 }
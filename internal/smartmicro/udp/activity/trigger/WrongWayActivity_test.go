@@ -0,0 +1,207 @@
+package trigger
+
+import (
+	"bytes"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/utils"
+	"rvpro3/radarvision.com/utils/bit"
+)
+
+func newTestWrongWay(t *testing.T) (*WrongWayActivity, *[]string) {
+	queued := new([]string)
+	store := &WrongWayStore{
+		FileName: filepath.Join(t.TempDir(), "wrongway.db"),
+		OnCaseQueued: func(rec *WrongWayAlertRec) {
+			*queued = append(*queued, rec.CaseID)
+		},
+	}
+	assert.NoError(t, store.Open())
+	t.Cleanup(func() { _ = store.Close() })
+
+	res := &WrongWayActivity{
+		CasePath:             t.TempDir(),
+		ChannelMask:          bit.Set(uint64(0), 2),
+		MaxRecordingDuration: 5 * time.Second,
+		store:                store,
+	}
+	res.Metrics.InitMetrics("Test.WrongWay."+t.Name(), &res.Metrics)
+	res.jpegCache.Init(3)
+	res.startWriter()
+	t.Cleanup(res.Close)
+	return res, queued
+}
+
+// awaitWrites waits for the writer to do the writes queued
+func awaitWrites(w *WrongWayActivity) {
+	done := make(chan bool)
+
+	w.lock.Lock()
+	w.queueWrite(func() { close(done) }, false)
+	w.lock.Unlock()
+	<-done
+}
+
+func pushFrames(w *WrongWayActivity, start time.Time, count int) {
+	for i := 0; i < count; i++ {
+		w.onFrameCallback(nil, start.Add(time.Duration(i)*200*time.Millisecond), bytes.NewBufferString("frame"))
+	}
+}
+
+func selectPhotos(t *testing.T, w *WrongWayActivity, caseID string) (photos []*WrongWayPhotoRec) {
+	awaitWrites(w)
+	assert.NoError(t, w.store.Exec(func(db *sql.DB) (err error) {
+		photos, err = WrongWayDao.SelectPhotoList(db, caseID)
+		return err
+	}))
+	return photos
+}
+
+func selectAlert(t *testing.T, w *WrongWayActivity, caseID string) (rec *WrongWayAlertRec) {
+	awaitWrites(w)
+	assert.NoError(t, w.store.Exec(func(db *sql.DB) (err error) {
+		rec, err = WrongWayDao.SelectWrongWay(db, caseID)
+		return err
+	}))
+	return rec
+}
+
+func TestWrongWayActivity_CaseOnRelease(t *testing.T) {
+	w, queued := newTestWrongWay(t)
+	now := time.Now()
+
+	// Other channels do not start a case, the frames are cached
+	pushFrames(w, now, 5)
+	w.processRelays(now.Add(time.Second), bit.Set(uint64(0), 1))
+	assert.Equal(t, wwsPending, w.CaseStatus)
+	assert.Equal(t, 3, w.jpegCache.Depth())
+
+	w.processRelays(now.Add(time.Second), bit.Set(uint64(0), 2))
+	assert.Equal(t, wwsWriteProgress, w.CaseStatus)
+	assert.True(t, w.InCase)
+	assert.Equal(t, 0, w.jpegCache.Depth(), "the cache is written as the head photos")
+
+	caseID := w.CaseID
+	if rec := selectAlert(t, w, caseID); assert.NotNil(t, rec) {
+		assert.Equal(t, WrongWayStatusRecording, rec.Status)
+		assert.Equal(t, filepath.Join(w.CasePath, caseID), rec.CaseDir)
+		assert.Equal(t, "0x4", rec.Trigger)
+	}
+
+	pushFrames(w, now.Add(2*time.Second), 2)
+	w.processRelays(now.Add(3*time.Second), 0)
+	assert.Equal(t, wwsPending, w.CaseStatus)
+	assert.False(t, w.InCase)
+	awaitWrites(w)
+	assert.Equal(t, []string{caseID}, *queued)
+
+	if rec := selectAlert(t, w, caseID); assert.NotNil(t, rec) {
+		assert.Equal(t, WrongWayStatusOpen, rec.Status)
+	}
+
+	photos := selectPhotos(t, w, caseID)
+	if assert.Len(t, photos, 5) {
+		for index, photoType := range []string{"head", "head", "head", "case", "case"} {
+			assert.Equal(t, photoType, photos[index].PhotoType)
+
+			data, err := os.ReadFile(photos[index].PhotoPath)
+			assert.NoError(t, err)
+			assert.Equal(t, "frame", string(data))
+		}
+		assert.Equal(t, WrongWayDao.ToDateStr(utils.Time.Correct(now.Add(400*time.Millisecond))), photos[0].CaptureOn)
+	}
+
+	// The frames after the case are cached for the next case
+	pushFrames(w, now.Add(4*time.Second), 1)
+	assert.Len(t, selectPhotos(t, w, caseID), 5)
+	assert.Equal(t, 1, w.jpegCache.Depth())
+}
+
+func TestWrongWayActivity_CaseOnDuration(t *testing.T) {
+	w, queued := newTestWrongWay(t)
+	now := time.Now()
+	trigger := bit.Set(uint64(0), 2)
+
+	w.processRelays(now, trigger)
+	caseID := w.CaseID
+	pushFrames(w, now.Add(time.Second), 2)
+
+	// The duration is checked on the frames, the radar not repeating the trigger
+	pushFrames(w, now.Add(6*time.Second), 1)
+	assert.Equal(t, wwsWriteTail, w.CaseStatus)
+	awaitWrites(w)
+	assert.Equal(t, []string{caseID}, *queued)
+	assert.Len(t, selectPhotos(t, w, caseID), 2)
+
+	// The trigger held is not a new case
+	w.processRelays(now.Add(7*time.Second), trigger)
+	assert.Equal(t, wwsWriteTail, w.CaseStatus)
+	assert.Equal(t, caseID, w.CaseID)
+
+	w.processRelays(now.Add(8*time.Second), 0)
+	assert.Equal(t, wwsPending, w.CaseStatus)
+
+	w.processRelays(now.Add(9*time.Second), trigger)
+	assert.NotEqual(t, caseID, w.CaseID)
+	assert.Len(t, selectPhotos(t, w, w.CaseID), 1, "the frame closing the previous case is a head photo")
+
+	w.processRelays(now.Add(20*time.Second), trigger)
+	assert.Equal(t, wwsWriteTail, w.CaseStatus)
+	awaitWrites(w)
+	assert.Len(t, *queued, 2)
+}
+
+func TestWrongWayActivity_Close(t *testing.T) {
+	w, queued := newTestWrongWay(t)
+
+	state := &utils.State{}
+	state.Init()
+	w.jpegService = &MJPegStreamService{ServiceName: "Test.WrongWay.MJPegStream"}
+	w.jpegService.Start(state, &utils.Settings{})
+	assert.True(t, state.Has("Test.WrongWay.MJPegStream"))
+
+	w.processRelays(time.Now(), bit.Set(uint64(0), 2))
+	caseID := w.CaseID
+	assert.True(t, w.InCase)
+
+	w.Close()
+	assert.False(t, w.InCase)
+	assert.Equal(t, wwsPending, w.CaseStatus)
+	assert.Equal(t, []string{caseID}, *queued, "the case recording is queued")
+	assert.False(t, state.Has("Test.WrongWay.MJPegStream"), "the stream of the reconfigured activity starts again")
+
+	// The frames arriving after the close are not written
+	w.CaseStatus = wwsWriteProgress
+	pushFrames(w, time.Now(), 1)
+	assert.Equal(t, int64(1), w.Metrics.PhotoDrops.Value)
+}
+
+func TestWrongWayActivity_PhotoDrops(t *testing.T) {
+	w, _ := newTestWrongWay(t)
+	w.Close()
+	w.WriteQueueSize = 2
+	w.startWriter()
+
+	// The writer is held up, the photos beyond the queue are dropped
+	started := make(chan bool)
+	blocked := make(chan bool)
+	w.lock.Lock()
+	w.queueWrite(func() {
+		close(started)
+		<-blocked
+	}, false)
+	w.lock.Unlock()
+	<-started
+
+	w.processRelays(time.Now(), bit.Set(uint64(0), 2))
+	pushFrames(w, time.Now(), 3)
+	assert.Equal(t, int64(2), w.Metrics.PhotoDrops.Value)
+
+	close(blocked)
+	assert.Len(t, selectPhotos(t, w, w.CaseID), 1)
+}
@@ -22,7 +22,7 @@ func (wrongDayDao) DropTables(db *sql.DB) (err error) {
 		return err
 	}
 
-	if _, err = db.Exec(`DROP INDEX IF EXISTS wrongway_ndx`); err != nil {
+	if _, err = db.Exec(`DROP INDEX IF EXISTS wrongway_alert_ndx`); err != nil {
 		return err
 	}
 
@@ -140,11 +140,11 @@ func (wrongDayDao) ToDateStr(date time.Time) string {
 }
 
 func (wrongDayDao) FromDateStr(date string) (time.Time, error) {
-	return time.Parse(date, time.RFC3339Nano)
+	return time.Parse(time.RFC3339Nano, date)
 }
 
 func (wrongDayDao) UpdateWrongWay(db *sql.DB, caseID string, status string) error {
-	s := `UPDATE wrongway set status=? where case_id=?`
+	s := `UPDATE wrongway_alert SET status=? WHERE case_id=?`
 	_, err := db.Exec(s, status, caseID)
 	return err
 }
@@ -183,7 +183,7 @@ VALUES
 
 func (wrongDayDao) SelectWrongWay(db *sql.DB, caseID string) (rec *WrongWayAlertRec, err error) {
 	var rows *sql.Rows
-	rows, err = db.Query(`SELECT id, case_id, case_dir, start_on, status, trigger FROM wrongway WHERE case_id=?`, caseID)
+	rows, err = db.Query(`SELECT id, case_id, case_dir, start_on, status, trigger FROM wrongway_alert WHERE case_id=?`, caseID)
 
 	if err != nil {
 		return nil, err
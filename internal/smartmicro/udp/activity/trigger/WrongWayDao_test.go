@@ -2,6 +2,7 @@ package trigger
 
 import (
 	"database/sql"
+	"path/filepath"
 	"strconv"
 	"testing"
 	"time"
@@ -13,7 +14,7 @@ import (
 )
 
 func TestWrongWayPhoto(t *testing.T) {
-	db := getDB()
+	db := getDB(t)
 	defer db.Close()
 
 	now := time.Now()
@@ -41,7 +42,7 @@ func TestWrongWayPhoto(t *testing.T) {
 }
 
 func TestWrongWayAlerts(t *testing.T) {
-	db := getDB()
+	db := getDB(t)
 	defer db.Close()
 
 	now := time.Now()
@@ -73,8 +74,8 @@ func TestWrongWayAlerts(t *testing.T) {
 	}
 }
 
-func getDB() *sql.DB {
-	db, err := sql.Open("sqlite", "test.db")
+func getDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
 	utils.Debug.Panic(err)
 
 	utils.Debug.Panic(WrongWayDao.CreateTables(db))
@@ -0,0 +1,129 @@
+package trigger
+
+import (
+	"database/sql"
+	"sync"
+
+	"rvpro3/radarvision.com/internal/services/sqlitestore"
+	"rvpro3/radarvision.com/utils"
+)
+
+const WrongWayStoreStateName = "WrongWay.Store"
+const wrongWayStoreFile = "wrongway.store.file"
+
+// The status of a wrongway_alert, a case is recording until closed, then
+// open until dispatched (or failed, the dispatch retries exhausted)
+const (
+	WrongWayStatusRecording  = "recording"
+	WrongWayStatusOpen       = "open"
+	WrongWayStatusDispatched = "dispatched"
+	WrongWayStatusFailed     = "failed"
+)
+
+// The photo_type of a wrongway_photo, head photos are taken before the
+// trigger (the cache), case photos while triggered
+const (
+	WrongWayPhotoHead = "head"
+	WrongWayPhotoCase = "case"
+)
+
+// WrongWayStore is the database of the wrong way cases, shared by the wrong
+// way activities of the radars.  OnCaseQueued is called once a case is
+// closed and open for dispatch
+type WrongWayStore struct {
+	FileName     string
+	Metrics      WrongWayStoreMetrics        `json:"-"`
+	OnCaseQueued func(rec *WrongWayAlertRec) `json:"-"`
+	store        sqlitestore.Store
+	initOnce     sync.Once
+}
+
+type WrongWayStoreMetrics struct {
+	AlertCount  *utils.Metric
+	PhotoCount  *utils.Metric
+	QueuedCount *utils.Metric
+	ErrCount    *utils.Metric
+	utils.MetricsInitMixin
+}
+
+// Open opens (and creates) the database, an open store is left as is
+func (s *WrongWayStore) Open() error {
+	s.initOnce.Do(func() {
+		s.Metrics.InitMetrics(WrongWayStoreStateName, &s.Metrics)
+		s.store.FileName = s.FileName
+		s.store.CreateTables = WrongWayDao.CreateTables
+	})
+	return s.store.Open()
+}
+
+func (s *WrongWayStore) Close() error {
+	return s.store.Close()
+}
+
+// Exec runs the callback with the database, serialised with the other
+// operations of the store
+func (s *WrongWayStore) Exec(callback func(db *sql.DB) error) error {
+	err := s.store.Exec(callback)
+
+	if err != nil && err != sqlitestore.ErrNotOpen {
+		s.Metrics.ErrCount.Inc(1)
+	}
+	return err
+}
+
+func (s *WrongWayStore) InsertAlert(rec *WrongWayAlertRec) error {
+	return s.Exec(func(db *sql.DB) error {
+		if err := WrongWayDao.InsertWrongWay(db, rec); err != nil {
+			return err
+		}
+		s.Metrics.AlertCount.Inc(1)
+		return nil
+	})
+}
+
+func (s *WrongWayStore) InsertPhoto(rec *WrongWayPhotoRec) error {
+	return s.Exec(func(db *sql.DB) error {
+		if err := WrongWayDao.InsertPhoto(db, rec); err != nil {
+			return err
+		}
+		s.Metrics.PhotoCount.Inc(1)
+		return nil
+	})
+}
+
+// QueueCase closes the recording of the case, opening it for dispatch
+func (s *WrongWayStore) QueueCase(caseID string) (rec *WrongWayAlertRec, err error) {
+	err = s.Exec(func(db *sql.DB) error {
+		if err := WrongWayDao.UpdateWrongWay(db, caseID, WrongWayStatusOpen); err != nil {
+			return err
+		}
+
+		var err error
+		rec, err = WrongWayDao.SelectWrongWay(db, caseID)
+		return err
+	})
+
+	if err != nil || rec == nil {
+		return rec, err
+	}
+
+	s.Metrics.QueuedCount.Inc(1)
+	if s.OnCaseQueued != nil {
+		s.OnCaseQueued(rec)
+	}
+	return rec, nil
+}
+
+type wrongWayStoreHelper struct{}
+
+var WrongWayStoreHelper wrongWayStoreHelper
+
+// GetOrOpen returns the store of the GlobalState, opening it on first use
+func (wrongWayStoreHelper) GetOrOpen(settings *utils.Settings) (*WrongWayStore, error) {
+	res := &WrongWayStore{
+		FileName: settings.Basic.Get(wrongWayStoreFile, "/media/SDLOGS/wrongway/wrongway.db"),
+	}
+
+	res = utils.GlobalState.GetOrSet(WrongWayStoreStateName, res).(*WrongWayStore)
+	return res, res.Open()
+}
@@ -0,0 +1,192 @@
+package broker
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"rvpro3/radarvision.com/internal/constants"
+	"rvpro3/radarvision.com/internal/general"
+	"rvpro3/radarvision.com/internal/models/servicemodel"
+	"rvpro3/radarvision.com/utils"
+)
+
+const configReloadFile = "config.reload.file"
+const configReloadWatchEvery = "config.reload.watch.every"
+const configReloadSaveEnabled = "config.reload.save.enabled"
+
+// ConfigReloadService watches the configuration file, and applies the
+// changed configuration to the UDPBrokersService without a restart.  It is
+// also the reloader of the web api, saving the configuration applied
+type ConfigReloadService struct {
+	FileName      string
+	WatchEvery    utils.Milliseconds
+	IsSaveEnabled bool
+	Terminate     bool
+	Terminated    bool
+	Brokers       *UDPBrokersService  `json:"-"`
+	Metrics       ConfigReloadMetrics `json:"-"`
+	LastDiffs     []servicemodel.RadarDiff
+	modifiedOn    time.Time
+	lock          sync.Mutex
+}
+
+type ConfigReloadMetrics struct {
+	WatchCount    *utils.Metric
+	ReloadCount   *utils.Metric
+	SaveCount     *utils.Metric
+	ErrCount      *utils.Metric
+	UnchangedSkip *utils.Metric
+	utils.MetricsInitMixin
+}
+
+func (s *ConfigReloadService) InitFromSettings(settings *utils.Settings) {
+	s.FileName = settings.Basic.Get(configReloadFile, settings.Basic.Get("startup.cfg.file", ""))
+	s.WatchEvery = settings.Basic.GetMilliseconds(configReloadWatchEvery, 2000)
+	s.IsSaveEnabled = settings.Basic.GetBool(configReloadSaveEnabled, true)
+	s.Metrics.InitMetrics(constants.ConfigReloadServiceName, &s.Metrics)
+}
+
+func (s *ConfigReloadService) Start(state *utils.State, settings *utils.Settings) {
+	if !general.ServiceHelper.ShouldStart(state, settings, s) {
+		return
+	}
+
+	var ok bool
+	if s.Brokers, ok = state.Get(UDPBrokersServiceName).(*UDPBrokersService); !ok {
+		log.Warn().Msg("Config reload not started due to no UDP brokers service...")
+		return
+	}
+
+	if s.IsWatched() {
+		s.modifiedOn = s.getModifiedOn()
+		go s.run()
+	}
+}
+
+func (s *ConfigReloadService) GetServiceName() string {
+	return constants.ConfigReloadServiceName
+}
+
+// IsWatched returns false for the built-in (test, debug and default)
+// configurations
+func (s *ConfigReloadService) IsWatched() bool {
+	return s.FileName != "" && !strings.EqualFold(s.FileName, "test") && !strings.EqualFold(s.FileName, "debug")
+}
+
+func (s *ConfigReloadService) run() {
+	for !s.Terminate {
+		s.WatchEvery.Sleep()
+
+		if _, err := s.Watch(); err != nil {
+			log.Err(err).Str("file", s.FileName).Msg("ConfigReloadService.run")
+		}
+	}
+	s.Terminated = true
+}
+
+// Watch reloads the configuration file when modified since the previous
+// load, returning true when reloaded.  A file failing to load or validate
+// leaves the running configuration as is, and is retried once modified again
+func (s *ConfigReloadService) Watch() (bool, error) {
+	s.Metrics.WatchCount.Inc(1)
+
+	modifiedOn := s.getModifiedOn()
+	if modifiedOn.IsZero() || modifiedOn.Equal(s.getLastModifiedOn()) {
+		return false, nil
+	}
+	s.setLastModifiedOn(modifiedOn)
+
+	serviceCfg, err := servicemodel.LoadConfig(s.FileName)
+	if err != nil {
+		s.Metrics.ErrCount.Inc(1)
+		return false, err
+	}
+
+	if _, err = s.reload(serviceCfg, false); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Reload applies the configuration, and saves it to the file when
+// IsSaveEnabled.  The file is only saved once applied
+func (s *ConfigReloadService) Reload(serviceCfg *servicemodel.Config) ([]servicemodel.RadarDiff, error) {
+	return s.reload(serviceCfg, s.IsSaveEnabled && s.IsWatched())
+}
+
+func (s *ConfigReloadService) reload(serviceCfg *servicemodel.Config, isSaved bool) ([]servicemodel.RadarDiff, error) {
+	if s.Brokers == nil {
+		return nil, errors.New("no UDP brokers service")
+	}
+
+	res, err := s.Brokers.Reload(serviceCfg)
+	if err != nil {
+		s.Metrics.ErrCount.Inc(1)
+		return nil, err
+	}
+
+	s.lock.Lock()
+	s.LastDiffs = res
+	s.lock.Unlock()
+
+	if !serviceCfg.IsChanged(res) {
+		s.Metrics.UnchangedSkip.Inc(1)
+		return res, nil
+	}
+	s.Metrics.ReloadCount.Inc(1)
+
+	if isSaved {
+		if err = s.save(serviceCfg); err != nil {
+			s.Metrics.ErrCount.Inc(1)
+			return res, errors.Wrap(err, "config applied but not saved")
+		}
+	}
+	return res, nil
+}
+
+// save writes the file (through a temporary file), the watcher skips the
+// modification
+func (s *ConfigReloadService) save(serviceCfg *servicemodel.Config) error {
+	data, err := json.MarshalIndent(serviceCfg, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tempFileName := s.FileName + ".tmp"
+	if err = os.WriteFile(tempFileName, data, 0644); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tempFileName, s.FileName); err != nil {
+		return err
+	}
+
+	s.setLastModifiedOn(s.getModifiedOn())
+	s.Metrics.SaveCount.Inc(1)
+	return nil
+}
+
+func (s *ConfigReloadService) getModifiedOn() time.Time {
+	info, err := os.Stat(s.FileName)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+func (s *ConfigReloadService) getLastModifiedOn() time.Time {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.modifiedOn
+}
+
+func (s *ConfigReloadService) setLastModifiedOn(modifiedOn time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.modifiedOn = modifiedOn
+}
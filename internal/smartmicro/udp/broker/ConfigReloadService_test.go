@@ -0,0 +1,247 @@
+package broker
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/internal/models/servicemodel"
+	"rvpro3/radarvision.com/internal/smartmicro/port"
+	"rvpro3/radarvision.com/internal/smartmicro/triggerpipeline"
+	"rvpro3/radarvision.com/internal/smartmicro/udp/activity/trigger"
+	"rvpro3/radarvision.com/internal/smartmicro/udp/state"
+	"rvpro3/radarvision.com/utils"
+)
+
+func newReloadConfig() *servicemodel.Config {
+	res := &servicemodel.Config{
+		SiteName:     "Reload Test",
+		DistanceUnit: "m",
+		SpeedUnit:    "mps",
+		Radars: []*servicemodel.Radar{
+			{
+				RadarIP:      "127.0.0.2:50001",
+				RadarName:    "Reload Sensor 1",
+				FailSafeTime: "30",
+				Channels:     []servicemodel.Channel{{Channel: 1, Phase: 2, FailSafe: "set"}},
+			},
+			{
+				RadarIP:      "127.0.0.2:50002",
+				RadarName:    "Reload Sensor 2",
+				FailSafeTime: "30",
+				Channels:     []servicemodel.Channel{{Channel: 2, Phase: 4, FailSafe: "set"}},
+			},
+		},
+	}
+	res.Normalize()
+	return res
+}
+
+func startReloadBrokers(t *testing.T) *UDPBrokersService {
+	utils.GlobalState.Set(servicemodel.StateName, newReloadConfig())
+
+	state := &utils.State{}
+	state.Init()
+
+	settings := &utils.Settings{}
+	settings.Init()
+
+	brokers := new(UDPBrokersService)
+	brokers.InitFromSettings(settings)
+	brokers.StartReplay(state, settings)
+	t.Cleanup(brokers.Stop)
+	return brokers
+}
+
+func TestUDPBrokersService_Reload(t *testing.T) {
+	brokers := startReloadBrokers(t)
+	now := time.Now()
+
+	first := brokers.Brokers[0]
+	second := brokers.Brokers[1]
+	staging := first.RadarState.Pipeline.Find(triggerpipeline.Staging, first.GetRadarIP())
+	staging.SetTrigger(now, 0, 1)
+	first.RadarState.FailSafe.SetUpdateOn(now)
+	second.RadarState.FailSafe.SetUpdateOn(now)
+	assert.Equal(t, utils.Uint128{Lo: 1}, brokers.ExecutePipelines(now, nil))
+
+	// The first radar gets an extend, the second is removed and a third added
+	cfg := newReloadConfig()
+	cfg.Radars[0].Channels[0].Extend = "2"
+	cfg.Radars[1] = &servicemodel.Radar{RadarIP: "127.0.0.2:50003", RadarName: "Reload Sensor 3"}
+	cfg.Normalize()
+
+	diffs, err := brokers.Reload(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, []servicemodel.RadarChange{
+		servicemodel.RadarChanged,
+		servicemodel.RadarAdded,
+		servicemodel.RadarRemoved,
+	}, []servicemodel.RadarChange{diffs[0].Change, diffs[1].Change, diffs[2].Change})
+
+	assert.Equal(t, 2, len(brokers.Brokers))
+	assert.Same(t, first, brokers.Brokers[0])
+	assert.Equal(t, "127.0.0.2:50003", brokers.Brokers[1].GetRadarIP().String())
+	assert.Same(t, cfg, utils.GlobalState.Get(servicemodel.StateName))
+	assert.True(t, second.terminated)
+	assert.Nil(t, brokers.getBroker(second.GetRadarIP()))
+	assert.Same(t, brokers.Brokers[1], brokers.getBroker(brokers.Brokers[1].GetRadarIP()))
+
+	// The call and failsafe timer carry over to the rebuilt pipeline
+	assert.NotNil(t, first.RadarState.Pipeline.Find(triggerpipeline.Extend, first.GetRadarIP()))
+	assert.NotSame(t, staging, first.RadarState.Pipeline.Find(triggerpipeline.Staging, first.GetRadarIP()))
+	assert.Equal(t, utils.Uint128{Lo: 1}, first.RadarState.Execute(now.Add(time.Second), nil))
+	assert.False(t, first.RadarState.IsAutoFailSafe)
+
+	// The workflows trigger the rebuilt staging item
+	var stageActivity *trigger.StageTriggerActivity
+	for _, activity := range first.Executor.GetWorkflow(port.PiEventTrigger).(*Workflow).Activities {
+		if stage, ok := activity.(*trigger.StageTriggerActivity); ok {
+			stageActivity = stage
+		}
+	}
+	assert.Same(t, first.RadarState, stageActivity.RadarState)
+	assert.True(t, stageActivity.RadarState.SetTrigger(triggerpipeline.Staging, now.Add(time.Second), 0, 2))
+	assert.Equal(t, uint64(2), first.RadarState.Pipeline.Find(triggerpipeline.Staging, first.GetRadarIP()).GetTrigger().Lo)
+
+	assert.Equal(t, int64(1), brokers.Metrics.ReloadCount.Value)
+	assert.Equal(t, int64(1), brokers.Metrics.RadarAddedCount.Value)
+	assert.Equal(t, int64(1), brokers.Metrics.RadarRemovedCount.Value)
+	assert.Equal(t, int64(1), brokers.Metrics.RadarChangedCount.Value)
+}
+
+func TestUDPBrokersService_ApplyFailedKeepsBrokers(t *testing.T) {
+	brokers := startReloadBrokers(t)
+	brokers.settings.Indexed.SetDefault("radar.pipeline.recorder.enabled", "true")
+	brokers.settings.Indexed.SetDefault("radar.pipeline.recorder.pathtemplate", filepath.Join(t.TempDir(), "pipeline-%s.jsonl"))
+
+	previous := utils.GlobalState.Get(servicemodel.StateName).(*servicemodel.Config)
+	previousBrokers := append([]*UDPBroker(nil), brokers.Brokers...)
+	first := brokers.Brokers[0]
+	staging := first.RadarState.Pipeline.Find(triggerpipeline.Staging, first.GetRadarIP())
+	changedCount := brokers.Metrics.RadarChangedCount.Value
+
+	added := &servicemodel.Radar{RadarIP: "127.0.0.2:50004", RadarName: "Reload Sensor 4"}
+	cfg := newReloadConfig()
+	cfg.Radars[0].RadarName = "Reload Sensor 1 Renamed"
+	cfg.Radars = append(cfg.Radars, added, &servicemodel.Radar{RadarIP: "127.0.0.2:50005"})
+	cfg.Normalize()
+
+	// The radar 50005 has no broker, the radar 50001 is not reconfigured and
+	// the broker built for 50004 is discarded
+	err := brokers.apply(previous, cfg, []servicemodel.RadarDiff{
+		{Change: servicemodel.RadarChanged, Previous: previous.Radars[0], Current: cfg.Radars[0]},
+		{Change: servicemodel.RadarAdded, Current: added},
+	})
+	assert.ErrorContains(t, err, "no broker for radar")
+	assert.Equal(t, previousBrokers, brokers.Brokers)
+	assert.Same(t, previous, utils.GlobalState.Get(servicemodel.StateName))
+	assert.Same(t, staging, first.RadarState.Pipeline.Find(triggerpipeline.Staging, first.GetRadarIP()))
+	assert.Equal(t, "Reload Sensor 1", first.RadarState.Name)
+	assert.Nil(t, brokers.getBroker(added.GetRadarIP()))
+	assert.Nil(t, state.RadarStateHelper.GetOrSet(added.GetRadarIP()).DetachRecorder(), "the pipeline recorder is closed")
+	assert.Equal(t, changedCount, brokers.Metrics.RadarChangedCount.Value)
+}
+
+func TestUDPBrokersService_ApplyPanicKeepsBrokers(t *testing.T) {
+	brokers := startReloadBrokers(t)
+	previous := utils.GlobalState.Get(servicemodel.StateName).(*servicemodel.Config)
+	previousBrokers := append([]*UDPBroker(nil), brokers.Brokers...)
+	removedCount := brokers.Metrics.RadarRemovedCount.Value
+
+	added := &servicemodel.Radar{RadarIP: "127.0.0.2:50006", RadarName: "Reload Sensor 6"}
+	cfg := newReloadConfig()
+	cfg.Radars = append(cfg.Radars[:1], added)
+	cfg.Normalize()
+
+	// The broker of the radar without a configuration panics, the removed
+	// radar keeps running
+	err := brokers.apply(previous, cfg, []servicemodel.RadarDiff{
+		{Change: servicemodel.RadarAdded, Current: added},
+		{Change: servicemodel.RadarAdded},
+		{Change: servicemodel.RadarRemoved, Previous: previous.Radars[1]},
+	})
+	assert.Error(t, err)
+	assert.Equal(t, previousBrokers, brokers.Brokers)
+	assert.Same(t, previous, utils.GlobalState.Get(servicemodel.StateName))
+	assert.Same(t, previousBrokers[1], brokers.getBroker(previousBrokers[1].GetRadarIP()))
+	assert.Nil(t, brokers.getBroker(added.GetRadarIP()))
+	assert.Equal(t, removedCount, brokers.Metrics.RadarRemovedCount.Value)
+}
+
+func TestUDPBrokersService_ReloadRejected(t *testing.T) {
+	brokers := startReloadBrokers(t)
+	previous := utils.GlobalState.Get(servicemodel.StateName)
+	previousBrokers := brokers.Brokers
+
+	cfg := newReloadConfig()
+	cfg.Radars[1].RadarIP = cfg.Radars[0].RadarIP
+	cfg.Normalize()
+
+	_, err := brokers.Reload(cfg)
+	assert.ErrorContains(t, err, "duplicate RadarIP")
+	assert.Same(t, previous, utils.GlobalState.Get(servicemodel.StateName))
+	assert.Equal(t, previousBrokers, brokers.Brokers)
+	assert.Equal(t, int64(1), brokers.Metrics.ReloadRejectedCount.Value)
+}
+
+func TestConfigReloadService_Watch(t *testing.T) {
+	brokers := startReloadBrokers(t)
+	fileName := filepath.Join(t.TempDir(), "config.json")
+
+	writeConfig := func(cfg *servicemodel.Config, modifiedOn time.Time) {
+		data, err := json.Marshal(cfg)
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(fileName, data, 0644))
+		assert.NoError(t, os.Chtimes(fileName, modifiedOn, modifiedOn))
+	}
+
+	now := time.Now()
+	writeConfig(newReloadConfig(), now)
+
+	settings := &utils.Settings{}
+	settings.Init()
+	settings.Basic.Set(configReloadFile, fileName)
+
+	reloader := &ConfigReloadService{Brokers: brokers}
+	reloader.InitFromSettings(settings)
+	reloader.modifiedOn = reloader.getModifiedOn()
+
+	isReloaded, err := reloader.Watch()
+	assert.NoError(t, err)
+	assert.False(t, isReloaded)
+
+	cfg := newReloadConfig()
+	cfg.Radars[1].RadarName = "Renamed"
+	writeConfig(cfg, now.Add(time.Second))
+
+	isReloaded, err = reloader.Watch()
+	assert.NoError(t, err)
+	assert.True(t, isReloaded)
+	assert.Equal(t, "Renamed", brokers.Brokers[1].RadarState.Name)
+
+	// An invalid file leaves the running configuration as is
+	assert.NoError(t, os.WriteFile(fileName, []byte("{"), 0644))
+	assert.NoError(t, os.Chtimes(fileName, now.Add(2*time.Second), now.Add(2*time.Second)))
+	_, err = reloader.Watch()
+	assert.Error(t, err)
+	assert.Equal(t, "Renamed", brokers.Brokers[1].RadarState.Name)
+
+	// The api reload saves the file, without the watcher reloading it again
+	cfg = newReloadConfig()
+	cfg.Radars[0].RadarName = "Saved"
+	_, err = reloader.Reload(cfg)
+	assert.NoError(t, err)
+
+	saved, err := servicemodel.LoadConfig(fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, "Saved", saved.Radars[0].RadarName)
+
+	isReloaded, err = reloader.Watch()
+	assert.NoError(t, err)
+	assert.False(t, isReloaded)
+	assert.Equal(t, int64(1), reloader.Metrics.SaveCount.Value)
+}
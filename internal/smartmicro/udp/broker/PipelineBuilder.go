@@ -0,0 +1,180 @@
+package broker
+
+import (
+	"math"
+	"time"
+
+	"rvpro3/radarvision.com/internal/models/servicemodel"
+	"rvpro3/radarvision.com/internal/smartmicro/interfaces"
+	"rvpro3/radarvision.com/internal/smartmicro/triggerpipeline"
+	"rvpro3/radarvision.com/utils"
+)
+
+type pipelineBuilder struct {
+}
+
+// PipelineBuilder adds the trigger pipeline items of a radar.  It is used by
+// the UDPBroker, and by the pipeline playback to build the same pipeline
+// from a (new) configuration
+var PipelineBuilder pipelineBuilder
+
+// Build adds the radar's items to the pipeline and returns the failsafe item.
+// The detector logic items (delay, extend, max presence) are only added when
+// at least one channel of the radar uses the feature
+func (b pipelineBuilder) Build(
+	pipeline *triggerpipeline.TriggerPipeline,
+	radarCfg *servicemodel.Radar,
+) *triggerpipeline.RadarFailsafePipelineItem {
+	radarIP := radarCfg.GetRadarIP()
+
+	addStagingItem := func() {
+		stageItem := new(triggerpipeline.TriggerPipelineOrItem)
+		stageItem.RadarIP = radarIP
+		stageItem.Name = triggerpipeline.Staging
+		stageItem.Order = 10
+		stageItem.Status = triggerpipeline.ChannelStatusCall
+		pipeline.AddItem(stageItem)
+	}
+
+	addZoneDetectItem := func() {
+		if !b.hasZones(radarCfg) {
+			return
+		}
+
+		zoneItem := new(triggerpipeline.TriggerPipelineOrItem)
+		zoneItem.RadarIP = radarIP
+		zoneItem.Name = triggerpipeline.ZoneDetect
+		zoneItem.Order = 15
+		zoneItem.Status = triggerpipeline.ChannelStatusCall
+		pipeline.AddItem(zoneItem)
+	}
+
+	addDelayItem := func() {
+		channels := b.channels(radarCfg, (*servicemodel.Channel).GetDelay)
+		if len(channels) == 0 {
+			return
+		}
+
+		delayItem := new(triggerpipeline.DelayPipelineItem)
+		delayItem.RadarIP = radarIP
+		delayItem.Name = triggerpipeline.Delay
+		delayItem.Order = 20
+		delayItem.Channels = channels
+		delayItem.Phases, _ = utils.GlobalState.Get(interfaces.PhaseStateName).(*interfaces.PhaseState)
+		pipeline.AddItem(delayItem)
+	}
+
+	addExtendItem := func() {
+		channels := b.channels(radarCfg, (*servicemodel.Channel).GetExtend)
+		if len(channels) == 0 {
+			return
+		}
+
+		extendItem := new(triggerpipeline.ExtendPipelineItem)
+		extendItem.RadarIP = radarIP
+		extendItem.Name = triggerpipeline.Extend
+		extendItem.Order = 30
+		extendItem.Channels = channels
+		pipeline.AddItem(extendItem)
+	}
+
+	addMaxPresenceItem := func() {
+		channels := b.channels(radarCfg, (*servicemodel.Channel).GetMaxHold)
+		if len(channels) == 0 {
+			return
+		}
+
+		maxPresenceItem := new(triggerpipeline.MaxPresencePipelineItem)
+		maxPresenceItem.RadarIP = radarIP
+		maxPresenceItem.Name = triggerpipeline.MaxPresence
+		maxPresenceItem.Order = 40
+		maxPresenceItem.Channels = channels
+		pipeline.AddItem(maxPresenceItem)
+	}
+
+	addManualItem := func() {
+		manualItem := new(triggerpipeline.TriggerPipelineOrItem)
+		manualItem.RadarIP = radarIP
+		manualItem.Name = triggerpipeline.Manual
+		manualItem.Order = 80
+		manualItem.Status = triggerpipeline.ChannelStatusForceSet
+		pipeline.AddItem(manualItem)
+	}
+
+	addFailsafeItem := func() *triggerpipeline.RadarFailsafePipelineItem {
+		failsafeItem := new(triggerpipeline.RadarFailsafePipelineItem)
+		failsafeItem.RadarIP = radarIP
+		failsafeItem.Name = triggerpipeline.Failsafe
+		failsafeItem.Order = 90
+		// Rounded up, a fraction (e.g. "0.5") must not fail safe as soon as
+		// the radar is silent
+		failsafeItem.NoRadarActivitySecs = int(math.Ceil(radarCfg.GetFailSafeTime().Seconds()))
+		failsafeItem.SetChannels, failsafeItem.ClearChannels = b.failsafeMasks(radarCfg)
+		pipeline.AddItem(failsafeItem)
+		return failsafeItem
+	}
+
+	addStagingItem()
+	addZoneDetectItem()
+	addDelayItem()
+	addExtendItem()
+	addMaxPresenceItem()
+	addManualItem()
+	return addFailsafeItem()
+}
+
+// channels returns the detector channels with a non-zero duration
+func (pipelineBuilder) channels(
+	radarCfg *servicemodel.Radar,
+	duration func(*servicemodel.Channel) time.Duration,
+) (res []triggerpipeline.DetectorChannel) {
+	for index := range radarCfg.Channels {
+		channelCfg := &radarCfg.Channels[index]
+
+		if !channelCfg.IsValid() {
+			continue
+		}
+
+		if value := duration(channelCfg); value > 0 {
+			res = append(res, triggerpipeline.DetectorChannel{
+				Index:      channelCfg.GetIndex(),
+				PhaseIndex: channelCfg.GetPhaseIndex(),
+				Duration:   value,
+			})
+		}
+	}
+	return res
+}
+
+// failsafeMasks returns the channels to set and the mask of the channels to
+// keep when the radar fails safe.  Channels are kept unless configured to clear
+func (pipelineBuilder) failsafeMasks(radarCfg *servicemodel.Radar) (set utils.Uint128, keep utils.Uint128) {
+	keep = utils.Uint128{Hi: math.MaxUint64, Lo: math.MaxUint64}
+
+	for index := range radarCfg.Channels {
+		channelCfg := &radarCfg.Channels[index]
+
+		if !channelCfg.IsValid() {
+			continue
+		}
+
+		switch channelCfg.GetFailSafe() {
+		case servicemodel.FailSafeSet:
+			set = set.SetBit(channelCfg.GetIndex(), true)
+		case servicemodel.FailSafeClear:
+			keep = keep.SetBit(channelCfg.GetIndex(), false)
+		}
+	}
+	return set, keep
+}
+
+func (pipelineBuilder) hasZones(radarCfg *servicemodel.Radar) bool {
+	for index := range radarCfg.Channels {
+		channelCfg := &radarCfg.Channels[index]
+
+		if channelCfg.IsZoneDetect() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package broker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/internal/models/servicemodel"
+	"rvpro3/radarvision.com/internal/smartmicro/triggerpipeline"
+)
+
+func TestPipelineBuilder_Build(t *testing.T) {
+	radarCfg := &servicemodel.Radar{
+		RadarIP:      "192.168.11.12:55555",
+		FailSafeTime: "50",
+		Channels: []servicemodel.Channel{
+			{Channel: 1, Phase: 2, MaxHold: "0", Extend: "1.5", FailSafe: "set"},
+			{Channel: 2, Phase: 4, MaxHold: "120", Extend: "0", FailSafe: "clear"},
+			{Channel: 3, Phase: 4, Delay: "3", MaxHold: "abc", Extend: "", FailSafe: "hold"},
+		},
+	}
+	radarCfg.Normalize()
+
+	pipeline := new(triggerpipeline.TriggerPipeline)
+	failsafe := PipelineBuilder.Build(pipeline, radarCfg)
+
+	names := make([]string, 0, len(pipeline.Item))
+	for _, item := range pipeline.Item {
+		names = append(names, item.GetName())
+	}
+	assert.Equal(t, []string{
+		triggerpipeline.Staging,
+		triggerpipeline.Delay,
+		triggerpipeline.Extend,
+		triggerpipeline.MaxPresence,
+		triggerpipeline.Manual,
+		triggerpipeline.Failsafe,
+	}, names)
+
+	extend := pipeline.Find(triggerpipeline.Extend, radarCfg.GetRadarIP()).(*triggerpipeline.ExtendPipelineItem)
+	assert.Equal(t, 1, len(extend.Channels))
+	assert.Equal(t, 0, extend.Channels[0].Index)
+	assert.Equal(t, 1, extend.Channels[0].PhaseIndex)
+
+	maxPresence := pipeline.Find(triggerpipeline.MaxPresence, radarCfg.GetRadarIP()).(*triggerpipeline.MaxPresencePipelineItem)
+	assert.Equal(t, 1, len(maxPresence.Channels))
+	assert.Equal(t, 1, maxPresence.Channels[0].Index)
+
+	assert.Equal(t, 50, failsafe.NoRadarActivitySecs)
+	assert.Equal(t, uint64(1), failsafe.SetChannels.Lo)
+	assert.False(t, failsafe.ClearChannels.IsBit(1))
+	assert.True(t, failsafe.ClearChannels.IsBit(0))
+	assert.True(t, failsafe.ClearChannels.IsBit(2))
+}
+
+func TestPipelineBuilder_FailSafeTimeRoundsUp(t *testing.T) {
+	radarCfg := &servicemodel.Radar{RadarIP: "192.168.11.12:55555", FailSafeTime: "0.5"}
+	radarCfg.Normalize()
+
+	failsafe := PipelineBuilder.Build(new(triggerpipeline.TriggerPipeline), radarCfg)
+	assert.Equal(t, 1, failsafe.NoRadarActivitySecs)
+
+	radarCfg.FailSafeTime = "2.1"
+	failsafe = PipelineBuilder.Build(new(triggerpipeline.TriggerPipeline), radarCfg)
+	assert.Equal(t, 3, failsafe.NoRadarActivitySecs)
+}
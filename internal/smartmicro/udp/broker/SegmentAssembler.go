@@ -0,0 +1,294 @@
+package broker
+
+import (
+	"time"
+
+	"rvpro3/radarvision.com/internal/smartmicro/port"
+	"rvpro3/radarvision.com/utils"
+)
+
+const maxSegmentSlots = 8
+const maxSegmentsPerMessage = 16
+const segmentBufferSize = 16000
+
+// SegmentAssembler reassembles segmented port messages.  Segments are keyed
+// by the transport header data identifier, meaning that more than one message
+// can be in flight at the same time and that non-segmented messages can be
+// interleaved without losing the segments received so far.
+// Segments are ordered by the transport header message counter (when the
+// radar sends it), otherwise by arrival.  The first segment retains its
+// transport and port header, the subsequent segments only contribute their
+// payload - the same layout as port.TransportHeaderReader.CombineTo
+type SegmentAssembler struct {
+	InFlight  int
+	Timeout   time.Duration
+	Metrics   SegmentAssemblerMetrics `json:"-"`
+	slots     [maxSegmentSlots]segmentSlot
+	assembled [segmentBufferSize]byte
+}
+
+type SegmentAssemblerMetrics struct {
+	SegmentCount             *utils.Metric
+	SegmentAssembledCount    *utils.Metric
+	SegmentReorderCount      *utils.Metric
+	SegmentInterleaveCount   *utils.Metric
+	SegmentExpiredErr        *utils.Metric
+	SegmentEvictedErr        *utils.Metric
+	SegmentDuplicateErr      *utils.Metric
+	SegmentTotalErr          *utils.Metric
+	SegmentBufferOverflowErr *utils.Metric
+	utils.MetricsInitMixin
+}
+
+type segmentRef struct {
+	Counter uint16
+	Offset  int
+	Length  int
+}
+
+type segmentSlot struct {
+	IsActive       bool
+	IsCounted      bool
+	DataIdentifier uint16
+	Total          uint16
+	Received       uint16
+	StartOn        time.Time
+	refs           [maxSegmentsPerMessage]segmentRef
+	staging        [segmentBufferSize]byte
+	stagingLen     int
+}
+
+func (s *SegmentAssembler) InitMetrics(sectionName string) {
+	s.Metrics.InitMetrics(sectionName, &s.Metrics)
+}
+
+func (s *SegmentAssembler) InitFromSettings(settings *utils.Settings, radarIP utils.IP4) {
+	ip := radarIP.String()
+	s.InFlight = settings.Indexed.GetInt("radar.udp.segment.inflight", ip, 4)
+	s.Timeout = settings.Indexed.GetDurationMs("radar.udp.segment.timeout", ip, 500)
+}
+
+// Reset clears all in-flight messages
+func (s *SegmentAssembler) Reset() {
+	for index := range s.slots {
+		s.slots[index].reset()
+	}
+}
+
+// IsBusy returns true when at least one segmented message is incomplete
+func (s *SegmentAssembler) IsBusy() bool {
+	for index := range s.slots {
+		if s.slots[index].IsActive {
+			return true
+		}
+	}
+	return false
+}
+
+// NoInFlight returns the number of incomplete segmented messages
+func (s *SegmentAssembler) NoInFlight() (res int) {
+	for index := range s.slots {
+		if s.slots[index].IsActive {
+			res++
+		}
+	}
+	return res
+}
+
+// Expire drops the in-flight messages that has not completed within the Timeout
+func (s *SegmentAssembler) Expire(now time.Time) {
+	if s.Timeout <= 0 {
+		return
+	}
+
+	for index := range s.slots {
+		slot := &s.slots[index]
+
+		if slot.IsActive && now.Sub(slot.StartOn) > s.Timeout {
+			s.Metrics.SegmentExpiredErr.IncAt(1, now)
+			slot.reset()
+		}
+	}
+}
+
+// MarkInterleaved registers a non-segmented message received while segmented
+// messages are in flight
+func (s *SegmentAssembler) MarkInterleaved(now time.Time) {
+	if s.IsBusy() {
+		s.Metrics.SegmentInterleaveCount.IncAt(1, now)
+	}
+}
+
+// Add stores the segment and returns the reassembled message once all
+// segments for the data identifier has been received, otherwise nil.
+// The returned slice remains valid up until the next call to Add
+func (s *SegmentAssembler) Add(now time.Time, th *port.TransportHeaderReader) []byte {
+	s.Metrics.SegmentCount.IncAt(1, now)
+
+	flags := th.GetFlags()
+	total := th.GetSegmentation()
+
+	if total == 0 || total > maxSegmentsPerMessage {
+		s.Metrics.SegmentTotalErr.IncAt(1, now)
+		return nil
+	}
+
+	slot := s.acquire(now, th.GetDataIdentifier(), total, flags.IsMessageCount())
+
+	if slot.Total != total {
+		s.Metrics.SegmentTotalErr.IncAt(1, now)
+		slot.reset()
+		return nil
+	}
+
+	var counter uint16
+	if slot.IsCounted {
+		counter = th.GetMessageCounter()
+		if slot.has(counter) {
+			s.Metrics.SegmentDuplicateErr.IncAt(1, now)
+			return nil
+		}
+	} else {
+		counter = slot.Received
+	}
+
+	if !slot.store(counter, th.Buffer) {
+		s.Metrics.SegmentBufferOverflowErr.IncAt(1, now)
+		slot.reset()
+		return nil
+	}
+
+	if slot.Received < slot.Total {
+		return nil
+	}
+
+	if slot.sort() {
+		s.Metrics.SegmentReorderCount.IncAt(1, now)
+	}
+
+	res, ok := slot.assemble(s.assembled[:])
+	slot.reset()
+
+	if !ok {
+		s.Metrics.SegmentBufferOverflowErr.IncAt(1, now)
+		return nil
+	}
+
+	s.Metrics.SegmentAssembledCount.IncAt(1, now)
+	return res
+}
+
+// acquire finds the slot for the data identifier, or claims a free slot.
+// When all the slots are in use, the oldest in-flight message is evicted
+func (s *SegmentAssembler) acquire(now time.Time, dataIdentifier uint16, total uint16, isCounted bool) *segmentSlot {
+	inFlight := s.getInFlight()
+
+	var free *segmentSlot
+	var oldest *segmentSlot
+
+	for index := range s.slots[:inFlight] {
+		slot := &s.slots[index]
+
+		if !slot.IsActive {
+			if free == nil {
+				free = slot
+			}
+			continue
+		}
+
+		if slot.DataIdentifier == dataIdentifier {
+			return slot
+		}
+
+		if oldest == nil || slot.StartOn.Before(oldest.StartOn) {
+			oldest = slot
+		}
+	}
+
+	if free == nil {
+		s.Metrics.SegmentEvictedErr.IncAt(1, now)
+		oldest.reset()
+		free = oldest
+	}
+
+	free.IsActive = true
+	free.IsCounted = isCounted
+	free.DataIdentifier = dataIdentifier
+	free.Total = total
+	free.StartOn = now
+	return free
+}
+
+func (s *SegmentAssembler) getInFlight() int {
+	return min(max(s.InFlight, 1), maxSegmentSlots)
+}
+
+func (s *segmentSlot) reset() {
+	s.IsActive = false
+	s.IsCounted = false
+	s.DataIdentifier = 0
+	s.Total = 0
+	s.Received = 0
+	s.stagingLen = 0
+}
+
+func (s *segmentSlot) has(counter uint16) bool {
+	for _, ref := range s.refs[:s.Received] {
+		if ref.Counter == counter {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *segmentSlot) store(counter uint16, buffer []byte) bool {
+	if s.stagingLen+len(buffer) > len(s.staging) {
+		return false
+	}
+
+	copy(s.staging[s.stagingLen:], buffer)
+	s.refs[s.Received] = segmentRef{
+		Counter: counter,
+		Offset:  s.stagingLen,
+		Length:  len(buffer),
+	}
+	s.stagingLen += len(buffer)
+	s.Received++
+	return true
+}
+
+// sort orders the segments by message counter and returns true if the
+// segments were received out of sequence.  The comparison is wrap around
+// safe as the segments of a single message are in close proximity
+func (s *segmentSlot) sort() bool {
+	isReordered := false
+	refs := s.refs[:s.Received]
+
+	for i := 1; i < len(refs); i++ {
+		for j := i; j > 0 && int16(refs[j].Counter-refs[j-1].Counter) < 0; j-- {
+			refs[j], refs[j-1] = refs[j-1], refs[j]
+			isReordered = true
+		}
+	}
+	return isReordered
+}
+
+func (s *segmentSlot) assemble(target []byte) ([]byte, bool) {
+	fixed := utils.NewFixedBuffer(target, 0, 0)
+
+	for index, ref := range s.refs[:s.Received] {
+		segment := s.staging[ref.Offset : ref.Offset+ref.Length]
+
+		if index > 0 {
+			th := port.TransportHeaderReader{Buffer: segment}
+			segment = segment[th.GetHeaderLength():]
+		}
+
+		fixed.WriteBytes(segment)
+	}
+
+	if fixed.Err != nil {
+		return nil, false
+	}
+	return fixed.AsWriteSlice(), true
+}
@@ -0,0 +1,100 @@
+package broker
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/internal/smartmicro/port"
+	"rvpro3/radarvision.com/utils"
+)
+
+func newSegment(counter uint16, dataIdentifier uint16, total uint16, payload string) *port.TransportHeaderReader {
+	flags := port.FlMessageCount | port.FlDataIdentifier | port.FlSegmentation
+	buffer := make([]byte, 100)
+	fixed := utils.NewFixedBuffer(buffer, 0, 0)
+
+	fixed.StartWriteMarker()
+	fixed.WriteU8(port.StartPattern)
+	fixed.WriteU8(port.ProtocolVersion)
+	fixed.WriteU8(18)
+	fixed.WriteU16(uint16(len(payload)), binary.BigEndian)
+	fixed.WriteU8(uint8(port.PtSmartMicroPort))
+	fixed.WriteU32(uint32(flags), binary.BigEndian)
+	fixed.WriteU16(counter, binary.BigEndian)
+	fixed.WriteU16(dataIdentifier, binary.BigEndian)
+	fixed.WriteU16(total, binary.BigEndian)
+	fixed.WriteCRC16(binary.BigEndian)
+	fixed.WriteBytes([]byte(payload))
+
+	return &port.TransportHeaderReader{Buffer: fixed.AsWriteSlice()}
+}
+
+func newAssembler() *SegmentAssembler {
+	res := &SegmentAssembler{InFlight: 2, Timeout: 500 * time.Millisecond}
+	res.InitMetrics("Test.Segment.Assembler")
+	return res
+}
+
+func TestSegmentAssembler_InOrder(t *testing.T) {
+	sa := newAssembler()
+	now := time.Now()
+
+	assert.Nil(t, sa.Add(now, newSegment(10, 1, 2, "AB")))
+	res := sa.Add(now, newSegment(11, 1, 2, "CD"))
+
+	assert.NotNil(t, res)
+	assert.Equal(t, "ABCD", string(res[18:]))
+	assert.False(t, sa.IsBusy())
+}
+
+func TestSegmentAssembler_Reordered(t *testing.T) {
+	sa := newAssembler()
+	now := time.Now()
+
+	assert.Nil(t, sa.Add(now, newSegment(0, 1, 3, "EF")))
+	assert.Nil(t, sa.Add(now, newSegment(65534, 1, 3, "AB")))
+	res := sa.Add(now, newSegment(65535, 1, 3, "CD"))
+
+	assert.NotNil(t, res)
+	assert.Equal(t, "ABCDEF", string(res[18:]))
+
+	th := port.TransportHeaderReader{Buffer: res}
+	assert.Equal(t, uint16(65534), th.GetMessageCounter())
+	assert.Equal(t, int64(1), sa.Metrics.SegmentReorderCount.Value)
+}
+
+func TestSegmentAssembler_Interleaved(t *testing.T) {
+	sa := newAssembler()
+	now := time.Now()
+
+	assert.Nil(t, sa.Add(now, newSegment(1, 1, 2, "AB")))
+	assert.Nil(t, sa.Add(now, newSegment(5, 2, 2, "12")))
+	sa.MarkInterleaved(now)
+
+	res := sa.Add(now, newSegment(6, 2, 2, "34"))
+	assert.Equal(t, "1234", string(res[18:]))
+
+	res = sa.Add(now, newSegment(2, 1, 2, "CD"))
+	assert.Equal(t, "ABCD", string(res[18:]))
+}
+
+func TestSegmentAssembler_DuplicateEvictAndExpire(t *testing.T) {
+	sa := newAssembler()
+	now := time.Now()
+
+	assert.Nil(t, sa.Add(now, newSegment(1, 1, 2, "AB")))
+	assert.Nil(t, sa.Add(now, newSegment(1, 1, 2, "AB")))
+	assert.Equal(t, int64(1), sa.Metrics.SegmentDuplicateErr.Value)
+
+	// Only 2 in flight, so data identifier 1 gets evicted
+	assert.Nil(t, sa.Add(now.Add(time.Millisecond), newSegment(3, 2, 2, "AB")))
+	assert.Nil(t, sa.Add(now.Add(time.Millisecond), newSegment(5, 3, 2, "AB")))
+	assert.Equal(t, int64(1), sa.Metrics.SegmentEvictedErr.Value)
+	assert.Equal(t, 2, sa.NoInFlight())
+
+	sa.Expire(now.Add(time.Second))
+	assert.Equal(t, int64(2), sa.Metrics.SegmentExpiredErr.Value)
+	assert.False(t, sa.IsBusy())
+}
@@ -2,10 +2,13 @@ package broker
 
 import (
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/rs/zerolog/log"
 	"rvpro3/radarvision.com/internal/models/servicemodel"
+	"rvpro3/radarvision.com/internal/models/streammodel"
+	"rvpro3/radarvision.com/internal/smartmicro/interfaces"
 	"rvpro3/radarvision.com/internal/smartmicro/port"
 	"rvpro3/radarvision.com/internal/smartmicro/triggerpipeline"
 	"rvpro3/radarvision.com/internal/smartmicro/udp/activity/generic"
@@ -14,35 +17,53 @@ import (
 	"rvpro3/radarvision.com/internal/smartmicro/udp/activity/statistics"
 	"rvpro3/radarvision.com/internal/smartmicro/udp/activity/trigger"
 	"rvpro3/radarvision.com/internal/smartmicro/udp/state"
+	"rvpro3/radarvision.com/internal/smartmicro/workflows/udp/diagnostics"
+	pvrworkflow "rvpro3/radarvision.com/internal/smartmicro/workflows/udp/pvr"
+	statisticsworkflow "rvpro3/radarvision.com/internal/smartmicro/workflows/udp/statistics"
 	"rvpro3/radarvision.com/utils"
 )
 
 type UDPBroker struct {
-	RadarState       *state.RadarState
-	IPAddress        utils.IP4
-	SegmentCounter   uint16
-	SegmentTotal     uint16
-	SegmentId        uint16
-	Now              time.Time
-	FailSafePipeline triggerpipeline.RadarFailsafePipelineItem
-	Executor         Workflows
-	IsVerboseTrigger bool
-	IsVerboseStats   bool
-	IsVerboseObjList bool
-	IsVerbosePVR     bool
-	IsCountTrigger   bool
-	IsCountStats     bool
-	IsCountObjList   bool
-	IsCountPVR       bool
-	DataSlice        []byte           `json:"-"`
-	OnTerminate      func(*UDPBroker) `json:"-"`
-	Metrics          UDPBrokerMetrics `json:"-"`
-	buffer           [16000]byte
-	fixed            utils.FixedBuffer
-	terminated       bool
-	isDone           bool
-	msgChannel       chan *UDPMessage
-	doneChannel      chan bool
+	RadarState           *state.RadarState
+	IPAddress            utils.IP4
+	Segments             SegmentAssembler
+	Now                  time.Time
+	FailSafePipeline     triggerpipeline.RadarFailsafePipelineItem
+	Executor             Workflows
+	IsVerboseTrigger     bool
+	IsVerboseStats       bool
+	IsVerboseObjList     bool
+	IsVerbosePVR         bool
+	IsCountTrigger       bool
+	IsCountStats         bool
+	IsCountObjList       bool
+	IsCountPVR           bool
+	IsZoneDetect         bool
+	IsWrongWay           bool
+	IsStatsAggregated    bool
+	IsPVRRecorded        bool
+	IsHealthMonitored    bool
+	IsPipelineRecorded   bool
+	PipelineRecorderPath string
+	PipelineRecorderMb   int
+	PipelineRecorderKeep int
+	PipelineRecorderSize int
+	IsPipelineChanges    bool
+	IsSocketStreamed     bool
+	StreamSink           interfaces.IStreamSink `json:"-"`
+	ChannelStreamEvery   utils.Milliseconds
+	channelStreamCalls   utils.Uint128
+	channelStreamOn      time.Time
+	statisticsAggregate  *statisticsworkflow.AggregateActivity
+	DataSlice            []byte           `json:"-"`
+	OnTerminate          func(*UDPBroker) `json:"-"`
+	Metrics              UDPBrokerMetrics `json:"-"`
+	terminated           bool
+	isDone               bool
+	msgChannel           chan *UDPMessage
+	doneChannel          chan bool
+	terminatedChannel    chan bool
+	reconfigureChannel   chan func()
 }
 
 type UDPBrokerMetrics struct {
@@ -52,9 +73,7 @@ type UDPBrokerMetrics struct {
 	TransportHeaderFormatErr *utils.Metric
 	TransportHeaderCrcErr    *utils.Metric
 	ProtocolTypeErr          *utils.Metric
-	DiscardSegmentErr        *utils.Metric
 	UnknownPortErr           *utils.Metric
-	SegmentBufferOverflowErr *utils.Metric
 	PortHeaderFormatErr      *utils.Metric
 	utils.MetricsInitMixin
 }
@@ -70,6 +89,27 @@ func (rc *UDPBroker) InitFromSettings(settings *utils.Settings) {
 	rc.IsCountStats = settings.Indexed.GetBool("radar.udp.counting.statistics", ip, false)
 	rc.IsCountObjList = settings.Indexed.GetBool("radar.udp.counting.objectlist", ip, false)
 	rc.IsCountPVR = settings.Indexed.GetBool("radar.udp.counting.pvr", ip, false)
+
+	rc.IsZoneDetect = settings.Indexed.GetBool("radar.zone.detect.enabled", ip, false)
+	rc.IsWrongWay = settings.Indexed.GetBool("radar.wrongway.enabled", ip, false)
+	rc.IsStatsAggregated = settings.Indexed.GetBool("radar.statistics.aggregate.enabled", ip, false)
+	rc.IsPVRRecorded = settings.Indexed.GetBool("radar.pvr.record.enabled", ip, false)
+	rc.IsHealthMonitored = settings.Indexed.GetBool("radar.health.enabled", ip, true)
+	rc.IsPipelineRecorded = settings.Indexed.GetBool("radar.pipeline.recorder.enabled", ip, false)
+	rc.PipelineRecorderPath = settings.Indexed.Get(
+		"radar.pipeline.recorder.pathtemplate",
+		ip,
+		fmt.Sprintf("/media/SDLOGS/logs/sensor/%d/pipeline/pipeline-%%s.jsonl", rc.IPAddress.GetHost()),
+	)
+	rc.PipelineRecorderMb = settings.Indexed.GetInt("radar.pipeline.recorder.file.max.mb", ip, 50)
+	rc.PipelineRecorderKeep = settings.Indexed.GetInt("radar.pipeline.recorder.max.files", ip, 10)
+	rc.PipelineRecorderSize = settings.Indexed.GetInt("radar.pipeline.recorder.queue.size", ip, 100)
+	rc.IsPipelineChanges = settings.Indexed.GetBool("radar.pipeline.recorder.changes.only", ip, false)
+
+	rc.IsSocketStreamed = settings.Indexed.GetBool("radar.socket.stream.enabled", ip, true)
+	rc.ChannelStreamEvery = settings.Basic.GetMilliseconds("radar.socket.stream.channels.every", 1000)
+
+	rc.Segments.InitFromSettings(settings, rc.IPAddress)
 }
 
 func (rc *UDPBroker) Start(_ *utils.State, _ *utils.Settings) {
@@ -83,6 +123,7 @@ func (rc *UDPBroker) InitMetrics(ip utils.IP4) {
 	rc.IPAddress = ip
 	sectionName := fmt.Sprintf("UDP.Broker-%s", ip)
 	rc.Metrics.InitMetrics(sectionName, &rc.Metrics)
+	rc.Segments.InitMetrics(sectionName)
 	rc.Executor.Init(ip)
 }
 
@@ -96,7 +137,9 @@ func (rc *UDPBroker) Run(radarIP utils.IP4) {
 	rc.isDone = false
 	rc.msgChannel = make(chan *UDPMessage, 5)
 	rc.doneChannel = make(chan bool)
-	rc.fixed = utils.NewFixedBuffer(rc.buffer[:], 0, 0)
+	rc.terminatedChannel = make(chan bool)
+	rc.reconfigureChannel = make(chan func())
+	rc.Segments.Reset()
 
 	rc.SetupFailSafe()
 
@@ -105,10 +148,7 @@ func (rc *UDPBroker) Run(radarIP utils.IP4) {
 
 func (rc *UDPBroker) Stop() {
 	rc.doneChannel <- true
-
-	for !rc.terminated {
-		time.Sleep(100 * time.Millisecond)
-	}
+	<-rc.terminatedChannel
 }
 
 func (rc *UDPBroker) execute() {
@@ -117,14 +157,20 @@ func (rc *UDPBroker) execute() {
 		case msg := <-rc.msgChannel:
 			rc.startMsg(msg)
 
+		case reconfigure := <-rc.reconfigureChannel:
+			reconfigure()
+
 		case <-rc.doneChannel:
 			rc.isDone = true
 			close(rc.msgChannel)
 			close(rc.doneChannel)
+			rc.Executor.Close()
+			rc.closePipelineRecorder()
 
 			if rc.OnTerminate != nil {
 				rc.OnTerminate(rc)
 				rc.terminated = true
+				close(rc.terminatedChannel)
 			}
 
 			return
@@ -134,6 +180,9 @@ func (rc *UDPBroker) execute() {
 
 func (rc *UDPBroker) startMsg(msg *UDPMessage) {
 	rc.Now = utils.Time.Exact()
+	if msg.IsReplayed {
+		rc.Now = msg.CreateOn
+	}
 	rc.Metrics.ReceivedCount.IncAt(1, rc.Now)
 	rc.Metrics.ReceivedBytes.IncAt(int64(msg.BufferLen), rc.Now)
 
@@ -147,66 +196,31 @@ func (rc *UDPBroker) startMsg(msg *UDPMessage) {
 	// Process may be false on segmentation, or error
 	if process {
 		rc.process()
-		rc.resetSegmentation()
 	}
 
 	// Put the UDPMessage back into the pool
 	messagePool.Put(msg)
 }
 
-func (rc *UDPBroker) handleSegmentation(msg *UDPMessage, th *port.TransportHeaderReader) bool {
-	// The data is segmented, so we have to check whether we are currently
-	// segmented
-
-	// Check if currently in segmentation mode
-	if rc.SegmentId != 0 {
-		// Check if segment out of sequence
-		if rc.SegmentId != th.GetDataIdentifier() {
-			// The segment is out of sequence, so we have to reset
-			rc.Metrics.DiscardSegmentErr.IncAt(1, rc.Now)
-			rc.SegmentCounter = 0
-			rc.fixed.Reset()
-			return false
-		}
-		// The segment is in sequence to append and process if last
-		rc.SegmentCounter += 1
-
-		if !rc.fixed.CanWrite(msg.BufferLen) {
-			// Segmentation won't fit
-			rc.Metrics.SegmentBufferOverflowErr.IncAt(1, rc.Now)
-			rc.resetSegmentation()
-			return false
-		}
-		rc.fixed.WriteBytes(msg.Buffer[th.GetHeaderLength():msg.BufferLen])
-		rc.DataSlice = rc.fixed.AsWriteSlice()
-		return rc.SegmentCounter == rc.SegmentTotal
-	}
-	// Run segmentation
-	rc.SegmentCounter = 1
-	rc.SegmentId = th.GetDataIdentifier()
-	rc.SegmentTotal = th.GetSegmentation()
-	rc.fixed.WriteBytes(msg.Buffer[:msg.BufferLen])
-	return false
-}
-
-// consumeData copies/concats the msg.Buffer to our own Buffer
-// by checking whether it is segmented.  consumeData returns true
-// the DataSlice is ready for processing, otherwise false
+// consumeData points the DataSlice to a complete port message.  Segmented
+// messages are handed to the SegmentAssembler, which copies the segments
+// until the message is complete.  consumeData returns true when the
+// DataSlice is ready for processing, otherwise false
 func (rc *UDPBroker) consumeData(msg *UDPMessage) bool {
 	th := port.TransportHeaderReader{
 		Buffer: msg.Buffer[:msg.BufferLen],
 	}
 
+	rc.Segments.Expire(rc.Now)
+
 	// The header says the data is segmented
 	if th.GetFlags().IsSegmentation() {
-		return rc.handleSegmentation(msg, &th)
+		rc.DataSlice = rc.Segments.Add(rc.Now, &th)
+		return rc.DataSlice != nil
 	}
-	if rc.SegmentId != 0 {
-		// Currently in segmentation mode and received a message without
-		// segmentation, so we register the discarded segment
-		rc.Metrics.DiscardSegmentErr.IncAt(1, rc.Now)
-	}
-	// The data is not segmented, then reset whatever segment we may have
+
+	// A non-segmented message does not interfere with the segments in flight
+	rc.Segments.MarkInterleaved(rc.Now)
 
 	// Simply use the data from the messagePool, as it is complete and does not
 	// require any buffer copying
@@ -234,7 +248,7 @@ func (rc *UDPBroker) isTransportHeader(th *port.TransportHeaderReader, msg *UDPM
 }
 
 // process assumes that the DataSlice points to a complete port message
-// The source can either be UDPBroker.buffer (due to segmentation) or the
+// The source can either be the SegmentAssembler (due to segmentation) or the
 // UDPMessage.Buffer (no segmentation)
 func (rc *UDPBroker) process() {
 	th := port.TransportHeaderReader{
@@ -253,7 +267,7 @@ func (rc *UDPBroker) process() {
 	}
 
 	rc.RadarState.ReplaceSerial(th.GetSourceClientId())
-	rc.RadarState.FailSafe.SetUpdateOn(utils.Time.Approx())
+	rc.RadarState.SetActivityOn(utils.Time.Approx())
 
 	rc.Executor.Execute(
 		rc.Now,
@@ -289,13 +303,6 @@ func (rc *UDPBroker) logError(err error) {
 	log.Err(err).Msgf("radar: %s", rc.IPAddress)
 }
 
-func (rc *UDPBroker) resetSegmentation() {
-	rc.SegmentTotal = 0
-	rc.SegmentCounter = 0
-	rc.SegmentId = 0
-	rc.fixed.Reset()
-}
-
 func (rc *UDPBroker) SendMessage(msg *UDPMessage) {
 	if rc.isDone {
 		return
@@ -320,48 +327,102 @@ func (rc *UDPBroker) SetupWorkflow(
 	serviceCfg *servicemodel.Config,
 	radarCfg *servicemodel.Radar,
 ) {
+	rc.setupPipeline(radarCfg)
+	rc.setupWorkflows(serviceCfg, radarCfg)
+}
+
+// Reconfigure rebuilds the trigger pipeline and the workflows of the radar
+// from the (changed) configuration.  A running broker reconfigures between
+// two messages, so no message is processed by a partially built workflow,
+// and the pipeline is swapped between two executions with the state carried
+// over, so the channel calls do not drop
+func (rc *UDPBroker) Reconfigure(serviceCfg *servicemodel.Config, radarCfg *servicemodel.Radar) {
+	reconfigure := func() {
+		pipeline := new(triggerpipeline.TriggerPipeline)
+		failSafe := PipelineBuilder.Build(pipeline, radarCfg)
+		rc.RadarState.ReplacePipeline(pipeline, failSafe)
+		rc.RadarState.Name = radarCfg.RadarName
+
+		// The open statistics bins are carried over to the rebuilt workflow
+		var bins []*statisticsworkflow.Aggregator
+		if rc.statisticsAggregate != nil {
+			bins = rc.statisticsAggregate.Aggregate.Detach()
+			rc.statisticsAggregate = nil
+		}
+
+		// The files and streams of the workflows are released before the
+		// workflows rebuilt open them again
+		rc.Executor.Close()
+		rc.Executor.Workflows = nil
+		rc.setupWorkflows(serviceCfg, radarCfg)
+
+		if rc.statisticsAggregate != nil {
+			rc.statisticsAggregate.Aggregate.Attach(bins)
+		}
+	}
+
+	if rc.reconfigureChannel == nil || rc.isDone {
+		reconfigure()
+		return
+	}
+
+	done := make(chan bool)
+	rc.reconfigureChannel <- func() {
+		reconfigure()
+		close(done)
+	}
+	<-done
+}
+
+func (rc *UDPBroker) setupWorkflows(serviceCfg *servicemodel.Config, radarCfg *servicemodel.Radar) {
 	cuter := &rc.Executor
-	rc.setupPipeline()
 	rc.setupTriggerWorkflow()
+	rc.setupZoneDetection(serviceCfg, radarCfg)
+	rc.setupStatisticsAggregation(serviceCfg)
+	rc.setupPVRRecording(serviceCfg)
+	rc.setupHealthMonitoring()
 	//rc.setupVerboseActivityLogging(cuter)
 	//rc.setupVerboseActivityCounting(cuter)
 	rc.setupCSVLogging(cuter)
+	rc.setupSocketStreams(cuter)
 }
 
-func (rc *UDPBroker) setupPipeline() {
+func (rc *UDPBroker) setupPipeline(radarCfg *servicemodel.Radar) {
 	pipeline := &rc.RadarState.Pipeline
+	rc.RadarState.FailSafe = PipelineBuilder.Build(pipeline, radarCfg)
 
-	addStagingItem := func() {
-		stageItem := new(triggerpipeline.TriggerPipelineOrItem)
-		stageItem.RadarIP = rc.GetRadarIP()
-		stageItem.Name = triggerpipeline.Staging
-		stageItem.Order = 10
-		stageItem.Status = triggerpipeline.ChannelStatusCall
-		pipeline.AddItem(stageItem)
+	if rc.IsPipelineRecorded {
+		rc.setupPipelineRecorder(pipeline)
 	}
+}
 
-	addManualItem := func() {
-		manualItem := new(triggerpipeline.TriggerPipelineOrItem)
-		manualItem.RadarIP = rc.GetRadarIP()
-		manualItem.Name = triggerpipeline.Manual
-		manualItem.Order = 80
-		manualItem.Status = triggerpipeline.ChannelStatusForceSet
-		pipeline.AddItem(manualItem)
-	}
+func (rc *UDPBroker) setupPipelineRecorder(pipeline *triggerpipeline.TriggerPipeline) {
+	recorder, err := triggerpipeline.CreateRotatingTriggerRecorder(
+		rc.PipelineRecorderPath,
+		int64(rc.PipelineRecorderMb)*utils.Megabyte,
+		rc.PipelineRecorderKeep,
+	)
 
-	addFailsafeItem := func() {
-		failsafeItem := new(triggerpipeline.RadarFailsafePipelineItem)
-		failsafeItem.RadarIP = rc.GetRadarIP()
-		failsafeItem.Name = triggerpipeline.Failsafe
-		failsafeItem.Order = 90
-		pipeline.AddItem(failsafeItem)
+	if err != nil {
+		rc.logError(err)
+		return
+	}
+	recorder.IsChangesOnly = rc.IsPipelineChanges
+	recorder.Start(rc.PipelineRecorderSize)
+	pipeline.Recorder = recorder
+}
 
-		rc.RadarState.FailSafe = failsafeItem
+// closePipelineRecorder flushes and closes the timeline of the pipeline
+func (rc *UDPBroker) closePipelineRecorder() {
+	if rc.RadarState == nil {
+		return
 	}
 
-	addStagingItem()
-	addManualItem()
-	addFailsafeItem()
+	if closer, ok := rc.RadarState.DetachRecorder().(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			rc.logError(err)
+		}
+	}
 }
 
 func (rc *UDPBroker) setupTriggerWorkflow() {
@@ -370,6 +431,70 @@ func (rc *UDPBroker) setupTriggerWorkflow() {
 	utils.Exec.If(rc.IsCountTrigger, func() { wf.AddActivity(&generic.CountingActivity{}) })
 	wf.AddActivity(&trigger.LogCSVActivity{})
 	wf.AddActivity(&trigger.StageTriggerActivity{})
+	utils.Exec.If(rc.IsWrongWay, func() { wf.AddActivity(&trigger.WrongWayActivity{}) })
+}
+
+// setupZoneDetection raises channel calls from the object list, in addition
+// to the radar's own relays
+func (rc *UDPBroker) setupZoneDetection(serviceCfg *servicemodel.Config, radarCfg *servicemodel.Radar) {
+	if !rc.IsZoneDetect {
+		return
+	}
+
+	rc.Executor.
+		Workflow(port.PiObjectList).
+		AddActivity(&objectlist.ZoneDetectActivity{
+			Radar:          radarCfg,
+			DistanceFactor: serviceCfg.GetDistanceFactor(),
+			SpeedFactor:    serviceCfg.GetSpeedFactor(),
+		})
+}
+
+// setupStatisticsAggregation aggregates the statistics into bins, written to
+// CSV and to the statistics history
+func (rc *UDPBroker) setupStatisticsAggregation(serviceCfg *servicemodel.Config) {
+	if !rc.IsStatsAggregated {
+		return
+	}
+
+	rc.statisticsAggregate = &statisticsworkflow.AggregateActivity{
+		SpeedFactor: serviceCfg.GetSpeedFactor(),
+		SpeedUnit:   serviceCfg.SpeedUnit,
+	}
+
+	rc.Executor.
+		Workflow(port.PiStatistics).
+		AddActivity(rc.statisticsAggregate)
+}
+
+// setupPVRRecording records the vehicles of the PVR into the PVR history
+func (rc *UDPBroker) setupPVRRecording(serviceCfg *servicemodel.Config) {
+	if !rc.IsPVRRecorded {
+		return
+	}
+
+	rc.Executor.
+		Workflow(port.PiPVR).
+		AddActivity(&pvrworkflow.RecordActivity{
+			SpeedFactor:    serviceCfg.GetSpeedFactor(),
+			SpeedUnit:      serviceCfg.SpeedUnit,
+			DistanceFactor: serviceCfg.GetDistanceFactor(),
+			DistanceUnit:   serviceCfg.DistanceUnit,
+		})
+}
+
+// setupHealthMonitoring decodes the diagnostics of the radar into the radar
+// state, failing safe while the health is degraded
+func (rc *UDPBroker) setupHealthMonitoring() {
+	if !rc.IsHealthMonitored {
+		return
+	}
+
+	rc.Executor.
+		Workflow(port.PiDiagnostics).
+		AddActivity(&diagnostics.HealthActivity{
+			Health: diagnostics.Workflow{RadarState: rc.RadarState},
+		})
 }
 
 func (rc *UDPBroker) setupCSVLogging(cuter *Workflows) {
@@ -377,6 +502,64 @@ func (rc *UDPBroker) setupCSVLogging(cuter *Workflows) {
 		AddActivity(&trigger.LogCSVActivity{})
 }
 
+// setupSocketStreams streams the radar data to the clients of the stream
+// sink, the data is only decoded while a client is subscribed
+func (rc *UDPBroker) setupSocketStreams(cuter *Workflows) {
+	if !rc.IsSocketStreamed || rc.StreamSink == nil {
+		return
+	}
+
+	cuter.Workflow(port.PiObjectList).
+		AddActivity(rc.newSocketStream(streammodel.SubscriptionObjectList, objectlist.StreamMessageOf))
+	cuter.Workflow(port.PiPVR).
+		AddActivity(rc.newSocketStream(streammodel.SubscriptionPVR, pvr.StreamMessageOf))
+	cuter.Workflow(port.PiStatistics).
+		AddActivity(rc.newSocketStream(streammodel.SubscriptionStatistics, statistics.StreamMessageOf))
+	cuter.Workflow(port.PiEventTrigger).
+		AddActivity(rc.newSocketStream(streammodel.SubscriptionTrigger, trigger.StreamMessageOf))
+}
+
+func (rc *UDPBroker) newSocketStream(subscription uint64, decode generic.StreamDecoder) *generic.SocketStreamActivity {
+	return &generic.SocketStreamActivity{
+		Sink:         rc.StreamSink,
+		Subscription: subscription,
+		Decode:       decode,
+	}
+}
+
+// StreamChannelStatus sends the channel calls of the radar, when changed
+// and at least every ChannelStreamEvery, to the clients subscribed.  It is
+// called by UDPBrokersService.ExecutePipelines, meaning the channel status is
+// only streamed while the DetectorOutputService executes the pipelines
+// (feature.detector.output.enabled)
+func (rc *UDPBroker) StreamChannelStatus(now time.Time, calls utils.Uint128) {
+	if !rc.IsSocketStreamed || rc.RadarState == nil || rc.StreamSink == nil {
+		return
+	}
+
+	if !rc.StreamSink.IsAnySubscribedTo(streammodel.SubscriptionChannelStatus, rc.IPAddress) {
+		return
+	}
+
+	if calls == rc.channelStreamCalls && !rc.ChannelStreamEvery.Expired(now, rc.channelStreamOn) {
+		return
+	}
+
+	rc.channelStreamCalls = calls
+	rc.channelStreamOn = now
+
+	message := streammodel.ChannelStatusMessage{
+		RadarMessage:     streammodel.NewRadarMessage(streammodel.ChannelStatusType, rc.IPAddress, now),
+		Calls:            calls,
+		IsAutoFailSafe:   rc.RadarState.IsAutoFailSafe,
+		IsManualFailSafe: rc.RadarState.IsManualFailSafe,
+	}
+
+	if err := rc.StreamSink.Stream(streammodel.SubscriptionChannelStatus, rc.IPAddress, &message); err != nil {
+		rc.logError(err)
+	}
+}
+
 func (rc *UDPBroker) setupVerboseActivityLogging(cuter *Workflows) {
 	if rc.IsVerboseObjList {
 		cuter.
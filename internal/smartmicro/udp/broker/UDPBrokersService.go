@@ -2,9 +2,11 @@ package broker
 
 import (
 	"net"
+	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"rvpro3/radarvision.com/internal/constants"
 	"rvpro3/radarvision.com/internal/general"
@@ -19,14 +21,33 @@ import (
 const UDPBrokersServiceName = "UDP.Brokers.Service"
 
 type UDPBrokersService struct {
-	Brokers           []UDPBroker   `json:"Broker"`
-	TerminateRefCount atomic.Uint32 `json:"-"`
+	Brokers           []*UDPBroker             `json:"Broker"`
+	TerminateRefCount atomic.Uint32            `json:"-"`
+	Metrics           UDPBrokersServiceMetrics `json:"-"`
+	StreamSink        interfaces.IStreamSink   `json:"-"`
 	workflowBuilder   interfaces.IUDPWorkflowBuilder
+	dataService       *service.UDPDataService
+	receiverId        int
 	IsEnabled         bool
+	state             *utils.State
+	settings          *utils.Settings
+	brokersLock       sync.RWMutex
+	reloadLock        sync.Mutex
+}
+
+type UDPBrokersServiceMetrics struct {
+	ReloadCount         *utils.Metric
+	ReloadRejectedCount *utils.Metric
+	RollbackCount       *utils.Metric
+	RadarAddedCount     *utils.Metric
+	RadarRemovedCount   *utils.Metric
+	RadarChangedCount   *utils.Metric
+	utils.MetricsInitMixin
 }
 
 func (rc *UDPBrokersService) InitFromSettings(_ *utils.Settings) {
 	rc.IsEnabled = true
+	rc.Metrics.InitMetrics(UDPBrokersServiceName, &rc.Metrics)
 }
 
 func (rc *UDPBrokersService) getChannelConfig() *servicemodel.Config {
@@ -50,29 +71,195 @@ func (rc *UDPBrokersService) Start(state *utils.State, settings *utils.Settings)
 		return
 	}
 
-	serviceCfg := rc.getChannelConfig()
-	rc.InitNoRadars(len(serviceCfg.Radars))
+	rc.startBrokers(state, settings)
 	rc.AttachTo(dataService)
+}
 
-	for index, radarCfg := range serviceCfg.Radars {
-		udpBroker := &rc.Brokers[index]
-		udpBroker.RadarState = state2.RadarStateHelper.GetOrSet(radarCfg.GetRadarIP())
-		udpBroker.RadarState.IP = radarCfg.GetRadarIP()
-		udpBroker.RadarState.Name = radarCfg.RadarName
-		udpBroker.InitMetrics(radarCfg.GetRadarIP())
-		udpBroker.InitFromSettings(settings)
-		udpBroker.SetupWorkflow(udpBroker, serviceCfg, radarCfg)
+// StartReplay starts the brokers without attaching to the UDP data service,
+// the brokers then only receive the datagrams fed to them using Replay
+func (rc *UDPBrokersService) StartReplay(state *utils.State, settings *utils.Settings) {
+	if !general.ServiceHelper.ShouldStart(state, settings, rc) {
+		return
 	}
 
+	rc.startBrokers(state, settings)
+}
+
+func (rc *UDPBrokersService) startBrokers(state *utils.State, settings *utils.Settings) {
+	rc.state = state
+	rc.settings = settings
+
+	serviceCfg := rc.getChannelConfig()
+	rc.InitNoRadars(len(serviceCfg.Radars))
+
+	// The phase state is required by the (phase conditioned) pipeline items
 	rc.SetupStates(state)
 
+	for index, radarCfg := range serviceCfg.Radars {
+		rc.Brokers[index] = rc.newBroker(serviceCfg, radarCfg)
+	}
+
 	rc.TerminateRefCount.Store(0)
 
-	for index := range rc.Brokers {
-		udpBroker := &rc.Brokers[index]
+	for _, udpBroker := range rc.Brokers {
+		udpBroker.OnTerminate = rc.OnChannelTerminate
+		udpBroker.Run(udpBroker.GetRadarIP())
+	}
+}
+
+func (rc *UDPBrokersService) newBroker(serviceCfg *servicemodel.Config, radarCfg *servicemodel.Radar) *UDPBroker {
+	res := new(UDPBroker)
+	res.RadarState = state2.RadarStateHelper.GetOrSet(radarCfg.GetRadarIP())
+	res.RadarState.IP = radarCfg.GetRadarIP()
+	res.RadarState.Name = radarCfg.RadarName
+	res.InitMetrics(radarCfg.GetRadarIP())
+	res.InitFromSettings(rc.settings)
+	res.StreamSink = rc.StreamSink
+	res.SetupWorkflow(res, serviceCfg, radarCfg)
+	return res
+}
+
+// Reload applies the configuration to the running brokers.  Only the radars
+// changed are rebuilt, added radars get a broker and the brokers of removed
+// radars stop.  An invalid configuration is rejected, and a configuration
+// failing to apply is rolled back, the brokers and configuration running
+// before the reload are kept as they were
+func (rc *UDPBrokersService) Reload(serviceCfg *servicemodel.Config) ([]servicemodel.RadarDiff, error) {
+	rc.reloadLock.Lock()
+	defer rc.reloadLock.Unlock()
+
+	if err := serviceCfg.Validate(); err != nil {
+		rc.Metrics.ReloadRejectedCount.Inc(1)
+		return nil, errors.Wrap(err, "invalid config")
+	}
+
+	previousCfg := rc.getChannelConfig()
+	diffs := serviceCfg.Diff(previousCfg)
+
+	if err := rc.apply(previousCfg, serviceCfg, diffs); err != nil {
+		rc.Metrics.RollbackCount.Inc(1)
+		return nil, errors.Wrap(err, "config rolled back")
+	}
+
+	rc.Metrics.ReloadCount.Inc(1)
+	return diffs, nil
+}
+
+// apply builds the brokers of the radars added off to the side, and only once
+// every radar of the configuration has a broker reconfigures the brokers of
+// the radars changed.  The brokers are then swapped in, in the order of the
+// configuration, together with the configuration (used to find the broker of
+// a datagram).  A failing apply leaves the running brokers as they were, the
+// brokers built are discarded and the brokers reconfigured get their
+// previous configuration back
+func (rc *UDPBrokersService) apply(
+	previousCfg *servicemodel.Config,
+	serviceCfg *servicemodel.Config,
+	diffs []servicemodel.RadarDiff,
+) (err error) {
+	var added []*UDPBroker
+	var reconfigured []servicemodel.RadarDiff
+
+	current := rc.getBrokers()
+	brokers := make(map[utils.IP4]*UDPBroker, len(current))
+	for _, udpBroker := range current {
+		brokers[udpBroker.GetRadarIP()] = udpBroker
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = errors.Errorf("%v", recovered)
+		}
+
+		if err != nil {
+			rc.undo(previousCfg, brokers, added, reconfigured)
+		}
+	}()
+
+	for _, diff := range diffs {
+		if diff.Change == servicemodel.RadarAdded {
+			udpBroker := rc.newBroker(serviceCfg, diff.Current)
+			added = append(added, udpBroker)
+			brokers[udpBroker.GetRadarIP()] = udpBroker
+		}
+	}
+
+	res := make([]*UDPBroker, 0, len(serviceCfg.Radars))
+	for _, radarCfg := range serviceCfg.Radars {
+		udpBroker, ok := brokers[radarCfg.GetRadarIP()]
+		if !ok {
+			return errors.Errorf("no broker for radar %s", radarCfg.GetRadarIP())
+		}
+		res = append(res, udpBroker)
+	}
+
+	var removed []*UDPBroker
+
+	for _, diff := range diffs {
+		switch diff.Change {
+		case servicemodel.RadarChanged:
+			if udpBroker, ok := brokers[diff.Current.GetRadarIP()]; ok {
+				reconfigured = append(reconfigured, diff)
+				udpBroker.Reconfigure(serviceCfg, diff.Current)
+			}
+
+		case servicemodel.RadarRemoved:
+			if udpBroker, ok := brokers[diff.Previous.GetRadarIP()]; ok {
+				removed = append(removed, udpBroker)
+			}
+		}
+	}
+
+	rc.brokersLock.Lock()
+	rc.Brokers = res
+	utils.GlobalState.Set(servicemodel.StateName, serviceCfg)
+	rc.brokersLock.Unlock()
+
+	for _, udpBroker := range added {
 		udpBroker.OnTerminate = rc.OnChannelTerminate
 		udpBroker.Run(udpBroker.GetRadarIP())
 	}
+
+	// The removed brokers no longer receive datagrams
+	rc.stopDetached(removed)
+
+	rc.Metrics.RadarAddedCount.Inc(int64(len(added)))
+	rc.Metrics.RadarChangedCount.Inc(int64(len(reconfigured)))
+	rc.Metrics.RadarRemovedCount.Inc(int64(len(removed)))
+	return nil
+}
+
+// undo discards the brokers built (never run) by a failing apply, and
+// reconfigures the brokers changed back to the previous configuration
+func (rc *UDPBrokersService) undo(
+	previousCfg *servicemodel.Config,
+	brokers map[utils.IP4]*UDPBroker,
+	added []*UDPBroker,
+	reconfigured []servicemodel.RadarDiff,
+) {
+	for _, udpBroker := range added {
+		udpBroker.Executor.Close()
+		udpBroker.closePipelineRecorder()
+	}
+
+	for _, diff := range reconfigured {
+		brokers[diff.Previous.GetRadarIP()].Reconfigure(previousCfg, diff.Previous)
+	}
+}
+
+// stopDetached stops the brokers no longer receiving datagrams, they are not
+// counted as terminated by AwaitStop
+func (rc *UDPBrokersService) stopDetached(brokers []*UDPBroker) {
+	for _, udpBroker := range brokers {
+		udpBroker.OnTerminate = func(*UDPBroker) {}
+		udpBroker.Stop()
+	}
+}
+
+func (rc *UDPBrokersService) getBrokers() []*UDPBroker {
+	rc.brokersLock.RLock()
+	defer rc.brokersLock.RUnlock()
+	return rc.Brokers
 }
 
 func (rc *UDPBrokersService) SetupStates(state *utils.State) {
@@ -86,6 +273,24 @@ func (rc *UDPBrokersService) SetupStates(state *utils.State) {
 	state.Set(interfaces.PhaseStateName, new(interfaces.PhaseState))
 }
 
+// ExecutePipelines executes the trigger pipeline of every radar, streams the
+// channel status of the radars and returns the combined channel calls
+func (rc *UDPBrokersService) ExecutePipelines(
+	now time.Time,
+	display triggerpipeline.ITriggerDisplay,
+) utils.Uint128 {
+	var res utils.Uint128
+
+	for _, broker := range rc.getBrokers() {
+		if broker.RadarState != nil {
+			calls := broker.RadarState.Execute(now, display)
+			broker.StreamChannelStatus(now, calls)
+			res = res.Or(calls)
+		}
+	}
+	return res
+}
+
 func (rc *UDPBrokersService) GetServiceName() string { return UDPBrokersServiceName }
 
 func (rc *UDPBrokersService) GetServiceNames() []string {
@@ -93,12 +298,18 @@ func (rc *UDPBrokersService) GetServiceNames() []string {
 }
 
 func (rc *UDPBrokersService) InitNoRadars(numberOfRadars int) {
-	rc.Brokers = make([]UDPBroker, numberOfRadars)
+	rc.Brokers = make([]*UDPBroker, numberOfRadars)
 }
 
+// Stop detaches from the UDP data service and stops the brokers, the
+// workflows of the brokers are closed (flushing their files and bins)
 func (rc *UDPBrokersService) Stop() {
-	for index := range rc.Brokers {
-		radar := &rc.Brokers[index]
+	if rc.dataService != nil {
+		rc.dataService.UnregisterReceiver(rc.receiverId)
+		rc.dataService = nil
+	}
+
+	for _, radar := range rc.getBrokers() {
 		radar.Stop()
 	}
 }
@@ -110,7 +321,8 @@ func (rc *UDPBrokersService) AwaitStop(sleepTime time.Duration) {
 }
 
 func (rc *UDPBrokersService) AttachTo(udp *service.UDPDataService) {
-	udp.RegisterReceiver(rc.OnData)
+	rc.dataService = udp
+	rc.receiverId = udp.RegisterReceiver(rc.OnData)
 }
 
 func (rc *UDPBrokersService) OnData(
@@ -119,9 +331,9 @@ func (rc *UDPBrokersService) OnData(
 	bytes []byte,
 ) {
 	ip4 := utils.IP4Builder.FromIP(addr.IP, addr.Port)
-	radarIndex := rc.getChannelConfig().GetRadarIndex(ip4)
+	radar := rc.getBroker(ip4)
 
-	if radarIndex == -1 {
+	if radar == nil {
 		dataService.Metrics.InvalidRadarSkipCount.Inc(1)
 		return
 	}
@@ -130,12 +342,25 @@ func (rc *UDPBrokersService) OnData(
 	msg.BufferLen = len(bytes)
 	msg.IPAddress = utils.IP4Builder.FromIP(addr.IP, addr.Port)
 	msg.CreateOn = time.Now()
+	msg.IsReplayed = false
 	copy(msg.Buffer[:], bytes)
 
-	radar := &rc.Brokers[radarIndex]
 	radar.SendMessage(msg)
 }
 
+// getBroker returns the broker of the radar, nil when the radar is not
+// configured.  The configuration and brokers are swapped together on reload
+func (rc *UDPBrokersService) getBroker(ip4 utils.IP4) *UDPBroker {
+	rc.brokersLock.RLock()
+	defer rc.brokersLock.RUnlock()
+
+	radarIndex := rc.getChannelConfig().GetRadarIndex(ip4)
+	if radarIndex == -1 || radarIndex >= len(rc.Brokers) {
+		return nil
+	}
+	return rc.Brokers[radarIndex]
+}
+
 func (rc *UDPBrokersService) OnChannelTerminate(channel *UDPBroker) {
 	rc.TerminateRefCount.Add(1)
 }
@@ -0,0 +1,251 @@
+package broker
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"rvpro3/radarvision.com/internal/constants"
+	"rvpro3/radarvision.com/internal/general"
+	"rvpro3/radarvision.com/internal/models/servicemodel"
+	"rvpro3/radarvision.com/internal/smartmicro/service"
+	"rvpro3/radarvision.com/utils"
+	"rvpro3/radarvision.com/utils/pcap"
+)
+
+const UDPCaptureServiceName = "UDP.Capture.Service"
+
+const udpCaptureFilePathTemplate = "udp.capture.file.path.template"
+const udpCaptureFileMaxMb = "udp.capture.file.max.mb"
+const udpCaptureQueueSize = "udp.capture.queue.size"
+const udpCaptureFlushMillis = "udp.capture.flush.millis"
+const udpCaptureRadar = "udp.capture.radar"
+
+var errCaptureNoDataService = errors.New("udp capture not started due to no UDP data service")
+
+// UDPCaptureService records the raw UDP datagrams received from the configured
+// radars to pcap files.  Radars are filtered with the indexed setting
+// udp.capture.radar-<ip> (default true).  The datagrams are written on a
+// separate goroutine so that a slow SD card does not hold up the UDP reader,
+// datagrams are dropped (and counted) when the queue is full
+type UDPCaptureService struct {
+	FilePathTemplate string
+	FileName         string
+	MaxFileBytes     int64
+	QueueSize        int
+	FlushInterval    utils.Milliseconds
+	Radars           []utils.IP4
+	CurrentErr       utils.ErrorLoggerMixin
+	Metrics          UDPCaptureMetrics `json:"-"`
+	writer           *pcap.Writer
+	captureChannel   chan *captureData
+	doneChannel      chan bool
+	stopOnce         sync.Once
+	capturePool      sync.Pool
+	dataService      *service.UDPDataService
+	receiverId       int
+}
+
+type UDPCaptureMetrics struct {
+	CaptureCount     *utils.Metric
+	CaptureBytes     *utils.Metric
+	CaptureSkipCount *utils.Metric
+	CaptureDrops     *utils.Metric
+	FileOpenCount    *utils.Metric
+	FileWriteErr     *utils.Metric
+	utils.MetricsInitMixin
+}
+
+type captureData struct {
+	CreateOn time.Time
+	Source   utils.IP4
+	Target   utils.IP4
+	Buffer   []byte
+}
+
+func (s *UDPCaptureService) InitFromSettings(settings *utils.Settings) {
+	s.FilePathTemplate = settings.Basic.Get(udpCaptureFilePathTemplate, "/media/SDLOGS/capture/udp-%s.pcap")
+	s.MaxFileBytes = int64(settings.Basic.GetInt(udpCaptureFileMaxMb, 100)) * utils.Megabyte
+	s.QueueSize = settings.Basic.GetInt(udpCaptureQueueSize, 64)
+	s.FlushInterval = settings.Basic.GetMilliseconds(udpCaptureFlushMillis, 1000)
+}
+
+func (s *UDPCaptureService) Start(state *utils.State, settings *utils.Settings) {
+	if !general.ServiceHelper.ShouldStart(state, settings, s) {
+		return
+	}
+
+	dataService, ok := state.Get(constants.UDPDataServiceName).(*service.UDPDataService)
+	if !ok {
+		s.CurrentErr.LogErrorAt(time.Now(), s.GetServiceName(), errCaptureNoDataService)
+		return
+	}
+
+	s.Metrics.InitMetrics(s.GetServiceName(), &s.Metrics)
+	s.initRadars(settings)
+
+	s.capturePool = sync.Pool{
+		New: func() interface{} {
+			return &captureData{}
+		},
+	}
+	s.captureChannel = make(chan *captureData, s.QueueSize)
+	s.doneChannel = make(chan bool)
+	s.dataService = dataService
+	s.receiverId = dataService.RegisterReceiver(s.OnData)
+
+	go s.execute()
+}
+
+// Stop stops receiving datagrams and closes the capture file, a Stop of a
+// service not started (or stopped) does nothing
+func (s *UDPCaptureService) Stop() {
+	if s.doneChannel == nil {
+		return
+	}
+
+	s.stopOnce.Do(func() {
+		s.dataService.UnregisterReceiver(s.receiverId)
+		close(s.doneChannel)
+	})
+}
+
+func (s *UDPCaptureService) GetServiceName() string {
+	return UDPCaptureServiceName
+}
+
+// initRadars determines the radars to capture from the channel configuration
+func (s *UDPCaptureService) initRadars(settings *utils.Settings) {
+	s.Radars = s.Radars[:0]
+
+	config, ok := utils.GlobalState.Get(servicemodel.StateName).(*servicemodel.Config)
+	if !ok {
+		return
+	}
+
+	for _, radarCfg := range config.Radars {
+		radarIP := radarCfg.GetRadarIP()
+		if settings.Indexed.GetBool(udpCaptureRadar, radarIP.String(), true) {
+			s.Radars = append(s.Radars, radarIP)
+		}
+	}
+}
+
+// IsCaptured returns true if datagrams from the radar should be recorded
+func (s *UDPCaptureService) IsCaptured(ip4 utils.IP4) bool {
+	for _, radarIP := range s.Radars {
+		if radarIP.Equals(ip4) {
+			return true
+		}
+	}
+	return false
+}
+
+// OnData is registered with the UDPDataService and is called on the UDP
+// reader goroutine, it must therefore not block
+func (s *UDPCaptureService) OnData(
+	dataService *service.UDPDataService,
+	addr net.UDPAddr,
+	bytes []byte,
+) {
+	source := utils.IP4Builder.FromUDPAddr(addr)
+	now := dataService.Now
+
+	if !s.IsCaptured(source) {
+		s.Metrics.CaptureSkipCount.IncAt(1, now)
+		return
+	}
+
+	if len(s.captureChannel) >= cap(s.captureChannel) {
+		s.Metrics.CaptureDrops.IncAt(1, now)
+		return
+	}
+
+	data := s.capturePool.Get().(*captureData)
+	data.CreateOn = now
+	data.Source = source
+	data.Target = dataService.ListenAddr
+	data.Buffer = append(data.Buffer[:0], bytes...)
+
+	s.captureChannel <- data
+}
+
+func (s *UDPCaptureService) execute() {
+	ticker := time.NewTicker(time.Duration(s.FlushInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case data := <-s.captureChannel:
+			s.write(data)
+			s.capturePool.Put(data)
+
+		case <-ticker.C:
+			s.flush()
+
+		case <-s.doneChannel:
+			s.closeFile()
+			return
+		}
+	}
+}
+
+func (s *UDPCaptureService) write(data *captureData) {
+	if s.writer != nil && s.writer.Written >= s.MaxFileBytes {
+		s.closeFile()
+	}
+
+	if s.writer == nil && !s.openFile(data.CreateOn) {
+		s.Metrics.FileWriteErr.IncAt(1, data.CreateOn)
+		return
+	}
+
+	err := s.writer.WritePacket(data.CreateOn, data.Source, data.Target, data.Buffer)
+	if err != nil {
+		s.Metrics.FileWriteErr.IncAt(1, data.CreateOn)
+		s.CurrentErr.LogErrorAt(data.CreateOn, s.GetServiceName(), err)
+		s.closeFile()
+		return
+	}
+
+	s.Metrics.CaptureCount.IncAt(1, data.CreateOn)
+	s.Metrics.CaptureBytes.IncAt(int64(len(data.Buffer)), data.CreateOn)
+}
+
+func (s *UDPCaptureService) openFile(now time.Time) bool {
+	var err error
+
+	s.FileName = fmt.Sprintf(s.FilePathTemplate, now.Format(utils.FileDateTimeMS))
+	if s.writer, err = pcap.Create(s.FileName); err != nil {
+		s.CurrentErr.LogErrorAt(now, s.GetServiceName(), err)
+		s.writer = nil
+		return false
+	}
+
+	s.CurrentErr.Clear()
+	s.Metrics.FileOpenCount.IncAt(1, now)
+	return true
+}
+
+func (s *UDPCaptureService) flush() {
+	if s.writer == nil {
+		return
+	}
+
+	if err := s.writer.Flush(); err != nil {
+		s.CurrentErr.LogErrorAt(time.Now(), s.GetServiceName(), err)
+	}
+}
+
+func (s *UDPCaptureService) closeFile() {
+	if s.writer == nil {
+		return
+	}
+
+	if err := s.writer.Close(); err != nil {
+		s.CurrentErr.LogErrorAt(time.Now(), s.GetServiceName(), err)
+	}
+	s.writer = nil
+}
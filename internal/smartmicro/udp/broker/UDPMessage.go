@@ -13,9 +13,12 @@ var messagePool = sync.Pool{
 	},
 }
 
+// UDPMessage is a datagram of a radar, a replayed (IsReplayed) datagram is
+// processed at its capture time CreateOn
 type UDPMessage struct {
-	CreateOn  time.Time
-	IPAddress utils.IP4
-	Buffer    [4000]byte
-	BufferLen int
+	CreateOn   time.Time
+	IPAddress  utils.IP4
+	Buffer     [4000]byte
+	BufferLen  int
+	IsReplayed bool
 }
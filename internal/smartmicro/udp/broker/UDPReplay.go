@@ -0,0 +1,131 @@
+package broker
+
+import (
+	"io"
+	"time"
+
+	"rvpro3/radarvision.com/utils"
+	"rvpro3/radarvision.com/utils/pcap"
+)
+
+// Replay feeds a captured datagram through the broker of the radar.  Unlike
+// OnData, the datagram is processed synchronously on the calling goroutine,
+// meaning that the brokers must have been started with StartReplay.
+// Replay returns false when the datagram is not from a configured radar
+func (rc *UDPBrokersService) Replay(source utils.IP4, createOn time.Time, bytes []byte) bool {
+	radar := rc.getBroker(source)
+
+	if radar == nil || len(bytes) > len(UDPMessage{}.Buffer) {
+		return false
+	}
+
+	msg := messagePool.Get().(*UDPMessage)
+	msg.BufferLen = len(bytes)
+	msg.IPAddress = source
+	msg.CreateOn = createOn
+	msg.IsReplayed = true
+	copy(msg.Buffer[:], bytes)
+
+	radar.startMsg(msg)
+	return true
+}
+
+// UDPReplay reads a pcap/pcapng capture and replays the datagrams through
+// the UDPBrokersService.  A Speed of 1 replays at the original timing, 2 at
+// double the speed, etc. A Speed of 0 (or less) replays as fast as possible
+type UDPReplay struct {
+	Brokers *UDPBrokersService
+	Speed   float64
+	Radars  []utils.IP4
+	Metrics UDPReplayMetrics `json:"-"`
+}
+
+type UDPReplayMetrics struct {
+	ReplayCount     *utils.Metric
+	ReplayBytes     *utils.Metric
+	FilterSkipCount *utils.Metric
+	RadarSkipCount  *utils.Metric
+	utils.MetricsInitMixin
+}
+
+func (r *UDPReplay) InitMetrics(sectionName string) {
+	r.Metrics.InitMetrics(sectionName, &r.Metrics)
+}
+
+// ReplayFile replays the capture file up until the end of the file
+func (r *UDPReplay) ReplayFile(fileName string) error {
+	reader, err := pcap.Open(fileName)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	return r.ReplayFrom(reader)
+}
+
+// ReplayFrom replays the packets from the reader up until io.EOF
+func (r *UDPReplay) ReplayFrom(reader *pcap.Reader) error {
+	var firstOn time.Time
+	var startOn time.Time
+
+	for {
+		packet, err := reader.Next()
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if !r.isReplayed(packet.Source) {
+			r.Metrics.FilterSkipCount.Inc(1)
+			continue
+		}
+
+		if firstOn.IsZero() {
+			firstOn = packet.Timestamp
+			startOn = time.Now()
+		}
+
+		r.await(startOn, packet.Timestamp.Sub(firstOn))
+
+		// The capture time, so that the activities see the original timing
+		if !r.Brokers.Replay(packet.Source, packet.Timestamp, packet.Payload) {
+			r.Metrics.RadarSkipCount.Inc(1)
+			continue
+		}
+
+		r.Metrics.ReplayCount.Inc(1)
+		r.Metrics.ReplayBytes.Inc(int64(len(packet.Payload)))
+	}
+}
+
+func (r *UDPReplay) isReplayed(source utils.IP4) bool {
+	if len(r.Radars) == 0 {
+		return true
+	}
+
+	for _, radarIP := range r.Radars {
+		if radarIP.IsEqualIP(source) {
+			return true
+		}
+	}
+	return false
+}
+
+// await sleeps until the packet offset (scaled by Speed) is reached
+func (r *UDPReplay) await(startOn time.Time, offset time.Duration) {
+	if r.Speed <= 0 || offset <= 0 {
+		return
+	}
+
+	target := startOn.Add(time.Duration(float64(offset) / r.Speed))
+	if wait := time.Until(target); wait > 0 {
+		time.Sleep(wait)
+	}
+}
@@ -0,0 +1,54 @@
+package broker
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/internal/models/servicemodel"
+	"rvpro3/radarvision.com/utils"
+	"rvpro3/radarvision.com/utils/pcap"
+)
+
+func TestUDPReplay_ReplayFrom(t *testing.T) {
+	config := servicemodel.TestBuilder.Build()
+	utils.GlobalState.Set(servicemodel.StateName, config)
+
+	state := &utils.State{}
+	state.Init()
+
+	settings := &utils.Settings{}
+	settings.Init()
+
+	brokers := new(UDPBrokersService)
+	brokers.InitFromSettings(settings)
+	brokers.StartReplay(state, settings)
+
+	radar := config.Radars[0].GetRadarIP()
+	unknown := utils.IP4Builder.FromString("10.0.0.99:55555")
+	local := utils.IP4Builder.FromString("127.0.0.1:55555")
+	now := time.Now()
+
+	var buffer bytes.Buffer
+	writer, err := pcap.NewWriter(&buffer)
+	assert.NoError(t, err)
+	assert.NoError(t, writer.WritePacket(now, radar, local, newSegment(1, 7, 2, "AB").Buffer))
+	assert.NoError(t, writer.WritePacket(now.Add(time.Millisecond), unknown, local, newSegment(2, 7, 2, "CD").Buffer))
+	assert.NoError(t, writer.WritePacket(now.Add(2*time.Millisecond), radar, local, newSegment(2, 7, 2, "CD").Buffer))
+	assert.NoError(t, writer.Flush())
+
+	reader, err := pcap.NewReader(&buffer)
+	assert.NoError(t, err)
+
+	replay := &UDPReplay{Brokers: brokers}
+	replay.InitMetrics("Test.UDP.Replay")
+	assert.NoError(t, replay.ReplayFrom(reader))
+
+	udpBroker := brokers.Brokers[0]
+	assert.Equal(t, int64(2), replay.Metrics.ReplayCount.Value)
+	assert.Equal(t, int64(1), replay.Metrics.RadarSkipCount.Value)
+	assert.Equal(t, int64(2), udpBroker.Metrics.ReceivedCount.Value)
+	assert.Equal(t, int64(1), udpBroker.Segments.Metrics.SegmentAssembledCount.Value)
+	assert.WithinDuration(t, now.Add(2*time.Millisecond), udpBroker.Now, time.Microsecond, "processed at the capture time")
+}
@@ -93,6 +93,13 @@ func (w *Workflow) Process(now time.Time, payload []byte) {
 	w.Metrics.TotalDuration.IncAt(duration, endOn)
 }
 
+// Close closes the activities of the workflow
+func (w *Workflow) Close() {
+	for _, activity := range w.Activities {
+		activity.Close()
+	}
+}
+
 func (w *Workflow) Drop(now time.Time, payload []byte) {
 	w.Metrics.DroppedCount.IncAt(1, now)
 	w.Metrics.DroppedBytes.IncAt(int64(len(payload)), now)
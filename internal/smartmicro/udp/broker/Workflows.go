@@ -15,13 +15,16 @@ type Workflows struct {
 }
 
 type WorkflowExecutorMetrics struct {
-	ProcessedCount       *utils.Metric
-	ProcessedBytes       *utils.Metric
-	ProcessedDuration    *utils.Metric
-	ProcessedMinDuration *utils.Metric
-	ProcessedMaxDuration *utils.Metric
-	SkippedCount         *utils.Metric
-	SkippedBytes         *utils.Metric
+	ProcessedCount             *utils.Metric
+	ProcessedBytes             *utils.Metric
+	ProcessedDuration          *utils.Metric
+	ProcessedMinDuration       *utils.Metric
+	ProcessedMaxDuration       *utils.Metric
+	ProcessedRate              *utils.Metric
+	ProcessedDurationWindow    *utils.Metric
+	ProcessedDurationHistogram *utils.Metric
+	SkippedCount               *utils.Metric
+	SkippedBytes               *utils.Metric
 	utils.MetricsInitMixin
 }
 
@@ -56,6 +59,13 @@ func (we *Workflows) Workflow(portIdentifier uint32) interfaces.IUDPWorkflow {
 	return res
 }
 
+// Close closes the workflows, before they are replaced or the broker stops
+func (we *Workflows) Close() {
+	for _, workflow := range we.Workflows {
+		workflow.Close()
+	}
+}
+
 func (we *Workflows) Init(radarIP utils.IP4) {
 	we.RadarIP = radarIP
 	we.Metrics.InitMetrics(fmt.Sprintf("Workflow.Executor-%s", radarIP), &we.Metrics)
@@ -100,6 +110,9 @@ func (we *Workflows) onProcess(now time.Time, workflow interfaces.IUDPWorkflow,
 	we.Metrics.ProcessedMinDuration.SetIfLessAt(duration, now)
 	we.Metrics.ProcessedMaxDuration.SetIfMoreAt(duration, now)
 	we.Metrics.ProcessedDuration.IncAt(duration, now)
+	we.Metrics.ProcessedRate.CountAt(1, now)
+	we.Metrics.ProcessedDurationWindow.ObserveAt(duration, now)
+	we.Metrics.ProcessedDurationHistogram.ObserveAt(duration, now)
 }
 
 func (we *Workflows) onSkip(now time.Time, bytes []byte) {
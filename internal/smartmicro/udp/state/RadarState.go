@@ -2,8 +2,10 @@ package state
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
+	"rvpro3/radarvision.com/internal/smartmicro/port"
 	"rvpro3/radarvision.com/internal/smartmicro/triggerpipeline"
 	"rvpro3/radarvision.com/utils"
 )
@@ -14,10 +16,29 @@ type RadarState struct {
 	Name             string
 	IsManualFailSafe bool
 	IsAutoFailSafe   bool
+	Health           RadarHealth `json:"-"`
 	Pipeline         triggerpipeline.TriggerPipeline
 	Serial           uint32                               `json:"-"`
 	FailSafe         triggerpipeline.ITriggerPipelineItem `json:"-"`
 	triggerState     triggerState
+	pipelineLock     sync.Mutex
+	healthLock       sync.Mutex
+}
+
+// RadarHealth is the health reported by the radar on the diagnostics port,
+// the temperature in °C, the supply voltage in V, the misalignment in
+// degrees and the blockage in percent.  IsDegraded (and Reasons) is the
+// health evaluated against the limits of the radar
+type RadarHealth struct {
+	On            time.Time
+	Status        port.DiagnosticsStatus
+	Temperature   float32
+	SupplyVoltage float32
+	Misalignment  float32
+	Blockage      uint8
+	ErrorCodes    []uint16
+	IsDegraded    bool
+	Reasons       []string
 }
 
 func (s *RadarState) ReplaceSerial(serial uint32) string {
@@ -28,6 +49,123 @@ func (s *RadarState) ReplaceSerial(serial uint32) string {
 	return s.SerialStr
 }
 
+// Execute executes the trigger pipeline, and updates IsAutoFailSafe from the
+// failsafe (the radar being silent for too long)
+func (s *RadarState) Execute(now time.Time, display triggerpipeline.ITriggerDisplay) utils.Uint128 {
+	s.pipelineLock.Lock()
+	defer s.pipelineLock.Unlock()
+
+	res := s.Pipeline.Execute(now, utils.Uint128{}, display)
+
+	if failSafe, ok := s.FailSafe.(*triggerpipeline.RadarFailsafePipelineItem); ok {
+		s.IsAutoFailSafe = failSafe.IsActive
+	}
+	return res
+}
+
+// SetTrigger sets the trigger of the (named) pipeline item of the radar
+// between two executions.  It returns false when the item is absent or the
+// trigger is unchanged
+func (s *RadarState) SetTrigger(name string, now time.Time, hi uint64, lo uint64) bool {
+	s.pipelineLock.Lock()
+	defer s.pipelineLock.Unlock()
+
+	item := s.Pipeline.Find(name, s.IP)
+	if item == nil {
+		return false
+	}
+	return item.SetTrigger(now, hi, lo)
+}
+
+// SetActivityOn marks the radar as sending (on now) for the failsafe
+func (s *RadarState) SetActivityOn(now time.Time) {
+	s.pipelineLock.Lock()
+	defer s.pipelineLock.Unlock()
+
+	if s.FailSafe != nil {
+		s.FailSafe.SetUpdateOn(now)
+	}
+}
+
+// GetAutoFailSafe returns whether the failsafe of the radar is active, the
+// radar being silent for too long or degraded
+func (s *RadarState) GetAutoFailSafe() bool {
+	s.pipelineLock.Lock()
+	defer s.pipelineLock.Unlock()
+
+	if failSafe, ok := s.FailSafe.(*triggerpipeline.RadarFailsafePipelineItem); ok {
+		return failSafe.IsActive
+	}
+	return s.IsAutoFailSafe
+}
+
+// GetActivityOn returns when the radar last sent, zero when it never did
+func (s *RadarState) GetActivityOn() time.Time {
+	s.pipelineLock.Lock()
+	defer s.pipelineLock.Unlock()
+
+	if s.FailSafe == nil {
+		return time.Time{}
+	}
+	return s.FailSafe.GetUpdateOn()
+}
+
+// SetHealth updates the health of the radar, and fails safe while degraded
+func (s *RadarState) SetHealth(health RadarHealth) {
+	s.healthLock.Lock()
+	s.Health = health
+	s.healthLock.Unlock()
+
+	s.pipelineLock.Lock()
+	defer s.pipelineLock.Unlock()
+
+	if failSafe, ok := s.FailSafe.(*triggerpipeline.RadarFailsafePipelineItem); ok {
+		failSafe.IsDegraded = health.IsDegraded
+	}
+}
+
+func (s *RadarState) GetHealth() RadarHealth {
+	s.healthLock.Lock()
+	defer s.healthLock.Unlock()
+
+	return s.Health
+}
+
+// DetachRecorder removes (and returns) the recorder of the pipeline, between
+// two executions
+func (s *RadarState) DetachRecorder() triggerpipeline.ITriggerRecorder {
+	s.pipelineLock.Lock()
+	defer s.pipelineLock.Unlock()
+
+	res := s.Pipeline.Recorder
+	s.Pipeline.Recorder = nil
+	return res
+}
+
+// ReplacePipeline swaps in the (rebuilt) pipeline between two executions,
+// carrying over the state of the current items so the channel calls continue
+func (s *RadarState) ReplacePipeline(
+	pipeline *triggerpipeline.TriggerPipeline,
+	failSafe triggerpipeline.ITriggerPipelineItem,
+) {
+	s.pipelineLock.Lock()
+	defer s.pipelineLock.Unlock()
+
+	pipeline.CarryOver(&s.Pipeline)
+	if pipeline.Recorder == nil {
+		pipeline.Recorder = s.Pipeline.Recorder
+	}
+
+	previous, isPreviousOk := s.FailSafe.(*triggerpipeline.RadarFailsafePipelineItem)
+	current, ok := failSafe.(*triggerpipeline.RadarFailsafePipelineItem)
+	if ok && isPreviousOk {
+		current.IsDegraded = previous.IsDegraded
+	}
+
+	s.Pipeline.Replace(pipeline)
+	s.FailSafe = failSafe
+}
+
 type radarStateHelper struct {
 }
 
@@ -0,0 +1,23 @@
+package diagnostics
+
+import (
+	"time"
+
+	"rvpro3/radarvision.com/internal/smartmicro/interfaces"
+)
+
+// HealthActivity runs the diagnostics Workflow in the diagnostics workflow
+// of the broker
+type HealthActivity struct {
+	interfaces.UDPActivityMixin
+	Health Workflow
+}
+
+func (a *HealthActivity) Init(workflow interfaces.IUDPWorkflow, index int, fullName string) {
+	a.InitBase(workflow, index, fullName)
+	a.Health.Init(workflow)
+}
+
+func (a *HealthActivity) Process(now time.Time, bytes []byte) {
+	a.Health.Process(now, bytes)
+}
@@ -1,18 +1,144 @@
 package diagnostics
 
 import (
+	"fmt"
 	"time"
 
+	"github.com/rs/zerolog/log"
 	"rvpro3/radarvision.com/internal/smartmicro/interfaces"
+	"rvpro3/radarvision.com/internal/smartmicro/port"
+	"rvpro3/radarvision.com/internal/smartmicro/udp/state"
+	"rvpro3/radarvision.com/utils"
 )
 
+// degradedStatus is the status flags degrading the health, interference
+// comes and goes with the traffic so it is only reported
+const degradedStatus = port.DsBlockage | port.DsMisalignment | port.DsTemperature | port.DsVoltage | port.DsHardware
+
+// HealthLimits is the range of a healthy radar, the temperature in °C, the
+// supply voltage in V, the misalignment in degrees and the blockage in percent
+type HealthLimits struct {
+	MinTemperature  float32
+	MaxTemperature  float32
+	MinVoltage      float32
+	MaxVoltage      float32
+	MaxMisalignment float32
+	MaxBlockage     uint8
+}
+
+// Evaluate sets IsDegraded and the Reasons of the health
+func (l *HealthLimits) Evaluate(health *state.RadarHealth) {
+	health.Reasons = nil
+
+	if status := health.Status & degradedStatus; status != 0 {
+		health.Reasons = append(health.Reasons, "status: "+status.String())
+	}
+
+	if health.Temperature < l.MinTemperature || health.Temperature > l.MaxTemperature {
+		health.Reasons = append(health.Reasons, fmt.Sprintf("temperature: %.1f", health.Temperature))
+	}
+
+	if health.SupplyVoltage < l.MinVoltage || health.SupplyVoltage > l.MaxVoltage {
+		health.Reasons = append(health.Reasons, fmt.Sprintf("voltage: %.2f", health.SupplyVoltage))
+	}
+
+	if health.Misalignment > l.MaxMisalignment || health.Misalignment < -l.MaxMisalignment {
+		health.Reasons = append(health.Reasons, fmt.Sprintf("misalignment: %.2f", health.Misalignment))
+	}
+
+	if health.Blockage > l.MaxBlockage {
+		health.Reasons = append(health.Reasons, fmt.Sprintf("blockage: %d", health.Blockage))
+	}
+
+	health.IsDegraded = len(health.Reasons) > 0
+}
+
+// Workflow decodes the health of the radar from the diagnostics port into
+// the RadarState, a degraded health fails the radar safe
 type Workflow struct {
-	Parent interfaces.IUDPWorkflowParent
+	Parent     interfaces.IUDPWorkflowParent
+	Limits     HealthLimits
+	RadarState *state.RadarState `json:"-"`
+	Metrics    WorkflowMetrics   `json:"-"`
+	isDegraded bool
+}
+
+type WorkflowMetrics struct {
+	ProcessCount       *utils.Metric
+	UnsupportedVersion *utils.Metric
+	DegradedCount      *utils.Metric
+	RecoveredCount     *utils.Metric
+	utils.MetricsInitMixin
 }
 
 func (w *Workflow) Init(p interfaces.IUDPWorkflowParent) {
 	w.Parent = p
+
+	radarIP := p.GetRadarIP()
+	ip := radarIP.String()
+	gs := &utils.GlobalSettings
+
+	w.Metrics.InitMetrics(interfaces.GetUDPRadarMetric(radarIP)+".Diagnostics.Workflow", &w.Metrics)
+
+	w.Limits = HealthLimits{
+		MinTemperature:  float32(gs.Indexed.GetFloat("radar.health.temperature.min", ip, -40)),
+		MaxTemperature:  float32(gs.Indexed.GetFloat("radar.health.temperature.max", ip, 85)),
+		MinVoltage:      float32(gs.Indexed.GetFloat("radar.health.voltage.min", ip, 9)),
+		MaxVoltage:      float32(gs.Indexed.GetFloat("radar.health.voltage.max", ip, 32)),
+		MaxMisalignment: float32(gs.Indexed.GetFloat("radar.health.misalignment.max", ip, 2)),
+		MaxBlockage:     uint8(gs.Indexed.GetInt("radar.health.blockage.max", ip, 50)),
+	}
+
+	if w.RadarState == nil {
+		w.RadarState = state.RadarStateHelper.GetOrSet(radarIP)
+	}
 }
 
 func (w *Workflow) Process(time time.Time, bytes []byte) {
+	diagnostics := port.DiagnosticsReader{}
+	diagnostics.Init(bytes)
+
+	if !diagnostics.IsSupported() {
+		w.Metrics.UnsupportedVersion.IncAt(1, time)
+		return
+	}
+
+	w.Metrics.ProcessCount.IncAt(1, time)
+
+	health := w.Decode(time, &diagnostics)
+	w.RadarState.SetHealth(health)
+
+	if health.IsDegraded == w.isDegraded {
+		return
+	}
+	w.isDegraded = health.IsDegraded
+
+	if health.IsDegraded {
+		w.Metrics.DegradedCount.IncAt(1, time)
+		log.Warn().
+			Str("radar", w.Parent.GetRadarIP().String()).
+			Strs("reasons", health.Reasons).
+			Msg("Workflow.Process: radar health degraded, failing safe")
+	} else {
+		w.Metrics.RecoveredCount.IncAt(1, time)
+		log.Info().
+			Str("radar", w.Parent.GetRadarIP().String()).
+			Msg("Workflow.Process: radar health recovered")
+	}
+}
+
+// Decode returns the health of the message, evaluated against the Limits
+func (w *Workflow) Decode(now time.Time, diagnostics *port.DiagnosticsReader) state.RadarHealth {
+	res := state.RadarHealth{
+		On:            utils.Time.Correct(now),
+		Status:        diagnostics.GetStatus(),
+		Temperature:   diagnostics.GetTemperature(),
+		SupplyVoltage: diagnostics.GetSupplyVoltage(),
+		Misalignment:  diagnostics.GetMisalignment(),
+		Blockage:      diagnostics.GetBlockage(),
+		ErrorCodes:    diagnostics.GetErrorCodes(),
+	}
+
+	w.Limits.Evaluate(&res)
+	return res
 }
@@ -0,0 +1,78 @@
+package diagnostics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/internal/smartmicro/port"
+	"rvpro3/radarvision.com/internal/smartmicro/triggerpipeline"
+	"rvpro3/radarvision.com/internal/smartmicro/udp/state"
+	"rvpro3/radarvision.com/utils"
+)
+
+type testParent struct{}
+
+func (testParent) GetRadarIP() utils.IP4 {
+	return utils.IP4Builder.FromString("192.168.11.12")
+}
+
+func newTestDiagnostics(status port.DiagnosticsStatus, temperature int16, blockage uint8) []byte {
+	diagnostics := port.NewDiagnostics()
+	diagnostics.Header.Status = status
+	diagnostics.Header.Temperature = temperature
+	diagnostics.Header.SupplyVoltage = 12000
+	diagnostics.Header.Blockage = blockage
+	return diagnostics.SaveAsBytes()
+}
+
+func TestWorkflow_Process(t *testing.T) {
+	radarState := &state.RadarState{}
+	failSafe := &triggerpipeline.RadarFailsafePipelineItem{NoRadarActivitySecs: 30}
+	failSafe.Name = triggerpipeline.Failsafe
+	failSafe.SetChannels.Lo = 0x3
+	radarState.Pipeline.AddItem(failSafe)
+	radarState.FailSafe = failSafe
+
+	workflow := Workflow{RadarState: radarState}
+	workflow.Init(testParent{})
+
+	now := time.Now()
+	failSafe.SetUpdateOn(now)
+
+	workflow.Process(now, newTestDiagnostics(port.DsInterference, 355, 10))
+	assert.Equal(t, utils.Uint128{}, radarState.Execute(now, nil))
+	assert.False(t, radarState.IsAutoFailSafe)
+	assert.False(t, radarState.GetHealth().IsDegraded, "interference is only reported")
+	assert.InDelta(t, 35.5, radarState.GetHealth().Temperature, 0.001)
+
+	// Still sending, but blocked and too hot
+	workflow.Process(now, newTestDiagnostics(port.DsBlockage, 900, 80))
+	assert.Equal(t, uint64(0x3), radarState.Execute(now, nil).Lo)
+	assert.True(t, radarState.IsAutoFailSafe)
+
+	health := radarState.GetHealth()
+	assert.True(t, health.IsDegraded)
+	assert.Equal(t, []string{"status: blockage", "temperature: 90.0", "blockage: 80"}, health.Reasons)
+	assert.Equal(t, int64(1), workflow.Metrics.DegradedCount.Value)
+
+	workflow.Process(now, newTestDiagnostics(0, 355, 10))
+	assert.Equal(t, utils.Uint128{}, radarState.Execute(now, nil))
+	assert.False(t, radarState.IsAutoFailSafe)
+	assert.Equal(t, int64(1), workflow.Metrics.RecoveredCount.Value)
+}
+
+func TestHealthLimits_Evaluate(t *testing.T) {
+	limits := HealthLimits{MinTemperature: -40, MaxTemperature: 85, MinVoltage: 9, MaxVoltage: 32, MaxMisalignment: 2, MaxBlockage: 50}
+
+	health := state.RadarHealth{Temperature: 20, SupplyVoltage: 8.5, Misalignment: -2.5}
+	limits.Evaluate(&health)
+	assert.True(t, health.IsDegraded)
+	assert.Equal(t, []string{"voltage: 8.50", "misalignment: -2.50"}, health.Reasons)
+
+	health.SupplyVoltage = 24
+	health.Misalignment = 1
+	limits.Evaluate(&health)
+	assert.False(t, health.IsDegraded)
+	assert.Empty(t, health.Reasons)
+}
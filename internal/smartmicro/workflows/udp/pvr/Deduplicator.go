@@ -0,0 +1,46 @@
+package pvr
+
+import (
+	"time"
+)
+
+type vehicleKey struct {
+	ObjectId int
+	Counter  int
+}
+
+// Deduplicator drops the vehicles repeated across the PVR frames of the
+// radar.  A vehicle is its object id and counter, seen again within Window
+// it is a repeat; beyond Window the radar has reused the object id and the
+// counter wrapped, so it is a new vehicle
+type Deduplicator struct {
+	Window time.Duration
+	seen   map[vehicleKey]time.Time
+}
+
+// IsNew returns whether the vehicle is not a repeat, and remembers it
+func (d *Deduplicator) IsNew(now time.Time, objectId int, counter int) bool {
+	if d.seen == nil {
+		d.seen = make(map[vehicleKey]time.Time)
+	}
+
+	key := vehicleKey{ObjectId: objectId, Counter: counter}
+	seenOn, ok := d.seen[key]
+	d.seen[key] = now
+
+	return !ok || now.Sub(seenOn) > d.Window
+}
+
+// Expire forgets the vehicles not seen within Window
+func (d *Deduplicator) Expire(now time.Time) {
+	for key, seenOn := range d.seen {
+		if now.Sub(seenOn) > d.Window {
+			delete(d.seen, key)
+		}
+	}
+}
+
+// Len returns the number of vehicles remembered
+func (d *Deduplicator) Len() int {
+	return len(d.seen)
+}
@@ -0,0 +1,25 @@
+package pvr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeduplicator_IsNew(t *testing.T) {
+	dedup := Deduplicator{Window: 5 * time.Second}
+	on := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+
+	assert.True(t, dedup.IsNew(on, 7, 1))
+	assert.False(t, dedup.IsNew(on.Add(time.Second), 7, 1), "repeated in the next frame")
+	assert.True(t, dedup.IsNew(on.Add(time.Second), 7, 2), "the next vehicle of the object id")
+	assert.True(t, dedup.IsNew(on.Add(time.Second), 8, 1))
+
+	// Seen again on 1s, so a repeat until 6s
+	assert.False(t, dedup.IsNew(on.Add(6*time.Second), 7, 1))
+	assert.True(t, dedup.IsNew(on.Add(12*time.Second), 7, 1), "the object id reused")
+
+	dedup.Expire(on.Add(12 * time.Second))
+	assert.Equal(t, 1, dedup.Len())
+}
@@ -0,0 +1,31 @@
+package pvr
+
+import (
+	"time"
+
+	"rvpro3/radarvision.com/internal/smartmicro/interfaces"
+)
+
+// RecordActivity runs the PVR Workflow in the PVR workflow of the broker,
+// the factors and units come from the configuration
+type RecordActivity struct {
+	interfaces.UDPActivityMixin
+	SpeedFactor    float64
+	SpeedUnit      string
+	DistanceFactor float64
+	DistanceUnit   string
+	Record         Workflow
+}
+
+func (a *RecordActivity) Init(workflow interfaces.IUDPWorkflow, index int, fullName string) {
+	a.InitBase(workflow, index, fullName)
+	a.Record.SpeedFactor = a.SpeedFactor
+	a.Record.SpeedUnit = a.SpeedUnit
+	a.Record.DistanceFactor = a.DistanceFactor
+	a.Record.DistanceUnit = a.DistanceUnit
+	a.Record.Init(workflow)
+}
+
+func (a *RecordActivity) Process(now time.Time, bytes []byte) {
+	a.Record.Process(now, bytes)
+}
@@ -3,16 +3,115 @@ package pvr
 import (
 	"time"
 
+	"rvpro3/radarvision.com/internal/services/pvrhistory"
 	"rvpro3/radarvision.com/internal/smartmicro/interfaces"
+	"rvpro3/radarvision.com/internal/smartmicro/port"
+	"rvpro3/radarvision.com/utils"
 )
 
+const pvrDedupWindow = "radar.pvr.dedup.window"
+
+// Workflow records the vehicles of the per vehicle record of the radar, once
+// each (see Deduplicator), into the PVR history when the service is started.
+// The speed (m/s) is converted by SpeedFactor to SpeedUnit and the length
+// (m) by DistanceFactor to DistanceUnit
 type Workflow struct {
-	Parent interfaces.IUDPWorkflowParent
+	Parent         interfaces.IUDPWorkflowParent
+	SpeedFactor    float64
+	SpeedUnit      string
+	DistanceFactor float64
+	DistanceUnit   string
+	Dedup          Deduplicator
+	Metrics        WorkflowMetrics `json:"-"`
+}
+
+type WorkflowMetrics struct {
+	ProcessCount       *utils.Metric
+	UnsupportedVersion *utils.Metric
+	VehicleCount       *utils.Metric
+	DuplicateCount     *utils.Metric
+	utils.MetricsInitMixin
 }
 
 func (w *Workflow) Init(p interfaces.IUDPWorkflowParent) {
 	w.Parent = p
+
+	radarIP := p.GetRadarIP()
+	gs := &utils.GlobalSettings
+
+	w.Metrics.InitMetrics(interfaces.GetUDPRadarMetric(radarIP)+".PVR.Workflow", &w.Metrics)
+	w.Dedup.Window = gs.Indexed.GetDurationMs(pvrDedupWindow, radarIP.String(), 10000)
+
+	if w.SpeedFactor == 0 {
+		w.SpeedFactor = 1
+	}
+
+	if w.SpeedUnit == "" {
+		w.SpeedUnit = "mps"
+	}
+
+	if w.DistanceFactor == 0 {
+		w.DistanceFactor = 1
+	}
+
+	if w.DistanceUnit == "" {
+		w.DistanceUnit = "m"
+	}
 }
 
 func (w *Workflow) Process(time time.Time, bytes []byte) {
+	pvr := port.PVRReader{}
+	pvr.Init(bytes)
+
+	if !pvr.IsSupported() {
+		w.Metrics.UnsupportedVersion.IncAt(1, time)
+		return
+	}
+
+	w.Metrics.ProcessCount.IncAt(1, time)
+
+	recs := w.Decode(time, &pvr)
+	w.Dedup.Expire(time)
+
+	if len(recs) == 0 {
+		return
+	}
+	w.Metrics.VehicleCount.IncAt(int64(len(recs)), time)
+
+	// The events are inserted on the writer goroutine of the history
+	if history, ok := utils.GlobalState.Get(pvrhistory.PVRHistoryServiceName).(*pvrhistory.PVRHistoryService); ok {
+		history.Queue(time, recs)
+	}
+}
+
+// Decode returns the vehicles of the message not yet recorded, in the units
+// of the configuration
+func (w *Workflow) Decode(now time.Time, pvr *port.PVRReader) []*pvrhistory.PVREventRec {
+	nofObjects := int(pvr.GetNofObjects())
+	res := make([]*pvrhistory.PVREventRec, 0, nofObjects)
+	radarIP := w.Parent.GetRadarIP().String()
+	on := utils.Time.Correct(now).UnixMilli()
+
+	for idx := 0; idx < nofObjects; idx++ {
+		objectId := int(pvr.GetObjectId(idx))
+		counter := int(pvr.GetCounter(idx))
+
+		if !w.Dedup.IsNew(now, objectId, counter) {
+			w.Metrics.DuplicateCount.IncAt(1, now)
+			continue
+		}
+
+		res = append(res, &pvrhistory.PVREventRec{
+			RadarIP:  radarIP,
+			On:       on,
+			ObjectId: objectId,
+			Counter:  counter,
+			Zone:     int(pvr.GetZone(idx)),
+			Class:    pvr.GetObjectClass(idx).String(),
+			Speed:    float64(pvr.GetSpeed(idx)) * w.SpeedFactor,
+			Heading:  float64(pvr.GetHeading(idx)),
+			Length:   float64(pvr.GetLength(idx)) * w.DistanceFactor,
+		})
+	}
+	return res
 }
@@ -0,0 +1,31 @@
+package statistics
+
+import (
+	"time"
+
+	"rvpro3/radarvision.com/internal/smartmicro/interfaces"
+)
+
+// AggregateActivity runs the statistics Workflow in the statistics workflow
+// of the broker, SpeedFactor and SpeedUnit come from the configuration
+type AggregateActivity struct {
+	interfaces.UDPActivityMixin
+	SpeedFactor float64
+	SpeedUnit   string
+	Aggregate   Workflow
+}
+
+func (a *AggregateActivity) Init(workflow interfaces.IUDPWorkflow, index int, fullName string) {
+	a.InitBase(workflow, index, fullName)
+	a.Aggregate.SpeedFactor = a.SpeedFactor
+	a.Aggregate.SpeedUnit = a.SpeedUnit
+	a.Aggregate.Init(workflow)
+}
+
+func (a *AggregateActivity) Process(now time.Time, bytes []byte) {
+	a.Aggregate.Process(now, bytes)
+}
+
+func (a *AggregateActivity) Close() {
+	a.Aggregate.Close()
+}
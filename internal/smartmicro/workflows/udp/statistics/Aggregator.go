@@ -0,0 +1,164 @@
+package statistics
+
+import (
+	"sort"
+	"time"
+
+	"rvpro3/radarvision.com/internal/smartmicro/port"
+)
+
+// StatisticsInterval is the statistics of a zone and class reported by the
+// radar over an interval, the speed in the speed unit, the occupancy in
+// percent, the headway and the gap in seconds.  The Has flags are set for
+// the modes reported
+type StatisticsInterval struct {
+	Zone         int
+	Class        port.ObjectClassType
+	Volume       int
+	Occupancy    float64
+	AvgSpeed     float64
+	Headway      float64
+	Gap          float64
+	HasOccupancy bool
+	HasAvgSpeed  bool
+	HasHeadway   bool
+	HasGap       bool
+}
+
+type statisticsKey struct {
+	Zone  int
+	Class port.ObjectClassType
+}
+
+// StatisticsBin is the statistics of a zone and class over a bin.  The
+// volume is summed, the occupancy weighted by the interval time, the speed,
+// headway and gap by the volume
+type StatisticsBin struct {
+	Zone      int
+	Class     port.ObjectClassType
+	Volume    int
+	Occupancy float64
+	AvgSpeed  float64
+	Headway   float64
+	Gap       float64
+	Intervals int
+
+	occupancySecs float64
+	speedVolume   int
+	headwayVolume int
+	gapVolume     int
+}
+
+func (b *StatisticsBin) add(interval *StatisticsInterval, intervalSecs float64) {
+	b.Intervals++
+	b.Volume += interval.Volume
+
+	if interval.HasOccupancy {
+		b.Occupancy = weighted(b.Occupancy, b.occupancySecs, interval.Occupancy, intervalSecs)
+		b.occupancySecs += intervalSecs
+	}
+
+	if interval.HasAvgSpeed && interval.Volume > 0 {
+		b.AvgSpeed = weighted(b.AvgSpeed, float64(b.speedVolume), interval.AvgSpeed, float64(interval.Volume))
+		b.speedVolume += interval.Volume
+	}
+
+	if interval.HasHeadway && interval.Volume > 0 {
+		b.Headway = weighted(b.Headway, float64(b.headwayVolume), interval.Headway, float64(interval.Volume))
+		b.headwayVolume += interval.Volume
+	}
+
+	if interval.HasGap && interval.Volume > 0 {
+		b.Gap = weighted(b.Gap, float64(b.gapVolume), interval.Gap, float64(interval.Volume))
+		b.gapVolume += interval.Volume
+	}
+}
+
+// weighted returns the average of the average (of weight) and the value (of
+// valueWeight)
+func weighted(average float64, weight float64, value float64, valueWeight float64) float64 {
+	return (average*weight + value*valueWeight) / (weight + valueWeight)
+}
+
+// Aggregator accumulates the intervals of the radar into bins of Minutes,
+// aligned on the local clock.  An interval goes to the bin of its middle, the
+// bins are closed (OnBin) by the first interval of a later bin
+type Aggregator struct {
+	Minutes int
+	OnBin   func(startOn time.Time, minutes int, bins []*StatisticsBin)
+	startOn time.Time
+	bins    map[statisticsKey]*StatisticsBin
+}
+
+// Add accumulates the intervals of intervalSecs ending on endOn.  It returns
+// false for the late intervals of a bin closed already, which are dropped
+func (a *Aggregator) Add(endOn time.Time, intervalSecs int, intervals []*StatisticsInterval) bool {
+	middle := endOn.Add(-time.Duration(intervalSecs) * time.Second / 2)
+	startOn := a.binOf(middle)
+
+	if startOn.Before(a.startOn) {
+		return false
+	}
+
+	if startOn.After(a.startOn) {
+		a.Flush()
+		a.startOn = startOn
+	}
+
+	if a.bins == nil {
+		a.bins = make(map[statisticsKey]*StatisticsBin)
+	}
+
+	for _, interval := range intervals {
+		key := statisticsKey{Zone: interval.Zone, Class: interval.Class}
+
+		bin, ok := a.bins[key]
+		if !ok {
+			bin = &StatisticsBin{Zone: interval.Zone, Class: interval.Class}
+			a.bins[key] = bin
+		}
+		bin.add(interval, float64(intervalSecs))
+	}
+	return true
+}
+
+// binOf returns the start of the bin of the time.  Truncate aligns on UTC,
+// so the time is shifted by its zone offset to align on the local hours
+func (a *Aggregator) binOf(on time.Time) time.Time {
+	_, offset := on.Zone()
+	shift := time.Duration(offset) * time.Second
+
+	return on.Add(shift).Truncate(time.Duration(a.Minutes) * time.Minute).Add(-shift)
+}
+
+// takeOver continues the open bin of the previous aggregator, the previous
+// is left empty
+func (a *Aggregator) takeOver(previous *Aggregator) {
+	a.startOn = previous.startOn
+	a.bins = previous.bins
+	previous.bins = nil
+}
+
+// Flush closes the bin accumulated, by zone and class
+func (a *Aggregator) Flush() {
+	if len(a.bins) == 0 {
+		return
+	}
+
+	bins := make([]*StatisticsBin, 0, len(a.bins))
+	for _, bin := range a.bins {
+		bins = append(bins, bin)
+	}
+
+	sort.Slice(bins, func(i, j int) bool {
+		if bins[i].Zone != bins[j].Zone {
+			return bins[i].Zone < bins[j].Zone
+		}
+		return bins[i].Class < bins[j].Class
+	})
+
+	a.bins = nil
+	if a.OnBin != nil {
+		a.OnBin(a.startOn, a.Minutes, bins)
+	}
+}
@@ -0,0 +1,157 @@
+package statistics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/internal/smartmicro/interfaces"
+	"rvpro3/radarvision.com/internal/smartmicro/port"
+	"rvpro3/radarvision.com/utils"
+)
+
+type closedBin struct {
+	StartOn time.Time
+	Minutes int
+	Bins    []*StatisticsBin
+}
+
+func TestAggregator_Add(t *testing.T) {
+	var closed []closedBin
+	aggregator := Aggregator{
+		Minutes: 5,
+		OnBin: func(startOn time.Time, minutes int, bins []*StatisticsBin) {
+			closed = append(closed, closedBin{StartOn: startOn, Minutes: minutes, Bins: bins})
+		},
+	}
+
+	on := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	car := func(volume int, speed float64, occupancy float64) []*StatisticsInterval {
+		return []*StatisticsInterval{{
+			Zone: 1, Class: port.OctCar, Volume: volume,
+			AvgSpeed: speed, HasAvgSpeed: true,
+			Occupancy: occupancy, HasOccupancy: true,
+		}}
+	}
+
+	// 10:00-10:01 and 10:01-10:02 go to 10:00
+	aggregator.Add(on.Add(time.Minute), 60, car(10, 20, 10))
+	aggregator.Add(on.Add(2*time.Minute), 60, car(30, 40, 30))
+	aggregator.Add(on.Add(2*time.Minute), 60, []*StatisticsInterval{{Zone: 0, Class: port.OctLongTruck, Volume: 1}})
+	assert.Empty(t, closed)
+
+	// 10:04:30-10:05:30 goes to 10:05, closing 10:00
+	aggregator.Add(on.Add(5*time.Minute+30*time.Second), 60, car(0, 0, 0))
+	if assert.Len(t, closed, 1) {
+		assert.Equal(t, on, closed[0].StartOn)
+		assert.Equal(t, 5, closed[0].Minutes)
+
+		if assert.Len(t, closed[0].Bins, 2) {
+			assert.Equal(t, port.OctLongTruck, closed[0].Bins[0].Class, "sorted by zone and class")
+
+			bin := closed[0].Bins[1]
+			assert.Equal(t, 40, bin.Volume)
+			assert.Equal(t, 2, bin.Intervals)
+			assert.InDelta(t, 35.0, bin.AvgSpeed, 0.001, "weighted by the volume")
+			assert.InDelta(t, 20.0, bin.Occupancy, 0.001, "weighted by the interval time")
+		}
+	}
+
+	// A late interval of 10:00 is dropped
+	assert.False(t, aggregator.Add(on.Add(3*time.Minute), 60, car(5, 5, 5)))
+	aggregator.Flush()
+	if assert.Len(t, closed, 2) {
+		assert.Equal(t, on.Add(5*time.Minute), closed[1].StartOn)
+		assert.Equal(t, 0, closed[1].Bins[0].Volume)
+		assert.Equal(t, 0.0, closed[1].Bins[0].AvgSpeed, "no speed without volume")
+		assert.Equal(t, 0.0, closed[1].Bins[0].Occupancy)
+	}
+
+	aggregator.Flush()
+	assert.Len(t, closed, 2, "nothing to close")
+}
+
+func TestAggregator_AddLocal(t *testing.T) {
+	var closed []closedBin
+	aggregator := Aggregator{
+		Minutes: 60,
+		OnBin: func(startOn time.Time, minutes int, bins []*StatisticsBin) {
+			closed = append(closed, closedBin{StartOn: startOn, Minutes: minutes, Bins: bins})
+		},
+	}
+
+	// The hours of a zone 30 minutes off UTC
+	zone := time.FixedZone("UTC+0530", 5*3600+30*60)
+	on := time.Date(2026, 10, 18, 10, 0, 0, 0, zone)
+
+	assert.True(t, aggregator.Add(on.Add(50*time.Minute), 60, []*StatisticsInterval{{Zone: 1, Volume: 1}}))
+	aggregator.Flush()
+
+	if assert.Len(t, closed, 1) {
+		assert.True(t, on.Equal(closed[0].StartOn), "aligned on the local hour")
+	}
+}
+
+type testParent struct{}
+
+func (testParent) GetRadarIP() utils.IP4 { return utils.IP4Builder.FromString("192.168.11.99") }
+
+func newTestWorkflow(minutes ...int) *Workflow {
+	res := new(Workflow)
+	res.Init(testParent{})
+	res.IsCSVEnabled = false
+	res.aggregators = nil
+
+	for _, minute := range minutes {
+		res.aggregators = append(res.aggregators, &Aggregator{Minutes: minute, OnBin: res.onBin})
+	}
+	return res
+}
+
+func TestWorkflow_Attach(t *testing.T) {
+	previous := newTestWorkflow(5, 15)
+	next := newTestWorkflow(5)
+	binCount := next.Metrics.BinCount.Value
+
+	on := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	intervals := []*StatisticsInterval{{Zone: 1, Volume: 10}}
+	for _, aggregator := range previous.aggregators {
+		aggregator.Add(on.Add(time.Minute), 60, intervals)
+	}
+
+	bins := previous.Detach()
+	previous.Close()
+	assert.Equal(t, binCount, next.Metrics.BinCount.Value, "the detached bins are not closed by the previous")
+
+	// The 15 minute bin is no longer aggregated and is closed by next
+	next.Attach(bins)
+	assert.Equal(t, binCount+1, next.Metrics.BinCount.Value)
+
+	// The 5 minute bin continues, closed once with both intervals
+	next.aggregators[0].Add(on.Add(2*time.Minute), 60, intervals)
+	assert.Equal(t, 2, next.aggregators[0].bins[statisticsKey{Zone: 1}].Intervals)
+
+	next.Close()
+	assert.Equal(t, binCount+2, next.Metrics.BinCount.Value)
+}
+
+func TestAggregateActivity_Close(t *testing.T) {
+	activity := &AggregateActivity{}
+	activity.Aggregate.Init(testParent{})
+	activity.Aggregate.IsCSVEnabled = false
+	activity.Aggregate.aggregators = []*Aggregator{{Minutes: 5, OnBin: activity.Aggregate.onBin}}
+	binCount := activity.Aggregate.Metrics.BinCount.Value
+
+	on := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	activity.Aggregate.aggregators[0].Add(on.Add(time.Minute), 60, []*StatisticsInterval{{Zone: 1, Volume: 10}})
+
+	// The broker closes the activities of the workflows it stops or rebuilds
+	var closer interfaces.IUDPActivity = activity
+	closer.Close()
+	assert.Equal(t, binCount+1, activity.Aggregate.Metrics.BinCount.Value)
+}
+
+func TestWorkflowHelper_ParseBins(t *testing.T) {
+	assert.Equal(t, []int{1, 5, 15, 60}, WorkflowHelper.ParseBins("1;5;15;60"))
+	assert.Equal(t, []int{10, 30}, WorkflowHelper.ParseBins("10; 7;x;0;30;90"))
+}
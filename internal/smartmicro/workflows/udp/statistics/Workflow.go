@@ -1,18 +1,304 @@
 package statistics
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/rs/zerolog/log"
+	"rvpro3/radarvision.com/internal/services/statisticshistory"
 	"rvpro3/radarvision.com/internal/smartmicro/interfaces"
+	"rvpro3/radarvision.com/internal/smartmicro/port"
+	"rvpro3/radarvision.com/internal/smartmicro/udp/activity/statistics"
+	"rvpro3/radarvision.com/utils"
 )
 
+const statisticsBins = "radar.statistics.bins"
+const statisticsCSVEnabled = "radar.statistics.csv.enabled"
+const statisticsCSVPathTemplate = "radar.statistics.csv.pathtemplate"
+const statisticsCSVPathDefault = "/media/SDLOGS/logs/sensor/%d/statistics/statistics-%%dmin-%%%%s.csv"
+const statisticsSpeedResolution = "radar.statistics.speed.resolution"
+const statisticsOccupancyResolution = "radar.statistics.occupancy.resolution"
+const statisticsTimeResolution = "radar.statistics.time.resolution"
+
+// Workflow aggregates the statistics of the radar into bins of BinMinutes
+// (1, 5, 15 and 60 by default), each bin is written to a CSV file per bin
+// size, and stored in the statistics history when the service is started.
+// The outputs of the radar are scaled by the resolutions (speed in m/s,
+// occupancy in percent, headway and gap in seconds), the speed is then
+// converted by SpeedFactor to SpeedUnit
 type Workflow struct {
-	Parent interfaces.IUDPWorkflowParent
+	Parent              interfaces.IUDPWorkflowParent
+	BinMinutes          []int
+	SpeedFactor         float64
+	SpeedUnit           string
+	SpeedResolution     float64
+	OccupancyResolution float64
+	TimeResolution      float64
+	IsCSVEnabled        bool
+	CSVPathTemplate     string
+	Metrics             WorkflowMetrics `json:"-"`
+	aggregators         []*Aggregator
+	writers             map[int]*statistics.StatisticsCSVWriter
+	detectionZones      int
+	intervalSecs        int
+	writeError          utils.ErrorLoggerMixin
+}
+
+type WorkflowMetrics struct {
+	ProcessCount       *utils.Metric
+	ArchiveSkipCount   *utils.Metric
+	LateIntervalCount  *utils.Metric
+	UnsupportedVersion *utils.Metric
+	BinCount           *utils.Metric
+	ErrCount           *utils.Metric
+	utils.MetricsInitMixin
 }
 
 func (w *Workflow) Init(p interfaces.IUDPWorkflowParent) {
 	w.Parent = p
+
+	radarIP := p.GetRadarIP()
+	ip := radarIP.String()
+	gs := &utils.GlobalSettings
+
+	w.Metrics.InitMetrics(interfaces.GetUDPRadarMetric(radarIP)+".Statistics.Workflow", &w.Metrics)
+
+	w.BinMinutes = WorkflowHelper.ParseBins(gs.Indexed.Get(statisticsBins, ip, "1;5;15;60"))
+	w.SpeedResolution = gs.Indexed.GetFloat(statisticsSpeedResolution, ip, 0.1)
+	w.OccupancyResolution = gs.Indexed.GetFloat(statisticsOccupancyResolution, ip, 0.1)
+	w.TimeResolution = gs.Indexed.GetFloat(statisticsTimeResolution, ip, 0.1)
+	w.IsCSVEnabled = gs.Indexed.GetBool(statisticsCSVEnabled, ip, true)
+	w.CSVPathTemplate = gs.Indexed.Get(
+		statisticsCSVPathTemplate,
+		ip,
+		fmt.Sprintf(statisticsCSVPathDefault, radarIP.GetHost()),
+	)
+
+	if w.SpeedFactor == 0 {
+		w.SpeedFactor = 1
+	}
+
+	if w.SpeedUnit == "" {
+		w.SpeedUnit = "mps"
+	}
+
+	w.aggregators = make([]*Aggregator, 0, len(w.BinMinutes))
+	for _, minutes := range w.BinMinutes {
+		w.aggregators = append(w.aggregators, &Aggregator{Minutes: minutes, OnBin: w.onBin})
+	}
+	w.writers = make(map[int]*statistics.StatisticsCSVWriter, len(w.BinMinutes))
 }
 
 func (w *Workflow) Process(time time.Time, bytes []byte) {
+	stats := port.StatisticsReader{}
+	stats.Init(bytes)
+
+	if !stats.IsSupported() {
+		w.Metrics.UnsupportedVersion.IncAt(1, time)
+		return
+	}
+
+	// The archive is the statistics of past intervals, replayed on request
+	if stats.GetOutputType() != port.SotCurrentData {
+		w.Metrics.ArchiveSkipCount.IncAt(1, time)
+		return
+	}
+
+	w.Metrics.ProcessCount.IncAt(1, time)
+	w.detectionZones = int(stats.GetNofZones())
+	w.intervalSecs = int(stats.GetIntervalTime())
+
+	intervals := w.Decode(&stats)
+	endOn := utils.Time.Correct(time)
+
+	for _, aggregator := range w.aggregators {
+		if !aggregator.Add(endOn, w.intervalSecs, intervals) {
+			w.Metrics.LateIntervalCount.IncAt(1, time)
+		}
+	}
+}
+
+// Decode returns the statistics of the message by zone and class, each
+// statistic of the message is the output of one mode
+func (w *Workflow) Decode(stats *port.StatisticsReader) []*StatisticsInterval {
+	res := make([]*StatisticsInterval, 0, 16)
+	byKey := make(map[statisticsKey]*StatisticsInterval)
+
+	for idx := 0; idx < int(stats.GetNofStatistics()); idx++ {
+		key := statisticsKey{Zone: int(stats.GetZone(idx)), Class: stats.GetObjectClass(idx)}
+
+		interval, ok := byKey[key]
+		if !ok {
+			interval = &StatisticsInterval{Zone: key.Zone, Class: key.Class}
+			byKey[key] = interval
+			res = append(res, interval)
+		}
+
+		output := float64(stats.GetOutput(idx))
+		switch stats.GetMode(idx) {
+		case port.SmVolume:
+			interval.Volume = int(output)
+		case port.SmOccupancy:
+			interval.Occupancy = output * w.OccupancyResolution
+			interval.HasOccupancy = true
+		case port.SmAvgSpeed:
+			interval.AvgSpeed = output * w.SpeedResolution * w.SpeedFactor
+			interval.HasAvgSpeed = true
+		case port.SmHeadway:
+			interval.Headway = output * w.TimeResolution
+			interval.HasHeadway = true
+		case port.SmGap:
+			interval.Gap = output * w.TimeResolution
+			interval.HasGap = true
+		}
+	}
+	return res
+}
+
+// Flush closes the bins accumulated
+func (w *Workflow) Flush() {
+	for _, aggregator := range w.aggregators {
+		aggregator.Flush()
+	}
+}
+
+// Close closes the bins accumulated, and the CSV files of the bins
+func (w *Workflow) Close() {
+	w.Flush()
+
+	for _, writer := range w.writers {
+		writer.Close()
+	}
+}
+
+// Detach returns the aggregators with their open bins, the workflow no
+// longer closes them.  A reconfigure attaches them to the rebuilt workflow,
+// so a bin is not closed twice, partial and then with the remaining intervals
+func (w *Workflow) Detach() []*Aggregator {
+	res := w.aggregators
+	w.aggregators = nil
+	return res
+}
+
+// Attach continues the open bins of the aggregators detached from the
+// previous workflow, the bins of the sizes no longer aggregated are closed
+func (w *Workflow) Attach(previous []*Aggregator) {
+	for _, aggregator := range previous {
+		if next := w.aggregatorOf(aggregator.Minutes); next != nil {
+			next.takeOver(aggregator)
+			continue
+		}
+
+		aggregator.OnBin = w.onBin
+		aggregator.Flush()
+	}
+}
+
+func (w *Workflow) aggregatorOf(minutes int) *Aggregator {
+	for _, aggregator := range w.aggregators {
+		if aggregator.Minutes == minutes {
+			return aggregator
+		}
+	}
+	return nil
+}
+
+func (w *Workflow) onBin(startOn time.Time, minutes int, bins []*StatisticsBin) {
+	w.Metrics.BinCount.IncAt(int64(len(bins)), startOn)
+
+	if w.IsCSVEnabled {
+		w.writeCSV(startOn, minutes, bins)
+	}
+
+	// The bins are inserted on the writer goroutine of the history
+	if history, ok := utils.GlobalState.Get(statisticshistory.StatisticsHistoryServiceName).(*statisticshistory.StatisticsHistoryService); ok {
+		history.Queue(startOn, w.toRecs(startOn, minutes, bins))
+	}
+}
+
+func (w *Workflow) writeCSV(startOn time.Time, minutes int, bins []*StatisticsBin) {
+	writer := w.getWriter(minutes)
+
+	// The bins start on the reference clock already
+	for _, bin := range bins {
+		err := writer.WriteCorrected(
+			startOn,
+			bin.Zone,
+			bin.Class,
+			bin.Volume,
+			float32(bin.Occupancy),
+			float32(bin.AvgSpeed),
+			float32(bin.Headway),
+			float32(bin.Gap),
+		)
+
+		if err != nil {
+			w.Metrics.ErrCount.IncAt(1, startOn)
+			w.writeError.LogErrorAt(startOn, "Workflow.writeCSV", err)
+			return
+		}
+	}
+}
+
+func (w *Workflow) getWriter(minutes int) *statistics.StatisticsCSVWriter {
+	if writer, ok := w.writers[minutes]; ok {
+		return writer
+	}
+
+	writer := &statistics.StatisticsCSVWriter{
+		IntervalSecs:   minutes * 60,
+		DetectionZones: w.detectionZones,
+		SpeedUnit:      w.SpeedUnit,
+	}
+	writer.SensorIP = w.Parent.GetRadarIP().String()
+	writer.CSVFacade.PathTemplate = fmt.Sprintf(w.CSVPathTemplate, minutes)
+	writer.Init()
+
+	w.writers[minutes] = writer
+	return writer
+}
+
+func (w *Workflow) toRecs(startOn time.Time, minutes int, bins []*StatisticsBin) []*statisticshistory.StatisticsBinRec {
+	res := make([]*statisticshistory.StatisticsBinRec, 0, len(bins))
+	radarIP := w.Parent.GetRadarIP().String()
+
+	for _, bin := range bins {
+		res = append(res, &statisticshistory.StatisticsBinRec{
+			RadarIP:    radarIP,
+			BinMinutes: minutes,
+			StartOn:    startOn.UnixMilli(),
+			Zone:       bin.Zone,
+			Class:      bin.Class.String(),
+			Volume:     bin.Volume,
+			Occupancy:  bin.Occupancy,
+			AvgSpeed:   bin.AvgSpeed,
+			Headway:    bin.Headway,
+			Gap:        bin.Gap,
+			Intervals:  bin.Intervals,
+		})
+	}
+	return res
+}
+
+type workflowHelper struct{}
+
+var WorkflowHelper workflowHelper
+
+// ParseBins returns the bin sizes (minutes) of the list separated by ';',
+// a bin must divide the hour so the bins align on it
+func (workflowHelper) ParseBins(value string) []int {
+	res := make([]int, 0, 4)
+
+	for _, item := range strings.Split(value, ";") {
+		minutes, err := strconv.Atoi(strings.TrimSpace(item))
+
+		if err != nil || minutes <= 0 || 60%minutes != 0 {
+			log.Warn().Str("bin", item).Msg("WorkflowHelper.ParseBins: ignored")
+			continue
+		}
+		res = append(res, minutes)
+	}
+	return res
 }
@@ -4,13 +4,21 @@ import (
 	"bufio"
 	"io"
 	"net"
+	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"rvpro3/radarvision.com/utils"
 )
 
+// instructionWriteTimeout is the wait of an instruction for room in the queue
+// of a client, the instructions are not resent by the radar
+const instructionWriteTimeout = time.Second
+
+var ErrInstructionDropped = errors.New("instruction dropped, the client queue is full")
+
 // HubClient holds a TCP connection with a remote client
 // executeWrite sends data from the Radar/via the Hub to the remote client
 // executeRead reads data from the remote client(instructions) and dispatches it to the radar
@@ -20,10 +28,13 @@ type HubClient struct {
 	writeCache        [2 * utils.Kilobyte]byte
 	writeChannel      chan Packet
 	doneChannel       chan bool
+	closingChannel    chan bool
 	host              *HubHost
 	stats             HubClientStat
 	terminate         bool
 	terminateRefCount atomic.Int32
+	subscription      Subscription
+	subscriptionLock  sync.RWMutex
 	OnError           func(*HubClient, error)
 	OnConnect         func(*HubClient)
 	OnDisconnect      func(*HubClient)
@@ -43,6 +54,7 @@ func (c *HubClient) Start(connection net.TCPConn) {
 	c.terminateRefCount.Store(2)
 	c.writeChannel = make(chan Packet, 10)
 	c.doneChannel = make(chan bool)
+	c.closingChannel = make(chan bool)
 
 	go c.executeRead()
 	go c.executeWrite()
@@ -69,31 +81,96 @@ func (c *HubClient) executeWrite() {
 			c.writePacket(packet)
 
 		case <-c.doneChannel:
+			// The instructions waiting for the queue give up
+			close(c.closingChannel)
 			c.stats.RegisterDisconnect()
 			c.terminate = true
 			c.terminateRefCount.Add(-1)
-			close(c.writeChannel)
-			close(c.doneChannel)
+
+			// The host removes the client before the channels close, as
+			// the host writes to its clients concurrently
 			if c.OnDisconnect != nil {
 				c.OnDisconnect(c)
 			}
+			close(c.writeChannel)
+			close(c.doneChannel)
 			return
 		}
 	}
 }
 
+// Write queues the packet when the client subscribed to its radar (the
+// target).  The packet is dropped when the queue is half full and never
+// blocks, the instructions excepted (see writeInstruction)
 func (c *HubClient) Write(packet Packet) bool {
 	if c.terminate {
 		return false
 	}
 
-	if len(c.writeChannel) < cap(c.writeChannel)/2 || packet.Type == PtUdpInstruction {
-		c.writeChannel <- packet
+	if !c.IsSubscribedTo(packet) {
+		return false
+	}
+
+	if packet.Type == PtUdpInstruction {
+		return c.writeInstruction(packet)
+	}
+
+	if len(c.writeChannel) < cap(c.writeChannel)/2 {
+		select {
+		case c.writeChannel <- packet:
+			return true
+		default:
+		}
+	}
+	c.stats.RegisterDrop()
+	return false
+}
+
+// writeInstruction waits up to instructionWriteTimeout for room in the queue,
+// a client not reading its socket holding up the other clients no longer.
+// The instruction dropped is counted and reported
+func (c *HubClient) writeInstruction(packet Packet) bool {
+	timer := time.NewTimer(instructionWriteTimeout)
+	defer timer.Stop()
+
+	select {
+	case c.writeChannel <- packet:
 		return true
+	case <-c.closingChannel:
+	case <-timer.C:
 	}
+
+	c.stats.RegisterInstructionDrop()
+	c.onError(ErrInstructionDropped)
 	return false
 }
 
+func (c *HubClient) IsSubscribedTo(packet Packet) bool {
+	c.subscriptionLock.RLock()
+	defer c.subscriptionLock.RUnlock()
+	return c.subscription.IsSubscribedTo(packet.Type, packet.GetTargetIP(), packet.Data)
+}
+
+// GetSubscription returns the subscription of the client's handshake
+func (c *HubClient) GetSubscription() Subscription {
+	c.subscriptionLock.RLock()
+	defer c.subscriptionLock.RUnlock()
+	return c.subscription
+}
+
+func (c *HubClient) subscribe(packet Packet) {
+	var subscription Subscription
+
+	if err := subscription.Deserialize(packet.Data); err != nil {
+		c.onError(err)
+		return
+	}
+
+	c.subscriptionLock.Lock()
+	c.subscription = subscription
+	c.subscriptionLock.Unlock()
+}
+
 func (c *HubClient) writePacket(packet Packet) {
 	if c.terminate {
 		return
@@ -138,7 +215,11 @@ func (c *HubClient) executeRead() {
 		var packet Packet
 
 		for c.buffer.Pop(&packet) {
-			c.host.dispatcher.writePacket(packet)
+			if packet.Type == PtSubscribe {
+				c.subscribe(packet)
+			} else {
+				c.host.dispatcher.writePacket(packet)
+			}
 		}
 	}
 
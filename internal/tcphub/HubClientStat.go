@@ -8,15 +8,17 @@ import (
 )
 
 type HubClientStat struct {
-	ClientIP     uint32
-	ConnectAt    int64
-	DisconnectAt int64
-	WriteCount   uint32
-	WriteSize    uint32
-	WriteAt      int64
-	ReadCount    uint32
-	ReadSize     uint32
-	ReadAt       int64
+	ClientIP             uint32
+	ConnectAt            int64
+	DisconnectAt         int64
+	WriteCount           uint32
+	WriteSize            uint32
+	WriteAt              int64
+	ReadCount            uint32
+	ReadSize             uint32
+	ReadAt               int64
+	DropCount            uint32
+	InstructionDropCount uint32
 }
 
 func (c *HubClientStat) RegisterConnect(remoteAddr net.Addr) {
@@ -30,6 +32,8 @@ func (c *HubClientStat) RegisterConnect(remoteAddr net.Addr) {
 	c.ReadCount = 0
 	c.ReadSize = 0
 	c.ReadAt = 0
+	c.DropCount = 0
+	c.InstructionDropCount = 0
 }
 
 func (c *HubClientStat) RegisterRead(read int) {
@@ -44,6 +48,14 @@ func (c *HubClientStat) RegisterWrite(write int) {
 	c.WriteAt = time.Now().UnixMilli()
 }
 
+func (c *HubClientStat) RegisterDrop() {
+	c.DropCount++
+}
+
+func (c *HubClientStat) RegisterInstructionDrop() {
+	c.InstructionDropCount++
+}
+
 func (c *HubClientStat) RegisterDisconnect() {
 	c.DisconnectAt = time.Now().UnixMilli()
 }
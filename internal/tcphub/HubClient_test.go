@@ -0,0 +1,55 @@
+package tcphub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestHubClient() (*HubClient, *[]error) {
+	errs := new([]error)
+	res := &HubClient{
+		writeChannel:   make(chan Packet, 2),
+		closingChannel: make(chan bool),
+		OnError: func(_ *HubClient, err error) {
+			*errs = append(*errs, err)
+		},
+	}
+	return res, errs
+}
+
+func TestHubClient_Write(t *testing.T) {
+	client, _ := newTestHubClient()
+
+	assert.True(t, client.Write(Packet{Type: PtUdpForward}))
+	assert.False(t, client.Write(Packet{Type: PtUdpForward}), "the queue is half full")
+	assert.Equal(t, uint32(1), client.stats.DropCount)
+
+	// The instructions take the rest of the queue
+	assert.True(t, client.Write(Packet{Type: PtUdpInstruction}))
+	assert.Equal(t, 2, len(client.writeChannel))
+}
+
+func TestHubClient_WriteInstructionWaits(t *testing.T) {
+	client, errs := newTestHubClient()
+	client.writeChannel <- Packet{Type: PtUdpForward}
+	client.writeChannel <- Packet{Type: PtUdpForward}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		<-client.writeChannel
+	}()
+	assert.True(t, client.Write(Packet{Type: PtUdpInstruction}), "queued once the client reads")
+
+	startOn := time.Now()
+	assert.False(t, client.Write(Packet{Type: PtUdpInstruction}))
+	assert.GreaterOrEqual(t, time.Since(startOn), instructionWriteTimeout)
+	assert.Equal(t, uint32(1), client.stats.InstructionDropCount)
+	assert.Equal(t, []error{ErrInstructionDropped}, *errs)
+
+	// A client stopping drops the instruction straight away
+	close(client.closingChannel)
+	assert.False(t, client.Write(Packet{Type: PtUdpInstruction}))
+	assert.Equal(t, uint32(2), client.stats.InstructionDropCount)
+}
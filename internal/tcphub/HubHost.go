@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -16,13 +17,15 @@ type HubHost struct {
 	Terminate          bool
 	Terminated         bool
 	ListenAddr         utils.IP4
-	Clients            [2]HubClient
+	Clients            []*HubClient
 	ClientsLen         int
+	MaxClients         int
 	OnError            func(*HubHost, error)        `json:"-"`
 	OnRejectConnection func(*HubHost, *net.TCPConn) `json:"-"`
 	OnAcceptConnection func(*HubHost, *net.TCPConn) `json:"-"`
 	doneChan           chan bool                    `json:"-"`
 	dispatcher         HubDispatcher                `json:"-"`
+	clientsLock        sync.RWMutex
 	Metrics            HubHostMetrics
 }
 
@@ -49,18 +52,6 @@ func (h *HubHost) Start(listenAddr utils.IP4) {
 
 		h.ListenAddr = listenAddr
 
-		for i := range h.Clients {
-			client := &h.Clients[i]
-			client.Init(h)
-			client.OnConnect = func(hub *HubClient) {
-				h.ClientsLen++
-				h.Metrics.TotalConnects.Inc(1)
-			}
-			client.OnDisconnect = func(hub *HubClient) {
-				h.Metrics.TotalDisconnects.Inc(1)
-				h.ClientsLen--
-			}
-		}
 		go h.executeListen()
 
 		h.dispatcher.Start(listenAddr)
@@ -72,8 +63,7 @@ func (h *HubHost) IsRunning() bool {
 }
 
 func (h *HubHost) Stop() {
-	for i := range h.Clients {
-		client := &h.Clients[i]
+	for _, client := range h.getClients() {
 		client.Stop()
 	}
 
@@ -109,17 +99,12 @@ func (h *HubHost) executeListen() {
 					listener = nil
 					time.Sleep(time.Second)
 				}
+			} else if h.MaxClients > 0 && h.getClientsLen() >= h.MaxClients {
+				h.onRejectConnection(conn)
+				_ = conn.Close()
 			} else {
-				index := h.GetFreeSlot()
-
-				if index == -1 {
-					h.onRejectConnection(conn)
-					_ = conn.Close()
-				} else {
-					h.onAcceptConnection(conn)
-					hubClient := &h.Clients[index]
-					hubClient.Start(*conn)
-				}
+				h.onAcceptConnection(conn)
+				h.startClient(conn)
 			}
 		}
 	}
@@ -139,14 +124,50 @@ func (h *HubHost) onError(err error) {
 	}
 }
 
-func (h *HubHost) GetFreeSlot() int {
-	for i := range h.Clients {
-		client := &h.Clients[i]
-		if client.IsTerminated() {
-			return i
+// startClient adds a client per connection, the clients being unlimited
+// unless MaxClients is set.  The client is removed once disconnected
+func (h *HubHost) startClient(conn *net.TCPConn) {
+	client := &HubClient{}
+	client.Init(h)
+	client.OnConnect = func(hub *HubClient) {
+		h.Metrics.TotalConnects.Inc(1)
+	}
+	client.OnDisconnect = func(hub *HubClient) {
+		h.Metrics.TotalDisconnects.Inc(1)
+		h.removeClient(hub)
+	}
+
+	h.clientsLock.Lock()
+	h.Clients = append(h.Clients, client)
+	h.ClientsLen = len(h.Clients)
+	h.clientsLock.Unlock()
+
+	client.Start(*conn)
+}
+
+func (h *HubHost) removeClient(client *HubClient) {
+	h.clientsLock.Lock()
+	defer h.clientsLock.Unlock()
+
+	for i, c := range h.Clients {
+		if c == client {
+			h.Clients = append(h.Clients[:i], h.Clients[i+1:]...)
+			break
 		}
 	}
-	return -1
+	h.ClientsLen = len(h.Clients)
+}
+
+func (h *HubHost) getClients() []*HubClient {
+	h.clientsLock.RLock()
+	defer h.clientsLock.RUnlock()
+	return append([]*HubClient(nil), h.Clients...)
+}
+
+func (h *HubHost) getClientsLen() int {
+	h.clientsLock.RLock()
+	defer h.clientsLock.RUnlock()
+	return h.ClientsLen
 }
 
 func (h *HubHost) onRejectConnection(client *net.TCPConn) {
@@ -163,14 +184,18 @@ func (h *HubHost) onAcceptConnection(client *net.TCPConn) {
 	}
 }
 
+// WriteToClients writes the packet to the clients subscribed to its radar,
+// a client too slow skipping the packet without holding up the others
 func (h *HubHost) WriteToClients(packet Packet) {
 	now := time.Now()
+	h.clientsLock.RLock()
+	defer h.clientsLock.RUnlock()
+
 	if h.ClientsLen > 0 {
 		h.Metrics.WriteToClients.IncAt(1, now)
 		h.Metrics.WriteBytes.IncAt(1, now)
 
-		for i := range h.Clients {
-			client := &h.Clients[i]
+		for _, client := range h.Clients {
 			client.Write(packet)
 		}
 	} else {
@@ -0,0 +1,251 @@
+package tcphub
+
+import (
+	"hash/fnv"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/ipv4"
+	"rvpro3/radarvision.com/utils"
+)
+
+const defaultLoopWindow = utils.Milliseconds(2 * time.Second)
+
+// MulticastRelay emits the multicast tunnelled through the router on a
+// local interface, and reads the multicast of the interface to tunnel the
+// other way.  A datagram emitted is remembered for LoopWindow, reading it
+// back (loopback, or another relay on the network) is then not tunnelled
+// again.  The datagrams are emitted from their source (e.g. the radar ip
+// and port of an announcement) when the address is local
+type MulticastRelay struct {
+	GroupAddr     utils.IP4
+	InterfaceName string
+	IsLoopback    bool
+	LoopWindow    utils.Milliseconds
+	Terminate     bool
+	Metrics       MulticastRelayMetrics
+	OnRead        func(*MulticastRelay, utils.IP4, []byte) `json:"-"`
+	OnError       func(*MulticastRelay, error)             `json:"-"`
+	emitConns     map[utils.IP4]*net.UDPConn
+	emitted       map[uint64]time.Time
+	lastErr       error
+	lock          sync.Mutex
+}
+
+type MulticastRelayMetrics struct {
+	EmitIterations    *utils.Metric
+	EmitBytes         *utils.Metric
+	EmitErrors        *utils.Metric
+	EmitUnboundSource *utils.Metric
+	ReadIterations    *utils.Metric
+	ReadBytes         *utils.Metric
+	ReadErrors        *utils.Metric
+	ReadLoopDrops     *utils.Metric
+	utils.MetricsInitMixin
+}
+
+func (r *MulticastRelay) Init(sectionName string) {
+	r.Metrics.InitMetrics(sectionName, &r.Metrics)
+	r.emitConns = make(map[utils.IP4]*net.UDPConn)
+	r.emitted = make(map[uint64]time.Time)
+	r.Terminate = false
+
+	if r.LoopWindow <= 0 {
+		r.LoopWindow = defaultLoopWindow
+	}
+}
+
+// Listen reads the multicast of the interface until Terminate, each
+// datagram not looped back is given to OnRead
+func (r *MulticastRelay) Listen() {
+	go r.executeRead()
+}
+
+func (r *MulticastRelay) Stop() {
+	r.Terminate = true
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for source, conn := range r.emitConns {
+		_ = conn.Close()
+		delete(r.emitConns, source)
+	}
+}
+
+// Emit sends the datagram to the group on the interface, from the source
+// when a local address (an unbound socket otherwise)
+func (r *MulticastRelay) Emit(source utils.IP4, data []byte, now time.Time) error {
+	conn, err := r.getEmitConn(source)
+	if err != nil {
+		r.Metrics.EmitErrors.IncAt(1, now)
+		return err
+	}
+
+	r.remember(data, now)
+
+	group := r.GroupAddr.ToUDPAddr()
+	if _, err = conn.WriteToUDP(data, &group); err != nil {
+		r.Metrics.EmitErrors.IncAt(1, now)
+		return err
+	}
+
+	r.Metrics.EmitIterations.IncAt(1, now)
+	r.Metrics.EmitBytes.IncAt(int64(len(data)), now)
+	return nil
+}
+
+// IsLooped returns true when the datagram was emitted by the relay within
+// the LoopWindow, or is read from one of the sockets of the relay
+func (r *MulticastRelay) IsLooped(source utils.IP4, data []byte, now time.Time) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if emittedOn, ok := r.emitted[r.hash(data)]; ok && !r.LoopWindow.Expired(now, emittedOn) {
+		return true
+	}
+
+	for _, conn := range r.emitConns {
+		if source.Equals(utils.IP4Builder.FromAddr(conn.LocalAddr())) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *MulticastRelay) remember(data []byte, now time.Time) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for key, emittedOn := range r.emitted {
+		if r.LoopWindow.Expired(now, emittedOn) {
+			delete(r.emitted, key)
+		}
+	}
+	r.emitted[r.hash(data)] = now
+}
+
+func (r *MulticastRelay) hash(data []byte) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write(data)
+	return h.Sum64()
+}
+
+func (r *MulticastRelay) getEmitConn(source utils.IP4) (*net.UDPConn, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if conn, ok := r.emitConns[source]; ok {
+		return conn, nil
+	}
+
+	iface, err := r.getInterface()
+	if err != nil {
+		return nil, err
+	}
+
+	localAddr := source.ToUDPAddr()
+	conn, err := net.ListenUDP("udp4", &localAddr)
+	if err != nil {
+		// The source is not an address of the machine, the datagram is
+		// emitted from any address
+		r.Metrics.EmitUnboundSource.Inc(1)
+
+		if conn, err = net.ListenUDP("udp4", nil); err != nil {
+			return nil, err
+		}
+	}
+
+	pc := ipv4.NewPacketConn(conn)
+	if iface != nil {
+		if err = pc.SetMulticastInterface(iface); err != nil {
+			_ = conn.Close()
+			return nil, errors.Wrap(err, "multicast interface "+r.InterfaceName)
+		}
+	}
+	_ = pc.SetMulticastLoopback(r.IsLoopback)
+
+	r.emitConns[source] = conn
+	return conn, nil
+}
+
+// getInterface returns nil for the default interface
+func (r *MulticastRelay) getInterface() (*net.Interface, error) {
+	if r.InterfaceName == "" {
+		return nil, nil
+	}
+	return net.InterfaceByName(r.InterfaceName)
+}
+
+func (r *MulticastRelay) executeRead() {
+	var conn *net.UDPConn
+	var buffer [2 * utils.Kilobyte]byte
+
+	for !r.Terminate {
+		if conn == nil {
+			var err error
+
+			if conn, err = r.listen(); err != nil {
+				conn = nil
+				r.onError(err)
+				time.Sleep(3 * time.Second)
+				continue
+			}
+		}
+
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		readBytes, readAddr, err := conn.ReadFromUDP(buffer[:])
+		now := time.Now()
+
+		if err != nil {
+			if !errors.Is(err, os.ErrDeadlineExceeded) {
+				r.Metrics.ReadErrors.IncAt(1, now)
+				r.onError(err)
+				_ = conn.Close()
+				conn = nil
+			}
+			continue
+		}
+
+		source := utils.IP4Builder.FromAddr(readAddr)
+		if r.IsLooped(source, buffer[:readBytes], now) {
+			r.Metrics.ReadLoopDrops.IncAt(1, now)
+			continue
+		}
+
+		r.Metrics.ReadIterations.IncAt(1, now)
+		r.Metrics.ReadBytes.IncAt(int64(readBytes), now)
+
+		if r.OnRead != nil {
+			r.OnRead(r, source, buffer[:readBytes])
+		}
+	}
+
+	if conn != nil {
+		_ = conn.Close()
+	}
+}
+
+func (r *MulticastRelay) listen() (*net.UDPConn, error) {
+	iface, err := r.getInterface()
+	if err != nil {
+		return nil, err
+	}
+
+	group := r.GroupAddr.ToUDPAddr()
+	return net.ListenMulticastUDP("udp4", iface, &group)
+}
+
+// onError logs an error once, until another error occurs
+func (r *MulticastRelay) onError(err error) {
+	if r.OnError != nil {
+		r.OnError(r, err)
+	} else if r.lastErr == nil || r.lastErr.Error() != err.Error() {
+		log.Err(err).Str("group", r.GroupAddr.String()).Msg("MulticastRelay.onError")
+	}
+	r.lastErr = err
+}
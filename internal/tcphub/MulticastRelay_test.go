@@ -0,0 +1,79 @@
+package tcphub
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/utils"
+)
+
+func TestMulticastRelay_IsLooped(t *testing.T) {
+	relay := MulticastRelay{LoopWindow: utils.Milliseconds(time.Second)}
+	relay.Init("Test.Multicast.Relay.Loop")
+
+	now := time.Now()
+	source := utils.IP4Builder.FromString("192.168.11.2:50000")
+	relay.remember([]byte("keep-alive"), now)
+
+	assert.True(t, relay.IsLooped(source, []byte("keep-alive"), now.Add(500*time.Millisecond)))
+	assert.False(t, relay.IsLooped(source, []byte("other"), now.Add(500*time.Millisecond)))
+	assert.False(t, relay.IsLooped(source, []byte("keep-alive"), now.Add(time.Second)), "expired after the loop window")
+
+	relay.remember([]byte("announcement"), now.Add(2*time.Second))
+	assert.Len(t, relay.emitted, 1, "the expired datagrams are forgotten")
+}
+
+func TestMulticastRelay_Emit(t *testing.T) {
+	group := utils.IP4Builder.FromString("239.144.0.1:60123")
+	groupAddr := group.ToUDPAddr()
+
+	if conn, err := net.ListenMulticastUDP("udp4", nil, &groupAddr); err != nil {
+		t.Skip("Multicast not available", err)
+	} else {
+		_ = conn.Close()
+	}
+
+	var lock sync.Mutex
+	var read [][]byte
+
+	emitter := MulticastRelay{GroupAddr: group, IsLoopback: true}
+	emitter.Init("Test.Multicast.Relay.Emitter")
+	emitter.OnRead = func(_ *MulticastRelay, _ utils.IP4, data []byte) {
+		t.Error("the datagrams emitted are not read back", string(data))
+	}
+
+	listener := MulticastRelay{GroupAddr: group}
+	listener.Init("Test.Multicast.Relay.Listener")
+	listener.OnRead = func(_ *MulticastRelay, _ utils.IP4, data []byte) {
+		lock.Lock()
+		defer lock.Unlock()
+		read = append(read, append([]byte(nil), data...))
+	}
+
+	emitter.Listen()
+	listener.Listen()
+	defer emitter.Stop()
+	defer listener.Stop()
+
+	if err := emitter.Emit(utils.IP4{}, []byte("announcement"), time.Now()); err != nil {
+		t.Skip("Multicast emit not available", err)
+	}
+
+	assert.Eventually(t, func() bool {
+		_ = emitter.Emit(utils.IP4{}, []byte("announcement"), time.Now())
+
+		lock.Lock()
+		defer lock.Unlock()
+		return len(read) > 0
+	}, 5*time.Second, 200*time.Millisecond)
+
+	lock.Lock()
+	defer lock.Unlock()
+	if assert.NotEmpty(t, read) {
+		assert.Equal(t, "announcement", string(read[0]))
+	}
+	assert.Positive(t, emitter.Metrics.ReadLoopDrops.Value)
+}
@@ -30,6 +30,9 @@ const (
 	PtUdpInstruction
 	PtStats
 	PtServerClosesConnection
+	// PtSubscribe is the handshake of a client, the data being its
+	// Subscription.  The server answers with the subscription applied
+	PtSubscribe
 )
 
 type Packet struct {
@@ -0,0 +1,159 @@
+package tcphub
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+	"rvpro3/radarvision.com/internal/smartmicro/port"
+	"rvpro3/radarvision.com/utils"
+)
+
+// maxSubscriptionItems caps the radars and the port identifiers of a
+// subscription, the handshake fitting a single packet
+const maxSubscriptionItems = 128
+
+var ErrSubscriptionTooLarge = errors.New("subscription too large")
+
+// Subscription are the radars and the port identifiers a client receives,
+// sent by the client as a PtSubscribe packet.  An empty list subscribes to
+// all, a client never subscribing receives everything.  The radars match on
+// the ip only, the radar data and the multicast having different ports
+type Subscription struct {
+	Radars          []utils.IP4
+	PortIdentifiers []uint32
+}
+
+// IsAll returns true when subscribed to every radar and port
+func (s *Subscription) IsAll() bool {
+	return len(s.Radars) == 0 && len(s.PortIdentifiers) == 0
+}
+
+// IsSubscribedTo returns whether the packet of the radar is subscribed to.
+// The port identifier is read from the smartmicro headers of the data, the
+// radar multicast (PtRadarMulticast) is not filtered by port
+func (s *Subscription) IsSubscribedTo(packetType PacketType, radar utils.IP4, data []byte) bool {
+	if !s.IsRadarSubscribed(radar) {
+		return false
+	}
+
+	if len(s.PortIdentifiers) == 0 || packetType == PtRadarMulticast {
+		return true
+	}
+
+	portIdentifier, ok := s.getPortIdentifier(data)
+	return ok && s.IsPortSubscribed(portIdentifier)
+}
+
+func (s *Subscription) IsRadarSubscribed(radar utils.IP4) bool {
+	if len(s.Radars) == 0 {
+		return true
+	}
+
+	for _, subscribed := range s.Radars {
+		if subscribed.IsEqualIP(radar) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Subscription) IsPortSubscribed(portIdentifier uint32) bool {
+	if len(s.PortIdentifiers) == 0 {
+		return true
+	}
+
+	for _, subscribed := range s.PortIdentifiers {
+		if subscribed == portIdentifier {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Subscription) getPortIdentifier(data []byte) (uint32, bool) {
+	th, ph := port.Helper.GetHeaders(data)
+
+	if th.CheckFormat() != nil || ph.Check() != nil {
+		return 0, false
+	}
+	return uint32(ph.GetIdentifier()), true
+}
+
+// Serialize writes the subscription as the data of the PtSubscribe packet:
+// the radar count (u16), each radar ip (u32) and port (u16), the port
+// identifier count (u16) and each port identifier (u32)
+func (s *Subscription) Serialize(target []byte) ([]byte, error) {
+	if len(s.Radars) > maxSubscriptionItems || len(s.PortIdentifiers) > maxSubscriptionItems {
+		return nil, ErrSubscriptionTooLarge
+	}
+
+	writer := utils.NewFixedBuffer(target, 0, 0)
+	writer.WriteU16(uint16(len(s.Radars)), binary.LittleEndian)
+
+	for _, radar := range s.Radars {
+		writer.WriteU32(radar.ToU32(), binary.LittleEndian)
+		writer.WriteU16(uint16(radar.Port), binary.LittleEndian)
+	}
+
+	writer.WriteU16(uint16(len(s.PortIdentifiers)), binary.LittleEndian)
+
+	for _, portIdentifier := range s.PortIdentifiers {
+		writer.WriteU32(portIdentifier, binary.LittleEndian)
+	}
+	return target[:writer.WritePos], writer.Err
+}
+
+// Deserialize reads the subscription from the data of a PtSubscribe packet
+func (s *Subscription) Deserialize(source []byte) error {
+	reader := utils.NewFixedBuffer(source, 0, len(source))
+
+	radarCount := int(reader.ReadU16(binary.LittleEndian))
+	if radarCount > maxSubscriptionItems {
+		return ErrSubscriptionTooLarge
+	}
+
+	var radars []utils.IP4
+	for i := 0; i < radarCount && reader.Err == nil; i++ {
+		ip := reader.ReadU32(binary.LittleEndian)
+		radars = append(radars, utils.IP4Builder.FromU32(ip, int(reader.ReadU16(binary.LittleEndian))))
+	}
+
+	portCount := int(reader.ReadU16(binary.LittleEndian))
+	if portCount > maxSubscriptionItems {
+		return ErrSubscriptionTooLarge
+	}
+
+	var portIdentifiers []uint32
+	for i := 0; i < portCount && reader.Err == nil; i++ {
+		portIdentifiers = append(portIdentifiers, reader.ReadU32(binary.LittleEndian))
+	}
+
+	if reader.Err != nil {
+		return errors.Wrap(reader.Err, "subscription")
+	}
+
+	s.Radars = radars
+	s.PortIdentifiers = portIdentifiers
+	return nil
+}
+
+// NewSubscribePacket writes the PtSubscribe packet of the subscription into
+// the buffer, returning the packet
+func NewSubscribePacket(buffer []byte, subscription *Subscription) ([]byte, error) {
+	var dataBuffer [2 * utils.Kilobyte]byte
+
+	data, err := subscription.Serialize(dataBuffer[:])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(buffer) <= dataOffset+len(data) {
+		return nil, ErrSubscriptionTooLarge
+	}
+
+	packet := PacketWrapper{}
+	packet.Init(buffer, 0, utils.IP4{}, utils.IP4{})
+	packet.SetPacketType(PtSubscribe)
+	packet.SetData(data)
+	return packet.GetPacket(), nil
+}
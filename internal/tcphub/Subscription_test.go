@@ -0,0 +1,86 @@
+package tcphub
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/internal/smartmicro/port"
+	"rvpro3/radarvision.com/utils"
+)
+
+func TestSubscription_Serialize(t *testing.T) {
+	var buffer [256]byte
+
+	subscription := Subscription{
+		Radars:          []utils.IP4{utils.IP4Builder.FromString("192.168.11.12:55555"), utils.IP4Builder.FromString("192.168.11.13:55555")},
+		PortIdentifiers: []uint32{port.PiObjectList, port.PiPVR},
+	}
+
+	data, err := subscription.Serialize(buffer[:])
+	assert.NoError(t, err)
+	assert.Equal(t, 2+2*6+2+2*4, len(data))
+
+	res := Subscription{}
+	assert.NoError(t, res.Deserialize(data))
+	assert.Equal(t, subscription, res)
+
+	assert.Error(t, res.Deserialize(data[:5]))
+	assert.Equal(t, subscription, res, "a subscription failing to parse is left as is")
+
+	empty := Subscription{}
+	data, err = empty.Serialize(buffer[:])
+	assert.NoError(t, err)
+	assert.NoError(t, res.Deserialize(data))
+	assert.True(t, res.IsAll())
+}
+
+func TestSubscription_IsSubscribedTo(t *testing.T) {
+	radar12 := utils.IP4Builder.FromString("192.168.11.12:55555")
+	radar13 := utils.IP4Builder.FromString("192.168.11.13:55555")
+	objectList := newPortData(port.PiObjectList)
+	statistics := newPortData(port.PiStatistics)
+
+	all := Subscription{}
+	assert.True(t, all.IsSubscribedTo(PtUdpForward, radar13, statistics))
+
+	byRadar := Subscription{Radars: []utils.IP4{radar12}}
+	assert.True(t, byRadar.IsSubscribedTo(PtUdpForward, radar12, statistics))
+	assert.True(t, byRadar.IsSubscribedTo(PtRadarMulticast, radar12.WithPort(60000), nil), "radars match on the ip only")
+	assert.False(t, byRadar.IsSubscribedTo(PtUdpForward, radar13, statistics))
+
+	byPort := Subscription{Radars: []utils.IP4{radar12}, PortIdentifiers: []uint32{port.PiObjectList}}
+	assert.True(t, byPort.IsSubscribedTo(PtUdpForward, radar12, objectList))
+	assert.False(t, byPort.IsSubscribedTo(PtUdpForward, radar12, statistics))
+	assert.False(t, byPort.IsSubscribedTo(PtUdpForward, radar12, []byte("not smartmicro")))
+	assert.True(t, byPort.IsSubscribedTo(PtRadarMulticast, radar12, nil), "the multicast is not filtered by port")
+}
+
+func TestNewSubscribePacket(t *testing.T) {
+	var buffer [2 * utils.Kilobyte]byte
+
+	subscription := Subscription{PortIdentifiers: []uint32{port.PiStatistics}}
+	data, err := NewSubscribePacket(buffer[:], &subscription)
+	assert.NoError(t, err)
+
+	packet := PacketWrapper{Buffer: data}
+	assert.True(t, packet.IsComplete())
+	assert.Equal(t, PtSubscribe, packet.GetPacketType())
+
+	res := Subscription{}
+	assert.NoError(t, res.Deserialize(packet.GetData()))
+	assert.Equal(t, subscription, res)
+
+	_, err = NewSubscribePacket(buffer[:20], &subscription)
+	assert.ErrorIs(t, err, ErrSubscriptionTooLarge)
+}
+
+// newPortData returns a transport header (12 bytes) followed by the port
+// header of the identifier
+func newPortData(portIdentifier uint32) []byte {
+	data := make([]byte, 12+24)
+	data[0] = port.StartPattern
+	data[2] = 12
+	binary.BigEndian.PutUint32(data[12:], portIdentifier)
+	return data
+}
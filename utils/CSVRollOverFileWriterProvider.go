@@ -52,7 +52,7 @@ func (c *CSVRollOverFileWriterProvider) GetWriter() (*CSVWriter, error) {
 		}
 
 		c.writer.Filename = newFilename
-		c.FileDate = time.Now()
+		c.FileDate = Time.Corrected()
 
 		return &c.writer, nil
 	}
@@ -60,12 +60,19 @@ func (c *CSVRollOverFileWriterProvider) GetWriter() (*CSVWriter, error) {
 	return &c.writer, nil
 }
 
+// Close closes the file written, without opening one when none is.  The next
+// GetWriter opens the file again
+func (c *CSVRollOverFileWriterProvider) Close() {
+	c.writer.Close()
+	c.FileDate = time.Time{}
+}
+
 func (c *CSVRollOverFileWriterProvider) OnFileNameCallback(*CSVRollOverFileWriterProvider) string {
-	return fmt.Sprintf(c.PathTemplate, Time.Approx().Format(c.TimeFormat))
+	return fmt.Sprintf(c.PathTemplate, Time.Correct(Time.Approx()).Format(c.TimeFormat))
 }
 
 func (c *CSVRollOverFileWriterProvider) OnShouldRolloverCallback(*CSVRollOverFileWriterProvider) bool {
-	return !Time.IsSameDay(Time.Approx(), c.FileDate)
+	return !Time.IsSameDay(Time.Correct(Time.Approx()), c.FileDate)
 }
 
 func (c *CSVRollOverFileWriterProvider) createPathFor(filename string) error {
@@ -3,6 +3,7 @@ package utils
 import (
 	"maps"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 )
@@ -81,6 +82,18 @@ func (g *globalMetrics) Names() []string {
 	return res
 }
 
+// Sections returns the sections ordered by name
+func (g *globalMetrics) Sections() []*Metrics {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	res := slices.Collect(maps.Values(g.section))
+	slices.SortFunc(res, func(a, b *Metrics) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return res
+}
+
 func (g *globalMetrics) FindOrNil(name string) *Metrics {
 	g.mutex.RLock()
 	defer g.mutex.RUnlock()
@@ -32,9 +32,10 @@ func (ip4Builder) FromString(addr string) IP4 {
 func (ip4Builder) FromIP(addr net.IP, port int) IP4 {
 	result := IP4{}
 
+	// An invalid address (nil) is left as 0.0.0.0
 	if len(addr) == 16 {
 		copy(result.Bytes[:], addr[12:16])
-	} else {
+	} else if len(addr) >= 4 {
 		copy(result.Bytes[:], addr[0:4])
 	}
 	result.Port = port
@@ -1,8 +1,10 @@
 package utils
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -13,10 +15,14 @@ const (
 	MtMilliTime
 	MtMilliDuration
 	MtBool
+	MtGauge
+	MtRate
+	MtWindow
+	MtHistogram
 	MtUnknown
 )
 
-var metricTypeAbbr = [...]string{"I64", "MT", "MD", "YN", "UNK"}
+var metricTypeAbbr = [...]string{"I64", "MT", "MD", "YN", "GA", "RT", "WN", "HG", "UNK"}
 
 func (m *MetricType) MarshalJSON() ([]byte, error) {
 	return []byte(fmt.Sprintf("\"%s\"", metricTypeAbbr[*m])), nil
@@ -27,6 +33,7 @@ func (m *MetricType) UnmarshalJSON(data []byte) error {
 	for index, name := range metricTypeAbbr {
 		if src == name {
 			*m = MetricType(index)
+			return nil
 		}
 	}
 
@@ -34,14 +41,186 @@ func (m *MetricType) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// Metric is a running value.  The MtRate and MtWindow metrics also keep a
+// sliding Window (the rate of the counts, the min/max/avg of the values), the
+// MtHistogram metrics a fixed-bucket Histogram of the values.  An MtI64 is a
+// count, it becomes an MtGauge once its value is set or decremented.
+//
+// The Window and the Histogram are guarded by the lock, they are updated on
+// the broker goroutines while the api and the snapshots read them.  The json
+// holds the Rate, the window Summary and the Histogram summary instead
 type Metric struct {
-	Name    string `json:"-"`
-	FirstOn int64  `json:"FirstOn,omitempty"`
-	LastOn  int64  `json:"LastOn,omitempty"`
-	ResetOn int64  `json:"ResetOn,omitempty"`
-	IsSet   bool   `json:"-"`
-	Type    MetricType
-	Value   int64
+	Name      string           `json:"-"`
+	FirstOn   int64            `json:"FirstOn,omitempty"`
+	LastOn    int64            `json:"LastOn,omitempty"`
+	ResetOn   int64            `json:"ResetOn,omitempty"`
+	IsSet     bool             `json:"-"`
+	Type      MetricType       `json:"Type"`
+	Value     int64            `json:"Value"`
+	Window    *MetricWindow    `json:"-"`
+	Histogram *MetricHistogram `json:"-"`
+	lock      sync.Mutex
+}
+
+// metricJSON is the json of a Metric
+type metricJSON struct {
+	FirstOn   int64                   `json:"FirstOn,omitempty"`
+	LastOn    int64                   `json:"LastOn,omitempty"`
+	ResetOn   int64                   `json:"ResetOn,omitempty"`
+	Type      MetricType              `json:"Type"`
+	Value     int64                   `json:"Value"`
+	Rate      float64                 `json:"Rate,omitempty"`
+	Summary   *MetricSummary          `json:"Summary,omitempty"`
+	Histogram *MetricHistogramSummary `json:"Histogram,omitempty"`
+}
+
+// NewMetric creates the metric, with the default window or histogram when
+// the type requires one
+func NewMetric(name string, metricType MetricType) *Metric {
+	res := &Metric{Name: name, Type: metricType}
+
+	switch metricType {
+	case MtRate, MtWindow:
+		res.Window = NewMetricWindow(DefaultWindowSlots, DefaultWindowSlotMs*time.Millisecond)
+
+	case MtHistogram:
+		res.Histogram = NewMetricHistogram()
+	}
+	return res
+}
+
+// MarshalJSON returns the metric with the rate (MtRate) or the summary
+// (MtWindow) of the window ending now, and the summary of the histogram
+func (s *Metric) MarshalJSON() ([]byte, error) {
+	on := time.Now().UnixMilli()
+
+	s.lock.Lock()
+	res := metricJSON{
+		FirstOn: s.FirstOn,
+		LastOn:  s.LastOn,
+		ResetOn: s.ResetOn,
+		Type:    s.Type,
+		Value:   s.Value,
+	}
+
+	if s.Window != nil {
+		if s.Type == MtRate {
+			res.Rate = s.Window.RateAt(on)
+		} else {
+			summary := s.Window.SummaryAt(on)
+			res.Summary = &summary
+		}
+	}
+
+	if s.Histogram != nil {
+		summary := s.Histogram.Summary()
+		res.Histogram = &summary
+	}
+	s.lock.Unlock()
+
+	return json.Marshal(&res)
+}
+
+// WithWindow replaces the window, to be called before the metric is used
+func (s *Metric) WithWindow(slots int, slot time.Duration) *Metric {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.Window = NewMetricWindow(slots, slot)
+	return s
+}
+
+// WithBounds replaces the histogram buckets, to be called before the metric
+// is used
+func (s *Metric) WithBounds(bounds ...int64) *Metric {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.Histogram = NewMetricHistogram(bounds...)
+	return s
+}
+
+// CountAt adds the count to the Value and to the window of a rate metric
+func (s *Metric) CountAt(count int64, tm time.Time) {
+	on := tm.UnixMilli()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.touch(on)
+
+	s.Value += count
+	if s.Window != nil {
+		s.Window.Add(on, count, 1)
+	}
+}
+
+// ObserveAt sets the Value and adds the value to the window of a window
+// metric, or to the buckets of a histogram metric
+func (s *Metric) ObserveAt(value int64, tm time.Time) {
+	on := tm.UnixMilli()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.touch(on)
+
+	s.Value = value
+	if s.Window != nil {
+		s.Window.Add(on, value, 1)
+	}
+
+	if s.Histogram != nil {
+		s.Histogram.Observe(value)
+	}
+}
+
+// RateAt returns the counts per second over the window ending at tm
+func (s *Metric) RateAt(tm time.Time) float64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.Window == nil {
+		return 0
+	}
+	return s.Window.RateAt(tm.UnixMilli())
+}
+
+// SummaryAt returns the min/max/avg over the window ending at tm
+func (s *Metric) SummaryAt(tm time.Time) MetricSummary {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.Window == nil {
+		return MetricSummary{}
+	}
+	return s.Window.SummaryAt(tm.UnixMilli())
+}
+
+// GetHistogram returns a copy of the histogram, nil when the metric has none
+func (s *Metric) GetHistogram() *MetricHistogram {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.Histogram == nil {
+		return nil
+	}
+	return s.Histogram.Clone()
+}
+
+func (s *Metric) touch(on int64) {
+	if !s.IsSet {
+		s.IsSet = true
+		s.FirstOn = on
+	}
+	s.LastOn = on
+}
+
+// setGauge makes an MtI64 a gauge, its value being set instead of counted
+func (s *Metric) setGauge() {
+	if s.Type == MtI64 {
+		s.Type = MtGauge
+	}
 }
 
 func (s *Metric) Inc(value int64) {
@@ -72,8 +251,10 @@ func (s *Metric) IncAt(value int64, tm time.Time) bool {
 }
 
 func (s *Metric) SetAt(value int64, now time.Time) bool {
+	s.setGauge()
 	if !s.IsSet {
 		s.IsSet = true
+		s.Value = value
 		s.FirstOn = now.UnixMilli()
 		s.LastOn = now.UnixMilli()
 
@@ -87,6 +268,7 @@ func (s *Metric) SetAt(value int64, now time.Time) bool {
 }
 
 func (s *Metric) Dec(value int64) {
+	s.setGauge()
 	now := time.Now().UnixMilli()
 
 	if !s.IsSet {
@@ -99,6 +281,7 @@ func (s *Metric) Dec(value int64) {
 }
 
 func (s *Metric) Set(value int64) {
+	s.setGauge()
 	now := time.Now().UnixMilli()
 
 	if !s.IsSet {
@@ -106,7 +289,7 @@ func (s *Metric) Set(value int64) {
 		s.FirstOn = now
 	}
 
-	s.Value = s.Value - value
+	s.Value = value
 	s.LastOn = now
 }
 
@@ -150,6 +333,7 @@ func (s *Metric) GetTime() time.Time {
 //}
 
 func (s *Metric) SetIfMore(value int64) {
+	s.setGauge()
 	if !s.IsSet {
 		now := time.Now().UnixMilli()
 		s.IsSet = true
@@ -166,6 +350,7 @@ func (s *Metric) SetIfMore(value int64) {
 }
 
 func (s *Metric) SetIfMoreAt(value int64, tm time.Time) {
+	s.setGauge()
 	if !s.IsSet {
 		now := tm.UnixMilli()
 		s.IsSet = true
@@ -182,6 +367,7 @@ func (s *Metric) SetIfMoreAt(value int64, tm time.Time) {
 }
 
 func (s *Metric) SetIfLess(value int64) {
+	s.setGauge()
 	if !s.IsSet {
 		now := time.Now().UnixMilli()
 		s.IsSet = true
@@ -198,6 +384,7 @@ func (s *Metric) SetIfLess(value int64) {
 }
 
 func (s *Metric) SetIfLessAt(value int64, tm time.Time) {
+	s.setGauge()
 	if !s.IsSet {
 		now := tm.UnixMilli()
 		s.IsSet = true
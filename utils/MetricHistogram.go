@@ -0,0 +1,88 @@
+package utils
+
+import "slices"
+
+// DefaultHistogramBounds are the (upper, inclusive) bucket bounds in
+// milliseconds of a latency histogram
+var DefaultHistogramBounds = []int64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000}
+
+// MetricHistogram is the fixed-bucket histogram of the MtHistogram metrics.
+// Counts holds a count per bound, the last count holds the values above the
+// last bound (+Inf).  The counts are not cumulative
+type MetricHistogram struct {
+	Bounds []int64
+	Counts []int64
+	Count  int64
+	Sum    int64
+}
+
+// MetricHistogramSummary is the json of a histogram, with the cumulative
+// counts of the buckets
+type MetricHistogramSummary struct {
+	Bounds     []int64
+	Cumulative []int64
+	Count      int64
+	Sum        int64
+	Avg        float64
+}
+
+func NewMetricHistogram(bounds ...int64) *MetricHistogram {
+	if len(bounds) == 0 {
+		bounds = DefaultHistogramBounds
+	}
+
+	bounds = slices.Clone(bounds)
+	slices.Sort(bounds)
+	bounds = slices.Compact(bounds)
+
+	return &MetricHistogram{
+		Bounds: bounds,
+		Counts: make([]int64, len(bounds)+1),
+	}
+}
+
+// Observe counts the value into the bucket of the first bound not below it
+func (h *MetricHistogram) Observe(value int64) {
+	index, _ := slices.BinarySearch(h.Bounds, value)
+
+	h.Counts[index]++
+	h.Count++
+	h.Sum += value
+}
+
+// Cumulative returns the cumulative counts, the last being Count
+func (h *MetricHistogram) Cumulative() []int64 {
+	res := make([]int64, len(h.Counts))
+	var total int64
+
+	for index, count := range h.Counts {
+		total += count
+		res[index] = total
+	}
+	return res
+}
+
+// Clone returns a copy of the histogram
+func (h *MetricHistogram) Clone() *MetricHistogram {
+	return &MetricHistogram{
+		Bounds: slices.Clone(h.Bounds),
+		Counts: slices.Clone(h.Counts),
+		Count:  h.Count,
+		Sum:    h.Sum,
+	}
+}
+
+// Summary returns the cumulative counts, the count, the sum and the average
+func (h *MetricHistogram) Summary() MetricHistogramSummary {
+	res := MetricHistogramSummary{
+		Bounds:     slices.Clone(h.Bounds),
+		Cumulative: h.Cumulative(),
+		Count:      h.Count,
+		Sum:        h.Sum,
+	}
+
+	if h.Count > 0 {
+		res.Avg = float64(h.Sum) / float64(h.Count)
+	}
+	return res
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"math"
+	"time"
+)
+
+// DefaultWindowSlots and DefaultWindowSlotMs give a one minute window with a
+// one second resolution
+const DefaultWindowSlots = 60
+const DefaultWindowSlotMs = 1000
+
+// MetricWindow is the sliding window of the MtRate and MtWindow metrics.  The
+// window is divided into slots of SlotMs, the slot of a time is reused once
+// the window moved past it.  Every slot holds the time it started, meaning
+// the window is read without having to advance it
+type MetricWindow struct {
+	SlotMs int64
+	Slots  []MetricSlot
+}
+
+// MetricSlot holds the values observed (or counted) during the slot
+type MetricSlot struct {
+	On    int64
+	Count int64
+	Sum   int64
+	Min   int64
+	Max   int64
+}
+
+// MetricSummary summarises the slots within the window
+type MetricSummary struct {
+	Count int64
+	Sum   int64
+	Min   int64
+	Max   int64
+	Avg   float64
+}
+
+func NewMetricWindow(slots int, slot time.Duration) *MetricWindow {
+	if slots < 1 {
+		slots = 1
+	}
+
+	slotMs := slot.Milliseconds()
+	if slotMs < 1 {
+		slotMs = 1
+	}
+
+	return &MetricWindow{
+		SlotMs: slotMs,
+		Slots:  make([]MetricSlot, slots),
+	}
+}
+
+// GetDuration returns the length of the window
+func (w *MetricWindow) GetDuration() time.Duration {
+	return time.Duration(w.SlotMs*int64(len(w.Slots))) * time.Millisecond
+}
+
+// Add adds the value (count times) to the slot of on (unix milliseconds)
+func (w *MetricWindow) Add(on int64, value int64, count int64) {
+	slotOn := on - on%w.SlotMs
+	slot := &w.Slots[(slotOn/w.SlotMs)%int64(len(w.Slots))]
+
+	if slot.On != slotOn {
+		*slot = MetricSlot{On: slotOn, Min: math.MaxInt64, Max: math.MinInt64}
+	}
+
+	slot.Count += count
+	slot.Sum += value * count
+
+	if value < slot.Min {
+		slot.Min = value
+	}
+
+	if value > slot.Max {
+		slot.Max = value
+	}
+}
+
+// SummaryAt summarises the slots within the window ending at on (unix
+// milliseconds), Min and Max are 0 when nothing was added
+func (w *MetricWindow) SummaryAt(on int64) MetricSummary {
+	res := MetricSummary{Min: math.MaxInt64, Max: math.MinInt64}
+	currentOn := on - on%w.SlotMs
+	firstOn := currentOn - w.SlotMs*int64(len(w.Slots)-1)
+
+	for index := range w.Slots {
+		slot := &w.Slots[index]
+
+		if slot.Count == 0 || slot.On < firstOn || slot.On > currentOn {
+			continue
+		}
+
+		res.Count += slot.Count
+		res.Sum += slot.Sum
+		res.Min = min(res.Min, slot.Min)
+		res.Max = max(res.Max, slot.Max)
+	}
+
+	if res.Count == 0 {
+		return MetricSummary{}
+	}
+
+	res.Avg = float64(res.Sum) / float64(res.Count)
+	return res
+}
+
+// RateAt returns the sum per second over the window ending at on (unix
+// milliseconds)
+func (w *MetricWindow) RateAt(on int64) float64 {
+	return float64(w.SummaryAt(on).Sum) / w.GetDuration().Seconds()
+}
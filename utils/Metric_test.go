@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetric_Set(t *testing.T) {
+	metric := Metric{}
+	metric.Set(5)
+	metric.Set(3)
+	assert.Equal(t, int64(3), metric.Value)
+
+	metric.SetBool(true)
+	assert.True(t, metric.GetBool())
+	metric.SetBool(false)
+	metric.SetBool(true)
+	assert.True(t, metric.GetBool())
+	metric.SetBool(false)
+	assert.False(t, metric.GetBool())
+}
+
+func TestMetricsInitMixin_Gauge(t *testing.T) {
+	metrics := struct {
+		QueueSize *Metric `metric:"gauge"`
+		DropCount *Metric
+		MetricsInitMixin
+	}{}
+	metrics.InitMetrics("Metric.Gauge", &metrics)
+
+	assert.Equal(t, MtGauge, metrics.QueueSize.Type)
+	assert.Equal(t, MtI64, metrics.DropCount.Type)
+
+	// A count that is set (or decremented) is a gauge
+	metrics.DropCount.Inc(2)
+	assert.Equal(t, MtI64, metrics.DropCount.Type)
+	metrics.DropCount.Set(-1)
+	assert.Equal(t, MtGauge, metrics.DropCount.Type)
+}
+
+func TestMetric_CountAt(t *testing.T) {
+	metric := NewMetric("ReceivedRate", MtRate).WithWindow(10, time.Second)
+	on := time.UnixMilli(1_700_000_000_000)
+
+	for second := 0; second < 10; second++ {
+		metric.CountAt(5, on.Add(time.Duration(second)*time.Second))
+	}
+
+	assert.Equal(t, int64(50), metric.Value)
+	assert.Equal(t, 5.0, metric.RateAt(on.Add(9*time.Second)))
+
+	// The first 5 seconds slid out of the window
+	assert.Equal(t, 2.5, metric.RateAt(on.Add(14*time.Second)))
+	assert.Equal(t, 0.0, metric.RateAt(on.Add(time.Minute)))
+
+	// A slot is reused once the window moved past it
+	metric.CountAt(20, on.Add(10*time.Second))
+	assert.Equal(t, 6.5, metric.RateAt(on.Add(10*time.Second)))
+}
+
+func TestMetric_ObserveAt_Window(t *testing.T) {
+	metric := NewMetric("FrameDurationWindow", MtWindow).WithWindow(4, 250*time.Millisecond)
+	on := time.UnixMilli(1_700_000_000_000)
+
+	metric.ObserveAt(10, on)
+	metric.ObserveAt(30, on.Add(100*time.Millisecond))
+	metric.ObserveAt(20, on.Add(600*time.Millisecond))
+
+	summary := metric.SummaryAt(on.Add(900 * time.Millisecond))
+	assert.Equal(t, int64(20), metric.Value)
+	assert.Equal(t, int64(3), summary.Count)
+	assert.Equal(t, int64(10), summary.Min)
+	assert.Equal(t, int64(30), summary.Max)
+	assert.Equal(t, 20.0, summary.Avg)
+
+	summary = metric.SummaryAt(on.Add(1100 * time.Millisecond))
+	assert.Equal(t, int64(1), summary.Count)
+	assert.Equal(t, int64(20), summary.Min)
+
+	assert.Equal(t, MetricSummary{}, metric.SummaryAt(on.Add(time.Hour)))
+}
+
+func TestMetric_ObserveAt_Histogram(t *testing.T) {
+	metric := NewMetric("FrameDurationHistogram", MtHistogram).WithBounds(10, 1, 5)
+	on := time.UnixMilli(1_700_000_000_000)
+
+	for _, value := range []int64{0, 1, 3, 5, 7, 10, 50} {
+		metric.ObserveAt(value, on)
+	}
+
+	assert.Equal(t, []int64{1, 5, 10}, metric.Histogram.Bounds)
+	assert.Equal(t, []int64{2, 2, 2, 1}, metric.Histogram.Counts)
+	assert.Equal(t, []int64{2, 4, 6, 7}, metric.Histogram.Cumulative())
+	assert.Equal(t, int64(7), metric.Histogram.Count)
+	assert.Equal(t, int64(76), metric.Histogram.Sum)
+}
+
+func TestMetric_JSON(t *testing.T) {
+	metric := NewMetric("FrameDurationHistogram", MtHistogram).WithBounds(1, 10)
+	metric.ObserveAt(5, time.UnixMilli(1_700_000_000_000))
+
+	data, err := json.Marshal(metric)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"Type":"HG"`)
+	assert.Contains(t, string(data), `"Histogram":{"Bounds":[1,10],"Cumulative":[0,1,1],"Count":1,"Sum":5,"Avg":5}`)
+	assert.NotContains(t, string(data), `"Window"`)
+
+	res := Metric{}
+	assert.NoError(t, json.Unmarshal(data, &res))
+	assert.Equal(t, MtHistogram, res.Type)
+	assert.Equal(t, int64(5), res.Value)
+
+	// The window is summarised, the raw slots are not returned
+	rate := NewMetric("ReceivedRate", MtRate)
+	rate.CountAt(120, time.Now())
+	data, err = json.Marshal(rate)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"Type":"RT"`)
+	assert.Contains(t, string(data), `"Rate":2`)
+	assert.NotContains(t, string(data), `"Slots"`)
+
+	window := NewMetric("FrameDurationWindow", MtWindow)
+	window.ObserveAt(4, time.Now())
+	data, err = json.Marshal(window)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"Summary":{"Count":1,"Sum":4,"Min":4,"Max":4,"Avg":4}`)
+}
+
+func TestMetricsInitMixin_Kinds(t *testing.T) {
+	metrics := struct {
+		FrameRate              *Metric
+		FrameDurationWindow    *Metric
+		FrameDurationHistogram *Metric
+		FrameDuration          *Metric
+		MetricsInitMixin
+	}{}
+	metrics.InitMetrics("Metric.Kinds", &metrics)
+
+	assert.Equal(t, MtRate, metrics.FrameRate.Type)
+	assert.Equal(t, MtWindow, metrics.FrameDurationWindow.Type)
+	assert.Equal(t, MtHistogram, metrics.FrameDurationHistogram.Type)
+	assert.Equal(t, MtMilliDuration, metrics.FrameDuration.Type)
+}
@@ -1,8 +1,20 @@
 package utils
 
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Metrics is a section of metrics.  The Metric map is guarded by the lock,
+// the metrics are put on the service goroutines while the api and the
+// snapshots list them
 type Metrics struct {
 	Name   string `json:"-"`
 	Metric map[string]*Metric
+	lock   sync.RWMutex
 }
 
 func (m *Metrics) Init(sectionName string) {
@@ -11,6 +23,9 @@ func (m *Metrics) Init(sectionName string) {
 }
 
 func (m *Metrics) GetOrPut(name string, dataType MetricType) *Metric {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
 	if m.Metric == nil {
 		m.Init(name)
 	}
@@ -19,19 +34,42 @@ func (m *Metrics) GetOrPut(name string, dataType MetricType) *Metric {
 		return metric
 	}
 
-	metric := new(Metric)
-	metric.Type = dataType
-	metric.IsSet = false
-	metric.Name = name
+	metric := NewMetric(name, dataType)
 	m.Metric[name] = metric
 
 	return metric
 }
 
 func (m *Metrics) Get(name string) *Metric {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
 	return m.Metric[name]
 }
 
+// List returns the metrics ordered by name
+func (m *Metrics) List() []*Metric {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	res := slices.Collect(maps.Values(m.Metric))
+	slices.SortFunc(res, func(a, b *Metric) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return res
+}
+
+// MarshalJSON returns the metrics of the section, copied under the lock
+func (m *Metrics) MarshalJSON() ([]byte, error) {
+	m.lock.RLock()
+	res := struct {
+		Metric map[string]*Metric
+	}{Metric: maps.Clone(m.Metric)}
+	m.lock.RUnlock()
+
+	return json.Marshal(&res)
+}
+
 //func (s *Metrics) MarshalJSON() ([]byte, error) {
 //	dataPointers := make([]*GetOrPut, 0, len(s.Metric))
 //
@@ -21,7 +21,17 @@ func (m *MetricsInitMixin) InitMetrics(sectionName string, source any) {
 
 			var metric *Metric
 
-			if strings.Contains(fieldName, "Time") {
+			// A gauge by tag (metric:"gauge"), then the kinds with a window or
+			// histogram, e.g. FrameDurationHistogram
+			if elem.Type().Field(i).Tag.Get("metric") == "gauge" {
+				metric = gm.Metric(sectionName, fieldName, MtGauge)
+			} else if strings.HasSuffix(fieldName, "Histogram") {
+				metric = gm.Metric(sectionName, fieldName, MtHistogram)
+			} else if strings.HasSuffix(fieldName, "Window") {
+				metric = gm.Metric(sectionName, fieldName, MtWindow)
+			} else if strings.HasSuffix(fieldName, "Rate") {
+				metric = gm.Metric(sectionName, fieldName, MtRate)
+			} else if strings.Contains(fieldName, "Time") {
 				metric = gm.Metric(sectionName, fieldName, MtMilliTime)
 			} else if strings.Contains(fieldName, "Dur") {
 				metric = gm.Metric(sectionName, fieldName, MtMilliDuration)
@@ -0,0 +1,385 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// OpenMetricsContentType is the content type of the OpenMetrics exposition,
+// PrometheusContentType the one of the (older) Prometheus text exposition
+const OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// ErrMetricFamilyCollision is returned by Write when a family name is used
+// with more than one type
+var ErrMetricFamilyCollision = errors.New("metric family name used with more than one type")
+
+var sectionIPRegEx = regexp.MustCompile(`[-.]?(\d{1,3}\.){3}\d{1,3}(:\d+)?`)
+
+// openMetrics writes the GlobalMetrics in the Prometheus (text) and
+// OpenMetrics exposition formats.  The metric name becomes the family name
+// (prefix_snake_case), the section becomes the service label and the radar
+// ip (when part of the section name) the radar_ip label.
+//
+// The MetricType determines the family type:
+//   - MtI64 is a counter
+//   - MtGauge is a gauge (an MtI64 that was set or decremented)
+//   - MtMilliTime is a gauge holding the unix time in seconds
+//   - MtMilliDuration is a gauge holding the duration in seconds
+//   - MtBool is a gauge holding 0 or 1
+//   - MtRate is a counter, with the _per_second gauge over the window
+//   - MtWindow is a gauge, with the _min, _max and _avg gauges over the window
+//   - MtHistogram is a histogram of milliseconds exposed in seconds
+//
+// The FirstOn and LastOn of every metric set are exposed as the
+// prefix_metric_first_on_seconds and prefix_metric_last_on_seconds gauges.
+//
+// A family name used with two types (e.g. ReadCount a counter in one section
+// and a gauge in another) keeps the type first added, the samples of the
+// other type are dropped and reported in the error of Write
+type openMetrics struct{}
+
+var OpenMetrics openMetrics
+
+type openMetricsSample struct {
+	suffix string
+	labels string
+	value  string
+}
+
+type openMetricsFamily struct {
+	name       string
+	help       string
+	metricType string
+	samples    []openMetricsSample
+	collisions []string
+}
+
+// Write writes the sections using the prefix (e.g. rvpro) for the family
+// names.  The families are written regardless of the collisions returned
+func (openMetrics) Write(writer io.Writer, sections []*Metrics, prefix string, isOpenMetrics bool) error {
+	families := make(map[string]*openMetricsFamily)
+	now := time.Now()
+
+	for _, section := range sections {
+		service, radarIP := OpenMetrics.SplitSection(section.Name)
+		labels := OpenMetrics.labels("service", service, "radar_ip", radarIP)
+
+		for _, metric := range section.List() {
+			metricName := metric.Name
+			snakeName := OpenMetrics.SnakeCase(metricName)
+
+			switch metric.Type {
+			case MtRate:
+				OpenMetrics.addRate(families, prefix+"_"+snakeName, metricName, service, radarIP, metric, now)
+
+			case MtWindow:
+				OpenMetrics.addWindow(families, prefix+"_"+snakeName, metricName, service, radarIP, metric, now)
+
+			case MtHistogram:
+				OpenMetrics.addHistogram(families, prefix+"_"+snakeName+"_seconds", metricName, service, radarIP, metric)
+
+			default:
+				familyName, metricType, value := OpenMetrics.sample(prefix, snakeName, metric)
+				OpenMetrics.add(families, familyName, metricType, metricName, labels, value)
+			}
+
+			if !metric.IsSet {
+				continue
+			}
+
+			metricLabels := OpenMetrics.labels("service", service, "radar_ip", radarIP, "metric", snakeName)
+			OpenMetrics.add(
+				families,
+				prefix+"_metric_first_on_seconds",
+				"gauge",
+				"First update of the metric",
+				metricLabels,
+				OpenMetrics.seconds(metric.FirstOn),
+			)
+			OpenMetrics.add(
+				families,
+				prefix+"_metric_last_on_seconds",
+				"gauge",
+				"Last update of the metric",
+				metricLabels,
+				OpenMetrics.seconds(metric.LastOn),
+			)
+		}
+	}
+
+	buffered := bufio.NewWriter(writer)
+	var collisions []string
+
+	for _, name := range slices.Sorted(maps.Keys(families)) {
+		family := families[name]
+		OpenMetrics.writeFamily(buffered, family, isOpenMetrics)
+
+		for _, collision := range family.collisions {
+			collisions = append(collisions, fmt.Sprintf("%s %s (%s)", name, family.metricType, collision))
+		}
+	}
+
+	if isOpenMetrics {
+		_, _ = buffered.WriteString("# EOF\n")
+	}
+
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+
+	if len(collisions) > 0 {
+		return fmt.Errorf("%w: %s", ErrMetricFamilyCollision, strings.Join(collisions, ", "))
+	}
+	return nil
+}
+
+// SplitSection splits the section name into the service and the radar ip,
+// e.g. UDP.Broker.192.168.11.12:55555 is UDP.Broker and 192.168.11.12:55555
+func (openMetrics) SplitSection(sectionName string) (service string, radarIP string) {
+	location := sectionIPRegEx.FindStringIndex(sectionName)
+	if location == nil {
+		return sectionName, ""
+	}
+
+	radarIP = strings.TrimLeft(sectionName[location[0]:location[1]], "-.")
+	service = sectionName[:location[0]] + sectionName[location[1]:]
+	return strings.Trim(service, "-."), radarIP
+}
+
+// SnakeCase converts the metric name (ReceivedCount, ErrUDPRead) into a
+// family name (received_count, err_udp_read)
+func (openMetrics) SnakeCase(name string) string {
+	res := strings.Builder{}
+	res.Grow(len(name) + 8)
+	runes := []rune(name)
+
+	for index, r := range runes {
+		switch {
+		case unicode.IsUpper(r):
+			if index > 0 && res.Len() > 0 {
+				previous := runes[index-1]
+				isNextLower := index+1 < len(runes) && unicode.IsLower(runes[index+1])
+
+				if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && isNextLower) {
+					res.WriteByte('_')
+				}
+			}
+			res.WriteRune(unicode.ToLower(r))
+
+		case unicode.IsLower(r) || unicode.IsDigit(r):
+			res.WriteRune(r)
+
+		default:
+			if res.Len() > 0 && !strings.HasSuffix(res.String(), "_") {
+				res.WriteByte('_')
+			}
+		}
+	}
+	return strings.Trim(res.String(), "_")
+}
+
+func (openMetrics) sample(prefix string, snakeName string, metric *Metric) (string, string, string) {
+	switch metric.Type {
+	case MtMilliTime:
+		return prefix + "_" + snakeName + "_seconds", "gauge", OpenMetrics.seconds(metric.Value)
+
+	case MtMilliDuration:
+		return prefix + "_" + snakeName + "_seconds", "gauge", OpenMetrics.seconds(metric.Value)
+
+	case MtBool:
+		if metric.GetBool() {
+			return prefix + "_" + snakeName, "gauge", "1"
+		}
+		return prefix + "_" + snakeName, "gauge", "0"
+
+	case MtI64:
+		return prefix + "_" + snakeName, "counter", strconv.FormatInt(metric.Value, 10)
+
+	case MtGauge:
+		return prefix + "_" + snakeName, "gauge", strconv.FormatInt(metric.Value, 10)
+	}
+	return prefix + "_" + snakeName, "unknown", strconv.FormatInt(metric.Value, 10)
+}
+
+func (openMetrics) addRate(
+	families map[string]*openMetricsFamily,
+	name string,
+	help string,
+	service string,
+	radarIP string,
+	metric *Metric,
+	now time.Time,
+) {
+	labels := OpenMetrics.labels("service", service, "radar_ip", radarIP)
+
+	OpenMetrics.add(families, name, "counter", help, labels, strconv.FormatInt(metric.Value, 10))
+	OpenMetrics.add(families, name+"_per_second", "gauge", help+" per second", labels, OpenMetrics.float(metric.RateAt(now)))
+}
+
+func (openMetrics) addWindow(
+	families map[string]*openMetricsFamily,
+	name string,
+	help string,
+	service string,
+	radarIP string,
+	metric *Metric,
+	now time.Time,
+) {
+	labels := OpenMetrics.labels("service", service, "radar_ip", radarIP)
+	summary := metric.SummaryAt(now)
+
+	OpenMetrics.add(families, name, "gauge", help, labels, strconv.FormatInt(metric.Value, 10))
+	OpenMetrics.add(families, name+"_min", "gauge", help+" window minimum", labels, strconv.FormatInt(summary.Min, 10))
+	OpenMetrics.add(families, name+"_max", "gauge", help+" window maximum", labels, strconv.FormatInt(summary.Max, 10))
+	OpenMetrics.add(families, name+"_avg", "gauge", help+" window average", labels, OpenMetrics.float(summary.Avg))
+}
+
+// addHistogram adds the cumulative buckets (le is the bound in seconds), the
+// sum and the count of the histogram
+func (openMetrics) addHistogram(
+	families map[string]*openMetricsFamily,
+	name string,
+	help string,
+	service string,
+	radarIP string,
+	metric *Metric,
+) {
+	histogram := metric.GetHistogram()
+	if histogram == nil {
+		histogram = NewMetricHistogram()
+	}
+
+	labels := OpenMetrics.labels("service", service, "radar_ip", radarIP)
+	cumulative := histogram.Cumulative()
+
+	for index, count := range cumulative {
+		le := "+Inf"
+		if index < len(histogram.Bounds) {
+			le = OpenMetrics.seconds(histogram.Bounds[index])
+		}
+
+		OpenMetrics.addSample(
+			families,
+			name,
+			"histogram",
+			help,
+			"_bucket",
+			OpenMetrics.labels("service", service, "radar_ip", radarIP, "le", le),
+			strconv.FormatInt(count, 10),
+		)
+	}
+
+	OpenMetrics.addSample(families, name, "histogram", help, "_sum", labels, OpenMetrics.seconds(histogram.Sum))
+	OpenMetrics.addSample(families, name, "histogram", help, "_count", labels, strconv.FormatInt(histogram.Count, 10))
+}
+
+func (openMetrics) add(
+	families map[string]*openMetricsFamily,
+	name string,
+	metricType string,
+	help string,
+	labels string,
+	value string,
+) {
+	OpenMetrics.addSample(families, name, metricType, help, "", labels, value)
+}
+
+func (openMetrics) addSample(
+	families map[string]*openMetricsFamily,
+	name string,
+	metricType string,
+	help string,
+	suffix string,
+	labels string,
+	value string,
+) {
+	family, ok := families[name]
+	if !ok {
+		family = &openMetricsFamily{name: name, help: help, metricType: metricType}
+		families[name] = family
+	}
+
+	if family.metricType != metricType {
+		collision := help + " " + metricType
+		if !slices.Contains(family.collisions, collision) {
+			family.collisions = append(family.collisions, collision)
+		}
+		return
+	}
+	family.samples = append(family.samples, openMetricsSample{suffix: suffix, labels: labels, value: value})
+}
+
+func (openMetrics) writeFamily(writer *bufio.Writer, family *openMetricsFamily, isOpenMetrics bool) {
+	sampleName := family.name
+	typeName := family.name
+
+	// A counter sample carries the _total suffix, OpenMetrics omits the
+	// suffix from the family name
+	if family.metricType == "counter" {
+		sampleName += "_total"
+		if !isOpenMetrics {
+			typeName = sampleName
+		}
+	}
+
+	_, _ = writer.WriteString("# HELP " + typeName + " " + OpenMetrics.escape(family.help, false) + "\n")
+	_, _ = writer.WriteString("# TYPE " + typeName + " " + family.metricType + "\n")
+
+	for _, sample := range family.samples {
+		_, _ = writer.WriteString(sampleName + sample.suffix + sample.labels + " " + sample.value + "\n")
+	}
+}
+
+// labels formats the name value pairs, omitting the empty values
+func (openMetrics) labels(nameValues ...string) string {
+	res := strings.Builder{}
+
+	for index := 0; index+1 < len(nameValues); index += 2 {
+		if len(nameValues[index+1]) == 0 {
+			continue
+		}
+
+		if res.Len() == 0 {
+			res.WriteByte('{')
+		} else {
+			res.WriteByte(',')
+		}
+		res.WriteString(nameValues[index])
+		res.WriteString("=\"")
+		res.WriteString(OpenMetrics.escape(nameValues[index+1], true))
+		res.WriteByte('"')
+	}
+
+	if res.Len() > 0 {
+		res.WriteByte('}')
+	}
+	return res.String()
+}
+
+func (openMetrics) escape(value string, isLabel bool) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+
+	if isLabel {
+		value = strings.ReplaceAll(value, `"`, `\"`)
+	}
+	return value
+}
+
+func (openMetrics) seconds(milliseconds int64) string {
+	return strconv.FormatFloat(float64(milliseconds)/1000, 'f', -1, 64)
+}
+
+func (openMetrics) float(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenMetrics_SnakeCase(t *testing.T) {
+	assert.Equal(t, "received_count", OpenMetrics.SnakeCase("ReceivedCount"))
+	assert.Equal(t, "err_udp_read", OpenMetrics.SnakeCase("ErrUDPRead"))
+	assert.Equal(t, "crc_error", OpenMetrics.SnakeCase("CRCError"))
+	assert.Equal(t, "drift_ppm", OpenMetrics.SnakeCase("DriftPPM"))
+	assert.Equal(t, "last_on", OpenMetrics.SnakeCase("Last.On"))
+}
+
+func TestOpenMetrics_SplitSection(t *testing.T) {
+	service, radarIP := OpenMetrics.SplitSection("UDP.Broker.192.168.11.12:55555")
+	assert.Equal(t, "UDP.Broker", service)
+	assert.Equal(t, "192.168.11.12:55555", radarIP)
+
+	service, radarIP = OpenMetrics.SplitSection("Ping-192.168.11.13")
+	assert.Equal(t, "Ping", service)
+	assert.Equal(t, "192.168.11.13", radarIP)
+
+	service, radarIP = OpenMetrics.SplitSection("SDLC.Service")
+	assert.Equal(t, "SDLC.Service", service)
+	assert.Equal(t, "", radarIP)
+}
+
+func TestOpenMetrics_Write(t *testing.T) {
+	metrics := globalMetrics{}
+	metrics.Init()
+
+	on := time.UnixMilli(1_700_000_000_500)
+	metrics.Metric("UDP.Broker.192.168.11.12:55555", "ReceivedCount", MtI64).IncAt(5, on)
+	metrics.Metric("UDP.Broker.192.168.11.13:55555", "ReceivedCount", MtI64).IncAt(7, on)
+	metrics.Metric("SDLC.Service", "IsWriteEnabled", MtBool).SetAt(1, on)
+	metrics.Metric("SDLC.Service", "StartTime", MtMilliTime).SetAt(1_700_000_000_250, on)
+	metrics.Metric("SDLC.Service", "ReadDur", MtMilliDuration).SetAt(1500, on)
+	metrics.Metric("SDLC.Service", "Pops", MtI64)
+
+	text := strings.Builder{}
+	assert.NoError(t, OpenMetrics.Write(&text, metrics.Sections(), "rvpro", false))
+	prometheus := text.String()
+
+	assert.Contains(t, prometheus, "# TYPE rvpro_received_count_total counter\n")
+	assert.Contains(t, prometheus,
+		"rvpro_received_count_total{service=\"UDP.Broker\",radar_ip=\"192.168.11.12:55555\"} 5\n"+
+			"rvpro_received_count_total{service=\"UDP.Broker\",radar_ip=\"192.168.11.13:55555\"} 7\n")
+	assert.Contains(t, prometheus, "# TYPE rvpro_is_write_enabled gauge\n")
+	assert.Contains(t, prometheus, "rvpro_is_write_enabled{service=\"SDLC.Service\"} 1\n")
+	assert.Contains(t, prometheus, "rvpro_start_time_seconds{service=\"SDLC.Service\"} 1700000000.25\n")
+	assert.Contains(t, prometheus, "rvpro_read_dur_seconds{service=\"SDLC.Service\"} 1.5\n")
+	assert.Contains(t, prometheus, "rvpro_pops_total{service=\"SDLC.Service\"} 0\n")
+	assert.Contains(t, prometheus,
+		"rvpro_metric_last_on_seconds{service=\"SDLC.Service\",metric=\"read_dur\"} 1700000000.5\n")
+	assert.NotContains(t, prometheus, "metric=\"pops\"")
+	assert.NotContains(t, prometheus, "# EOF")
+
+	text.Reset()
+	assert.NoError(t, OpenMetrics.Write(&text, metrics.Sections(), "rvpro", true))
+	openMetrics := text.String()
+
+	assert.Contains(t, openMetrics, "# TYPE rvpro_received_count counter\n")
+	assert.Contains(t, openMetrics, "rvpro_received_count_total{")
+	assert.True(t, strings.HasSuffix(openMetrics, "# EOF\n"))
+}
+
+func TestOpenMetrics_Write_Gauge(t *testing.T) {
+	metrics := globalMetrics{}
+	metrics.Init()
+
+	on := time.UnixMilli(1_700_000_000_500)
+	metrics.Metric("SDLC.TimeSource.Service", "OffsetMillis", MtI64).SetAt(-250, on)
+	metrics.Metric("UDP.Broker.192.168.11.12:55555", "QueueCount", MtI64).IncAt(3, on)
+	metrics.Metric("UDP.Broker.192.168.11.13:55555", "QueueCount", MtI64).SetAt(4, on)
+
+	text := strings.Builder{}
+	err := OpenMetrics.Write(&text, metrics.Sections(), "rvpro", false)
+	prometheus := text.String()
+
+	assert.Contains(t, prometheus, "# TYPE rvpro_offset_millis gauge\n")
+	assert.Contains(t, prometheus, "rvpro_offset_millis{service=\"SDLC.TimeSource.Service\"} -250\n")
+
+	// The gauge of .13 collides with the counter of .12, and is reported
+	assert.ErrorIs(t, err, ErrMetricFamilyCollision)
+	assert.ErrorContains(t, err, "rvpro_queue_count counter (QueueCount gauge)")
+	assert.Contains(t, prometheus, "rvpro_queue_count_total{service=\"UDP.Broker\",radar_ip=\"192.168.11.12:55555\"} 3\n")
+	assert.NotContains(t, prometheus, "radar_ip=\"192.168.11.13:55555\"} 4")
+}
+
+func TestOpenMetrics_Write_Kinds(t *testing.T) {
+	metrics := globalMetrics{}
+	metrics.Init()
+
+	on := time.Now()
+	metrics.Metric("Workflow.Executor", "ProcessedRate", MtRate).CountAt(3, on)
+	metrics.Metric("Workflow.Executor", "ProcessedDurationWindow", MtWindow).ObserveAt(4, on)
+	histogram := metrics.Metric("Workflow.Executor", "ProcessedDurationHistogram", MtHistogram).WithBounds(1, 10)
+	histogram.ObserveAt(5, on)
+	histogram.ObserveAt(50, on)
+
+	text := strings.Builder{}
+	assert.NoError(t, OpenMetrics.Write(&text, metrics.Sections(), "rvpro", false))
+	prometheus := text.String()
+
+	assert.Contains(t, prometheus, "rvpro_processed_rate_total{service=\"Workflow.Executor\"} 3\n")
+	assert.Contains(t, prometheus, "rvpro_processed_rate_per_second{service=\"Workflow.Executor\"} 0.05\n")
+	assert.Contains(t, prometheus, "rvpro_processed_duration_window_max{service=\"Workflow.Executor\"} 4\n")
+	assert.Contains(t, prometheus, "rvpro_processed_duration_window_avg{service=\"Workflow.Executor\"} 4\n")
+	assert.Contains(t, prometheus, "# TYPE rvpro_processed_duration_histogram_seconds histogram\n"+
+		"rvpro_processed_duration_histogram_seconds_bucket{service=\"Workflow.Executor\",le=\"0.001\"} 0\n"+
+		"rvpro_processed_duration_histogram_seconds_bucket{service=\"Workflow.Executor\",le=\"0.01\"} 1\n"+
+		"rvpro_processed_duration_histogram_seconds_bucket{service=\"Workflow.Executor\",le=\"+Inf\"} 2\n"+
+		"rvpro_processed_duration_histogram_seconds_sum{service=\"Workflow.Executor\"} 0.055\n"+
+		"rvpro_processed_duration_histogram_seconds_count{service=\"Workflow.Executor\"} 2\n")
+}
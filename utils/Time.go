@@ -1,9 +1,13 @@
 package utils
 
 import (
+	"sync/atomic"
 	"time"
 )
 
+// clockOffset is the offset (nanoseconds) between the reference clock (e.g.
+// the cabinet controller) and the system clock, see Time.Corrected
+var clockOffset atomic.Int64
 var tzOffset int
 var tzName string
 var lastTime time.Time
@@ -60,3 +64,23 @@ func (timeUtil) Exact() time.Time {
 func (timeUtil) Approx() time.Time {
 	return lastTime
 }
+
+// SetClockOffset sets the offset of the reference clock against the system
+// clock.  The offset is applied by Corrected and Correct
+func (timeUtil) SetClockOffset(offset time.Duration) {
+	clockOffset.Store(int64(offset))
+}
+
+func (timeUtil) GetClockOffset() time.Duration {
+	return time.Duration(clockOffset.Load())
+}
+
+// Corrected returns the system time adjusted to the reference clock
+func (timeUtil) Corrected() time.Time {
+	return time.Now().Add(time.Duration(clockOffset.Load()))
+}
+
+// Correct adjusts a system time to the reference clock
+func (timeUtil) Correct(tm time.Time) time.Time {
+	return tm.Add(time.Duration(clockOffset.Load()))
+}
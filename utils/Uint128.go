@@ -17,6 +17,17 @@ func (u Uint128) MarshalJSON() ([]byte, error) {
 	return json.Marshal(fmt.Sprintf("%016x:%016x", u.Hi, u.Lo))
 }
 
+func (u *Uint128) UnmarshalJSON(data []byte) error {
+	var value string
+
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	_, err := fmt.Sscanf(value, "%016x:%016x", &u.Hi, &u.Lo)
+	return err
+}
+
 type callback func(int, bool)
 
 func (u Uint128) Byte(index int) byte {
@@ -1,3 +1,4 @@
 package utils
 
 const Kilobyte = 1024
+const Megabyte = 1024 * Kilobyte
@@ -0,0 +1,118 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rvpro3/radarvision.com/utils"
+)
+
+func TestPcap_RoundTrip(t *testing.T) {
+	var buffer bytes.Buffer
+
+	radar := utils.IP4Builder.FromString("192.168.11.12:55555")
+	local := utils.IP4Builder.FromString("192.168.11.1:55555")
+	now := time.Unix(1700000000, 123456789)
+
+	writer, err := NewWriter(&buffer)
+	assert.NoError(t, err)
+	assert.NoError(t, writer.WritePacket(now, radar, local, []byte("hello")))
+	assert.NoError(t, writer.WritePacket(now.Add(time.Millisecond), local, radar, []byte("world!")))
+	assert.NoError(t, writer.Close())
+
+	reader, err := NewReader(&buffer)
+	assert.NoError(t, err)
+
+	packet, err := reader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, now, packet.Timestamp)
+	assert.Equal(t, radar, packet.Source)
+	assert.Equal(t, local, packet.Target)
+	assert.Equal(t, "hello", string(packet.Payload))
+
+	packet, err = reader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(time.Millisecond), packet.Timestamp)
+	assert.Equal(t, local, packet.Source)
+	assert.Equal(t, "world!", string(packet.Payload))
+
+	_, err = reader.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestPcap_ReadPcapNG(t *testing.T) {
+	var buffer bytes.Buffer
+	le := binary.LittleEndian
+
+	writeBlock := func(blockType uint32, body []byte) {
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+		blockLen := uint32(12 + len(body))
+		_ = binary.Write(&buffer, le, blockType)
+		_ = binary.Write(&buffer, le, blockLen)
+		buffer.Write(body)
+		_ = binary.Write(&buffer, le, blockLen)
+	}
+
+	// Section header
+	shb := le.AppendUint32(nil, ngByteOrderMagic)
+	shb = le.AppendUint16(shb, 1)
+	shb = le.AppendUint16(shb, 0)
+	shb = le.AppendUint64(shb, 0xffffffffffffffff)
+	writeBlock(ngSectionHeaderBlock, shb)
+
+	// Ethernet interface with nanosecond resolution
+	idb := le.AppendUint16(nil, linkTypeEthernet)
+	idb = le.AppendUint16(idb, 0)
+	idb = le.AppendUint32(idb, snapLen)
+	idb = le.AppendUint16(idb, ngOptionTsResolution)
+	idb = le.AppendUint16(idb, 1)
+	idb = append(idb, 9, 0, 0, 0)
+	idb = le.AppendUint32(idb, 0)
+	writeBlock(ngInterfaceBlock, idb)
+
+	// Build the IPv4 frame using the writer, then strip the pcap headers
+	var raw bytes.Buffer
+	radar := utils.IP4Builder.FromString("10.0.0.5:55555")
+	local := utils.IP4Builder.FromString("10.0.0.1:55556")
+	writer, _ := NewWriter(&raw)
+	_ = writer.WritePacket(time.Now(), radar, local, []byte("payload"))
+	_ = writer.Flush()
+	ipFrame := raw.Bytes()[24+16:]
+
+	frame := make([]byte, 12, 14+len(ipFrame))
+	frame = binary.BigEndian.AppendUint16(frame, etherTypeIPv4)
+	frame = append(frame, ipFrame...)
+
+	ts := uint64(1700000000_000000123)
+	epb := le.AppendUint32(nil, 0)
+	epb = le.AppendUint32(epb, uint32(ts>>32))
+	epb = le.AppendUint32(epb, uint32(ts))
+	epb = le.AppendUint32(epb, uint32(len(frame)))
+	epb = le.AppendUint32(epb, uint32(len(frame)))
+	epb = append(epb, frame...)
+	writeBlock(ngEnhancedPacketBlock, epb)
+
+	reader, err := NewReader(&buffer)
+	assert.NoError(t, err)
+
+	packet, err := reader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Unix(0, int64(ts)), packet.Timestamp)
+	assert.Equal(t, radar, packet.Source)
+	assert.Equal(t, local, packet.Target)
+	assert.Equal(t, "payload", string(packet.Payload))
+
+	_, err = reader.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestPcap_UnknownFormat(t *testing.T) {
+	_, err := NewReader(bytes.NewReader([]byte("not a capture file")))
+	assert.Equal(t, ErrUnknownFormat, err)
+}
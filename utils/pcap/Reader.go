@@ -0,0 +1,420 @@
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"rvpro3/radarvision.com/utils"
+)
+
+const ngSectionHeaderBlock = 0x0A0D0D0A
+const ngInterfaceBlock = 0x00000001
+const ngSimplePacketBlock = 0x00000003
+const ngEnhancedPacketBlock = 0x00000006
+const ngByteOrderMagic = 0x1A2B3C4D
+const ngOptionTsResolution = 9
+
+const linkTypeNull = 0
+const linkTypeEthernet = 1
+const linkTypeRaw = 101
+const linkTypeLinuxSLL = 113
+const linkTypeLinuxSLL2 = 276
+
+const etherTypeIPv4 = 0x0800
+const etherTypeVLAN = 0x8100
+
+const maxBlockLen = 16 * 1024 * 1024
+
+var ErrUnknownFormat = errors.New("pcap unknown file format")
+var ErrBlockFormat = errors.New("pcap invalid block format")
+
+// Packet is a single UDP datagram read from a capture.  The Payload is only
+// valid up until the next call to Reader.Next
+type Packet struct {
+	Timestamp time.Time
+	Source    utils.IP4
+	Target    utils.IP4
+	Payload   []byte
+}
+
+type ngInterface struct {
+	LinkType   uint16
+	Resolution float64
+}
+
+// Reader reads the UDP datagrams from a classic pcap or a pcapng capture.
+// Packets that are not IPv4/UDP (or fragmented) are skipped.  Supported link
+// types are Ethernet (with VLAN), raw IPv4, Linux cooked (v1 and v2) and BSD
+// loopback
+type Reader struct {
+	reader     *bufio.Reader
+	closer     io.Closer
+	order      binary.ByteOrder
+	isNG       bool
+	isNano     bool
+	linkType   uint16
+	interfaces []ngInterface
+	buffer     []byte
+	Skipped    int
+}
+
+// Open opens the capture file and reads the file header
+func Open(fileName string) (*Reader, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := NewReader(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	res.closer = file
+	return res, nil
+}
+
+// NewReader reads the file header from source and determines the format
+func NewReader(source io.Reader) (*Reader, error) {
+	res := &Reader{
+		reader: bufio.NewReaderSize(source, 64*utils.Kilobyte),
+		buffer: make([]byte, snapLen),
+	}
+
+	magic, err := res.reader.Peek(4)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case binary.LittleEndian.Uint32(magic) == ngSectionHeaderBlock:
+		res.isNG = true
+		return res, nil
+
+	case binary.LittleEndian.Uint32(magic) == magicMicro:
+		res.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(magic) == magicMicro:
+		res.order = binary.BigEndian
+	case binary.LittleEndian.Uint32(magic) == magicNano:
+		res.order = binary.LittleEndian
+		res.isNano = true
+	case binary.BigEndian.Uint32(magic) == magicNano:
+		res.order = binary.BigEndian
+		res.isNano = true
+	default:
+		return nil, ErrUnknownFormat
+	}
+
+	var header [24]byte
+	if _, err = io.ReadFull(res.reader, header[:]); err != nil {
+		return nil, err
+	}
+
+	res.linkType = uint16(res.order.Uint32(header[20:]))
+	return res, nil
+}
+
+// Close closes the file (if opened with Open)
+func (r *Reader) Close() error {
+	if r.closer != nil {
+		err := r.closer.Close()
+		r.closer = nil
+		return err
+	}
+	return nil
+}
+
+// Next returns the next UDP datagram, or io.EOF at the end of the capture
+func (r *Reader) Next() (res Packet, err error) {
+	for {
+		var frame []byte
+		var linkType uint16
+
+		if r.isNG {
+			frame, linkType, res.Timestamp, err = r.nextBlock()
+		} else {
+			frame, linkType, res.Timestamp, err = r.nextRecord()
+		}
+
+		if err != nil {
+			return res, err
+		}
+
+		if frame != nil && r.decode(&res, frame, linkType) {
+			return res, nil
+		}
+
+		r.Skipped++
+	}
+}
+
+func (r *Reader) nextRecord() ([]byte, uint16, time.Time, error) {
+	var header [16]byte
+
+	if _, err := io.ReadFull(r.reader, header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, 0, time.Time{}, io.EOF
+		}
+		return nil, 0, time.Time{}, err
+	}
+
+	seconds := int64(r.order.Uint32(header[0:]))
+	fraction := int64(r.order.Uint32(header[4:]))
+	capLen := int(r.order.Uint32(header[8:]))
+
+	if capLen > maxBlockLen {
+		return nil, 0, time.Time{}, ErrBlockFormat
+	}
+
+	if !r.isNano {
+		fraction *= int64(time.Microsecond)
+	}
+
+	frame := r.grow(capLen)
+	if _, err := io.ReadFull(r.reader, frame); err != nil {
+		return nil, 0, time.Time{}, io.EOF
+	}
+
+	return frame, r.linkType, time.Unix(seconds, fraction), nil
+}
+
+// nextBlock reads the next pcapng block, returning a nil frame for blocks
+// that do not contain packet data
+func (r *Reader) nextBlock() ([]byte, uint16, time.Time, error) {
+	var header [8]byte
+
+	if _, err := io.ReadFull(r.reader, header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, 0, time.Time{}, io.EOF
+		}
+		return nil, 0, time.Time{}, err
+	}
+
+	blockType := binary.LittleEndian.Uint32(header[0:])
+
+	// The byte order is only known once the section header has been read
+	if blockType == ngSectionHeaderBlock {
+		return nil, 0, time.Time{}, r.readSectionHeader(header[4:])
+	}
+
+	if r.order == nil {
+		return nil, 0, time.Time{}, ErrBlockFormat
+	}
+
+	blockType = r.order.Uint32(header[0:])
+	blockLen := int(r.order.Uint32(header[4:]))
+
+	if blockLen < 12 || blockLen > maxBlockLen || blockLen%4 != 0 {
+		return nil, 0, time.Time{}, ErrBlockFormat
+	}
+
+	body := r.grow(blockLen - 8)
+	if _, err := io.ReadFull(r.reader, body); err != nil {
+		return nil, 0, time.Time{}, io.EOF
+	}
+	body = body[:len(body)-4]
+
+	switch blockType {
+	case ngInterfaceBlock:
+		return nil, 0, time.Time{}, r.readInterface(body)
+
+	case ngEnhancedPacketBlock:
+		if len(body) < 20 {
+			return nil, 0, time.Time{}, ErrBlockFormat
+		}
+
+		index := int(r.order.Uint32(body[0:]))
+		if index >= len(r.interfaces) {
+			return nil, 0, time.Time{}, ErrBlockFormat
+		}
+
+		ts := uint64(r.order.Uint32(body[4:]))<<32 | uint64(r.order.Uint32(body[8:]))
+		capLen := int(r.order.Uint32(body[12:]))
+		if capLen > len(body)-20 {
+			return nil, 0, time.Time{}, ErrBlockFormat
+		}
+
+		ifc := r.interfaces[index]
+		return body[20 : 20+capLen], ifc.LinkType, r.toTime(ts, ifc.Resolution), nil
+
+	case ngSimplePacketBlock:
+		if len(r.interfaces) == 0 || len(body) < 4 {
+			return nil, 0, time.Time{}, ErrBlockFormat
+		}
+
+		capLen := min(int(r.order.Uint32(body[0:])), len(body)-4)
+		return body[4 : 4+capLen], r.interfaces[0].LinkType, time.Time{}, nil
+	}
+
+	return nil, 0, time.Time{}, nil
+}
+
+func (r *Reader) readSectionHeader(lenBytes []byte) error {
+	var magic [4]byte
+
+	if _, err := io.ReadFull(r.reader, magic[:]); err != nil {
+		return ErrBlockFormat
+	}
+
+	switch {
+	case binary.LittleEndian.Uint32(magic[:]) == ngByteOrderMagic:
+		r.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(magic[:]) == ngByteOrderMagic:
+		r.order = binary.BigEndian
+	default:
+		return ErrUnknownFormat
+	}
+
+	blockLen := int(r.order.Uint32(lenBytes))
+	if blockLen < 28 || blockLen > maxBlockLen {
+		return ErrBlockFormat
+	}
+
+	// Interfaces are scoped to the section
+	r.interfaces = r.interfaces[:0]
+
+	if _, err := io.ReadFull(r.reader, r.grow(blockLen-12)); err != nil {
+		return io.EOF
+	}
+	return nil
+}
+
+func (r *Reader) readInterface(body []byte) error {
+	if len(body) < 8 {
+		return ErrBlockFormat
+	}
+
+	ifc := ngInterface{
+		LinkType:   r.order.Uint16(body[0:]),
+		Resolution: 1e-6,
+	}
+
+	options := body[8:]
+	for len(options) >= 4 {
+		code := r.order.Uint16(options[0:])
+		length := int(r.order.Uint16(options[2:]))
+
+		if code == 0 || 4+length > len(options) {
+			break
+		}
+
+		if code == ngOptionTsResolution && length >= 1 {
+			value := options[4]
+			if value&0x80 == 0 {
+				ifc.Resolution = math.Pow(10, -float64(value))
+			} else {
+				ifc.Resolution = math.Pow(2, -float64(value&0x7f))
+			}
+		}
+
+		options = options[4+(length+3)&^3:]
+	}
+
+	r.interfaces = append(r.interfaces, ifc)
+	return nil
+}
+
+func (r *Reader) toTime(ts uint64, resolution float64) time.Time {
+	if resolution == 1e-9 {
+		return time.Unix(0, int64(ts))
+	}
+
+	if resolution == 1e-6 {
+		return time.Unix(0, int64(ts)*int64(time.Microsecond))
+	}
+
+	seconds := float64(ts) * resolution
+	whole := math.Floor(seconds)
+	return time.Unix(int64(whole), int64((seconds-whole)*1e9))
+}
+
+func (r *Reader) grow(size int) []byte {
+	if cap(r.buffer) < size {
+		r.buffer = make([]byte, size)
+	}
+	return r.buffer[:size]
+}
+
+// decode strips the link layer, IPv4 and UDP headers and returns false when
+// the frame is not an unfragmented IPv4 UDP datagram
+func (r *Reader) decode(res *Packet, frame []byte, linkType uint16) bool {
+	var ip []byte
+
+	switch linkType {
+	case LinkTypeIPv4, linkTypeRaw:
+		ip = frame
+
+	case linkTypeNull:
+		if len(frame) < 4 {
+			return false
+		}
+		ip = frame[4:]
+
+	case linkTypeEthernet:
+		if len(frame) < 14 {
+			return false
+		}
+
+		etherType := binary.BigEndian.Uint16(frame[12:])
+		ip = frame[14:]
+
+		if etherType == etherTypeVLAN {
+			if len(frame) < 18 {
+				return false
+			}
+			etherType = binary.BigEndian.Uint16(frame[16:])
+			ip = frame[18:]
+		}
+
+		if etherType != etherTypeIPv4 {
+			return false
+		}
+
+	case linkTypeLinuxSLL:
+		if len(frame) < 16 || binary.BigEndian.Uint16(frame[14:]) != etherTypeIPv4 {
+			return false
+		}
+		ip = frame[16:]
+
+	case linkTypeLinuxSLL2:
+		if len(frame) < 20 || binary.BigEndian.Uint16(frame[0:]) != etherTypeIPv4 {
+			return false
+		}
+		ip = frame[20:]
+
+	default:
+		return false
+	}
+
+	if len(ip) < ipv4HeaderLen || ip[0]>>4 != 4 || ip[9] != protocolUDP {
+		return false
+	}
+
+	// Skip fragments (more fragments flag, or a fragment offset)
+	if binary.BigEndian.Uint16(ip[6:])&0x3fff != 0 {
+		return false
+	}
+
+	headerLen := int(ip[0]&0x0f) * 4
+	if headerLen < ipv4HeaderLen || len(ip) < headerLen+udpHeaderLen {
+		return false
+	}
+
+	udp := ip[headerLen:]
+	udpLen := int(binary.BigEndian.Uint16(udp[4:]))
+
+	if udpLen < udpHeaderLen || udpLen > len(udp) {
+		return false
+	}
+
+	res.Source = utils.IP4Builder.FromBytes(ip[12:16], int(binary.BigEndian.Uint16(udp[0:])))
+	res.Target = utils.IP4Builder.FromBytes(ip[16:20], int(binary.BigEndian.Uint16(udp[2:])))
+	res.Payload = udp[udpHeaderLen:udpLen]
+	return true
+}
@@ -0,0 +1,166 @@
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"rvpro3/radarvision.com/utils"
+)
+
+const magicMicro = 0xa1b2c3d4
+const magicNano = 0xa1b23c4d
+const versionMajor = 2
+const versionMinor = 4
+const snapLen = 65535
+
+// LinkTypeIPv4 is the pcap link type for raw IPv4 packets without a link layer
+const LinkTypeIPv4 = 228
+
+const ipv4HeaderLen = 20
+const udpHeaderLen = 8
+const protocolUDP = 17
+
+var ErrPayloadTooLarge = errors.New("pcap payload too large")
+
+// Writer writes UDP datagrams to a classic (libpcap) capture file.  The
+// datagrams are wrapped in a synthesized IPv4 and UDP header, which means
+// the capture opens in Wireshark/tcpdump with the source and target address
+// intact.  Timestamps are written in nanosecond resolution
+type Writer struct {
+	writer  *bufio.Writer
+	closer  io.Closer
+	header  [16 + ipv4HeaderLen + udpHeaderLen]byte
+	ipId    uint16
+	Written int64
+}
+
+// Create creates (or truncates) the capture file, including any missing
+// directories, and writes the file header
+func Create(fileName string) (*Writer, error) {
+	if err := os.MkdirAll(filepath.Dir(fileName), os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := NewWriter(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	res.closer = file
+	return res, nil
+}
+
+// NewWriter writes the pcap file header to target
+func NewWriter(target io.Writer) (*Writer, error) {
+	res := &Writer{
+		writer: bufio.NewWriterSize(target, 64*utils.Kilobyte),
+	}
+
+	var header [24]byte
+	binary.LittleEndian.PutUint32(header[0:], magicNano)
+	binary.LittleEndian.PutUint16(header[4:], versionMajor)
+	binary.LittleEndian.PutUint16(header[6:], versionMinor)
+	binary.LittleEndian.PutUint32(header[16:], snapLen)
+	binary.LittleEndian.PutUint32(header[20:], LinkTypeIPv4)
+
+	if _, err := res.writer.Write(header[:]); err != nil {
+		return nil, err
+	}
+
+	res.Written = int64(len(header))
+	return res, nil
+}
+
+// WritePacket writes the UDP payload as sent from source to target at the
+// given time
+func (w *Writer) WritePacket(now time.Time, source utils.IP4, target utils.IP4, payload []byte) error {
+	packetLen := ipv4HeaderLen + udpHeaderLen + len(payload)
+
+	if packetLen > snapLen {
+		return ErrPayloadTooLarge
+	}
+
+	nanos := now.UnixNano()
+	w.ipId++
+
+	// Record header
+	record := w.header[:16]
+	binary.LittleEndian.PutUint32(record[0:], uint32(nanos/int64(time.Second)))
+	binary.LittleEndian.PutUint32(record[4:], uint32(nanos%int64(time.Second)))
+	binary.LittleEndian.PutUint32(record[8:], uint32(packetLen))
+	binary.LittleEndian.PutUint32(record[12:], uint32(packetLen))
+
+	// IPv4 header
+	ip := w.header[16 : 16+ipv4HeaderLen]
+	ip[0] = 0x45
+	ip[1] = 0
+	binary.BigEndian.PutUint16(ip[2:], uint16(packetLen))
+	binary.BigEndian.PutUint16(ip[4:], w.ipId)
+	binary.BigEndian.PutUint16(ip[6:], 0x4000) // Don't fragment
+	ip[8] = 64
+	ip[9] = protocolUDP
+	binary.BigEndian.PutUint16(ip[10:], 0)
+	copy(ip[12:16], source.Bytes[:])
+	copy(ip[16:20], target.Bytes[:])
+	binary.BigEndian.PutUint16(ip[10:], checksum(ip))
+
+	// UDP header, a zero checksum means "not computed" for IPv4
+	udp := w.header[16+ipv4HeaderLen:]
+	binary.BigEndian.PutUint16(udp[0:], uint16(source.Port))
+	binary.BigEndian.PutUint16(udp[2:], uint16(target.Port))
+	binary.BigEndian.PutUint16(udp[4:], uint16(udpHeaderLen+len(payload)))
+	binary.BigEndian.PutUint16(udp[6:], 0)
+
+	if _, err := w.writer.Write(w.header[:]); err != nil {
+		return err
+	}
+
+	if _, err := w.writer.Write(payload); err != nil {
+		return err
+	}
+
+	w.Written += int64(len(w.header) + len(payload))
+	return nil
+}
+
+// Flush writes the buffered packets to the underlying writer
+func (w *Writer) Flush() error {
+	return w.writer.Flush()
+}
+
+// Close flushes the buffered packets and closes the file (if created with Create)
+func (w *Writer) Close() error {
+	err := w.writer.Flush()
+
+	if w.closer != nil {
+		if closeErr := w.closer.Close(); err == nil {
+			err = closeErr
+		}
+		w.closer = nil
+	}
+	return err
+}
+
+func checksum(header []byte) uint16 {
+	var sum uint32
+
+	for index := 0; index+1 < len(header); index += 2 {
+		sum += uint32(binary.BigEndian.Uint16(header[index:]))
+	}
+
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}